@@ -81,8 +81,10 @@ func main() {
 	}
 
 	shutdownCh := make(chan os.Signal, 1)
+	shutdownDone := make(chan struct{})
 	signal.Notify(shutdownCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
+		defer close(shutdownDone)
 		sig := <-shutdownCh
 		logger.Info("shutdown signal received", "signal", sig.String())
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
@@ -90,6 +92,9 @@ func main() {
 		if err := server.Shutdown(ctx); err != nil {
 			logger.Error("graceful shutdown failed", "error", err)
 		}
+		if err := application.Close(ctx); err != nil {
+			logger.Error("failed to flush queued ingest snapshots", "error", err)
+		}
 	}()
 
 	logger.Info("server listening", "addr", cfg.Server.Listen, "db", dbPath)
@@ -97,5 +102,6 @@ func main() {
 		logger.Error("server stopped unexpectedly", "error", err)
 		os.Exit(1)
 	}
+	<-shutdownDone
 	logger.Info("server stopped")
 }
@@ -12,10 +12,11 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
-	"github.com/osbits/upupup/server/internal/access"
 	"github.com/osbits/upupup/server/internal/config"
 	"github.com/osbits/upupup/server/internal/hooks"
 	"github.com/osbits/upupup/server/internal/storage"
+	"github.com/osbits/upupup/shared/access"
+	"github.com/osbits/upupup/shared/maintenance"
 )
 
 // App wires configuration, storage and HTTP handlers together.
@@ -33,6 +34,9 @@ type App struct {
 	healthCfg         config.HealthConfig
 	metricsCfg        config.MetricsConfig
 	location          *time.Location
+	maintenance       []maintenance.Window
+	ingestQueue       *ingestQueue
+	ingestFilter      ingestFilter
 	promConfigMu      sync.RWMutex
 	promConfigPath    string
 	promConfigAt      time.Time
@@ -58,6 +62,7 @@ func New(ctx context.Context, cfg *config.Config, store *storage.Store, logger *
 	if err := store.EnsureIngestSchema(ctx); err != nil {
 		return nil, err
 	}
+	store.SetSnapshotHistory(cfg.Server.Ingest.HistoryRetention.Duration)
 
 	allowlist, err := access.NewAllowlist(cfg.Server.AllowedIPs)
 	if err != nil {
@@ -96,6 +101,11 @@ func New(ctx context.Context, cfg *config.Config, store *storage.Store, logger *
 		}
 	}
 
+	windows, err := maintenance.Parse(cfg.Service.Defaults.MaintenanceWindows, location, cfg.Service.Defaults.Interval.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("parse maintenance windows: %w", err)
+	}
+
 	app := &App{
 		cfg:             cfg,
 		store:           store,
@@ -110,11 +120,25 @@ func New(ctx context.Context, cfg *config.Config, store *storage.Store, logger *
 		healthCfg:       applyHealthDefaults(cfg.Server.Health),
 		metricsCfg:      applyMetricsDefaults(cfg.Server.Prometheus),
 		location:        location,
+		maintenance:     windows,
+		ingestFilter:    newIngestFilter(cfg.Server.Ingest, cfg.Checks),
+	}
+	if cfg.Server.Ingest.QueueSize > 0 {
+		app.ingestQueue = newIngestQueue(store, logger, cfg.Server.Ingest)
 	}
 	app.initialisePrometheusConfig()
 	return app, nil
 }
 
+// Close writes snapshots still queued for ingestion. Call it after the HTTP
+// server has stopped accepting requests.
+func (a *App) Close(ctx context.Context) error {
+	if a.ingestQueue == nil {
+		return nil
+	}
+	return a.ingestQueue.close(ctx)
+}
+
 // Routes returns the HTTP handler tree for the server.
 func (a *App) Routes() http.Handler {
 	r := chi.NewRouter()
@@ -132,8 +156,16 @@ func (a *App) Routes() http.Handler {
 		r.Route("/hook", func(r chi.Router) {
 			r.Post("/{hookID}", a.handleHook)
 		})
+		r.Post("/hooks/batch", a.handleHookBatch)
+		r.Route("/checks", func(r chi.Router) {
+			r.Get("/", a.handleListChecks)
+			r.Get("/{checkID}", a.handleCheck)
+		})
+		r.Get("/maintenance", a.handleMaintenance)
+		r.Get("/summary", a.handleSummary)
 		r.Route("/metrics", func(r chi.Router) {
 			r.Get("/{checkID}", a.handleMetrics)
+			r.Get("/{checkID}/raw", a.handleRawMetrics)
 		})
 		r.Route("/ingest", func(r chi.Router) {
 			r.Post("/{nodeID}", a.handleIngestMetrics)
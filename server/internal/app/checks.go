@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/osbits/upupup/server/internal/config"
+)
+
+const (
+	includeNotifications     = "notifications"
+	defaultNotificationLimit = 10
+	maxNotificationLimit     = 100
+)
+
+type checkListResponse struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Checks      []checkDetail `json:"checks"`
+}
+
+type checkDetail struct {
+	CheckID       string               `json:"check_id"`
+	Name          string               `json:"name"`
+	Type          string               `json:"type"`
+	Labels        map[string]string    `json:"labels,omitempty"`
+	LastRun       *checkRunDetail      `json:"last_run,omitempty"`
+	Notifications []notificationDetail `json:"notifications,omitempty"`
+}
+
+type notificationDetail struct {
+	NotifierID string    `json:"notifier_id"`
+	Status     string    `json:"status"`
+	Severity   string    `json:"severity,omitempty"`
+	Summary    string    `json:"summary,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	ErrorClass string    `json:"error_class,omitempty"`
+}
+
+// checkQuery holds the optional sections requested through ?include=.
+type checkQuery struct {
+	notifications     bool
+	notificationLimit int
+}
+
+func parseCheckQuery(r *http.Request) (checkQuery, error) {
+	query := checkQuery{notificationLimit: defaultNotificationLimit}
+	values := r.URL.Query()
+	for _, raw := range values["include"] {
+		for _, part := range strings.Split(raw, ",") {
+			switch strings.TrimSpace(part) {
+			case "":
+			case includeNotifications:
+				query.notifications = true
+			default:
+				return query, fmt.Errorf("unknown include %q", strings.TrimSpace(part))
+			}
+		}
+	}
+	if raw := values.Get("notification_limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return query, errors.New("notification_limit must be a positive integer")
+		}
+		if limit > maxNotificationLimit {
+			limit = maxNotificationLimit
+		}
+		query.notificationLimit = limit
+	}
+	return query, nil
+}
+
+func (a *App) handleListChecks(w http.ResponseWriter, r *http.Request) {
+	query, err := parseCheckQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := checkListResponse{
+		GeneratedAt: time.Now().UTC(),
+		Checks:      make([]checkDetail, 0, len(a.cfg.Checks)),
+	}
+	for _, check := range a.cfg.Checks {
+		detail, err := a.checkDetail(r.Context(), check, query)
+		if err != nil {
+			http.Error(w, "failed to load check data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Checks = append(resp.Checks, detail)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (a *App) handleCheck(w http.ResponseWriter, r *http.Request) {
+	checkID := chi.URLParam(r, "checkID")
+	if checkID == "" {
+		http.Error(w, "check id is required", http.StatusBadRequest)
+		return
+	}
+	check, ok := a.checkConfigs[checkID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	query, err := parseCheckQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	detail, err := a.checkDetail(r.Context(), check, query)
+	if err != nil {
+		http.Error(w, "failed to load check data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(detail)
+}
+
+func (a *App) checkDetail(ctx context.Context, check config.CheckConfig, query checkQuery) (checkDetail, error) {
+	detail := checkDetail{
+		CheckID: check.ID,
+		Name:    check.Name,
+		Type:    check.Type,
+		Labels:  check.Labels,
+	}
+	lastRun, err := a.store.LatestCheckRun(ctx, check.ID)
+	if err != nil {
+		return detail, err
+	}
+	if lastRun != nil {
+		detail.LastRun = &checkRunDetail{
+			Success:    lastRun.Success,
+			Summary:    lastRun.Summary,
+			Error:      lastRun.Error,
+			LatencyMs:  float64(lastRun.Latency.Milliseconds()),
+			OccurredAt: lastRun.OccurredAt,
+		}
+	}
+	if !query.notifications {
+		return detail, nil
+	}
+	logs, err := a.store.CheckNotificationLogs(ctx, check.ID, query.notificationLimit)
+	if err != nil {
+		return detail, err
+	}
+	for _, entry := range logs {
+		detail.Notifications = append(detail.Notifications, notificationDetail{
+			NotifierID: entry.NotifierID,
+			Status:     entry.Status,
+			Severity:   entry.Severity,
+			Summary:    entry.Summary,
+			OccurredAt: entry.OccurredAt,
+			ErrorClass: entry.ErrorClass,
+		})
+	}
+	return detail, nil
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/storage"
+)
+
+func newChecksTestApp(t *testing.T) *App {
+	t.Helper()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	_, err = store.DB().Exec(`
+		CREATE TABLE IF NOT EXISTS check_states (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			check_id TEXT NOT NULL,
+			check_name TEXT NOT NULL,
+			success INTEGER NOT NULL,
+			summary TEXT,
+			error TEXT,
+			latency_ms INTEGER,
+			occurred_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS notification_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			notifier_id TEXT NOT NULL,
+			check_id TEXT NOT NULL,
+			check_name TEXT NOT NULL,
+			run_id TEXT,
+			status TEXT,
+			severity TEXT,
+			summary TEXT,
+			labels_json TEXT,
+			occurred_at TIMESTAMP NOT NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	now := time.Now().UTC()
+	_, err = store.DB().Exec(`
+		INSERT INTO check_states (check_id, check_name, success, summary, error, latency_ms, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, "api", "API", 0, "status 500", "unexpected status", 80, now)
+	if err != nil {
+		t.Fatalf("insert check_state: %v", err)
+	}
+	notifications := []struct {
+		notifier string
+		checkID  string
+		status   string
+		at       time.Time
+	}{
+		{"oncall", "api", "firing", now.Add(-3 * time.Minute)},
+		{"slack", "api", "firing", now.Add(-2 * time.Minute)},
+		{"slack", "db", "firing", now.Add(-time.Minute)},
+		{"slack", "api", "resolved", now},
+	}
+	for _, n := range notifications {
+		_, err = store.DB().Exec(`
+			INSERT INTO notification_logs (notifier_id, check_id, check_name, status, severity, summary, occurred_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, n.notifier, n.checkID, n.checkID, n.status, "critical", "summary", n.at)
+		if err != nil {
+			t.Fatalf("insert notification_log: %v", err)
+		}
+	}
+
+	checks := []config.CheckConfig{{ID: "api", Name: "API", Type: "http"}, {ID: "db", Name: "DB", Type: "tcp"}}
+	return &App{
+		cfg:   &config.Config{Checks: checks},
+		store: store,
+		checkConfigs: map[string]config.CheckConfig{
+			"api": checks[0],
+			"db":  checks[1],
+		},
+	}
+}
+
+func getCheckDetail(t *testing.T, app *App, target string) (int, checkDetail) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("checkID", "api")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	app.handleCheck(rec, req)
+
+	var detail checkDetail
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rec.Code, detail
+}
+
+func TestCheckDetailIncludesRecentNotifications(t *testing.T) {
+	app := newChecksTestApp(t)
+
+	code, detail := getCheckDetail(t, app, "/api/checks/api?include=notifications")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if detail.LastRun == nil || detail.LastRun.Success {
+		t.Fatalf("expected failed last run, got %+v", detail.LastRun)
+	}
+	if len(detail.Notifications) != 3 {
+		t.Fatalf("expected 3 notifications for api, got %+v", detail.Notifications)
+	}
+	first := detail.Notifications[0]
+	if first.NotifierID != "slack" || first.Status != "resolved" || first.Severity != "critical" {
+		t.Fatalf("expected newest notification first, got %+v", first)
+	}
+	if last := detail.Notifications[2]; last.NotifierID != "oncall" {
+		t.Fatalf("expected oldest notification last, got %+v", last)
+	}
+
+	_, limited := getCheckDetail(t, app, "/api/checks/api?include=notifications&notification_limit=1")
+	if len(limited.Notifications) != 1 {
+		t.Fatalf("expected notification_limit to cap results, got %+v", limited.Notifications)
+	}
+}
+
+func TestCheckDetailOmitsNotificationsByDefault(t *testing.T) {
+	app := newChecksTestApp(t)
+
+	code, detail := getCheckDetail(t, app, "/api/checks/api")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if len(detail.Notifications) != 0 {
+		t.Fatalf("expected no notifications without include, got %+v", detail.Notifications)
+	}
+
+	if code, _ := getCheckDetail(t, app, "/api/checks/api?include=bogus"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown include, got %d", code)
+	}
+}
+
+func TestListChecksIncludesNotificationsPerCheck(t *testing.T) {
+	app := newChecksTestApp(t)
+
+	rec := httptest.NewRecorder()
+	app.handleListChecks(rec, httptest.NewRequest(http.MethodGet, "/api/checks?include=notifications", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	var resp checkListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(resp.Checks))
+	}
+	if got := len(resp.Checks[0].Notifications); got != 3 {
+		t.Fatalf("expected 3 notifications for api, got %d", got)
+	}
+	db := resp.Checks[1]
+	if db.LastRun != nil {
+		t.Fatalf("expected no last run for db, got %+v", db.LastRun)
+	}
+	if len(db.Notifications) != 1 || db.Notifications[0].NotifierID != "slack" {
+		t.Fatalf("expected only db notifications, got %+v", db.Notifications)
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/shared/computed"
+)
+
+// computedSample is the value of a computed metric evaluated from a node
+// payload, mirroring what the worker compares against thresholds.
+type computedSample struct {
+	name  string
+	value float64
+}
+
+// evaluateComputedMetrics evaluates every computed metric of a metrics check
+// against the node payload. Metrics that cannot be evaluated are skipped and
+// reported through the returned error map.
+func evaluateComputedMetrics(payload string, specs map[string]config.ComputedMetric) ([]computedSample, map[string]error) {
+	if len(specs) == 0 || strings.TrimSpace(payload) == "" {
+		return nil, nil
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(payload))
+	if err != nil {
+		errs := make(map[string]error, len(specs))
+		for name := range specs {
+			errs[name] = fmt.Errorf("parse node metrics: %w", err)
+		}
+		return nil, errs
+	}
+
+	values, errs := computed.NewEvaluator(families, specs).Values()
+	samples := make([]computedSample, 0, len(values))
+	for name, value := range values {
+		samples = append(samples, computedSample{name: name, value: value})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+	return samples, errs
+}
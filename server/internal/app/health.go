@@ -3,11 +3,13 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/shared/schedule"
 )
 
 type healthResponse struct {
@@ -102,6 +104,9 @@ func (a *App) evaluateChecks(ctx context.Context, now time.Time) []checkComponen
 	results := make([]checkComponent, 0, len(a.cfg.Checks))
 	requiredRuns := a.healthCfg.RequiredRecentRuns
 	multiplier := a.healthCfg.MaxIntervalMultiplier
+	// The worker skips every check during a maintenance window, so missing
+	// or stale runs are expected and not worth a warning.
+	inMaintenance := a.inMaintenance(now)
 
 	for _, check := range a.cfg.Checks {
 		result := checkComponent{
@@ -128,7 +133,10 @@ func (a *App) evaluateChecks(ctx context.Context, now time.Time) []checkComponen
 			continue
 		}
 		if lastRun == nil {
-			if a.healthCfg.SkipChecksWithNoHistory {
+			if inMaintenance {
+				result.Status = statusOK
+				result.Detail = "in maintenance"
+			} else if a.healthCfg.SkipChecksWithNoHistory {
 				result.Status = statusOK
 				result.Detail = "no history yet - skipped"
 			} else if a.healthCfg.FailOnMissingCheckState {
@@ -158,16 +166,19 @@ func (a *App) evaluateChecks(ctx context.Context, now time.Time) []checkComponen
 				results = append(results, result)
 				continue
 			}
-			if count < requiredRuns {
+			if count < requiredRuns && !inMaintenance {
 				result.Status = statusWarn
 				result.Detail = "insufficient recent check runs"
 			}
 		}
 
-		if dt := now.Sub(lastRun.OccurredAt); dt > window {
+		if dt := now.Sub(lastRun.OccurredAt); dt > window && !inMaintenance {
 			result.Status = statusWarn
 			result.Detail = "last run exceeded expected interval"
 		}
+		if inMaintenance {
+			result.Detail = appendDetail(result.Detail, "in maintenance")
+		}
 		if !lastRun.Success {
 			if result.Status == statusOK {
 				result.Status = statusWarn
@@ -206,6 +217,21 @@ func (a *App) evaluateNotifications(ctx context.Context) componentStatus {
 			break
 		}
 	}
+	// Auth and permanent delivery errors do not clear on retry, so they
+	// point at a notifier that needs its configuration fixed.
+	misconfigured := map[string]struct{}{}
+	for _, entry := range logs {
+		if entry.ErrorClass != "auth" && entry.ErrorClass != "permanent" {
+			continue
+		}
+		key := entry.NotifierID + ":" + entry.ErrorClass
+		if _, seen := misconfigured[key]; seen {
+			continue
+		}
+		misconfigured[key] = struct{}{}
+		status.Status = statusWarn
+		status.Detail = appendDetail(status.Detail, fmt.Sprintf("notifier %s failing with %s errors", entry.NotifierID, entry.ErrorClass))
+	}
 	return status
 }
 
@@ -253,14 +279,26 @@ func deriveOverallStatus(databaseStatus string, notificationStatus string, check
 	return status
 }
 
+// effectiveInterval returns how often the worker runs the check, raised to
+// min_interval as the worker does. A cron-scheduled check is expected at
+// the longest gap between its upcoming activations.
 func (a *App) effectiveInterval(check config.CheckConfig) time.Duration {
-	if check.Schedule != nil && check.Schedule.Interval != nil && check.Schedule.Interval.Set {
-		return check.Schedule.Interval.Duration
+	if check.Schedule != nil && strings.TrimSpace(check.Schedule.Cron) != "" {
+		spec := strings.TrimSpace(check.Schedule.Cron)
+		interval, err := schedule.CronInterval(spec, a.localTime(time.Now()))
+		if err == nil {
+			return interval
+		}
+		a.logger.Warn("invalid check cron, expecting the default interval", "check_id", check.ID, "cron", spec, "error", err)
 	}
-	if a.serviceDefaults.Interval.Duration > 0 {
-		return a.serviceDefaults.Interval.Duration
+	interval := 60 * time.Second
+	if check.Schedule != nil && check.Schedule.Interval != nil && check.Schedule.Interval.Set {
+		interval = check.Schedule.Interval.Duration
+	} else if a.serviceDefaults.Interval.Duration > 0 {
+		interval = a.serviceDefaults.Interval.Duration
 	}
-	return 60 * time.Second
+	interval, _ = schedule.ClampInterval(interval, a.serviceDefaults.MinInterval.Duration)
+	return interval
 }
 
 func appendDetail(existing, addition string) string {
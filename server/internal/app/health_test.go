@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/storage"
+	"github.com/osbits/upupup/shared/maintenance"
+)
+
+func newHealthTestApp(t *testing.T, lastRunAt time.Time, specs []config.MaintenanceSpec) *App {
+	t.Helper()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	_, err = store.DB().Exec(`
+		CREATE TABLE IF NOT EXISTS check_states (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			check_id TEXT NOT NULL,
+			check_name TEXT NOT NULL,
+			success INTEGER NOT NULL,
+			summary TEXT,
+			error TEXT,
+			latency_ms INTEGER,
+			occurred_at TIMESTAMP NOT NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("create check_states: %v", err)
+	}
+	if !lastRunAt.IsZero() {
+		_, err = store.DB().Exec(`
+			INSERT INTO check_states (check_id, check_name, success, summary, error, latency_ms, occurred_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, "api", "API", 1, "", "", 120, lastRunAt)
+		if err != nil {
+			t.Fatalf("insert check_state: %v", err)
+		}
+	}
+
+	parsed, err := maintenance.Parse(specs, time.UTC, time.Minute)
+	if err != nil {
+		t.Fatalf("parse maintenance: %v", err)
+	}
+	return &App{
+		cfg: &config.Config{
+			Checks: []config.CheckConfig{{ID: "api", Name: "API"}},
+		},
+		store:     store,
+		healthCfg: config.HealthConfig{MaxIntervalMultiplier: 3, RequiredRecentRuns: 1},
+		serviceDefaults: config.ServiceDefault{
+			Interval: config.Duration{Duration: time.Minute},
+		},
+		location:    time.UTC,
+		maintenance: parsed,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func activeRangeWindow(now time.Time) []config.MaintenanceSpec {
+	layout := "2006-01-02T15:04"
+	return []config.MaintenanceSpec{{
+		Kind: config.MaintenanceKindRange,
+		Expr: now.Add(-2*time.Hour).Format(layout) + "-" + now.Add(time.Hour).Format(layout),
+	}}
+}
+
+func TestEvaluateChecksStaleRunWarnsOutsideMaintenance(t *testing.T) {
+	now := time.Now().UTC()
+	app := newHealthTestApp(t, now.Add(-time.Hour), nil)
+
+	checks := app.evaluateChecks(context.Background(), now)
+	if len(checks) != 1 || checks[0].Status != statusWarn {
+		t.Fatalf("expected stale check to warn, got %+v", checks)
+	}
+}
+
+func TestEvaluateChecksExpectsRunsAtMinInterval(t *testing.T) {
+	now := time.Now().UTC()
+	app := newHealthTestApp(t, now.Add(-time.Hour), nil)
+	app.serviceDefaults.MinInterval = config.Duration{Duration: time.Hour}
+
+	checks := app.evaluateChecks(context.Background(), now)
+	if len(checks) != 1 || checks[0].Status != statusOK {
+		t.Fatalf("expected a check clamped to min_interval not to be stale, got %+v", checks)
+	}
+	if checks[0].RecentWithinSecs != int64((3 * time.Hour).Seconds()) {
+		t.Fatalf("expected the window to follow min_interval, got %d", checks[0].RecentWithinSecs)
+	}
+}
+
+func TestEvaluateChecksExpectsCronChecksAtTheirSchedule(t *testing.T) {
+	now := time.Now().UTC()
+	app := newHealthTestApp(t, now.Add(-90*time.Minute), nil)
+	app.cfg.Checks[0].Schedule = &config.CheckSchedule{Cron: "0 * * * *"}
+
+	checks := app.evaluateChecks(context.Background(), now)
+	if len(checks) != 1 || checks[0].Status != statusOK {
+		t.Fatalf("expected an hourly cron check not to be stale, got %+v", checks)
+	}
+	if checks[0].RecentWithinSecs != int64((3 * time.Hour).Seconds()) {
+		t.Fatalf("expected the window to follow the cron schedule, got %d", checks[0].RecentWithinSecs)
+	}
+}
+
+func TestEvaluateChecksStaleRunOKDuringMaintenance(t *testing.T) {
+	now := time.Now().UTC()
+	app := newHealthTestApp(t, now.Add(-time.Hour), activeRangeWindow(now))
+
+	checks := app.evaluateChecks(context.Background(), now)
+	if len(checks) != 1 {
+		t.Fatalf("expected one check, got %d", len(checks))
+	}
+	if checks[0].Status != statusOK {
+		t.Fatalf("expected ok during maintenance, got %+v", checks[0])
+	}
+	if !strings.Contains(checks[0].Detail, "in maintenance") {
+		t.Fatalf("expected maintenance detail, got %q", checks[0].Detail)
+	}
+}
+
+func TestEvaluateChecksNoHistoryOKDuringCronMaintenance(t *testing.T) {
+	now := time.Now().UTC()
+	windows := []config.MaintenanceSpec{{Kind: config.MaintenanceKindCron, Expr: "* * * * *"}}
+	app := newHealthTestApp(t, time.Time{}, windows)
+
+	checks := app.evaluateChecks(context.Background(), now)
+	if len(checks) != 1 || checks[0].Status != statusOK || checks[0].Detail != "in maintenance" {
+		t.Fatalf("expected missing runs to be ok during maintenance, got %+v", checks)
+	}
+}
+
+func TestEvaluateNotificationsWarnsOnAuthErrors(t *testing.T) {
+	now := time.Now().UTC()
+	app := newHealthTestApp(t, now, nil)
+	app.healthCfg.NotificationErrorLookback = 10
+	_, err := app.store.DB().Exec(`
+		CREATE TABLE IF NOT EXISTS notification_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			notifier_id TEXT NOT NULL,
+			check_id TEXT NOT NULL,
+			check_name TEXT NOT NULL,
+			run_id TEXT,
+			status TEXT,
+			severity TEXT,
+			summary TEXT,
+			labels_json TEXT,
+			occurred_at TIMESTAMP NOT NULL,
+			error_class TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT ''
+		);
+	`)
+	if err != nil {
+		t.Fatalf("create notification_logs: %v", err)
+	}
+	entries := []struct {
+		notifier   string
+		errorClass string
+	}{
+		{"slack", ""},
+		{"pager", "transient"},
+		{"github", "auth"},
+		{"github", "auth"},
+	}
+	for i, e := range entries {
+		_, err = app.store.DB().Exec(`
+			INSERT INTO notification_logs (notifier_id, check_id, check_name, status, summary, occurred_at, error_class)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, e.notifier, "api", "API", "firing", "api failing", now.Add(time.Duration(i)*time.Second), e.errorClass)
+		if err != nil {
+			t.Fatalf("insert notification_log: %v", err)
+		}
+	}
+
+	status := app.evaluateNotifications(context.Background())
+	if status.Status != statusWarn || status.Detail != "notifier github failing with auth errors" {
+		t.Fatalf("expected warning for auth errors only, got %+v", status)
+	}
+}
+
+func TestHandleMaintenanceReportsWindows(t *testing.T) {
+	now := time.Now().UTC()
+	windows := append(activeRangeWindow(now), config.MaintenanceSpec{Kind: config.MaintenanceKindCron, Expr: "0 3 * * *"})
+	app := newHealthTestApp(t, now, windows)
+
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/maintenance", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp maintenanceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.Active || len(resp.Windows) != 2 {
+		t.Fatalf("expected active response with two windows, got %+v", resp)
+	}
+	rangeWindow, cronWindow := resp.Windows[0], resp.Windows[1]
+	if !rangeWindow.Active || rangeWindow.ActiveUntil == nil || rangeWindow.NextStart != nil {
+		t.Fatalf("expected open range window without next occurrence, got %+v", rangeWindow)
+	}
+	if cronWindow.NextStart == nil || cronWindow.NextEnd == nil || cronWindow.Duration != "1m0s" {
+		t.Fatalf("expected cron window with next occurrence, got %+v", cronWindow)
+	}
+	if next := cronWindow.NextStart.UTC(); next.Hour() != 3 || next.Minute() != 0 || !next.After(now) || next.Sub(now) > 24*time.Hour {
+		t.Fatalf("unexpected next cron start %s", next)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -9,7 +10,9 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/osbits/upupup/server/internal/config"
 	"github.com/osbits/upupup/server/internal/hooks"
+	"github.com/osbits/upupup/server/internal/storage"
 )
 
 type hookRequestPayload struct {
@@ -39,6 +42,20 @@ type hookResponsePayload struct {
 	Message           string            `json:"message,omitempty"`
 }
 
+type hookBatchRequestPayload struct {
+	Hooks []hookBatchItem `json:"hooks"`
+}
+
+type hookBatchItem struct {
+	HookID string `json:"hook_id"`
+	hookRequestPayload
+}
+
+type hookBatchResponsePayload struct {
+	Status     string                `json:"status"`
+	Executions []hookResponsePayload `json:"executions"`
+}
+
 func (a *App) handleHook(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	hookID := chi.URLParam(r, "hookID")
@@ -53,15 +70,43 @@ func (a *App) handleHook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	clientIPStr := a.clientIP(ctx)
-	if allow, ok := a.hookAllowlist[hookID]; ok {
-		ip := net.ParseIP(clientIPStr)
-		if !allow.Allowed(ip) {
-			http.Error(w, "forbidden", http.StatusForbidden)
+	if !a.hookAllowed(hookID, clientIPStr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var payload hookRequestPayload
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			http.Error(w, "invalid json payload: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 
-	var payload hookRequestPayload
+	opts, err := hookInvokeOptions(cfg, payload, clientIPStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exec, err := a.hookManager.Invoke(ctx, hookID, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(hookResponse(exec, cfg))
+}
+
+// handleHookBatch invokes several hooks at once, e.g. to pause every scope
+// touched by a deploy. The batch is all-or-nothing: any unknown, forbidden
+// or invalid entry rejects the request and no execution is stored.
+func (a *App) handleHookBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var payload hookBatchRequestPayload
 	if r.Body != nil {
 		defer r.Body.Close()
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
@@ -69,13 +114,65 @@ func (a *App) handleHook(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if len(payload.Hooks) == 0 {
+		http.Error(w, "hooks are required", http.StatusBadRequest)
+		return
+	}
+
+	clientIPStr := a.clientIP(ctx)
+	invocations := make([]hooks.Invocation, 0, len(payload.Hooks))
+	for i, item := range payload.Hooks {
+		cfg, ok := a.hookConfigs[item.HookID]
+		if !ok {
+			http.Error(w, fmt.Sprintf("hooks[%d]: unknown hook %q", i, item.HookID), http.StatusNotFound)
+			return
+		}
+		if !a.hookAllowed(item.HookID, clientIPStr) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		opts, err := hookInvokeOptions(cfg, item.hookRequestPayload, clientIPStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("hooks[%d]: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		invocations = append(invocations, hooks.Invocation{HookID: item.HookID, Options: opts})
+	}
 
+	execs, err := a.hookManager.InvokeBatch(ctx, invocations)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := hookBatchResponsePayload{
+		Status:     "accepted",
+		Executions: make([]hookResponsePayload, 0, len(execs)),
+	}
+	for _, exec := range execs {
+		resp.Executions = append(resp.Executions, hookResponse(exec, a.hookConfigs[exec.HookID]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// hookAllowed applies the hook's own allowlist, if any, to the client.
+func (a *App) hookAllowed(hookID, clientIP string) bool {
+	allow, ok := a.hookAllowlist[hookID]
+	if !ok {
+		return true
+	}
+	return allow.Allowed(net.ParseIP(clientIP))
+}
+
+// hookInvokeOptions turns a request payload into invocation options.
+func hookInvokeOptions(cfg config.HookConfig, payload hookRequestPayload, clientIP string) (hooks.InvokeOptions, error) {
 	var durationOverride *time.Duration
 	if payload.Duration != "" {
 		d, err := time.ParseDuration(payload.Duration)
 		if err != nil {
-			http.Error(w, "invalid duration: "+err.Error(), http.StatusBadRequest)
-			return
+			return hooks.InvokeOptions{}, fmt.Errorf("invalid duration: %w", err)
 		}
 		durationOverride = &d
 	}
@@ -91,27 +188,23 @@ func (a *App) handleHook(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if requestedBy == "" {
-		requestedBy = clientIPStr
+		requestedBy = clientIP
 	}
 
-	opts := hooks.InvokeOptions{
+	return hooks.InvokeOptions{
 		DurationOverride:   durationOverride,
 		UntilFirstSuccess:  payload.UntilFirstSuccess,
 		Note:               payload.Note,
 		RequestedBy:        requestedBy,
-		RequestedFromIP:    clientIPStr,
+		RequestedFromIP:    clientIP,
 		AdditionalMetadata: payload.Metadata,
-	}
-
-	exec, err := a.hookManager.Invoke(ctx, hookID, opts)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+	}, nil
+}
 
+func hookResponse(exec storage.HookExecution, cfg config.HookConfig) hookResponsePayload {
 	resp := hookResponsePayload{
 		Status:            "accepted",
-		HookID:            hookID,
+		HookID:            exec.HookID,
 		ExecutionID:       exec.ID,
 		Kind:              exec.Kind,
 		Scope:             exec.Scope,
@@ -130,8 +223,5 @@ func (a *App) handleHook(w http.ResponseWriter, r *http.Request) {
 		secs := int64(duration / time.Second)
 		resp.DurationSeconds = &secs
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(resp)
+	return resp
 }
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/hooks"
+	"github.com/osbits/upupup/server/internal/storage"
+)
+
+func newHookTestApp(t *testing.T, hookCfgs ...config.HookConfig) (*App, *storage.Store) {
+	t.Helper()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	if err := store.EnsureHookSchema(context.Background()); err != nil {
+		t.Fatalf("ensure hook schema: %v", err)
+	}
+	byID := make(map[string]config.HookConfig, len(hookCfgs))
+	for _, hook := range hookCfgs {
+		byID[hook.ID] = hook
+	}
+	return &App{
+		cfg:         &config.Config{Hooks: hookCfgs},
+		store:       store,
+		hookManager: hooks.NewManager(store, hookCfgs),
+		hookConfigs: byID,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, store
+}
+
+func pauseHook(id, target string) config.HookConfig {
+	return config.HookConfig{
+		ID: id,
+		Action: config.HookAction{
+			Kind:      "pause_notifications",
+			Scope:     "check",
+			TargetIDs: []string{target},
+		},
+	}
+}
+
+func postHookBatch(app *App, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/hooks/batch", strings.NewReader(body)))
+	return rec
+}
+
+func TestHookInvokeStoresExecution(t *testing.T) {
+	app, store := newHookTestApp(t, pauseHook("pause-api", "api"))
+
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/hook/pause-api", strings.NewReader(`{"duration": "15m", "note": "deploy 42"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp hookResponsePayload
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.ExecutionID == 0 {
+		t.Fatalf("expected an execution id, got %+v", resp)
+	}
+
+	active, err := store.ActiveHookExecutions(context.Background(), time.Now().UTC())
+	if err != nil {
+		t.Fatalf("active hooks: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != resp.ExecutionID || active[0].HookID != "pause-api" || active[0].Note != "deploy 42" {
+		t.Fatalf("expected execution %d to be stored, got %+v", resp.ExecutionID, active)
+	}
+}
+
+func TestHookBatchCreatesAllExecutions(t *testing.T) {
+	app, store := newHookTestApp(t, pauseHook("pause-api", "api"), pauseHook("pause-web", "web"))
+
+	rec := postHookBatch(app, `{"hooks": [
+		{"hook_id": "pause-api", "duration": "15m", "note": "deploy 42"},
+		{"hook_id": "pause-web", "duration_seconds": 600}
+	]}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp hookBatchResponsePayload
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Executions) != 2 || resp.Executions[0].HookID != "pause-api" || resp.Executions[1].HookID != "pause-web" {
+		t.Fatalf("expected both executions in request order, got %+v", resp.Executions)
+	}
+	if resp.Executions[0].Note != "deploy 42" || *resp.Executions[1].DurationSeconds != 600 {
+		t.Fatalf("expected per-entry options to apply, got %+v", resp.Executions)
+	}
+
+	active, err := store.ActiveHookExecutions(context.Background(), time.Now().UTC())
+	if err != nil {
+		t.Fatalf("active hooks: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("expected 2 active executions, got %d", len(active))
+	}
+}
+
+func TestHookBatchRollsBackOnInvalidHook(t *testing.T) {
+	// The broken hook has no action kind, so storage rejects it after the
+	// first execution was already inserted in the same transaction.
+	broken := config.HookConfig{ID: "broken", Action: config.HookAction{Scope: "check"}}
+	app, store := newHookTestApp(t, pauseHook("pause-api", "api"), broken)
+
+	cases := map[string]string{
+		"storage rejects entry": `{"hooks": [{"hook_id": "pause-api"}, {"hook_id": "broken"}]}`,
+		"unknown hook":          `{"hooks": [{"hook_id": "pause-api"}, {"hook_id": "missing"}]}`,
+		"invalid duration":      `{"hooks": [{"hook_id": "pause-api"}, {"hook_id": "pause-api", "duration": "soon"}]}`,
+	}
+	for name, body := range cases {
+		rec := postHookBatch(app, body)
+		if rec.Code == http.StatusAccepted {
+			t.Fatalf("%s: expected batch to be rejected, got 202", name)
+		}
+		active, err := store.ActiveHookExecutions(context.Background(), time.Now().UTC())
+		if err != nil {
+			t.Fatalf("active hooks: %v", err)
+		}
+		if len(active) != 0 {
+			t.Fatalf("%s: expected no executions after a rejected batch, got %d", name, len(active))
+		}
+	}
+}
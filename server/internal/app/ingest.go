@@ -25,6 +25,11 @@ func (a *App) handleIngestMetrics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "node id is required", http.StatusBadRequest)
 		return
 	}
+	format, ok := negotiateIngestFormat(r.Header.Get("Content-Type"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
 
 	reader := http.MaxBytesReader(w, r.Body, maxIngestPayloadBytes)
 	defer reader.Close()
@@ -49,6 +54,15 @@ func (a *App) handleIngestMetrics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "payload is empty", http.StatusBadRequest)
 		return
 	}
+	payload, err = convertIngestPayload(format, payload)
+	if err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	payload, dropped, truncated := a.ingestFilter.apply(payload)
+	if truncated {
+		a.logger.Warn("stored metrics payload cut to max_stored_bytes", "node_id", nodeID, "max_stored_bytes", a.ingestFilter.maxBytes)
+	}
 	metrics := string(payload)
 
 	ingestedAt := time.Now().UTC()
@@ -58,19 +72,31 @@ func (a *App) handleIngestMetrics(w http.ResponseWriter, r *http.Request) {
 		SourceIP:   a.clientIP(ctx),
 		IngestedAt: ingestedAt,
 	}
-	if err := a.store.UpsertNodeMetrics(ctx, snapshot); err != nil {
+	status := "stored"
+	if a.ingestQueue != nil {
+		if err := a.ingestQueue.enqueue(snapshot); err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		status = "queued"
+	} else if err := a.store.UpsertNodeMetrics(ctx, snapshot); err != nil {
 		http.Error(w, "failed to persist metrics: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	resp := struct {
-		Status     string    `json:"status"`
-		NodeID     string    `json:"node_id"`
-		IngestedAt time.Time `json:"ingested_at"`
+		Status        string    `json:"status"`
+		NodeID        string    `json:"node_id"`
+		IngestedAt    time.Time `json:"ingested_at"`
+		DroppedSeries int       `json:"dropped_series,omitempty"`
+		Truncated     bool      `json:"truncated,omitempty"`
 	}{
-		Status:     "stored",
-		NodeID:     nodeID,
-		IngestedAt: ingestedAt,
+		Status:        status,
+		NodeID:        nodeID,
+		IngestedAt:    ingestedAt,
+		DroppedSeries: dropped,
+		Truncated:     truncated,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
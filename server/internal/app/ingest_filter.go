@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/osbits/upupup/server/internal/config"
+)
+
+// ingestFilter trims a Prometheus text payload before it is stored: only
+// series whose name starts with one of prefixes are kept, and the result is
+// cut to maxBytes at a line boundary. The zero value stores payloads as-is.
+type ingestFilter struct {
+	prefixes []string
+	maxBytes int
+}
+
+// newIngestFilter combines keep_series with, when keep_referenced_series is
+// set, the metric names referenced by the configured metrics checks.
+func newIngestFilter(cfg config.IngestConfig, checks []config.CheckConfig) ingestFilter {
+	seen := map[string]bool{}
+	var prefixes []string
+	add := func(prefix string) {
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "" && !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	for _, prefix := range cfg.KeepSeries {
+		add(prefix)
+	}
+	if cfg.KeepReferencedSeries {
+		for _, name := range referencedMetricNames(checks) {
+			add(name)
+		}
+	}
+	sort.Strings(prefixes)
+	return ingestFilter{prefixes: prefixes, maxBytes: cfg.MaxStoredBytes}
+}
+
+// referencedMetricNames lists the raw metric names metrics checks read:
+// threshold names that are not computed metrics and computed variables.
+func referencedMetricNames(checks []config.CheckConfig) []string {
+	var names []string
+	for _, check := range checks {
+		if check.Metrics == nil {
+			continue
+		}
+		for _, threshold := range check.Metrics.Thresholds {
+			if _, computed := check.Metrics.Computed[threshold.Name]; !computed {
+				names = append(names, threshold.Name)
+			}
+		}
+		for _, metric := range check.Metrics.Computed {
+			for _, variable := range metric.Variables {
+				names = append(names, variable.Name)
+			}
+		}
+	}
+	return names
+}
+
+// apply returns the payload to store, how many sample lines were dropped by
+// the prefix filter and whether the size cap cut it.
+func (f ingestFilter) apply(payload []byte) ([]byte, int, bool) {
+	dropped := 0
+	if len(f.prefixes) > 0 {
+		var kept bytes.Buffer
+		kept.Grow(len(payload))
+		for _, line := range bytes.SplitAfter(payload, []byte("\n")) {
+			name, sample, ok := exposedName(line)
+			if ok && !f.keeps(name) {
+				if sample {
+					dropped++
+				}
+				continue
+			}
+			kept.Write(line)
+		}
+		payload = kept.Bytes()
+	}
+	if f.maxBytes <= 0 || len(payload) <= f.maxBytes {
+		return payload, dropped, false
+	}
+	cut := bytes.LastIndexByte(payload[:f.maxBytes], '\n')
+	return payload[:cut+1], dropped, true
+}
+
+func (f ingestFilter) keeps(name string) bool {
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// exposedName returns the metric name of a sample line or of a HELP, TYPE
+// or UNIT comment. ok is false for other comments and blank lines, which
+// are kept.
+func exposedName(line []byte) (name string, sample bool, ok bool) {
+	text := strings.TrimSpace(string(line))
+	if text == "" {
+		return "", false, false
+	}
+	if strings.HasPrefix(text, "#") {
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return "", false, false
+		}
+		switch fields[1] {
+		case "HELP", "TYPE", "UNIT":
+			return fields[2], false, true
+		}
+		return "", false, false
+	}
+	end := strings.IndexAny(text, "{ \t")
+	if end < 0 {
+		end = len(text)
+	}
+	return text[:end], true, true
+}
@@ -0,0 +1,268 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// ingestFormat identifies how an ingest payload is encoded.
+type ingestFormat int
+
+const (
+	ingestFormatPrometheus ingestFormat = iota
+	ingestFormatJSON
+	ingestFormatInfluxLine
+)
+
+// negotiateIngestFormat maps a Content-Type onto a supported payload format.
+// A missing Content-Type is treated as Prometheus text exposition.
+func negotiateIngestFormat(contentType string) (ingestFormat, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return ingestFormatPrometheus, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, false
+	}
+	switch mediaType {
+	case "text/plain", "application/openmetrics-text":
+		return ingestFormatPrometheus, true
+	case "application/json":
+		return ingestFormatJSON, true
+	case "application/x-influxdb-line-protocol", "text/x-influxdb-line-protocol":
+		return ingestFormatInfluxLine, true
+	default:
+		return 0, false
+	}
+}
+
+// ingestSample is one value of a JSON ingest payload.
+type ingestSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  *float64          `json:"value"`
+}
+
+// convertIngestPayload converts JSON and line protocol payloads into
+// Prometheus text exposition so stored snapshots keep a single format.
+func convertIngestPayload(format ingestFormat, payload []byte) ([]byte, error) {
+	var samples []ingestSample
+	var err error
+	switch format {
+	case ingestFormatPrometheus:
+		return payload, nil
+	case ingestFormatJSON:
+		samples, err = parseJSONSamples(payload)
+	case ingestFormatInfluxLine:
+		samples, err = parseLineProtocol(payload)
+	default:
+		return nil, fmt.Errorf("unsupported ingest format %d", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("payload contains no numeric samples")
+	}
+	return samplesToExposition(samples)
+}
+
+func parseJSONSamples(payload []byte) ([]ingestSample, error) {
+	var samples []ingestSample
+	if err := json.Unmarshal(payload, &samples); err != nil {
+		return nil, fmt.Errorf("decode json samples: %w", err)
+	}
+	for idx, sample := range samples {
+		if strings.TrimSpace(sample.Name) == "" {
+			return nil, fmt.Errorf("sample %d: name is required", idx)
+		}
+		if sample.Value == nil {
+			return nil, fmt.Errorf("sample %d (%s): value is required", idx, sample.Name)
+		}
+	}
+	return samples, nil
+}
+
+// parseLineProtocol turns each numeric field of an InfluxDB line into a
+// sample named <measurement>_<field>, with tags as labels. String fields and
+// timestamps are dropped.
+func parseLineProtocol(payload []byte) ([]ingestSample, error) {
+	var samples []ingestSample
+	for lineNo, line := range strings.Split(string(payload), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sections := splitUnescaped(line, ' ', true)
+		if len(sections) < 2 || len(sections) > 3 {
+			return nil, fmt.Errorf("line %d: expected measurement, fields and optional timestamp", lineNo+1)
+		}
+		keyParts := splitUnescaped(sections[0], ',', false)
+		measurement := unescapeLineProtocol(keyParts[0])
+		if measurement == "" {
+			return nil, fmt.Errorf("line %d: measurement is required", lineNo+1)
+		}
+		labels := make(map[string]string, len(keyParts)-1)
+		for _, tag := range keyParts[1:] {
+			key, value, ok := cutUnescaped(tag, '=')
+			if !ok || key == "" {
+				return nil, fmt.Errorf("line %d: invalid tag %q", lineNo+1, tag)
+			}
+			labels[unescapeLineProtocol(key)] = unescapeLineProtocol(value)
+		}
+		for _, field := range splitUnescaped(sections[1], ',', true) {
+			key, raw, ok := cutUnescaped(field, '=')
+			if !ok || key == "" {
+				return nil, fmt.Errorf("line %d: invalid field %q", lineNo+1, field)
+			}
+			value, numeric, err := parseLineProtocolValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: field %q: %w", lineNo+1, key, err)
+			}
+			if !numeric {
+				continue
+			}
+			samples = append(samples, ingestSample{
+				Name:   measurement + "_" + unescapeLineProtocol(key),
+				Labels: labels,
+				Value:  &value,
+			})
+		}
+	}
+	return samples, nil
+}
+
+func parseLineProtocolValue(raw string) (float64, bool, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return 0, false, nil
+	case raw == "t" || raw == "T" || raw == "true" || raw == "True" || raw == "TRUE":
+		return 1, true, nil
+	case raw == "f" || raw == "F" || raw == "false" || raw == "False" || raw == "FALSE":
+		return 0, true, nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(strings.TrimSuffix(raw, "i"), 10, 64)
+		return float64(v), err == nil, err
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(strings.TrimSuffix(raw, "u"), 10, 64)
+		return float64(v), err == nil, err
+	default:
+		v, err := strconv.ParseFloat(raw, 64)
+		return v, err == nil, err
+	}
+}
+
+// splitUnescaped splits s on sep, honouring backslash escapes and, when
+// quotes is set, double-quoted string field values.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func unescapeLineProtocol(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func samplesToExposition(samples []ingestSample) ([]byte, error) {
+	families := make(map[string]*dto.MetricFamily)
+	for _, sample := range samples {
+		name := sanitizeMetricName(sample.Name)
+		if math.IsNaN(*sample.Value) {
+			continue
+		}
+		family, ok := families[name]
+		if !ok {
+			family = &dto.MetricFamily{Name: stringPtr(name), Type: dto.MetricType_UNTYPED.Enum()}
+			families[name] = family
+		}
+		metric := &dto.Metric{Untyped: &dto.Untyped{Value: sample.Value}}
+		keys := make([]string, 0, len(sample.Labels))
+		for key := range sample.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: stringPtr(sanitizeLabelName(key)), Value: stringPtr(sample.Labels[key])})
+		}
+		family.Metric = append(family.Metric, metric)
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		if _, err := expfmt.MetricFamilyToText(&buf, families[name]); err != nil {
+			return nil, fmt.Errorf("encode %s: %w", name, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// sanitizeMetricName replaces characters that are not valid in Prometheus
+// metric names with underscores.
+func sanitizeMetricName(name string) string {
+	return sanitizeName(name, true)
+}
+
+func sanitizeLabelName(name string) string {
+	return sanitizeName(name, false)
+}
+
+func sanitizeName(name string, allowColon bool) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (allowColon && r == ':') || (i > 0 && r >= '0' && r <= '9')
+		if !valid {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
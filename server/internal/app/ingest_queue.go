@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/storage"
+)
+
+const (
+	defaultIngestBatchSize     = 100
+	defaultIngestFlushInterval = 100 * time.Millisecond
+	ingestWriteTimeout         = 10 * time.Second
+)
+
+var (
+	errIngestQueueFull   = errors.New("ingest queue is full")
+	errIngestQueueClosed = errors.New("ingest queue is closed")
+)
+
+// ingestQueue buffers node snapshots in memory and persists them from a
+// single writer in batched transactions. Only the latest pending snapshot of
+// a node is kept, so a newer payload always replaces an older one.
+type ingestQueue struct {
+	store     *storage.Store
+	logger    *slog.Logger
+	capacity  int
+	batchSize int
+	interval  time.Duration
+
+	mu      sync.Mutex
+	pending map[string]storage.NodeMetricSnapshot
+	order   []string
+	closed  bool
+
+	// flushMu serialises writers so batches reach the store in queue order.
+	flushMu sync.Mutex
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newIngestQueue(store *storage.Store, logger *slog.Logger, cfg config.IngestConfig) *ingestQueue {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultIngestBatchSize
+	}
+	interval := cfg.FlushInterval.Duration
+	if interval <= 0 {
+		interval = defaultIngestFlushInterval
+	}
+	q := &ingestQueue{
+		store:     store,
+		logger:    logger,
+		capacity:  cfg.QueueSize,
+		batchSize: batchSize,
+		interval:  interval,
+		pending:   make(map[string]storage.NodeMetricSnapshot),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// enqueue stores the snapshot for the next batch, replacing any snapshot of
+// the same node that is still pending.
+func (q *ingestQueue) enqueue(snapshot storage.NodeMetricSnapshot) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errIngestQueueClosed
+	}
+	if _, ok := q.pending[snapshot.NodeID]; !ok {
+		if len(q.pending) >= q.capacity {
+			return errIngestQueueFull
+		}
+		q.order = append(q.order, snapshot.NodeID)
+	}
+	q.pending[snapshot.NodeID] = snapshot
+	if len(q.pending) >= q.batchSize {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (q *ingestQueue) run() {
+	defer close(q.done)
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			q.flush()
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+		q.flush()
+	}
+}
+
+// flush writes every pending snapshot and returns the number of batches and
+// snapshots written.
+func (q *ingestQueue) flush() (batches, written int) {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+	for {
+		batch := q.takeBatch()
+		if len(batch) == 0 {
+			return batches, written
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ingestWriteTimeout)
+		err := q.store.UpsertNodeMetricsBatch(ctx, batch)
+		cancel()
+		if err != nil {
+			// Agents resend their full snapshot every interval, so a lost
+			// batch is replaced on the next push.
+			q.logger.Error("failed to persist ingest batch", "snapshots", len(batch), "error", err)
+			continue
+		}
+		batches++
+		written += len(batch)
+	}
+}
+
+func (q *ingestQueue) takeBatch() []storage.NodeMetricSnapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := min(len(q.order), q.batchSize)
+	batch := make([]storage.NodeMetricSnapshot, 0, n)
+	for _, nodeID := range q.order[:n] {
+		batch = append(batch, q.pending[nodeID])
+		delete(q.pending, nodeID)
+	}
+	q.order = q.order[n:]
+	return batch
+}
+
+// close stops accepting snapshots and waits until the pending ones are
+// written or ctx expires.
+func (q *ingestQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+	close(q.stop)
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
 
 	"github.com/osbits/upupup/server/internal/config"
 	"github.com/osbits/upupup/server/internal/storage"
@@ -93,3 +99,342 @@ func TestDecodeMetricsPayloadRejectsUnsupportedEncoding(t *testing.T) {
 	}
 }
 
+func newIngestTestApp(t *testing.T) *App {
+	t.Helper()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	cfg := &config.Config{Storage: config.StorageConfig{Path: ":memory:"}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	app, err := New(context.Background(), cfg, store, logger)
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	return app
+}
+
+func ingest(app *App, nodeID, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/ingest/"+nodeID, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func storedSample(t *testing.T, app *App, nodeID, family string, labels map[string]string) float64 {
+	t.Helper()
+	snapshot, err := app.store.LatestNodeMetrics(context.Background(), nodeID)
+	if err != nil || snapshot == nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(snapshot.Payload))
+	if err != nil {
+		t.Fatalf("stored payload is not valid exposition: %v\n%s", err, snapshot.Payload)
+	}
+	mf, ok := families[family]
+	if !ok {
+		t.Fatalf("metric %s not stored:\n%s", family, snapshot.Payload)
+	}
+	for _, metric := range mf.GetMetric() {
+		matched := len(metric.GetLabel()) == len(labels)
+		for _, pair := range metric.GetLabel() {
+			if labels[pair.GetName()] != pair.GetValue() {
+				matched = false
+			}
+		}
+		if matched {
+			return metric.GetUntyped().GetValue()
+		}
+	}
+	t.Fatalf("no %s sample with labels %v:\n%s", family, labels, snapshot.Payload)
+	return 0
+}
+
+func TestHandleIngestMetricsConvertsLineProtocol(t *testing.T) {
+	app := newIngestTestApp(t)
+	body := `cpu,host=web\ 1,cpu=cpu0 usage_idle=98.5,usage_user=1.25 1700000000000000000
+mem,host=web\ 1 used=1024i,available=2048u,swapping=f,state="ok, healthy"
+`
+	rec := ingest(app, "node-a", "application/x-influxdb-line-protocol", body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d (body=%s)", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+
+	cpu := map[string]string{"host": "web 1", "cpu": "cpu0"}
+	if got := storedSample(t, app, "node-a", "cpu_usage_idle", cpu); got != 98.5 {
+		t.Fatalf("expected cpu_usage_idle 98.5, got %v", got)
+	}
+	if got := storedSample(t, app, "node-a", "cpu_usage_user", cpu); got != 1.25 {
+		t.Fatalf("expected cpu_usage_user 1.25, got %v", got)
+	}
+	mem := map[string]string{"host": "web 1"}
+	if got := storedSample(t, app, "node-a", "mem_used", mem); got != 1024 {
+		t.Fatalf("expected mem_used 1024, got %v", got)
+	}
+	if got := storedSample(t, app, "node-a", "mem_available", mem); got != 2048 {
+		t.Fatalf("expected mem_available 2048, got %v", got)
+	}
+	if got := storedSample(t, app, "node-a", "mem_swapping", mem); got != 0 {
+		t.Fatalf("expected mem_swapping 0, got %v", got)
+	}
+	snapshot, _ := app.store.LatestNodeMetrics(context.Background(), "node-a")
+	if strings.Contains(snapshot.Payload, "mem_state") {
+		t.Fatalf("string fields must be dropped:\n%s", snapshot.Payload)
+	}
+}
+
+func TestHandleIngestMetricsConvertsJSON(t *testing.T) {
+	app := newIngestTestApp(t)
+	body := `[{"name":"disk.free_bytes","labels":{"mount":"/"},"value":5e9},{"name":"load1","value":0.5}]`
+	rec := ingest(app, "node-b", "application/json; charset=utf-8", body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d (body=%s)", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if got := storedSample(t, app, "node-b", "disk_free_bytes", map[string]string{"mount": "/"}); got != 5e9 {
+		t.Fatalf("expected disk_free_bytes 5e9, got %v", got)
+	}
+	if got := storedSample(t, app, "node-b", "load1", nil); got != 0.5 {
+		t.Fatalf("expected load1 0.5, got %v", got)
+	}
+
+	if rec := ingest(app, "node-b", "application/json", `[{"name":"load1"}]`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected sample without value to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestHandleIngestMetricsRejectsUnknownContentType(t *testing.T) {
+	app := newIngestTestApp(t)
+	rec := ingest(app, "node-c", "application/xml", "<metrics/>")
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
+
+func newQueuedIngestTestApp(t *testing.T, ingestCfg config.IngestConfig) *App {
+	t.Helper()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	cfg := &config.Config{Server: config.ServerConfig{Ingest: ingestCfg}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	app, err := New(context.Background(), cfg, store, logger)
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = app.Close(context.Background())
+	})
+	return app
+}
+
+func TestQueuedIngestBatchesConcurrentNodesAndKeepsLatest(t *testing.T) {
+	// A long flush interval leaves the batch to the explicit flush below.
+	app := newQueuedIngestTestApp(t, config.IngestConfig{
+		QueueSize:     100,
+		FlushInterval: config.Duration{Duration: time.Hour},
+	})
+
+	const nodes, pushes = 8, 10
+	var wg sync.WaitGroup
+	for n := 0; n < nodes; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for i := 1; i <= pushes; i++ {
+				rec := ingest(app, fmt.Sprintf("node-%d", n), "text/plain", fmt.Sprintf("node_load1 %d\n", i))
+				if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"queued"`) {
+					t.Errorf("unexpected response %d: %s", rec.Code, rec.Body.String())
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	batches, written := app.ingestQueue.flush()
+	if batches != 1 || written != nodes {
+		t.Fatalf("expected %d snapshots in 1 batch, got %d in %d", nodes, written, batches)
+	}
+	for n := 0; n < nodes; n++ {
+		if got := storedSample(t, app, fmt.Sprintf("node-%d", n), "node_load1", map[string]string{}); got != pushes {
+			t.Fatalf("node-%d: expected latest value %d, got %v", n, pushes, got)
+		}
+	}
+}
+
+func TestQueuedIngestRejectsWhenFull(t *testing.T) {
+	app := newQueuedIngestTestApp(t, config.IngestConfig{
+		QueueSize:     1,
+		FlushInterval: config.Duration{Duration: time.Hour},
+	})
+
+	if rec := ingest(app, "node-a", "text/plain", "node_load1 1\n"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected first node to be queued, got %d", rec.Code)
+	}
+	if rec := ingest(app, "node-a", "text/plain", "node_load1 2\n"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected pending node to be replaced, got %d", rec.Code)
+	}
+	rec := ingest(app, "node-b", "text/plain", "node_load1 1\n")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After for a full queue, got %d", rec.Code)
+	}
+
+	if err := app.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := storedSample(t, app, "node-a", "node_load1", map[string]string{}); got != 2 {
+		t.Fatalf("expected close to flush the latest snapshot, got %v", got)
+	}
+}
+
+func TestIngestKeepsSnapshotHistory(t *testing.T) {
+	app := newQueuedIngestTestApp(t, config.IngestConfig{HistoryRetention: config.Duration{Duration: time.Hour}})
+
+	for i := 1; i <= 3; i++ {
+		if rec := ingest(app, "node-a", "text/plain", fmt.Sprintf("node_load1 %d\n", i)); rec.Code != http.StatusAccepted {
+			t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	_, err := app.store.DB().Exec(`INSERT INTO node_metrics_history (node_id, payload, ingested_at) VALUES (?, ?, ?)`,
+		"node-a", "node_load1 0", time.Now().UTC().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("insert expired history: %v", err)
+	}
+	if rec := ingest(app, "node-a", "text/plain", "node_load1 4\n"); rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var count int
+	if err := app.store.DB().QueryRow(`SELECT COUNT(*) FROM node_metrics_history WHERE node_id = ?`, "node-a").Scan(&count); err != nil {
+		t.Fatalf("count history: %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 retained snapshots, got %d", count)
+	}
+	if got := storedSample(t, app, "node-a", "node_load1", map[string]string{}); got != 4 {
+		t.Fatalf("expected latest value 4, got %v", got)
+	}
+}
+
+func TestIngestHistoryPruneUsesIndex(t *testing.T) {
+	app := newQueuedIngestTestApp(t, config.IngestConfig{HistoryRetention: config.Duration{Duration: time.Hour}})
+
+	rows, err := app.store.DB().Query(`EXPLAIN QUERY PLAN DELETE FROM node_metrics_history WHERE ingested_at < ?`, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("explain prune: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatalf("scan plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if joined := strings.Join(plan, "; "); !strings.Contains(joined, "idx_node_metrics_history_ingested") {
+		t.Fatalf("expected the prune to use the ingested_at index, got plan %q", joined)
+	}
+}
+
+func TestIngestFilterKeepsReferencedSeries(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	cfg := &config.Config{
+		Server: config.ServerConfig{Ingest: config.IngestConfig{
+			KeepSeries:           []string{"node_load"},
+			KeepReferencedSeries: true,
+		}},
+		Checks: []config.CheckConfig{{
+			ID:   "disk",
+			Type: "metrics",
+			Metrics: &config.MetricsCheck{
+				NodeID: "node-a",
+				Thresholds: []config.MetricThreshold{
+					{Name: "disk_usage", Op: "less_than", Value: 80},
+					{Name: "node_memory_MemAvailable_bytes", Op: "greater_than", Value: 1},
+				},
+				Computed: map[string]config.ComputedMetric{"disk_usage": {
+					Expression: "100 - avail / size * 100",
+					Variables: map[string]config.MetricReference{
+						"avail": {Name: "node_filesystem_avail_bytes"},
+						"size":  {Name: "node_filesystem_size_bytes"},
+					},
+				}},
+			},
+		}},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	app, err := New(context.Background(), cfg, store, logger)
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+
+	body := `node_load1 0.5
+node_load5 0.4
+# HELP node_cpu_seconds_total Seconds the CPUs spent in each mode.
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 1000
+node_cpu_seconds_total{cpu="0",mode="user"} 20
+node_filesystem_avail_bytes{mountpoint="/"} 40
+node_filesystem_size_bytes{mountpoint="/"} 100
+node_memory_MemAvailable_bytes 2048
+go_goroutines 12
+`
+	rec := ingest(app, "node-a", "text/plain", body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"dropped_series":3`) {
+		t.Fatalf("expected 3 dropped series in the response, got %s", rec.Body.String())
+	}
+	for family, want := range map[string]float64{
+		"node_load1":                     0.5,
+		"node_load5":                     0.4,
+		"node_memory_MemAvailable_bytes": 2048,
+	} {
+		if got := storedSample(t, app, "node-a", family, map[string]string{}); got != want {
+			t.Fatalf("expected %s %v, got %v", family, want, got)
+		}
+	}
+	if got := storedSample(t, app, "node-a", "node_filesystem_avail_bytes", map[string]string{"mountpoint": "/"}); got != 40 {
+		t.Fatalf("expected referenced variable to be kept, got %v", got)
+	}
+	snapshot, _ := app.store.LatestNodeMetrics(context.Background(), "node-a")
+	// HELP and TYPE lines of dropped families go with them.
+	if strings.Contains(snapshot.Payload, "node_cpu_seconds_total") || strings.Contains(snapshot.Payload, "go_goroutines") {
+		t.Fatalf("unreferenced series must be dropped:\n%s", snapshot.Payload)
+	}
+}
+
+func TestIngestFilterCapsStoredPayload(t *testing.T) {
+	app := newQueuedIngestTestApp(t, config.IngestConfig{MaxStoredBytes: 30})
+	rec := ingest(app, "node-a", "text/plain", "node_load1 0.5\nnode_load5 0.4\nnode_load15 0.3\n")
+	if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"truncated":true`) {
+		t.Fatalf("expected a truncated snapshot, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := app.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	snapshot, err := app.store.LatestNodeMetrics(context.Background(), "node-a")
+	if err != nil || snapshot == nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	if snapshot.Payload != "node_load1 0.5\nnode_load5 0.4\n" {
+		t.Fatalf("expected the payload cut at a line boundary, got %q", snapshot.Payload)
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/osbits/upupup/shared/maintenance"
+)
+
+type maintenanceResponse struct {
+	GeneratedAt time.Time                 `json:"generated_at"`
+	Timezone    string                    `json:"timezone"`
+	Active      bool                      `json:"active"`
+	Windows     []maintenanceWindowDetail `json:"windows"`
+}
+
+type maintenanceWindowDetail struct {
+	Kind string `json:"kind"`
+	Expr string `json:"expr"`
+	// Duration is how long each occurrence of a cron window lasts.
+	Duration string `json:"duration,omitempty"`
+	Active   bool   `json:"active"`
+	// ActiveSince and ActiveUntil bound the occurrence in progress.
+	ActiveSince *time.Time `json:"active_since,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	// NextStart and NextEnd bound the first occurrence starting later; both
+	// are omitted for a range window that has already started.
+	NextStart *time.Time `json:"next_start,omitempty"`
+	NextEnd   *time.Time `json:"next_end,omitempty"`
+}
+
+func (a *App) localTime(now time.Time) time.Time {
+	if a.location != nil {
+		return now.In(a.location)
+	}
+	return now
+}
+
+func (a *App) inMaintenance(now time.Time) bool {
+	return maintenance.Active(a.maintenance, a.localTime(now))
+}
+
+func (a *App) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	now := a.localTime(time.Now())
+	resp := maintenanceResponse{
+		GeneratedAt: now.UTC(),
+		Timezone:    now.Location().String(),
+		Windows:     make([]maintenanceWindowDetail, 0, len(a.maintenance)),
+	}
+	for _, window := range a.maintenance {
+		detail := describeMaintenanceWindow(window, now)
+		resp.Active = resp.Active || detail.Active
+		resp.Windows = append(resp.Windows, detail)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func describeMaintenanceWindow(window maintenance.Window, now time.Time) maintenanceWindowDetail {
+	detail := maintenanceWindowDetail{
+		Kind: string(window.Kind),
+		Expr: window.Expr,
+	}
+	if window.Duration > 0 {
+		detail.Duration = window.Duration.String()
+	}
+	if start, end, ok := window.Current(now); ok {
+		detail.Active = true
+		detail.ActiveSince = &start
+		detail.ActiveUntil = &end
+	}
+	if start, end, ok := window.Next(now); ok {
+		detail.NextStart = &start
+		detail.NextEnd = &end
+	}
+	return detail
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"sort"
@@ -8,6 +9,9 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/storage"
 )
 
 func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
@@ -55,10 +59,184 @@ func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		namespace = "upupup"
 	}
 
+	var snapshot *storage.NodeMetricSnapshot
+	nodeID := checkNodeID(check)
+	if nodeID != "" {
+		snapshot, err = a.store.LatestNodeMetrics(ctx, nodeID)
+		if err != nil {
+			http.Error(w, "failed to load node metrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	targets, err := a.store.CheckTargets(ctx, checkID)
+	if err != nil {
+		http.Error(w, "failed to load check targets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var computed []computedSample
+	if snapshot != nil && check.Metrics != nil {
+		var errs map[string]error
+		computed, errs = evaluateComputedMetrics(snapshot.Payload, check.Metrics.Computed)
+		for name, err := range errs {
+			a.logger.Debug("computed metric unavailable", "check_id", checkID, "metric", name, "error", err)
+		}
+	}
+
+	gauges := []checkGauge{
+		{name: "check_status", help: "Last check status (1=success)", value: boolToFloat(lastRun.Success), format: "%.0f"},
+		{name: "check_last_run_timestamp_seconds", help: "Unix time of last check run", value: float64(lastRun.OccurredAt.Unix()), format: "%.0f"},
+		{name: "check_latency_seconds", help: "Last check latency in seconds", value: lastRun.Latency.Seconds(), format: "%.6f"},
+		{name: "check_recent_window_seconds", help: "Observation window for recent counts", value: window.Seconds(), format: "%.0f"},
+		{name: "check_recent_total", openMetricsName: "check_recent_runs", help: "Total runs within the observation window", value: float64(total), format: "%.0f"},
+		{name: "check_recent_failures", help: "Failed runs within the observation window", value: float64(failed), format: "%.0f"},
+	}
+
+	if negotiateMetricsFormat(r) == metricsFormatOpenMetrics {
+		var buf bytes.Buffer
+		if err := writeOpenMetrics(&buf, namespace, checkLabels(checkID, check), gauges, lastRun.OccurredAt, nodeID, snapshot, targets, computed); err != nil {
+			http.Error(w, "failed to render openmetrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", openMetricsContentType)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+
 	builder := &strings.Builder{}
-	labelPairs := []string{
-		fmt.Sprintf(`check_id="%s"`, promLabelValue(checkID)),
-		fmt.Sprintf(`check_name="%s"`, promLabelValue(check.Name)),
+	labelPairs := []string{}
+	for _, pair := range checkLabels(checkID, check) {
+		labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, pair.name, promLabelValue(pair.value)))
+	}
+	labels := strings.Join(labelPairs, ",")
+	for idx, gauge := range gauges {
+		fmt.Fprintf(builder, "# HELP %s_%s %s\n", namespace, gauge.name, gauge.help)
+		fmt.Fprintf(builder, "# TYPE %s_%s gauge\n", namespace, gauge.name)
+		fmt.Fprintf(builder, "%s_%s{%s} "+gauge.format+"\n", namespace, gauge.name, labels, gauge.value)
+		if idx < len(gauges)-1 {
+			builder.WriteString("\n")
+		}
+	}
+
+	if len(targets) > 0 {
+		builder.WriteString("\n")
+		fmt.Fprintf(builder, "# HELP %s_check_target_up %s\n", namespace, targetUpHelp)
+		fmt.Fprintf(builder, "# TYPE %s_check_target_up gauge\n", namespace)
+		for _, target := range targets {
+			fmt.Fprintf(builder, "%s_check_target_up{%s,target=\"%s\"} %.0f\n", namespace, labels, promLabelValue(target.Target), boolToFloat(target.Success))
+		}
+		builder.WriteString("\n")
+		fmt.Fprintf(builder, "# HELP %s_check_target_latency_seconds %s\n", namespace, targetLatencyHelp)
+		fmt.Fprintf(builder, "# TYPE %s_check_target_latency_seconds gauge\n", namespace)
+		for _, target := range targets {
+			fmt.Fprintf(builder, "%s_check_target_latency_seconds{%s,target=\"%s\"} %.6f\n", namespace, labels, promLabelValue(target.Target), target.Latency.Seconds())
+		}
+	}
+
+	if len(computed) > 0 {
+		builder.WriteString("\n")
+		fmt.Fprintf(builder, "# HELP %s_computed %s\n", namespace, computedHelp)
+		fmt.Fprintf(builder, "# TYPE %s_computed gauge\n", namespace)
+		for _, sample := range computed {
+			fmt.Fprintf(builder, "%s_computed{%s,name=\"%s\",node_id=\"%s\"} %g\n", namespace, labels, promLabelValue(sample.name), promLabelValue(nodeID), sample.value)
+		}
+	}
+
+	if snapshot != nil && snapshot.Payload != "" {
+		decoratedPayload := ensureCheckIDLabel(snapshot.Payload, nodeID)
+		builder.WriteString("\n")
+		ingestedAt := snapshot.IngestedAt.UTC()
+		timestamp := "unknown"
+		if !ingestedAt.IsZero() {
+			timestamp = ingestedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(builder, "# Raw metrics from node %s (ingested_at=%s)\n", promLabelValue(nodeID), timestamp)
+		builder.WriteString(decoratedPayload)
+		if !strings.HasSuffix(decoratedPayload, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(builder.String()))
+}
+
+// handleRawMetrics serves only the node payload of a metrics check, decorated
+// with the check_id label, for federation scrapes that do not want the
+// synthetic check gauges.
+func (a *App) handleRawMetrics(w http.ResponseWriter, r *http.Request) {
+	checkID := chi.URLParam(r, "checkID")
+	if checkID == "" {
+		http.Error(w, "check id is required", http.StatusBadRequest)
+		return
+	}
+	check, ok := a.checkConfigs[checkID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	nodeID := checkNodeID(check)
+	if nodeID == "" {
+		http.Error(w, "no node metrics available", http.StatusNotFound)
+		return
+	}
+	snapshot, err := a.store.LatestNodeMetrics(r.Context(), nodeID)
+	if err != nil {
+		http.Error(w, "failed to load node metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if snapshot == nil || strings.TrimSpace(snapshot.Payload) == "" {
+		http.Error(w, "no node metrics available", http.StatusNotFound)
+		return
+	}
+
+	payload := ensureCheckIDLabel(snapshot.Payload, nodeID)
+	if !strings.HasSuffix(payload, "\n") {
+		payload += "\n"
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(payload))
+}
+
+// checkNodeID returns the node whose ingested metrics belong to a metrics
+// check, or "" for other check types.
+func checkNodeID(check config.CheckConfig) string {
+	if check.Metrics == nil {
+		return ""
+	}
+	if nodeID := strings.TrimSpace(check.Metrics.NodeID); nodeID != "" {
+		return nodeID
+	}
+	return strings.TrimSpace(check.Target)
+}
+
+const (
+	computedHelp      = "Value of a computed metric evaluated from the node payload"
+	targetUpHelp      = "Last status of each pool target (1=healthy)"
+	targetLatencyHelp = "Last latency of each pool target in seconds"
+)
+
+// checkGauge describes a synthetic per-check gauge rendered by handleMetrics.
+type checkGauge struct {
+	name string
+	// openMetricsName overrides name in OpenMetrics output where the
+	// Prometheus name would collide with a reserved sample suffix.
+	openMetricsName string
+	help            string
+	value           float64
+	format          string
+}
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+func checkLabels(checkID string, check config.CheckConfig) []labelPair {
+	pairs := []labelPair{
+		{name: "check_id", value: checkID},
+		{name: "check_name", value: check.Name},
 	}
 	if len(check.Labels) > 0 {
 		keys := make([]string, 0, len(check.Labels))
@@ -67,65 +245,10 @@ func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 		sort.Strings(keys)
 		for _, key := range keys {
-			value := check.Labels[key]
-			labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, promLabelKey(key), promLabelValue(value)))
+			pairs = append(pairs, labelPair{name: promLabelKey(key), value: check.Labels[key]})
 		}
 	}
-	labels := strings.Join(labelPairs, ",")
-	fmt.Fprintf(builder, "# HELP %s_check_status Last check status (1=success)\n", namespace)
-	fmt.Fprintf(builder, "# TYPE %s_check_status gauge\n", namespace)
-	fmt.Fprintf(builder, "%s_check_status{%s} %.0f\n\n", namespace, labels, boolToFloat(lastRun.Success))
-
-	fmt.Fprintf(builder, "# HELP %s_check_last_run_timestamp_seconds Unix time of last check run\n", namespace)
-	fmt.Fprintf(builder, "# TYPE %s_check_last_run_timestamp_seconds gauge\n", namespace)
-	fmt.Fprintf(builder, "%s_check_last_run_timestamp_seconds{%s} %.0f\n\n", namespace, labels, float64(lastRun.OccurredAt.Unix()))
-
-	fmt.Fprintf(builder, "# HELP %s_check_latency_seconds Last check latency in seconds\n", namespace)
-	fmt.Fprintf(builder, "# TYPE %s_check_latency_seconds gauge\n", namespace)
-	fmt.Fprintf(builder, "%s_check_latency_seconds{%s} %.6f\n\n", namespace, labels, float64(lastRun.Latency.Seconds()))
-
-	fmt.Fprintf(builder, "# HELP %s_check_recent_window_seconds Observation window for recent counts\n", namespace)
-	fmt.Fprintf(builder, "# TYPE %s_check_recent_window_seconds gauge\n", namespace)
-	fmt.Fprintf(builder, "%s_check_recent_window_seconds{%s} %.0f\n\n", namespace, labels, window.Seconds())
-
-	fmt.Fprintf(builder, "# HELP %s_check_recent_total Total runs within the observation window\n", namespace)
-	fmt.Fprintf(builder, "# TYPE %s_check_recent_total gauge\n", namespace)
-	fmt.Fprintf(builder, "%s_check_recent_total{%s} %d\n\n", namespace, labels, total)
-
-	fmt.Fprintf(builder, "# HELP %s_check_recent_failures Failed runs within the observation window\n", namespace)
-	fmt.Fprintf(builder, "# TYPE %s_check_recent_failures gauge\n", namespace)
-	fmt.Fprintf(builder, "%s_check_recent_failures{%s} %d\n", namespace, labels, failed)
-
-	if check.Metrics != nil {
-		nodeID := strings.TrimSpace(check.Metrics.NodeID)
-		if nodeID == "" {
-			nodeID = strings.TrimSpace(check.Target)
-		}
-		if nodeID != "" {
-			snapshot, err := a.store.LatestNodeMetrics(ctx, nodeID)
-			if err != nil {
-				http.Error(w, "failed to load node metrics: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if snapshot != nil && snapshot.Payload != "" {
-				decoratedPayload := ensureCheckIDLabel(snapshot.Payload, nodeID)
-				builder.WriteString("\n")
-				ingestedAt := snapshot.IngestedAt.UTC()
-				timestamp := "unknown"
-				if !ingestedAt.IsZero() {
-					timestamp = ingestedAt.Format(time.RFC3339)
-				}
-				fmt.Fprintf(builder, "# Raw metrics from node %s (ingested_at=%s)\n", promLabelValue(nodeID), timestamp)
-				builder.WriteString(decoratedPayload)
-				if !strings.HasSuffix(decoratedPayload, "\n") {
-					builder.WriteString("\n")
-				}
-			}
-		}
-	}
-
-	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
-	_, _ = w.Write([]byte(builder.String()))
+	return pairs
 }
 
 func promLabelValue(input string) string {
@@ -164,3 +164,334 @@ func TestEnsureCheckIDLabelHandlesEmptyCheckID(t *testing.T) {
 		t.Fatalf("expected unchanged result when check ID empty")
 	}
 }
+
+func newMetricsTestApp(t *testing.T, occurredAt time.Time, nodePayload string, ingestedAt time.Time) *App {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := storage.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	if err := store.EnsureIngestSchema(ctx); err != nil {
+		t.Fatalf("ensure ingest schema: %v", err)
+	}
+	_, err = store.DB().Exec(`
+		CREATE TABLE IF NOT EXISTS check_states (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			check_id TEXT NOT NULL,
+			check_name TEXT NOT NULL,
+			success INTEGER NOT NULL,
+			summary TEXT,
+			error TEXT,
+			latency_ms INTEGER,
+			occurred_at TIMESTAMP NOT NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("create check_states: %v", err)
+	}
+	_, err = store.DB().Exec(`
+		INSERT INTO check_states (check_id, check_name, success, summary, error, latency_ms, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, "metrics-check", "Metrics Check", 1, "", "", 250, occurredAt)
+	if err != nil {
+		t.Fatalf("insert check_state: %v", err)
+	}
+	if nodePayload != "" {
+		err = store.UpsertNodeMetrics(ctx, storage.NodeMetricSnapshot{
+			NodeID:     "node-1",
+			Payload:    nodePayload,
+			IngestedAt: ingestedAt,
+		})
+		if err != nil {
+			t.Fatalf("upsert node metrics: %v", err)
+		}
+	}
+
+	return &App{
+		store: store,
+		checkConfigs: map[string]config.CheckConfig{
+			"metrics-check": {
+				ID:   "metrics-check",
+				Name: "Metrics Check",
+				Type: "metrics",
+				Metrics: &config.MetricsCheck{
+					NodeID: "node-1",
+				},
+			},
+		},
+		metricsCfg: config.MetricsConfig{Namespace: "upupup"},
+		healthCfg:  config.HealthConfig{MaxIntervalMultiplier: 3},
+		serviceDefaults: config.ServiceDefault{
+			Interval: config.Duration{Duration: time.Minute},
+		},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func serveMetrics(app *App, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("checkID", "metrics-check")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	app.handleMetrics(rec, req)
+	return rec
+}
+
+func TestHandleMetricsOpenMetricsFramingAndTimestamps(t *testing.T) {
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	ingestedAt := time.Date(2025, 1, 2, 3, 4, 0, 0, time.UTC)
+	payload := "# TYPE node_requests_total counter\nnode_requests_total 7\nnode_load1 0.5\n"
+	app := newMetricsTestApp(t, occurredAt, payload, ingestedAt)
+
+	header := http.Header{}
+	header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	rec := serveMetrics(app, "/api/metrics/metrics-check", header)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d (%s)", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	body := rec.Body.String()
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Fatalf("expected body to end with # EOF:\n%s", body)
+	}
+	if strings.Contains(body, "\n\n") {
+		t.Fatalf("openmetrics output must not contain blank lines:\n%s", body)
+	}
+	if strings.Contains(body, "# Raw metrics") {
+		t.Fatalf("openmetrics output must not contain free-form comments:\n%s", body)
+	}
+	expectedStatus := `upupup_check_status{check_id="metrics-check",check_name="Metrics Check"} 1.0 1.735787045e+09`
+	if !strings.Contains(body, expectedStatus) {
+		t.Fatalf("expected %q in output:\n%s", expectedStatus, body)
+	}
+	if strings.Contains(body, "upupup_check_recent_total") {
+		t.Fatalf("gauge must not use the _total suffix in openmetrics:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE upupup_check_recent_runs gauge") {
+		t.Fatalf("expected renamed recent runs gauge:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE node_requests counter") {
+		t.Fatalf("expected counter family without _total suffix:\n%s", body)
+	}
+	expectedCounter := `node_requests_total{check_id="node-1"} 7.0 1.73578704e+09`
+	if !strings.Contains(body, expectedCounter) {
+		t.Fatalf("expected %q in output:\n%s", expectedCounter, body)
+	}
+}
+
+func TestHandleMetricsOpenMetricsViaQueryParameter(t *testing.T) {
+	app := newMetricsTestApp(t, time.Now().UTC(), "", time.Time{})
+
+	rec := serveMetrics(app, "/api/metrics/metrics-check?format=openmetrics", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "# EOF\n") {
+		t.Fatalf("expected openmetrics framing:\n%s", rec.Body.String())
+	}
+}
+
+func TestHandleMetricsDefaultsToPrometheusText(t *testing.T) {
+	app := newMetricsTestApp(t, time.Now().UTC(), "", time.Time{})
+
+	rec := serveMetrics(app, "/api/metrics/metrics-check", nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "# EOF") {
+		t.Fatalf("prometheus text output must not contain # EOF:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE upupup_check_recent_total gauge") {
+		t.Fatalf("expected prometheus gauge names to be unchanged:\n%s", body)
+	}
+}
+
+func diskComputedMetrics() map[string]config.ComputedMetric {
+	return map[string]config.ComputedMetric{
+		"disk_usage_percent": {
+			Expression: "((size - avail) / size) * 100",
+			Variables: map[string]config.MetricReference{
+				"size":  {Name: "node_filesystem_size_bytes", Labels: map[string]string{"mountpoint": "/"}},
+				"avail": {Name: "node_filesystem_avail_bytes", Labels: map[string]string{"mountpoint": "/"}},
+			},
+		},
+		"disk_pressure": {
+			Expression: "usage > 90 ? 1 : 0",
+			Variables: map[string]config.MetricReference{
+				"usage": {Name: "disk_usage_percent"},
+			},
+		},
+		"broken": {
+			Expression: "missing * 2",
+			Variables: map[string]config.MetricReference{
+				"missing": {Name: "node_does_not_exist"},
+			},
+		},
+	}
+}
+
+func TestHandleMetricsExposesComputedGauges(t *testing.T) {
+	payload := "node_filesystem_size_bytes{mountpoint=\"/\"} 200\nnode_filesystem_avail_bytes{mountpoint=\"/\"} 50\n"
+	app := newMetricsTestApp(t, time.Now().UTC(), payload, time.Now().UTC())
+	check := app.checkConfigs["metrics-check"]
+	check.Metrics.Computed = diskComputedMetrics()
+
+	rec := serveMetrics(app, "/api/metrics/metrics-check", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d (%s)", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"# TYPE upupup_computed gauge",
+		`upupup_computed{check_id="metrics-check",check_name="Metrics Check",name="disk_usage_percent",node_id="node-1"} 75`,
+		`upupup_computed{check_id="metrics-check",check_name="Metrics Check",name="disk_pressure",node_id="node-1"} 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, `name="broken"`) {
+		t.Fatalf("unevaluable computed metrics must be skipped:\n%s", body)
+	}
+}
+
+func TestHandleMetricsOpenMetricsComputedGauges(t *testing.T) {
+	ingestedAt := time.Date(2025, 1, 2, 3, 4, 0, 0, time.UTC)
+	payload := "node_filesystem_size_bytes{mountpoint=\"/\"} 200\nnode_filesystem_avail_bytes{mountpoint=\"/\"} 10\n"
+	app := newMetricsTestApp(t, time.Now().UTC(), payload, ingestedAt)
+	check := app.checkConfigs["metrics-check"]
+	check.Metrics.Computed = diskComputedMetrics()
+
+	rec := serveMetrics(app, "/api/metrics/metrics-check?format=openmetrics", nil)
+	body := rec.Body.String()
+	expected := `upupup_computed{check_id="metrics-check",check_name="Metrics Check",name="disk_pressure",node_id="node-1"} 1.0 1.73578704e+09`
+	if !strings.Contains(body, expected) {
+		t.Fatalf("expected %q in output:\n%s", expected, body)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Fatalf("expected openmetrics framing:\n%s", body)
+	}
+}
+
+func TestEvaluateComputedMetricsReportsCycle(t *testing.T) {
+	computed := map[string]config.ComputedMetric{
+		"a": {Expression: "b + 1", Variables: map[string]config.MetricReference{"b": {Name: "b"}}},
+		"b": {Expression: "a + 1", Variables: map[string]config.MetricReference{"a": {Name: "a"}}},
+	}
+	samples, errs := evaluateComputedMetrics("node_load1 1\n", computed)
+	if len(samples) != 0 {
+		t.Fatalf("expected no samples, got %+v", samples)
+	}
+	if err := errs["a"]; err == nil || !strings.Contains(err.Error(), "computed metric cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func insertCheckTargets(t *testing.T, app *App, occurredAt time.Time) {
+	t.Helper()
+	_, err := app.store.DB().Exec(`
+		CREATE TABLE IF NOT EXISTS check_target_states (
+			check_id TEXT NOT NULL,
+			target TEXT NOT NULL,
+			success INTEGER NOT NULL,
+			latency_ms INTEGER NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			occurred_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (check_id, target)
+		);
+		INSERT INTO check_target_states (check_id, target, success, latency_ms, error, occurred_at) VALUES
+			('metrics-check', '10.0.0.1', 1, 120, '', ?),
+			('metrics-check', '10.0.0.2', 0, 2000, 'connection refused', ?);
+	`, occurredAt, occurredAt)
+	if err != nil {
+		t.Fatalf("insert check targets: %v", err)
+	}
+}
+
+func TestHandleMetricsExposesPoolTargets(t *testing.T) {
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	app := newMetricsTestApp(t, occurredAt, "", time.Time{})
+
+	rec := serveMetrics(app, "/api/metrics/metrics-check", nil)
+	if strings.Contains(rec.Body.String(), "check_target_up") {
+		t.Fatalf("expected no target gauges without pool results:\n%s", rec.Body.String())
+	}
+
+	insertCheckTargets(t, app, occurredAt)
+	rec = serveMetrics(app, "/api/metrics/metrics-check", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d (%s)", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`upupup_check_target_up{check_id="metrics-check",check_name="Metrics Check",target="10.0.0.1"} 1`,
+		`upupup_check_target_up{check_id="metrics-check",check_name="Metrics Check",target="10.0.0.2"} 0`,
+		`upupup_check_target_latency_seconds{check_id="metrics-check",check_name="Metrics Check",target="10.0.0.2"} 2.000000`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in output:\n%s", want, body)
+		}
+	}
+
+	rec = serveMetrics(app, "/api/metrics/metrics-check?format=openmetrics", nil)
+	want := `upupup_check_target_up{check_id="metrics-check",check_name="Metrics Check",target="10.0.0.1"} 1.0 1.735787045e+09`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %q in openmetrics output:\n%s", want, rec.Body.String())
+	}
+}
+
+func serveRawMetrics(app *App, checkID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/metrics/"+checkID+"/raw", nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("checkID", checkID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	app.handleRawMetrics(rec, req)
+	return rec
+}
+
+func TestHandleRawMetricsReturnsOnlyNodePayload(t *testing.T) {
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	payload := "# TYPE node_load1 gauge\nnode_load1 0.5\nnode_cpu_seconds_total{cpu=\"0\"} 42"
+	app := newMetricsTestApp(t, occurredAt, payload, occurredAt)
+
+	rec := serveRawMetrics(app, "metrics-check")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d (%s)", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	want := "# TYPE node_load1 gauge\nnode_load1{check_id=\"node-1\"} 0.5\nnode_cpu_seconds_total{cpu=\"0\",check_id=\"node-1\"} 42\n"
+	if body != want {
+		t.Fatalf("unexpected raw payload:\n%s\nwant:\n%s", body, want)
+	}
+	for _, synthetic := range []string{"upupup_check_status", "upupup_check_latency_seconds", "upupup_check_recent_total", "# Raw metrics"} {
+		if strings.Contains(body, synthetic) {
+			t.Fatalf("raw payload must not contain %q:\n%s", synthetic, body)
+		}
+	}
+}
+
+func TestHandleRawMetricsNotFoundWithoutNodeMetrics(t *testing.T) {
+	app := newMetricsTestApp(t, time.Now(), "", time.Time{})
+	if rec := serveRawMetrics(app, "metrics-check"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without node metrics, got %d", rec.Code)
+	}
+	if rec := serveRawMetrics(app, "unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown check, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/osbits/upupup/server/internal/storage"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+type metricsFormat int
+
+const (
+	metricsFormatPrometheus metricsFormat = iota
+	metricsFormatOpenMetrics
+)
+
+// negotiateMetricsFormat selects OpenMetrics when requested through the
+// format query parameter or the Accept header and falls back to the
+// Prometheus 0.0.4 text format otherwise.
+func negotiateMetricsFormat(r *http.Request) metricsFormat {
+	if strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("format")), "openmetrics") {
+		return metricsFormatOpenMetrics
+	}
+	if expfmt.NegotiateIncludingOpenMetrics(r.Header).FormatType() == expfmt.TypeOpenMetrics {
+		return metricsFormatOpenMetrics
+	}
+	return metricsFormatPrometheus
+}
+
+// writeOpenMetrics renders the check gauges, pool targets, computed metrics
+// and the optional node payload in the OpenMetrics text format. Check gauges
+// and pool targets carry the run's occurred_at as explicit timestamp, computed
+// and node samples without their own timestamp inherit the snapshot's
+// ingestion time.
+func writeOpenMetrics(w io.Writer, namespace string, labels []labelPair, gauges []checkGauge, occurredAt time.Time, nodeID string, snapshot *storage.NodeMetricSnapshot, targets []storage.CheckTargetState, computed []computedSample) error {
+	labelSet := make([]*dto.LabelPair, 0, len(labels))
+	for _, pair := range labels {
+		labelSet = append(labelSet, &dto.LabelPair{Name: stringPtr(pair.name), Value: stringPtr(pair.value)})
+	}
+	timestampMs := occurredAt.UnixMilli()
+	gaugeType := dto.MetricType_GAUGE
+
+	for _, gauge := range gauges {
+		name := gauge.name
+		if gauge.openMetricsName != "" {
+			name = gauge.openMetricsName
+		}
+		value := gauge.value
+		family := &dto.MetricFamily{
+			Name: stringPtr(namespace + "_" + name),
+			Help: stringPtr(gauge.help),
+			Type: &gaugeType,
+			Metric: []*dto.Metric{
+				{
+					Label:       labelSet,
+					Gauge:       &dto.Gauge{Value: &value},
+					TimestampMs: &timestampMs,
+				},
+			},
+		}
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+			return fmt.Errorf("encode %s: %w", family.GetName(), err)
+		}
+	}
+
+	if len(targets) > 0 {
+		up := &dto.MetricFamily{
+			Name: stringPtr(namespace + "_check_target_up"),
+			Help: stringPtr(targetUpHelp),
+			Type: &gaugeType,
+		}
+		latency := &dto.MetricFamily{
+			Name: stringPtr(namespace + "_check_target_latency_seconds"),
+			Help: stringPtr(targetLatencyHelp),
+			Type: &gaugeType,
+		}
+		for _, target := range targets {
+			upValue := boolToFloat(target.Success)
+			latencyValue := target.Latency.Seconds()
+			sampleLabels := append(append([]*dto.LabelPair{}, labelSet...),
+				&dto.LabelPair{Name: stringPtr("target"), Value: stringPtr(target.Target)},
+			)
+			up.Metric = append(up.Metric, &dto.Metric{
+				Label:       sampleLabels,
+				Gauge:       &dto.Gauge{Value: &upValue},
+				TimestampMs: &timestampMs,
+			})
+			latency.Metric = append(latency.Metric, &dto.Metric{
+				Label:       sampleLabels,
+				Gauge:       &dto.Gauge{Value: &latencyValue},
+				TimestampMs: &timestampMs,
+			})
+		}
+		for _, family := range []*dto.MetricFamily{up, latency} {
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+				return fmt.Errorf("encode %s: %w", family.GetName(), err)
+			}
+		}
+	}
+
+	if len(computed) > 0 && snapshot != nil {
+		var ingestedMs *int64
+		if !snapshot.IngestedAt.IsZero() {
+			ms := snapshot.IngestedAt.UnixMilli()
+			ingestedMs = &ms
+		}
+		family := &dto.MetricFamily{
+			Name: stringPtr(namespace + "_computed"),
+			Help: stringPtr(computedHelp),
+			Type: &gaugeType,
+		}
+		for _, sample := range computed {
+			value := sample.value
+			sampleLabels := append(append([]*dto.LabelPair{}, labelSet...),
+				&dto.LabelPair{Name: stringPtr("name"), Value: stringPtr(sample.name)},
+				&dto.LabelPair{Name: stringPtr("node_id"), Value: stringPtr(nodeID)},
+			)
+			family.Metric = append(family.Metric, &dto.Metric{
+				Label:       sampleLabels,
+				Gauge:       &dto.Gauge{Value: &value},
+				TimestampMs: ingestedMs,
+			})
+		}
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+			return fmt.Errorf("encode %s: %w", family.GetName(), err)
+		}
+	}
+
+	if snapshot != nil && snapshot.Payload != "" {
+		var parser expfmt.TextParser
+		families, err := parser.TextToMetricFamilies(strings.NewReader(ensureCheckIDLabel(snapshot.Payload, nodeID)))
+		if err != nil {
+			return fmt.Errorf("parse node metrics: %w", err)
+		}
+		names := make([]string, 0, len(families))
+		for name := range families {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var ingestedMs *int64
+		if !snapshot.IngestedAt.IsZero() {
+			ms := snapshot.IngestedAt.UnixMilli()
+			ingestedMs = &ms
+		}
+		for _, name := range names {
+			family := families[name]
+			for _, metric := range family.Metric {
+				if metric.TimestampMs == nil {
+					metric.TimestampMs = ingestedMs
+				}
+			}
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+				return fmt.Errorf("encode %s: %w", name, err)
+			}
+		}
+	}
+
+	_, err := expfmt.FinalizeOpenMetrics(w)
+	return err
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
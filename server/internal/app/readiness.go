@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -17,9 +18,11 @@ import (
 )
 
 type readinessResponse struct {
-	Status        string                   `json:"status"`
-	GeneratedAt   time.Time                `json:"generated_at"`
-	Configuration readinessConfigComponent `json:"configuration"`
+	Status        string                    `json:"status"`
+	GeneratedAt   time.Time                 `json:"generated_at"`
+	Configuration readinessConfigComponent  `json:"configuration"`
+	Database      componentStatus           `json:"database"`
+	Ingest        *readinessIngestComponent `json:"ingest,omitempty"`
 }
 
 type readinessConfigComponent struct {
@@ -31,16 +34,52 @@ type readinessConfigComponent struct {
 	Targets       []string   `json:"targets,omitempty"`
 }
 
+// readinessIngestComponent reports how fresh the node snapshots used by
+// metrics checks are.
+type readinessIngestComponent struct {
+	Status string            `json:"status"`
+	Detail string            `json:"detail,omitempty"`
+	Nodes  []ingestNodeState `json:"nodes"`
+}
+
+type ingestNodeState struct {
+	NodeID       string     `json:"node_id"`
+	Status       string     `json:"status"`
+	LastIngested *time.Time `json:"last_ingested,omitempty"`
+	MaxAgeSecs   int64      `json:"max_age_seconds"`
+}
+
+const readinessPingTimeout = 2 * time.Second
+
 func (a *App) handleReadiness(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC()
 
 	configStatus := a.prometheusConfigStatus()
-	ready := configStatus.Status == statusOK
+	dbStatus := componentStatus{Status: statusOK}
+	pingCtx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+	err := a.store.Ping(pingCtx)
+	cancel()
+	if err != nil {
+		dbStatus = componentStatus{Status: statusCritical, Detail: err.Error()}
+	}
+	var ingest *readinessIngestComponent
+	if dbStatus.Status == statusOK {
+		ingest = a.ingestFreshness(r.Context(), now)
+	}
 
+	// Stale nodes are reported but don't fail readiness: one offline agent
+	// should not take the server out of rotation.
+	ready := configStatus.Status == statusOK && dbStatus.Status == statusOK &&
+		(ingest == nil || ingest.Status != statusCritical)
 	resp := readinessResponse{
-		Status:        configStatus.Status,
+		Status:        worstStatus(configStatus.Status, dbStatus.Status),
 		GeneratedAt:   now,
 		Configuration: configStatus,
+		Database:      dbStatus,
+		Ingest:        ingest,
+	}
+	if ingest != nil {
+		resp.Status = worstStatus(resp.Status, ingest.Status)
 	}
 
 	statusCode := http.StatusOK
@@ -56,6 +95,70 @@ func (a *App) handleReadiness(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// ingestFreshness checks the latest snapshot of every node referenced by a
+// metrics check. It returns nil when no metrics checks are configured.
+func (a *App) ingestFreshness(ctx context.Context, now time.Time) *readinessIngestComponent {
+	maxAges := map[string]time.Duration{}
+	for _, check := range a.checkConfigs {
+		nodeID := checkNodeID(check)
+		if nodeID == "" {
+			continue
+		}
+		maxAge := a.effectiveInterval(check) * time.Duration(max(a.healthCfg.MaxIntervalMultiplier, 1))
+		if check.Metrics.MaxAge != nil && check.Metrics.MaxAge.Set && check.Metrics.MaxAge.Duration > 0 {
+			maxAge = check.Metrics.MaxAge.Duration
+		}
+		if current, ok := maxAges[nodeID]; !ok || maxAge < current {
+			maxAges[nodeID] = maxAge
+		}
+	}
+	if len(maxAges) == 0 {
+		return nil
+	}
+	nodeIDs := make([]string, 0, len(maxAges))
+	for nodeID := range maxAges {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	component := &readinessIngestComponent{Status: statusOK, Nodes: make([]ingestNodeState, 0, len(nodeIDs))}
+	stale := 0
+	for _, nodeID := range nodeIDs {
+		node := ingestNodeState{NodeID: nodeID, Status: statusOK, MaxAgeSecs: int64(maxAges[nodeID].Seconds())}
+		snapshot, err := a.store.LatestNodeMetrics(ctx, nodeID)
+		if err != nil {
+			component.Status = statusCritical
+			component.Detail = err.Error()
+			return component
+		}
+		switch {
+		case snapshot == nil:
+			node.Status = statusWarn
+			stale++
+		case now.Sub(snapshot.IngestedAt) > maxAges[nodeID]:
+			node.Status = statusWarn
+			node.LastIngested = &snapshot.IngestedAt
+			stale++
+		default:
+			node.LastIngested = &snapshot.IngestedAt
+		}
+		component.Nodes = append(component.Nodes, node)
+	}
+	if stale > 0 {
+		component.Status = statusWarn
+		component.Detail = fmt.Sprintf("%d of %d nodes without fresh metrics", stale, len(nodeIDs))
+	}
+	return component
+}
+
+func worstStatus(a, b string) string {
+	rank := map[string]int{statusOK: 0, statusWarn: 1, statusCritical: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
 func (a *App) initialisePrometheusConfig() {
 	path := strings.TrimSpace(a.metricsCfg.ConfigPath)
 	if path == "" {
@@ -190,6 +293,7 @@ func (a *App) generatePrometheusConfig() ([]string, error) {
 				JobName:        a.metricsCfg.JobName,
 				Scheme:         a.metricsCfg.Scheme,
 				ScrapeInterval: durationString(a.metricsCfg.ScrapeInterval.Duration, 0),
+				TLSConfig:      buildTLSConfig(a.metricsCfg.TLS),
 				StaticConfigs:  staticConfigs,
 				RelabelConfigs: []promRelabelConfig{
 					{
@@ -273,6 +377,23 @@ func buildGlobalConfig(cfg config.MetricsConfig) *promGlobalConfig {
 	}
 }
 
+// buildTLSConfig returns the tls_config of the scrape config, or nil when no
+// TLS settings are configured so the block is omitted.
+func buildTLSConfig(cfg *config.PrometheusTLSConfig) *promTLSConfig {
+	if cfg == nil {
+		return nil
+	}
+	tlsCfg := &promTLSConfig{
+		CAFile:             strings.TrimSpace(cfg.CAFile),
+		ServerName:         strings.TrimSpace(cfg.ServerName),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if *tlsCfg == (promTLSConfig{}) {
+		return nil
+	}
+	return tlsCfg
+}
+
 func durationString(value time.Duration, fallback time.Duration) string {
 	if value > 0 {
 		return value.String()
@@ -311,10 +432,17 @@ type promScrapeConfig struct {
 	JobName        string              `yaml:"job_name"`
 	Scheme         string              `yaml:"scheme,omitempty"`
 	ScrapeInterval string              `yaml:"scrape_interval,omitempty"`
+	TLSConfig      *promTLSConfig      `yaml:"tls_config,omitempty"`
 	StaticConfigs  []promStaticConfig  `yaml:"static_configs,omitempty"`
 	RelabelConfigs []promRelabelConfig `yaml:"relabel_configs,omitempty"`
 }
 
+type promTLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
 type promStaticConfig struct {
 	Targets []string          `yaml:"targets"`
 	Labels  map[string]string `yaml:"labels,omitempty"`
@@ -1,8 +1,12 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -13,6 +17,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/storage"
 )
 
 func TestGeneratePrometheusConfigWritesExpectedScrapeConfig(t *testing.T) {
@@ -105,6 +110,57 @@ func TestGeneratePrometheusConfigWritesExpectedScrapeConfig(t *testing.T) {
 	}
 }
 
+func TestGeneratePrometheusConfigIncludesTLSConfig(t *testing.T) {
+	generate := func(tlsCfg *config.PrometheusTLSConfig) string {
+		t.Helper()
+		outputPath := filepath.Join(t.TempDir(), "prometheus.yml")
+		app := &App{
+			cfg:          &config.Config{},
+			logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			checkConfigs: map[string]config.CheckConfig{"check-1": {ID: "check-1"}},
+			metricsCfg: applyMetricsDefaults(config.MetricsConfig{
+				ConfigPath: outputPath,
+				Scheme:     "https",
+				Targets:    []string{"server:8443"},
+				TLS:        tlsCfg,
+			}),
+		}
+		if _, err := app.generatePrometheusConfig(); err != nil {
+			t.Fatalf("generatePrometheusConfig returned error: %v", err)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("failed to read generated config: %v", err)
+		}
+		return string(data)
+	}
+
+	data := generate(&config.PrometheusTLSConfig{
+		CAFile:     "/etc/prometheus/upupup-ca.pem",
+		ServerName: "upupup.internal",
+	})
+	var prom promConfigFile
+	if err := yaml.Unmarshal([]byte(data), &prom); err != nil {
+		t.Fatalf("failed to unmarshal generated config: %v", err)
+	}
+	want := &promTLSConfig{CAFile: "/etc/prometheus/upupup-ca.pem", ServerName: "upupup.internal"}
+	if got := prom.ScrapeConfigs[0].TLSConfig; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected tls_config: got %+v want %+v", got, want)
+	}
+	if !strings.Contains(data, "tls_config:\n") || strings.Contains(data, "insecure_skip_verify") {
+		t.Fatalf("expected tls_config block without unset fields:\n%s", data)
+	}
+
+	if data := generate(&config.PrometheusTLSConfig{InsecureSkipVerify: true}); !strings.Contains(data, "insecure_skip_verify: true") {
+		t.Fatalf("expected insecure_skip_verify in tls_config:\n%s", data)
+	}
+	for _, tlsCfg := range []*config.PrometheusTLSConfig{nil, {}} {
+		if data := generate(tlsCfg); strings.Contains(data, "tls_config") {
+			t.Fatalf("expected no tls_config when unset:\n%s", data)
+		}
+	}
+}
+
 func TestGeneratePrometheusConfigUsesListenFallback(t *testing.T) {
 	t.Helper()
 
@@ -164,3 +220,90 @@ func TestGeneratePrometheusConfigUsesListenFallback(t *testing.T) {
 		t.Fatalf("unexpected readiness targets: %v", status.Targets)
 	}
 }
+
+func newReadinessTestApp(t *testing.T, checks ...config.CheckConfig) *App {
+	t.Helper()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	if err := store.EnsureIngestSchema(context.Background()); err != nil {
+		t.Fatalf("ensure ingest schema: %v", err)
+	}
+	checkConfigs := make(map[string]config.CheckConfig, len(checks))
+	for _, check := range checks {
+		checkConfigs[check.ID] = check
+	}
+	app := &App{
+		cfg:             &config.Config{Checks: checks},
+		store:           store,
+		checkConfigs:    checkConfigs,
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		metricsCfg:      config.MetricsConfig{ConfigPath: filepath.Join(t.TempDir(), "prometheus.yml")},
+		healthCfg:       config.HealthConfig{MaxIntervalMultiplier: 3},
+		serviceDefaults: config.ServiceDefault{Interval: config.Duration{Duration: time.Minute}},
+	}
+	app.setPrometheusConfigStatus(time.Now().UTC(), []string{"server:8080"}, nil)
+	return app
+}
+
+func getReadiness(t *testing.T, app *App) (int, readinessResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.handleReadiness(rec, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+	var resp readinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode readiness: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func TestReadinessFailsWhenDatabaseUnreachable(t *testing.T) {
+	app := newReadinessTestApp(t)
+
+	code, resp := getReadiness(t, app)
+	if code != http.StatusOK || resp.Database.Status != statusOK {
+		t.Fatalf("expected ready with reachable database, got %d %+v", code, resp)
+	}
+
+	_ = app.store.Close()
+	code, resp = getReadiness(t, app)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with unreachable database, got %d", code)
+	}
+	if resp.Configuration.Status != statusOK {
+		t.Fatalf("expected configuration to stay ok, got %+v", resp.Configuration)
+	}
+	if resp.Database.Status != statusCritical || resp.Status != statusCritical {
+		t.Fatalf("expected critical database status, got %+v", resp)
+	}
+}
+
+func TestReadinessReportsIngestFreshness(t *testing.T) {
+	check := config.CheckConfig{
+		ID:      "node-load",
+		Type:    "metrics",
+		Metrics: &config.MetricsCheck{NodeID: "node-a"},
+	}
+	app := newReadinessTestApp(t, check)
+
+	code, resp := getReadiness(t, app)
+	if code != http.StatusOK {
+		t.Fatalf("expected missing node metrics not to fail readiness, got %d", code)
+	}
+	if resp.Ingest == nil || resp.Ingest.Status != statusWarn || resp.Ingest.Nodes[0].NodeID != "node-a" {
+		t.Fatalf("expected warn for node without metrics, got %+v", resp.Ingest)
+	}
+
+	err := app.store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{NodeID: "node-a", Payload: "node_load1 1\n"})
+	if err != nil {
+		t.Fatalf("upsert node metrics: %v", err)
+	}
+	code, resp = getReadiness(t, app)
+	if code != http.StatusOK || resp.Status != statusOK || resp.Ingest.Status != statusOK {
+		t.Fatalf("expected fresh ingest to be ok, got %d %+v", code, resp.Ingest)
+	}
+}
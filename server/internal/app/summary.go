@@ -0,0 +1,72 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// summaryResponse condenses the health snapshot into counts for dashboards
+// that only need the overall picture.
+type summaryResponse struct {
+	Status        string         `json:"status"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Checks        summaryCounts  `json:"checks"`
+	ActiveHooks   int            `json:"active_hooks"`
+	Database      string         `json:"database"`
+	Notifications string         `json:"notifications"`
+	Ingest        *summaryIngest `json:"ingest,omitempty"`
+}
+
+type summaryCounts struct {
+	Total    int `json:"total"`
+	OK       int `json:"ok"`
+	Warn     int `json:"warn"`
+	Critical int `json:"critical"`
+}
+
+// summaryIngest reports the freshness of node snapshots; it is omitted when
+// no metrics checks are configured.
+type summaryIngest struct {
+	Status     string `json:"status"`
+	Nodes      int    `json:"nodes"`
+	StaleNodes int    `json:"stale_nodes"`
+}
+
+// handleSummary always answers 200 so dashboards can render a degraded
+// fleet; the status field carries the verdict.
+func (a *App) handleSummary(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	health := a.healthSnapshot(r.Context(), now)
+	resp := summaryResponse{
+		Status:        health.Status,
+		GeneratedAt:   now,
+		Checks:        summaryCounts{Total: len(health.Checks)},
+		ActiveHooks:   len(health.ActiveHooks),
+		Database:      health.Database.Status,
+		Notifications: health.Notifications.Status,
+	}
+	for _, check := range health.Checks {
+		switch check.Status {
+		case statusOK:
+			resp.Checks.OK++
+		case statusWarn:
+			resp.Checks.Warn++
+		default:
+			resp.Checks.Critical++
+		}
+	}
+	if health.Database.Status == statusOK {
+		if ingest := a.ingestFreshness(r.Context(), now); ingest != nil {
+			resp.Ingest = &summaryIngest{Status: ingest.Status, Nodes: len(ingest.Nodes)}
+			for _, node := range ingest.Nodes {
+				if node.Status != statusOK {
+					resp.Ingest.StaleNodes++
+				}
+			}
+			resp.Status = worstStatus(resp.Status, ingest.Status)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
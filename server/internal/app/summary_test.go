@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/server/internal/config"
+	"github.com/osbits/upupup/server/internal/hooks"
+	"github.com/osbits/upupup/server/internal/storage"
+)
+
+func TestSummaryCountsCheckStates(t *testing.T) {
+	now := time.Now().UTC()
+	app := newHealthTestApp(t, now, nil)
+	nodeCheck := config.CheckConfig{ID: "node-load", Name: "Node load", Type: "metrics", Metrics: &config.MetricsCheck{NodeID: "node-a"}}
+	app.cfg.Checks = append(app.cfg.Checks, config.CheckConfig{ID: "web", Name: "Web"}, nodeCheck)
+	app.checkConfigs = map[string]config.CheckConfig{nodeCheck.ID: nodeCheck}
+	app.healthCfg.FailOnMissingCheckState = true
+	app.healthCfg.AllowNoNotifications = true
+	app.hookManager = hooks.NewManager(app.store, nil)
+
+	ctx := context.Background()
+	_, err := app.store.DB().Exec(`
+		CREATE TABLE IF NOT EXISTS notification_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			notifier_id TEXT NOT NULL,
+			check_id TEXT NOT NULL,
+			check_name TEXT NOT NULL,
+			run_id TEXT,
+			status TEXT,
+			severity TEXT,
+			summary TEXT,
+			labels_json TEXT,
+			occurred_at TIMESTAMP NOT NULL,
+			error_class TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT ''
+		);
+	`)
+	if err != nil {
+		t.Fatalf("create notification_logs: %v", err)
+	}
+	_, err = app.store.DB().Exec(`
+		INSERT INTO check_states (check_id, check_name, success, summary, error, latency_ms, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, "web", "Web", 0, "status 500", "", 80, now)
+	if err != nil {
+		t.Fatalf("insert check_state: %v", err)
+	}
+	if err := app.store.EnsureIngestSchema(ctx); err != nil {
+		t.Fatalf("ensure ingest schema: %v", err)
+	}
+	if err := app.store.EnsureHookSchema(ctx); err != nil {
+		t.Fatalf("ensure hook schema: %v", err)
+	}
+	_, err = app.store.InsertHookExecution(ctx, storage.HookExecution{
+		HookID:      "pause-web",
+		Kind:        "pause_notifications",
+		Scope:       "check",
+		TargetIDs:   []string{"web"},
+		RequestedAt: now,
+		ActiveUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+		Status:      "active",
+	})
+	if err != nil {
+		t.Fatalf("insert hook execution: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/summary", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp summaryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	want := summaryCounts{Total: 3, OK: 1, Warn: 1, Critical: 1}
+	if resp.Checks != want {
+		t.Fatalf("expected counts %+v, got %+v", want, resp.Checks)
+	}
+	if resp.Status != statusCritical || resp.Database != statusOK || resp.Notifications != statusOK {
+		t.Fatalf("unexpected statuses: %+v", resp)
+	}
+	if resp.ActiveHooks != 1 {
+		t.Fatalf("expected 1 active hook, got %d", resp.ActiveHooks)
+	}
+	if resp.Ingest == nil || resp.Ingest.Status != statusWarn || resp.Ingest.Nodes != 1 || resp.Ingest.StaleNodes != 1 {
+		t.Fatalf("expected one stale node, got %+v", resp.Ingest)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/osbits/upupup/shared/computed"
+	"github.com/osbits/upupup/shared/maintenance"
 )
 
 // Duration wraps time.Duration to allow YAML unmarshalling from strings.
@@ -85,6 +88,8 @@ type ServiceDefault struct {
 	Backoff            Duration          `yaml:"backoff"`
 	MaintenanceWindows []MaintenanceSpec `yaml:"maintenance_windows"`
 	LogRuns            bool              `yaml:"log_runs"`
+	// MinInterval is the floor the worker raises check intervals to.
+	MinInterval Duration `yaml:"min_interval"`
 }
 
 // StorageConfig describes persistence options.
@@ -95,38 +100,16 @@ type StorageConfig struct {
 }
 
 // MaintenanceSpec includes cron or range expressions.
-type MaintenanceSpec struct {
-	Expr string
-	Kind MaintenanceKind
-}
+type MaintenanceSpec = maintenance.Spec
 
 // MaintenanceKind indicates the maintenance window type.
-type MaintenanceKind string
+type MaintenanceKind = maintenance.Kind
 
 const (
-	MaintenanceKindCron  MaintenanceKind = "cron"
-	MaintenanceKindRange MaintenanceKind = "range"
+	MaintenanceKindCron  = maintenance.KindCron
+	MaintenanceKindRange = maintenance.KindRange
 )
 
-// UnmarshalYAML allows parsing "cron: ..." or "range: ...".
-func (m *MaintenanceSpec) UnmarshalYAML(value *yaml.Node) error {
-	if value.Kind != yaml.ScalarNode {
-		return fmt.Errorf("maintenance spec must be scalar, got %s", value.ShortTag())
-	}
-	raw := strings.TrimSpace(value.Value)
-	switch {
-	case strings.HasPrefix(raw, "cron:"):
-		m.Kind = MaintenanceKindCron
-		m.Expr = strings.TrimSpace(strings.TrimPrefix(raw, "cron:"))
-	case strings.HasPrefix(raw, "range:"):
-		m.Kind = MaintenanceKindRange
-		m.Expr = strings.TrimSpace(strings.TrimPrefix(raw, "range:"))
-	default:
-		return fmt.Errorf("unsupported maintenance spec %q", raw)
-	}
-	return nil
-}
-
 // SecretSpec defines how to resolve a secret.
 type SecretSpec struct {
 	Source string
@@ -215,6 +198,9 @@ type CheckSchedule struct {
 	Timeout  *NullableDuration `yaml:"timeout"`
 	Retries  *int              `yaml:"retries"`
 	Backoff  *NullableDuration `yaml:"backoff"`
+	// Cron runs the check at the activations of a cron expression instead
+	// of every Interval.
+	Cron string `yaml:"cron"`
 }
 
 // HTTPRequest describes an HTTP request template.
@@ -276,19 +262,10 @@ type MetricThreshold struct {
 }
 
 // ComputedMetric defines a derived metric calculated from other metrics.
-type ComputedMetric struct {
-	Expression  string                     `yaml:"expression"`
-	Variables   map[string]MetricReference `yaml:"variables"`
-	Labels      map[string]string          `yaml:"labels"`
-	Description string                     `yaml:"description"`
-}
+type ComputedMetric = computed.Metric
 
 // MetricReference identifies a metric to pull into a computed expression.
-type MetricReference struct {
-	Name    string            `yaml:"name"`
-	Labels  map[string]string `yaml:"labels"`
-	Default *float64          `yaml:"default"`
-}
+type MetricReference = computed.Reference
 
 // CheckNotification describes check-specific notification config.
 type CheckNotification struct {
@@ -299,6 +276,8 @@ type CheckNotification struct {
 // NotificationOverride overrides the policy for a check.
 type NotificationOverride struct {
 	InitialNotifiers []string `yaml:"initial_notifiers"`
+	// ResolveNotifiers replaces the resolve_notifiers of the check's policy.
+	ResolveNotifiers []string `yaml:"resolve_notifiers"`
 }
 
 // ServerConfig contains HTTP server specific settings.
@@ -308,9 +287,30 @@ type ServerConfig struct {
 	TrustedProxies []string      `yaml:"trusted_proxies"`
 	Health         HealthConfig  `yaml:"health"`
 	Prometheus     MetricsConfig `yaml:"prometheus"`
+	Ingest         IngestConfig  `yaml:"ingest"`
 	LogRequests    bool          `yaml:"log_requests"`
 }
 
+// IngestConfig controls how ingested node snapshots are written. With a
+// QueueSize the endpoint answers right away and a single writer persists
+// queued snapshots in batches; without one every request writes directly.
+type IngestConfig struct {
+	QueueSize     int      `yaml:"queue_size"`
+	BatchSize     int      `yaml:"batch_size"`
+	FlushInterval Duration `yaml:"flush_interval"`
+	// HistoryRetention keeps every snapshot for this long so the worker can
+	// replay metrics checks against them; zero keeps only the latest.
+	HistoryRetention Duration `yaml:"history_retention"`
+	// KeepSeries stores only series whose name starts with one of these
+	// prefixes; KeepReferencedSeries adds the metrics the configured
+	// metrics checks read. Both unset stores every series.
+	KeepSeries           []string `yaml:"keep_series"`
+	KeepReferencedSeries bool     `yaml:"keep_referenced_series"`
+	// MaxStoredBytes cuts stored payloads to this size at a line boundary.
+	// Zero stores them whole.
+	MaxStoredBytes int `yaml:"max_stored_bytes"`
+}
+
 // HealthConfig controls healthcheck behaviour.
 type HealthConfig struct {
 	MaxIntervalMultiplier      int      `yaml:"max_interval_multiplier"`
@@ -333,6 +333,17 @@ type MetricsConfig struct {
 	GlobalScrapeInterval     Duration `yaml:"global_scrape_interval"`
 	GlobalEvaluationInterval Duration `yaml:"global_evaluation_interval"`
 	ScrapeInterval           Duration `yaml:"scrape_interval"`
+	// TLS is written as the tls_config of the generated scrape config, e.g.
+	// to scrape an HTTPS server with a private CA.
+	TLS *PrometheusTLSConfig `yaml:"tls"`
+}
+
+// PrometheusTLSConfig holds the Prometheus tls_config fields of the
+// generated scrape config.
+type PrometheusTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 }
 
 // HookConfig describes a runtime hook.
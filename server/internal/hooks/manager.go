@@ -46,16 +46,60 @@ type InvokeOptions struct {
 	AdditionalMetadata map[string]string
 }
 
+// Invocation names a hook and its runtime overrides within a batch.
+type Invocation struct {
+	HookID  string
+	Options InvokeOptions
+}
+
 // Invoke executes a hook identified by ID and persists the result.
 func (m *Manager) Invoke(ctx context.Context, hookID string, opts InvokeOptions) (storage.HookExecution, error) {
 	if m == nil {
 		return storage.HookExecution{}, errors.New("manager is nil")
 	}
+	exec, err := m.prepare(hookID, opts, time.Now().UTC())
+	if err != nil {
+		return storage.HookExecution{}, err
+	}
+	id, err := m.store.InsertHookExecution(ctx, exec)
+	if err != nil {
+		return storage.HookExecution{}, err
+	}
+	exec.ID = id
+	return exec, nil
+}
+
+// InvokeBatch executes several hooks at once. The executions are persisted
+// in one transaction, so an invalid invocation leaves none of them active.
+func (m *Manager) InvokeBatch(ctx context.Context, invocations []Invocation) ([]storage.HookExecution, error) {
+	if m == nil {
+		return nil, errors.New("manager is nil")
+	}
+	now := time.Now().UTC()
+	execs := make([]storage.HookExecution, 0, len(invocations))
+	for _, invocation := range invocations {
+		exec, err := m.prepare(invocation.HookID, invocation.Options, now)
+		if err != nil {
+			return nil, err
+		}
+		execs = append(execs, exec)
+	}
+	ids, err := m.store.InsertHookExecutions(ctx, execs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range execs {
+		execs[i].ID = ids[i]
+	}
+	return execs, nil
+}
+
+// prepare builds the execution of a hook requested at now.
+func (m *Manager) prepare(hookID string, opts InvokeOptions, now time.Time) (storage.HookExecution, error) {
 	hookCfg, ok := m.hooksByID[hookID]
 	if !ok {
 		return storage.HookExecution{}, fmt.Errorf("unknown hook %q", hookID)
 	}
-	now := time.Now().UTC()
 
 	duration := resolveDuration(&hookCfg, opts, m.defaultOpts.MaxDuration)
 	var activeUntil sql.NullTime
@@ -100,11 +144,6 @@ func (m *Manager) Invoke(ctx context.Context, hookID string, opts InvokeOptions)
 		Status:            "active",
 	}
 
-	id, err := m.store.InsertHookExecution(ctx, exec)
-	if err != nil {
-		return storage.HookExecution{}, err
-	}
-	exec.ID = id
 	return exec, nil
 }
 
@@ -59,51 +59,85 @@ func (s *Store) EnsureHookSchema(ctx context.Context) error {
 
 // InsertHookExecution persists a new hook invocation.
 func (s *Store) InsertHookExecution(ctx context.Context, exec HookExecution) (int64, error) {
-	if s == nil || s.db == nil {
-		return 0, errors.New("store not initialised")
-	}
-	targetsJSON, err := json.Marshal(exec.TargetIDs)
+	ids, err := s.InsertHookExecutions(ctx, []HookExecution{exec})
 	if err != nil {
-		return 0, fmt.Errorf("encode target ids: %w", err)
+		return 0, err
 	}
-	paramsJSON, err := json.Marshal(exec.Parameters)
-	if err != nil {
-		return 0, fmt.Errorf("encode parameters: %w", err)
+	return ids[0], nil
+}
+
+// InsertHookExecutions persists several hook invocations in a single
+// transaction and returns their ids in order. Either all of them are stored
+// or none is.
+func (s *Store) InsertHookExecutions(ctx context.Context, execs []HookExecution) (ids []int64, err error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
 	}
-	if exec.RequestedAt.IsZero() {
-		exec.RequestedAt = time.Now().UTC()
+	if len(execs) == 0 {
+		return nil, nil
 	}
-	if exec.Status == "" {
-		exec.Status = "active"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
 	}
-	res, err := s.db.ExecContext(ctx, `
-		INSERT INTO hook_executions (
-			hook_id, kind, scope, target_ids_json, requested_by, requested_from_ip,
-			parameters_json, note, until_first_success, active_until, requested_at, status
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	ids = make([]int64, 0, len(execs))
+	for _, exec := range execs {
+		if exec.HookID == "" || exec.Kind == "" {
+			return nil, fmt.Errorf("insert hook execution: hook id and kind are required")
+		}
+		targetsJSON, err := json.Marshal(exec.TargetIDs)
+		if err != nil {
+			return nil, fmt.Errorf("encode target ids: %w", err)
+		}
+		paramsJSON, err := json.Marshal(exec.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("encode parameters: %w", err)
+		}
+		if exec.RequestedAt.IsZero() {
+			exec.RequestedAt = time.Now().UTC()
+		}
+		if exec.Status == "" {
+			exec.Status = "active"
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO hook_executions (
+				hook_id, kind, scope, target_ids_json, requested_by, requested_from_ip,
+				parameters_json, note, until_first_success, active_until, requested_at, status
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			exec.HookID,
+			exec.Kind,
+			exec.Scope,
+			string(targetsJSON),
+			exec.RequestedBy,
+			exec.RequestedFromIP,
+			string(paramsJSON),
+			exec.Note,
+			boolToInt(exec.UntilFirstSuccess),
+			nullTimePointer(exec.ActiveUntil),
+			exec.RequestedAt,
+			exec.Status,
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`,
-		exec.HookID,
-		exec.Kind,
-		exec.Scope,
-		string(targetsJSON),
-		exec.RequestedBy,
-		exec.RequestedFromIP,
-		string(paramsJSON),
-		exec.Note,
-		boolToInt(exec.UntilFirstSuccess),
-		nullTimePointer(exec.ActiveUntil),
-		exec.RequestedAt,
-		exec.Status,
-	)
-	if err != nil {
-		return 0, fmt.Errorf("insert hook execution: %w", err)
+		if err != nil {
+			return nil, fmt.Errorf("insert hook execution: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("retrieve hook execution id: %w", err)
+		}
+		ids = append(ids, id)
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("retrieve hook execution id: %w", err)
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit hook executions: %w", err)
 	}
-	return id, nil
+	return ids, nil
 }
 
 func nullTimePointer(val sql.NullTime) any {
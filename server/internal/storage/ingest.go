@@ -16,6 +16,14 @@ CREATE TABLE IF NOT EXISTS node_metrics (
 	ingested_at TIMESTAMP NOT NULL,
 	source_ip TEXT
 );
+CREATE TABLE IF NOT EXISTS node_metrics_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	node_id TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	ingested_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_node_metrics_history_node ON node_metrics_history (node_id, ingested_at);
+CREATE INDEX IF NOT EXISTS idx_node_metrics_history_ingested ON node_metrics_history (ingested_at);
 `
 
 // NodeMetricSnapshot represents the latest raw metrics payload ingested for a node.
@@ -37,17 +45,90 @@ func (s *Store) EnsureIngestSchema(ctx context.Context) error {
 	return nil
 }
 
+// SetSnapshotHistory keeps every ingested snapshot for retention in addition
+// to the latest one per node, so metrics checks can be replayed against
+// them. Zero or less keeps no history.
+func (s *Store) SetSnapshotHistory(retention time.Duration) {
+	if s == nil {
+		return
+	}
+	s.historyRetention = retention
+}
+
 // UpsertNodeMetrics persists the latest metrics payload for a node.
 func (s *Store) UpsertNodeMetrics(ctx context.Context, snapshot NodeMetricSnapshot) error {
+	return s.UpsertNodeMetricsBatch(ctx, []NodeMetricSnapshot{snapshot})
+}
+
+// UpsertNodeMetricsBatch persists the latest payload of several nodes in a
+// single transaction. Later entries for the same node win.
+func (s *Store) UpsertNodeMetricsBatch(ctx context.Context, snapshots []NodeMetricSnapshot) (err error) {
 	if s == nil || s.db == nil {
 		return errors.New("store not initialised")
 	}
-	nodeID := strings.TrimSpace(snapshot.NodeID)
-	if nodeID == "" {
+	for i := range snapshots {
+		if err := normaliseSnapshot(&snapshots[i]); err != nil {
+			return err
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, snapshot := range snapshots {
+		var sourceIP any
+		if snapshot.SourceIP != "" {
+			sourceIP = snapshot.SourceIP
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO node_metrics (node_id, payload, ingested_at, source_ip)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(node_id) DO UPDATE SET
+				payload = excluded.payload,
+				ingested_at = excluded.ingested_at,
+				source_ip = excluded.source_ip
+		`, snapshot.NodeID, snapshot.Payload, snapshot.IngestedAt, sourceIP)
+		if err != nil {
+			return fmt.Errorf("upsert node metrics: %w", err)
+		}
+		if s.historyRetention <= 0 {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO node_metrics_history (node_id, payload, ingested_at)
+			VALUES (?, ?, ?)
+		`, snapshot.NodeID, snapshot.Payload, snapshot.IngestedAt)
+		if err != nil {
+			return fmt.Errorf("insert node metrics history: %w", err)
+		}
+	}
+	if s.historyRetention > 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM node_metrics_history WHERE ingested_at < ?`, time.Now().UTC().Add(-s.historyRetention))
+		if err != nil {
+			return fmt.Errorf("prune node metrics history: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit node metrics: %w", err)
+	}
+	return nil
+}
+
+func normaliseSnapshot(snapshot *NodeMetricSnapshot) error {
+	snapshot.NodeID = strings.TrimSpace(snapshot.NodeID)
+	if snapshot.NodeID == "" {
 		return errors.New("node id is required")
 	}
-	payload := snapshot.Payload
-	if strings.TrimSpace(payload) == "" {
+	if strings.TrimSpace(snapshot.Payload) == "" {
 		return errors.New("payload is required")
 	}
 	if snapshot.IngestedAt.IsZero() {
@@ -55,21 +136,7 @@ func (s *Store) UpsertNodeMetrics(ctx context.Context, snapshot NodeMetricSnapsh
 	} else {
 		snapshot.IngestedAt = snapshot.IngestedAt.UTC()
 	}
-	var sourceIP any
-	if strings.TrimSpace(snapshot.SourceIP) != "" {
-		sourceIP = strings.TrimSpace(snapshot.SourceIP)
-	}
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO node_metrics (node_id, payload, ingested_at, source_ip)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(node_id) DO UPDATE SET
-			payload = excluded.payload,
-			ingested_at = excluded.ingested_at,
-			source_ip = excluded.source_ip
-	`, nodeID, payload, snapshot.IngestedAt, sourceIP)
-	if err != nil {
-		return fmt.Errorf("upsert node metrics: %w", err)
-	}
+	snapshot.SourceIP = strings.TrimSpace(snapshot.SourceIP)
 	return nil
 }
 
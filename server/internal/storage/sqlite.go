@@ -13,6 +13,9 @@ import (
 // Store wraps read/write access to the sqlite database.
 type Store struct {
 	db *sql.DB
+	// historyRetention is how long ingested snapshots are kept in
+	// node_metrics_history; zero keeps none.
+	historyRetention time.Duration
 }
 
 // Open initialises a sqlite connection with sane defaults.
@@ -143,8 +146,41 @@ type NotificationLog struct {
 	NotifierID string
 	CheckID    string
 	Status     string
+	Severity   string
 	Summary    string
 	OccurredAt time.Time
+	// ErrorClass is the class of a failed delivery (auth, rate_limited,
+	// transient or permanent); empty when the delivery succeeded or the
+	// worker predates error classification.
+	ErrorClass string
+}
+
+// notificationErrorClassColumn selects error_class when the worker's schema
+// has it, so databases written by older workers still read.
+func (s *Store) notificationErrorClassColumn(ctx context.Context) (string, error) {
+	rows, err := s.db.QueryContext(ctx, "PRAGMA table_info(notification_logs)")
+	if err != nil {
+		return "", fmt.Errorf("inspect notification_logs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return "", fmt.Errorf("inspect notification_logs: %w", err)
+		}
+		if name == "error_class" {
+			return "COALESCE(error_class, '')", nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("inspect notification_logs: %w", err)
+	}
+	return "''", nil
 }
 
 // RecentNotificationLogs returns latest notification entries up to limit.
@@ -155,8 +191,12 @@ func (s *Store) RecentNotificationLogs(ctx context.Context, limit int) ([]Notifi
 	if limit <= 0 {
 		limit = 10
 	}
+	errorClass, err := s.notificationErrorClassColumn(ctx)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT notifier_id, check_id, status, summary, occurred_at
+		SELECT notifier_id, check_id, status, summary, occurred_at, `+errorClass+`
 		FROM notification_logs
 		ORDER BY occurred_at DESC
 		LIMIT ?
@@ -169,7 +209,45 @@ func (s *Store) RecentNotificationLogs(ctx context.Context, limit int) ([]Notifi
 	var logs []NotificationLog
 	for rows.Next() {
 		var entry NotificationLog
-		if err := rows.Scan(&entry.NotifierID, &entry.CheckID, &entry.Status, &entry.Summary, &entry.OccurredAt); err != nil {
+		if err := rows.Scan(&entry.NotifierID, &entry.CheckID, &entry.Status, &entry.Summary, &entry.OccurredAt, &entry.ErrorClass); err != nil {
+			return nil, fmt.Errorf("scan notification log: %w", err)
+		}
+		logs = append(logs, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate notification logs: %w", err)
+	}
+	return logs, nil
+}
+
+// CheckNotificationLogs returns the latest notification entries recorded for a check, up to limit.
+func (s *Store) CheckNotificationLogs(ctx context.Context, checkID string, limit int) ([]NotificationLog, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	errorClass, err := s.notificationErrorClassColumn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT notifier_id, check_id, COALESCE(status, ''), COALESCE(severity, ''), COALESCE(summary, ''), occurred_at, `+errorClass+`
+		FROM notification_logs
+		WHERE check_id = ?
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT ?
+	`, checkID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query check notification logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []NotificationLog
+	for rows.Next() {
+		var entry NotificationLog
+		if err := rows.Scan(&entry.NotifierID, &entry.CheckID, &entry.Status, &entry.Severity, &entry.Summary, &entry.OccurredAt, &entry.ErrorClass); err != nil {
 			return nil, fmt.Errorf("scan notification log: %w", err)
 		}
 		logs = append(logs, entry)
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CheckTargetState is the latest outcome against one backend of a pooled
+// check, as written by the worker.
+type CheckTargetState struct {
+	Target     string
+	Success    bool
+	Latency    time.Duration
+	Error      string
+	OccurredAt time.Time
+}
+
+// CheckTargets returns the per-target results of a pooled check ordered by
+// target. Databases written by workers without pool support yield no rows.
+func (s *Store) CheckTargets(ctx context.Context, checkID string) ([]CheckTargetState, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	var tables int
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'check_target_states'
+	`).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("lookup check targets table: %w", err)
+	}
+	if tables == 0 {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT target, success, latency_ms, error, occurred_at
+		FROM check_target_states
+		WHERE check_id = ?
+		ORDER BY target
+	`, checkID)
+	if err != nil {
+		return nil, fmt.Errorf("query check targets: %w", err)
+	}
+	defer rows.Close()
+
+	var result []CheckTargetState
+	for rows.Next() {
+		var (
+			state     CheckTargetState
+			success   int
+			latencyMs int64
+		)
+		if err := rows.Scan(&state.Target, &success, &latencyMs, &state.Error, &state.OccurredAt); err != nil {
+			return nil, fmt.Errorf("scan check target: %w", err)
+		}
+		state.Success = success == 1
+		state.Latency = time.Duration(latencyMs) * time.Millisecond
+		state.OccurredAt = state.OccurredAt.UTC()
+		result = append(result, state)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate check targets: %w", err)
+	}
+	return result, nil
+}
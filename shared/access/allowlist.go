@@ -1,3 +1,5 @@
+// Package access implements the IP allowlists of the server and of the
+// worker's admin listener, so allowed_ips means the same on both.
 package access
 
 import (
@@ -7,7 +9,7 @@ import (
 	"strings"
 )
 
-// Allowlist controls which IP addresses can access the server.
+// Allowlist controls which IP addresses can access a listener.
 type Allowlist struct {
 	networks      []*net.IPNet
 	allowAll      bool
@@ -100,6 +102,19 @@ func ClientIPFromRequest(r *http.Request, trusted []*net.IPNet) (net.IP, string)
 	return ip, ip.String()
 }
 
+// RemoteIP returns the IP of the connection peer, ignoring forwarded
+// headers, for listeners that are not meant to sit behind a proxy.
+func RemoteIP(r *http.Request) net.IP {
+	if r == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
 func lastHopTrusted(remoteAddr string, trusted []*net.IPNet) bool {
 	if len(trusted) == 0 {
 		return false
@@ -56,3 +56,11 @@ func TestClientIPFromRequestRespectsXForwardedFor(t *testing.T) {
 	}
 }
 
+func TestRemoteIPIgnoresForwardedHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.10")
+	if got := RemoteIP(req); !got.Equal(net.ParseIP("10.0.0.5")) {
+		t.Fatalf("expected the connection peer, got %s", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Package computed evaluates the computed metrics of a metrics check against
+// a node's Prometheus payload. The worker compares them against thresholds
+// and the server exposes them on its metrics endpoint, so both use it to get
+// the same values.
+package computed
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Knetic/govaluate"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metric defines a derived metric calculated from other metrics.
+type Metric struct {
+	Expression  string               `yaml:"expression"`
+	Variables   map[string]Reference `yaml:"variables"`
+	Labels      map[string]string    `yaml:"labels"`
+	Description string               `yaml:"description"`
+}
+
+// Reference identifies a metric to pull into a computed expression. It names
+// either a series of the payload or another computed metric.
+type Reference struct {
+	Name    string            `yaml:"name"`
+	Labels  map[string]string `yaml:"labels"`
+	Default *float64          `yaml:"default"`
+}
+
+type result struct {
+	value float64
+	err   error
+	// resolving marks a metric whose expression is being evaluated so that
+	// references back to it are reported as a cycle.
+	resolving bool
+}
+
+// Evaluator resolves computed metrics against one set of metric families,
+// evaluating each metric at most once.
+type Evaluator struct {
+	families map[string]*dto.MetricFamily
+	specs    map[string]Metric
+	results  map[string]result
+}
+
+// NewEvaluator returns an evaluator for specs over families.
+func NewEvaluator(families map[string]*dto.MetricFamily, specs map[string]Metric) *Evaluator {
+	return &Evaluator{
+		families: families,
+		specs:    specs,
+		results:  make(map[string]result, len(specs)),
+	}
+}
+
+// Value evaluates the computed metric name, resolving variables that
+// reference other computed metrics recursively.
+func (e *Evaluator) Value(name string) (float64, error) {
+	res := e.resolve(name, nil)
+	return res.value, res.err
+}
+
+// Values evaluates every computed metric. Metrics that produced a value are
+// returned by name; the others are reported in the error map.
+func (e *Evaluator) Values() (map[string]float64, map[string]error) {
+	names := make([]string, 0, len(e.specs))
+	for name := range e.specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string]float64, len(names))
+	var errs map[string]error
+	for _, name := range names {
+		value, err := e.Value(name)
+		if err != nil {
+			if errs == nil {
+				errs = map[string]error{}
+			}
+			errs[name] = err
+			continue
+		}
+		values[name] = value
+	}
+	return values, errs
+}
+
+// resolve returns the cached result of name or evaluates it. chain holds the
+// computed metrics currently being resolved and is used to describe cycles.
+func (e *Evaluator) resolve(name string, chain []string) result {
+	if cached, ok := e.results[name]; ok {
+		if cached.resolving {
+			return result{err: fmt.Errorf("computed metric cycle: %s", describeCycle(chain, name))}
+		}
+		return cached
+	}
+	e.results[name] = result{resolving: true}
+	res := e.evaluate(name, chain)
+	e.results[name] = res
+	return res
+}
+
+func (e *Evaluator) evaluate(name string, chain []string) result {
+	spec, ok := e.specs[name]
+	if !ok {
+		return result{err: fmt.Errorf("computed metric %q not defined", name)}
+	}
+	exprStr := strings.TrimSpace(spec.Expression)
+	if exprStr == "" {
+		return result{err: fmt.Errorf("computed metric %q missing expression", name)}
+	}
+	if len(spec.Variables) == 0 {
+		return result{err: fmt.Errorf("computed metric %q has no variables", name)}
+	}
+
+	vars := make(map[string]interface{}, len(spec.Variables))
+	for varName, ref := range spec.Variables {
+		if strings.TrimSpace(varName) == "" {
+			return result{err: fmt.Errorf("computed metric %q has empty variable name", name)}
+		}
+		var value float64
+		var err error
+		if dep, ok := e.specs[ref.Name]; ok {
+			if len(ref.Labels) > 0 && !LabelsEqual(dep.Labels, ref.Labels) {
+				err = fmt.Errorf("labels do not match computed metric %q", ref.Name)
+			} else {
+				depResult := e.resolve(ref.Name, append(chain, name))
+				value, err = depResult.value, depResult.err
+			}
+		} else {
+			value, err = e.reference(ref)
+		}
+		if err != nil {
+			return result{err: fmt.Errorf("variable %q: %w", varName, err)}
+		}
+		vars[varName] = value
+	}
+
+	expr, err := govaluate.NewEvaluableExpression(exprStr)
+	if err != nil {
+		return result{err: fmt.Errorf("parse expression: %w", err)}
+	}
+	raw, err := expr.Evaluate(vars)
+	if err != nil {
+		return result{err: fmt.Errorf("evaluate expression: %w", err)}
+	}
+	value, ok := toFloat64(raw)
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return result{err: fmt.Errorf("expression result is not a finite number")}
+	}
+	return result{value: value}
+}
+
+func (e *Evaluator) reference(ref Reference) (float64, error) {
+	if strings.TrimSpace(ref.Name) == "" {
+		return 0, fmt.Errorf("metric name is required")
+	}
+	family, ok := e.families[ref.Name]
+	if !ok {
+		if ref.Default != nil {
+			return *ref.Default, nil
+		}
+		return 0, fmt.Errorf("metric %q not found", ref.Name)
+	}
+	value, found, err := SeriesValue(family, ref.Labels)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		if ref.Default != nil {
+			return *ref.Default, nil
+		}
+		return 0, fmt.Errorf("no series matched labels %s", FormatLabels(ref.Labels))
+	}
+	return value, nil
+}
+
+// SeriesValue returns the value of the first series of family carrying all of
+// labels. found is false when no series matches.
+func SeriesValue(family *dto.MetricFamily, labels map[string]string) (value float64, found bool, err error) {
+	if family == nil {
+		return 0, false, nil
+	}
+	for _, metric := range family.Metric {
+		if !labelsMatch(metric, labels) {
+			continue
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			if metric.Counter == nil {
+				return 0, false, fmt.Errorf("metric missing counter value")
+			}
+			return metric.Counter.GetValue(), true, nil
+		case dto.MetricType_GAUGE:
+			if metric.Gauge == nil {
+				return 0, false, fmt.Errorf("metric missing gauge value")
+			}
+			return metric.Gauge.GetValue(), true, nil
+		case dto.MetricType_UNTYPED:
+			if metric.Untyped == nil {
+				return 0, false, fmt.Errorf("metric missing untyped value")
+			}
+			return metric.Untyped.GetValue(), true, nil
+		default:
+			return 0, false, fmt.Errorf("unsupported metric type %s", family.GetType().String())
+		}
+	}
+	return 0, false, nil
+}
+
+// LabelsEqual reports whether a and b hold the same label set.
+func LabelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, val := range a {
+		if b[key] != val {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatLabels renders labels as a sorted {key="value",...} selector, or an
+// empty string when there are none.
+func FormatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, key, labels[key]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func labelsMatch(metric *dto.Metric, expected map[string]string) bool {
+	for key, value := range expected {
+		found := false
+		for _, pair := range metric.Label {
+			if pair.GetName() == key && pair.GetValue() == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func describeCycle(chain []string, name string) string {
+	start := 0
+	for i, entry := range chain {
+		if entry == name {
+			start = i
+			break
+		}
+	}
+	path := append(append([]string{}, chain[start:]...), name)
+	return strings.Join(path, " -> ")
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
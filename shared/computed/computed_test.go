@@ -0,0 +1,74 @@
+package computed
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func parseFamilies(t *testing.T, payload string) map[string]*dto.MetricFamily {
+	t.Helper()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("parse payload: %v", err)
+	}
+	return families
+}
+
+func TestValueResolvesNestedMetricsAndDefaults(t *testing.T) {
+	families := parseFamilies(t, `# TYPE node_filesystem_avail_bytes gauge
+node_filesystem_avail_bytes{mountpoint="/"} 25
+node_filesystem_avail_bytes{mountpoint="/data"} 90
+# TYPE node_filesystem_size_bytes gauge
+node_filesystem_size_bytes{mountpoint="/"} 100
+`)
+	fallback := 0.0
+	specs := map[string]Metric{
+		"root_used_ratio": {
+			Expression: "1 - avail / size",
+			Variables: map[string]Reference{
+				"avail": {Name: "node_filesystem_avail_bytes", Labels: map[string]string{"mountpoint": "/"}},
+				"size":  {Name: "node_filesystem_size_bytes", Labels: map[string]string{"mountpoint": "/"}},
+			},
+		},
+		"root_used_percent": {
+			Expression: "ratio * 100 + missing",
+			Variables: map[string]Reference{
+				"ratio":   {Name: "root_used_ratio"},
+				"missing": {Name: "node_not_exported", Default: &fallback},
+			},
+		},
+	}
+
+	value, err := NewEvaluator(families, specs).Value("root_used_percent")
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if value != 75 {
+		t.Fatalf("expected 75, got %v", value)
+	}
+}
+
+func TestValuesReportsCyclesAndMissingSeries(t *testing.T) {
+	families := parseFamilies(t, "node_load1 1\n")
+	specs := map[string]Metric{
+		"a":    {Expression: "b + 1", Variables: map[string]Reference{"b": {Name: "b"}}},
+		"b":    {Expression: "a + 1", Variables: map[string]Reference{"a": {Name: "a"}}},
+		"load": {Expression: "l * 2", Variables: map[string]Reference{"l": {Name: "node_load1"}}},
+		"gone": {Expression: "x", Variables: map[string]Reference{"x": {Name: "node_load1", Labels: map[string]string{"cpu": "0"}}}},
+	}
+
+	values, errs := NewEvaluator(families, specs).Values()
+	if len(values) != 1 || values["load"] != 2 {
+		t.Fatalf("expected only load=2, got %v", values)
+	}
+	if err := errs["a"]; err == nil || !strings.Contains(err.Error(), "computed metric cycle: a -> b -> a") {
+		t.Fatalf("expected cycle error for a, got %v", err)
+	}
+	if err := errs["gone"]; err == nil || !strings.Contains(err.Error(), `no series matched labels {cpu="0"}`) {
+		t.Fatalf("expected missing series error, got %v", err)
+	}
+}
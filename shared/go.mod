@@ -0,0 +1,18 @@
+module github.com/osbits/upupup/shared
+
+go 1.24.0
+
+toolchain go1.24.10
+
+require (
+	github.com/Knetic/govaluate v3.0.0+incompatible
+	github.com/prometheus/client_model v0.6.1
+	github.com/prometheus/common v0.55.0
+	github.com/robfig/cron/v3 v3.0.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
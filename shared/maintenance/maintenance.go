@@ -0,0 +1,185 @@
+// Package maintenance interprets service.defaults.maintenance_windows. The
+// server and the worker both use it, so they agree on when a window is open.
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// rangeLayout is the timestamp layout of both ends of a range window.
+const rangeLayout = "2006-01-02T15:04"
+
+// Spec includes cron or range expressions.
+type Spec struct {
+	Expr string
+	Kind Kind
+}
+
+// Kind indicates the maintenance window type.
+type Kind string
+
+const (
+	KindCron  Kind = "cron"
+	KindRange Kind = "range"
+)
+
+// UnmarshalYAML allows parsing "cron: ..." or "range: ...".
+func (m *Spec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("maintenance spec must be scalar, got %s", value.ShortTag())
+	}
+	raw := strings.TrimSpace(value.Value)
+	switch {
+	case strings.HasPrefix(raw, "cron:"):
+		m.Kind = KindCron
+		m.Expr = strings.TrimSpace(strings.TrimPrefix(raw, "cron:"))
+	case strings.HasPrefix(raw, "range:"):
+		m.Kind = KindRange
+		m.Expr = strings.TrimSpace(strings.TrimPrefix(raw, "range:"))
+	default:
+		return fmt.Errorf("unsupported maintenance spec %q", raw)
+	}
+	return nil
+}
+
+// MarshalYAML renders the spec in its "kind: expr" form.
+func (m Spec) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%s: %s", m.Kind, m.Expr), nil
+}
+
+// Window is a parsed maintenance window: a fixed range, or a cron schedule
+// whose occurrences each last Duration.
+type Window struct {
+	Kind     Kind
+	Expr     string
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+	schedule cron.Schedule
+}
+
+// Parse parses the configured windows in loc. Cron occurrences last
+// defaultDuration, or an hour when it is zero.
+func Parse(specs []Spec, loc *time.Location, defaultDuration time.Duration) ([]Window, error) {
+	result := make([]Window, 0, len(specs))
+	for _, spec := range specs {
+		switch spec.Kind {
+		case KindRange:
+			start, end, err := parseRange(spec.Expr, loc)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, Window{
+				Kind:  KindRange,
+				Expr:  spec.Expr,
+				Start: start,
+				End:   end,
+			})
+		case KindCron:
+			schedule, err := cron.ParseStandard(spec.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("parse cron %q: %w", spec.Expr, err)
+			}
+			duration := defaultDuration
+			if duration == 0 {
+				duration = time.Hour
+			}
+			result = append(result, Window{
+				Kind:     KindCron,
+				Expr:     spec.Expr,
+				Duration: duration,
+				schedule: schedule,
+			})
+		default:
+			return nil, fmt.Errorf("unsupported maintenance kind %q", spec.Kind)
+		}
+	}
+	return result, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	_, _, ok := w.Current(t)
+	return ok
+}
+
+// Current returns the occurrence of the window that contains t.
+func (w Window) Current(t time.Time) (start, end time.Time, ok bool) {
+	switch w.Kind {
+	case KindRange:
+		if w.Start.IsZero() || w.End.IsZero() {
+			return time.Time{}, time.Time{}, false
+		}
+		if t.Before(w.Start) || !t.Before(w.End) {
+			return time.Time{}, time.Time{}, false
+		}
+		return w.Start, w.End, true
+	case KindCron:
+		if w.schedule == nil {
+			return time.Time{}, time.Time{}, false
+		}
+		prev := w.schedule.Next(t.Add(-w.Duration))
+		if prev.After(t) || t.Sub(prev) > w.Duration {
+			return time.Time{}, time.Time{}, false
+		}
+		return prev, prev.Add(w.Duration), true
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+}
+
+// Next returns the first occurrence of the window that starts after t. A
+// range window has none once it has started.
+func (w Window) Next(t time.Time) (start, end time.Time, ok bool) {
+	switch w.Kind {
+	case KindRange:
+		if w.Start.IsZero() || w.End.IsZero() || !w.Start.After(t) {
+			return time.Time{}, time.Time{}, false
+		}
+		return w.Start, w.End, true
+	case KindCron:
+		if w.schedule == nil {
+			return time.Time{}, time.Time{}, false
+		}
+		next := w.schedule.Next(t)
+		if next.IsZero() {
+			return time.Time{}, time.Time{}, false
+		}
+		return next, next.Add(w.Duration), true
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+}
+
+// Active reports whether any of the windows contains t.
+func Active(windows []Window, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange splits "2006-01-02T15:04-2006-01-02T18:00" into its start and
+// end.
+func parseRange(expr string, loc *time.Location) (time.Time, time.Time, error) {
+	chunks := strings.SplitN(expr, "-", 6)
+	if len(chunks) < 6 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q", expr)
+	}
+	start, err := time.ParseInLocation(rangeLayout, strings.Join(chunks[:3], "-"), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse range start: %w", err)
+	}
+	end, err := time.ParseInLocation(rangeLayout, strings.Join(chunks[3:], "-"), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse range end: %w", err)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,80 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, specs ...Spec) []Window {
+	t.Helper()
+	windows, err := Parse(specs, time.UTC, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return windows
+}
+
+func at(value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func TestCronWindowOccurrences(t *testing.T) {
+	window := mustParse(t, Spec{Kind: KindCron, Expr: "0 2 * * *"})[0]
+
+	now := at("2025-03-04T12:00:00Z")
+	if window.Contains(now) {
+		t.Fatalf("expected window closed at %s", now)
+	}
+	start, end, ok := window.Next(now)
+	if !ok || !start.Equal(at("2025-03-05T02:00:00Z")) || !end.Equal(at("2025-03-05T02:30:00Z")) {
+		t.Fatalf("unexpected next occurrence %s-%s (ok=%v)", start, end, ok)
+	}
+
+	now = at("2025-03-05T02:10:00Z")
+	start, end, ok = window.Current(now)
+	if !ok || !start.Equal(at("2025-03-05T02:00:00Z")) || !end.Equal(at("2025-03-05T02:30:00Z")) {
+		t.Fatalf("unexpected current occurrence %s-%s (ok=%v)", start, end, ok)
+	}
+	start, _, ok = window.Next(now)
+	if !ok || !start.Equal(at("2025-03-06T02:00:00Z")) {
+		t.Fatalf("expected next occurrence the following night, got %s (ok=%v)", start, ok)
+	}
+}
+
+func TestRangeWindowOccurrences(t *testing.T) {
+	window := mustParse(t, Spec{Kind: KindRange, Expr: "2025-03-05T22:00-2025-03-06T01:00"})[0]
+
+	start, end, ok := window.Next(at("2025-03-05T12:00:00Z"))
+	if !ok || !start.Equal(at("2025-03-05T22:00:00Z")) || !end.Equal(at("2025-03-06T01:00:00Z")) {
+		t.Fatalf("unexpected next occurrence %s-%s (ok=%v)", start, end, ok)
+	}
+
+	now := at("2025-03-05T23:00:00Z")
+	if !window.Contains(now) {
+		t.Fatalf("expected window open at %s", now)
+	}
+	if _, _, ok := window.Next(now); ok {
+		t.Fatalf("expected no next occurrence once the range started")
+	}
+
+	if window.Contains(at("2025-03-06T01:00:00Z")) {
+		t.Fatalf("expected range end to be exclusive")
+	}
+}
+
+func TestParseRejectsInvalidWindows(t *testing.T) {
+	specs := []Spec{
+		{Kind: KindRange, Expr: "2025-03-05T22:00"},
+		{Kind: KindCron, Expr: "not a cron"},
+		{Kind: "weekly", Expr: "mon"},
+	}
+	for _, spec := range specs {
+		if _, err := Parse([]Spec{spec}, time.UTC, 0); err == nil {
+			t.Fatalf("expected %s %q to be rejected", spec.Kind, spec.Expr)
+		}
+	}
+}
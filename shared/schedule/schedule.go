@@ -0,0 +1,50 @@
+// Package schedule resolves how often a check runs. The worker schedules
+// checks with it and the server derives when their runs are due from it, so
+// the two can't disagree.
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronLookahead is how many upcoming activations CronInterval compares, so
+// a weekday-only schedule reports its weekend gap.
+const cronLookahead = 64
+
+// ClampInterval raises interval to minInterval, service.defaults.min_interval,
+// when it is shorter and reports whether it did. A zero minInterval disables
+// the floor.
+func ClampInterval(interval, minInterval time.Duration) (time.Duration, bool) {
+	if minInterval > 0 && interval < minInterval {
+		return minInterval, true
+	}
+	return interval, false
+}
+
+// CronInterval returns the longest gap between the upcoming activations of
+// a standard five-field cron expression after t, evaluated in t's location.
+// It is the longest a cron-scheduled check may legitimately go without a
+// run.
+func CronInterval(spec string, t time.Time) (time.Duration, error) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return 0, fmt.Errorf("parse cron %q: %w", spec, err)
+	}
+	var longest time.Duration
+	prev := sched.Next(t)
+	for i := 0; i < cronLookahead && !prev.IsZero(); i++ {
+		next := sched.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		longest = max(longest, next.Sub(prev))
+		prev = next
+	}
+	if longest == 0 {
+		return 0, fmt.Errorf("cron %q has no further activations", spec)
+	}
+	return longest, nil
+}
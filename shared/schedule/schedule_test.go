@@ -0,0 +1,44 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClampInterval(t *testing.T) {
+	cases := []struct {
+		interval, floor, want time.Duration
+		clamped               bool
+	}{
+		{interval: 10 * time.Second, floor: time.Minute, want: time.Minute, clamped: true},
+		{interval: 5 * time.Minute, floor: time.Minute, want: 5 * time.Minute},
+		{interval: 10 * time.Second, want: 10 * time.Second},
+	}
+	for _, tc := range cases {
+		got, clamped := ClampInterval(tc.interval, tc.floor)
+		if got != tc.want || clamped != tc.clamped {
+			t.Fatalf("ClampInterval(%s, %s) = %s, %v; want %s, %v", tc.interval, tc.floor, got, clamped, tc.want, tc.clamped)
+		}
+	}
+}
+
+func TestCronInterval(t *testing.T) {
+	monday := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"*/5 * * * *": 5 * time.Minute,
+		"0 * * * *":   time.Hour,
+		"0 9 * * 1-5": 72 * time.Hour,
+	}
+	for spec, want := range cases {
+		got, err := CronInterval(spec, monday)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if got != want {
+			t.Fatalf("CronInterval(%q) = %s, want %s", spec, got, want)
+		}
+	}
+	if _, err := CronInterval("every day", monday); err == nil {
+		t.Fatalf("expected an invalid expression to fail")
+	}
+}
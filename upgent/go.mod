@@ -4,5 +4,12 @@ go 1.24.0
 
 toolchain go1.24.10
 
-
-
+require (
+	github.com/prometheus/client_model v0.6.1
+	github.com/prometheus/common v0.55.0
+)
+
+require (
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
@@ -19,9 +19,10 @@ import (
 
 // Agent periodically scrapes a source endpoint and forwards the payload to the ingest API.
 type Agent struct {
-	cfg    *config.Config
-	logger *slog.Logger
-	client *http.Client
+	cfg     *config.Config
+	logger  *slog.Logger
+	client  *http.Client
+	metrics *selfMetrics
 }
 
 // New constructs an Agent instance with reasonable defaults.
@@ -56,12 +57,25 @@ func New(cfg *config.Config, logger *slog.Logger) (*Agent, error) {
 			Timeout:   cfg.Timeout,
 			Transport: transport,
 		},
+		metrics: newSelfMetrics(),
 	}, nil
 }
 
 // Run starts the scrape/forward loop and blocks until context cancellation.
 func (a *Agent) Run(ctx context.Context) error {
-	a.logger.Info("starting upgent", "node_id", a.cfg.NodeID, "interval", a.cfg.Interval)
+	a.logger.Info("starting upgent", "node_id", a.cfg.NodeID, "interval", a.cfg.Interval, "scrape_urls", len(a.cfg.ScrapeURLs))
+
+	if a.cfg.MetricsListen != "" {
+		ln, err := net.Listen("tcp", a.cfg.MetricsListen)
+		if err != nil {
+			return fmt.Errorf("metrics listen: %w", err)
+		}
+		go func() {
+			if err := a.serveMetrics(ctx, ln); err != nil {
+				a.logger.Error("metrics listener failed", "error", err)
+			}
+		}()
+	}
 
 	if err := a.execute(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		a.logger.Error("initial scrape failed", "error", err)
@@ -83,15 +97,21 @@ func (a *Agent) Run(ctx context.Context) error {
 	}
 }
 
-func (a *Agent) execute(ctx context.Context) error {
+func (a *Agent) execute(ctx context.Context) (err error) {
 	start := time.Now()
+	defer func() {
+		a.metrics.observeCycle(err)
+	}()
 
 	payload, err := a.scrape(ctx)
 	if err != nil {
 		return err
 	}
 
-	if err := a.forward(ctx, payload); err != nil {
+	forwardStart := time.Now()
+	err = a.forward(ctx, payload)
+	a.metrics.observeForward(len(payload), time.Since(forwardStart), err)
+	if err != nil {
 		return err
 	}
 
@@ -99,8 +119,9 @@ func (a *Agent) execute(ctx context.Context) error {
 	return nil
 }
 
-func (a *Agent) scrape(ctx context.Context) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.cfg.ScrapeURL, nil)
+// scrapeURL fetches one metrics endpoint.
+func (a *Agent) scrapeURL(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
 		return nil, fmt.Errorf("build scrape request: %w", err)
 	}
@@ -109,13 +130,13 @@ func (a *Agent) scrape(ctx context.Context) ([]byte, error) {
 
 	resp, err := a.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("scrape %s: %w", a.cfg.ScrapeURL, err)
+		return nil, fmt.Errorf("scrape %s: %w", target, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
-		return nil, fmt.Errorf("scrape %s: unexpected status %d: %s", a.cfg.ScrapeURL, resp.StatusCode, strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("scrape %s: unexpected status %d: %s", target, resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
 	limited := io.LimitReader(resp.Body, a.cfg.MaxMetricsBytes+1)
@@ -174,4 +195,3 @@ func (a *Agent) forward(ctx context.Context, payload []byte) error {
 	}
 	return nil
 }
-
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// selfMetrics tracks how the agent itself is doing, so the server can
+// monitor its agents through UPGENT_METRICS_LISTEN.
+type selfMetrics struct {
+	mu              sync.Mutex
+	cycles          int
+	cycleFailures   int
+	scrapeFailures  map[string]int
+	forwardFailures int
+	forwardedBytes  int64
+	payloadBytes    int
+	forwardDuration time.Duration
+	lastSuccess     time.Time
+	scrapeDurations map[string]time.Duration
+}
+
+func newSelfMetrics() *selfMetrics {
+	return &selfMetrics{
+		scrapeFailures:  map[string]int{},
+		scrapeDurations: map[string]time.Duration{},
+	}
+}
+
+func (m *selfMetrics) observeScrapes(results []scrapeResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, res := range results {
+		m.scrapeDurations[res.url] = res.duration
+		if _, ok := m.scrapeFailures[res.url]; !ok {
+			m.scrapeFailures[res.url] = 0
+		}
+		if res.err != nil {
+			m.scrapeFailures[res.url]++
+		}
+	}
+}
+
+func (m *selfMetrics) observeForward(size int, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payloadBytes = size
+	m.forwardDuration = duration
+	if err != nil {
+		m.forwardFailures++
+		return
+	}
+	m.forwardedBytes += int64(size)
+}
+
+func (m *selfMetrics) observeCycle(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cycles++
+	if err != nil {
+		m.cycleFailures++
+		return
+	}
+	m.lastSuccess = time.Now()
+}
+
+func (m *selfMetrics) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeMetric(&b, "upgent_cycles_total", "counter", "Scrape and forward cycles run by the agent.", float64(m.cycles))
+	writeMetric(&b, "upgent_cycle_failures_total", "counter", "Cycles that forwarded nothing.", float64(m.cycleFailures))
+	writeMetric(&b, "upgent_forward_failures_total", "counter", "Failed requests to the ingest API.", float64(m.forwardFailures))
+	writeMetric(&b, "upgent_forwarded_bytes_total", "counter", "Uncompressed payload bytes accepted by the ingest API.", float64(m.forwardedBytes))
+	writeMetric(&b, "upgent_payload_bytes", "gauge", "Uncompressed size of the last forwarded payload.", float64(m.payloadBytes))
+	writeMetric(&b, "upgent_forward_duration_seconds", "gauge", "Duration of the last request to the ingest API.", m.forwardDuration.Seconds())
+	var lastSuccess float64
+	if !m.lastSuccess.IsZero() {
+		lastSuccess = float64(m.lastSuccess.Unix())
+	}
+	writeMetric(&b, "upgent_last_success_timestamp_seconds", "gauge", "Time of the last cycle that forwarded metrics.", lastSuccess)
+
+	urls := make([]string, 0, len(m.scrapeFailures))
+	for url := range m.scrapeFailures {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	if len(urls) > 0 {
+		b.WriteString("# HELP upgent_scrape_failures_total Failed scrapes of the URL.\n")
+		b.WriteString("# TYPE upgent_scrape_failures_total counter\n")
+		for _, url := range urls {
+			fmt.Fprintf(&b, "upgent_scrape_failures_total{url=\"%s\"} %d\n", urlLabel(url), m.scrapeFailures[url])
+		}
+		b.WriteString("# HELP upgent_scrape_last_duration_seconds Duration of the last scrape of the URL.\n")
+		b.WriteString("# TYPE upgent_scrape_last_duration_seconds gauge\n")
+		for _, url := range urls {
+			fmt.Fprintf(&b, "upgent_scrape_last_duration_seconds{url=\"%s\"} %g\n", urlLabel(url), m.scrapeDurations[url].Seconds())
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// labelEscaper escapes a label value for the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// urlLabel is the url label value of a scrape URL: credentials, query and
+// fragment are dropped so secrets passed in the URL are not exposed.
+func urlLabel(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "invalid"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return labelEscaper.Replace(u.String())
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(b, "%s %g\n", name, value)
+}
+
+// serveMetrics exposes the agent's own metrics on /metrics of the
+// UPGENT_METRICS_LISTEN address until ctx is cancelled.
+func (a *Agent) serveMetrics(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", a.metrics.handleMetrics)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	a.logger.Info("metrics listener started", "addr", ln.Addr().String())
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
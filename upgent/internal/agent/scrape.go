@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// scrapeResult is the outcome of scraping one URL in a cycle.
+type scrapeResult struct {
+	url      string
+	families []*dto.MetricFamily
+	err      error
+	duration time.Duration
+}
+
+// scrape fetches every configured URL with at most ScrapeWorkers requests in
+// flight and merges the payloads by metric family in URL order, so a family
+// exposed by several exporters is forwarded with one HELP and TYPE. Each URL
+// gets a slice of the overall Timeout, so the cycle finishes in time even
+// when an exporter hangs. Failed URLs are reported through upgent_scrape_up
+// instead of dropping the healthy ones; scrape only fails when no URL
+// succeeded.
+func (a *Agent) scrape(ctx context.Context) ([]byte, error) {
+	results := a.scrapeAll(ctx)
+	a.metrics.observeScrapes(results)
+
+	var errs []error
+	var order []string
+	merged := map[string]*dto.MetricFamily{}
+	for _, res := range results {
+		if res.err != nil {
+			a.logger.Warn("scrape failed", "url", res.url, "duration", res.duration, "error", res.err)
+			errs = append(errs, res.err)
+			continue
+		}
+		for _, family := range res.families {
+			name := family.GetName()
+			existing, ok := merged[name]
+			if !ok {
+				merged[name] = family
+				order = append(order, name)
+				continue
+			}
+			if existing.GetType() != family.GetType() {
+				a.logger.Warn("dropping metric family with conflicting type", "url", res.url, "family", name, "type", family.GetType().String(), "first_type", existing.GetType().String())
+				continue
+			}
+			if existing.Help == nil {
+				existing.Help = family.Help
+			}
+			existing.Metric = append(existing.Metric, family.Metric...)
+		}
+	}
+	if len(errs) == len(results) {
+		return nil, errors.Join(errs...)
+	}
+	var payload bytes.Buffer
+	for _, name := range order {
+		if _, err := expfmt.MetricFamilyToText(&payload, merged[name]); err != nil {
+			return nil, fmt.Errorf("encode metric family %s: %w", name, err)
+		}
+	}
+	writeScrapeStatus(&payload, results)
+	if int64(payload.Len()) > a.cfg.MaxMetricsBytes {
+		return nil, fmt.Errorf("scrape payload exceeds %d bytes", a.cfg.MaxMetricsBytes)
+	}
+	return payload.Bytes(), nil
+}
+
+func (a *Agent) scrapeAll(ctx context.Context) []scrapeResult {
+	urls := a.cfg.ScrapeURLs
+	workers := min(max(a.cfg.ScrapeWorkers, 1), len(urls))
+	slot := scrapeSlot(a.cfg.Timeout, len(urls), workers)
+
+	results := make([]scrapeResult, len(urls))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range next {
+				results[idx] = a.scrapeWithin(ctx, urls[idx], slot)
+			}
+		}()
+	}
+	for idx := range urls {
+		next <- idx
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// scrapeSlot divides the overall timeout between the rounds of scrapes the
+// worker pool needs for n URLs.
+func scrapeSlot(timeout time.Duration, n, workers int) time.Duration {
+	rounds := (n + workers - 1) / workers
+	return timeout / time.Duration(rounds)
+}
+
+// scrapeWithin scrapes target after a small random delay, which spreads
+// requests to exporters sharing a host, and gives up when its slot ends.
+func (a *Agent) scrapeWithin(ctx context.Context, target string, slot time.Duration) scrapeResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, slot)
+	defer cancel()
+	if jitter := slot / 10; jitter > 0 {
+		select {
+		case <-ctx.Done():
+			return scrapeResult{url: target, err: ctx.Err(), duration: time.Since(start)}
+		case <-time.After(rand.N(jitter)):
+		}
+	}
+	data, err := a.scrapeURL(ctx, target)
+	var families []*dto.MetricFamily
+	if err == nil {
+		families, err = parseFamilies(data)
+		if err != nil {
+			err = fmt.Errorf("scrape %s: %w", target, err)
+		}
+	}
+	return scrapeResult{url: target, families: families, err: err, duration: time.Since(start)}
+}
+
+// parseFamilies parses a text exposition into its metric families, sorted by
+// name.
+func parseFamilies(data []byte) ([]*dto.MetricFamily, error) {
+	var parser expfmt.TextParser
+	byName, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse metrics: %w", err)
+	}
+	families := make([]*dto.MetricFamily, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, nil
+}
+
+// writeScrapeStatus appends per-URL health so failed exporters stay visible
+// on the server.
+func writeScrapeStatus(buf *bytes.Buffer, results []scrapeResult) {
+	buf.WriteString("# HELP upgent_scrape_up Whether the last scrape of the URL succeeded.\n")
+	buf.WriteString("# TYPE upgent_scrape_up gauge\n")
+	for _, res := range results {
+		up := 1
+		if res.err != nil {
+			up = 0
+		}
+		fmt.Fprintf(buf, "upgent_scrape_up{url=\"%s\"} %d\n", urlLabel(res.url), up)
+	}
+	buf.WriteString("# HELP upgent_scrape_duration_seconds Duration of the last scrape of the URL.\n")
+	buf.WriteString("# TYPE upgent_scrape_duration_seconds gauge\n")
+	for _, res := range results {
+		fmt.Fprintf(buf, "upgent_scrape_duration_seconds{url=\"%s\"} %g\n", urlLabel(res.url), res.duration.Seconds())
+	}
+}
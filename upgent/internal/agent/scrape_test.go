@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/osbits/upupup/upgent/internal/config"
+)
+
+// ingestStub accepts forwarded payloads and hands them to the test.
+func ingestStub(t *testing.T) (*httptest.Server, <-chan string) {
+	t.Helper()
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies
+}
+
+func newTestAgent(t *testing.T, ingestURL string, timeout time.Duration, scrapeURLs ...string) *Agent {
+	t.Helper()
+	a, err := New(&config.Config{
+		NodeID:          "node-1",
+		ScrapeURLs:      scrapeURLs,
+		ScrapeWorkers:   len(scrapeURLs),
+		Timeout:         timeout,
+		MaxMetricsBytes: 1 << 20,
+		UserAgent:       "upgent-test",
+		IngestURL:       ingestURL,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new agent: %v", err)
+	}
+	return a
+}
+
+func TestExecuteForwardsHealthyPayloadWhenAScrapeStalls(t *testing.T) {
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(stalled.Close)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "node_load1 0.5\n")
+	}))
+	t.Cleanup(healthy.Close)
+	ingest, bodies := ingestStub(t)
+
+	const timeout = 500 * time.Millisecond
+	a := newTestAgent(t, ingest.URL, timeout, stalled.URL, healthy.URL)
+	start := time.Now()
+	if err := a.execute(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > timeout+250*time.Millisecond {
+		t.Fatalf("expected the cycle to finish within the timeout, took %s", elapsed)
+	}
+
+	body := <-bodies
+	if !strings.Contains(body, "node_load1 0.5\n") {
+		t.Fatalf("expected the healthy payload to be forwarded, got:\n%s", body)
+	}
+	if want := "upgent_scrape_up{url=" + strconv.Quote(stalled.URL) + "} 0\n"; !strings.Contains(body, want) {
+		t.Fatalf("expected %q in the payload, got:\n%s", want, body)
+	}
+	if want := "upgent_scrape_up{url=" + strconv.Quote(healthy.URL) + "} 1\n"; !strings.Contains(body, want) {
+		t.Fatalf("expected %q in the payload, got:\n%s", want, body)
+	}
+}
+
+func TestExecuteMergesFamiliesSharedByTargets(t *testing.T) {
+	exporter := func(value string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "# HELP process_open_fds Number of open file descriptors.\n# TYPE process_open_fds gauge\nprocess_open_fds{job=\""+value+"\"} "+value+"\n")
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	first, second := exporter("3"), exporter("7")
+	ingest, bodies := ingestStub(t)
+
+	a := newTestAgent(t, ingest.URL, 2*time.Second, first.URL, second.URL)
+	if err := a.execute(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	body := <-bodies
+	for _, meta := range []string{"# HELP process_open_fds ", "# TYPE process_open_fds "} {
+		if n := strings.Count(body, meta); n != 1 {
+			t.Fatalf("expected one %q line, got %d in:\n%s", meta, n, body)
+		}
+	}
+	for _, sample := range []string{"process_open_fds{job=\"3\"} 3\n", "process_open_fds{job=\"7\"} 7\n"} {
+		if !strings.Contains(body, sample) {
+			t.Fatalf("expected %q in the payload, got:\n%s", sample, body)
+		}
+	}
+	var parser expfmt.TextParser
+	if _, err := parser.TextToMetricFamilies(strings.NewReader(body)); err != nil {
+		t.Fatalf("expected a valid exposition, got %v:\n%s", err, body)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
@@ -15,13 +16,15 @@ const (
 	defaultInterval        = 15 * time.Second
 	defaultTimeout         = 10 * time.Second
 	defaultMaxMetricsBytes = 2 * 1024 * 1024 // 2 MiB
+	defaultScrapeWorkers   = 4
 	defaultUserAgent       = "upgent/0.1"
 )
 
 // Config represents runtime configuration for the agent.
 type Config struct {
 	NodeID          string
-	ScrapeURL       string
+	ScrapeURLs      []string
+	ScrapeWorkers   int
 	ServerBaseURL   string
 	Interval        time.Duration
 	Timeout         time.Duration
@@ -30,6 +33,9 @@ type Config struct {
 	SkipTLSVerify   bool
 	UserAgent       string
 	IngestURL       string
+	// MetricsListen is the address of the agent's own /metrics listener;
+	// empty disables it.
+	MetricsListen string
 }
 
 // LoadFromEnv builds a Config from environment variables.
@@ -47,12 +53,16 @@ func LoadFromEnv() (*Config, error) {
 		return nil, fmt.Errorf("invalid UPGENT_SERVER_URL: %w", err)
 	}
 
-	scrapeURL := strings.TrimSpace(os.Getenv("UPGENT_SCRAPE_URL"))
-	if scrapeURL == "" {
-		scrapeURL = defaultScrapeURL
+	scrapeURLs, err := parseURLListEnv("UPGENT_SCRAPE_URL", defaultScrapeURL)
+	if err != nil {
+		return nil, err
+	}
+	scrapeWorkers, err := parseSizeEnv("UPGENT_SCRAPE_CONCURRENCY", defaultScrapeWorkers)
+	if err != nil {
+		return nil, err
 	}
-	if _, err := url.ParseRequestURI(scrapeURL); err != nil {
-		return nil, fmt.Errorf("invalid UPGENT_SCRAPE_URL: %w", err)
+	if scrapeWorkers <= 0 {
+		return nil, errors.New("UPGENT_SCRAPE_CONCURRENCY must be positive")
 	}
 
 	interval, err := parseDurationEnv("UPGENT_INTERVAL", defaultInterval)
@@ -94,9 +104,17 @@ func LoadFromEnv() (*Config, error) {
 
 	ingestURL := buildIngestURL(serverBase, nodeID)
 
+	metricsListen := strings.TrimSpace(os.Getenv("UPGENT_METRICS_LISTEN"))
+	if metricsListen != "" {
+		if _, _, err := net.SplitHostPort(metricsListen); err != nil {
+			return nil, fmt.Errorf("invalid UPGENT_METRICS_LISTEN: %w", err)
+		}
+	}
+
 	cfg := &Config{
 		NodeID:          nodeID,
-		ScrapeURL:       scrapeURL,
+		ScrapeURLs:      scrapeURLs,
+		ScrapeWorkers:   int(scrapeWorkers),
 		ServerBaseURL:   serverBase,
 		Interval:        interval,
 		Timeout:         timeout,
@@ -105,10 +123,34 @@ func LoadFromEnv() (*Config, error) {
 		SkipTLSVerify:   skipTLS,
 		UserAgent:       userAgent,
 		IngestURL:       ingestURL,
+		MetricsListen:   metricsListen,
 	}
 	return cfg, nil
 }
 
+// parseURLListEnv reads a comma-separated list of URLs.
+func parseURLListEnv(name, def string) ([]string, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		value = def
+	}
+	var urls []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, err := url.ParseRequestURI(item); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		urls = append(urls, item)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("%s must list at least one URL", name)
+	}
+	return urls, nil
+}
+
 func parseDurationEnv(name string, def time.Duration) (time.Duration, error) {
 	value := strings.TrimSpace(os.Getenv(name))
 	if value == "" {
@@ -152,4 +194,3 @@ func buildIngestURL(base, nodeID string) string {
 	base = strings.TrimRight(base, "/")
 	return fmt.Sprintf("%s/api/ingest/%s", base, url.PathEscape(nodeID))
 }
-
@@ -3,18 +3,25 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
+	"github.com/osbits/upupup/worker/internal/admin"
 	"github.com/osbits/upupup/worker/internal/config"
 	"github.com/osbits/upupup/worker/internal/notifier"
 	"github.com/osbits/upupup/worker/internal/observability"
 	"github.com/osbits/upupup/worker/internal/render"
 	"github.com/osbits/upupup/worker/internal/runner"
+	"github.com/osbits/upupup/worker/internal/sink"
 	"github.com/osbits/upupup/worker/internal/storage"
 )
 
@@ -25,8 +32,56 @@ func main() {
 		defaultConfig = "config.yml"
 	}
 	flag.StringVar(&configPath, "config", defaultConfig, "path to configuration file")
+	var envName string
+	flag.StringVar(&envName, "env", os.Getenv("MONITOR_ENV"), "environment overlay to merge (reads overrides.<env>.yml next to the config)")
+	var printConfig bool
+	flag.BoolVar(&printConfig, "print-config", false, "print the effective configuration as YAML and exit (secrets redacted)")
+	var validate bool
+	flag.BoolVar(&validate, "validate", false, "check the configuration, notifiers and secrets, report problems and exit")
+	var once bool
+	flag.BoolVar(&once, "once", false, "run every check once, print the results and exit non-zero if any failed")
+	var output string
+	flag.StringVar(&output, "output", outputText, "output format of -validate, -once and -print-config: text or json")
+	var replayCheckID string
+	flag.StringVar(&replayCheckID, "replay", "", "replay a metrics check against the stored snapshot history, print its pass/fail timeline and exit")
+	var replaySince time.Duration
+	flag.DurationVar(&replaySince, "since", 24*time.Hour, "how far back -replay reaches")
+	var selector runner.CheckSelector
+	flag.Var(checkFlag{&selector}, "check", "only run the check with this id (repeatable)")
+	flag.Var(tagFlag{&selector}, "tag", "only run checks with this key=value label (repeatable, all must match)")
 	flag.Parse()
 
+	if err := validOutput(output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	if validate {
+		os.Exit(validateConfig(os.Stdout, configPath, envName, output))
+	}
+	if once {
+		ctx, cancel := signalContext()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+		code := runChecksOnce(ctx, os.Stdout, logger, configPath, envName, output, selector)
+		cancel()
+		os.Exit(code)
+	}
+
+	if replayCheckID != "" {
+		if err := replayCheck(configPath, envName, replayCheckID, replaySince); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if printConfig {
+		if err := printEffectiveConfig(os.Stdout, configPath, envName, output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
 	observability.LoadDotEnv(logger)
@@ -39,12 +94,25 @@ func main() {
 	}()
 	defer observability.CapturePanic(logger, rollbarEnabled)()
 
-	cfg, err := config.Load(configPath)
+	cfg, err := config.LoadForEnv(configPath, envName)
 	if err != nil {
 		logger.Error("failed to load config", "error", err)
 		os.Exit(1)
 	}
 
+	if cfg.Service.Environment == "" {
+		cfg.Service.Environment = envName
+	}
+	logSkippedCheckFiles(logger, cfg)
+	cfg.Checks, err = runner.SelectChecks(cfg.Checks, selector)
+	if err != nil {
+		logger.Error("failed to select checks", "error", err)
+		os.Exit(1)
+	}
+	if !selector.Empty() {
+		logger.Info("running selected checks only", "selector", selector.String(), "checks", len(cfg.Checks))
+	}
+
 	secrets, err := cfg.ResolveSecrets()
 	if err != nil {
 		logger.Error("failed to resolve secrets", "error", err)
@@ -53,8 +121,9 @@ func main() {
 
 	engine := render.New()
 	notifierFactory := notifier.Factory{
-		Secrets: secrets,
-		Render:  engine,
+		Secrets:     secrets,
+		Render:      engine,
+		Environment: cfg.Service.Environment,
 	}
 
 	registry, err := notifier.Build(notifierFactory, cfg.Notifiers)
@@ -78,35 +147,278 @@ func main() {
 		os.Exit(1)
 	}
 
-	store, err := storage.Open(dbPath, storage.Options{
-		CheckStateRetention:   cfg.Storage.CheckStateRetention,
-		NotificationRetention: cfg.Storage.NotificationLogRetention,
-	})
+	openStore := func() (*storage.Store, error) {
+		return storage.Open(dbPath, storage.Options{
+			CheckStateRetention:   cfg.Storage.CheckStateRetention,
+			NotificationRetention: cfg.Storage.NotificationLogRetention,
+		})
+	}
+	store, err := openStore()
+	if err != nil {
+		if !cfg.Storage.Optional {
+			logger.Error("failed to open storage", "error", err)
+			os.Exit(1)
+		}
+		logger.Warn("storage unavailable, running degraded without persistence", "path", dbPath, "error", err)
+		store = nil
+	}
+
+	run, err := runner.New(cfg, secrets, registry, engine, logger, location, store)
 	if err != nil {
-		logger.Error("failed to open storage", "error", err)
+		logger.Error("failed to initialize runner", "error", err)
 		os.Exit(1)
 	}
 	defer func() {
-		if err := store.Close(); err != nil {
-			logger.Warn("failed to close storage", "error", err)
+		if store := run.Store(); store != nil {
+			if err := store.Close(); err != nil {
+				logger.Warn("failed to close storage", "error", err)
+			}
 		}
 	}()
 
-	run, err := runner.New(cfg, secrets, registry, engine, logger, location, store)
+	sinks, err := sink.Build(sink.Factory{Secrets: secrets, Render: engine, Logger: logger}, cfg.Sinks)
 	if err != nil {
-		logger.Error("failed to initialize runner", "error", err)
+		logger.Error("failed to build sinks", "error", err)
 		os.Exit(1)
 	}
+	if cfg.Service.Audit.WebhookURL != "" {
+		audit, err := sink.NewAuditSink(cfg.Service.Audit, secrets, engine, logger)
+		if err != nil {
+			logger.Error("failed to build audit stream", "error", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, audit)
+	}
+	for _, s := range sinks {
+		run.AddSink(s)
+	}
+	defer func() {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelFlush()
+		for _, s := range sinks {
+			if err := s.Close(flushCtx); err != nil {
+				logger.Warn("failed to flush sink", "sink_id", s.ID(), "error", err)
+			}
+		}
+	}()
+
+	var adminServer *admin.Server
+	if cfg.Admin.Listen != "" {
+		token := ""
+		if ref := cfg.Admin.TokenRef; ref != "" {
+			token = secrets[ref]
+			if token == "" {
+				logger.Error("admin token secret is empty", "token_ref", ref)
+				os.Exit(1)
+			}
+		}
+		adminServer, err = admin.New(cfg.Admin, token, run, logger)
+		if err != nil {
+			logger.Error("failed to configure admin listener", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	ctx, cancel := signalContext()
 	defer cancel()
 
+	if store == nil {
+		go run.RecoverStorage(ctx, openStore, cfg.Storage.RetryInterval.Duration)
+	}
+
+	if adminServer != nil {
+		go func() {
+			if err := adminServer.Run(ctx); err != nil {
+				logger.Error("admin listener stopped", "error", err)
+			}
+		}()
+	}
+
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reloadSignals:
+				logger.Info("reload signal received")
+				reloadSecrets(logger, run, cfg, notifierFactory)
+				reloadChecks(logger, run, configPath, envName, selector)
+			}
+		}
+	}()
+
+	if cfg.Service.ChecksDir != "" {
+		dir := config.ChecksDirPath(configPath, cfg.Service.ChecksDir)
+		go func() {
+			err := config.WatchChecksDir(ctx, dir, func() {
+				reloadChecks(logger, run, configPath, envName, selector)
+			})
+			if err != nil {
+				logger.Error("checks_dir watch stopped", "dir", dir, "error", err)
+			}
+		}()
+	}
+
 	if err := run.Start(ctx); err != nil && err != context.Canceled {
 		logger.Error("runner stopped", "error", err)
 		os.Exit(1)
 	}
 }
 
+// reloadChecks re-reads the config and checks_dir and applies the resulting
+// checks that selector selects. A config that no longer loads keeps the
+// running checks.
+func reloadChecks(logger *slog.Logger, run *runner.Runner, configPath, envName string, selector runner.CheckSelector) {
+	cfg, err := config.LoadForEnv(configPath, envName)
+	if err != nil {
+		logger.Error("failed to reload checks", "error", err)
+		return
+	}
+	logSkippedCheckFiles(logger, cfg)
+	checks, err := runner.SelectChecks(cfg.Checks, selector)
+	if err != nil {
+		logger.Error("failed to reload checks", "error", err)
+		return
+	}
+	if err := run.ReloadChecks(checks, cfg.CheckAssertionSets); err != nil {
+		logger.Error("failed to reload checks", "error", err)
+		return
+	}
+	logger.Info("checks reloaded", "checks", len(checks))
+}
+
+// reloadSecrets re-reads .env, resolves the secrets again and rebuilds the
+// notifiers with them so rotated credentials apply without a restart. On
+// error the previous secrets stay in use.
+func reloadSecrets(logger *slog.Logger, run *runner.Runner, cfg *config.Config, factory notifier.Factory) {
+	observability.LoadDotEnv(logger)
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		logger.Error("failed to reload secrets", "error", err)
+		return
+	}
+	factory.Secrets = secrets
+	registry, err := notifier.Build(factory, cfg.Notifiers)
+	if err != nil {
+		logger.Error("failed to rebuild notifiers", "error", err)
+		return
+	}
+	if err := run.ReloadSecrets(secrets, registry); err != nil {
+		logger.Error("failed to reload secrets", "error", err)
+		return
+	}
+	logger.Info("secrets reloaded", "secrets", len(secrets))
+}
+
+func logSkippedCheckFiles(logger *slog.Logger, cfg *config.Config) {
+	for _, skipped := range cfg.SkippedCheckFiles {
+		logger.Warn("skipping malformed check file", "path", skipped.Path, "error", skipped.Err)
+	}
+}
+
+// printEffectiveConfig writes the effective configuration as YAML, or with
+// format json as the same document in JSON.
+func printEffectiveConfig(w io.Writer, configPath, envName, format string) error {
+	cfg, err := config.LoadForEnv(configPath, envName)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	resolved, err := runner.EffectiveConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("resolve config: %w", err)
+	}
+	var node yaml.Node
+	if err := node.Encode(resolved); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	pruneEmpty(&node)
+	if format == outputJSON {
+		var doc any
+		if err := node.Decode(&doc); err != nil {
+			return fmt.Errorf("encode config: %w", err)
+		}
+		writeJSON(w, doc)
+		return nil
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// replayCheck evaluates a metrics check against the snapshots stored for its
+// node over the last since and prints one line per snapshot plus the number
+// of times the check would have fired. Nothing is notified or persisted.
+func replayCheck(configPath, envName, checkID string, since time.Duration) error {
+	cfg, err := config.LoadForEnv(configPath, envName)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	dbPath := cfg.Storage.Path
+	if envPath := os.Getenv("MONITOR_DB_PATH"); envPath != "" {
+		dbPath = envPath
+	}
+	if dbPath == "" {
+		return fmt.Errorf("storage path is not configured")
+	}
+	store, err := storage.Open(dbPath, storage.Options{})
+	if err != nil {
+		return fmt.Errorf("open storage: %w", err)
+	}
+	defer store.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	run, err := runner.New(cfg, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, store)
+	if err != nil {
+		return fmt.Errorf("initialize runner: %w", err)
+	}
+	report, err := run.Replay(context.Background(), checkID, time.Now().Add(-since))
+	if err != nil {
+		return fmt.Errorf("replay %s: %w", checkID, err)
+	}
+	for _, entry := range report.Runs {
+		status := "pass"
+		switch {
+		case entry.Fired:
+			status = "FIRE"
+		case entry.Failing:
+			status = "failing"
+		case !entry.Result.Success:
+			status = "fail"
+		}
+		fmt.Printf("%s  %-7s  %s\n", entry.At.Format(time.RFC3339), status, entry.Summary)
+	}
+	fmt.Printf("%s (node %s): %d snapshots, fired %d times\n", report.CheckID, report.NodeID, len(report.Runs), report.Fires)
+	return nil
+}
+
+// pruneEmpty drops mapping entries that are null, empty strings or empty
+// collections so the dump only shows options that carry a value.
+func pruneEmpty(node *yaml.Node) {
+	for _, child := range node.Content {
+		pruneEmpty(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	kept := node.Content[:0]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := node.Content[i+1]
+		switch {
+		case value.Kind == yaml.ScalarNode && (value.Tag == "!!null" || (value.Tag == "!!str" && value.Value == "")):
+			continue
+		case (value.Kind == yaml.MappingNode || value.Kind == yaml.SequenceNode) && len(value.Content) == 0:
+			continue
+		}
+		kept = append(kept, node.Content[i], value)
+	}
+	node.Content = kept
+}
+
 func signalContext() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	signals := make(chan os.Signal, 1)
@@ -121,3 +433,46 @@ func signalContext() (context.Context, context.CancelFunc) {
 	}()
 	return ctx, cancel
 }
+
+// checkFlag adds the ids of repeated -check flags to a selector.
+type checkFlag struct {
+	selector *runner.CheckSelector
+}
+
+func (f checkFlag) String() string {
+	if f.selector == nil {
+		return ""
+	}
+	return strings.Join(f.selector.IDs, ",")
+}
+
+func (f checkFlag) Set(value string) error {
+	id := strings.TrimSpace(value)
+	if id == "" {
+		return fmt.Errorf("empty check id")
+	}
+	f.selector.IDs = append(f.selector.IDs, id)
+	return nil
+}
+
+// tagFlag adds the key=value labels of repeated -tag flags to a selector.
+type tagFlag struct {
+	selector *runner.CheckSelector
+}
+
+func (f tagFlag) String() string {
+	return ""
+}
+
+func (f tagFlag) Set(value string) error {
+	key, label, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("invalid tag %q, want key=value", value)
+	}
+	if f.selector.Labels == nil {
+		f.selector.Labels = map[string]string{}
+	}
+	f.selector.Labels[key] = strings.TrimSpace(label)
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/notifier"
+	"github.com/osbits/upupup/worker/internal/render"
+	"github.com/osbits/upupup/worker/internal/runner"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+// Output formats of -validate, -once and -print-config.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// Exit codes of -validate and -once.
+const (
+	exitOK     = 0
+	exitFailed = 1 // the config is invalid or a check failed
+	exitError  = 2 // -once could not run the checks
+)
+
+// validateResult is the -validate -output json document.
+type validateResult struct {
+	Valid     bool     `json:"valid"`
+	Checks    int      `json:"checks"`
+	Notifiers int      `json:"notifiers"`
+	Errors    []string `json:"errors"`
+	Warnings  []string `json:"warnings"`
+}
+
+// onceResult is the -once -output json document.
+type onceResult struct {
+	Success bool        `json:"success"`
+	Passed  int         `json:"passed"`
+	Failed  int         `json:"failed"`
+	Checks  []onceCheck `json:"checks"`
+}
+
+// onceCheck is one check of a -once run. Field names follow the sink
+// check_run record.
+type onceCheck struct {
+	CheckID    string    `json:"check_id"`
+	CheckName  string    `json:"check_name"`
+	Type       string    `json:"type"`
+	Success    bool      `json:"success"`
+	Summary    string    `json:"summary,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Severity   string    `json:"severity,omitempty"`
+	LatencyMS  int64     `json:"latency_ms"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func validOutput(format string) error {
+	if format != outputText && format != outputJSON {
+		return fmt.Errorf("unknown -output %q, want text or json", format)
+	}
+	return nil
+}
+
+// validateConfig loads the config and builds everything the worker builds at
+// startup, without opening storage or running checks, and reports every
+// problem it finds.
+func validateConfig(w io.Writer, configPath, envName, format string) int {
+	out := validateResult{Errors: []string{}, Warnings: []string{}}
+	cfg, err := config.LoadForEnv(configPath, envName)
+	if err != nil {
+		out.Errors = append(out.Errors, err.Error())
+		return writeValidate(w, format, out)
+	}
+	out.Checks = len(cfg.Checks)
+	out.Notifiers = len(cfg.Notifiers)
+	for _, skipped := range cfg.SkippedCheckFiles {
+		out.Warnings = append(out.Warnings, fmt.Sprintf("skipped check file %s: %v", skipped.Path, skipped.Err))
+	}
+	location, err := time.LoadLocation(cfg.Service.Timezone)
+	if err != nil {
+		out.Errors = append(out.Errors, fmt.Sprintf("timezone: %v", err))
+		location = time.UTC
+	}
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		out.Errors = append(out.Errors, fmt.Sprintf("secrets: %v", err))
+		return writeValidate(w, format, out)
+	}
+	engine := render.New()
+	registry, err := notifier.Build(notifier.Factory{Secrets: secrets, Render: engine, Environment: cfg.Service.Environment}, cfg.Notifiers)
+	if err != nil {
+		out.Errors = append(out.Errors, fmt.Sprintf("notifiers: %v", err))
+		return writeValidate(w, format, out)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := runner.New(cfg, secrets, registry, engine, logger, location, nil); err != nil {
+		out.Errors = append(out.Errors, err.Error())
+	}
+	return writeValidate(w, format, out)
+}
+
+func writeValidate(w io.Writer, format string, out validateResult) int {
+	out.Valid = len(out.Errors) == 0
+	if format == outputJSON {
+		writeJSON(w, out)
+	} else {
+		for _, warning := range out.Warnings {
+			fmt.Fprintf(w, "warning: %s\n", warning)
+		}
+		for _, msg := range out.Errors {
+			fmt.Fprintf(w, "error: %s\n", msg)
+		}
+		if out.Valid {
+			fmt.Fprintf(w, "config ok: %d checks, %d notifiers\n", out.Checks, out.Notifiers)
+		}
+	}
+	if !out.Valid {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// runChecksOnce runs every selected check once, prints the results and returns
+// exitFailed when any check failed. Storage is opened when configured so
+// metrics and history checks can read it, but nothing is written or
+// notified.
+func runChecksOnce(ctx context.Context, w io.Writer, logger *slog.Logger, configPath, envName, format string, selector runner.CheckSelector) int {
+	cfg, err := config.LoadForEnv(configPath, envName)
+	if err != nil {
+		logger.Error("failed to load config", "error", err)
+		return exitError
+	}
+	cfg.Checks, err = runner.SelectChecks(cfg.Checks, selector)
+	if err != nil {
+		logger.Error("failed to select checks", "error", err)
+		return exitError
+	}
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		logger.Error("failed to resolve secrets", "error", err)
+		return exitError
+	}
+	location, err := time.LoadLocation(cfg.Service.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	var store *storage.Store
+	dbPath := cfg.Storage.Path
+	if envPath := os.Getenv("MONITOR_DB_PATH"); envPath != "" {
+		dbPath = envPath
+	}
+	if dbPath != "" {
+		store, err = storage.Open(dbPath, storage.Options{})
+		if err != nil {
+			logger.Warn("storage unavailable, metrics and history checks will fail", "path", dbPath, "error", err)
+			store = nil
+		} else {
+			defer store.Close()
+		}
+	}
+	run, err := runner.New(cfg, secrets, notifier.NewRegistry(), render.New(), logger, location, store)
+	if err != nil {
+		logger.Error("failed to initialize runner", "error", err)
+		return exitError
+	}
+
+	out := onceResult{Checks: []onceCheck{}}
+	for _, entry := range run.RunOnce(ctx) {
+		result := entry.Result
+		check := onceCheck{
+			CheckID:    entry.Check.ID,
+			CheckName:  entry.Check.Name,
+			Type:       entry.Check.Type,
+			Success:    result.Success,
+			Summary:    entry.Summary,
+			Reason:     result.Reason,
+			Severity:   result.Severity,
+			LatencyMS:  result.Latency.Milliseconds(),
+			OccurredAt: result.StartedAt,
+		}
+		if result.Error != nil {
+			check.Error = result.Error.Error()
+		}
+		if result.Success {
+			out.Passed++
+		} else {
+			out.Failed++
+		}
+		out.Checks = append(out.Checks, check)
+	}
+	out.Success = out.Failed == 0
+
+	if format == outputJSON {
+		writeJSON(w, out)
+	} else {
+		for _, check := range out.Checks {
+			status := "ok"
+			if !check.Success {
+				status = "FAIL"
+			}
+			fmt.Fprintf(w, "%-4s  %s  %dms  %s\n", status, check.CheckID, check.LatencyMS, check.Summary)
+		}
+		fmt.Fprintf(w, "%d passed, %d failed\n", out.Passed, out.Failed)
+	}
+	if !out.Success {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func writeJSON(w io.Writer, v any) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
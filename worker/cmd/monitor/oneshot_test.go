@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/runner"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestOnceJSONReportsEveryCheck(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer open.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+
+	path := writeConfig(t, `
+service:
+  defaults:
+    interval: 1m
+    timeout: 1s
+checks:
+  - id: up
+    type: tcp
+    target: "`+open.Addr().String()+`"
+  - id: down
+    name: Down
+    type: tcp
+    target: "`+closedAddr+`"
+`)
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	code := runChecksOnce(context.Background(), &out, logger, path, "", outputJSON, runner.CheckSelector{})
+	if code != exitFailed {
+		t.Fatalf("expected exit code %d for a failed check, got %d", exitFailed, code)
+	}
+	var result onceResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("decode output: %v\n%s", err, out.String())
+	}
+	if result.Success || result.Passed != 1 || result.Failed != 1 || len(result.Checks) != 2 {
+		t.Fatalf("unexpected totals %+v", result)
+	}
+	up, down := result.Checks[0], result.Checks[1]
+	if up.CheckID != "up" || !up.Success || up.Type != "tcp" {
+		t.Fatalf("unexpected up result %+v", up)
+	}
+	if down.CheckID != "down" || down.Success || down.Reason != "connection_refused" || down.Error == "" || down.Summary == "" {
+		t.Fatalf("unexpected down result %+v", down)
+	}
+
+	path = writeConfig(t, `
+checks:
+  - id: up
+    type: tcp
+    target: "`+open.Addr().String()+`"
+`)
+	out.Reset()
+	if code := runChecksOnce(context.Background(), &out, logger, path, "", outputJSON, runner.CheckSelector{}); code != exitOK {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+}
+
+func TestOnceRunsSelectedChecks(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer open.Close()
+
+	path := writeConfig(t, `
+checks:
+  - id: up
+    type: tcp
+    target: "`+open.Addr().String()+`"
+    labels: {team: core}
+  - id: down
+    type: tcp
+    target: "127.0.0.1:1"
+    labels: {team: edge}
+`)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, selector := range []runner.CheckSelector{
+		{IDs: []string{"up"}},
+		{Labels: map[string]string{"team": "core"}},
+	} {
+		var out bytes.Buffer
+		if code := runChecksOnce(context.Background(), &out, logger, path, "", outputJSON, selector); code != exitOK {
+			t.Fatalf("%s: expected exit code 0, got %d: %s", selector, code, out.String())
+		}
+		var result onceResult
+		if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+			t.Fatalf("decode output: %v\n%s", err, out.String())
+		}
+		if len(result.Checks) != 1 || result.Checks[0].CheckID != "up" {
+			t.Fatalf("%s: expected only the selected check, got %+v", selector, result.Checks)
+		}
+	}
+
+	var out bytes.Buffer
+	if code := runChecksOnce(context.Background(), &out, logger, path, "", outputJSON, runner.CheckSelector{IDs: []string{"missing"}}); code != exitError {
+		t.Fatalf("expected unknown check to exit %d, got %d", exitError, code)
+	}
+}
+
+func TestOnceLeavesStorageUnchanged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	t.Setenv("MONITOR_DB_PATH", dbPath)
+
+	path := writeConfig(t, `
+checks:
+  - id: api
+    type: http
+    target: "`+srv.URL+`"
+    assertions:
+      - { kind: baseline_deviation, value: "50%" }
+  - id: node
+    type: metrics
+    metrics:
+      node_id: node-a
+      first_run_grace: 10m
+      thresholds:
+        - { name: node_load1, op: less_than, value: 1.5 }
+`)
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if code := runChecksOnce(context.Background(), &out, logger, path, "", outputJSON, runner.CheckSelector{}); code != exitOK {
+		t.Fatalf("expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	store, err := storage.Open(dbPath, storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	defer store.Close()
+	baselines, err := store.CheckBaselines(context.Background(), "api")
+	if err != nil {
+		t.Fatalf("load baselines: %v", err)
+	}
+	if len(baselines) != 0 {
+		t.Fatalf("expected -once not to store a baseline, got %+v", baselines)
+	}
+	if _, seen, err := store.LookupCheckFirstSeen(context.Background(), "node", "node-a"); err != nil || seen {
+		t.Fatalf("expected -once not to record first seen, got seen=%v err=%v", seen, err)
+	}
+}
+
+func TestValidateJSONListsErrors(t *testing.T) {
+	path := writeConfig(t, `
+notifiers:
+  - id: pager
+    type: webhook
+    min_severity: loud
+    config:
+      url: https://hooks.example.com
+`)
+	var out bytes.Buffer
+	if code := validateConfig(&out, path, "", outputJSON); code != exitFailed {
+		t.Fatalf("expected exit code %d, got %d", exitFailed, code)
+	}
+	var result validateResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("decode output: %v\n%s", err, out.String())
+	}
+	if result.Valid || len(result.Errors) != 1 || result.Notifiers != 1 {
+		t.Fatalf("expected one error, got %+v", result)
+	}
+}
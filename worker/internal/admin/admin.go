@@ -0,0 +1,182 @@
+// Package admin serves the worker's self-metrics and health endpoints on a
+// dedicated listener guarded by an IP allowlist, an optional bearer token and
+// optional TLS.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/shared/access"
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/runner"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// StatusSource provides the check statuses exported on /metrics.
+type StatusSource interface {
+	CheckStatuses() []runner.CheckStatus
+}
+
+// Server is the worker's admin listener.
+type Server struct {
+	addr      string
+	allowlist *access.Allowlist
+	token     string
+	tlsConfig *tls.Config
+	source    StatusSource
+	logger    *slog.Logger
+	startedAt time.Time
+}
+
+// New validates the admin configuration. token is the resolved value of
+// cfg.TokenRef; requests must present it as a bearer token when set.
+func New(cfg config.AdminConfig, token string, source StatusSource, logger *slog.Logger) (*Server, error) {
+	if strings.TrimSpace(cfg.Listen) == "" {
+		return nil, errors.New("admin listen address is required")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	allowlist, err := access.NewAllowlist(cfg.AllowedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("admin allowlist: %w", err)
+	}
+	addr, err := listenAddr(cfg.Listen, len(cfg.AllowedIPs) > 0)
+	if err != nil {
+		return nil, err
+	}
+	if addr != cfg.Listen {
+		logger.Warn("admin listener bound to localhost because admin.allowed_ips is empty", "listen", cfg.Listen, "addr", addr)
+	}
+	var tlsConfig *tls.Config
+	if cfg.TLS != nil {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return nil, errors.New("admin tls requires cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load admin tls certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	return &Server{
+		addr:      addr,
+		allowlist: allowlist,
+		token:     token,
+		tlsConfig: tlsConfig,
+		source:    source,
+		logger:    logger,
+		startedAt: time.Now(),
+	}, nil
+}
+
+// listenAddr keeps the listener on localhost unless an allowlist restricts
+// who may connect.
+func listenAddr(listen string, hasAllowlist bool) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid admin listen address %q: %w", listen, err)
+	}
+	if hasAllowlist || isLoopback(host) {
+		return listen, nil
+	}
+	return net.JoinHostPort("127.0.0.1", port), nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Addr returns the address the listener binds to.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Handler returns the admin routes wrapped in the access checks.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return s.guard(mux)
+}
+
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := access.RemoteIP(r)
+		if !s.allowlist.Allowed(ip) {
+			s.logger.Warn("admin request denied", "remote_ip", ip.String(), "path", r.URL.Path)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if s.token != "" && !validToken(r.Header.Get("Authorization"), s.token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="upupup-worker"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validToken(header, token string) bool {
+	presented, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(presented)), []byte(token)) == 1
+}
+
+// Run listens on the configured address until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("admin listen: %w", err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves the admin routes on ln, wrapping it in TLS when configured,
+// until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	if s.tlsConfig != nil {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.logger.Info("admin listener started", "addr", ln.Addr().String(), "tls", s.tlsConfig != nil)
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
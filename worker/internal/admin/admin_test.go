@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/runner"
+)
+
+type staticStatuses []runner.CheckStatus
+
+func (s staticStatuses) CheckStatuses() []runner.CheckStatus { return s }
+
+func newTestServer(t *testing.T, cfg config.AdminConfig, token string) *Server {
+	t.Helper()
+	statuses := staticStatuses{{CheckID: "api", CheckName: "API", Success: false, Failing: true, Reason: "status_mismatch", Latency: 120 * time.Millisecond, LastRun: time.Unix(1700000000, 0)}}
+	srv, err := New(cfg, token, statuses, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new admin server: %v", err)
+	}
+	return srv
+}
+
+func serve(srv *Server, remoteAddr, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", "10.0.0.5")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminAllowlist(t *testing.T) {
+	srv := newTestServer(t, config.AdminConfig{Listen: ":9464", AllowedIPs: []string{"10.0.0.0/8"}}, "")
+
+	rec := serve(srv, "10.1.2.3:5000", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected allowed IP to get 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`upupup_worker_check_success{check_id="api",check_name="API"} 0`,
+		`upupup_worker_check_failing{check_id="api",check_name="API"} 1`,
+		`upupup_worker_check_failure_reason{check_id="api",check_name="API",reason="status_mismatch"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics, got:\n%s", want, body)
+		}
+	}
+
+	// X-Forwarded-For must not grant access to a denied peer.
+	if rec := serve(srv, "192.168.1.10:5000", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected denied IP to get 403, got %d", rec.Code)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	srv := newTestServer(t, config.AdminConfig{Listen: "127.0.0.1:9464"}, "s3cret")
+
+	if rec := serve(srv, "127.0.0.1:5000", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected missing token to get 401, got %d", rec.Code)
+	}
+	if rec := serve(srv, "127.0.0.1:5000", "Bearer wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong token to get 401, got %d", rec.Code)
+	}
+	if rec := serve(srv, "127.0.0.1:5000", "Bearer s3cret"); rec.Code != http.StatusOK {
+		t.Fatalf("expected valid token to get 200, got %d", rec.Code)
+	}
+}
+
+func TestAdminListensOnLocalhostWithoutAllowlist(t *testing.T) {
+	cases := map[string]string{
+		":9464":          "127.0.0.1:9464",
+		"0.0.0.0:9464":   "127.0.0.1:9464",
+		"localhost:9464": "localhost:9464",
+		"[::1]:9464":     "[::1]:9464",
+	}
+	for listen, want := range cases {
+		srv := newTestServer(t, config.AdminConfig{Listen: listen}, "")
+		if srv.Addr() != want {
+			t.Errorf("listen %q: expected %q, got %q", listen, want, srv.Addr())
+		}
+	}
+	srv := newTestServer(t, config.AdminConfig{Listen: "0.0.0.0:9464", AllowedIPs: []string{"10.0.0.0/8"}}, "")
+	if srv.Addr() != "0.0.0.0:9464" {
+		t.Errorf("expected allowlisted listener to keep its address, got %q", srv.Addr())
+	}
+}
+
+func TestAdminServesTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	srv := newTestServer(t, config.AdminConfig{
+		Listen: "127.0.0.1:0",
+		TLS:    &config.AdminTLSConfig{CertFile: certFile, KeyFile: keyFile},
+	}, "")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatalf("get over tls: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Fatalf("expected 200 over TLS, got %d (tls %v)", resp.StatusCode, resp.TLS != nil)
+	}
+}
+
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "admin.crt")
+	keyFile := filepath.Join(dir, "admin.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const namespace = "upupup_worker"
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# HELP %s_uptime_seconds Seconds since the worker started.\n", namespace)
+	fmt.Fprintf(&builder, "# TYPE %s_uptime_seconds gauge\n", namespace)
+	fmt.Fprintf(&builder, "%s_uptime_seconds %.0f\n", namespace, time.Since(s.startedAt).Seconds())
+
+	statuses := s.source.CheckStatuses()
+	if len(statuses) > 0 {
+		fmt.Fprintf(&builder, "# HELP %s_check_success Whether the last run of the check succeeded.\n", namespace)
+		fmt.Fprintf(&builder, "# TYPE %s_check_success gauge\n", namespace)
+		for _, status := range statuses {
+			fmt.Fprintf(&builder, "%s_check_success{%s} %d\n", namespace, checkLabels(status.CheckID, status.CheckName), boolToInt(status.Success))
+		}
+		fmt.Fprintf(&builder, "# HELP %s_check_failing Whether the check is in a failing state after thresholds.\n", namespace)
+		fmt.Fprintf(&builder, "# TYPE %s_check_failing gauge\n", namespace)
+		for _, status := range statuses {
+			fmt.Fprintf(&builder, "%s_check_failing{%s} %d\n", namespace, checkLabels(status.CheckID, status.CheckName), boolToInt(status.Failing))
+		}
+		fmt.Fprintf(&builder, "# HELP %s_check_maintenance Whether the last run of the check returned its maintenance response.\n", namespace)
+		fmt.Fprintf(&builder, "# TYPE %s_check_maintenance gauge\n", namespace)
+		for _, status := range statuses {
+			fmt.Fprintf(&builder, "%s_check_maintenance{%s} %d\n", namespace, checkLabels(status.CheckID, status.CheckName), boolToInt(status.Maintenance))
+		}
+		fmt.Fprintf(&builder, "# HELP %s_check_latency_seconds Latency of the last run of the check.\n", namespace)
+		fmt.Fprintf(&builder, "# TYPE %s_check_latency_seconds gauge\n", namespace)
+		for _, status := range statuses {
+			fmt.Fprintf(&builder, "%s_check_latency_seconds{%s} %.6f\n", namespace, checkLabels(status.CheckID, status.CheckName), status.Latency.Seconds())
+		}
+		fmt.Fprintf(&builder, "# HELP %s_check_last_run_timestamp_seconds Completion time of the last run of the check.\n", namespace)
+		fmt.Fprintf(&builder, "# TYPE %s_check_last_run_timestamp_seconds gauge\n", namespace)
+		for _, status := range statuses {
+			fmt.Fprintf(&builder, "%s_check_last_run_timestamp_seconds{%s} %d\n", namespace, checkLabels(status.CheckID, status.CheckName), status.LastRun.Unix())
+		}
+		fmt.Fprintf(&builder, "# HELP %s_check_failure_reason Reason code of the last failed run of the check.\n", namespace)
+		fmt.Fprintf(&builder, "# TYPE %s_check_failure_reason gauge\n", namespace)
+		for _, status := range statuses {
+			if status.Success || status.Reason == "" {
+				continue
+			}
+			fmt.Fprintf(&builder, "%s_check_failure_reason{%s,reason=\"%s\"} 1\n", namespace, checkLabels(status.CheckID, status.CheckName), labelValue(status.Reason))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(builder.String()))
+}
+
+func checkLabels(checkID, checkName string) string {
+	return fmt.Sprintf("check_id=\"%s\",check_name=\"%s\"", labelValue(checkID), labelValue(checkName))
+}
+
+func labelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
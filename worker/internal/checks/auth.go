@@ -0,0 +1,46 @@
+package checks
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// applyAuth sets the Authorization header of req from the request's auth
+// block. It runs before the configured headers are set, so an explicit
+// Authorization header replaces it.
+func applyAuth(req *http.Request, auth *config.HTTPAuth, secrets map[string]string) error {
+	if auth == nil {
+		return nil
+	}
+	switch strings.ToLower(strings.TrimSpace(auth.Type)) {
+	case "basic":
+		password, err := authSecret(secrets, auth.PasswordRef, "password_ref")
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(auth.Username, password)
+	case "bearer":
+		token, err := authSecret(secrets, auth.TokenRef, "token_ref")
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		return fmt.Errorf("unsupported auth type %q, want basic or bearer", auth.Type)
+	}
+	return nil
+}
+
+func authSecret(secrets map[string]string, ref, field string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("auth %s is required", field)
+	}
+	value, ok := secrets[ref]
+	if !ok {
+		return "", fmt.Errorf("missing secret %q", ref)
+	}
+	return value, nil
+}
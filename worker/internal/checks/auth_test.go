@@ -0,0 +1,60 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestRunHTTPAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Basic cHJvYmU6czNjcjN0", "Bearer tok-123", "Token explicit":
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	env := Environment{
+		TemplateEngine: render.New(),
+		Secrets:        map[string]string{"PROBE_PASSWORD": "s3cr3t", "API_TOKEN": "tok-123"},
+	}
+	check := func(request *config.HTTPRequest) config.CheckConfig {
+		return config.CheckConfig{
+			ID:         "auth",
+			Type:       "http",
+			Target:     srv.URL,
+			Request:    request,
+			Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		}
+	}
+
+	basic := &config.HTTPAuth{Type: "basic", Username: "probe", PasswordRef: "PROBE_PASSWORD"}
+	if result := Execute(context.Background(), check(&config.HTTPRequest{Auth: basic}), env); !result.Success {
+		t.Fatalf("expected basic auth to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	bearer := &config.HTTPAuth{Type: "bearer", TokenRef: "API_TOKEN"}
+	if result := Execute(context.Background(), check(&config.HTTPRequest{Auth: bearer}), env); !result.Success {
+		t.Fatalf("expected bearer auth to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	explicit := &config.HTTPRequest{
+		Auth:    &config.HTTPAuth{Type: "bearer", TokenRef: "API_TOKEN"},
+		Headers: map[string]string{"authorization": "Token explicit"},
+	}
+	if result := Execute(context.Background(), check(explicit), env); !result.Success {
+		t.Fatalf("expected explicit header to win, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	missing := &config.HTTPAuth{Type: "bearer", TokenRef: "MISSING_TOKEN"}
+	result := Execute(context.Background(), check(&config.HTTPRequest{Auth: missing}), env)
+	if result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected missing secret to be a config error, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
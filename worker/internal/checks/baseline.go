@@ -0,0 +1,138 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+// defaultBaselineMetric is compared when a baseline_deviation assertion does
+// not name a metric in its path.
+const defaultBaselineMetric = "latency_ms"
+
+// evaluateBaselines fills in the baseline_deviation results at indices by
+// comparing the current value of each metric to the check's stored baseline.
+// A metric without a positive baseline, or every metric when
+// env.ResetBaseline is set, takes its current value as the new baseline once
+// the run's other assertions pass and the value is positive.
+func evaluateBaselines(ctx context.Context, cfg config.CheckConfig, env Environment, res *Result, assertions []AssertionResult, indices []int, current map[string]float64) {
+	othersPassed := true
+	for i, result := range assertions {
+		if !slices.Contains(indices, i) && !result.Passed && !result.Warning {
+			othersPassed = false
+		}
+	}
+	var stored map[string]storage.CheckBaseline
+	loadErr := errors.New("baseline store not configured")
+	if env.Store != nil {
+		stored, loadErr = env.Store.CheckBaselines(ctx, cfg.ID)
+	}
+
+	details := map[string]any{}
+	for _, idx := range indices {
+		assertion := cfg.Assertions[idx]
+		result := &assertions[idx]
+		result.Passed = false
+		metric := strings.TrimSpace(assertion.Path)
+		if metric == "" {
+			metric = defaultBaselineMetric
+		}
+		value, ok := current[metric]
+		if !ok {
+			result.Message = fmt.Sprintf("unsupported baseline metric %q", metric)
+			continue
+		}
+		tolerance, err := parseTolerance(assertion.Value)
+		if err != nil {
+			result.Message = err.Error()
+			continue
+		}
+		if loadErr != nil {
+			result.Message = fmt.Sprintf("load baseline: %v", loadErr)
+			if res.Reason == "" {
+				res.Reason = ReasonStorageError
+			}
+			continue
+		}
+
+		// A baseline of zero or less, e.g. from an empty first response,
+		// gives no scale to deviate from, so it is re-seeded like a missing
+		// one.
+		baseline, ok := stored[metric]
+		if !ok || baseline.Value <= 0 || (env.ResetBaseline && othersPassed) {
+			if !othersPassed || env.ReadOnly || value <= 0 {
+				result.Passed = true
+				result.Message = "no baseline yet"
+				continue
+			}
+			baseline = storage.CheckBaseline{CheckID: cfg.ID, Metric: metric, Value: value, EstablishedAt: time.Now()}
+			if err := env.Store.SetCheckBaseline(ctx, baseline); err != nil {
+				result.Message = fmt.Sprintf("store baseline: %v", err)
+				if res.Reason == "" {
+					res.Reason = ReasonStorageError
+				}
+				continue
+			}
+			stored[metric] = baseline
+			res.BaselineEstablished = true
+			result.Passed = true
+			result.Message = fmt.Sprintf("baseline established at %g", value)
+			details[metric] = map[string]any{"baseline": value, "current": value, "deviation_percent": 0.0}
+			continue
+		}
+
+		detail := map[string]any{"baseline": baseline.Value, "current": value, "established_at": baseline.EstablishedAt}
+		details[metric] = detail
+		deviation := (value - baseline.Value) / baseline.Value * 100
+		detail["deviation_percent"] = deviation
+		result.Passed, err = withinTolerance(deviation, tolerance, assertion.Op)
+		if err != nil {
+			result.Message = err.Error()
+			continue
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%s %g deviates %+.1f%% from baseline %g (tolerance %g%%)", metric, value, deviation, baseline.Value, tolerance)
+		}
+	}
+	if len(details) > 0 {
+		if res.Metadata == nil {
+			res.Metadata = map[string]any{}
+		}
+		res.Metadata["baseline"] = details
+	}
+}
+
+// withinTolerance checks a signed deviation in percent. The default op only
+// fails increases (a regression for latency and size), "decrease" only
+// decreases and "either" both.
+func withinTolerance(deviation, tolerance float64, op string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(op)) {
+	case "", "increase":
+		return deviation <= tolerance, nil
+	case "decrease":
+		return -deviation <= tolerance, nil
+	case "either":
+		return math.Abs(deviation) <= tolerance, nil
+	default:
+		return false, fmt.Errorf("unsupported op %q", op)
+	}
+}
+
+// parseTolerance reads a percentage such as 25 or "25%".
+func parseTolerance(value any) (float64, error) {
+	if s, ok := value.(string); ok {
+		value = strings.TrimSuffix(strings.TrimSpace(s), "%")
+	}
+	tolerance, ok := toFloat(value)
+	if !ok || tolerance < 0 {
+		return 0, fmt.Errorf("baseline_deviation value must be a non-negative percentage, got %v", value)
+	}
+	return tolerance, nil
+}
@@ -0,0 +1,145 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func runBaselineCheck(t *testing.T, url string, env Environment) Result {
+	t.Helper()
+	cfg := config.CheckConfig{
+		ID:     "api",
+		Type:   "http",
+		Target: url,
+		Assertions: []config.Assertion{
+			{Kind: "status_code", Op: "equals", Value: 200},
+			{Kind: "baseline_deviation", Path: "response_size_bytes", Value: "20%"},
+		},
+	}
+	return Execute(context.Background(), cfg, env)
+}
+
+func TestBaselineDeviationDetectsRegression(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "monitor.db"), storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	body := strings.Repeat("x", 100)
+	url := startBodyServer(t, &body)
+	env := Environment{TemplateEngine: render.New(), HttpClient: http.DefaultClient, Store: store}
+
+	result := runBaselineCheck(t, url, env)
+	if !result.Success || !result.BaselineEstablished {
+		t.Fatalf("expected first run to establish the baseline, got success=%v %+v", result.Success, result.AssertionResults)
+	}
+	baselines, err := store.CheckBaselines(context.Background(), "api")
+	if err != nil {
+		t.Fatalf("load baselines: %v", err)
+	}
+	if got := baselines["response_size_bytes"].Value; got != 100 {
+		t.Fatalf("expected stored baseline 100, got %v", got)
+	}
+
+	body = strings.Repeat("x", 115)
+	result = runBaselineCheck(t, url, env)
+	if !result.Success || result.BaselineEstablished {
+		t.Fatalf("expected growth within tolerance to pass without re-baselining, got %+v", result.AssertionResults)
+	}
+
+	body = strings.Repeat("x", 150)
+	result = runBaselineCheck(t, url, env)
+	if result.Success {
+		t.Fatalf("expected growth beyond tolerance to fail")
+	}
+	if result.Reason != ReasonBaselineDeviation {
+		t.Fatalf("expected reason %q, got %q", ReasonBaselineDeviation, result.Reason)
+	}
+	detail := result.Metadata["baseline"].(map[string]any)["response_size_bytes"].(map[string]any)
+	if detail["deviation_percent"] != 50.0 {
+		t.Fatalf("unexpected baseline metadata: %v", detail)
+	}
+
+	env.ResetBaseline = true
+	result = runBaselineCheck(t, url, env)
+	if !result.Success || !result.BaselineEstablished {
+		t.Fatalf("expected reset to accept the current size as baseline, got %+v", result.AssertionResults)
+	}
+}
+
+func TestBaselineNotEstablishedFromFailingRun(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "monitor.db"), storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	body := "ok"
+	url := startBodyServer(t, &body)
+	cfg := config.CheckConfig{
+		ID:     "api",
+		Type:   "http",
+		Target: url,
+		Assertions: []config.Assertion{
+			{Kind: "status_code", Op: "equals", Value: 500},
+			{Kind: "baseline_deviation", Value: 50},
+		},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: http.DefaultClient, Store: store})
+	if result.Success || result.BaselineEstablished {
+		t.Fatalf("expected failing run not to establish a baseline")
+	}
+	if result.Reason != ReasonStatusMismatch {
+		t.Fatalf("expected status mismatch, got %q", result.Reason)
+	}
+	baselines, err := store.CheckBaselines(context.Background(), "api")
+	if err != nil || len(baselines) != 0 {
+		t.Fatalf("expected no stored baseline, got %v (%v)", baselines, err)
+	}
+}
+
+func TestBaselineReseedsZeroBaseline(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "monitor.db"), storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	body := ""
+	url := startBodyServer(t, &body)
+	env := Environment{TemplateEngine: render.New(), HttpClient: http.DefaultClient, Store: store}
+
+	result := runBaselineCheck(t, url, env)
+	if !result.Success || result.BaselineEstablished {
+		t.Fatalf("expected an empty response not to establish a baseline, got %+v", result.AssertionResults)
+	}
+	err = store.SetCheckBaseline(context.Background(), storage.CheckBaseline{CheckID: "api", Metric: "response_size_bytes", Value: 0, EstablishedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("store baseline: %v", err)
+	}
+
+	body = strings.Repeat("x", 100)
+	result = runBaselineCheck(t, url, env)
+	if !result.Success || !result.BaselineEstablished {
+		t.Fatalf("expected a zero baseline to be re-seeded, got %+v", result.AssertionResults)
+	}
+	baselines, err := store.CheckBaselines(context.Background(), "api")
+	if err != nil {
+		t.Fatalf("load baselines: %v", err)
+	}
+	if got := baselines["response_size_bytes"].Value; got != 100 {
+		t.Fatalf("expected stored baseline 100, got %v", got)
+	}
+}
@@ -0,0 +1,176 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/oliveagle/jsonpath"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// diffExcerptBytes is how much of each body is quoted around a mismatch.
+const diffExcerptBytes = 40
+
+// runDiff fetches the check target and the secondary URL with the same
+// request and asserts that the responses are equivalent: same status code,
+// and either identical bodies or equal values at every configured jsonpath.
+func runDiff(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:   cfg.ID,
+		CheckName: cfg.Name,
+		StartedAt: start,
+	}
+	if cfg.Diff == nil || strings.TrimSpace(cfg.Diff.Secondary) == "" {
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("diff.secondary is required for diff check")
+		res.Reason = ReasonConfigError
+		return res
+	}
+
+	secondaryCfg := cfg
+	secondaryCfg.Target = cfg.Diff.Secondary
+	if cfg.Request != nil {
+		request := *cfg.Request
+		request.URL = ""
+		secondaryCfg.Request = &request
+	}
+
+	type fetchOutcome struct {
+		res     Result
+		fetched *httpResponse
+	}
+	secondaryDone := make(chan fetchOutcome, 1)
+	go func() {
+		r, f := fetchHTTP(ctx, start, secondaryCfg, env)
+		secondaryDone <- fetchOutcome{res: r, fetched: f}
+	}()
+	primaryRes, primary := fetchHTTP(ctx, start, cfg, env)
+	secondaryOut := <-secondaryDone
+	secondaryRes, secondary := secondaryOut.res, secondaryOut.fetched
+
+	res.CompletedAt = time.Now()
+	res.Latency = max(primaryRes.Latency, secondaryRes.Latency)
+	if primary == nil {
+		res.Error = fmt.Errorf("primary: %w", primaryRes.Error)
+		res.Reason = primaryRes.Reason
+		return res
+	}
+	if secondary == nil {
+		res.Error = fmt.Errorf("secondary: %w", secondaryRes.Error)
+		res.Reason = secondaryRes.Reason
+		return res
+	}
+
+	res.Metadata = map[string]any{
+		"primary_status":       primary.resp.StatusCode,
+		"secondary_status":     secondary.resp.StatusCode,
+		"primary_latency_ms":   primaryRes.Latency.Seconds() * 1000,
+		"secondary_latency_ms": secondaryRes.Latency.Seconds() * 1000,
+	}
+
+	assertions := []AssertionResult{diffStatus(primary, secondary)}
+	if len(cfg.Diff.JSONPaths) == 0 {
+		result, location := diffBody(primary.body, secondary.body)
+		res.Metadata["primary_sha256"] = bodyHash(primary.body)
+		res.Metadata["secondary_sha256"] = bodyHash(secondary.body)
+		if location != "" {
+			res.Metadata["diff_location"] = location
+		}
+		assertions = append(assertions, result)
+	} else {
+		results, location := diffJSONPaths(cfg, primary.body, secondary.body)
+		if location != "" {
+			res.Metadata["diff_location"] = location
+		}
+		assertions = append(assertions, results...)
+	}
+	res.AssertionResults = assertions
+	res.Success = allPassed(assertions)
+	return res
+}
+
+func diffStatus(primary, secondary *httpResponse) AssertionResult {
+	result := AssertionResult{Kind: "diff_status", Op: "equals"}
+	result.Passed = primary.resp.StatusCode == secondary.resp.StatusCode
+	if !result.Passed {
+		result.Message = fmt.Sprintf("status differs: primary %d, secondary %d", primary.resp.StatusCode, secondary.resp.StatusCode)
+	}
+	return result
+}
+
+// diffBody compares the bodies byte for byte. On mismatch it reports the
+// line, column and byte offset of the first difference.
+func diffBody(primary, secondary []byte) (AssertionResult, string) {
+	result := AssertionResult{Kind: "diff_body", Op: "equals"}
+	if bytes.Equal(primary, secondary) {
+		result.Passed = true
+		return result, ""
+	}
+	offset := 0
+	for offset < len(primary) && offset < len(secondary) && primary[offset] == secondary[offset] {
+		offset++
+	}
+	line := 1 + bytes.Count(primary[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(primary[:offset], '\n')
+	location := fmt.Sprintf("line %d, column %d (byte %d)", line, column, offset)
+	result.Message = fmt.Sprintf("bodies differ at %s: primary %q, secondary %q", location, excerpt(primary, offset), excerpt(secondary, offset))
+	return result, location
+}
+
+// diffJSONPaths compares the value at each configured jsonpath and returns
+// the first differing path as the diff location.
+func diffJSONPaths(cfg config.CheckConfig, primaryBody, secondaryBody []byte) ([]AssertionResult, string) {
+	results := make([]AssertionResult, 0, len(cfg.Diff.JSONPaths))
+	primaryJSON, primaryErr := decodeJSON(primaryBody, cfg.Request)
+	secondaryJSON, secondaryErr := decodeJSON(secondaryBody, cfg.Request)
+	location := ""
+	for _, path := range cfg.Diff.JSONPaths {
+		result := AssertionResult{Kind: "diff_jsonpath", Op: "equals", Path: path}
+		switch {
+		case primaryErr != nil:
+			result.Message = fmt.Sprintf("primary: decode json: %v", primaryErr)
+		case secondaryErr != nil:
+			result.Message = fmt.Sprintf("secondary: decode json: %v", secondaryErr)
+		default:
+			primaryVal, err := jsonpath.JsonPathLookup(primaryJSON, path)
+			if err != nil {
+				result.Message = fmt.Sprintf("primary: jsonpath lookup: %v", err)
+				break
+			}
+			secondaryVal, err := jsonpath.JsonPathLookup(secondaryJSON, path)
+			if err != nil {
+				result.Message = fmt.Sprintf("secondary: jsonpath lookup: %v", err)
+				break
+			}
+			result.Passed = reflect.DeepEqual(primaryVal, secondaryVal)
+			if !result.Passed {
+				result.Message = fmt.Sprintf("%s differs: primary %v, secondary %v", path, primaryVal, secondaryVal)
+			}
+		}
+		if !result.Passed && location == "" {
+			location = path
+		}
+		results = append(results, result)
+	}
+	return results, location
+}
+
+func excerpt(body []byte, offset int) string {
+	if offset >= len(body) {
+		return ""
+	}
+	end := min(offset+diffExcerptBytes, len(body))
+	return string(body[offset:end])
+}
+
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func startBodyServer(t *testing.T, body *string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(*body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func runDiffCheck(primary, secondary string, paths ...string) Result {
+	cfg := config.CheckConfig{
+		ID:     "blue-green",
+		Type:   "diff",
+		Target: primary,
+		Diff:   &config.DiffCheck{Secondary: secondary, JSONPaths: paths},
+	}
+	return Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: http.DefaultClient})
+}
+
+func TestDiffCheckComparesFullBodies(t *testing.T) {
+	blue := "{\n  \"version\": \"1.2.0\",\n  \"region\": \"eu\"\n}"
+	green := blue
+	blueURL := startBodyServer(t, &blue)
+	greenURL := startBodyServer(t, &green)
+
+	result := runDiffCheck(blueURL, greenURL)
+	if !result.Success {
+		t.Fatalf("expected identical bodies to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if result.Metadata["primary_sha256"] != result.Metadata["secondary_sha256"] {
+		t.Fatalf("expected equal hashes, got %v", result.Metadata)
+	}
+
+	green = "{\n  \"version\": \"1.3.0\",\n  \"region\": \"eu\"\n}"
+	result = runDiffCheck(blueURL, greenURL)
+	if result.Success {
+		t.Fatalf("expected drifted bodies to fail")
+	}
+	if result.Reason != ReasonBodyMismatch {
+		t.Fatalf("expected reason %q, got %q", ReasonBodyMismatch, result.Reason)
+	}
+	if got := result.Metadata["diff_location"]; got != "line 2, column 17 (byte 18)" {
+		t.Fatalf("unexpected diff location %v", got)
+	}
+	if msg := result.AssertionResults[1].Message; !strings.Contains(msg, `primary "2.0\"`) {
+		t.Fatalf("expected excerpt of primary body in message, got %q", msg)
+	}
+}
+
+func TestDiffCheckComparesJSONPaths(t *testing.T) {
+	blue := `{"version":"1.2.0","build":"a1","deployed_at":"10:00"}`
+	green := `{"version":"1.2.0","build":"a1","deployed_at":"10:05"}`
+	blueURL := startBodyServer(t, &blue)
+	greenURL := startBodyServer(t, &green)
+
+	result := runDiffCheck(blueURL, greenURL, "$.version", "$.build")
+	if !result.Success {
+		t.Fatalf("expected matching jsonpaths to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	green = `{"version":"1.3.0","build":"a1","deployed_at":"10:05"}`
+	result = runDiffCheck(blueURL, greenURL, "$.version", "$.build")
+	if result.Success {
+		t.Fatalf("expected drifted version to fail")
+	}
+	if got := result.Metadata["diff_location"]; got != "$.version" {
+		t.Fatalf("expected diff location $.version, got %v", got)
+	}
+	if !result.AssertionResults[2].Passed {
+		t.Fatalf("expected $.build to still match, got %+v", result.AssertionResults[2])
+	}
+}
+
+func TestDiffCheckRequiresSecondary(t *testing.T) {
+	result := Execute(context.Background(), config.CheckConfig{ID: "d", Type: "diff", Target: "http://127.0.0.1"}, Environment{TemplateEngine: render.New()})
+	if result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected config error, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
@@ -0,0 +1,167 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dnsclient "github.com/miekg/dns"
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// dnsFlags returns the header flags of a DNS response keyed by their
+// lowercase mnemonic.
+func dnsFlags(resp *dnsclient.Msg) map[string]bool {
+	return map[string]bool{
+		"aa": resp.Authoritative,
+		"tc": resp.Truncated,
+		"rd": resp.RecursionDesired,
+		"ra": resp.RecursionAvailable,
+		"ad": resp.AuthenticatedData,
+		"cd": resp.CheckingDisabled,
+	}
+}
+
+// rcodeName returns the mnemonic of a response code, e.g. "NXDOMAIN".
+func rcodeName(rcode int) string {
+	if name, ok := dnsclient.RcodeToString[rcode]; ok {
+		return name
+	}
+	return strconv.Itoa(rcode)
+}
+
+// hasAssertion reports whether the check configures an assertion of kind.
+func hasAssertion(assertions []config.Assertion, kind string) bool {
+	for _, assertion := range assertions {
+		if strings.EqualFold(assertion.Kind, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateDNSFlag checks a flag given as "aa=true", "ra=false" or a bare
+// "aa" (meaning true).
+func evaluateDNSFlag(result AssertionResult, flags map[string]bool, value interface{}) AssertionResult {
+	spec := strings.ToLower(strings.TrimSpace(fmt.Sprint(value)))
+	name, want, found := strings.Cut(spec, "=")
+	expected := true
+	if found {
+		parsed, err := strconv.ParseBool(strings.TrimSpace(want))
+		if err != nil {
+			result.Message = fmt.Sprintf("invalid dns_flag value %q", spec)
+			return result
+		}
+		expected = parsed
+	}
+	name = strings.TrimSpace(name)
+	actual, ok := flags[name]
+	if !ok {
+		result.Message = fmt.Sprintf("unknown dns flag %q", name)
+		return result
+	}
+	result.Path = name
+	result.Passed = actual == expected
+	if !result.Passed {
+		result.Message = fmt.Sprintf("flag %s is %t, expected %t", name, actual, expected)
+	}
+	return result
+}
+
+// evaluateDNSRcode compares the response code by mnemonic ("NXDOMAIN") or
+// number with equals or not_equals.
+func evaluateDNSRcode(result AssertionResult, rcode int, value interface{}, op string) AssertionResult {
+	spec := strings.ToUpper(strings.TrimSpace(fmt.Sprint(value)))
+	expected, ok := dnsclient.StringToRcode[spec]
+	if !ok {
+		number, err := strconv.Atoi(spec)
+		if err != nil {
+			result.Message = fmt.Sprintf("unknown dns rcode %q", spec)
+			return result
+		}
+		expected = number
+	}
+	switch strings.ToLower(op) {
+	case "", "equals", "equal", "==":
+		result.Passed = rcode == expected
+	case "not_equals", "!=":
+		result.Passed = rcode != expected
+	default:
+		result.Message = fmt.Sprintf("unsupported op %q for dns_rcode", op)
+		return result
+	}
+	if !result.Passed {
+		result.Message = fmt.Sprintf("rcode %s not %s %s", rcodeName(rcode), op, rcodeName(expected))
+	}
+	return result
+}
+
+// runDNSResolvers queries every resolver of the check concurrently and
+// evaluates the check's assertions plus the resolver's own against each
+// answer. Results carry the resolver name as Path, so a split-horizon check
+// can expect internal addresses from one view and public ones from another.
+func runDNSResolvers(ctx context.Context, start time.Time, cfg config.CheckConfig) Result {
+	res := Result{
+		CheckID:   cfg.ID,
+		CheckName: cfg.Name,
+		StartedAt: start,
+	}
+	type answer struct {
+		resp    *dnsclient.Msg
+		latency time.Duration
+		err     error
+	}
+	answers := make([]answer, len(cfg.Resolvers))
+	var wg sync.WaitGroup
+	for i, resolver := range cfg.Resolvers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queryStart := time.Now()
+			resp, err := queryDNS(ctx, cfg, resolver.Address)
+			answers[i] = answer{resp: resp, latency: time.Since(queryStart), err: err}
+		}()
+	}
+	wg.Wait()
+	res.CompletedAt = time.Now()
+	res.Latency = res.CompletedAt.Sub(start)
+
+	views := make(map[string]any, len(cfg.Resolvers))
+	var assertions []AssertionResult
+	for i, resolver := range cfg.Resolvers {
+		name := resolver.Name
+		if name == "" {
+			name = resolver.Address
+		}
+		view := map[string]any{"address": resolver.Address}
+		views[name] = view
+		resp, err := answers[i].resp, answers[i].err
+		if err != nil {
+			view["error"] = err.Error()
+			assertions = append(assertions, AssertionResult{Kind: "dns_query", Path: name, Message: fmt.Sprintf("%s: %v", name, err)})
+			continue
+		}
+		view["rcode"] = rcodeName(resp.Rcode)
+		view["answers"] = extractAnswerStrings(resp.Answer)
+
+		specs := append(append([]config.Assertion(nil), cfg.Assertions...), resolver.Assertions...)
+		if resp.Rcode != dnsclient.RcodeSuccess && !hasAssertion(specs, "dns_rcode") {
+			assertions = append(assertions, AssertionResult{Kind: "dns_query", Path: name, Message: fmt.Sprintf("%s: dns error code %s", name, rcodeName(resp.Rcode))})
+			continue
+		}
+		for _, result := range evaluateDNS(specs, resp, answers[i].latency) {
+			result.Path = name
+			if result.Message != "" {
+				result.Message = name + ": " + result.Message
+			}
+			assertions = append(assertions, result)
+		}
+	}
+	res.Metadata = map[string]any{"resolvers": views}
+	res.AssertionResults = assertions
+	res.Success = allPassed(assertions)
+	return res
+}
@@ -0,0 +1,197 @@
+package checks
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	dnsclient "github.com/miekg/dns"
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// startDNSServer answers A queries for ok.example. authoritatively and
+// NXDOMAIN for everything else.
+func startDNSServer(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	handler := dnsclient.HandlerFunc(func(w dnsclient.ResponseWriter, req *dnsclient.Msg) {
+		msg := new(dnsclient.Msg)
+		msg.SetReply(req)
+		q := req.Question[0]
+		if !strings.EqualFold(q.Name, "ok.example.") {
+			msg.SetRcode(req, dnsclient.RcodeNameError)
+			_ = w.WriteMsg(msg)
+			return
+		}
+		msg.Authoritative = true
+		msg.Answer = append(msg.Answer, &dnsclient.A{
+			Hdr: dnsclient.RR_Header{Name: q.Name, Rrtype: dnsclient.TypeA, Class: dnsclient.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.10"),
+		})
+		_ = w.WriteMsg(msg)
+	})
+	server := &dnsclient.Server{PacketConn: pc, Handler: handler}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
+func TestRunDNSMatchesNXDOMAIN(t *testing.T) {
+	resolver := startDNSServer(t)
+	cfg := config.CheckConfig{
+		ID:         "dns-nx",
+		Type:       "dns",
+		Target:     "missing.example",
+		RecordType: "A",
+		Resolver:   resolver,
+		Assertions: []config.Assertion{{Kind: "dns_rcode", Op: "equals", Value: "NXDOMAIN"}},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{})
+	if !result.Success {
+		t.Fatalf("expected NXDOMAIN to match, got error=%v assertions=%+v", result.Error, result.AssertionResults)
+	}
+	if result.Metadata["rcode"] != "NXDOMAIN" {
+		t.Fatalf("expected rcode metadata, got %v", result.Metadata["rcode"])
+	}
+
+	cfg.Assertions = nil
+	result = Execute(context.Background(), cfg, Environment{})
+	if result.Success || result.Reason != ReasonDNSError {
+		t.Fatalf("expected NXDOMAIN without dns_rcode assertion to fail with dns_error, got %q", result.Reason)
+	}
+}
+
+func TestRunDNSChecksFlags(t *testing.T) {
+	resolver := startDNSServer(t)
+	cfg := config.CheckConfig{
+		ID:         "dns-aa",
+		Type:       "dns",
+		Target:     "ok.example",
+		RecordType: "A",
+		Resolver:   resolver,
+		Assertions: []config.Assertion{
+			{Kind: "dns_flag", Value: "aa=true"},
+			{Kind: "dns_rcode", Op: "equals", Value: "NOERROR"},
+		},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{})
+	if !result.Success {
+		t.Fatalf("expected authoritative answer to pass, got %+v", result.AssertionResults)
+	}
+	flags, _ := result.Metadata["flags"].(map[string]bool)
+	if !flags["aa"] || flags["ra"] {
+		t.Fatalf("expected aa set and ra unset in metadata, got %v", flags)
+	}
+
+	cfg.Assertions = []config.Assertion{{Kind: "dns_flag", Value: "aa=false"}}
+	result = Execute(context.Background(), cfg, Environment{})
+	if result.Success || result.Reason != ReasonDNSMismatch {
+		t.Fatalf("expected aa=false to fail with dns_mismatch, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if msg := result.AssertionResults[0].Message; !strings.Contains(msg, "flag aa is true") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+// startViewDNSServer answers every A query with ip, standing in for one view
+// of split-horizon DNS.
+func startViewDNSServer(t *testing.T, ip string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	handler := dnsclient.HandlerFunc(func(w dnsclient.ResponseWriter, req *dnsclient.Msg) {
+		msg := new(dnsclient.Msg)
+		msg.SetReply(req)
+		msg.Answer = append(msg.Answer, &dnsclient.A{
+			Hdr: dnsclient.RR_Header{Name: req.Question[0].Name, Rrtype: dnsclient.TypeA, Class: dnsclient.ClassINET, Ttl: 60},
+			A:   net.ParseIP(ip),
+		})
+		_ = w.WriteMsg(msg)
+	})
+	server := &dnsclient.Server{PacketConn: pc, Handler: handler}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
+func TestRunDNSChecksEachResolverView(t *testing.T) {
+	internal := startViewDNSServer(t, "10.0.0.5")
+	external := startViewDNSServer(t, "203.0.113.5")
+	cfg := config.CheckConfig{
+		ID:         "split-horizon",
+		Type:       "dns",
+		Target:     "app.example.com",
+		RecordType: "A",
+		Assertions: []config.Assertion{{Kind: "dns_rcode", Op: "equals", Value: "NOERROR"}},
+		Resolvers: []config.DNSResolver{
+			{Name: "internal", Address: internal, Assertions: []config.Assertion{{Kind: "dns_answer", Value: []any{"10.0.0.5"}}}},
+			{Name: "external", Address: external, Assertions: []config.Assertion{{Kind: "dns_answer", Value: []any{"203.0.113.5"}}}},
+		},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{})
+	if !result.Success {
+		t.Fatalf("expected both views to match, got %+v", result.AssertionResults)
+	}
+	if len(result.AssertionResults) != 4 {
+		t.Fatalf("expected shared and per-view assertions for both resolvers, got %+v", result.AssertionResults)
+	}
+	views := result.Metadata["resolvers"].(map[string]any)
+	if got := views["external"].(map[string]any)["answers"]; !reflect.DeepEqual(got, []string{"203.0.113.5"}) {
+		t.Fatalf("unexpected external answers %v", got)
+	}
+
+	// The internal view leaking to the external resolver must fail.
+	cfg.Resolvers[1].Address = internal
+	result = Execute(context.Background(), cfg, Environment{})
+	if result.Success || result.Reason != ReasonDNSMismatch {
+		t.Fatalf("expected external view mismatch, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	failed := result.AssertionResults[3]
+	if failed.Passed || failed.Path != "external" || !strings.HasPrefix(failed.Message, "external: ") {
+		t.Fatalf("expected failure labelled with the external resolver, got %+v", failed)
+	}
+}
+
+func TestRunDNSSupportsLatencyAssertion(t *testing.T) {
+	resolver := startDNSServer(t)
+	cfg := config.CheckConfig{
+		ID:         "dns-latency",
+		Type:       "dns",
+		Target:     "ok.example",
+		RecordType: "A",
+		Resolver:   resolver,
+		Assertions: []config.Assertion{{Kind: "latency_ms", Op: "less_than", Value: 5000}},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{})
+	if !result.Success {
+		t.Fatalf("expected latency_ms to pass, got error=%v assertions=%+v", result.Error, result.AssertionResults)
+	}
+	if result.Latency <= 0 {
+		t.Fatalf("expected dns run to record its latency, got %s", result.Latency)
+	}
+
+	cfg.Assertions = []config.Assertion{{Kind: "latency_ms", Op: "less_than", Value: 0}}
+	result = Execute(context.Background(), cfg, Environment{})
+	if result.Success || result.Reason != ReasonLatencyExceeded {
+		t.Fatalf("expected latency_exceeded, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
@@ -0,0 +1,285 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+const (
+	// execOutputLimit caps how much of stdout and stderr each is kept for
+	// assertions; execExcerptBytes is the tail recorded in the metadata.
+	execOutputLimit  = 64 << 10
+	execExcerptBytes = 2 << 10
+	// execWaitDelay bounds how long a command that exited, or was killed,
+	// may keep its output open through a process that left its group.
+	execWaitDelay = 2 * time.Second
+)
+
+// runExec runs the configured command and asserts on its exit code and
+// output. Without an exit_code assertion a non-zero exit fails the check.
+// When the check times out the whole process group is killed.
+func runExec(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:          cfg.ID,
+		CheckName:        cfg.Name,
+		StartedAt:        start,
+		Metadata:         map[string]any{},
+		AssertionResults: []AssertionResult{},
+	}
+	defer func() {
+		res.CompletedAt = time.Now()
+		res.Latency = res.CompletedAt.Sub(start)
+	}()
+
+	if cfg.Exec == nil || len(cfg.Exec.Command) == 0 || strings.TrimSpace(cfg.Exec.Command[0]) == "" {
+		res.Error = fmt.Errorf("exec.command is required for exec check")
+		res.Reason = ReasonConfigError
+		return res
+	}
+	if !env.Exec.Enabled {
+		res.Error = fmt.Errorf("exec checks are disabled; set exec.enabled to run them")
+		res.Reason = ReasonConfigError
+		return res
+	}
+	path, err := resolveExecCommand(cfg.Exec.Command[0], env.Exec.AllowedCommands)
+	if err != nil {
+		res.Error = err
+		res.Reason = ReasonConfigError
+		return res
+	}
+	vars, err := execEnv(cfg, env)
+	if err != nil {
+		res.Error = err
+		res.Reason = ReasonConfigError
+		return res
+	}
+
+	var stdout, stderr limitedBuffer
+	stdout.limit, stderr.limit = execOutputLimit, execOutputLimit
+	cmd := exec.Command(path, cfg.Exec.Command[1:]...)
+	cmd.Dir = cfg.Exec.Dir
+	cmd.Env = vars
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = execWaitDelay
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		res.Error = fmt.Errorf("start command: %w", err)
+		res.Reason = ReasonCommandFailed
+		return res
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var waitErr error
+	select {
+	case waitErr = <-done:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		recordExecOutput(&res, stdout.String(), stderr.String())
+		res.Error = fmt.Errorf("command killed: %w", ctx.Err())
+		return res
+	}
+	if errors.Is(waitErr, exec.ErrWaitDelay) {
+		// The command exited successfully; only a process it started still
+		// held its output.
+		waitErr = nil
+	}
+	exitCode := cmd.ProcessState.ExitCode()
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		res.Error = fmt.Errorf("run command: %w", waitErr)
+		res.Reason = ReasonCommandFailed
+		return res
+	}
+	res.Metadata["exit_code"] = exitCode
+	recordExecOutput(&res, stdout.String(), stderr.String())
+
+	if exitCode != 0 && !hasAssertion(cfg.Assertions, "exit_code") {
+		res.AssertionResults = append(res.AssertionResults, AssertionResult{
+			Kind:    "exit_code",
+			Op:      "equals",
+			Message: fmt.Sprintf("command exited with status %d", exitCode),
+		})
+	}
+	for _, assertion := range cfg.Assertions {
+		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
+		switch strings.ToLower(assertion.Kind) {
+		case "exit_code":
+			expect, ok := toFloat(assertion.Value)
+			if !ok {
+				result.Message = fmt.Sprintf("invalid exit_code value %v", assertion.Value)
+				break
+			}
+			op := assertion.Op
+			if op == "" {
+				op = "equals"
+			}
+			result.Passed = compareFloats(float64(exitCode), expect, op)
+			if !result.Passed {
+				result.Message = fmt.Sprintf("exit code %d not %s %g", exitCode, op, expect)
+			}
+		case "stdout_contains", "stderr_contains":
+			output, stream := stdout.String(), "stdout"
+			if strings.EqualFold(assertion.Kind, "stderr_contains") {
+				output, stream = stderr.String(), "stderr"
+			}
+			expect := fmt.Sprintf("%v", assertion.Value)
+			result.Passed = strings.Contains(output, expect)
+			if !result.Passed {
+				result.Message = fmt.Sprintf("%s does not contain %q", stream, expect)
+			}
+		case "latency_ms":
+			result = evaluateLatency(assertion, time.Since(start))
+		default:
+			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
+		}
+		res.AssertionResults = append(res.AssertionResults, result)
+	}
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
+
+// resolveExecCommand looks the command up on PATH and, when allowed is not
+// empty, requires its absolute path to match one of the patterns.
+func resolveExecCommand(command string, allowed []string) (string, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", fmt.Errorf("find command: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("resolve command: %w", err)
+	}
+	if len(allowed) == 0 {
+		return abs, nil
+	}
+	for _, pattern := range allowed {
+		if pattern == abs {
+			return abs, nil
+		}
+		if ok, err := filepath.Match(pattern, abs); err == nil && ok {
+			return abs, nil
+		}
+	}
+	return "", fmt.Errorf("command %s is not in exec.allowed_commands", abs)
+}
+
+// execEnv builds the environment of the command: PATH (or the worker's
+// environment with exec.inherit_env), UPUPUP_CHECK_ID, UPUPUP_CHECK_NAME,
+// UPUPUP_CHECK_TARGET, one UPUPUP_LABEL_<KEY> per label and the rendered
+// exec.env entries.
+func execEnv(cfg config.CheckConfig, env Environment) ([]string, error) {
+	var vars []string
+	if env.Exec.InheritEnv {
+		vars = os.Environ()
+	} else {
+		vars = []string{"PATH=" + os.Getenv("PATH")}
+	}
+	vars = append(vars,
+		"UPUPUP_CHECK_ID="+cfg.ID,
+		"UPUPUP_CHECK_NAME="+cfg.Name,
+		"UPUPUP_CHECK_TARGET="+cfg.Target,
+	)
+	labels := make([]string, 0, len(cfg.Labels))
+	for key := range cfg.Labels {
+		labels = append(labels, key)
+	}
+	sort.Strings(labels)
+	for _, key := range labels {
+		vars = append(vars, "UPUPUP_LABEL_"+envName(key)+"="+cfg.Labels[key])
+	}
+	if len(cfg.Exec.Env) == 0 {
+		return vars, nil
+	}
+	if env.TemplateEngine == nil {
+		return nil, fmt.Errorf("template engine not configured")
+	}
+	renderCtx := render.TemplateContext{
+		Secrets: env.Secrets,
+		Vars:    env.Vars,
+		Data: map[string]interface{}{
+			"labels": cfg.Labels,
+			"check": map[string]interface{}{
+				"id":     cfg.ID,
+				"name":   cfg.Name,
+				"target": cfg.Target,
+			},
+		},
+	}
+	rendered, err := render.RenderMap(cfg.Exec.Env, renderCtx, env.TemplateEngine)
+	if err != nil {
+		return nil, fmt.Errorf("render exec.env: %w", err)
+	}
+	keys := make([]string, 0, len(rendered))
+	for key := range rendered {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		vars = append(vars, key+"="+rendered[key])
+	}
+	return vars, nil
+}
+
+// envName upper-cases a label key and replaces characters that are not
+// valid in environment variable names.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+func recordExecOutput(res *Result, stdout, stderr string) {
+	res.Metadata["stdout"] = tail(stdout, execExcerptBytes)
+	res.Metadata["stderr"] = tail(stderr, execExcerptBytes)
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a chatty command cannot exhaust memory.
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package checks
+
+import "os/exec"
+
+// Process groups are unix-only; elsewhere only the command itself is killed.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = cmd.Process.Kill()
+}
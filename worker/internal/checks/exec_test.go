@@ -0,0 +1,125 @@
+package checks
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("exec tests use shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), "check.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	return path
+}
+
+func execEnvironment(allowed ...string) Environment {
+	return Environment{
+		TemplateEngine: render.New(),
+		Secrets:        map[string]string{"TOKEN": "s3cr3t"},
+		Exec:           config.ExecConfig{Enabled: true, AllowedCommands: allowed},
+	}
+}
+
+func TestExecCheckSucceeds(t *testing.T) {
+	script := writeScript(t, `echo "check=$UPUPUP_CHECK_ID team=$UPUPUP_LABEL_TEAM token=$TOKEN"`+"\n")
+	cfg := config.CheckConfig{
+		ID:     "backup",
+		Type:   "exec",
+		Labels: map[string]string{"team": "ops"},
+		Exec: &config.ExecCheck{
+			Command: []string{script},
+			Env:     map[string]string{"TOKEN": `{{ secret "TOKEN" }}`},
+		},
+		Assertions: []config.Assertion{
+			{Kind: "exit_code", Value: 0},
+			{Kind: "stdout_contains", Value: "check=backup team=ops token=s3cr3t"},
+		},
+	}
+
+	result := Execute(context.Background(), cfg, execEnvironment(filepath.Dir(script)+"/*.sh"))
+	if !result.Success {
+		t.Fatalf("expected success, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if result.Metadata["exit_code"] != 0 {
+		t.Fatalf("expected exit_code metadata 0, got %v", result.Metadata["exit_code"])
+	}
+}
+
+func TestExecCheckFailsOnNonZeroExit(t *testing.T) {
+	script := writeScript(t, "echo broken >&2\nexit 3\n")
+	cfg := config.CheckConfig{ID: "job", Type: "exec", Exec: &config.ExecCheck{Command: []string{script}}}
+
+	result := Execute(context.Background(), cfg, execEnvironment())
+	if result.Success || result.Reason != ReasonCommandFailed {
+		t.Fatalf("expected command_failed, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if result.Metadata["stderr"] != "broken\n" {
+		t.Fatalf("expected stderr captured, got %q", result.Metadata["stderr"])
+	}
+}
+
+func TestExecCheckKillsProcessGroupOnTimeout(t *testing.T) {
+	// The child sleep inherits stdout, so the check only returns once the
+	// whole group is killed.
+	script := writeScript(t, "sleep 30 &\nsleep 30\n")
+	cfg := config.CheckConfig{ID: "slow", Type: "exec", Exec: &config.ExecCheck{Command: []string{script}}}
+	env := execEnvironment()
+	env.Defaults.Timeout = config.Duration{Duration: 200 * time.Millisecond}
+
+	started := time.Now()
+	result := Execute(context.Background(), cfg, env)
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("expected the timeout to kill the command, took %s", elapsed)
+	}
+	if result.Success || result.Reason != ReasonTimeout {
+		t.Fatalf("expected timeout, got success=%v reason=%q err=%v", result.Success, result.Reason, result.Error)
+	}
+}
+
+func TestExecCheckReturnsWhenAnEscapedChildHoldsOutput(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+	// setsid moves the child out of the process group, so killing the group
+	// leaves it running with stdout open.
+	script := writeScript(t, "setsid sleep 10 &\nsleep 30\n")
+	cfg := config.CheckConfig{ID: "slow", Type: "exec", Exec: &config.ExecCheck{Command: []string{script}}}
+	env := execEnvironment()
+	env.Defaults.Timeout = config.Duration{Duration: 200 * time.Millisecond}
+
+	started := time.Now()
+	result := Execute(context.Background(), cfg, env)
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("expected the check to stop waiting for the escaped child, took %s", elapsed)
+	}
+	if result.Success || result.Reason != ReasonTimeout {
+		t.Fatalf("expected timeout, got success=%v reason=%q err=%v", result.Success, result.Reason, result.Error)
+	}
+}
+
+func TestExecCheckRefusedByPolicy(t *testing.T) {
+	script := writeScript(t, "exit 0\n")
+	cfg := config.CheckConfig{ID: "job", Type: "exec", Exec: &config.ExecCheck{Command: []string{script}}}
+
+	env := execEnvironment()
+	env.Exec.Enabled = false
+	if result := Execute(context.Background(), cfg, env); result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected disabled exec to be refused, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if result := Execute(context.Background(), cfg, execEnvironment("/usr/local/bin/*")); result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected command outside the allowlist to be refused, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
@@ -0,0 +1,51 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// expectAnyFailure makes expect_failure accept a failure of any reason.
+const expectAnyFailure = "any"
+
+// withExpectedFailure applies expect_failure to a finished run. A failure
+// with the expected reason becomes a success that keeps the original error
+// and reason in its metadata; a success becomes an unexpected_success
+// failure. Maintenance and pending runs are left as they are.
+func withExpectedFailure(cfg config.CheckConfig, res Result) Result {
+	expected := strings.ToLower(strings.TrimSpace(cfg.ExpectFailure))
+	if expected == "" || res.Maintenance || res.Pending {
+		return res
+	}
+	if expected == "true" {
+		expected = expectAnyFailure
+	}
+	result := AssertionResult{Kind: "expect_failure", Op: "equals"}
+	if res.Success {
+		result.Message = fmt.Sprintf("check succeeded, expected it to fail with %s", expected)
+		res.AssertionResults = append(res.AssertionResults, result)
+		res.Success = false
+		res.Reason = ReasonUnexpectedSuccess
+		return res
+	}
+	if expected != expectAnyFailure && res.Reason != expected {
+		result.Message = fmt.Sprintf("check failed with %s, expected %s", res.Reason, expected)
+		res.AssertionResults = append(res.AssertionResults, result)
+		return res
+	}
+	if res.Metadata == nil {
+		res.Metadata = map[string]any{}
+	}
+	res.Metadata["expected_failure"] = res.Reason
+	if res.Error != nil {
+		res.Metadata["expected_error"] = res.Error.Error()
+	}
+	result.Passed = true
+	res.AssertionResults = append(res.AssertionResults, result)
+	res.Success = true
+	res.Error = nil
+	res.Reason = ""
+	return res
+}
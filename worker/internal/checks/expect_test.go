@@ -0,0 +1,76 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+func TestExpectFailurePassesOnRefusedConnect(t *testing.T) {
+	closed := fmt.Sprintf("127.0.0.1:%d", closedPort(t))
+	cfg := config.CheckConfig{
+		ID:            "firewall",
+		Type:          "tcp",
+		Target:        closed,
+		ExpectFailure: "connection_refused",
+		Assertions:    []config.Assertion{{Kind: "tcp_connect", Op: "equals", Value: true}},
+	}
+	res := Execute(context.Background(), cfg, Environment{})
+	if !res.Success || res.Error != nil || res.Reason != "" {
+		t.Fatalf("expected refused connect to pass, got success=%v reason=%q err=%v", res.Success, res.Reason, res.Error)
+	}
+	if res.Metadata["expected_failure"] != ReasonConnectionRefused || res.Metadata["expected_error"] == nil {
+		t.Fatalf("expected the original failure in metadata, got %v", res.Metadata)
+	}
+
+	cfg.ExpectFailure = "timeout"
+	res = Execute(context.Background(), cfg, Environment{})
+	if res.Success || res.Reason != ReasonConnectionRefused {
+		t.Fatalf("expected a failure of another reason to still fail, got success=%v reason=%q", res.Success, res.Reason)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	cfg.Target = ln.Addr().String()
+	cfg.ExpectFailure = "any"
+	res = Execute(context.Background(), cfg, Environment{})
+	if res.Success || res.Reason != ReasonUnexpectedSuccess {
+		t.Fatalf("expected an open port to fail with unexpected_success, got success=%v reason=%q", res.Success, res.Reason)
+	}
+}
+
+func TestTCPConnectionRefusedAssertion(t *testing.T) {
+	closed := fmt.Sprintf("127.0.0.1:%d", closedPort(t))
+	for _, assertion := range []config.Assertion{
+		{Kind: "connection_refused", Op: "equals", Value: true},
+		{Kind: "tcp_connect", Op: "equals", Value: false},
+	} {
+		cfg := config.CheckConfig{ID: "closed", Type: "tcp", Target: closed, Assertions: []config.Assertion{assertion}}
+		res := Execute(context.Background(), cfg, Environment{})
+		if !res.Success || res.Error != nil {
+			t.Fatalf("%s: expected refused connect to pass, got %+v", assertion.Kind, res.AssertionResults)
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	cfg := config.CheckConfig{
+		ID:         "open",
+		Type:       "tcp",
+		Target:     ln.Addr().String(),
+		Assertions: []config.Assertion{{Kind: "connection_refused", Op: "equals", Value: true}},
+	}
+	res := Execute(context.Background(), cfg, Environment{})
+	if res.Success || res.Reason != ReasonConnectionError {
+		t.Fatalf("expected an accepted connect to fail, got success=%v reason=%q", res.Success, res.Reason)
+	}
+}
@@ -0,0 +1,129 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// runGRPC calls the standard grpc.health.v1.Health/Check RPC of the target
+// and asserts on the returned serving status. Without a grpc_status
+// assertion the check requires SERVING.
+func runGRPC(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:          cfg.ID,
+		CheckName:        cfg.Name,
+		StartedAt:        start,
+		Metadata:         map[string]any{},
+		AssertionResults: []AssertionResult{},
+	}
+	defer func() {
+		res.CompletedAt = time.Now()
+		res.Latency = res.CompletedAt.Sub(start)
+	}()
+
+	var opts config.GRPCCheck
+	if cfg.GRPC != nil {
+		opts = *cfg.GRPC
+	}
+	res.Metadata["service"] = opts.Service
+	creds := insecure.NewCredentials()
+	if opts.TLS {
+		host, _, err := net.SplitHostPort(cfg.Target)
+		if err != nil {
+			res.Error = fmt.Errorf("invalid target: %w", err)
+			res.Reason = ReasonConfigError
+			return res
+		}
+		serverName := cfg.SNI
+		if serverName == "" {
+			serverName = host
+		}
+		creds = credentials.NewTLS(tlsBaseConfig(env, serverName, nil))
+	}
+	conn, err := grpc.NewClient(cfg.Target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		res.Error = fmt.Errorf("create grpc client: %w", err)
+		res.Reason = ReasonConfigError
+		return res
+	}
+	defer conn.Close()
+
+	callCtx := ctx
+	if timeout := EffectiveTimeout(cfg, env.Defaults); timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(callCtx, &healthpb.HealthCheckRequest{Service: opts.Service})
+	if err != nil {
+		res.Error = fmt.Errorf("health check: %w", err)
+		res.Reason = reasonForGRPCError(err)
+		return res
+	}
+	serving := resp.GetStatus().String()
+	res.Metadata["serving_status"] = serving
+
+	if !hasAssertion(cfg.Assertions, "grpc_status") && resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		res.AssertionResults = append(res.AssertionResults, AssertionResult{
+			Kind:    "grpc_status",
+			Op:      "equals",
+			Message: fmt.Sprintf("serving status %s", serving),
+		})
+	}
+	for _, assertion := range cfg.Assertions {
+		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
+		switch strings.ToLower(assertion.Kind) {
+		case "grpc_status":
+			expect := strings.ToUpper(strings.TrimSpace(fmt.Sprintf("%v", assertion.Value)))
+			if _, ok := healthpb.HealthCheckResponse_ServingStatus_value[expect]; !ok {
+				result.Message = fmt.Sprintf("invalid grpc_status value %v", assertion.Value)
+				break
+			}
+			result.Passed = serving == expect
+			if strings.EqualFold(assertion.Op, "not_equals") {
+				result.Passed = !result.Passed
+			}
+			if !result.Passed {
+				result.Message = fmt.Sprintf("serving status %s, expected %s %s", serving, assertion.Op, expect)
+			}
+		case "latency_ms":
+			result = evaluateLatency(assertion, time.Since(start))
+		default:
+			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
+		}
+		res.AssertionResults = append(res.AssertionResults, result)
+	}
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
+
+// reasonForGRPCError maps the status of a failed health RPC onto a reason
+// code; the transport error behind it is not exposed by grpc.
+func reasonForGRPCError(err error) string {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return ReasonTimeout
+	case codes.Unavailable:
+		if strings.Contains(err.Error(), "tls: ") || strings.Contains(err.Error(), "x509: ") {
+			return ReasonTLSError
+		}
+		return ReasonConnectionError
+	case codes.NotFound:
+		// The server does not know the queried service.
+		return ReasonStatusMismatch
+	default:
+		return ReasonError
+	}
+}
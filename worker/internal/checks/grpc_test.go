@@ -0,0 +1,114 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// startHealthServer serves grpc.health.v1 with the payments service not
+// serving, over TLS with the PKI's leaf when pki is not nil.
+func startHealthServer(t *testing.T, pki *testPKI) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var opts []grpc.ServerOption
+	if pki != nil {
+		cert := tls.Certificate{Certificate: [][]byte{pki.leaf.Raw}, PrivateKey: pki.leafKey}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	}
+	srv := grpc.NewServer(opts...)
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("payments", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String()
+}
+
+func runGRPCCheck(addr string, opts *config.GRPCCheck, client *http.Client, assertions ...config.Assertion) Result {
+	cfg := config.CheckConfig{ID: "grpc", Type: "grpc", Target: addr, GRPC: opts, Assertions: assertions}
+	env := Environment{
+		TemplateEngine: render.New(),
+		HttpClient:     client,
+		Defaults:       config.ServiceDefault{Timeout: config.Duration{Duration: 2 * time.Second}},
+	}
+	return Execute(context.Background(), cfg, env)
+}
+
+func TestGRPCCheckAssertsServingStatus(t *testing.T) {
+	addr := startHealthServer(t, nil)
+
+	result := runGRPCCheck(addr, nil, nil, config.Assertion{Kind: "latency_ms", Op: "less_than", Value: 5000})
+	if !result.Success {
+		t.Fatalf("expected overall health to be serving, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if result.Metadata["serving_status"] != "SERVING" || result.Metadata["service"] != "" {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+
+	payments := &config.GRPCCheck{Service: "payments"}
+	result = runGRPCCheck(addr, payments, nil)
+	if result.Success || result.Reason != ReasonStatusMismatch {
+		t.Fatalf("expected not serving service to fail with status_mismatch, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if result.Metadata["serving_status"] != "NOT_SERVING" || result.Metadata["service"] != "payments" {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+
+	result = runGRPCCheck(addr, payments, nil, config.Assertion{Kind: "grpc_status", Op: "equals", Value: "not_serving"})
+	if !result.Success {
+		t.Fatalf("expected grpc_status NOT_SERVING to pass, got %+v", result.AssertionResults)
+	}
+
+	result = runGRPCCheck(addr, &config.GRPCCheck{Service: "unknown"}, nil)
+	if result.Success || result.Error == nil {
+		t.Fatalf("expected unknown service to fail, got %+v", result)
+	}
+}
+
+func TestGRPCCheckUsesTLS(t *testing.T) {
+	pki := newTestPKI(t, "")
+	addr := startHealthServer(t, &pki)
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	result := runGRPCCheck(addr, &config.GRPCCheck{TLS: true}, client)
+	if !result.Success {
+		t.Fatalf("expected tls health check to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	result = runGRPCCheck(addr, nil, client)
+	if result.Success || result.Reason != ReasonConnectionError {
+		t.Fatalf("expected plaintext call to a tls server to fail, got success=%v reason=%q err=%v", result.Success, result.Reason, result.Error)
+	}
+}
+
+func TestGRPCCheckConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	result := runGRPCCheck(addr, nil, nil)
+	if result.Success || result.Reason != ReasonConnectionError {
+		t.Fatalf("expected connection_error, got success=%v reason=%q err=%v", result.Success, result.Reason, result.Error)
+	}
+}
@@ -0,0 +1,107 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+const defaultHistoryWindow = time.Hour
+
+// runHistory asserts on the failure count and ratio of another check's runs
+// stored within the trailing window.
+func runHistory(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:          cfg.ID,
+		CheckName:        cfg.Name,
+		StartedAt:        start,
+		Metadata:         map[string]any{},
+		AssertionResults: []AssertionResult{},
+	}
+	defer func() {
+		res.CompletedAt = time.Now()
+		res.Latency = res.CompletedAt.Sub(start)
+	}()
+
+	if env.Store == nil {
+		res.Error = fmt.Errorf("history store not configured")
+		res.Reason = ReasonConfigError
+		return res
+	}
+	checkID := strings.TrimSpace(cfg.Target)
+	window := defaultHistoryWindow
+	if cfg.History != nil {
+		if id := strings.TrimSpace(cfg.History.CheckID); id != "" {
+			checkID = id
+		}
+		if cfg.History.Window.Duration > 0 {
+			window = cfg.History.Window.Duration
+		}
+	}
+	if checkID == "" {
+		res.Error = fmt.Errorf("history.check_id or target is required for history check")
+		res.Reason = ReasonConfigError
+		return res
+	}
+	if checkID == cfg.ID {
+		res.Error = fmt.Errorf("history check cannot observe itself")
+		res.Reason = ReasonConfigError
+		return res
+	}
+	if len(cfg.Assertions) == 0 {
+		res.Error = fmt.Errorf("no history assertions configured")
+		res.Reason = ReasonConfigError
+		return res
+	}
+
+	total, failed, err := env.Store.RecentOutcomeCounts(ctx, checkID, start.Add(-window))
+	if err != nil {
+		res.Error = fmt.Errorf("load check history: %w", err)
+		res.Reason = ReasonStorageError
+		return res
+	}
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(failed) / float64(total)
+	}
+	res.Metadata["history_check_id"] = checkID
+	res.Metadata["window_seconds"] = window.Seconds()
+	res.Metadata["run_count"] = total
+	res.Metadata["failure_count"] = failed
+	res.Metadata["failure_ratio"] = ratio
+
+	for _, assertion := range cfg.Assertions {
+		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
+		var actual float64
+		switch strings.ToLower(assertion.Kind) {
+		case "failure_count":
+			actual = float64(failed)
+		case "failure_ratio":
+			actual = ratio
+		case "success_count":
+			actual = float64(total - failed)
+		case "run_count":
+			actual = float64(total)
+		default:
+			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
+			res.AssertionResults = append(res.AssertionResults, result)
+			continue
+		}
+		expect, ok := toFloat(assertion.Value)
+		if !ok {
+			result.Message = fmt.Sprintf("invalid %s value %v", assertion.Kind, assertion.Value)
+			res.AssertionResults = append(res.AssertionResults, result)
+			continue
+		}
+		result.Passed = compareFloats(actual, expect, assertion.Op)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%s %g not %s %g over %s", assertion.Kind, actual, assertion.Op, expect, window)
+		}
+		res.AssertionResults = append(res.AssertionResults, result)
+	}
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
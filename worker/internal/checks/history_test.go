@@ -0,0 +1,93 @@
+package checks
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func seedHistory(t *testing.T, outcomes map[time.Duration]bool) *storage.Store {
+	t.Helper()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "monitor.db"), storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	now := time.Now()
+	for age, success := range outcomes {
+		err := store.RecordCheckRun(context.Background(), storage.CheckRun{
+			CheckID:    "api",
+			CheckName:  "API",
+			Success:    success,
+			OccurredAt: now.Add(-age),
+		})
+		if err != nil {
+			t.Fatalf("record run: %v", err)
+		}
+	}
+	return store
+}
+
+func TestRunHistoryFailureCountThreshold(t *testing.T) {
+	store := seedHistory(t, map[time.Duration]bool{
+		5 * time.Minute:  false,
+		10 * time.Minute: false,
+		15 * time.Minute: true,
+		20 * time.Minute: false,
+		25 * time.Minute: true,
+		90 * time.Minute: false, // outside the window
+		95 * time.Minute: false,
+	})
+	cfg := config.CheckConfig{
+		ID:      "api-flapping",
+		Type:    "history",
+		History: &config.HistoryCheck{CheckID: "api", Window: config.Duration{Duration: time.Hour}},
+		Assertions: []config.Assertion{
+			{Kind: "failure_count", Op: "less_than", Value: 3},
+		},
+	}
+	env := Environment{Store: store}
+
+	result := Execute(context.Background(), cfg, env)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.Success {
+		t.Fatalf("expected 3 failures in the last hour to breach the threshold")
+	}
+	if result.Metadata["failure_count"] != 3 || result.Metadata["run_count"] != 5 {
+		t.Fatalf("unexpected history metadata: %+v", result.Metadata)
+	}
+
+	cfg.Assertions = []config.Assertion{
+		{Kind: "failure_count", Op: "less_than", Value: 4},
+		{Kind: "failure_ratio", Op: "less_than", Value: 0.7},
+	}
+	if result := Execute(context.Background(), cfg, env); !result.Success {
+		t.Fatalf("expected relaxed thresholds to pass, got %+v", result.AssertionResults)
+	}
+
+	cfg.History.Window = config.Duration{Duration: 2 * time.Hour}
+	cfg.Assertions = []config.Assertion{{Kind: "failure_count", Op: "equals", Value: 5}}
+	if result := Execute(context.Background(), cfg, env); !result.Success {
+		t.Fatalf("expected wider window to include older failures, got %+v", result.AssertionResults)
+	}
+}
+
+func TestRunHistoryRequiresStore(t *testing.T) {
+	cfg := config.CheckConfig{
+		ID:         "api-flapping",
+		Type:       "history",
+		Target:     "api",
+		Assertions: []config.Assertion{{Kind: "failure_count", Op: "less_than", Value: 3}},
+	}
+	if result := Execute(context.Background(), cfg, Environment{}); result.Error == nil {
+		t.Fatalf("expected error without store")
+	}
+}
@@ -0,0 +1,50 @@
+package checks
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// ProtocolHTTP3 selects the QUIC round tripper for an HTTP check.
+const ProtocolHTTP3 = "h3"
+
+// httpProtocol validates the protocol option of an HTTP check. An empty
+// protocol keeps the default HTTP/1.1 and HTTP/2 transport.
+func httpProtocol(cfg config.CheckConfig) (string, error) {
+	protocol := strings.ToLower(strings.TrimSpace(cfg.Protocol))
+	switch protocol {
+	case "":
+		return "", nil
+	case ProtocolHTTP3, "http3":
+		return ProtocolHTTP3, nil
+	default:
+		return "", fmt.Errorf("unsupported protocol %q", cfg.Protocol)
+	}
+}
+
+// IsHTTP3 reports whether the check runs over HTTP/3.
+func IsHTTP3(cfg config.CheckConfig) bool {
+	protocol, err := httpProtocol(cfg)
+	return err == nil && protocol == ProtocolHTTP3
+}
+
+// http3Transport builds a QUIC round tripper that trusts the same roots as
+// the base transport and honours the check's SNI.
+func http3Transport(rt http.RoundTripper, cfg config.CheckConfig) *http3.Transport {
+	tlsConfig := &tls.Config{}
+	if base, ok := rt.(*http.Transport); ok && base != nil && base.TLSClientConfig != nil {
+		tlsConfig = base.TLSClientConfig.Clone()
+	}
+	if cfg.SNI != "" {
+		tlsConfig.ServerName = cfg.SNI
+	}
+	// http3 negotiates "h3" itself; a custom ALPN list does not apply.
+	tlsConfig.NextProtos = nil
+	return &http3.Transport{TLSClientConfig: tlsConfig}
+}
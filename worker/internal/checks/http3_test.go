@@ -0,0 +1,80 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// startHTTP3Server serves handler over QUIC with the certificate of an
+// httptest TLS server, whose client trusts it.
+func startHTTP3Server(t *testing.T, handler http.Handler) (string, *http.Client) {
+	t.Helper()
+	tlsSrv := httptest.NewTLSServer(handler)
+	t.Cleanup(tlsSrv.Close)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	server := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tlsSrv.TLS.Certificates}),
+	}
+	go func() {
+		_ = server.Serve(pc)
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = pc.Close()
+	})
+	return "https://" + pc.LocalAddr().String(), tlsSrv.Client()
+}
+
+func TestRunHTTPOverHTTP3(t *testing.T) {
+	url, client := startHTTP3Server(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proto=" + r.Proto))
+	}))
+	cfg := config.CheckConfig{
+		ID:       "h3",
+		Type:     "https",
+		Target:   url,
+		Protocol: "h3",
+		Assertions: []config.Assertion{
+			{Kind: "status_code", Op: "equals", Value: 200},
+			{Kind: "body_contains", Op: "contains", Value: "proto=HTTP/3.0"},
+		},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+	if !result.Success {
+		t.Fatalf("expected HTTP/3 check to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if got := result.Metadata["http_protocol"]; got != "HTTP/3.0" {
+		t.Fatalf("expected HTTP/3.0, got %v", got)
+	}
+	if got := result.Metadata["negotiated_protocol"]; got != "h3" {
+		t.Fatalf("expected negotiated h3, got %v", got)
+	}
+
+	cfg.Protocol = ""
+	if result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client}); result.Success {
+		t.Fatalf("expected the default transport not to reach an HTTP/3-only endpoint")
+	}
+}
+
+func TestRunHTTPRejectsUnknownProtocol(t *testing.T) {
+	cfg := config.CheckConfig{ID: "proto", Type: "https", Target: "https://example.com", Protocol: "spdy"}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()})
+	if result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected config_error, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
@@ -0,0 +1,113 @@
+package checks
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHSTSMaxAge is the minimum HSTS max-age https_enforced requires when
+// the assertion sets none: 180 days.
+const defaultHSTSMaxAge = 180 * 24 * time.Hour
+
+// evaluateHTTPSEnforced checks that the plain-HTTP target redirected to HTTPS
+// and that the final HTTPS response sends Strict-Transport-Security with at
+// least the minimum max-age given as the assertion value. The findings are
+// returned for the run metadata.
+func evaluateHTTPSEnforced(result AssertionResult, resp *http.Response, value any) (AssertionResult, map[string]any) {
+	chain := redirectChain(resp)
+	findings := map[string]any{"redirect_chain": chain}
+	minAge, err := parseHSTSMaxAge(value)
+	if err != nil {
+		result.Message = err.Error()
+		return result, findings
+	}
+
+	first := resp.Request
+	for first.Response != nil {
+		first = first.Response.Request
+	}
+	redirected := len(chain) > 1 && first.URL.Scheme == "http" && resp.Request.URL.Scheme == "https"
+	findings["redirects_to_https"] = redirected
+
+	header := resp.Header.Get("Strict-Transport-Security")
+	maxAge, directives := parseHSTS(header)
+	if resp.TLS != nil && header != "" {
+		findings["hsts"] = header
+		findings["hsts_max_age"] = maxAge
+		findings["hsts_include_subdomains"] = slices.Contains(directives, "includesubdomains")
+		findings["hsts_preload"] = slices.Contains(directives, "preload")
+	}
+
+	switch {
+	case first.URL.Scheme != "http":
+		result.Message = "https_enforced needs an http:// target to verify the redirect"
+	case !redirected:
+		result.Message = fmt.Sprintf("http request was not redirected to https (ended at %s)", resp.Request.URL.Redacted())
+	case resp.TLS == nil || header == "":
+		result.Message = "https response has no Strict-Transport-Security header"
+	case maxAge < 0:
+		result.Message = fmt.Sprintf("invalid Strict-Transport-Security header %q", header)
+	case time.Duration(maxAge)*time.Second < minAge:
+		result.Message = fmt.Sprintf("hsts max-age %d below required %d", maxAge, int64(minAge/time.Second))
+	default:
+		result.Passed = true
+	}
+	return result, findings
+}
+
+// redirectChain lists the URLs visited from the original request to the
+// final response.
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append(chain, req.URL.Redacted())
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// parseHSTS returns the max-age in seconds, or -1 when it is missing or
+// invalid, and the other directives in lower case.
+func parseHSTS(header string) (int64, []string) {
+	maxAge := int64(-1)
+	var directives []string
+	for _, part := range strings.Split(header, ";") {
+		name, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "max-age" {
+			if n, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(val), `"`), 10, 64); err == nil && n >= 0 {
+				maxAge = n
+			}
+			continue
+		}
+		if name != "" {
+			directives = append(directives, name)
+		}
+	}
+	return maxAge, directives
+}
+
+// parseHSTSMaxAge reads the minimum max-age as seconds or a Go duration.
+func parseHSTSMaxAge(value any) (time.Duration, error) {
+	switch v := value.(type) {
+	case nil, bool:
+		return defaultHSTSMaxAge, nil
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return d, nil
+		}
+	}
+	seconds, ok := toFloat(value)
+	if !ok || seconds < 0 {
+		return 0, fmt.Errorf("https_enforced value must be a minimum max-age in seconds or a duration, got %v", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,79 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// startRedirectingSite serves the site over HTTPS with the given HSTS header
+// and redirects plain HTTP to it. It returns the HTTP URL and a client that
+// trusts the HTTPS server.
+func startRedirectingSite(t *testing.T, hsts string) (string, *http.Client) {
+	t.Helper()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hsts != "" {
+			w.Header().Set("Strict-Transport-Security", hsts)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(secure.Close)
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, secure.URL+r.URL.Path, http.StatusMovedPermanently)
+	}))
+	t.Cleanup(plain.Close)
+	return plain.URL, secure.Client()
+}
+
+func runHTTPSEnforced(url string, client *http.Client, value any) Result {
+	cfg := config.CheckConfig{
+		ID:         "site",
+		Type:       "http",
+		Target:     url + "/login",
+		Assertions: []config.Assertion{{Kind: "https_enforced", Value: value}},
+	}
+	return Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+}
+
+func TestHTTPSEnforcedPassesForCompliantSite(t *testing.T) {
+	url, client := startRedirectingSite(t, "max-age=31536000; includeSubDomains")
+
+	result := runHTTPSEnforced(url, client, nil)
+	if !result.Success {
+		t.Fatalf("expected compliant site to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	findings := result.Metadata["https_enforced"].(map[string]any)
+	if findings["hsts_max_age"] != int64(31536000) || findings["hsts_include_subdomains"] != true {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+	if chain := findings["redirect_chain"].([]string); len(chain) != 2 || chain[0] != url+"/login" {
+		t.Fatalf("unexpected redirect chain %v", chain)
+	}
+
+	result = runHTTPSEnforced(url, client, "17520h")
+	if result.Success {
+		t.Fatalf("expected max-age below two years to fail")
+	}
+}
+
+func TestHTTPSEnforcedFailsWithoutHSTS(t *testing.T) {
+	url, client := startRedirectingSite(t, "")
+
+	result := runHTTPSEnforced(url, client, 86400)
+	if result.Success {
+		t.Fatalf("expected site without HSTS to fail")
+	}
+	if result.Reason != ReasonHTTPSNotEnforced {
+		t.Fatalf("expected reason %q, got %q", ReasonHTTPSNotEnforced, result.Reason)
+	}
+	if msg := result.AssertionResults[0].Message; msg != "https response has no Strict-Transport-Security header" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	if findings := result.Metadata["https_enforced"].(map[string]any); findings["redirects_to_https"] != true {
+		t.Fatalf("expected redirect to be recorded, got %v", findings)
+	}
+}
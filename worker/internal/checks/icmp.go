@@ -0,0 +1,228 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ping/ping"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// Pinger sends count echo requests to target over network ("ip", "ip4" or
+// "ip6") and returns the statistics. An error means the target could not be
+// resolved for that network.
+type Pinger func(ctx context.Context, target, network string, count int, timeout time.Duration) (*ping.Statistics, error)
+
+const icmpCount = 3
+
+// icmpFamily is one address family of an icmp check and the outcome of
+// pinging it.
+type icmpFamily struct {
+	name    string
+	network string
+	stats   *ping.Statistics
+	err     error
+}
+
+func (f icmpFamily) reachable() bool {
+	return f.err == nil && f.stats.PacketsRecv > 0
+}
+
+// goPing pings target with go-ping.
+func goPing(ctx context.Context, target, network string, count int, timeout time.Duration) (*ping.Statistics, error) {
+	pinger := ping.New(target)
+	pinger.SetNetwork(network)
+	if err := pinger.Resolve(); err != nil {
+		return nil, err
+	}
+	pinger.SetPrivileged(true)
+	pinger.Count = count
+	pinger.Timeout = timeout
+	stop := context.AfterFunc(ctx, pinger.Stop)
+	defer stop()
+	pinger.Run() // blocking
+	return pinger.Statistics(), nil
+}
+
+// icmpFamilies maps the configured families onto go-ping networks.
+func icmpFamilies(cfg config.CheckConfig) ([]icmpFamily, error) {
+	if cfg.ICMP == nil || len(cfg.ICMP.Families) == 0 {
+		return nil, nil
+	}
+	families := make([]icmpFamily, 0, len(cfg.ICMP.Families))
+	for _, family := range cfg.ICMP.Families {
+		switch strings.ToLower(strings.TrimSpace(family)) {
+		case "ipv4":
+			families = append(families, icmpFamily{name: "ipv4", network: "ip4"})
+		case "ipv6":
+			families = append(families, icmpFamily{name: "ipv6", network: "ip6"})
+		default:
+			return nil, fmt.Errorf("unsupported icmp.families entry %q (want ipv4 or ipv6)", family)
+		}
+	}
+	return families, nil
+}
+
+// runICMP pings the target. With icmp.families every family is pinged
+// concurrently and packet_loss_percent, latency_ms_p95 and latency_ms must
+// hold for each of them; v4_reachable and v6_reachable assert on a single
+// family.
+func runICMP(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:   cfg.ID,
+		CheckName: cfg.Name,
+		StartedAt: start,
+		Metadata:  map[string]any{},
+	}
+	families, err := icmpFamilies(cfg)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Error = err
+		res.Reason = ReasonConfigError
+		return res
+	}
+	pinger := env.Pinger
+	if pinger == nil {
+		pinger = goPing
+	}
+	timeout := EffectiveTimeout(cfg, env.Defaults)
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	if len(families) == 0 {
+		stats, err := pinger(ctx, cfg.Target, "ip", icmpCount, timeout)
+		if err != nil {
+			res.CompletedAt = time.Now()
+			res.Error = fmt.Errorf("init pinger: %w", err)
+			res.Reason = ReasonConfigError
+			return res
+		}
+		res.CompletedAt = time.Now()
+		res.Latency = stats.AvgRtt
+		res.Metadata["packet_loss"] = stats.PacketLoss
+		res.Metadata["rtt_p95_ms"] = stats.AvgRtt.Seconds() * 1000 // approximate
+		res.AssertionResults = evaluateICMP(cfg.Assertions, []icmpFamily{{stats: stats}})
+		res.Success = allPassed(res.AssertionResults)
+		return res
+	}
+
+	var wg sync.WaitGroup
+	for i := range families {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			families[i].stats, families[i].err = pinger(ctx, cfg.Target, families[i].network, icmpCount, timeout)
+		}()
+	}
+	wg.Wait()
+	res.CompletedAt = time.Now()
+
+	meta := make(map[string]any, len(families))
+	for _, family := range families {
+		entry := map[string]any{"reachable": family.reachable()}
+		if family.err != nil {
+			entry["error"] = family.err.Error()
+		} else {
+			entry["address"] = family.stats.Addr
+			if family.stats.IPAddr != nil {
+				entry["address"] = family.stats.IPAddr.String()
+			}
+			entry["packet_loss"] = family.stats.PacketLoss
+			entry["avg_rtt_ms"] = family.stats.AvgRtt.Seconds() * 1000
+			if family.stats.AvgRtt > res.Latency {
+				res.Latency = family.stats.AvgRtt
+			}
+		}
+		meta[family.name] = entry
+	}
+	res.Metadata["families"] = meta
+	res.AssertionResults = evaluateICMP(cfg.Assertions, families)
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
+
+// evaluateICMP applies the assertions to every pinged family. Messages name
+// the failing family when families were pinged separately.
+func evaluateICMP(specs []config.Assertion, families []icmpFamily) []AssertionResult {
+	assertions := make([]AssertionResult, 0, len(specs))
+	for _, assertion := range specs {
+		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
+		kind := strings.ToLower(assertion.Kind)
+		switch kind {
+		case "v4_reachable", "v6_reachable":
+			name := "ipv4"
+			if kind == "v6_reachable" {
+				name = "ipv6"
+			}
+			expect := strings.ToLower(fmt.Sprintf("%v", assertion.Value)) != "false"
+			var family *icmpFamily
+			for i := range families {
+				if families[i].name == name {
+					family = &families[i]
+				}
+			}
+			switch {
+			case family == nil:
+				result.Message = fmt.Sprintf("%s not probed; add it to icmp.families", name)
+			case family.reachable() == expect:
+				result.Passed = true
+			case family.err != nil:
+				result.Message = fmt.Sprintf("%s: %v", name, family.err)
+			case expect:
+				result.Message = fmt.Sprintf("%s unreachable (%.0f%% packet loss)", name, family.stats.PacketLoss)
+			default:
+				result.Message = fmt.Sprintf("%s unexpectedly reachable", name)
+			}
+		case "packet_loss_percent", "latency_ms_p95", "latency_ms":
+			var failures []string
+			for _, family := range families {
+				msg := evaluateICMPFamily(assertion, kind, family)
+				if msg == "" {
+					continue
+				}
+				if family.name != "" {
+					msg = family.name + ": " + msg
+				}
+				failures = append(failures, msg)
+			}
+			result.Passed = len(failures) == 0
+			result.Message = strings.Join(failures, "; ")
+		default:
+			result.Passed = false
+			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
+		}
+		assertions = append(assertions, result)
+	}
+	return assertions
+}
+
+func evaluateICMPFamily(assertion config.Assertion, kind string, family icmpFamily) string {
+	if family.err != nil {
+		return family.err.Error()
+	}
+	stats := family.stats
+	expect, _ := toFloat(assertion.Value)
+	switch kind {
+	case "packet_loss_percent":
+		if compareFloats(stats.PacketLoss, expect, assertion.Op) {
+			return ""
+		}
+		return fmt.Sprintf("packet loss %.2f%% not %s %.2f", stats.PacketLoss, assertion.Op, expect)
+	case "latency_ms_p95":
+		actual := stats.AvgRtt.Seconds() * 1000
+		if compareFloats(actual, expect, assertion.Op) {
+			return ""
+		}
+		return fmt.Sprintf("latency %.2fms not %s %.2f", actual, assertion.Op, expect)
+	default:
+		if result := evaluateLatency(assertion, stats.AvgRtt); !result.Passed {
+			return result.Message
+		}
+		return ""
+	}
+}
@@ -0,0 +1,84 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ping/ping"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// stubPinger answers ip4 pings and loses every ip6 packet.
+func stubPinger(ctx context.Context, target, network string, count int, timeout time.Duration) (*ping.Statistics, error) {
+	switch network {
+	case "ip4":
+		return &ping.Statistics{PacketsSent: count, PacketsRecv: count, IPAddr: &net.IPAddr{IP: net.ParseIP("192.0.2.1")}, AvgRtt: 12 * time.Millisecond}, nil
+	case "ip6":
+		return &ping.Statistics{PacketsSent: count, PacketLoss: 100, IPAddr: &net.IPAddr{IP: net.ParseIP("2001:db8::1")}}, nil
+	default:
+		return nil, errors.New("unexpected network " + network)
+	}
+}
+
+func runDualStackCheck(pinger Pinger, assertions ...config.Assertion) Result {
+	cfg := config.CheckConfig{
+		ID:         "edge",
+		Type:       "icmp",
+		Target:     "edge.example.com",
+		ICMP:       &config.ICMPCheck{Families: []string{"ipv4", "ipv6"}},
+		Assertions: assertions,
+	}
+	return Execute(context.Background(), cfg, Environment{Pinger: pinger})
+}
+
+func TestICMPReportsEachFamily(t *testing.T) {
+	result := runDualStackCheck(stubPinger,
+		config.Assertion{Kind: "v4_reachable", Value: true},
+		config.Assertion{Kind: "v6_reachable", Value: true},
+		config.Assertion{Kind: "packet_loss_percent", Op: "less_than", Value: 50},
+	)
+	if result.Success || result.Reason != ReasonPacketLoss {
+		t.Fatalf("expected unreachable ipv6 to fail with packet_loss, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if !result.AssertionResults[0].Passed {
+		t.Fatalf("expected ipv4 to be reachable, got %+v", result.AssertionResults[0])
+	}
+	if msg := result.AssertionResults[1].Message; msg != "ipv6 unreachable (100% packet loss)" {
+		t.Fatalf("unexpected v6_reachable message %q", msg)
+	}
+	if msg := result.AssertionResults[2].Message; !strings.HasPrefix(msg, "ipv6: packet loss") || strings.Contains(msg, "ipv4") {
+		t.Fatalf("expected only ipv6 to breach packet loss, got %q", msg)
+	}
+
+	families, _ := result.Metadata["families"].(map[string]any)
+	v4, _ := families["ipv4"].(map[string]any)
+	v6, _ := families["ipv6"].(map[string]any)
+	if v4["reachable"] != true || v4["address"] != "192.0.2.1" || v6["reachable"] != false || v6["packet_loss"] != 100.0 {
+		t.Fatalf("unexpected family metadata %v", families)
+	}
+	if result.Latency != 12*time.Millisecond {
+		t.Fatalf("expected latency of the reachable family, got %s", result.Latency)
+	}
+}
+
+func TestICMPFamilyWithoutAddress(t *testing.T) {
+	v4Only := func(ctx context.Context, target, network string, count int, timeout time.Duration) (*ping.Statistics, error) {
+		if network == "ip6" {
+			return nil, errors.New("no AAAA record")
+		}
+		return stubPinger(ctx, target, network, count, timeout)
+	}
+	result := runDualStackCheck(v4Only, config.Assertion{Kind: "v4_reachable", Value: true}, config.Assertion{Kind: "v6_reachable", Value: false})
+	if !result.Success {
+		t.Fatalf("expected v4-only host to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	families, _ := result.Metadata["families"].(map[string]any)
+	if v6, _ := families["ipv6"].(map[string]any); v6["error"] != "no AAAA record" {
+		t.Fatalf("expected ipv6 resolution error in metadata, got %v", families)
+	}
+}
@@ -0,0 +1,119 @@
+package checks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// inlineSchemaURL names an inline schema for the compiler, which needs a
+// location to resolve relative references against.
+const inlineSchemaURL = "inline://assertion/schema.json"
+
+// schemaCache holds compiled schemas by their source: "path:" and the file
+// path, or "inline:" and the schema document. A schema file is compiled
+// again once its modification time changes.
+var schemaCache sync.Map
+
+type cachedSchema struct {
+	schema  *jsonschema.Schema
+	modTime time.Time
+}
+
+// evaluateJSONSchema validates the decoded response body against the schema
+// of a json_schema assertion: inline in Value (a YAML mapping or a JSON
+// string) or in the file at Path.
+func evaluateJSONSchema(result AssertionResult, assertion config.Assertion, body interface{}) AssertionResult {
+	schema, err := compileSchema(assertion)
+	if err != nil {
+		result.Passed = false
+		result.Message = fmt.Sprintf("json schema: %v", err)
+		return result
+	}
+	err = schema.Validate(body)
+	result.Passed = err == nil
+	if err != nil {
+		result.Message = firstSchemaError(err)
+	}
+	return result
+}
+
+// compileSchema returns the compiled schema of a json_schema assertion,
+// compiling it only on the first run that uses it.
+func compileSchema(assertion config.Assertion) (*jsonschema.Schema, error) {
+	if path := strings.TrimSpace(assertion.Path); path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		key := "path:" + path
+		if cached, ok := schemaCache.Load(key); ok && cached.(cachedSchema).modTime.Equal(info.ModTime()) {
+			return cached.(cachedSchema).schema, nil
+		}
+		schema, err := jsonschema.NewCompiler().Compile(path)
+		if err != nil {
+			return nil, err
+		}
+		schemaCache.Store(key, cachedSchema{schema: schema, modTime: info.ModTime()})
+		return schema, nil
+	}
+	var key string
+	if source, err := json.Marshal(assertion.Value); err == nil {
+		key = "inline:" + string(source)
+		if cached, ok := schemaCache.Load(key); ok {
+			return cached.(cachedSchema).schema, nil
+		}
+	}
+	schema, err := compileInlineSchema(assertion.Value)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		schemaCache.Store(key, cachedSchema{schema: schema})
+	}
+	return schema, nil
+}
+
+func compileInlineSchema(value any) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	var doc interface{}
+	switch value := value.(type) {
+	case nil:
+		return nil, errors.New("value or path is required")
+	case string:
+		parsed, err := jsonschema.UnmarshalJSON(strings.NewReader(value))
+		if err != nil {
+			return nil, fmt.Errorf("parse inline schema: %w", err)
+		}
+		doc = parsed
+	default:
+		doc = value
+	}
+	if err := compiler.AddResource(inlineSchemaURL, doc); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(inlineSchemaURL)
+}
+
+// firstSchemaError reports the first leaf of a validation error with the
+// location of the offending value, e.g. `/items/0/id: got string, want
+// integer`.
+func firstSchemaError(err error) string {
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err.Error()
+	}
+	for len(verr.Causes) > 0 {
+		verr = verr.Causes[0]
+	}
+	out := verr.BasicOutput()
+	message := out.Error.String()
+	return fmt.Sprintf("/%s: %s", strings.Join(verr.InstanceLocation, "/"), message)
+}
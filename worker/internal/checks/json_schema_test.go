@@ -0,0 +1,101 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestRunHTTPJSONSchema(t *testing.T) {
+	body := `{"status": "ok", "items": [{"id": 1}, {"id": "two"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"status", "items"},
+		"properties": map[string]interface{}{
+			"status": map[string]interface{}{"enum": []interface{}{"ok"}},
+			"items": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "object", "required": []interface{}{"id"}},
+			},
+		},
+	}
+	cfg := config.CheckConfig{
+		ID:         "schema",
+		Type:       "http",
+		Target:     srv.URL,
+		Assertions: []config.Assertion{{Kind: "json_schema", Value: schema}},
+	}
+	env := Environment{TemplateEngine: render.New()}
+	if result := Execute(context.Background(), cfg, env); !result.Success {
+		t.Fatalf("expected inline schema to pass, got %+v", result.AssertionResults)
+	}
+
+	strict := `{"type": "object", "properties": {"items": {"type": "array", "items": {"properties": {"id": {"type": "integer"}}}}}}`
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(strict), 0o600); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+	cfg.Assertions = []config.Assertion{{Kind: "json_schema", Path: path}}
+	result := Execute(context.Background(), cfg, env)
+	if result.Success || result.Reason != ReasonBodyMismatch {
+		t.Fatalf("expected schema file to fail with body_mismatch, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if msg := result.AssertionResults[0].Message; !strings.HasPrefix(msg, "/items/1/id: ") {
+		t.Fatalf("expected first validation error with its location, got %q", msg)
+	}
+
+	cfg.Assertions = []config.Assertion{{Kind: "json_schema", Value: strict}}
+	if result := Execute(context.Background(), cfg, env); result.Success {
+		t.Fatalf("expected inline JSON schema to fail")
+	}
+}
+
+func TestCompileSchemaCachesUntilFileChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "object"}`), 0o600); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+	assertion := config.Assertion{Kind: "json_schema", Path: path}
+	first, err := compileSchema(assertion)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if again, err := compileSchema(assertion); err != nil || again != first {
+		t.Fatalf("expected the compiled schema to be reused, got %p and %p (%v)", first, again, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"type": "array"}`), 0o600); err != nil {
+		t.Fatalf("rewrite schema: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("touch schema: %v", err)
+	}
+	changed, err := compileSchema(assertion)
+	if err != nil || changed == first {
+		t.Fatalf("expected a changed file to be compiled again (%v)", err)
+	}
+	if err := changed.Validate([]any{}); err != nil {
+		t.Fatalf("expected the new schema to apply, got %v", err)
+	}
+
+	inline := config.Assertion{Kind: "json_schema", Value: map[string]any{"type": "object"}}
+	a, errA := compileSchema(inline)
+	b, errB := compileSchema(inline)
+	if errA != nil || errB != nil || a != b {
+		t.Fatalf("expected the inline schema to be reused (%v, %v)", errA, errB)
+	}
+}
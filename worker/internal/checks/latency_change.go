@@ -0,0 +1,131 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// Defaults of latency_change.
+const (
+	defaultLatencyChangeWindow  = time.Hour
+	defaultLatencyChangeOffset  = 7 * 24 * time.Hour
+	defaultLatencyChangeMinRuns = 5
+)
+
+// withLatencyChange evaluates latency_change on a successful run. The
+// current window holds the stored successful runs of the check plus this
+// one; the earlier window the runs of the same span Offset before. The run
+// fails with latency_exceeded when the median rose by more than
+// max_increase_percent. Windows with fewer than min_runs runs pass.
+func withLatencyChange(ctx context.Context, cfg config.CheckConfig, env Environment, res Result) Result {
+	if cfg.LatencyChange == nil || !res.Success || res.Maintenance || res.Pending {
+		return res
+	}
+	result := evaluateLatencyChange(ctx, cfg, env, &res)
+	res.AssertionResults = append(res.AssertionResults, result)
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
+
+func evaluateLatencyChange(ctx context.Context, cfg config.CheckConfig, env Environment, res *Result) AssertionResult {
+	change := cfg.LatencyChange
+	result := AssertionResult{Kind: "latency_change", Op: "increase"}
+	if change.MaxIncreasePercent <= 0 {
+		result.Message = "latency_change.max_increase_percent must be positive"
+		res.Reason = ReasonConfigError
+		return result
+	}
+	if env.Store == nil {
+		result.Message = "load latency history: store not configured"
+		res.Reason = ReasonStorageError
+		return result
+	}
+	window, offset := latencyChangeWindows(change)
+	minRuns := defaultLatencyChangeMinRuns
+	if change.MinRuns > 0 {
+		minRuns = change.MinRuns
+	}
+
+	now := res.StartedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	current, err := env.Store.SuccessLatencies(ctx, cfg.ID, now.Add(-window), now)
+	if err == nil {
+		current = append(current, res.Latency)
+	}
+	var previous []time.Duration
+	if err == nil {
+		previous, err = env.Store.SuccessLatencies(ctx, cfg.ID, now.Add(-offset-window), now.Add(-offset))
+	}
+	if err != nil {
+		result.Message = fmt.Sprintf("load latency history: %v", err)
+		res.Reason = ReasonStorageError
+		return result
+	}
+
+	detail := map[string]any{
+		"window_seconds": window.Seconds(),
+		"offset_seconds": offset.Seconds(),
+		"current_runs":   len(current),
+		"previous_runs":  len(previous),
+	}
+	if res.Metadata == nil {
+		res.Metadata = map[string]any{}
+	}
+	res.Metadata["latency_change"] = detail
+	if len(current) < minRuns || len(previous) < minRuns {
+		result.Passed = true
+		result.Message = fmt.Sprintf("not enough history: %d current and %d previous runs, need %d each", len(current), len(previous), minRuns)
+		return result
+	}
+	currentMedian := medianMilliseconds(current)
+	previousMedian := medianMilliseconds(previous)
+	detail["current_median_ms"] = currentMedian
+	detail["previous_median_ms"] = previousMedian
+	if previousMedian <= 0 {
+		result.Passed = true
+		return result
+	}
+	increase := (currentMedian - previousMedian) / previousMedian * 100
+	detail["change_percent"] = increase
+	result.Passed = increase <= change.MaxIncreasePercent
+	if !result.Passed {
+		result.Message = fmt.Sprintf("median latency %.0fms is %+.1f%% over %.0fms %s earlier (max %g%%)", currentMedian, increase, previousMedian, offset, change.MaxIncreasePercent)
+	}
+	return result
+}
+
+// LatencyChangeSpan returns how far back latency_change reads the check's
+// history: its offset plus its window.
+func LatencyChangeSpan(change *config.LatencyChange) time.Duration {
+	window, offset := latencyChangeWindows(change)
+	return offset + window
+}
+
+func latencyChangeWindows(change *config.LatencyChange) (window, offset time.Duration) {
+	window = defaultLatencyChangeWindow
+	if change.Window.Duration > 0 {
+		window = change.Window.Duration
+	}
+	offset = defaultLatencyChangeOffset
+	if change.Offset.Duration > 0 {
+		offset = change.Offset.Duration
+	}
+	return window, offset
+}
+
+// medianMilliseconds returns the median of durations in milliseconds.
+func medianMilliseconds(durations []time.Duration) float64 {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return float64(sorted[mid]) / float64(time.Millisecond)
+	}
+	return float64(sorted[mid-1]+sorted[mid]) / 2 / float64(time.Millisecond)
+}
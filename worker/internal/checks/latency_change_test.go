@@ -0,0 +1,62 @@
+package checks
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func TestLatencyChangeDetectsWeekOverWeekIncrease(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "monitor.db"), storage.Options{CheckStateRetention: 100})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	ctx := context.Background()
+	now := time.Now()
+	seed := func(at time.Time, latencies ...time.Duration) {
+		for i, latency := range latencies {
+			run := storage.CheckRun{CheckID: "api", Success: true, Latency: latency, OccurredAt: at.Add(time.Duration(i) * time.Minute)}
+			if err := store.RecordCheckRun(ctx, run); err != nil {
+				t.Fatalf("record run: %v", err)
+			}
+		}
+	}
+	week := 7 * 24 * time.Hour
+	seed(now.Add(-week-50*time.Minute), 10*time.Millisecond, 12*time.Millisecond, 9*time.Millisecond, 11*time.Millisecond, 10*time.Millisecond)
+	// A failed run must not count towards either median.
+	if err := store.RecordCheckRun(ctx, storage.CheckRun{CheckID: "api", Latency: 5 * time.Second, OccurredAt: now.Add(-40 * time.Minute)}); err != nil {
+		t.Fatalf("record run: %v", err)
+	}
+
+	cfg := config.CheckConfig{ID: "api", LatencyChange: &config.LatencyChange{MaxIncreasePercent: 100}}
+	env := Environment{Store: store}
+	run := Result{CheckID: "api", Success: true, StartedAt: now, Latency: 30 * time.Millisecond}
+
+	res := withLatencyChange(ctx, cfg, env, run)
+	if !res.Success || res.AssertionResults[0].Message == "" {
+		t.Fatalf("expected too little current history to pass with a note, got %+v", res.AssertionResults)
+	}
+
+	seed(now.Add(-50*time.Minute), 14*time.Millisecond, 15*time.Millisecond, 16*time.Millisecond, 15*time.Millisecond)
+	res = withLatencyChange(ctx, cfg, env, run)
+	if !res.Success {
+		t.Fatalf("expected a 50%% increase within 100%% to pass, got %+v", res.AssertionResults)
+	}
+
+	seed(now.Add(-30*time.Minute), 30*time.Millisecond, 32*time.Millisecond, 31*time.Millisecond, 29*time.Millisecond)
+	res = withReason(withLatencyChange(ctx, cfg, env, run))
+	if res.Success || res.Reason != ReasonLatencyExceeded {
+		t.Fatalf("expected a doubled median to fail with latency_exceeded, got success=%v reason=%q %+v", res.Success, res.Reason, res.AssertionResults)
+	}
+	detail, ok := res.Metadata["latency_change"].(map[string]any)
+	if !ok || detail["previous_median_ms"] != 10.0 || detail["current_median_ms"] != 29.0 {
+		t.Fatalf("unexpected latency_change metadata %v", res.Metadata["latency_change"])
+	}
+}
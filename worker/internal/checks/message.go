@@ -0,0 +1,106 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// withAssertionMessages replaces the message of each failed assertion that
+// has a message_template with the rendered template. A template that fails
+// to render or renders empty keeps the built-in message; render errors are
+// listed in the message_template_errors metadata.
+func withAssertionMessages(cfg config.CheckConfig, env Environment, res Result) Result {
+	if env.TemplateEngine == nil || !hasMessageTemplate(cfg.Assertions) {
+		return res
+	}
+	used := make([]bool, len(cfg.Assertions))
+	for i := range res.AssertionResults {
+		result := &res.AssertionResults[i]
+		idx := matchAssertion(cfg.Assertions, used, *result)
+		if idx < 0 {
+			continue
+		}
+		used[idx] = true
+		assertion := cfg.Assertions[idx]
+		tmpl := strings.TrimSpace(assertion.MessageTemplate)
+		if tmpl == "" || result.Passed || result.Skipped {
+			continue
+		}
+		message, err := env.TemplateEngine.RenderString(tmpl, render.TemplateContext{
+			Secrets: env.Secrets,
+			Vars:    env.Vars,
+			Data: map[string]interface{}{
+				"check": map[string]interface{}{
+					"id":     cfg.ID,
+					"name":   cfg.Name,
+					"target": cfg.Target,
+				},
+				"labels":   cfg.Labels,
+				"kind":     result.Kind,
+				"op":       result.Op,
+				"path":     result.Path,
+				"expected": assertion.Value,
+				"actual":   result.Actual,
+				"message":  result.Message,
+			},
+		})
+		if err != nil {
+			if res.Metadata == nil {
+				res.Metadata = map[string]any{}
+			}
+			errs, _ := res.Metadata["message_template_errors"].([]string)
+			res.Metadata["message_template_errors"] = append(errs, fmt.Sprintf("%s: %v", assertionName(assertion), err))
+			continue
+		}
+		if strings.TrimSpace(message) == "" {
+			continue
+		}
+		result.Message = message
+	}
+	return res
+}
+
+// assertionName identifies an assertion in errors: its id, or else its kind
+// and path.
+func assertionName(assertion config.Assertion) string {
+	if assertion.ID != "" {
+		return assertion.ID
+	}
+	if assertion.Path != "" {
+		return assertion.Kind + " " + assertion.Path
+	}
+	return assertion.Kind
+}
+
+func hasMessageTemplate(assertions []config.Assertion) bool {
+	for _, assertion := range assertions {
+		if strings.TrimSpace(assertion.MessageTemplate) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAssertion finds the configured assertion a result came from: the one
+// with the same id, or else the first unused one of the same kind and path.
+// Results that no assertion produced, such as expect_failure, match none.
+func matchAssertion(assertions []config.Assertion, used []bool, result AssertionResult) int {
+	for i, assertion := range assertions {
+		if used[i] {
+			continue
+		}
+		if result.ID != "" {
+			if assertion.ID == result.ID {
+				return i
+			}
+			continue
+		}
+		if strings.EqualFold(assertion.Kind, result.Kind) && assertion.Path == result.Path {
+			return i
+		}
+	}
+	return -1
+}
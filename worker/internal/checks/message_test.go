@@ -0,0 +1,52 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestAssertionMessageTemplate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"status": "degraded"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:     "checkout",
+		Name:   "Checkout API",
+		Type:   "http",
+		Target: srv.URL,
+		Assertions: []config.Assertion{
+			{Kind: "status_code", Op: "equals", Value: 200, MessageTemplate: `{{ .check.name }} answered {{ .actual }} instead of {{ .expected }}`},
+			{Kind: "jsonpath", Path: "$.status", Op: "equals", Value: "ok", MessageTemplate: `{{ .check.name }} reports {{ .actual }}`},
+			{Kind: "latency_ms", Op: "less_than", Value: 5000, MessageTemplate: `{{ .actual }}ms`},
+			{Kind: "body_contains", Op: "contains", Value: "healthy", MessageTemplate: `{{ .nope.missing }`},
+		},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()})
+	if result.Success || len(result.AssertionResults) != 4 {
+		t.Fatalf("expected failing run with four assertions, got %+v", result.AssertionResults)
+	}
+	want := []string{
+		"Checkout API answered 503 instead of 200",
+		"Checkout API reports degraded",
+		"",
+		"string not found in body",
+	}
+	for i, res := range result.AssertionResults {
+		if res.Message != want[i] {
+			t.Fatalf("assertion %d: expected message %q, got %q", i, want[i], res.Message)
+		}
+	}
+	errs, _ := result.Metadata["message_template_errors"].([]string)
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "body_contains: ") {
+		t.Fatalf("expected the body_contains render error in metadata, got %v", result.Metadata["message_template_errors"])
+	}
+}
@@ -3,16 +3,15 @@ package checks
 import (
 	"context"
 	"fmt"
-	"math"
-	"sort"
 	"strings"
 	"time"
 
-	"github.com/Knetic/govaluate"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 
+	"github.com/osbits/upupup/shared/computed"
 	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/storage"
 )
 
 func runMetrics(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
@@ -30,23 +29,24 @@ func runMetrics(ctx context.Context, start time.Time, cfg config.CheckConfig, en
 
 	if env.Store == nil {
 		res.Error = fmt.Errorf("metrics store not configured")
+		res.Reason = ReasonConfigError
 		return res
 	}
 	if cfg.Metrics == nil {
 		res.Error = fmt.Errorf("metrics configuration missing")
+		res.Reason = ReasonConfigError
 		return res
 	}
 	if len(cfg.Metrics.Thresholds) == 0 {
 		res.Error = fmt.Errorf("no metrics thresholds configured")
+		res.Reason = ReasonConfigError
 		return res
 	}
 
-	nodeID := strings.TrimSpace(cfg.Metrics.NodeID)
-	if nodeID == "" {
-		nodeID = strings.TrimSpace(cfg.Target)
-	}
+	nodeID := MetricsNodeID(cfg)
 	if nodeID == "" {
 		res.Error = fmt.Errorf("node id or target is required for metrics check")
+		res.Reason = ReasonConfigError
 		return res
 	}
 	res.Metadata["node_id"] = nodeID
@@ -54,42 +54,190 @@ func runMetrics(ctx context.Context, start time.Time, cfg config.CheckConfig, en
 	snapshot, err := env.Store.LatestNodeMetrics(ctx, nodeID)
 	if err != nil {
 		res.Error = fmt.Errorf("load node metrics: %w", err)
+		res.Reason = ReasonStorageError
 		return res
 	}
 	if snapshot == nil {
+		if grace := cfg.Metrics.FirstRunGrace.Duration; grace > 0 {
+			firstSeen, seen, err := env.Store.LookupCheckFirstSeen(ctx, cfg.ID, nodeID)
+			if err != nil {
+				res.Error = fmt.Errorf("load first seen: %w", err)
+				res.Reason = ReasonStorageError
+				return res
+			}
+			if seen {
+				res.Metadata["first_seen_at"] = firstSeen
+			} else {
+				since := awaitingDataSince(env.AwaitingData, nodeID, start)
+				res.Metadata["awaiting_data_since"] = since
+				if start.Sub(since) < grace {
+					res.Success = true
+					res.Pending = true
+					return res
+				}
+			}
+		}
 		res.Error = fmt.Errorf("no metrics available for node %q", nodeID)
+		res.Reason = ReasonNoData
 		return res
 	}
+	if cfg.Metrics.FirstRunGrace.Duration > 0 && !env.ReadOnly {
+		if _, err := env.Store.CheckFirstSeen(ctx, cfg.ID, nodeID, start); err != nil {
+			res.Error = fmt.Errorf("record first seen: %w", err)
+			res.Reason = ReasonStorageError
+			return res
+		}
+		delete(env.AwaitingData, nodeID)
+	}
 	res.Metadata["ingested_at"] = snapshot.IngestedAt
+	evaluateSnapshot(&res, cfg.Metrics, *snapshot, env.BreachedSince, time.Now())
+	return res
+}
+
+// awaitingDataSince returns when the check first found no snapshot of a node
+// that has never reported, recording start on the first such run. Without a
+// map every run is the first.
+func awaitingDataSince(awaiting map[string]time.Time, nodeID string, start time.Time) time.Time {
+	if awaiting == nil {
+		return start
+	}
+	since, ok := awaiting[nodeID]
+	if !ok {
+		since = start
+		awaiting[nodeID] = start
+	}
+	return since
+}
 
-	if cfg.Metrics.MaxAge != nil && cfg.Metrics.MaxAge.Set {
-		maxAge := cfg.Metrics.MaxAge.Duration
-		if snapshot.IngestedAt.IsZero() || time.Since(snapshot.IngestedAt) > maxAge {
-			res.AssertionResults = append(res.AssertionResults, AssertionResult{
-				Kind:    "freshness",
-				Op:      "max_age",
-				Path:    "",
-				Passed:  false,
-				Message: fmt.Sprintf("metrics older than %s", maxAge),
-			})
+// MetricsNodeID returns the node whose snapshots a metrics check reads:
+// metrics.node_id, or the target when unset.
+func MetricsNodeID(cfg config.CheckConfig) string {
+	if cfg.Metrics != nil {
+		if nodeID := strings.TrimSpace(cfg.Metrics.NodeID); nodeID != "" {
+			return nodeID
+		}
+	}
+	return strings.TrimSpace(cfg.Target)
+}
+
+// ReplayMetrics evaluates the thresholds of a metrics check against each
+// historical snapshot as if the check had run when the snapshot arrived.
+// Breaches are tracked across snapshots so `for` durations apply as they
+// would have live.
+func ReplayMetrics(cfg config.CheckConfig, snapshots []storage.NodeMetricSnapshot) ([]Result, error) {
+	if cfg.Metrics == nil {
+		return nil, fmt.Errorf("check %q is not a metrics check", cfg.ID)
+	}
+	if len(cfg.Metrics.Thresholds) == 0 {
+		return nil, fmt.Errorf("no metrics thresholds configured")
+	}
+	breachedSince := map[int]time.Time{}
+	results := make([]Result, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		res := Result{
+			CheckID:          cfg.ID,
+			CheckName:        cfg.Name,
+			StartedAt:        snapshot.IngestedAt,
+			CompletedAt:      snapshot.IngestedAt,
+			Metadata:         map[string]any{"node_id": snapshot.NodeID, "ingested_at": snapshot.IngestedAt},
+			AssertionResults: []AssertionResult{},
+		}
+		evaluateSnapshot(&res, cfg.Metrics, snapshot, breachedSince, snapshot.IngestedAt)
+		results = append(results, withReason(res))
+	}
+	return results, nil
+}
+
+// evaluateSnapshot checks freshness and thresholds of one snapshot as of now.
+func evaluateSnapshot(res *Result, metrics *config.MetricsCheck, snapshot storage.NodeMetricSnapshot, breachedSince map[int]time.Time, now time.Time) {
+	if metrics.MaxAge != nil && metrics.MaxAge.Set {
+		freshness := evaluateFreshness(snapshot.IngestedAt, metrics.MaxAge.Duration, now)
+		if !freshness.Passed && staleIsFatal(metrics) {
+			res.AssertionResults = append(res.AssertionResults, freshness)
 			res.Success = false
-			return res
+			res.Reason = ReasonStaleData
+			return
 		}
+		freshness.Warning = !freshness.Passed
+		res.AssertionResults = append(res.AssertionResults, freshness)
 	}
 
 	families, err := parseMetricFamilies(snapshot.Payload)
 	if err != nil {
 		res.Error = fmt.Errorf("parse metrics payload: %w", err)
-		return res
+		res.Reason = ReasonNoData
+		return
 	}
 
-	computedCache := make(map[string]computedMetricResult)
-	for _, threshold := range cfg.Metrics.Thresholds {
-		assertion := evaluateMetricThreshold(families, cfg.Metrics.Computed, computedCache, threshold)
+	evaluator := computed.NewEvaluator(families, metrics.Computed)
+	severity := ""
+	for idx, threshold := range metrics.Thresholds {
+		assertion := evaluateMetricThreshold(families, metrics.Computed, evaluator, threshold)
+		holdBreach(&assertion, threshold, breachedSince, idx, now)
 		res.AssertionResults = append(res.AssertionResults, assertion)
+		if !assertion.Passed && !assertion.Warning {
+			severity = maxSeverity(severity, thresholdSeverity(threshold))
+		}
+	}
+	if values, _ := evaluator.Values(); len(values) > 0 {
+		res.Metadata["computed"] = values
 	}
 	res.Success = allPassed(res.AssertionResults)
-	return res
+	if !res.Success {
+		res.Reason = ReasonThresholdBreached
+		res.Severity = severity
+	}
+}
+
+// holdBreach turns a breach that has not yet lasted the threshold's `for`
+// duration into a warning and tracks when the breach started.
+func holdBreach(assertion *AssertionResult, threshold config.MetricThreshold, since map[int]time.Time, idx int, now time.Time) {
+	if since == nil {
+		return
+	}
+	if assertion.Passed {
+		delete(since, idx)
+		return
+	}
+	if threshold.For.Duration <= 0 {
+		return
+	}
+	first, ok := since[idx]
+	if !ok {
+		first = now
+		since[idx] = now
+	}
+	if held := now.Sub(first); held < threshold.For.Duration {
+		assertion.Warning = true
+		assertion.Message = fmt.Sprintf("%s (breached for %s of %s)", assertion.Message, held.Truncate(time.Second), threshold.For.Duration)
+	}
+}
+
+func thresholdSeverity(threshold config.MetricThreshold) string {
+	if threshold.Severity == "" {
+		return SeverityCritical
+	}
+	return strings.ToLower(threshold.Severity)
+}
+
+func maxSeverity(a, b string) string {
+	if SeverityRank(b) > SeverityRank(a) {
+		return b
+	}
+	return a
+}
+
+func evaluateFreshness(ingestedAt time.Time, maxAge time.Duration, now time.Time) AssertionResult {
+	result := AssertionResult{Kind: "freshness", Op: "max_age", Passed: true}
+	if ingestedAt.IsZero() || now.Sub(ingestedAt) > maxAge {
+		result.Passed = false
+		result.Message = fmt.Sprintf("metrics older than %s", maxAge)
+	}
+	return result
+}
+
+func staleIsFatal(metrics *config.MetricsCheck) bool {
+	return metrics.StaleFatal == nil || *metrics.StaleFatal
 }
 
 func parseMetricFamilies(payload string) (map[string]*dto.MetricFamily, error) {
@@ -102,18 +250,13 @@ func parseMetricFamilies(payload string) (map[string]*dto.MetricFamily, error) {
 	return families, nil
 }
 
-type computedMetricResult struct {
-	value float64
-	err   error
-}
-
 func evaluateMetricThreshold(
 	families map[string]*dto.MetricFamily,
-	computed map[string]config.ComputedMetric,
-	cache map[string]computedMetricResult,
+	specs map[string]config.ComputedMetric,
+	evaluator *computed.Evaluator,
 	threshold config.MetricThreshold,
 ) AssertionResult {
-	labelPath := formatLabelSet(threshold.Labels)
+	labelPath := computed.FormatLabels(threshold.Labels)
 	result := AssertionResult{
 		Kind: threshold.Name,
 		Op:   threshold.Op,
@@ -124,20 +267,25 @@ func evaluateMetricThreshold(
 		result.Message = "metric name is required"
 		return result
 	}
+	if SeverityRank(thresholdSeverity(threshold)) == 0 {
+		result.Passed = false
+		result.Message = fmt.Sprintf("unknown severity %q", threshold.Severity)
+		return result
+	}
 
-	if spec, ok := computed[threshold.Name]; ok {
-		if len(spec.Labels) > 0 && !labelsEqual(spec.Labels, threshold.Labels) {
+	if spec, ok := specs[threshold.Name]; ok {
+		if len(spec.Labels) > 0 && !computed.LabelsEqual(spec.Labels, threshold.Labels) {
 			result.Passed = false
 			result.Message = "threshold labels do not match computed metric labels"
 			return result
 		}
-		compResult := resolveComputedMetric(threshold.Name, families, computed, cache)
-		if compResult.err != nil {
+		value, err := evaluator.Value(threshold.Name)
+		if err != nil {
 			result.Passed = false
-			result.Message = compResult.err.Error()
+			result.Message = err.Error()
 			return result
 		}
-		return evaluateNumericThreshold(result, compResult.value, threshold)
+		return evaluateNumericThreshold(result, value, threshold)
 	}
 
 	family, ok := families[threshold.Name]
@@ -146,7 +294,7 @@ func evaluateMetricThreshold(
 		result.Message = "metric not found"
 		return result
 	}
-	value, found, err := findMetricValue(family, threshold.Labels)
+	value, found, err := computed.SeriesValue(family, threshold.Labels)
 	if err != nil {
 		result.Passed = false
 		result.Message = err.Error()
@@ -169,205 +317,3 @@ func evaluateNumericThreshold(result AssertionResult, value float64, threshold c
 	result.Message = fmt.Sprintf("value %.4f not %s %.4f", value, threshold.Op, threshold.Value)
 	return result
 }
-
-func resolveComputedMetric(
-	name string,
-	families map[string]*dto.MetricFamily,
-	computed map[string]config.ComputedMetric,
-	cache map[string]computedMetricResult,
-) computedMetricResult {
-	if cached, ok := cache[name]; ok {
-		return cached
-	}
-	spec, ok := computed[name]
-	if !ok {
-		res := computedMetricResult{err: fmt.Errorf("computed metric %q not defined", name)}
-		cache[name] = res
-		return res
-	}
-
-	exprStr := strings.TrimSpace(spec.Expression)
-	if exprStr == "" {
-		res := computedMetricResult{err: fmt.Errorf("computed metric %q missing expression", name)}
-		cache[name] = res
-		return res
-	}
-	if len(spec.Variables) == 0 {
-		res := computedMetricResult{err: fmt.Errorf("computed metric %q has no variables", name)}
-		cache[name] = res
-		return res
-	}
-
-	vars := make(map[string]interface{}, len(spec.Variables))
-	for varName, ref := range spec.Variables {
-		if strings.TrimSpace(varName) == "" {
-			res := computedMetricResult{err: fmt.Errorf("computed metric %q has empty variable name", name)}
-			cache[name] = res
-			return res
-		}
-		val, err := resolveMetricReference(families, ref)
-		if err != nil {
-			res := computedMetricResult{err: fmt.Errorf("variable %q: %w", varName, err)}
-			cache[name] = res
-			return res
-		}
-		vars[varName] = val
-	}
-
-	expr, err := govaluate.NewEvaluableExpression(exprStr)
-	if err != nil {
-		res := computedMetricResult{err: fmt.Errorf("parse expression: %w", err)}
-		cache[name] = res
-		return res
-	}
-	value, err := expr.Evaluate(vars)
-	if err != nil {
-		res := computedMetricResult{err: fmt.Errorf("evaluate expression: %w", err)}
-		cache[name] = res
-		return res
-	}
-	floatVal, ok := toFloat64(value)
-	if !ok || math.IsNaN(floatVal) || math.IsInf(floatVal, 0) {
-		res := computedMetricResult{err: fmt.Errorf("expression result is not a finite number")}
-		cache[name] = res
-		return res
-	}
-	res := computedMetricResult{value: floatVal}
-	cache[name] = res
-	return res
-}
-
-func resolveMetricReference(families map[string]*dto.MetricFamily, ref config.MetricReference) (float64, error) {
-	if strings.TrimSpace(ref.Name) == "" {
-		return 0, fmt.Errorf("metric name is required")
-	}
-	family, ok := families[ref.Name]
-	if !ok {
-		if ref.Default != nil {
-			return *ref.Default, nil
-		}
-		return 0, fmt.Errorf("metric %q not found", ref.Name)
-	}
-	value, found, err := findMetricValue(family, ref.Labels)
-	if err != nil {
-		return 0, err
-	}
-	if !found {
-		if ref.Default != nil {
-			return *ref.Default, nil
-		}
-		return 0, fmt.Errorf("no series matched labels %s", formatLabelSet(ref.Labels))
-	}
-	return value, nil
-}
-
-func labelsEqual(a, b map[string]string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for key, val := range a {
-		if b[key] != val {
-			return false
-		}
-	}
-	return true
-}
-
-func toFloat64(value interface{}) (float64, bool) {
-	switch v := value.(type) {
-	case float64:
-		return v, true
-	case float32:
-		return float64(v), true
-	case int:
-		return float64(v), true
-	case int64:
-		return float64(v), true
-	case int32:
-		return float64(v), true
-	case int16:
-		return float64(v), true
-	case int8:
-		return float64(v), true
-	case uint:
-		return float64(v), true
-	case uint64:
-		return float64(v), true
-	case uint32:
-		return float64(v), true
-	case uint16:
-		return float64(v), true
-	case uint8:
-		return float64(v), true
-	default:
-		return 0, false
-	}
-}
-
-func findMetricValue(family *dto.MetricFamily, labels map[string]string) (float64, bool, error) {
-	if family == nil {
-		return 0, false, nil
-	}
-	for _, metric := range family.Metric {
-		if !labelsMatch(metric, labels) {
-			continue
-		}
-		switch family.GetType() {
-		case dto.MetricType_COUNTER:
-			if metric.Counter == nil {
-				return 0, false, fmt.Errorf("metric missing counter value")
-			}
-			return metric.Counter.GetValue(), true, nil
-		case dto.MetricType_GAUGE:
-			if metric.Gauge == nil {
-				return 0, false, fmt.Errorf("metric missing gauge value")
-			}
-			return metric.Gauge.GetValue(), true, nil
-		case dto.MetricType_UNTYPED:
-			if metric.Untyped == nil {
-				return 0, false, fmt.Errorf("metric missing untyped value")
-			}
-			return metric.Untyped.GetValue(), true, nil
-		default:
-			return 0, false, fmt.Errorf("unsupported metric type %s", family.GetType().String())
-		}
-	}
-	return 0, false, nil
-}
-
-func labelsMatch(metric *dto.Metric, expected map[string]string) bool {
-	if len(expected) == 0 {
-		return true
-	}
-	for key, value := range expected {
-		if !metricHasLabel(metric, key, value) {
-			return false
-		}
-	}
-	return true
-}
-
-func metricHasLabel(metric *dto.Metric, key, value string) bool {
-	for _, labelPair := range metric.Label {
-		if labelPair.GetName() == key && labelPair.GetValue() == value {
-			return true
-		}
-	}
-	return false
-}
-
-func formatLabelSet(labels map[string]string) string {
-	if len(labels) == 0 {
-		return ""
-	}
-	keys := make([]string, 0, len(labels))
-	for key := range labels {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	parts := make([]string, 0, len(keys))
-	for _, key := range keys {
-		parts = append(parts, fmt.Sprintf(`%s="%s"`, key, labels[key]))
-	}
-	return "{" + strings.Join(parts, ",") + "}"
-}
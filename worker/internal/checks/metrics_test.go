@@ -2,6 +2,7 @@ package checks
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -324,3 +325,444 @@ node_filesystem_avail_bytes{mountpoint="/"} 10
 		t.Fatalf("expected computed metric assertion failure")
 	}
 }
+
+func TestRunMetricsStaleDataNonFatal(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	payload := `node_load1{instance="node-a"} 0.5
+node_load5{instance="node-a"} 2.5
+`
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    payload,
+		IngestedAt: time.Now().Add(-10 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+
+	staleFatal := false
+	cfg := config.CheckConfig{
+		ID:   "metrics-stale-warn",
+		Name: "Metrics Stale Warning",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{
+			NodeID: "node-a",
+			MaxAge: &config.NullableDuration{
+				Duration: time.Minute,
+				Set:      true,
+			},
+			StaleFatal: &staleFatal,
+			Thresholds: []config.MetricThreshold{
+				{
+					Name:  "node_load1",
+					Op:    "<",
+					Value: 1.0,
+				},
+			},
+		},
+	}
+
+	env := Environment{
+		Defaults: config.ServiceDefault{},
+		Store:    store,
+	}
+
+	result := Execute(context.Background(), cfg, env)
+	if !result.Success {
+		t.Fatalf("expected stale data to only warn, got %+v", result.AssertionResults)
+	}
+	if len(result.AssertionResults) != 2 {
+		t.Fatalf("expected freshness and threshold results, got %+v", result.AssertionResults)
+	}
+	freshness := result.AssertionResults[0]
+	if freshness.Kind != "freshness" || freshness.Passed || !freshness.Warning {
+		t.Fatalf("expected freshness warning, got %+v", freshness)
+	}
+	if !result.AssertionResults[1].Passed {
+		t.Fatalf("expected threshold to be evaluated and pass, got %+v", result.AssertionResults[1])
+	}
+
+	cfg.Metrics.Thresholds = append(cfg.Metrics.Thresholds, config.MetricThreshold{
+		Name:  "node_load5",
+		Op:    "<",
+		Value: 1.0,
+	})
+	result = Execute(context.Background(), cfg, env)
+	if result.Success {
+		t.Fatalf("expected threshold failure despite non-fatal staleness")
+	}
+	if len(result.AssertionResults) != 3 || result.AssertionResults[2].Passed {
+		t.Fatalf("expected failing node_load5 threshold, got %+v", result.AssertionResults)
+	}
+}
+
+func TestRunMetricsComputedChain(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	payload := `node_filesystem_size_bytes{mountpoint="/"} 100
+node_filesystem_avail_bytes{mountpoint="/"} 5
+`
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    payload,
+		IngestedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+
+	cfg := config.CheckConfig{
+		ID:   "metrics-computed-chain",
+		Name: "Metrics Computed Chain",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{
+			NodeID: "node-a",
+			Computed: map[string]config.ComputedMetric{
+				"disk_usage_percent": {
+					Expression: "((size - avail) / size) * 100",
+					Variables: map[string]config.MetricReference{
+						"size":  {Name: "node_filesystem_size_bytes", Labels: map[string]string{"mountpoint": "/"}},
+						"avail": {Name: "node_filesystem_avail_bytes", Labels: map[string]string{"mountpoint": "/"}},
+					},
+				},
+				"disk_pressure": {
+					Expression: "usage > 90 ? 1 : 0",
+					Variables: map[string]config.MetricReference{
+						"usage": {Name: "disk_usage_percent"},
+					},
+				},
+			},
+			Thresholds: []config.MetricThreshold{
+				{Name: "disk_pressure", Op: "equals", Value: 1},
+				{Name: "disk_usage_percent", Op: "greater_than", Value: 90},
+			},
+		},
+	}
+
+	env := Environment{
+		Defaults: config.ServiceDefault{},
+		Store:    store,
+	}
+
+	result := Execute(context.Background(), cfg, env)
+	if !result.Success {
+		t.Fatalf("expected layered computed metrics to pass, got %+v", result.AssertionResults)
+	}
+	values, ok := result.Metadata["computed"].(map[string]float64)
+	if !ok || values["disk_usage_percent"] != 95 || values["disk_pressure"] != 1 {
+		t.Fatalf("expected computed values in metadata, got %#v", result.Metadata["computed"])
+	}
+}
+
+func TestRunMetricsComputedCycle(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    "node_load1 0.5\n",
+		IngestedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+
+	cfg := config.CheckConfig{
+		ID:   "metrics-computed-cycle",
+		Name: "Metrics Computed Cycle",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{
+			NodeID: "node-a",
+			Computed: map[string]config.ComputedMetric{
+				"a": {
+					Expression: "b + load",
+					Variables: map[string]config.MetricReference{
+						"b":    {Name: "b"},
+						"load": {Name: "node_load1"},
+					},
+				},
+				"b": {
+					Expression: "a * 2",
+					Variables: map[string]config.MetricReference{
+						"a": {Name: "a"},
+					},
+				},
+			},
+			Thresholds: []config.MetricThreshold{
+				{Name: "a", Op: "<", Value: 10},
+				{Name: "b", Op: "<", Value: 10},
+			},
+		},
+	}
+
+	env := Environment{
+		Defaults: config.ServiceDefault{},
+		Store:    store,
+	}
+
+	result := Execute(context.Background(), cfg, env)
+	if result.Success {
+		t.Fatalf("expected cycle to fail the check")
+	}
+	if result.Error != nil {
+		t.Fatalf("expected cycle reported through assertions, got error %v", result.Error)
+	}
+	for _, assertion := range result.AssertionResults {
+		if assertion.Passed || !strings.Contains(assertion.Message, "computed metric cycle: a -> b -> a") {
+			t.Fatalf("expected cycle message, got %+v", assertion)
+		}
+	}
+}
+
+func TestRunMetricsReportsWorstBreachedSeverity(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    "disk_usage_root 85\nnode_load1 3\n",
+		IngestedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+	env := Environment{Store: store}
+
+	cases := []struct {
+		name       string
+		thresholds []config.MetricThreshold
+		want       string
+	}{
+		{
+			name: "warning and critical breached",
+			thresholds: []config.MetricThreshold{
+				{Name: "disk_usage_root", Op: "<", Value: 80, Severity: "warning"},
+				{Name: "node_load1", Op: "<", Value: 2, Severity: "critical"},
+			},
+			want: SeverityCritical,
+		},
+		{
+			name: "only warning breached",
+			thresholds: []config.MetricThreshold{
+				{Name: "disk_usage_root", Op: "<", Value: 80, Severity: "warning"},
+				{Name: "node_load1", Op: "<", Value: 5},
+			},
+			want: SeverityWarning,
+		},
+		{
+			name: "default severity is critical",
+			thresholds: []config.MetricThreshold{
+				{Name: "disk_usage_root", Op: "<", Value: 80},
+			},
+			want: SeverityCritical,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.CheckConfig{
+				ID:      "metrics-severity",
+				Type:    "metrics",
+				Metrics: &config.MetricsCheck{NodeID: "node-a", Thresholds: tc.thresholds},
+			}
+			result := Execute(context.Background(), cfg, env)
+			if result.Success {
+				t.Fatalf("expected failure")
+			}
+			if result.Severity != tc.want {
+				t.Fatalf("expected severity %q, got %q", tc.want, result.Severity)
+			}
+		})
+	}
+}
+
+func TestRunMetricsRejectsUnknownSeverity(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    "node_load1 0.5\n",
+		IngestedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+	cfg := config.CheckConfig{
+		ID:   "metrics-severity",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{NodeID: "node-a", Thresholds: []config.MetricThreshold{
+			{Name: "node_load1", Op: "<", Value: 1, Severity: "page"},
+		}},
+	}
+	result := Execute(context.Background(), cfg, Environment{Store: store})
+	if result.Success {
+		t.Fatalf("expected unknown severity to fail the check")
+	}
+	if msg := result.AssertionResults[0].Message; !strings.Contains(msg, "unknown severity") {
+		t.Fatalf("expected unknown severity message, got %q", msg)
+	}
+}
+
+func TestRunMetricsHoldsBreachUntilForElapses(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    "node_load1 3\n",
+		IngestedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+	cfg := config.CheckConfig{
+		ID:   "metrics-for",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{NodeID: "node-a", Thresholds: []config.MetricThreshold{
+			{Name: "node_load1", Op: "<", Value: 2, For: config.Duration{Duration: 5 * time.Minute}},
+		}},
+	}
+	breaches := map[int]time.Time{}
+	env := Environment{Store: store, BreachedSince: breaches}
+
+	result := Execute(context.Background(), cfg, env)
+	if !result.Success {
+		t.Fatalf("expected a fresh breach to be held, got %+v", result.AssertionResults)
+	}
+	if !result.AssertionResults[0].Warning {
+		t.Fatalf("expected held breach reported as warning")
+	}
+	if breaches[0].IsZero() {
+		t.Fatalf("expected first breach time recorded")
+	}
+
+	breaches[0] = time.Now().Add(-6 * time.Minute)
+	result = Execute(context.Background(), cfg, env)
+	if result.Success || result.Reason != ReasonThresholdBreached {
+		t.Fatalf("expected sustained breach to fail, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
+
+func TestRunMetricsFirstRunGrace(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	check := func(id, nodeID string) config.CheckConfig {
+		return config.CheckConfig{
+			ID:   id,
+			Type: "metrics",
+			Metrics: &config.MetricsCheck{
+				NodeID:        nodeID,
+				FirstRunGrace: config.Duration{Duration: 10 * time.Minute},
+				Thresholds:    []config.MetricThreshold{{Name: "node_load1", Op: "<", Value: 1}},
+			},
+		}
+	}
+
+	awaiting := map[string]time.Time{}
+	env := Environment{Store: store, AwaitingData: awaiting}
+	for i := 0; i < 2; i++ {
+		result := Execute(context.Background(), check("fresh", "node-new"), env)
+		if !result.Success || !result.Pending || result.Reason != "" {
+			t.Fatalf("run %d: expected pending within grace, got success=%v pending=%v reason=%q err=%v", i, result.Success, result.Pending, result.Reason, result.Error)
+		}
+	}
+	if _, seen, err := store.LookupCheckFirstSeen(context.Background(), "fresh", "node-new"); err != nil || seen {
+		t.Fatalf("expected no first seen without a snapshot, got seen=%v err=%v", seen, err)
+	}
+
+	awaiting["node-new"] = time.Now().Add(-11 * time.Minute)
+	result := Execute(context.Background(), check("fresh", "node-new"), env)
+	if result.Success || result.Pending {
+		t.Fatalf("expected failure after grace, got success=%v pending=%v", result.Success, result.Pending)
+	}
+	if result.Reason != ReasonNoData {
+		t.Fatalf("expected reason %q, got %q", ReasonNoData, result.Reason)
+	}
+}
+
+func TestRunMetricsFirstRunGraceEndsOnceNodeReported(t *testing.T) {
+	store, err := storage.Open(":memory:", storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	err = store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    "node_load1 0.5\n",
+		IngestedAt: time.Now(),
+		SourceIP:   "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+
+	check := config.CheckConfig{
+		ID:   "reported",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{
+			NodeID:        "node-a",
+			FirstRunGrace: config.Duration{Duration: 10 * time.Minute},
+			Thresholds:    []config.MetricThreshold{{Name: "node_load1", Op: "<", Value: 1}},
+		},
+	}
+	awaiting := map[string]time.Time{"node-a": time.Now()}
+	env := Environment{Store: store, AwaitingData: awaiting}
+	if result := Execute(context.Background(), check, env); !result.Success || result.Pending {
+		t.Fatalf("expected success with a snapshot, got success=%v pending=%v err=%v", result.Success, result.Pending, result.Error)
+	}
+	if _, seen, err := store.LookupCheckFirstSeen(context.Background(), "reported", "node-a"); err != nil || !seen {
+		t.Fatalf("expected first seen recorded once a snapshot was found, got seen=%v err=%v", seen, err)
+	}
+	if _, ok := awaiting["node-a"]; ok {
+		t.Fatalf("expected the grace start to be cleared once the node reported")
+	}
+
+	// A node that has reported before gets no grace when its snapshot is
+	// missing, even within first_run_grace of the first run.
+	check.Metrics.NodeID = "node-gone"
+	if _, err := store.CheckFirstSeen(context.Background(), "reported", "node-gone", time.Now()); err != nil {
+		t.Fatalf("seed first seen: %v", err)
+	}
+	result := Execute(context.Background(), check, env)
+	if result.Success || result.Pending || result.Reason != ReasonNoData {
+		t.Fatalf("expected no_data failure for a node that reported before, got success=%v pending=%v reason=%q", result.Success, result.Pending, result.Reason)
+	}
+}
@@ -0,0 +1,178 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// OCSP statuses reported in the ocsp_status metadata.
+const (
+	OCSPGood    = "good"
+	OCSPRevoked = "revoked"
+	OCSPUnknown = "unknown"
+)
+
+// maxOCSPResponseSize bounds the responder body read by ocspStatus.
+const maxOCSPResponseSize = 1 << 20
+
+// ocspResult describes the revocation status of the leaf certificate.
+type ocspResult struct {
+	Status string
+	// Source is "stapled" or "responder".
+	Source string
+	Err    error
+}
+
+// ocspStatus determines the revocation status of the leaf certificate from
+// the stapled OCSP response, or by querying the responder named in the
+// certificate when nothing was stapled. Failures report OCSPUnknown with Err
+// set.
+func ocspStatus(ctx context.Context, cfg config.CheckConfig, env Environment, state tls.ConnectionState) ocspResult {
+	if len(state.PeerCertificates) == 0 {
+		return ocspResult{Status: OCSPUnknown, Err: errors.New("no peer certificates")}
+	}
+	leaf := state.PeerCertificates[0]
+	issuer := ocspIssuer(state)
+	if issuer == nil {
+		return ocspResult{Status: OCSPUnknown, Err: errors.New("issuer certificate not presented")}
+	}
+
+	if len(state.OCSPResponse) > 0 {
+		resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, leaf, issuer)
+		if err != nil {
+			return ocspResult{Status: OCSPUnknown, Source: "stapled", Err: fmt.Errorf("parse stapled ocsp response: %w", err)}
+		}
+		return ocspResult{Status: ocspStatusName(resp.Status), Source: "stapled"}
+	}
+
+	if len(leaf.OCSPServer) == 0 {
+		return ocspResult{Status: OCSPUnknown, Err: errors.New("certificate has no ocsp responder")}
+	}
+	client, err := ocspClient(cfg, env)
+	if err != nil {
+		return ocspResult{Status: OCSPUnknown, Source: "responder", Err: err}
+	}
+	resp, err := queryOCSP(ctx, client, leaf.OCSPServer[0], leaf, issuer)
+	if err != nil {
+		return ocspResult{Status: OCSPUnknown, Source: "responder", Err: err}
+	}
+	return ocspResult{Status: ocspStatusName(resp.Status), Source: "responder"}
+}
+
+// ocspClient returns the client that queries OCSP responders. The check's
+// proxy settings apply, but not env.HttpClient: in a pool run it is pinned to
+// the backend being checked, and the responder is another host.
+func ocspClient(cfg config.CheckConfig, env Environment) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+	if strings.TrimSpace(cfg.Proxy) != "" {
+		proxyURL, err := resolveProxyURL(cfg.Proxy, env)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxyFunc(proxyURL, cfg.NoProxy)
+	}
+	return &http.Client{Timeout: EffectiveTimeout(cfg, env.Defaults), Transport: transport}, nil
+}
+
+// ocspIssuer returns the certificate that signed the leaf, preferring the
+// verified chain over the certificates the peer sent.
+func ocspIssuer(state tls.ConnectionState) *x509.Certificate {
+	for _, chain := range state.VerifiedChains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	if len(state.PeerCertificates) > 1 {
+		return state.PeerCertificates[1]
+	}
+	return nil
+}
+
+func queryOCSP(ctx context.Context, client *http.Client, server string, leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	body, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build ocsp request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build ocsp request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query ocsp responder: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp responder returned status %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("read ocsp response: %w", err)
+	}
+	parsed, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("parse ocsp response: %w", err)
+	}
+	return parsed, nil
+}
+
+func ocspStatusName(status int) string {
+	switch status {
+	case ocsp.Good:
+		return OCSPGood
+	case ocsp.Revoked:
+		return OCSPRevoked
+	default:
+		return OCSPUnknown
+	}
+}
+
+// evaluateNotRevoked fails revoked certificates, and unknown ones unless the
+// assertion value is "allow_unknown".
+func evaluateNotRevoked(result AssertionResult, status ocspResult, value any) AssertionResult {
+	allowUnknown := strings.EqualFold(strings.TrimSpace(fmt.Sprintf("%v", value)), "allow_unknown")
+	switch status.Status {
+	case OCSPGood:
+		result.Passed = true
+	case OCSPRevoked:
+		result.Passed = false
+		result.Message = "certificate revoked"
+	default:
+		result.Passed = allowUnknown
+		if !result.Passed {
+			result.Message = "ocsp status unknown"
+			if status.Err != nil {
+				result.Message = fmt.Sprintf("ocsp status unknown: %v", status.Err)
+			}
+		}
+	}
+	return result
+}
+
+// recordOCSP adds the OCSP status to the result metadata.
+func recordOCSP(res *Result, status ocspResult) {
+	if res.Metadata == nil {
+		res.Metadata = map[string]any{}
+	}
+	res.Metadata["ocsp_status"] = status.Status
+	if status.Source != "" {
+		res.Metadata["ocsp_source"] = status.Source
+	}
+	if status.Err != nil {
+		res.Metadata["ocsp_error"] = status.Err.Error()
+	}
+}
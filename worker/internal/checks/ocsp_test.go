@@ -0,0 +1,232 @@
+package checks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+type testPKI struct {
+	ca      *x509.Certificate
+	caKey   crypto.Signer
+	leaf    *x509.Certificate
+	leafKey crypto.Signer
+}
+
+func newTestPKI(t *testing.T, ocspServer string) testPKI {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate leaf key: %v", err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ocspServer != "" {
+		leafTemplate.OCSPServer = []string{ocspServer}
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, leafKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("create leaf: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+	return testPKI{ca: ca, caKey: caKey, leaf: leaf, leafKey: leafKey}
+}
+
+func (p testPKI) ocspResponse(t *testing.T, status int) []byte {
+	t.Helper()
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: p.leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	if status == ocsp.Revoked {
+		template.RevokedAt = time.Now().Add(-time.Minute)
+	}
+	raw, err := ocsp.CreateResponse(p.ca, p.ca, template, p.caKey)
+	if err != nil {
+		t.Fatalf("create ocsp response: %v", err)
+	}
+	return raw
+}
+
+// startOCSPTLSServer serves the PKI's leaf certificate, stapling the given
+// OCSP response when it is non-empty, and returns a client trusting the CA.
+func startOCSPTLSServer(t *testing.T, pki testPKI, staple []byte) (string, *http.Client) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{pki.leaf.Raw, pki.ca.Raw},
+		PrivateKey:  pki.leafKey,
+		OCSPStaple:  staple,
+	}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+	}
+	return srv.URL, client
+}
+
+func runRevocationCheck(t *testing.T, url string, client *http.Client, value any) Result {
+	t.Helper()
+	cfg := config.CheckConfig{
+		ID:     "ocsp",
+		Type:   "https",
+		Target: url,
+		Assertions: []config.Assertion{
+			{Kind: "ssl_not_revoked", Value: value},
+		},
+	}
+	return Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+}
+
+func TestSSLNotRevokedUsesStapledResponse(t *testing.T) {
+	pki := newTestPKI(t, "")
+
+	url, client := startOCSPTLSServer(t, pki, pki.ocspResponse(t, ocsp.Good))
+	result := runRevocationCheck(t, url, client, true)
+	if !result.Success {
+		t.Fatalf("expected good certificate to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if result.Metadata["ocsp_status"] != OCSPGood || result.Metadata["ocsp_source"] != "stapled" {
+		t.Fatalf("unexpected ocsp metadata: %v", result.Metadata)
+	}
+
+	url, client = startOCSPTLSServer(t, pki, pki.ocspResponse(t, ocsp.Revoked))
+	result = runRevocationCheck(t, url, client, true)
+	if result.Success {
+		t.Fatalf("expected revoked certificate to fail")
+	}
+	if result.Reason != ReasonTLSRevoked {
+		t.Fatalf("expected reason %q, got %q", ReasonTLSRevoked, result.Reason)
+	}
+	if result.Metadata["ocsp_status"] != OCSPRevoked {
+		t.Fatalf("unexpected ocsp metadata: %v", result.Metadata)
+	}
+}
+
+func TestSSLNotRevokedQueriesResponder(t *testing.T) {
+	var response []byte
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil || r.Method != http.MethodPost {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if response == nil {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(response)
+	}))
+	t.Cleanup(responder.Close)
+
+	pki := newTestPKI(t, responder.URL)
+	url, client := startOCSPTLSServer(t, pki, nil)
+
+	response = pki.ocspResponse(t, ocsp.Revoked)
+	result := runRevocationCheck(t, url, client, true)
+	if result.Success || result.Reason != ReasonTLSRevoked {
+		t.Fatalf("expected revoked failure, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if result.Metadata["ocsp_source"] != "responder" {
+		t.Fatalf("unexpected ocsp metadata: %v", result.Metadata)
+	}
+
+	response = nil
+	result = runRevocationCheck(t, url, client, true)
+	if result.Success {
+		t.Fatalf("expected unknown status to fail by default")
+	}
+	if result.Metadata["ocsp_status"] != OCSPUnknown || result.Reason != ReasonTLSError {
+		t.Fatalf("unexpected result: reason=%q metadata=%v", result.Reason, result.Metadata)
+	}
+
+	result = runRevocationCheck(t, url, client, "allow_unknown")
+	if !result.Success {
+		t.Fatalf("expected allow_unknown to pass, got %+v", result.AssertionResults)
+	}
+}
+
+func TestSSLNotRevokedQueriesResponderThroughCheckProxy(t *testing.T) {
+	var pki testPKI
+	var proxied []string
+	// The proxy answers the responder request itself; the responder host
+	// does not resolve, so only a proxied request can succeed.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(pki.ocspResponse(t, ocsp.Good))
+	}))
+	t.Cleanup(proxy.Close)
+
+	pki = newTestPKI(t, "http://ocsp.invalid/")
+	url, client := startOCSPTLSServer(t, pki, nil)
+	cfg := config.CheckConfig{
+		ID:      "ocsp",
+		Type:    "https",
+		Target:  url,
+		Proxy:   proxy.URL,
+		NoProxy: []string{"127.0.0.1"},
+		Assertions: []config.Assertion{
+			{Kind: "ssl_not_revoked", Value: true},
+		},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+	if !result.Success {
+		t.Fatalf("expected good status via the proxy, got %v %v", result.Error, result.Metadata)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://ocsp.invalid/" {
+		t.Fatalf("expected one proxied responder request, got %v", proxied)
+	}
+}
@@ -0,0 +1,190 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// runPool runs an HTTP check once per backend, keeping the configured URL,
+// Host header and SNI while dialling each backend address directly.
+func runPool(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:   cfg.ID,
+		CheckName: cfg.Name,
+		StartedAt: start,
+		Metadata:  map[string]any{},
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "http", "https":
+	default:
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("pool is not supported for check type %q", cfg.Type)
+		res.Reason = ReasonConfigError
+		return res
+	}
+	if protocol, _ := httpProtocol(cfg); protocol == ProtocolHTTP3 {
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("pool is not supported with protocol %s", protocol)
+		res.Reason = ReasonConfigError
+		return res
+	}
+
+	backends, err := poolBackends(ctx, cfg)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("resolve pool: %w", err)
+		res.Reason = ReasonDNSError
+		return res
+	}
+
+	timeout := EffectiveTimeout(cfg, env.Defaults)
+	results := make([]Result, len(backends))
+	var wg sync.WaitGroup
+	for idx, backend := range backends {
+		wg.Add(1)
+		go func(idx int, backend string) {
+			defer wg.Done()
+			transport := pinnedTransport(backend, timeout)
+			defer transport.CloseIdleConnections()
+			backendEnv := env
+			backendEnv.HttpClient = &http.Client{Timeout: timeout, Transport: transport}
+			results[idx] = withReason(runHTTP(ctx, time.Now(), cfg, backendEnv))
+		}(idx, backend)
+	}
+	wg.Wait()
+
+	healthy := 0
+	summaries := make([]map[string]any, 0, len(backends))
+	for idx, backend := range backends {
+		backendRes := results[idx]
+		target := TargetResult{
+			Target:  backend,
+			Success: backendRes.Success,
+			Latency: backendRes.Latency,
+			Reason:  backendRes.Reason,
+		}
+		if backendRes.Error != nil {
+			target.Error = backendRes.Error.Error()
+		} else if !backendRes.Success {
+			target.Error = summarizeFailedAssertions(backendRes.AssertionResults)
+		}
+		if target.Success {
+			healthy++
+		}
+		if backendRes.Latency > res.Latency {
+			res.Latency = backendRes.Latency
+		}
+		if backendRes.RetryAfter.After(res.RetryAfter) {
+			res.RetryAfter = backendRes.RetryAfter
+		}
+		res.Targets = append(res.Targets, target)
+		summary := map[string]any{
+			"target":     backend,
+			"success":    target.Success,
+			"latency_ms": float64(target.Latency) / float64(time.Millisecond),
+		}
+		if target.Error != "" {
+			summary["error"] = target.Error
+		}
+		summaries = append(summaries, summary)
+	}
+
+	required := cfg.Pool.NHealthy
+	if required <= 0 || required > len(backends) {
+		required = len(backends)
+	}
+	assertion := AssertionResult{Kind: "pool", Op: "n_healthy", Passed: healthy >= required}
+	if !assertion.Passed {
+		assertion.Message = fmt.Sprintf("%d of %d backends healthy, need %d", healthy, len(backends), required)
+	}
+	res.AssertionResults = []AssertionResult{assertion}
+	res.Metadata["pool_targets"] = summaries
+	res.Metadata["pool_healthy"] = healthy
+	res.Metadata["pool_total"] = len(backends)
+	res.Success = assertion.Passed
+	res.CompletedAt = time.Now()
+	return res
+}
+
+// poolBackends lists the configured backend addresses followed by the
+// addresses of the URL host when ResolveAll is set.
+func poolBackends(ctx context.Context, cfg config.CheckConfig) ([]string, error) {
+	seen := map[string]bool{}
+	var backends []string
+	add := func(addr string) {
+		addr = strings.TrimSpace(addr)
+		if addr == "" || seen[addr] {
+			return
+		}
+		seen[addr] = true
+		backends = append(backends, addr)
+	}
+	for _, target := range cfg.Pool.Targets {
+		add(target)
+	}
+	if cfg.Pool.ResolveAll {
+		u, err := url.Parse(effectiveRequestURL(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("parse target url: %w", err)
+		}
+		if u.Hostname() == "" {
+			return nil, errors.New("target url has no host to resolve")
+		}
+		addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			add(addr)
+		}
+	}
+	if len(backends) == 0 {
+		return nil, errors.New("no pool targets configured")
+	}
+	return backends, nil
+}
+
+// pinnedTransport dials backend for every request. A backend without a port
+// uses the port of the requested URL.
+func pinnedTransport(backend string, timeout time.Duration) *http.Transport {
+	dialer := &net.Dialer{Timeout: timeout}
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			host, backendPort, err := net.SplitHostPort(backend)
+			if err != nil {
+				host, backendPort = strings.Trim(backend, "[]"), port
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(host, backendPort))
+		},
+		TLSHandshakeTimeout: timeout,
+		DisableKeepAlives:   true,
+	}
+}
+
+func summarizeFailedAssertions(results []AssertionResult) string {
+	var failed []string
+	for _, result := range results {
+		if result.Passed || result.Warning {
+			continue
+		}
+		if result.Message != "" {
+			failed = append(failed, result.Message)
+		} else {
+			failed = append(failed, fmt.Sprintf("%s %s failed", result.Kind, result.Op))
+		}
+	}
+	return strings.Join(failed, "; ")
+}
@@ -0,0 +1,89 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func startPoolBackends(t *testing.T, statuses ...int) []string {
+	t.Helper()
+	addrs := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		status := status
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Host != "pool.example.test" {
+				w.WriteHeader(http.StatusMisdirectedRequest)
+				return
+			}
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		addrs = append(addrs, strings.TrimPrefix(srv.URL, "http://"))
+	}
+	return addrs
+}
+
+func poolCheck(targets []string, nHealthy int) config.CheckConfig {
+	return config.CheckConfig{
+		ID:         "pool",
+		Type:       "http",
+		Target:     "http://pool.example.test/health",
+		Pool:       &config.PoolConfig{Targets: targets, NHealthy: nHealthy},
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+}
+
+func TestRunPoolToleratesUnhealthyBackends(t *testing.T) {
+	targets := startPoolBackends(t, http.StatusOK, http.StatusOK)
+	targets = append(targets, fmt.Sprintf("127.0.0.1:%d", closedPort(t)))
+
+	result := Execute(context.Background(), poolCheck(targets, 2), Environment{TemplateEngine: render.New()})
+	if !result.Success {
+		t.Fatalf("expected pool with 2 of 3 healthy to pass, got %+v", result.AssertionResults)
+	}
+	if len(result.Targets) != 3 {
+		t.Fatalf("expected 3 target results, got %d", len(result.Targets))
+	}
+	for idx, target := range result.Targets {
+		if target.Target != targets[idx] {
+			t.Fatalf("expected target %d to be %s, got %s", idx, targets[idx], target.Target)
+		}
+	}
+	if !result.Targets[0].Success || !result.Targets[1].Success {
+		t.Fatalf("expected live backends healthy, got %+v", result.Targets)
+	}
+	if result.Targets[2].Success || result.Targets[2].Error == "" {
+		t.Fatalf("expected closed backend to report an error, got %+v", result.Targets[2])
+	}
+	if result.Metadata["pool_healthy"] != 2 || result.Metadata["pool_total"] != 3 {
+		t.Fatalf("unexpected pool metadata: %+v", result.Metadata)
+	}
+}
+
+func TestRunPoolFailsBelowThreshold(t *testing.T) {
+	targets := startPoolBackends(t, http.StatusOK, http.StatusServiceUnavailable)
+	targets = append(targets, fmt.Sprintf("127.0.0.1:%d", closedPort(t)))
+
+	result := Execute(context.Background(), poolCheck(targets, 2), Environment{TemplateEngine: render.New()})
+	if result.Success {
+		t.Fatalf("expected pool with 1 of 3 healthy to fail")
+	}
+	if len(result.AssertionResults) != 1 || !strings.Contains(result.AssertionResults[0].Message, "1 of 3 backends healthy") {
+		t.Fatalf("unexpected assertion results: %+v", result.AssertionResults)
+	}
+	if result.Targets[1].Error == "" {
+		t.Fatalf("expected failed assertion message for 503 backend")
+	}
+
+	result = Execute(context.Background(), poolCheck(targets[:1], 0), Environment{TemplateEngine: render.New()})
+	if !result.Success {
+		t.Fatalf("expected single healthy backend to satisfy default threshold, got %+v", result.AssertionResults)
+	}
+}
@@ -0,0 +1,97 @@
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// resolveProxyURL renders the proxy template, which may pull credentials
+// from secrets, and parses the result. Errors never include the rendered URL.
+func resolveProxyURL(raw string, env Environment) (*url.URL, error) {
+	engine := env.TemplateEngine
+	if engine == nil {
+		engine = render.New()
+	}
+	rendered, err := engine.RenderString(raw, render.TemplateContext{Secrets: env.Secrets, Vars: env.Vars})
+	if err != nil {
+		return nil, errors.New("render proxy: invalid template")
+	}
+	proxyURL, err := url.Parse(strings.TrimSpace(rendered))
+	if err != nil || proxyURL.Host == "" {
+		return nil, errors.New("invalid proxy url")
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	return proxyURL, nil
+}
+
+// proxyFunc routes requests through proxyURL unless the target host matches
+// the no-proxy list.
+func proxyFunc(proxyURL *url.URL, noProxy []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// bypassProxy matches host against NO_PROXY style entries: "*", IPs, CIDR
+// ranges and domains, where "example.com" and ".example.com" both cover the
+// domain and its subdomains.
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactProxyError strips proxy credentials from transport errors before they
+// reach results, notifications and logs.
+func redactProxyError(err error, proxyURL *url.URL) error {
+	if err == nil || proxyURL == nil || proxyURL.User == nil {
+		return err
+	}
+	msg := err.Error()
+	redacted := strings.ReplaceAll(msg, proxyURL.String(), proxyURL.Redacted())
+	if password, ok := proxyURL.User.Password(); ok && password != "" {
+		redacted = strings.ReplaceAll(redacted, password, "xxxxx")
+	}
+	if redacted == msg {
+		return err
+	}
+	return errors.New(redacted)
+}
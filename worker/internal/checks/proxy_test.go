@@ -0,0 +1,126 @@
+package checks
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func newStubProxy(t *testing.T, hits *atomic.Int32, auth *atomic.Value) *httptest.Server {
+	t.Helper()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		auth.Store(r.Header.Get("Proxy-Authorization"))
+		_, _ = w.Write([]byte("via-proxy " + r.URL.Host))
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestRunHTTPAppliesAuthenticatedProxy(t *testing.T) {
+	var hits atomic.Int32
+	var auth atomic.Value
+	proxy := newStubProxy(t, &hits, &auth)
+
+	cfg := config.CheckConfig{
+		ID:         "proxied",
+		Type:       "http",
+		Target:     "http://backend.example.test/health",
+		Proxy:      "http://probe:{{ secret \"PROXY_PASSWORD\" }}@" + strings.TrimPrefix(proxy.URL, "http://"),
+		Assertions: []config.Assertion{{Kind: "body_contains", Op: "contains", Value: "via-proxy backend.example.test"}},
+	}
+	env := Environment{
+		Secrets:        map[string]string{"PROXY_PASSWORD": "s3cret"},
+		TemplateEngine: render.New(),
+		HttpClient:     &http.Client{},
+	}
+	result := Execute(context.Background(), cfg, env)
+	if !result.Success {
+		t.Fatalf("expected request through proxy to succeed, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected 1 proxy hit, got %d", hits.Load())
+	}
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("probe:s3cret"))
+	if got, _ := auth.Load().(string); got != want {
+		t.Fatalf("expected Proxy-Authorization %q, got %q", want, got)
+	}
+}
+
+func TestRunHTTPNoProxyBypassesProxy(t *testing.T) {
+	var hits atomic.Int32
+	var auth atomic.Value
+	proxy := newStubProxy(t, &hits, &auth)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	t.Cleanup(backend.Close)
+
+	cfg := config.CheckConfig{
+		ID:         "bypass",
+		Type:       "http",
+		Target:     backend.URL,
+		Proxy:      proxy.URL,
+		NoProxy:    []string{"internal.example.test", "127.0.0.0/8"},
+		Assertions: []config.Assertion{{Kind: "body_contains", Op: "contains", Value: "direct"}},
+	}
+	env := Environment{TemplateEngine: render.New(), HttpClient: &http.Client{}}
+	result := Execute(context.Background(), cfg, env)
+	if !result.Success {
+		t.Fatalf("expected direct request to succeed, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if hits.Load() != 0 {
+		t.Fatalf("expected no_proxy host to bypass the proxy, got %d hits", hits.Load())
+	}
+}
+
+func TestRunHTTPRedactsProxyCredentialsFromErrors(t *testing.T) {
+	cfg := config.CheckConfig{
+		ID:     "unreachable-proxy",
+		Type:   "http",
+		Target: "http://backend.example.test/",
+		Proxy:  "http://probe:{{ secret \"PROXY_PASSWORD\" }}@127.0.0.1:" + fmt.Sprint(closedPort(t)),
+	}
+	env := Environment{
+		Secrets:        map[string]string{"PROXY_PASSWORD": "s3cret"},
+		TemplateEngine: render.New(),
+		HttpClient:     &http.Client{},
+	}
+	result := Execute(context.Background(), cfg, env)
+	if result.Error == nil {
+		t.Fatalf("expected unreachable proxy to fail the check")
+	}
+	if strings.Contains(result.Error.Error(), "s3cret") {
+		t.Fatalf("expected proxy password to be redacted, got %q", result.Error)
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := []string{"example.com", ".internal", "10.0.0.0/8", "::1"}
+	cases := map[string]bool{
+		"example.com":     true,
+		"api.example.com": true,
+		"notexample.com":  false,
+		"db.internal":     true,
+		"10.1.2.3":        true,
+		"11.1.2.3":        false,
+		"::1":             true,
+		"other.test":      false,
+	}
+	for host, want := range cases {
+		if got := bypassProxy(host, noProxy); got != want {
+			t.Errorf("bypassProxy(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if !bypassProxy("anything.test", []string{"*"}) {
+		t.Errorf("expected * to bypass every host")
+	}
+}
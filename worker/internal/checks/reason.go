@@ -0,0 +1,172 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Reason codes classify why a check failed so notifications and dashboards
+// can route on them instead of parsing summaries.
+const (
+	ReasonConfigError       = "config_error"
+	ReasonTimeout           = "timeout"
+	ReasonConnectionRefused = "connection_refused"
+	ReasonConnectionError   = "connection_error"
+	ReasonDNSError          = "dns_error"
+	ReasonDNSMismatch       = "dns_mismatch"
+	ReasonTLSError          = "tls_error"
+	ReasonTLSExpired        = "tls_expired"
+	ReasonTLSExpiring       = "tls_expiring"
+	ReasonTLSHostname       = "tls_hostname_mismatch"
+	ReasonTLSRevoked        = "tls_revoked"
+	ReasonHTTPSNotEnforced  = "https_not_enforced"
+	ReasonPreAuthFailed     = "preauth_failed"
+	ReasonStatusMismatch    = "status_mismatch"
+	ReasonBodyMismatch      = "body_mismatch"
+	ReasonLatencyExceeded   = "latency_exceeded"
+	ReasonBaselineDeviation = "baseline_deviation"
+	ReasonPacketLoss        = "packet_loss"
+	ReasonWHOISError        = "whois_error"
+	ReasonDomainExpiring    = "domain_expiring"
+	ReasonPoolDegraded      = "pool_degraded"
+	ReasonGroupDegraded     = "group_degraded"
+	ReasonStorageError      = "storage_error"
+	ReasonNoData            = "no_data"
+	ReasonStaleData         = "stale_data"
+	ReasonObjectMissing     = "object_missing"
+	ReasonThresholdBreached = "threshold_breached"
+	ReasonCommandFailed     = "command_failed"
+	ReasonAssertionFailed   = "assertion_failed"
+	ReasonUnexpectedSuccess = "unexpected_success"
+	ReasonError             = "error"
+)
+
+// withReason fills in the reason of a failed result that the runner branch
+// did not classify itself and clears it on success.
+func withReason(res Result) Result {
+	if res.Success {
+		res.Reason = ""
+		return res
+	}
+	if res.Reason != "" {
+		return res
+	}
+	if res.Error != nil {
+		res.Reason = reasonForError(res.Error)
+		return res
+	}
+	for _, assertion := range res.AssertionResults {
+		if !assertion.Passed && !assertion.Warning {
+			res.Reason = reasonForAssertion(assertion.Kind)
+			return res
+		}
+	}
+	res.Reason = ReasonError
+	return res
+}
+
+// reasonForError maps transport errors onto reason codes.
+func reasonForError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &invalidCert) {
+		if invalidCert.Reason == x509.Expired {
+			return ReasonTLSExpired
+		}
+		return ReasonTLSError
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return ReasonTLSHostname
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &verifyErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) {
+		return ReasonTLSError
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ReasonTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ReasonTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ReasonDNSError
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ReasonConnectionRefused
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, syscall.ECONNRESET) {
+		return ReasonConnectionError
+	}
+	if strings.Contains(err.Error(), "tls: ") {
+		return ReasonTLSError
+	}
+	return ReasonError
+}
+
+// reasonForAssertion maps a failed assertion kind onto a reason code. Metric
+// thresholds are named after the metric, so runMetrics classifies its own
+// failures.
+func reasonForAssertion(kind string) string {
+	switch strings.ToLower(kind) {
+	case "status_code", "status_class", "diff_status", "grpc_status":
+		return ReasonStatusMismatch
+	case "body_contains", "jsonpath", "json_schema", "diff_body", "diff_jsonpath":
+		return ReasonBodyMismatch
+	case "latency_ms", "latency_ms_p95", "latency_change", "dns_ms", "connect_ms", "tls_handshake_ms", "ttfb_ms":
+		return ReasonLatencyExceeded
+	case "baseline_deviation":
+		return ReasonBaselineDeviation
+	case "dns_query":
+		return ReasonDNSError
+	case "packet_loss_percent", "v4_reachable", "v6_reachable":
+		return ReasonPacketLoss
+	case "tcp_connect", "connection_refused":
+		return ReasonConnectionError
+	case "dns_answer", "ttl_seconds", "dns_flag", "dns_rcode":
+		return ReasonDNSMismatch
+	case "ssl_valid_days":
+		return ReasonTLSExpiring
+	case "ssl_hostname_matches":
+		return ReasonTLSHostname
+	case "ssl_not_revoked":
+		return ReasonTLSRevoked
+	case "ssl_issuer", "tls_resumed":
+		return ReasonTLSError
+	case "https_enforced":
+		return ReasonHTTPSNotEnforced
+	case "domain_expires_in_days":
+		return ReasonDomainExpiring
+	case "pool":
+		return ReasonPoolDegraded
+	case "freshness", "object_age_hours":
+		return ReasonStaleData
+	case "object_exists":
+		return ReasonObjectMissing
+	case "failure_count", "failure_ratio", "success_count", "run_count":
+		return ReasonThresholdBreached
+	case "exit_code":
+		return ReasonCommandFailed
+	case "udp_responded":
+		return ReasonTimeout
+	case "expect_failure":
+		return ReasonUnexpectedSuccess
+	case "stdout_contains", "stderr_contains", "udp_response_contains":
+		return ReasonBodyMismatch
+	default:
+		return ReasonAssertionFailed
+	}
+}
@@ -0,0 +1,120 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestExecuteSetsFailureReason(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("maintenance"))
+	}))
+	t.Cleanup(srv.Close)
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(tlsSrv.Close)
+	closed := fmt.Sprintf("127.0.0.1:%d", closedPort(t))
+	short := &config.CheckSchedule{Timeout: &config.NullableDuration{Duration: 50 * time.Millisecond, Set: true}}
+
+	cases := []struct {
+		name string
+		cfg  config.CheckConfig
+		want string
+	}{
+		{
+			name: "status mismatch",
+			cfg:  config.CheckConfig{Type: "http", Target: srv.URL, Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}}},
+			want: ReasonStatusMismatch,
+		},
+		{
+			name: "body mismatch",
+			cfg:  config.CheckConfig{Type: "http", Target: srv.URL, Assertions: []config.Assertion{{Kind: "body_contains", Op: "contains", Value: "ok"}}},
+			want: ReasonBodyMismatch,
+		},
+		{
+			name: "latency exceeded",
+			cfg:  config.CheckConfig{Type: "http", Target: srv.URL, Assertions: []config.Assertion{{Kind: "latency_ms", Op: "less_than", Value: 0}}},
+			want: ReasonLatencyExceeded,
+		},
+		{
+			name: "timeout",
+			cfg:  config.CheckConfig{Type: "http", Target: srv.URL + "/slow", Schedule: short},
+			want: ReasonTimeout,
+		},
+		{
+			name: "connection refused",
+			cfg:  config.CheckConfig{Type: "tcp", Target: closed},
+			want: ReasonConnectionRefused,
+		},
+		{
+			name: "dns error",
+			cfg:  config.CheckConfig{Type: "dns", Target: "example.com", RecordType: "A", Resolver: closed},
+			want: ReasonDNSError,
+		},
+		{
+			name: "tls error",
+			cfg:  config.CheckConfig{Type: "tls", Target: strings.TrimPrefix(tlsSrv.URL, "https://")},
+			want: ReasonTLSError,
+		},
+		{
+			name: "template error",
+			cfg:  config.CheckConfig{Type: "http", Target: "{{ .missing"},
+			want: ReasonConfigError,
+		},
+		{
+			name: "unsupported type",
+			cfg:  config.CheckConfig{Type: "carrier-pigeon", Target: "loft"},
+			want: ReasonConfigError,
+		},
+		{
+			name: "history without store",
+			cfg:  config.CheckConfig{ID: "h", Type: "history", Target: "api"},
+			want: ReasonConfigError,
+		},
+	}
+	env := Environment{TemplateEngine: render.New(), HttpClient: &http.Client{}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Execute(context.Background(), tc.cfg, env)
+			if result.Success {
+				t.Fatalf("expected failure")
+			}
+			if result.Reason != tc.want {
+				t.Fatalf("expected reason %q, got %q (error %v, assertions %+v)", tc.want, result.Reason, result.Error, result.AssertionResults)
+			}
+		})
+	}
+
+	ok := Execute(context.Background(), config.CheckConfig{Type: "http", Target: srv.URL, Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 503}}}, env)
+	if !ok.Success || ok.Reason != "" {
+		t.Fatalf("expected successful run without reason, got %v %q", ok.Success, ok.Reason)
+	}
+}
+
+func TestReasonForErrorClassifiesCertificates(t *testing.T) {
+	expired := &tls.CertificateVerificationError{Err: x509.CertificateInvalidError{Reason: x509.Expired}}
+	if got := reasonForError(fmt.Errorf("get: %w", expired)); got != ReasonTLSExpired {
+		t.Fatalf("expected %q, got %q", ReasonTLSExpired, got)
+	}
+	hostname := &tls.CertificateVerificationError{Err: x509.HostnameError{Host: "example.com"}}
+	if got := reasonForError(hostname); got != ReasonTLSHostname {
+		t.Fatalf("expected %q, got %q", ReasonTLSHostname, got)
+	}
+	if got := reasonForError(errors.New("boom")); got != ReasonError {
+		t.Fatalf("expected %q, got %q", ReasonError, got)
+	}
+}
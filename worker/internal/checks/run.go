@@ -1,20 +1,25 @@
 package checks
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/go-ping/ping"
 	dnsclient "github.com/miekg/dns"
 	"github.com/oliveagle/jsonpath"
 	"github.com/osbits/upupup/worker/internal/config"
@@ -31,12 +36,35 @@ type Environment struct {
 	HttpClient     *http.Client
 	TimeLocation   *time.Location
 	Store          *storage.Store
+	// Vars seeds the template vars of a run, e.g. with active hook parameters.
+	Vars map[string]string
+	// WHOISPatterns are consulted before the built-in whois expiry parser.
+	WHOISPatterns []config.WHOISPattern
+	// BreachedSince holds when each metrics threshold, by index, was first
+	// seen breached. The runner keeps it across runs to honour `for`; when
+	// nil, thresholds fail as soon as they are breached.
+	BreachedSince map[int]time.Time
+	// AwaitingData holds, per node that has never reported, when the check
+	// first found no snapshot; first_run_grace counts from there. The runner
+	// keeps it across runs; when nil, each such run starts the grace anew.
+	AwaitingData map[string]time.Time
+	// ResetBaseline makes baseline_deviation assertions take this run's
+	// values as their new baseline, e.g. while a reset_baseline hook is
+	// active.
+	ResetBaseline bool
+	// ReadOnly keeps the run from writing to Store, e.g. in -once mode: no
+	// baseline is stored and no first-seen time is recorded.
+	ReadOnly bool
+	// Exec is the policy exec checks run under.
+	Exec config.ExecConfig
+	// Pinger sends the echo requests of icmp checks; nil uses go-ping.
+	Pinger Pinger
 }
 
 // Execute runs a check once.
 func Execute(ctx context.Context, cfg config.CheckConfig, env Environment) Result {
 	start := time.Now()
-	timeout := effectiveTimeout(cfg, env.Defaults)
+	timeout := EffectiveTimeout(cfg, env.Defaults)
 	// Align context with timeout.
 	var cancel context.CancelFunc
 	if timeout > 0 {
@@ -44,6 +72,30 @@ func Execute(ctx context.Context, cfg config.CheckConfig, env Environment) Resul
 		defer cancel()
 	}
 
+	var res Result
+	switch {
+	case cfg.Pool != nil:
+		res = runPool(ctx, start, cfg, env)
+	case cfg.TargetFromSRV != nil:
+		res = runWithSRV(ctx, start, cfg, env)
+	default:
+		res = runByType(ctx, start, cfg, env)
+	}
+	res = withLatencyChange(ctx, cfg, env, withLatency(res))
+	res = withAssertionMessages(cfg, env, res)
+	return withExpectedFailure(cfg, withReason(res))
+}
+
+// withLatency fills in the latency of runs that ended before their runner
+// measured it, e.g. on a failed handshake, so every run reports one.
+func withLatency(res Result) Result {
+	if res.Latency == 0 && !res.CompletedAt.IsZero() && !res.StartedAt.IsZero() {
+		res.Latency = res.CompletedAt.Sub(res.StartedAt)
+	}
+	return res
+}
+
+func runByType(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
 	switch strings.ToLower(cfg.Type) {
 	case "http", "https":
 		return runHTTP(ctx, start, cfg, env)
@@ -59,6 +111,18 @@ func Execute(ctx context.Context, cfg config.CheckConfig, env Environment) Resul
 		return runWHOIS(ctx, start, cfg, env)
 	case "metrics":
 		return runMetrics(ctx, start, cfg, env)
+	case "history":
+		return runHistory(ctx, start, cfg, env)
+	case "diff":
+		return runDiff(ctx, start, cfg, env)
+	case "s3":
+		return runS3(ctx, start, cfg, env)
+	case "exec":
+		return runExec(ctx, start, cfg, env)
+	case "grpc":
+		return runGRPC(ctx, start, cfg, env)
+	case "udp":
+		return runUDP(ctx, start, cfg, env)
 	default:
 		return Result{
 			CheckID:     cfg.ID,
@@ -66,12 +130,15 @@ func Execute(ctx context.Context, cfg config.CheckConfig, env Environment) Resul
 			StartedAt:   start,
 			CompletedAt: time.Now(),
 			Error:       fmt.Errorf("unsupported check type %q", cfg.Type),
+			Reason:      ReasonConfigError,
 			Success:     false,
 		}
 	}
 }
 
-func effectiveTimeout(cfg config.CheckConfig, defaults config.ServiceDefault) time.Duration {
+// EffectiveTimeout returns the request timeout of a check, falling back to
+// the schedule timeout and then the service default.
+func EffectiveTimeout(cfg config.CheckConfig, defaults config.ServiceDefault) time.Duration {
 	if cfg.Request != nil && cfg.Request.Timeout != nil && cfg.Request.Timeout.Set {
 		return cfg.Request.Timeout.Duration
 	}
@@ -89,105 +156,27 @@ func effectiveRequestURL(cfg config.CheckConfig) string {
 }
 
 func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
-	res := Result{
-		CheckID:   cfg.ID,
-		CheckName: cfg.Name,
-		StartedAt: start,
-	}
-	client := env.HttpClient
-	if client == nil {
-		client = &http.Client{Timeout: effectiveTimeout(cfg, env.Defaults)}
-	}
-
-	vars := map[string]string{}
-	// Pre-authentication
-	if cfg.PreAuth != nil {
-		if err := executePreAuth(ctx, cfg, env, vars, client); err != nil {
-			res.CompletedAt = time.Now()
-			res.Error = fmt.Errorf("preauth failed: %w", err)
-			return res
-		}
-	}
-
-	target := effectiveRequestURL(cfg)
-	reqMethod := "GET"
-	if cfg.Request != nil && cfg.Request.Method != "" {
-		reqMethod = cfg.Request.Method
-	}
-
-	templateData := map[string]interface{}{
-		"vars":   vars,
-		"labels": cfg.Labels,
-		"check": map[string]interface{}{
-			"id":     cfg.ID,
-			"name":   cfg.Name,
-			"target": cfg.Target,
-		},
-	}
-	renderCtx := render.TemplateContext{
-		Secrets: env.Secrets,
-		Vars:    vars,
-		Data:    templateData,
-	}
-
-	targetRendered, err := env.TemplateEngine.RenderString(target, renderCtx)
-	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Error = fmt.Errorf("render target: %w", err)
+	res, fetched := fetchHTTP(ctx, start, cfg, env)
+	if fetched == nil {
 		return res
 	}
-	req, err := http.NewRequestWithContext(ctx, reqMethod, targetRendered, nil)
-	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Error = fmt.Errorf("build request: %w", err)
-		return res
+	resp, bodyBytes, timing := fetched.resp, fetched.body, fetched.timing
+	var revocation ocspResult
+	if resp.TLS != nil && hasAssertion(cfg.Assertions, "ssl_not_revoked") {
+		revocation = ocspStatus(ctx, cfg, env, *resp.TLS)
+		recordOCSP(&res, revocation)
 	}
 
-	if cfg.Request != nil {
-		if len(cfg.Request.Headers) > 0 {
-			headers, err := render.RenderMap(cfg.Request.Headers, renderCtx, env.TemplateEngine)
-			if err != nil {
-				res.CompletedAt = time.Now()
-				res.Error = fmt.Errorf("render headers: %w", err)
-				return res
-			}
-			for k, v := range headers {
-				req.Header.Set(k, v)
-			}
-		}
-		if cfg.Request.Body != "" {
-			bodyRendered, err := env.TemplateEngine.RenderString(cfg.Request.Body, renderCtx)
-			if err != nil {
-				res.CompletedAt = time.Now()
-				res.Error = fmt.Errorf("render body: %w", err)
-				return res
-			}
-			req.Body = io.NopCloser(strings.NewReader(bodyRendered))
-			req.ContentLength = int64(len(bodyRendered))
+	bodyString := string(bodyBytes)
+	if maintenanceResponse(cfg.ExpectedMaintenanceStatus, resp.StatusCode, bodyString) {
+		if res.Metadata == nil {
+			res.Metadata = map[string]any{}
 		}
-	}
-
-	runStart := time.Now()
-	resp, err := client.Do(req)
-	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Latency = time.Since(runStart)
-		res.Error = err
-		res.Success = false
+		res.Metadata["maintenance"] = true
+		res.Maintenance = true
+		res.Success = true
 		return res
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Error = fmt.Errorf("read response: %w", err)
-		return res
-	}
-
-	res.Latency = time.Since(runStart)
-	res.CompletedAt = time.Now()
-
-	bodyString := string(bodyBytes)
 
 	// Precompute JSON body if required
 	var jsonBody interface{}
@@ -195,25 +184,64 @@ func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env E
 	var parsed bool
 
 	assertions := make([]AssertionResult, 0, len(cfg.Assertions))
+	var baselines []int
+	// byID holds the evaluated assertions that when conditions can refer to.
+	byID := map[string]AssertionResult{}
 
 	for _, assertion := range cfg.Assertions {
 		result := AssertionResult{
+			ID:   assertion.ID,
 			Kind: assertion.Kind,
 			Op:   assertion.Op,
 			Path: assertion.Path,
 		}
+		if assertion.When != nil {
+			holds, unmet, err := conditionHolds(assertion.When, resp.StatusCode, byID)
+			if err != nil {
+				result.Message = err.Error()
+				assertions = append(assertions, result)
+				continue
+			}
+			if !holds {
+				result = skippedResult(result, unmet)
+				assertions = append(assertions, result)
+				if assertion.ID != "" {
+					byID[assertion.ID] = result
+				}
+				continue
+			}
+		}
 		switch strings.ToLower(assertion.Kind) {
+		case "baseline_deviation":
+			// Evaluated below, once the other assertions are known.
+			baselines = append(baselines, len(assertions))
 		case "status_code":
 			expect, _ := toFloat(assertion.Value)
 			actual := float64(resp.StatusCode)
+			result.Actual = resp.StatusCode
 			result.Passed = compareFloats(actual, expect, assertion.Op)
 			if !result.Passed {
 				result.Message = fmt.Sprintf("expected status %s %.0f, got %.0f", assertion.Op, expect, actual)
 			}
+		case "status_class":
+			class := fmt.Sprintf("%v", assertion.Value)
+			matched, err := statusInClass(resp.StatusCode, class)
+			if err != nil {
+				result.Passed = false
+				result.Message = err.Error()
+				break
+			}
+			result.Passed = matched
+			if strings.EqualFold(assertion.Op, "not_equals") {
+				result.Passed = !matched
+			}
+			if !result.Passed {
+				result.Message = fmt.Sprintf("expected status class %s %s, got %d", assertion.Op, class, resp.StatusCode)
+			}
 		case "jsonpath":
 			if !parsed {
 				parsed = true
-				jsonErr = json.Unmarshal(bodyBytes, &jsonBody)
+				jsonBody, jsonErr = decodeJSON(bodyBytes, cfg.Request)
 			}
 			if jsonErr != nil {
 				result.Passed = false
@@ -221,6 +249,7 @@ func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env E
 				break
 			}
 			val, err := jsonpath.JsonPathLookup(jsonBody, assertion.Path)
+			result.Actual = val
 			if err != nil {
 				result.Passed = false
 				result.Message = fmt.Sprintf("jsonpath lookup: %v", err)
@@ -236,6 +265,17 @@ func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env E
 					result.Message = fmt.Sprintf("jsonpath value mismatch: got %v", val)
 				}
 			}
+		case "json_schema":
+			if !parsed {
+				parsed = true
+				jsonBody, jsonErr = decodeJSON(bodyBytes, cfg.Request)
+			}
+			if jsonErr != nil {
+				result.Passed = false
+				result.Message = fmt.Sprintf("parse json: %v", jsonErr)
+				break
+			}
+			result = evaluateJSONSchema(result, assertion, jsonBody)
 		case "body_contains":
 			expect := fmt.Sprintf("%v", assertion.Value)
 			switch strings.ToLower(assertion.Op) {
@@ -260,11 +300,21 @@ func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env E
 				result.Message = fmt.Sprintf("unsupported op %q", assertion.Op)
 			}
 		case "latency_ms":
+			result = evaluateLatency(assertion, res.Latency)
+		case "dns_ms", "connect_ms", "tls_handshake_ms", "ttfb_ms":
+			kind := strings.ToLower(assertion.Kind)
+			d, ok := timing.phase(kind)
+			if !ok {
+				result.Passed = false
+				result.Message = fmt.Sprintf("%s not observed (reused connection, IP target or no tls)", strings.TrimSuffix(kind, "_ms"))
+				break
+			}
 			expect, _ := toFloat(assertion.Value)
-			actual := float64(res.Latency / time.Millisecond)
+			actual := durationMillis(d)
+			result.Actual = actual
 			result.Passed = compareFloats(actual, expect, assertion.Op)
 			if !result.Passed {
-				result.Message = fmt.Sprintf("latency %.2fms not %s %.2fms", actual, assertion.Op, expect)
+				result.Message = fmt.Sprintf("%s %.2fms not %s %.2fms", strings.TrimSuffix(kind, "_ms"), actual, assertion.Op, expect)
 			}
 		case "ssl_valid_days":
 			if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
@@ -274,16 +324,43 @@ func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env E
 				cert := resp.TLS.PeerCertificates[0]
 				days := time.Until(cert.NotAfter).Hours() / 24
 				expect, _ := toFloat(assertion.Value)
+				result.Actual = math.Floor(days)
 				result.Passed = compareFloats(days, expect, assertion.Op)
 				if !result.Passed {
 					result.Message = fmt.Sprintf("cert valid for %.0f days", days)
 				}
 			}
+		case "https_enforced":
+			var findings map[string]any
+			result, findings = evaluateHTTPSEnforced(result, resp, assertion.Value)
+			if res.Metadata == nil {
+				res.Metadata = map[string]any{}
+			}
+			res.Metadata["https_enforced"] = findings
+		case "ssl_not_revoked":
+			if resp.TLS == nil {
+				result.Passed = false
+				result.Message = "no tls connection"
+			} else {
+				result = evaluateNotRevoked(result, revocation, assertion.Value)
+				if !result.Passed && revocation.Status != OCSPRevoked && res.Reason == "" {
+					res.Reason = ReasonTLSError
+				}
+			}
 		default:
 			result.Passed = false
 			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
 		}
 		assertions = append(assertions, result)
+		if assertion.ID != "" && !strings.EqualFold(assertion.Kind, "baseline_deviation") {
+			byID[assertion.ID] = result
+		}
+	}
+	if len(baselines) > 0 {
+		evaluateBaselines(ctx, cfg, env, &res, assertions, baselines, map[string]float64{
+			"latency_ms":          float64(res.Latency / time.Millisecond),
+			"response_size_bytes": float64(len(bodyBytes)),
+		})
 	}
 
 	res.AssertionResults = assertions
@@ -291,6 +368,316 @@ func runHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env E
 	return res
 }
 
+// maintenanceResponse reports whether a response matches the configured
+// maintenance status and body marker.
+func maintenanceResponse(expected *config.MaintenanceStatus, status int, body string) bool {
+	if expected == nil || expected.Status == 0 || status != expected.Status {
+		return false
+	}
+	return expected.BodyContains == "" || strings.Contains(body, expected.BodyContains)
+}
+
+// httpResponse is a completed HTTP check request with its body read.
+type httpResponse struct {
+	resp *http.Response
+	body []byte
+	// timing is set when the request phases were traced.
+	timing *httpTiming
+}
+
+// fetchHTTP sends the request of an HTTP check and reads the response body.
+// The response is nil when the request failed; the Result then carries the
+// error and reason.
+func fetchHTTP(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) (Result, *httpResponse) {
+	res := Result{
+		CheckID:   cfg.ID,
+		CheckName: cfg.Name,
+		StartedAt: start,
+	}
+	client := env.HttpClient
+	if client == nil {
+		client = &http.Client{Timeout: EffectiveTimeout(cfg, env.Defaults)}
+	}
+	var proxyURL *url.URL
+	if strings.TrimSpace(cfg.Proxy) != "" {
+		var err error
+		proxyURL, err = resolveProxyURL(cfg.Proxy, env)
+		if err != nil {
+			res.CompletedAt = time.Now()
+			res.Error = err
+			res.Reason = ReasonConfigError
+			return res, nil
+		}
+	}
+	protocol, err := httpProtocol(cfg)
+	if err == nil && protocol == ProtocolHTTP3 && proxyURL != nil {
+		err = fmt.Errorf("proxy is not supported with protocol %s", protocol)
+	}
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Error = err
+		res.Reason = ReasonConfigError
+		return res, nil
+	}
+	switch {
+	case protocol == ProtocolHTTP3:
+		transport := http3Transport(client.Transport, cfg)
+		defer transport.Close()
+		override := *client
+		override.Transport = transport
+		client = &override
+	case cfg.SNI != "" || len(cfg.ALPN) > 0 || proxyURL != nil || hasPhaseTimeouts(cfg) || timingEnabled(cfg):
+		transport := checkTransport(client.Transport, cfg, proxyURL)
+		defer transport.CloseIdleConnections()
+		override := *client
+		override.Transport = transport
+		client = &override
+	}
+
+	vars := make(map[string]string, len(env.Vars))
+	for k, v := range env.Vars {
+		vars[k] = v
+	}
+	// Pre-authentication
+	if cfg.PreAuth != nil {
+		if err := executePreAuth(ctx, cfg, env, vars, client); err != nil {
+			res.CompletedAt = time.Now()
+			res.Error = fmt.Errorf("preauth failed: %w", redactProxyError(err, proxyURL))
+			res.Reason = ReasonPreAuthFailed
+			return res, nil
+		}
+	}
+
+	if !followRedirects(cfg.Request) {
+		// The client may be shared between checks, so copy it.
+		override := *client
+		override.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &override
+	}
+
+	target := effectiveRequestURL(cfg)
+	reqMethod := "GET"
+	if cfg.Request != nil && cfg.Request.Method != "" {
+		reqMethod = cfg.Request.Method
+	}
+
+	templateData := map[string]interface{}{
+		"vars":   vars,
+		"labels": cfg.Labels,
+		"check": map[string]interface{}{
+			"id":     cfg.ID,
+			"name":   cfg.Name,
+			"target": cfg.Target,
+		},
+	}
+	renderCtx := render.TemplateContext{
+		Secrets: env.Secrets,
+		Vars:    vars,
+		Data:    templateData,
+	}
+
+	targetRendered, err := env.TemplateEngine.RenderString(target, renderCtx)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("render target: %w", err)
+		res.Reason = ReasonConfigError
+		return res, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, reqMethod, targetRendered, nil)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("build request: %w", err)
+		res.Reason = ReasonConfigError
+		return res, nil
+	}
+
+	if cfg.Request != nil {
+		if err := applyAuth(req, cfg.Request.Auth, env.Secrets); err != nil {
+			res.CompletedAt = time.Now()
+			res.Error = fmt.Errorf("auth: %w", err)
+			res.Reason = ReasonConfigError
+			return res, nil
+		}
+		if len(cfg.Request.Headers) > 0 {
+			headers, err := render.RenderMap(cfg.Request.Headers, renderCtx, env.TemplateEngine)
+			if err != nil {
+				res.CompletedAt = time.Now()
+				res.Error = fmt.Errorf("render headers: %w", err)
+				res.Reason = ReasonConfigError
+				return res, nil
+			}
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+		}
+		if cfg.Request.Body != "" {
+			bodyRendered, err := env.TemplateEngine.RenderString(cfg.Request.Body, renderCtx)
+			if err != nil {
+				res.CompletedAt = time.Now()
+				res.Error = fmt.Errorf("render body: %w", err)
+				res.Reason = ReasonConfigError
+				return res, nil
+			}
+			req.Body = io.NopCloser(strings.NewReader(bodyRendered))
+			req.ContentLength = int64(len(bodyRendered))
+		}
+	}
+
+	var timing *httpTiming
+	if timingEnabled(cfg) {
+		timing = newHTTPTiming()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), timing.trace()))
+	}
+
+	runStart := time.Now()
+	if timing != nil {
+		timing.start = runStart
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Latency = time.Since(runStart)
+		res.Error = redactProxyError(err, proxyURL)
+		res.Reason = reasonForError(err)
+		res.Success = false
+		return res, nil
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := readBody(req.Context(), resp.Body)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Latency = time.Since(runStart)
+		res.Error = fmt.Errorf("read response: %w", err)
+		res.Reason = reasonForError(err)
+		return res, nil
+	}
+
+	res.Latency = time.Since(runStart)
+	res.CompletedAt = time.Now()
+	if resp.TLS != nil || protocol != "" {
+		res.Metadata = map[string]any{"http_protocol": resp.Proto}
+		if resp.TLS != nil {
+			res.Metadata["negotiated_protocol"] = resp.TLS.NegotiatedProtocol
+		}
+	}
+	if timing != nil {
+		if res.Metadata == nil {
+			res.Metadata = map[string]any{}
+		}
+		res.Metadata["timing"] = timing.metadata(res.Latency)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		res.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), res.CompletedAt)
+	}
+	return res, &httpResponse{resp: resp, body: bodyBytes, timing: timing}
+}
+
+// readBody reads a response body and closes it once ctx is done, so a body
+// that trickles in slower than the check timeout aborts the read even when
+// the transport does not watch the request context while reading. The read
+// then fails with the context error.
+func readBody(ctx context.Context, body io.ReadCloser) ([]byte, error) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = body.Close()
+	})
+	defer stop()
+	data, err := io.ReadAll(body)
+	if err != nil && ctx.Err() != nil {
+		return data, ctx.Err()
+	}
+	return data, err
+}
+
+// checkTransport clones the client's transport with the SNI, ALPN and proxy
+// settings of the check. HTTP/2 is only attempted when "h2" is requested.
+func checkTransport(rt http.RoundTripper, cfg config.CheckConfig, proxyURL *url.URL) *http.Transport {
+	base, ok := rt.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	if cfg.SNI != "" {
+		transport.TLSClientConfig.ServerName = cfg.SNI
+	}
+	if len(cfg.ALPN) > 0 {
+		transport.TLSClientConfig.NextProtos = append([]string(nil), cfg.ALPN...)
+		transport.ForceAttemptHTTP2 = slices.Contains(cfg.ALPN, "h2")
+	}
+	if proxyURL != nil {
+		transport.Proxy = proxyFunc(proxyURL, cfg.NoProxy)
+	}
+	if cfg.Request != nil {
+		if timeout := cfg.Request.ConnectTimeout.Duration; timeout > 0 {
+			transport.DialContext = dialWithTimeout(transport.DialContext, timeout)
+		}
+		if timeout := cfg.Request.TLSTimeout.Duration; timeout > 0 {
+			transport.TLSHandshakeTimeout = timeout
+		}
+	}
+	if timingEnabled(cfg) {
+		// A reused connection skips DNS, connect and TLS, so every timed run
+		// dials afresh.
+		transport.DisableKeepAlives = true
+	}
+	return transport
+}
+
+// hasPhaseTimeouts reports whether the check sets connect_timeout or
+// tls_timeout.
+func hasPhaseTimeouts(cfg config.CheckConfig) bool {
+	return cfg.Request != nil && (cfg.Request.ConnectTimeout.Duration > 0 || cfg.Request.TLSTimeout.Duration > 0)
+}
+
+// dialWithTimeout bounds every dial of dial, or of a default dialer when nil,
+// by timeout and names the phase in the error when it expires.
+func dialWithTimeout(dial func(ctx context.Context, network, addr string) (net.Conn, error), timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		conn, err := dial(dialCtx, network, addr)
+		if err != nil && ctx.Err() == nil && errors.Is(dialCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("connect timeout after %s: %w", timeout, err)
+		}
+		return conn, err
+	}
+}
+
+// statusInClass reports whether code belongs to a class written as "2xx".
+func statusInClass(code int, class string) (bool, error) {
+	class = strings.ToLower(strings.TrimSpace(class))
+	if len(class) != 3 || class[1:] != "xx" || class[0] < '1' || class[0] > '5' {
+		return false, fmt.Errorf("invalid status class %q", class)
+	}
+	return code/100 == int(class[0]-'0'), nil
+}
+
+// parseRetryAfter interprets a Retry-After header given either as delay in
+// seconds or as HTTP date. It returns the zero time when absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return at
+	}
+	return time.Time{}
+}
+
 func executePreAuth(ctx context.Context, cfg config.CheckConfig, env Environment, vars map[string]string, client *http.Client) error {
 	flow := strings.ToLower(cfg.PreAuth.Flow)
 	if flow != "http-token" {
@@ -326,6 +713,9 @@ func executePreAuth(ctx context.Context, cfg config.CheckConfig, env Environment
 	if err != nil {
 		return fmt.Errorf("build preauth request: %w", err)
 	}
+	if err := applyAuth(req, reqCfg.Auth, env.Secrets); err != nil {
+		return fmt.Errorf("preauth auth: %w", err)
+	}
 
 	if len(reqCfg.Headers) > 0 {
 		headers, err := render.RenderMap(reqCfg.Headers, renderCtx, env.TemplateEngine)
@@ -354,15 +744,15 @@ func executePreAuth(ctx context.Context, cfg config.CheckConfig, env Environment
 		return fmt.Errorf("preauth request: %w", err)
 	}
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := readBody(req.Context(), resp.Body)
 	if err != nil {
 		return fmt.Errorf("preauth read: %w", err)
 	}
 	if strings.ToLower(cfg.PreAuth.Capture.From) != "jsonpath" {
 		return fmt.Errorf("unsupported capture from %q", cfg.PreAuth.Capture.From)
 	}
-	var jsonBody interface{}
-	if err := json.Unmarshal(body, &jsonBody); err != nil {
+	jsonBody, err := decodeJSON(body, &reqCfg)
+	if err != nil {
 		return fmt.Errorf("preauth json parse: %w", err)
 	}
 	val, err := jsonpath.JsonPathLookup(jsonBody, cfg.PreAuth.Capture.Path)
@@ -374,9 +764,35 @@ func executePreAuth(ctx context.Context, cfg config.CheckConfig, env Environment
 	return nil
 }
 
+// followRedirects reports whether the request follows redirects, which it
+// does unless follow_redirects is false.
+func followRedirects(req *config.HTTPRequest) bool {
+	return req == nil || req.FollowRedirects == nil || *req.FollowRedirects
+}
+
+// decodeJSON parses a response body for jsonpath lookups, honouring the
+// size limit and number mode of the request.
+func decodeJSON(body []byte, req *config.HTTPRequest) (interface{}, error) {
+	if req != nil && req.MaxJSONBytes > 0 && int64(len(body)) > req.MaxJSONBytes {
+		return nil, fmt.Errorf("body of %d bytes exceeds max_json_bytes %d", len(body), req.MaxJSONBytes)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if req != nil && req.JSONExactNumbers {
+		decoder.UseNumber()
+	}
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+	return value, nil
+}
+
 func allPassed(results []AssertionResult) bool {
 	for _, r := range results {
-		if !r.Passed {
+		if !r.Passed && !r.Warning {
 			return false
 		}
 	}
@@ -390,39 +806,61 @@ func runTCP(ctx context.Context, start time.Time, cfg config.CheckConfig, env En
 		StartedAt: start,
 		Metadata:  map[string]any{},
 	}
-	timeout := effectiveTimeout(cfg, env.Defaults)
+	timeout := EffectiveTimeout(cfg, env.Defaults)
 	dialer := &net.Dialer{Timeout: timeout}
 	runStart := time.Now()
 	conn, err := dialer.DialContext(ctx, "tcp", cfg.Target)
-	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Latency = time.Since(runStart)
+	latency := time.Since(runStart)
+	res.CompletedAt = time.Now()
+	res.Latency = latency
+	if err != nil && !expectsConnectFailure(cfg.Assertions) {
 		res.Error = err
 		res.Success = false
 		return res
 	}
-	latency := time.Since(runStart)
-	conn.Close()
-	res.CompletedAt = time.Now()
-	res.Latency = latency
+	connected := err == nil
+	if connected {
+		conn.Close()
+	} else {
+		res.Metadata["connect_error"] = err.Error()
+	}
 
 	assertions := make([]AssertionResult, 0, len(cfg.Assertions))
 	for _, assertion := range cfg.Assertions {
 		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
 		switch strings.ToLower(assertion.Kind) {
 		case "tcp_connect":
-			expect := fmt.Sprintf("%v", assertion.Value)
-			result.Passed = strings.ToLower(expect) == "true"
+			expect := strings.ToLower(fmt.Sprintf("%v", assertion.Value)) == "true"
+			result.Passed = connected == expect
 			if !result.Passed {
-				result.Message = "connection succeeded but expectation false"
+				if connected {
+					result.Message = "connection succeeded but expectation false"
+				} else {
+					result.Message = fmt.Sprintf("connection failed: %v", err)
+				}
 			}
-		case "latency_ms":
-			expect, _ := toFloat(assertion.Value)
-			actual := float64(latency / time.Millisecond)
-			result.Passed = compareFloats(actual, expect, assertion.Op)
+		case "connection_refused":
+			// Passes only on an active refusal, so a filtered port that
+			// times out is told apart from a closed one.
+			refused := !connected && errors.Is(err, syscall.ECONNREFUSED)
+			expect := !strings.EqualFold(fmt.Sprintf("%v", assertion.Value), "false")
+			result.Passed = refused == expect
 			if !result.Passed {
-				result.Message = fmt.Sprintf("latency %.2fms not %s %.2fms", actual, assertion.Op, expect)
+				switch {
+				case connected:
+					result.Message = "connection succeeded, expected it to be refused"
+				case refused:
+					result.Message = "connection refused, expected it not to be"
+				default:
+					result.Message = fmt.Sprintf("connection failed without refusal: %v", err)
+				}
+			}
+		case "latency_ms":
+			if !connected {
+				result.Message = "no connection to measure"
+				break
 			}
+			result = evaluateLatency(assertion, latency)
 		default:
 			result.Passed = false
 			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
@@ -434,93 +872,82 @@ func runTCP(ctx context.Context, start time.Time, cfg config.CheckConfig, env En
 	return res
 }
 
-func runICMP(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+// expectsConnectFailure reports whether a tcp check asserts that the connect
+// fails, in which case a dial error is evaluated instead of ending the run.
+func expectsConnectFailure(assertions []config.Assertion) bool {
+	for _, assertion := range assertions {
+		switch strings.ToLower(assertion.Kind) {
+		case "connection_refused":
+			return true
+		case "tcp_connect":
+			if !strings.EqualFold(fmt.Sprintf("%v", assertion.Value), "true") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runDNS(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	if len(cfg.Resolvers) > 0 {
+		return runDNSResolvers(ctx, start, cfg)
+	}
 	res := Result{
 		CheckID:   cfg.ID,
 		CheckName: cfg.Name,
 		StartedAt: start,
 		Metadata:  map[string]any{},
 	}
-	pinger, err := ping.NewPinger(cfg.Target)
+	resp, err := queryDNS(ctx, cfg, cfg.Resolver)
+	res.CompletedAt = time.Now()
+	res.Latency = res.CompletedAt.Sub(start)
 	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Error = fmt.Errorf("init pinger: %w", err)
+		res.Error = err
+		res.Reason = ReasonDNSError
 		return res
 	}
-	pinger.SetPrivileged(true)
-	timeout := effectiveTimeout(cfg, env.Defaults)
-	if timeout <= 0 {
-		timeout = 5 * time.Second
+	flags := dnsFlags(resp)
+	res.Metadata["rcode"] = rcodeName(resp.Rcode)
+	res.Metadata["flags"] = flags
+	// A non-success rcode is only an error when no dns_rcode assertion
+	// expects it.
+	if resp.Rcode != dnsclient.RcodeSuccess && !hasAssertion(cfg.Assertions, "dns_rcode") {
+		res.Error = fmt.Errorf("dns error code %s", rcodeName(resp.Rcode))
+		res.Reason = ReasonDNSError
+		return res
 	}
-	pinger.Count = 3
-	pinger.Timeout = timeout
-	pinger.Run() // blocking
-	stats := pinger.Statistics()
+	res.Metadata["answer_count"] = len(resp.Answer)
 
-	res.CompletedAt = time.Now()
-	res.Latency = stats.AvgRtt
-	res.Metadata["packet_loss"] = stats.PacketLoss
-	res.Metadata["rtt_p95_ms"] = stats.AvgRtt.Seconds() * 1000 // approximate
-
-	assertions := make([]AssertionResult, 0, len(cfg.Assertions))
-	for _, assertion := range cfg.Assertions {
-		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
-		switch strings.ToLower(assertion.Kind) {
-		case "packet_loss_percent":
-			expect, _ := toFloat(assertion.Value)
-			actual := stats.PacketLoss
-			result.Passed = compareFloats(actual, expect, assertion.Op)
-			if !result.Passed {
-				result.Message = fmt.Sprintf("packet loss %.2f%% not %s %.2f", actual, assertion.Op, expect)
-			}
-		case "latency_ms_p95":
-			expect, _ := toFloat(assertion.Value)
-			actual := stats.AvgRtt.Seconds() * 1000
-			result.Passed = compareFloats(actual, expect, assertion.Op)
-			if !result.Passed {
-				result.Message = fmt.Sprintf("latency %.2fms not %s %.2f", actual, assertion.Op, expect)
-			}
-		default:
-			result.Passed = false
-			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
-		}
-		assertions = append(assertions, result)
-	}
-	res.AssertionResults = assertions
-	res.Success = allPassed(assertions)
+	res.AssertionResults = evaluateDNS(cfg.Assertions, resp, res.Latency)
+	res.Success = allPassed(res.AssertionResults)
 	return res
 }
 
-func runDNS(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
-	res := Result{
-		CheckID:   cfg.ID,
-		CheckName: cfg.Name,
-		StartedAt: start,
-		Metadata:  map[string]any{},
-	}
+// queryDNS sends the check's question to server, 8.8.8.8:53 by default.
+func queryDNS(ctx context.Context, cfg config.CheckConfig, server string) (*dnsclient.Msg, error) {
 	client := &dnsclient.Client{}
 	msg := new(dnsclient.Msg)
 	msg.SetQuestion(dnsclient.Fqdn(cfg.Target), dnsTypeFromString(cfg.RecordType))
-	server := cfg.Resolver
 	if server == "" {
 		server = "8.8.8.8:53"
 	}
 	resp, _, err := client.ExchangeContext(ctx, msg, server)
 	if err != nil {
-		res.CompletedAt = time.Now()
-		res.Error = err
-		return res
+		return nil, err
 	}
-	res.CompletedAt = time.Now()
-	if resp == nil || resp.Rcode != dnsclient.RcodeSuccess {
-		res.Error = fmt.Errorf("dns error code %d", resp.Rcode)
-		return res
+	if resp == nil {
+		return nil, fmt.Errorf("empty dns response")
 	}
-	answers := resp.Answer
-	res.Metadata["answer_count"] = len(answers)
+	return resp, nil
+}
 
-	assertions := make([]AssertionResult, 0, len(cfg.Assertions))
-	for _, assertion := range cfg.Assertions {
+// evaluateDNS runs dns assertions against a response that took latency to
+// arrive.
+func evaluateDNS(specs []config.Assertion, resp *dnsclient.Msg, latency time.Duration) []AssertionResult {
+	answers := resp.Answer
+	flags := dnsFlags(resp)
+	assertions := make([]AssertionResult, 0, len(specs))
+	for _, assertion := range specs {
 		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
 		switch strings.ToLower(assertion.Kind) {
 		case "dns_answer":
@@ -542,15 +969,19 @@ func runDNS(ctx context.Context, start time.Time, cfg config.CheckConfig, env En
 					result.Message = fmt.Sprintf("ttl %.0f not %s %.0f", actual, assertion.Op, expect)
 				}
 			}
+		case "dns_flag":
+			result = evaluateDNSFlag(result, flags, assertion.Value)
+		case "dns_rcode":
+			result = evaluateDNSRcode(result, resp.Rcode, assertion.Value, assertion.Op)
+		case "latency_ms":
+			result = evaluateLatency(assertion, latency)
 		default:
 			result.Passed = false
 			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
 		}
 		assertions = append(assertions, result)
 	}
-	res.AssertionResults = assertions
-	res.Success = allPassed(assertions)
-	return res
+	return assertions
 }
 
 func runTLS(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
@@ -559,61 +990,121 @@ func runTLS(ctx context.Context, start time.Time, cfg config.CheckConfig, env En
 		CheckName: cfg.Name,
 		StartedAt: start,
 	}
-	dialer := &net.Dialer{Timeout: effectiveTimeout(cfg, env.Defaults)}
 	host, port, err := net.SplitHostPort(cfg.Target)
 	if err != nil {
 		res.CompletedAt = time.Now()
 		res.Error = fmt.Errorf("invalid target: %w", err)
+		res.Reason = ReasonConfigError
 		return res
 	}
 	serverName := cfg.SNI
 	if serverName == "" {
 		serverName = host
 	}
-	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: serverName})
+	probes, err := tlsProbes(cfg.TLSKeyTypes)
 	if err != nil {
 		res.CompletedAt = time.Now()
 		res.Error = err
+		res.Reason = ReasonConfigError
 		return res
 	}
-	defer conn.Close()
-	state := conn.ConnectionState()
+	dialer := &net.Dialer{Timeout: EffectiveTimeout(cfg, env.Defaults)}
+	var state tls.ConnectionState
+	leaves := make([]tlsLeaf, 0, len(probes))
+	for i, probe := range probes {
+		probeState, err := probe.handshake(dialer, net.JoinHostPort(host, port), tlsBaseConfig(env, serverName, cfg.ALPN))
+		if err != nil {
+			res.CompletedAt = time.Now()
+			res.Error = err
+			return res
+		}
+		if i == 0 {
+			state = probeState
+		}
+		if len(probeState.PeerCertificates) > 0 {
+			leaves = append(leaves, tlsLeaf{keyType: probe.keyType, cert: probeState.PeerCertificates[0]})
+		}
+	}
 	res.CompletedAt = time.Now()
+	res.Latency = res.CompletedAt.Sub(start)
 	res.Metadata = map[string]any{
 		"negotiated_protocol": state.NegotiatedProtocol,
 		"cipher_suite":        tls.CipherSuiteName(state.CipherSuite),
+		"certificates":        leafMetadata(leaves),
+	}
+	var resumption tlsResumption
+	if cfg.TLSSessionCache || hasAssertion(cfg.Assertions, "tls_resumed") {
+		resumption = probeResumption(dialer, net.JoinHostPort(host, port), tlsBaseConfig(env, serverName, cfg.ALPN))
+		res.Metadata["session_resumed"] = resumption.resumed
+		if resumption.err != nil {
+			res.Metadata["session_resumption_error"] = resumption.err.Error()
+		} else {
+			res.Metadata["resumed_handshake_ms"] = resumption.handshake.Milliseconds()
+		}
+	}
+	var revocation ocspResult
+	if hasAssertion(cfg.Assertions, "ssl_not_revoked") {
+		revocation = ocspStatus(ctx, cfg, env, state)
+		recordOCSP(&res, revocation)
 	}
 	assertions := make([]AssertionResult, 0, len(cfg.Assertions))
 	for _, assertion := range cfg.Assertions {
 		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
 		switch strings.ToLower(assertion.Kind) {
 		case "ssl_valid_days":
-			if len(state.PeerCertificates) == 0 {
-				result.Passed = false
-				result.Message = "no peer certificates"
-			} else {
-				exp := state.PeerCertificates[0].NotAfter
-				days := time.Until(exp).Hours() / 24
-				expect, _ := toFloat(assertion.Value)
-				result.Passed = compareFloats(days, expect, assertion.Op)
-				if !result.Passed {
-					result.Message = fmt.Sprintf("cert expires in %.0f days", days)
+			expect, _ := toFloat(assertion.Value)
+			result = evaluateLeaves(result, leaves, func(leaf tlsLeaf) string {
+				days := time.Until(leaf.cert.NotAfter).Hours() / 24
+				if compareFloats(days, expect, assertion.Op) {
+					return ""
 				}
-			}
+				if days <= 0 && res.Reason == "" {
+					res.Reason = ReasonTLSExpired
+				}
+				return fmt.Sprintf("cert expires in %.0f days", days)
+			})
 		case "ssl_hostname_matches":
 			expect := strings.ToLower(fmt.Sprintf("%v", assertion.Value)) == "true"
-			if len(state.PeerCertificates) == 0 {
+			if len(leaves) == 0 {
 				result.Passed = !expect
 				if expect {
 					result.Message = "no certificates"
 				}
-			} else {
-				err := state.PeerCertificates[0].VerifyHostname(serverName)
-				result.Passed = (err == nil) == expect
-				if !result.Passed && expect {
-					result.Message = fmt.Sprintf("hostname verify: %v", err)
+				break
+			}
+			result = evaluateLeaves(result, leaves, func(leaf tlsLeaf) string {
+				err := leaf.cert.VerifyHostname(serverName)
+				if (err == nil) == expect {
+					return ""
 				}
+				if !expect {
+					return "hostname unexpectedly matches"
+				}
+				return fmt.Sprintf("hostname verify: %v", err)
+			})
+		case "ssl_issuer":
+			expect := fmt.Sprintf("%v", assertion.Value)
+			result = evaluateLeaves(result, leaves, func(leaf tlsLeaf) string {
+				return evaluateIssuer(leaf.cert, expect, assertion.Op)
+			})
+		case "ssl_not_revoked":
+			result = evaluateNotRevoked(result, revocation, assertion.Value)
+			if !result.Passed && revocation.Status != OCSPRevoked && res.Reason == "" {
+				res.Reason = ReasonTLSError
+			}
+		case "tls_resumed":
+			expect := strings.ToLower(fmt.Sprintf("%v", assertion.Value)) != "false"
+			result.Passed = resumption.err == nil && resumption.resumed == expect
+			switch {
+			case resumption.err != nil:
+				result.Message = resumption.err.Error()
+			case !result.Passed && expect:
+				result.Message = "server did not resume the session"
+			case !result.Passed:
+				result.Message = "server unexpectedly resumed the session"
 			}
+		case "latency_ms":
+			result = evaluateLatency(assertion, res.Latency)
 		default:
 			result.Passed = false
 			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
@@ -632,13 +1123,16 @@ func runWHOIS(ctx context.Context, start time.Time, cfg config.CheckConfig, env
 		StartedAt: start,
 	}
 	domain := cfg.Target
-	server, err := whoisServerForDomain(domain)
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	patterns := whoisPatternsFor(env.WHOISPatterns, suffix)
+	server, err := whoisServerForDomain(domain, patterns)
 	if err != nil {
 		res.CompletedAt = time.Now()
 		res.Error = err
+		res.Reason = ReasonWHOISError
 		return res
 	}
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(server, "43"), effectiveTimeout(cfg, env.Defaults))
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(server, "43"), EffectiveTimeout(cfg, env.Defaults))
 	if err != nil {
 		res.CompletedAt = time.Now()
 		res.Error = fmt.Errorf("dial whois: %w", err)
@@ -649,6 +1143,7 @@ func runWHOIS(ctx context.Context, start time.Time, cfg config.CheckConfig, env
 	if err != nil {
 		res.CompletedAt = time.Now()
 		res.Error = fmt.Errorf("write whois: %w", err)
+		res.Reason = ReasonWHOISError
 		return res
 	}
 	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
@@ -659,13 +1154,15 @@ func runWHOIS(ctx context.Context, start time.Time, cfg config.CheckConfig, env
 		return res
 	}
 	res.CompletedAt = time.Now()
+	res.Latency = res.CompletedAt.Sub(start)
 	res.Metadata = map[string]any{
 		"raw": string(body),
 	}
 
-	expiration, err := extractExpiry(string(body))
+	expiration, err := extractExpiry(string(body), patterns)
 	if err != nil {
 		res.Error = err
+		res.Reason = ReasonWHOISError
 		return res
 	}
 
@@ -680,6 +1177,8 @@ func runWHOIS(ctx context.Context, start time.Time, cfg config.CheckConfig, env
 			if !result.Passed {
 				result.Message = fmt.Sprintf("domain expires in %.0f days", diff)
 			}
+		case "latency_ms":
+			result = evaluateLatency(assertion, res.Latency)
 		default:
 			result.Passed = false
 			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
@@ -701,6 +1200,9 @@ func toFloat(v interface{}) (float64, bool) {
 		return val, true
 	case float32:
 		return float64(val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
 	case string:
 		f, err := strconv.ParseFloat(val, 64)
 		if err == nil {
@@ -710,6 +1212,21 @@ func toFloat(v interface{}) (float64, bool) {
 	return 0, false
 }
 
+// evaluateLatency compares the latency of a run with a latency_ms
+// assertion. Every check type that reaches its target shares it, so the
+// assertion reads the same whatever the protocol.
+func evaluateLatency(assertion config.Assertion, latency time.Duration) AssertionResult {
+	result := AssertionResult{ID: assertion.ID, Kind: assertion.Kind, Op: assertion.Op, Path: assertion.Path}
+	expect, _ := toFloat(assertion.Value)
+	actual := float64(latency / time.Millisecond)
+	result.Actual = actual
+	result.Passed = compareFloats(actual, expect, assertion.Op)
+	if !result.Passed {
+		result.Message = fmt.Sprintf("latency %.2fms not %s %.2fms", actual, assertion.Op, expect)
+	}
+	return result
+}
+
 func compareFloats(actual, expected float64, op string) bool {
 	switch strings.ToLower(op) {
 	case "equals", "equal", "==":
@@ -774,7 +1291,21 @@ func toStringSlice(v interface{}) ([]string, error) {
 
 var whoisExpiryRegex = regexp.MustCompile(`(?i)Expiry Date:\s*(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z)`)
 
-func extractExpiry(body string) (time.Time, error) {
+// extractExpiry tries the configured patterns in order and falls back to the
+// built-in regex when none of them matches.
+func extractExpiry(body string, patterns []config.WHOISPattern) (time.Time, error) {
+	for _, pattern := range patterns {
+		if pattern.Regex == "" {
+			continue
+		}
+		t, ok, err := matchWHOISPattern(body, pattern)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if ok {
+			return t, nil
+		}
+	}
 	match := whoisExpiryRegex.FindStringSubmatch(body)
 	if len(match) < 2 {
 		return time.Time{}, errors.New("could not locate expiry date")
@@ -793,11 +1324,16 @@ var whoisServerCache = map[string]string{
 	"io":  "whois.nic.io",
 }
 
-func whoisServerForDomain(domain string) (string, error) {
+func whoisServerForDomain(domain string, patterns []config.WHOISPattern) (string, error) {
 	etld, err := publicsuffix.EffectiveTLDPlusOne(domain)
 	if err != nil {
 		return "", fmt.Errorf("public suffix: %w", err)
 	}
+	for _, pattern := range patterns {
+		if pattern.Server != "" {
+			return pattern.Server, nil
+		}
+	}
 	suffix, _ := publicsuffix.PublicSuffix(domain)
 	server, ok := whoisServerCache[suffix]
 	if !ok {
@@ -0,0 +1,324 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"30", now.Add(30 * time.Second)},
+		{"-5", time.Time{}},
+		{"Thu, 02 Jan 2025 03:10:00 GMT", time.Date(2025, 1, 2, 3, 10, 0, 0, time.UTC)},
+		{"soon", time.Time{}},
+	}
+	for _, tc := range cases {
+		if got := parseRetryAfter(tc.value, now); !got.Equal(tc.want) {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestStatusInClass(t *testing.T) {
+	cases := []struct {
+		code  int
+		class string
+		want  bool
+	}{
+		{100, "1xx", true},
+		{199, "1xx", true},
+		{200, "2xx", true},
+		{299, "2xx", true},
+		{300, "2xx", false},
+		{300, "3xx", true},
+		{399, "3xx", true},
+		{400, "3xx", false},
+		{400, "4xx", true},
+		{499, "4xx", true},
+		{500, "4xx", false},
+		{500, "5xx", true},
+		{599, "5XX", true},
+		{199, "2xx", false},
+	}
+	for _, tc := range cases {
+		got, err := statusInClass(tc.code, tc.class)
+		if err != nil {
+			t.Fatalf("statusInClass(%d, %q): %v", tc.code, tc.class, err)
+		}
+		if got != tc.want {
+			t.Errorf("statusInClass(%d, %q) = %v, want %v", tc.code, tc.class, got, tc.want)
+		}
+	}
+
+	for _, class := range []string{"", "2", "6xx", "20x", "xx"} {
+		if _, err := statusInClass(200, class); err == nil {
+			t.Errorf("expected error for class %q", class)
+		}
+	}
+}
+
+func TestRunHTTPStatusClassAssertion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:     "status-class",
+		Type:   "http",
+		Target: srv.URL,
+		Assertions: []config.Assertion{
+			{Kind: "status_class", Op: "equals", Value: "2xx"},
+			{Kind: "status_class", Op: "not_equals", Value: "5xx"},
+		},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()})
+	if !result.Success {
+		t.Fatalf("expected success, got %+v", result.AssertionResults)
+	}
+
+	cfg.Assertions = []config.Assertion{{Kind: "status_class", Op: "equals", Value: "3xx"}}
+	result = Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()})
+	if result.Success {
+		t.Fatalf("expected 201 to fail a 3xx assertion")
+	}
+}
+
+func TestRunHTTPOverridesSNI(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.ServerName == "example.com" {
+			_, _ = w.Write([]byte("vhost=example"))
+			return
+		}
+		_, _ = w.Write([]byte("vhost=default"))
+	}))
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:         "sni",
+		Type:       "https",
+		Target:     srv.URL,
+		SNI:        "example.com",
+		Assertions: []config.Assertion{{Kind: "body_contains", Op: "contains", Value: "vhost=example"}},
+	}
+	env := Environment{TemplateEngine: render.New(), HttpClient: srv.Client()}
+	result := Execute(context.Background(), cfg, env)
+	if !result.Success {
+		t.Fatalf("expected SNI override to reach example vhost, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	cfg.SNI = ""
+	result = Execute(context.Background(), cfg, env)
+	if result.Success {
+		t.Fatalf("expected default SNI to reach the default vhost")
+	}
+}
+
+func TestRunHTTPNegotiatesRequestedALPN(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	cases := []struct {
+		alpn     []string
+		protocol string
+		proto    string
+	}{
+		{[]string{"h2"}, "h2", "HTTP/2.0"},
+		{[]string{"http/1.1"}, "http/1.1", "HTTP/1.1"},
+	}
+	for _, tc := range cases {
+		cfg := config.CheckConfig{
+			ID:         "alpn",
+			Type:       "https",
+			Target:     srv.URL,
+			ALPN:       tc.alpn,
+			Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		}
+		result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: srv.Client()})
+		if !result.Success {
+			t.Fatalf("alpn %v: expected success, got %v %+v", tc.alpn, result.Error, result.AssertionResults)
+		}
+		if got := result.Metadata["negotiated_protocol"]; got != tc.protocol {
+			t.Fatalf("alpn %v: expected negotiated %q, got %v", tc.alpn, tc.protocol, got)
+		}
+		if got := result.Metadata["http_protocol"]; got != tc.proto {
+			t.Fatalf("alpn %v: expected %s, got %v", tc.alpn, tc.proto, got)
+		}
+	}
+}
+
+func TestRunHTTPJSONPathLargeIntegerID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": 12345678901234567, "items": [1, 2]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:         "big-id",
+		Type:       "http",
+		Target:     srv.URL,
+		Assertions: []config.Assertion{{Kind: "jsonpath", Op: "equals", Path: "$.id", Value: 12345678901234567}},
+	}
+	env := Environment{TemplateEngine: render.New()}
+	if result := Execute(context.Background(), cfg, env); result.Success {
+		t.Fatalf("expected float64 decoding to lose precision")
+	}
+
+	cfg.Request = &config.HTTPRequest{JSONExactNumbers: true}
+	if result := Execute(context.Background(), cfg, env); !result.Success {
+		t.Fatalf("expected exact id match, got %+v", result.AssertionResults)
+	}
+
+	cfg.Assertions = []config.Assertion{{Kind: "jsonpath", Op: "equals", Path: "$.id", Value: "12345678901234567"}}
+	if result := Execute(context.Background(), cfg, env); !result.Success {
+		t.Fatalf("expected exact id to match string value, got %+v", result.AssertionResults)
+	}
+}
+
+func TestRunHTTPFollowRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("sign in"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	shared := &http.Client{Timeout: 2 * time.Second}
+	env := Environment{TemplateEngine: render.New(), HttpClient: shared}
+	cfg := config.CheckConfig{
+		ID:         "health",
+		Type:       "http",
+		Target:     srv.URL + "/health",
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 302}},
+	}
+	if result := Execute(context.Background(), cfg, env); result.Success {
+		t.Fatalf("expected the followed 200 by default, got %+v", result.AssertionResults)
+	}
+
+	follow := false
+	cfg.Request = &config.HTTPRequest{FollowRedirects: &follow}
+	if result := Execute(context.Background(), cfg, env); !result.Success {
+		t.Fatalf("expected the 302 itself, got %+v", result.AssertionResults)
+	}
+	if shared.CheckRedirect != nil {
+		t.Fatalf("expected the shared client to stay unchanged")
+	}
+}
+
+func TestRunHTTPJSONPathMaxJSONBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "ok", "padding": "` + strings.Repeat("x", 256) + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:         "big-body",
+		Type:       "http",
+		Target:     srv.URL,
+		Request:    &config.HTTPRequest{MaxJSONBytes: 64},
+		Assertions: []config.Assertion{{Kind: "jsonpath", Op: "equals", Path: "$.status", Value: "ok"}},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()})
+	if result.Success || !strings.Contains(result.AssertionResults[0].Message, "max_json_bytes") {
+		t.Fatalf("expected size guard to fail the assertion, got %+v", result.AssertionResults)
+	}
+
+	cfg.Request.MaxJSONBytes = 1024
+	if result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()}); !result.Success {
+		t.Fatalf("expected body within limit to pass, got %+v", result.AssertionResults)
+	}
+}
+
+func TestMaintenanceResponse(t *testing.T) {
+	expected := &config.MaintenanceStatus{Status: 503, BodyContains: "maintenance"}
+	tests := []struct {
+		name     string
+		expected *config.MaintenanceStatus
+		status   int
+		body     string
+		want     bool
+	}{
+		{"status and marker", expected, 503, "down for maintenance", true},
+		{"missing marker", expected, 503, "bad gateway", false},
+		{"other status", expected, 500, "down for maintenance", false},
+		{"status only", &config.MaintenanceStatus{Status: 503}, 503, "", true},
+		{"not configured", nil, 503, "down for maintenance", false},
+	}
+	for _, tt := range tests {
+		if got := maintenanceResponse(tt.expected, tt.status, tt.body); got != tt.want {
+			t.Errorf("%s: maintenanceResponse = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// detachedBodyTransport hands out response bodies that keep reading after
+// the request context ends, like transports that don't watch it mid-body.
+type detachedBodyTransport struct {
+	base http.RoundTripper
+}
+
+func (t detachedBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	detached := req.Clone(context.WithoutCancel(req.Context()))
+	return t.base.RoundTrip(detached)
+}
+
+func TestRunHTTPSlowBodyTimesOut(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 40; i++ {
+			if _, err := w.Write([]byte(".")); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:         "slow-body",
+		Type:       "http",
+		Target:     srv.URL,
+		Request:    &config.HTTPRequest{Timeout: &config.NullableDuration{Duration: 200 * time.Millisecond, Set: true}},
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	clients := map[string]*http.Client{
+		"default":  {},
+		"detached": {Transport: detachedBodyTransport{base: &http.Transport{}}},
+	}
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			started := time.Now()
+			result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+			if elapsed := time.Since(started); elapsed > time.Second {
+				t.Fatalf("expected the read to abort at the timeout, took %s", elapsed)
+			}
+			if result.Success || result.Reason != ReasonTimeout {
+				t.Fatalf("expected timeout, got success=%v reason=%q err=%v", result.Success, result.Reason, result.Error)
+			}
+			if result.Latency < 200*time.Millisecond {
+				t.Fatalf("expected latency up to the timeout, got %s", result.Latency)
+			}
+		})
+	}
+}
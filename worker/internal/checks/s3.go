@@ -0,0 +1,216 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+// runS3 fetches the metadata of an object with a HEAD request and asserts on
+// its existence, size and age. Without an object_exists assertion a missing
+// object fails the check.
+func runS3(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:          cfg.ID,
+		CheckName:        cfg.Name,
+		StartedAt:        start,
+		Metadata:         map[string]any{},
+		AssertionResults: []AssertionResult{},
+	}
+	defer func() {
+		res.CompletedAt = time.Now()
+		res.Latency = res.CompletedAt.Sub(start)
+	}()
+
+	if cfg.S3 == nil || strings.TrimSpace(cfg.S3.Bucket) == "" || strings.TrimSpace(cfg.S3.Key) == "" {
+		res.Error = fmt.Errorf("s3.bucket and s3.key are required for s3 check")
+		res.Reason = ReasonConfigError
+		return res
+	}
+	s3cfg, err := renderS3Config(cfg, env)
+	if err != nil {
+		res.Error = err
+		res.Reason = ReasonConfigError
+		return res
+	}
+	client, err := newS3Client(s3cfg, env)
+	if err != nil {
+		res.Error = err
+		res.Reason = ReasonConfigError
+		return res
+	}
+	res.Metadata["bucket"] = s3cfg.Bucket
+	res.Metadata["key"] = s3cfg.Key
+
+	info, err := client.StatObject(ctx, s3cfg.Bucket, s3cfg.Key, minio.StatObjectOptions{})
+	exists := err == nil
+	if err != nil && !isS3NotFound(err) {
+		res.Error = fmt.Errorf("head object: %w", err)
+		return res
+	}
+	res.Metadata["object_exists"] = exists
+	var age time.Duration
+	if exists {
+		age = start.Sub(info.LastModified)
+		res.Metadata["object_size"] = info.Size
+		res.Metadata["last_modified"] = info.LastModified.UTC().Format(time.RFC3339)
+		res.Metadata["object_age_hours"] = age.Hours()
+		if info.ETag != "" {
+			res.Metadata["etag"] = info.ETag
+		}
+	}
+
+	if !exists && !hasAssertion(cfg.Assertions, "object_exists") {
+		res.AssertionResults = append(res.AssertionResults, AssertionResult{
+			Kind:    "object_exists",
+			Op:      "equals",
+			Message: fmt.Sprintf("object s3://%s/%s not found", s3cfg.Bucket, s3cfg.Key),
+		})
+		res.Reason = ReasonObjectMissing
+		return res
+	}
+
+	for _, assertion := range cfg.Assertions {
+		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
+		kind := strings.ToLower(assertion.Kind)
+		if kind == "object_exists" {
+			want := true
+			if assertion.Value != nil {
+				want = strings.EqualFold(fmt.Sprintf("%v", assertion.Value), "true")
+			}
+			result.Passed = exists == want
+			if !result.Passed {
+				result.Message = fmt.Sprintf("object exists is %t, expected %t", exists, want)
+			}
+			res.AssertionResults = append(res.AssertionResults, result)
+			continue
+		}
+
+		var actual float64
+		switch kind {
+		case "object_size":
+			actual = float64(info.Size)
+		case "object_age_hours":
+			actual = age.Hours()
+		case "latency_ms":
+			res.AssertionResults = append(res.AssertionResults, evaluateLatency(assertion, time.Since(start)))
+			continue
+		default:
+			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
+			res.AssertionResults = append(res.AssertionResults, result)
+			continue
+		}
+		if !exists {
+			result.Message = "object not found"
+			res.AssertionResults = append(res.AssertionResults, result)
+			continue
+		}
+		expect, ok := toFloat(assertion.Value)
+		if !ok {
+			result.Message = fmt.Sprintf("invalid %s value %v", assertion.Kind, assertion.Value)
+			res.AssertionResults = append(res.AssertionResults, result)
+			continue
+		}
+		result.Passed = compareFloats(actual, expect, assertion.Op)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("%s %g not %s %g", assertion.Kind, actual, assertion.Op, expect)
+		}
+		res.AssertionResults = append(res.AssertionResults, result)
+	}
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
+
+// renderS3Config returns a copy of the s3 block with the key and credentials
+// rendered.
+func renderS3Config(cfg config.CheckConfig, env Environment) (config.S3Check, error) {
+	s3cfg := *cfg.S3
+	renderCtx := render.TemplateContext{
+		Secrets: env.Secrets,
+		Vars:    env.Vars,
+		Data: map[string]interface{}{
+			"labels": cfg.Labels,
+			"check": map[string]interface{}{
+				"id":     cfg.ID,
+				"name":   cfg.Name,
+				"target": cfg.Target,
+			},
+		},
+	}
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"key", &s3cfg.Key},
+		{"access_key_id", &s3cfg.AccessKeyID},
+		{"secret_access_key", &s3cfg.SecretAccessKey},
+		{"session_token", &s3cfg.SessionToken},
+	}
+	for _, field := range fields {
+		if *field.value == "" {
+			continue
+		}
+		rendered, err := env.TemplateEngine.RenderString(*field.value, renderCtx)
+		if err != nil {
+			return s3cfg, fmt.Errorf("render s3.%s: %w", field.name, err)
+		}
+		*field.value = strings.TrimSpace(rendered)
+	}
+	return s3cfg, nil
+}
+
+// newS3Client builds a client for the endpoint, which may be given as a URL
+// (http:// selects plain HTTP) or as host[:port] served over HTTPS. Requests
+// go through the transport of the environment's HTTP client so proxy and TLS
+// settings apply. Without credentials requests are anonymous.
+func newS3Client(s3cfg config.S3Check, env Environment) (*minio.Client, error) {
+	endpoint := strings.TrimSpace(s3cfg.Endpoint)
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+	secure := true
+	if strings.Contains(endpoint, "://") {
+		parsed, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("parse s3.endpoint: %w", err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return nil, fmt.Errorf("unsupported s3.endpoint scheme %q", parsed.Scheme)
+		}
+		secure = parsed.Scheme == "https"
+		endpoint = parsed.Host
+	}
+	opts := &minio.Options{
+		Creds:  credentials.NewStaticV4(s3cfg.AccessKeyID, s3cfg.SecretAccessKey, s3cfg.SessionToken),
+		Secure: secure,
+		Region: s3cfg.Region,
+	}
+	if env.HttpClient != nil && env.HttpClient.Transport != nil {
+		opts.Transport = env.HttpClient.Transport
+	}
+	client, err := minio.New(endpoint, opts)
+	if err != nil {
+		return nil, fmt.Errorf("build s3 client: %w", err)
+	}
+	return client, nil
+}
+
+func isS3NotFound(err error) bool {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp.Code == "NoSuchKey" || (resp.StatusCode == http.StatusNotFound && resp.Code != "NoSuchBucket")
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// startS3Stub answers HEAD requests for the objects in modified, keyed by
+// "/bucket/key", and 404 for anything else. It records the Authorization
+// header of the last request.
+func startS3Stub(t *testing.T, modified map[string]time.Time, auth *string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = r.Header.Get("Authorization")
+		at, ok := modified[r.URL.Path]
+		if r.Method != http.MethodHead || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "2048")
+		w.Header().Set("ETag", `"abc123"`)
+		w.Header().Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func runS3Check(endpoint, key string, assertions ...config.Assertion) Result {
+	cfg := config.CheckConfig{
+		ID:   "nightly-backup",
+		Type: "s3",
+		S3: &config.S3Check{
+			Endpoint:        endpoint,
+			Region:          "us-east-1",
+			Bucket:          "backups",
+			Key:             key,
+			AccessKeyID:     `{{ secret "S3_ACCESS_KEY" }}`,
+			SecretAccessKey: `{{ secret "S3_SECRET_KEY" }}`,
+		},
+		Assertions: assertions,
+	}
+	env := Environment{
+		TemplateEngine: render.New(),
+		HttpClient:     http.DefaultClient,
+		Secrets:        map[string]string{"S3_ACCESS_KEY": "backup-reader", "S3_SECRET_KEY": "s3cr3t"},
+	}
+	return Execute(context.Background(), cfg, env)
+}
+
+func TestS3CheckAssertsObjectSizeAndAge(t *testing.T) {
+	var auth string
+	endpoint := startS3Stub(t, map[string]time.Time{"/backups/db.tar.gz": time.Now().Add(-2 * time.Hour)}, &auth)
+
+	result := runS3Check(endpoint, "db.tar.gz",
+		config.Assertion{Kind: "object_exists", Value: true},
+		config.Assertion{Kind: "object_size", Op: "greater_than", Value: 1024},
+		config.Assertion{Kind: "object_age_hours", Op: "less_than", Value: 26},
+	)
+	if !result.Success {
+		t.Fatalf("expected fresh backup to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if !strings.Contains(auth, "Credential=backup-reader/") {
+		t.Fatalf("expected request signed with the secret access key id, got %q", auth)
+	}
+	if result.Metadata["object_size"] != int64(2048) || result.Metadata["etag"] != "abc123" {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+
+	result = runS3Check(endpoint, "db.tar.gz", config.Assertion{Kind: "object_age_hours", Op: "less_than", Value: 1})
+	if result.Success || result.Reason != ReasonStaleData {
+		t.Fatalf("expected stale backup to fail with %q, got success=%v reason=%q", ReasonStaleData, result.Success, result.Reason)
+	}
+}
+
+func TestS3CheckReportsMissingObject(t *testing.T) {
+	var auth string
+	endpoint := startS3Stub(t, map[string]time.Time{}, &auth)
+
+	result := runS3Check(endpoint, "db.tar.gz", config.Assertion{Kind: "object_size", Op: "greater_than", Value: 0})
+	if result.Success || result.Reason != ReasonObjectMissing {
+		t.Fatalf("expected missing object to fail with %q, got success=%v reason=%q err=%v", ReasonObjectMissing, result.Success, result.Reason, result.Error)
+	}
+
+	result = runS3Check(endpoint, "db.tar.gz", config.Assertion{Kind: "object_exists", Value: false})
+	if !result.Success {
+		t.Fatalf("expected object_exists false to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+}
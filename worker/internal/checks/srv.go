@@ -0,0 +1,172 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// runWithSRV resolves the check target from an SRV record and runs the check
+// against the preferred target, or against every returned target when the
+// check asks for all of them.
+func runWithSRV(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:   cfg.ID,
+		CheckName: cfg.Name,
+		StartedAt: start,
+		Metadata:  map[string]any{},
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "http", "https", "tcp", "tls":
+	default:
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("target_from_srv is not supported for check type %q", cfg.Type)
+		res.Reason = ReasonConfigError
+		return res
+	}
+
+	srv := cfg.TargetFromSRV
+	res.Metadata["srv_name"] = srv.Name
+	targets, err := lookupSRVTargets(ctx, srv)
+	if err != nil {
+		res.CompletedAt = time.Now()
+		res.Error = fmt.Errorf("srv lookup %s: %w", srv.Name, err)
+		res.Reason = ReasonDNSError
+		return res
+	}
+	if !srv.All {
+		targets = targets[:1]
+	}
+
+	summaries := make([]map[string]any, 0, len(targets))
+	res.Success = true
+	for _, target := range targets {
+		targetCfg, err := withSRVTarget(cfg, target)
+		var targetRes Result
+		if err != nil {
+			targetRes = Result{StartedAt: time.Now(), CompletedAt: time.Now(), Error: err, Reason: ReasonConfigError}
+		} else {
+			targetRes = withReason(runByType(ctx, time.Now(), targetCfg, env))
+		}
+		summary := map[string]any{
+			"target":  target,
+			"success": targetRes.Success,
+		}
+		if targetRes.Error != nil {
+			summary["error"] = targetRes.Error.Error()
+		}
+		summaries = append(summaries, summary)
+
+		if targetRes.Latency > res.Latency {
+			res.Latency = targetRes.Latency
+		}
+		if targetRes.RetryAfter.After(res.RetryAfter) {
+			res.RetryAfter = targetRes.RetryAfter
+		}
+		for _, assertion := range targetRes.AssertionResults {
+			if srv.All {
+				assertion.Message = prefixTarget(target, assertion.Message)
+			}
+			res.AssertionResults = append(res.AssertionResults, assertion)
+		}
+		if !targetRes.Success {
+			res.Success = false
+			if res.Reason == "" {
+				res.Reason = targetRes.Reason
+			}
+			if res.Error == nil && targetRes.Error != nil {
+				res.Error = fmt.Errorf("%s: %w", target, targetRes.Error)
+			}
+		}
+		if !srv.All {
+			for key, value := range targetRes.Metadata {
+				res.Metadata[key] = value
+			}
+		}
+	}
+	res.CompletedAt = time.Now()
+	res.Metadata["srv_target"] = targets[0]
+	if srv.All {
+		res.Metadata["srv_targets"] = summaries
+	}
+	return res
+}
+
+// lookupSRVTargets returns the host:port pairs of an SRV record ordered by
+// priority and weight. A custom resolver address bypasses the system resolver.
+func lookupSRVTargets(ctx context.Context, srv *config.SRVTarget) ([]string, error) {
+	if strings.TrimSpace(srv.Name) == "" {
+		return nil, errors.New("srv name is required")
+	}
+	resolver := net.DefaultResolver
+	if srv.Resolver != "" {
+		address := srv.Resolver
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, address)
+			},
+		}
+	}
+	_, records, err := resolver.LookupSRV(ctx, "", "", srv.Name)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]string, 0, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		if host == "" {
+			continue
+		}
+		targets = append(targets, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no srv targets returned")
+	}
+	return targets, nil
+}
+
+// withSRVTarget returns a copy of cfg pointed at the resolved host:port. HTTP
+// checks keep the scheme, path and query of their configured URL.
+func withSRVTarget(cfg config.CheckConfig, hostPort string) (config.CheckConfig, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "http", "https":
+		raw := effectiveRequestURL(cfg)
+		if raw == "" {
+			raw = strings.ToLower(cfg.Type) + "://placeholder/"
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid target url: %w", err)
+		}
+		u.Host = hostPort
+		if cfg.Request != nil && cfg.Request.URL != "" {
+			req := *cfg.Request
+			req.URL = u.String()
+			cfg.Request = &req
+		} else {
+			cfg.Target = u.String()
+		}
+	default:
+		cfg.Target = hostPort
+	}
+	return cfg, nil
+}
+
+func prefixTarget(target, message string) string {
+	if message == "" {
+		return message
+	}
+	return target + ": " + message
+}
@@ -0,0 +1,185 @@
+package checks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	dnsclient "github.com/miekg/dns"
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// startSRVServer serves the given SRV records for name from a local UDP DNS
+// server and returns its address.
+func startSRVServer(t *testing.T, name string, records []*dnsclient.SRV) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	handler := dnsclient.HandlerFunc(func(w dnsclient.ResponseWriter, req *dnsclient.Msg) {
+		msg := new(dnsclient.Msg)
+		msg.SetReply(req)
+		for _, q := range req.Question {
+			if q.Qtype != dnsclient.TypeSRV || !strings.EqualFold(q.Name, dnsclient.Fqdn(name)) {
+				continue
+			}
+			for _, record := range records {
+				rr := *record
+				rr.Hdr = dnsclient.RR_Header{Name: q.Name, Rrtype: dnsclient.TypeSRV, Class: dnsclient.ClassINET, Ttl: 30}
+				msg.Answer = append(msg.Answer, &rr)
+			}
+		}
+		_ = w.WriteMsg(msg)
+	})
+	server := &dnsclient.Server{PacketConn: pc, Handler: handler}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
+func listenerPort(t *testing.T, addr string) uint16 {
+	t.Helper()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("split %q: %v", addr, err)
+	}
+	value, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("parse port %q: %v", port, err)
+	}
+	return uint16(value)
+}
+
+func closedPort(t *testing.T) uint16 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listenerPort(t, ln.Addr().String())
+	_ = ln.Close()
+	return port
+}
+
+func TestRunTCPWithSRVUsesPreferredTarget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	name := "_db._tcp.example.test"
+	resolver := startSRVServer(t, name, []*dnsclient.SRV{
+		{Priority: 20, Weight: 1, Port: closedPort(t), Target: "localhost."},
+		{Priority: 10, Weight: 1, Port: listenerPort(t, ln.Addr().String()), Target: "localhost."},
+	})
+
+	cfg := config.CheckConfig{
+		ID:            "srv-tcp",
+		Name:          "SRV TCP",
+		Type:          "tcp",
+		TargetFromSRV: &config.SRVTarget{Name: name, Resolver: resolver},
+		Assertions:    []config.Assertion{{Kind: "tcp_connect", Op: "equals", Value: true}},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{})
+	if !result.Success {
+		t.Fatalf("expected success, got %+v", result)
+	}
+	if got, want := result.Metadata["srv_target"], net.JoinHostPort("localhost", strconv.Itoa(int(listenerPort(t, ln.Addr().String())))); got != want {
+		t.Fatalf("srv_target = %v, want %v", got, want)
+	}
+}
+
+func TestRunTCPWithSRVAllTargets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	name := "_db._tcp.example.test"
+	down := closedPort(t)
+	resolver := startSRVServer(t, name, []*dnsclient.SRV{
+		{Priority: 10, Weight: 1, Port: listenerPort(t, ln.Addr().String()), Target: "localhost."},
+		{Priority: 20, Weight: 1, Port: down, Target: "localhost."},
+	})
+
+	cfg := config.CheckConfig{
+		ID:            "srv-tcp-all",
+		Name:          "SRV TCP all",
+		Type:          "tcp",
+		TargetFromSRV: &config.SRVTarget{Name: name, Resolver: resolver, All: true},
+		Assertions:    []config.Assertion{{Kind: "tcp_connect", Op: "equals", Value: true}},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{})
+	if result.Success {
+		t.Fatalf("expected failure when one srv target is down")
+	}
+	summaries, ok := result.Metadata["srv_targets"].([]map[string]any)
+	if !ok || len(summaries) != 2 {
+		t.Fatalf("expected two srv target summaries, got %#v", result.Metadata["srv_targets"])
+	}
+	if summaries[0]["success"] != true || summaries[1]["success"] != false {
+		t.Fatalf("unexpected summaries: %#v", summaries)
+	}
+	downTarget := net.JoinHostPort("localhost", strconv.Itoa(int(down)))
+	if result.Error == nil || !strings.HasPrefix(result.Error.Error(), downTarget) {
+		t.Fatalf("expected error for %s, got %v", downTarget, result.Error)
+	}
+}
+
+func TestRunHTTPWithSRVKeepsPath(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	name := "_http._tcp.example.test"
+	resolver := startSRVServer(t, name, []*dnsclient.SRV{
+		{Priority: 10, Weight: 1, Port: listenerPort(t, srv.Listener.Addr().String()), Target: "localhost."},
+	})
+
+	cfg := config.CheckConfig{
+		ID:            "srv-http",
+		Name:          "SRV HTTP",
+		Type:          "http",
+		Target:        "http://api.example.test/healthz",
+		TargetFromSRV: &config.SRVTarget{Name: name, Resolver: resolver},
+		Assertions:    []config.Assertion{{Kind: "status_code", Op: "equals", Value: 204}},
+	}
+
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New()})
+	if !result.Success {
+		t.Fatalf("expected success, got %+v", result)
+	}
+	if gotPath != "/healthz" {
+		t.Fatalf("path = %q, want /healthz", gotPath)
+	}
+}
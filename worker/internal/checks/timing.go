@@ -0,0 +1,110 @@
+package checks
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// timingAssertions are the HTTP assertion kinds that read a request phase.
+var timingAssertions = []string{"dns_ms", "connect_ms", "tls_handshake_ms", "ttfb_ms"}
+
+// timingEnabled reports whether the check traces its request phases, either
+// because request.timing is set or because an assertion needs them.
+func timingEnabled(cfg config.CheckConfig) bool {
+	if cfg.Request != nil && cfg.Request.Timing {
+		return true
+	}
+	for _, kind := range timingAssertions {
+		if hasAssertion(cfg.Assertions, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpTiming collects the phases of a request through httptrace. Phases of
+// every redirect hop add up; time to first byte is measured from the start
+// of the run to the first byte of the final response.
+type httpTiming struct {
+	mu      sync.Mutex
+	start   time.Time
+	phases  map[string]time.Duration
+	started map[string]time.Time
+	reused  bool
+}
+
+func newHTTPTiming() *httpTiming {
+	return &httpTiming{phases: map[string]time.Duration{}, started: map[string]time.Time{}}
+}
+
+func (t *httpTiming) begin(phase string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.started[phase]; !ok {
+		t.started[phase] = time.Now()
+	}
+}
+
+func (t *httpTiming) end(phase string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	began, ok := t.started[phase]
+	if !ok || err != nil {
+		return
+	}
+	delete(t.started, phase)
+	t.phases[phase] += time.Since(began)
+}
+
+func (t *httpTiming) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.begin("dns_ms") },
+		DNSDone:  func(info httptrace.DNSDoneInfo) { t.end("dns_ms", info.Err) },
+		// With several addresses only the first dial that succeeds counts.
+		ConnectStart:      func(string, string) { t.begin("connect_ms") },
+		ConnectDone:       func(_, _ string, err error) { t.end("connect_ms", err) },
+		TLSHandshakeStart: func() { t.begin("tls_handshake_ms") },
+		TLSHandshakeDone:  func(_ tls.ConnectionState, err error) { t.end("tls_handshake_ms", err) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = t.reused || info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.phases["ttfb_ms"] = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// phase returns the duration of a phase and whether it was observed. A
+// reused connection skips DNS, connect and the TLS handshake.
+func (t *httpTiming) phase(kind string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.phases[kind]
+	return d, ok
+}
+
+// metadata reports the observed phases in milliseconds.
+func (t *httpTiming) metadata(total time.Duration) map[string]any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := map[string]any{
+		"total_ms":          durationMillis(total),
+		"connection_reused": t.reused,
+	}
+	for phase, d := range t.phases {
+		out[phase] = durationMillis(d)
+	}
+	return out
+}
+
+func durationMillis(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
@@ -0,0 +1,170 @@
+package checks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestHTTPTimingBreakdownOverTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:     "timed",
+		Type:   "https",
+		Target: srv.URL,
+		Assertions: []config.Assertion{
+			{Kind: "connect_ms", Op: "less_than", Value: 5000},
+			{Kind: "tls_handshake_ms", Op: "less_than", Value: 5000},
+			{Kind: "ttfb_ms", Op: "greater_than", Value: 15},
+		},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: srv.Client()})
+	if !result.Success {
+		t.Fatalf("expected timing assertions to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	timing := result.Metadata["timing"].(map[string]any)
+	for _, phase := range []string{"connect_ms", "tls_handshake_ms", "ttfb_ms", "total_ms"} {
+		if v, ok := timing[phase].(float64); !ok || v <= 0 {
+			t.Fatalf("expected %s to be populated, got %v", phase, timing)
+		}
+	}
+	if timing["ttfb_ms"].(float64) > timing["total_ms"].(float64) {
+		t.Fatalf("expected ttfb within total, got %v", timing)
+	}
+}
+
+func TestHTTPTimingMeasuresEveryRunOnASharedClient(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:     "timed",
+		Type:   "https",
+		Target: srv.URL,
+		Assertions: []config.Assertion{
+			{Kind: "connect_ms", Op: "less_than", Value: 5000},
+			{Kind: "tls_handshake_ms", Op: "less_than", Value: 5000},
+		},
+	}
+	env := Environment{TemplateEngine: render.New(), HttpClient: srv.Client()}
+	for run := 1; run <= 2; run++ {
+		result := Execute(context.Background(), cfg, env)
+		if !result.Success {
+			t.Fatalf("run %d: expected timing assertions to pass, got %v %+v", run, result.Error, result.AssertionResults)
+		}
+		timing := result.Metadata["timing"].(map[string]any)
+		for _, phase := range []string{"connect_ms", "tls_handshake_ms"} {
+			if v, ok := timing[phase].(float64); !ok || v <= 0 {
+				t.Fatalf("run %d: expected %s to be measured, got %v", run, phase, timing)
+			}
+		}
+	}
+}
+
+func TestHTTPTimingRecordsDNSAndMissingPhases(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.CheckConfig{
+		ID:         "timed",
+		Type:       "http",
+		Target:     strings.Replace(srv.URL, "127.0.0.1", "localhost", 1),
+		Request:    &config.HTTPRequest{Timing: true},
+		Assertions: []config.Assertion{{Kind: "tls_handshake_ms", Op: "less_than", Value: 100}},
+	}
+	result := Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: &http.Client{Transport: &http.Transport{}}})
+	timing := result.Metadata["timing"].(map[string]any)
+	if _, ok := timing["dns_ms"]; !ok {
+		t.Fatalf("expected dns phase for a hostname target, got %v", timing)
+	}
+	if result.Success || result.Reason != ReasonLatencyExceeded {
+		t.Fatalf("expected unobserved tls phase to fail, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if msg := result.AssertionResults[0].Message; !strings.Contains(msg, "not observed") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func TestHTTPConnectTimeoutIsSeparateFromOverallTimeout(t *testing.T) {
+	// The dial blocks until its context ends, like a SYN that is never answered.
+	slowDial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	check := func(request *config.HTTPRequest) (Result, time.Duration) {
+		cfg := config.CheckConfig{ID: "slow", Type: "http", Target: "http://192.0.2.1/", Request: request}
+		env := Environment{
+			TemplateEngine: render.New(),
+			HttpClient:     &http.Client{Timeout: time.Second, Transport: &http.Transport{DialContext: slowDial}},
+		}
+		started := time.Now()
+		result := Execute(context.Background(), cfg, env)
+		return result, time.Since(started)
+	}
+
+	result, took := check(&config.HTTPRequest{ConnectTimeout: config.Duration{Duration: 50 * time.Millisecond}})
+	if result.Success || result.Reason != ReasonTimeout || !strings.Contains(result.Error.Error(), "connect timeout after 50ms") {
+		t.Fatalf("expected connect timeout, got reason=%q err=%v", result.Reason, result.Error)
+	}
+	if took > 500*time.Millisecond {
+		t.Fatalf("expected connect_timeout to end the request early, took %s", took)
+	}
+
+	result, took = check(nil)
+	if result.Success || result.Reason != ReasonTimeout || strings.Contains(result.Error.Error(), "connect timeout") {
+		t.Fatalf("expected the overall timeout, got reason=%q err=%v", result.Reason, result.Error)
+	}
+	if took < time.Second {
+		t.Fatalf("expected the overall timeout to apply, took %s", took)
+	}
+}
+
+func TestHTTPTLSTimeoutBoundsHandshake(t *testing.T) {
+	// The listener accepts connections but never answers the ClientHello.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	cfg := config.CheckConfig{
+		ID:      "silent",
+		Type:    "https",
+		Target:  "https://" + ln.Addr().String() + "/",
+		Request: &config.HTTPRequest{TLSTimeout: config.Duration{Duration: 50 * time.Millisecond}},
+	}
+	env := Environment{TemplateEngine: render.New(), HttpClient: &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{}}}
+	started := time.Now()
+	result := Execute(context.Background(), cfg, env)
+	if result.Success || !strings.Contains(result.Error.Error(), "TLS handshake timeout") {
+		t.Fatalf("expected tls handshake timeout, got %v", result.Error)
+	}
+	if took := time.Since(started); took > time.Second {
+		t.Fatalf("expected tls_timeout to end the request early, took %s", took)
+	}
+}
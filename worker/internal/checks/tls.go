@@ -0,0 +1,226 @@
+package checks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tlsProbe is one handshake of a tls check. A probe with a key type offers
+// only the TLS 1.2 cipher suites authenticated by that key type, so a server
+// with several certificates presents the matching one.
+type tlsProbe struct {
+	keyType      string
+	cipherSuites []uint16
+}
+
+// tlsLeaf is the leaf certificate presented to a probe.
+type tlsLeaf struct {
+	keyType string
+	cert    *x509.Certificate
+}
+
+// tlsProbes returns one probe per configured key type, or a single probe
+// with default preferences when none are configured.
+func tlsProbes(keyTypes []string) ([]tlsProbe, error) {
+	if len(keyTypes) == 0 {
+		return []tlsProbe{{}}, nil
+	}
+	probes := make([]tlsProbe, 0, len(keyTypes))
+	for _, keyType := range keyTypes {
+		keyType = strings.ToLower(strings.TrimSpace(keyType))
+		var marker string
+		switch keyType {
+		case "rsa":
+			marker = "_RSA_"
+		case "ecdsa":
+			marker = "_ECDSA_"
+		default:
+			return nil, fmt.Errorf("unsupported tls_key_types entry %q (want rsa or ecdsa)", keyType)
+		}
+		var suites []uint16
+		for _, suite := range tls.CipherSuites() {
+			if strings.Contains(suite.Name, marker) {
+				suites = append(suites, suite.ID)
+			}
+		}
+		probes = append(probes, tlsProbe{keyType: keyType, cipherSuites: suites})
+	}
+	return probes, nil
+}
+
+func (p tlsProbe) handshake(dialer *net.Dialer, addr string, base *tls.Config) (tls.ConnectionState, error) {
+	if p.keyType != "" {
+		base.MaxVersion = tls.VersionTLS12
+		base.CipherSuites = p.cipherSuites
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, base)
+	if err != nil {
+		if p.keyType != "" {
+			return tls.ConnectionState{}, fmt.Errorf("%s handshake: %w", p.keyType, err)
+		}
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState(), nil
+}
+
+// tlsTicketWait bounds how long the first handshake of a resumption probe
+// waits for a TLS 1.3 session ticket, which the server sends after the
+// handshake.
+const tlsTicketWait = time.Second
+
+// tlsResumption is the outcome of a resumption probe.
+type tlsResumption struct {
+	resumed   bool
+	handshake time.Duration
+	err       error
+}
+
+// probeResumption handshakes twice with a shared session cache and reports
+// whether the second handshake resumed the session of the first.
+func probeResumption(dialer *net.Dialer, addr string, base *tls.Config) tlsResumption {
+	cache := &ticketCache{ClientSessionCache: tls.NewLRUClientSessionCache(1), stored: make(chan struct{}, 1)}
+	base.ClientSessionCache = cache
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, base)
+	if err != nil {
+		return tlsResumption{err: fmt.Errorf("initial handshake: %w", err)}
+	}
+	// TLS 1.3 tickets are only processed while reading from the connection.
+	if conn.ConnectionState().Version >= tls.VersionTLS13 {
+		awaitTicket(conn, cache.stored, tlsTicketWait)
+	}
+	_ = conn.Close()
+
+	start := time.Now()
+	conn, err = tls.DialWithDialer(dialer, "tcp", addr, base)
+	if err != nil {
+		return tlsResumption{err: fmt.Errorf("resumed handshake: %w", err)}
+	}
+	defer conn.Close()
+	return tlsResumption{resumed: conn.ConnectionState().DidResume, handshake: time.Since(start)}
+}
+
+// awaitTicket reads from conn until the session cache stores a ticket, the
+// server closes the connection or wait elapses.
+func awaitTicket(conn *tls.Conn, stored <-chan struct{}, wait time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = conn.SetReadDeadline(time.Now().Add(wait))
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+	select {
+	case <-stored:
+		_ = conn.SetReadDeadline(time.Now())
+	case <-done:
+	}
+	<-done
+}
+
+// ticketCache signals every session it stores.
+type ticketCache struct {
+	tls.ClientSessionCache
+	stored chan struct{}
+}
+
+func (c *ticketCache) Put(key string, cs *tls.ClientSessionState) {
+	c.ClientSessionCache.Put(key, cs)
+	if cs == nil {
+		return
+	}
+	select {
+	case c.stored <- struct{}{}:
+	default:
+	}
+}
+
+// tlsBaseConfig trusts the same roots as the environment's HTTP transport and
+// sets the server name and ALPN protocols of the check.
+func tlsBaseConfig(env Environment, serverName string, alpn []string) *tls.Config {
+	config := &tls.Config{}
+	if env.HttpClient != nil {
+		if base, ok := env.HttpClient.Transport.(*http.Transport); ok && base != nil && base.TLSClientConfig != nil {
+			config = base.TLSClientConfig.Clone()
+		}
+	}
+	config.ServerName = serverName
+	config.NextProtos = alpn
+	return config
+}
+
+// evaluateLeaves applies check to every leaf; the assertion passes when
+// check returns no message for any of them. Messages name the key type of
+// the failing leaf when leaves were probed by key type.
+func evaluateLeaves(result AssertionResult, leaves []tlsLeaf, check func(tlsLeaf) string) AssertionResult {
+	if len(leaves) == 0 {
+		result.Passed = false
+		result.Message = "no peer certificates"
+		return result
+	}
+	var failures []string
+	for _, leaf := range leaves {
+		msg := check(leaf)
+		if msg == "" {
+			continue
+		}
+		if leaf.keyType != "" {
+			msg = leaf.keyType + ": " + msg
+		}
+		failures = append(failures, msg)
+	}
+	result.Passed = len(failures) == 0
+	result.Message = strings.Join(failures, "; ")
+	return result
+}
+
+// evaluateIssuer compares the issuer common name with equals, or the full
+// issuer distinguished name with contains.
+func evaluateIssuer(cert *x509.Certificate, expect, op string) string {
+	switch strings.ToLower(op) {
+	case "", "equals", "equal", "==":
+		if cert.Issuer.CommonName == expect {
+			return ""
+		}
+		return fmt.Sprintf("issuer %q, expected %q", cert.Issuer.CommonName, expect)
+	case "contains":
+		if strings.Contains(cert.Issuer.String(), expect) {
+			return ""
+		}
+		return fmt.Sprintf("issuer %q does not contain %q", cert.Issuer.String(), expect)
+	default:
+		return fmt.Sprintf("unsupported ssl_issuer op %q", op)
+	}
+}
+
+func leafMetadata(leaves []tlsLeaf) []map[string]any {
+	certs := make([]map[string]any, 0, len(leaves))
+	for _, leaf := range leaves {
+		entry := map[string]any{
+			"subject":   leaf.cert.Subject.CommonName,
+			"issuer":    leaf.cert.Issuer.CommonName,
+			"serial":    leaf.cert.SerialNumber.String(),
+			"not_after": leaf.cert.NotAfter.UTC().Format(time.RFC3339),
+			"key_type":  publicKeyType(leaf.cert),
+		}
+		certs = append(certs, entry)
+	}
+	return certs
+}
+
+func publicKeyType(cert *x509.Certificate) string {
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		return "rsa"
+	case x509.ECDSA:
+		return "ecdsa"
+	case x509.Ed25519:
+		return "ed25519"
+	default:
+		return strings.ToLower(cert.PublicKeyAlgorithm.String())
+	}
+}
@@ -0,0 +1,234 @@
+package checks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// issueLeaf signs a certificate for 127.0.0.1 with the PKI's CA.
+func (p testPKI) issueLeaf(t *testing.T, key crypto.Signer, serial int64, notAfter time.Time) tls.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, key.Public(), p.caKey)
+	if err != nil {
+		t.Fatalf("create leaf: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, p.ca.Raw}, PrivateKey: key}
+}
+
+// startDualCertServer serves an ECDSA leaf valid for 90 days and an RSA leaf
+// valid for rsaDays days, and returns its address and a client trusting the
+// CA.
+func startDualCertServer(t *testing.T, rsaDays int) (string, *http.Client) {
+	t.Helper()
+	pki := newTestPKI(t, "")
+	// The test CA expires in an hour; leaves outliving it are fine because
+	// verification happens now.
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ecdsa key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	certs := []tls.Certificate{
+		pki.issueLeaf(t, ecKey, 10, time.Now().Add(90*24*time.Hour)),
+		pki.issueLeaf(t, rsaKey, 11, time.Now().Add(time.Duration(rsaDays)*24*time.Hour)),
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certs})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	return ln.Addr().String(), client
+}
+
+func runDualCertCheck(addr string, client *http.Client, keyTypes []string, assertions ...config.Assertion) Result {
+	cfg := config.CheckConfig{
+		ID:          "dual-cert",
+		Type:        "tls",
+		Target:      addr,
+		TLSKeyTypes: keyTypes,
+		Assertions:  assertions,
+	}
+	return Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+}
+
+func TestTLSCheckEvaluatesEveryKeyType(t *testing.T) {
+	addr, client := startDualCertServer(t, 5)
+	validDays := config.Assertion{Kind: "ssl_valid_days", Op: "greater_than", Value: 14}
+	issuer := config.Assertion{Kind: "ssl_issuer", Op: "equals", Value: "test ca"}
+
+	result := runDualCertCheck(addr, client, nil, validDays, issuer)
+	if !result.Success {
+		t.Fatalf("expected default handshake to see only the healthy ecdsa leaf, got %v %+v", result.Error, result.AssertionResults)
+	}
+
+	result = runDualCertCheck(addr, client, []string{"rsa", "ecdsa"}, validDays, issuer)
+	if result.Success {
+		t.Fatalf("expected expiring rsa leaf to fail the check")
+	}
+	if result.Reason != ReasonTLSExpiring {
+		t.Fatalf("expected reason %q, got %q", ReasonTLSExpiring, result.Reason)
+	}
+	if msg := result.AssertionResults[0].Message; !strings.HasPrefix(msg, "rsa: cert expires in") || strings.Contains(msg, "ecdsa") {
+		t.Fatalf("expected only the rsa leaf to fail, got %q", msg)
+	}
+	if !result.AssertionResults[1].Passed {
+		t.Fatalf("expected issuer to match on both leaves, got %+v", result.AssertionResults[1])
+	}
+
+	certs, _ := result.Metadata["certificates"].([]map[string]any)
+	if len(certs) != 2 || certs[0]["key_type"] != "rsa" || certs[1]["key_type"] != "ecdsa" {
+		t.Fatalf("expected both leaves in metadata, got %v", result.Metadata["certificates"])
+	}
+	if certs[0]["serial"] == certs[1]["serial"] {
+		t.Fatalf("expected distinct leaves, got %v", certs)
+	}
+}
+
+func TestTLSCheckRejectsUnknownKeyType(t *testing.T) {
+	result := runDualCertCheck("127.0.0.1:443", nil, []string{"dsa"})
+	if result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected config error, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
+
+func TestTLSCheckSupportsLatencyAssertion(t *testing.T) {
+	addr, client := startDualCertServer(t, 90)
+
+	result := runDualCertCheck(addr, client, nil, config.Assertion{Kind: "latency_ms", Op: "less_than", Value: 5000})
+	if !result.Success {
+		t.Fatalf("expected latency_ms to pass, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if result.Latency <= 0 {
+		t.Fatalf("expected tls run to record its latency, got %s", result.Latency)
+	}
+
+	result = runDualCertCheck(addr, client, nil, config.Assertion{Kind: "latency_ms", Op: "less_than", Value: 0})
+	if result.Success || result.Reason != ReasonLatencyExceeded {
+		t.Fatalf("expected latency_exceeded, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
+
+// startResumptionServer serves an ECDSA leaf and issues session tickets
+// unless ticketsDisabled is set.
+func startResumptionServer(t *testing.T, ticketsDisabled bool) (string, *http.Client) {
+	t.Helper()
+	pki := newTestPKI(t, "")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ecdsa key: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates:           []tls.Certificate{pki.issueLeaf(t, key, 20, time.Now().Add(90*24*time.Hour))},
+		SessionTicketsDisabled: ticketsDisabled,
+	})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	return ln.Addr().String(), client
+}
+
+func TestTLSCheckReportsSessionResumption(t *testing.T) {
+	resumed := config.Assertion{Kind: "tls_resumed", Op: "equals", Value: true}
+
+	addr, client := startResumptionServer(t, false)
+	result := runDualCertCheck(addr, client, nil, resumed)
+	if !result.Success {
+		t.Fatalf("expected server with session tickets to resume, got %v %+v %v", result.Error, result.AssertionResults, result.Metadata)
+	}
+	if result.Metadata["session_resumed"] != true {
+		t.Fatalf("expected session_resumed metadata, got %v", result.Metadata)
+	}
+	if _, ok := result.Metadata["resumed_handshake_ms"]; !ok {
+		t.Fatalf("expected resumed_handshake_ms metadata, got %v", result.Metadata)
+	}
+
+	addr, client = startResumptionServer(t, true)
+	result = runDualCertCheck(addr, client, nil, resumed)
+	if result.Success || result.Reason != ReasonTLSError {
+		t.Fatalf("expected tls_error without session tickets, got success=%v reason=%q", result.Success, result.Reason)
+	}
+	if msg := result.AssertionResults[0].Message; msg != "server did not resume the session" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+
+	result = runDualCertCheck(addr, client, nil, config.Assertion{Kind: "tls_resumed", Op: "equals", Value: false})
+	if !result.Success || result.Metadata["session_resumed"] != false {
+		t.Fatalf("expected tls_resumed: false to pass, got %+v %v", result.AssertionResults, result.Metadata)
+	}
+}
+
+func TestTLSCheckSkipsResumptionByDefault(t *testing.T) {
+	addr, client := startResumptionServer(t, false)
+	result := runDualCertCheck(addr, client, nil)
+	if _, ok := result.Metadata["session_resumed"]; ok {
+		t.Fatalf("expected no resumption probe without tls_session_cache, got %v", result.Metadata)
+	}
+
+	cfg := config.CheckConfig{ID: "resume", Type: "tls", Target: addr, TLSSessionCache: true}
+	result = Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: client})
+	if !result.Success || result.Metadata["session_resumed"] != true {
+		t.Fatalf("expected tls_session_cache to record resumption, got %v %v", result.Error, result.Metadata)
+	}
+}
@@ -2,6 +2,7 @@ package checks
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/osbits/upupup/worker/internal/config"
@@ -18,15 +19,69 @@ type Result struct {
 	AssertionResults []AssertionResult
 	Error            error
 	Metadata         map[string]any
+	// Reason is a machine-readable failure code such as "dns_error" or
+	// "status_mismatch"; empty on success.
+	Reason string
+	// Severity overrides the default "critical" event severity of a failed
+	// run; metrics checks set it to the worst breached threshold severity.
+	Severity string
+	// Maintenance is set when the target answered with its configured
+	// maintenance response. Such runs are successful but leave the alerting
+	// state of the check unchanged.
+	Maintenance bool
+	// Pending is set while a metrics check waits for the first snapshot of
+	// its node within first_run_grace. Like maintenance runs, pending runs
+	// are successful but leave the alerting state of the check unchanged.
+	Pending bool
+	// BaselineEstablished is set when a baseline_deviation assertion stored
+	// this run's values as the check's new baseline.
+	BaselineEstablished bool
+	// RetryAfter is set when the target asked to be left alone until then.
+	RetryAfter time.Time
+	// Targets holds per-backend outcomes of pooled checks.
+	Targets []TargetResult
+}
+
+// Event severities of a failed run, in increasing order.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{SeverityInfo: 1, SeverityWarning: 2, SeverityCritical: 3}
+
+// SeverityRank orders severities from info (1) to critical (3). Unknown
+// severities rank 0.
+func SeverityRank(severity string) int {
+	return severityRank[strings.ToLower(strings.TrimSpace(severity))]
+}
+
+// TargetResult captures the outcome against one backend of a pooled check.
+type TargetResult struct {
+	Target  string
+	Success bool
+	Latency time.Duration
+	Error   string
+	Reason  string
 }
 
 // AssertionResult captures the outcome of a single assertion.
 type AssertionResult struct {
+	ID      string
 	Kind    string
 	Op      string
 	Path    string
 	Passed  bool
 	Message string
+	// Actual is the observed value a comparison was made against, e.g. the
+	// latency in milliseconds. It is nil for assertions that don't record it.
+	Actual interface{}
+	// Warning marks a result that reports a problem without failing the check.
+	Warning bool
+	// Skipped marks an assertion whose when condition did not hold. It is
+	// not evaluated and counts as passed, so it never fails the check.
+	Skipped bool
 }
 
 // Executor executes a configured check.
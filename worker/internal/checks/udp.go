@@ -0,0 +1,168 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// udpHexPrefix marks a request body given as hex bytes, e.g. "hex:1b00".
+const udpHexPrefix = "hex:"
+
+// udpMaxDatagram is the largest datagram a udp check reads.
+const udpMaxDatagram = 64 * 1024
+
+// runUDP sends the request body, if any, to the target over UDP and waits
+// for one datagram back within the effective timeout. Without a udp_responded
+// assertion the check requires a response; silence is a failed assertion
+// rather than an error.
+func runUDP(ctx context.Context, start time.Time, cfg config.CheckConfig, env Environment) Result {
+	res := Result{
+		CheckID:          cfg.ID,
+		CheckName:        cfg.Name,
+		StartedAt:        start,
+		Metadata:         map[string]any{},
+		AssertionResults: []AssertionResult{},
+	}
+	defer func() {
+		res.CompletedAt = time.Now()
+		res.Latency = res.CompletedAt.Sub(start)
+	}()
+
+	var payload []byte
+	if cfg.Request != nil {
+		var err error
+		payload, err = udpPayload(cfg.Request.Body)
+		if err != nil {
+			res.Error = err
+			res.Reason = ReasonConfigError
+			return res
+		}
+	}
+
+	timeout := EffectiveTimeout(cfg, env.Defaults)
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", cfg.Target)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetDeadline(deadline)
+
+	sent := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		res.Error = fmt.Errorf("send datagram: %w", err)
+		return res
+	}
+	buf := make([]byte, udpMaxDatagram)
+	n, err := conn.Read(buf)
+	rtt := time.Since(sent)
+	var netErr net.Error
+	if err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		// An ICMP port unreachable surfaces here as connection refused.
+		res.Error = fmt.Errorf("read datagram: %w", err)
+		return res
+	}
+	responded := err == nil
+	response := buf[:n]
+	res.Metadata["bytes_sent"] = len(payload)
+	res.Metadata["bytes_received"] = n
+	if responded {
+		res.Metadata["rtt_ms"] = float64(rtt) / float64(time.Millisecond)
+	}
+
+	if !hasAssertion(cfg.Assertions, "udp_responded") && !responded {
+		res.AssertionResults = append(res.AssertionResults, AssertionResult{
+			Kind:    "udp_responded",
+			Op:      "equals",
+			Message: fmt.Sprintf("no response within %s", timeout),
+		})
+	}
+	for _, assertion := range cfg.Assertions {
+		result := AssertionResult{Kind: assertion.Kind, Op: assertion.Op}
+		switch strings.ToLower(assertion.Kind) {
+		case "udp_responded":
+			expect := strings.EqualFold(fmt.Sprintf("%v", assertion.Value), "true")
+			result.Passed = responded == expect
+			if !result.Passed {
+				if responded {
+					result.Message = fmt.Sprintf("received %d bytes, expected no response", n)
+				} else {
+					result.Message = fmt.Sprintf("no response within %s", timeout)
+				}
+			}
+		case "udp_response_contains":
+			result = evaluateUDPResponse(result, assertion, response, responded)
+		case "latency_ms":
+			result = evaluateLatency(assertion, rtt)
+		default:
+			result.Message = fmt.Sprintf("unsupported assertion %q", assertion.Kind)
+		}
+		res.AssertionResults = append(res.AssertionResults, result)
+	}
+	res.Success = allPassed(res.AssertionResults)
+	return res
+}
+
+// udpPayload decodes a request body, treating a hex: prefix as hex bytes
+// (whitespace allowed) and anything else as a literal string.
+func udpPayload(body string) ([]byte, error) {
+	trimmed := strings.TrimSpace(body)
+	if !strings.HasPrefix(strings.ToLower(trimmed), udpHexPrefix) {
+		return []byte(body), nil
+	}
+	digits := strings.Join(strings.Fields(trimmed[len(udpHexPrefix):]), "")
+	payload, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex payload: %w", err)
+	}
+	return payload, nil
+}
+
+// evaluateUDPResponse matches the received datagram with op contains (the
+// default) or regex. A hex: value is matched as bytes.
+func evaluateUDPResponse(result AssertionResult, assertion config.Assertion, response []byte, responded bool) AssertionResult {
+	if !responded {
+		result.Message = "no response received"
+		return result
+	}
+	expect := fmt.Sprintf("%v", assertion.Value)
+	switch strings.ToLower(assertion.Op) {
+	case "regex":
+		rx, err := regexp.Compile(expect)
+		if err != nil {
+			result.Message = fmt.Sprintf("invalid regex %q: %v", expect, err)
+			return result
+		}
+		result.Passed = rx.Match(response)
+		if !result.Passed {
+			result.Message = "regex did not match response"
+		}
+	case "", "contains":
+		needle, err := udpPayload(expect)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		result.Passed = bytes.Contains(response, needle)
+		if !result.Passed {
+			result.Message = "string not found in response"
+		}
+	default:
+		result.Message = fmt.Sprintf("unsupported op %q", assertion.Op)
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// startUDPEcho answers every datagram starting with "ping" with "pong " and
+// the rest of the datagram, and ignores anything else.
+func startUDPEcho(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if rest, ok := bytes.CutPrefix(buf[:n], []byte("ping")); ok {
+				_, _ = conn.WriteTo(append([]byte("pong"), rest...), addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func runUDPCheck(addr, body string, assertions ...config.Assertion) Result {
+	cfg := config.CheckConfig{ID: "udp", Type: "udp", Target: addr, Request: &config.HTTPRequest{Body: body}, Assertions: assertions}
+	env := Environment{Defaults: config.ServiceDefault{Timeout: config.Duration{Duration: 200 * time.Millisecond}}}
+	return Execute(context.Background(), cfg, env)
+}
+
+func TestUDPCheckMatchesResponse(t *testing.T) {
+	addr := startUDPEcho(t)
+
+	result := runUDPCheck(addr, "hex:70 69 6e 67 01",
+		config.Assertion{Kind: "udp_responded", Value: true},
+		config.Assertion{Kind: "udp_response_contains", Op: "contains", Value: "hex:706f6e6701"},
+		config.Assertion{Kind: "udp_response_contains", Op: "regex", Value: "^pong"},
+	)
+	if !result.Success {
+		t.Fatalf("expected response to match, got %v %+v", result.Error, result.AssertionResults)
+	}
+	if result.Metadata["bytes_received"] != 5 || result.Metadata["bytes_sent"] != 5 {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+	if _, ok := result.Metadata["rtt_ms"]; !ok {
+		t.Fatalf("expected rtt_ms in metadata %v", result.Metadata)
+	}
+
+	result = runUDPCheck(addr, "ping", config.Assertion{Kind: "udp_response_contains", Op: "contains", Value: "nope"})
+	if result.Success || result.Reason != ReasonBodyMismatch {
+		t.Fatalf("expected body_mismatch, got success=%v reason=%q", result.Success, result.Reason)
+	}
+}
+
+func TestUDPCheckNoResponseIsCleanFailure(t *testing.T) {
+	addr := startUDPEcho(t)
+
+	result := runUDPCheck(addr, "hello")
+	if result.Success || result.Error != nil || result.Reason != ReasonTimeout {
+		t.Fatalf("expected a failed assertion with reason timeout, got success=%v reason=%q err=%v", result.Success, result.Reason, result.Error)
+	}
+	if result.Metadata["bytes_received"] != 0 {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+
+	result = runUDPCheck(addr, "hello", config.Assertion{Kind: "udp_responded", Value: false})
+	if !result.Success {
+		t.Fatalf("expected silence to satisfy udp_responded false, got %+v", result.AssertionResults)
+	}
+
+	result = runUDPCheck(addr, "hex:zz")
+	if result.Success || result.Reason != ReasonConfigError {
+		t.Fatalf("expected invalid hex to be a config error, got reason %q", result.Reason)
+	}
+}
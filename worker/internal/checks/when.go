@@ -0,0 +1,58 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// conditionHolds evaluates the when condition of an assertion against the
+// response status and the results of the earlier assertions, keyed by ID.
+// When the condition does not hold, the returned string describes the first
+// unmet part for the skipped result.
+func conditionHolds(when *config.AssertionCondition, status int, earlier map[string]AssertionResult) (bool, string, error) {
+	if when.StatusCode != 0 && status != when.StatusCode {
+		return false, fmt.Sprintf("status %d is not %d", status, when.StatusCode), nil
+	}
+	if class := strings.TrimSpace(when.StatusClass); class != "" {
+		matched, err := statusInClass(status, class)
+		if err != nil {
+			return false, "", err
+		}
+		if !matched {
+			return false, fmt.Sprintf("status %d is not %s", status, class), nil
+		}
+	}
+	for _, ref := range []struct {
+		id   string
+		pass bool
+	}{{when.Passed, true}, {when.Failed, false}} {
+		if ref.id == "" {
+			continue
+		}
+		result, ok := earlier[ref.id]
+		if !ok {
+			return false, "", fmt.Errorf("when references unknown or later assertion %q", ref.id)
+		}
+		if result.Skipped {
+			return false, fmt.Sprintf("assertion %q was skipped", ref.id), nil
+		}
+		if passed := result.Passed || result.Warning; passed != ref.pass {
+			state := "failed"
+			if passed {
+				state = "passed"
+			}
+			return false, fmt.Sprintf("assertion %q %s", ref.id, state), nil
+		}
+	}
+	return true, "", nil
+}
+
+// skippedResult records an assertion whose when condition did not hold.
+func skippedResult(result AssertionResult, unmet string) AssertionResult {
+	result.Passed = true
+	result.Skipped = true
+	result.Message = "skipped: " + unmet
+	return result
+}
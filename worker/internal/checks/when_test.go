@@ -0,0 +1,109 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func startStatusServer(t *testing.T, status *int, body string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(*status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func runConditionalCheck(url string, assertions ...config.Assertion) Result {
+	cfg := config.CheckConfig{ID: "shapes", Type: "http", Target: url, Assertions: assertions}
+	return Execute(context.Background(), cfg, Environment{TemplateEngine: render.New(), HttpClient: http.DefaultClient})
+}
+
+func TestWhenStatusClassSkipsAssertion(t *testing.T) {
+	status := http.StatusInternalServerError
+	url := startStatusServer(t, &status, `{"error":"boom"}`)
+	jsonpathWhenOK := config.Assertion{
+		Kind: "jsonpath", Path: "$.data.id", Op: "exists",
+		When: &config.AssertionCondition{StatusClass: "2xx"},
+	}
+
+	result := runConditionalCheck(url, jsonpathWhenOK)
+	if !result.Success {
+		t.Fatalf("expected skipped assertion not to fail the check, got %+v", result.AssertionResults)
+	}
+	if got := result.AssertionResults[0]; !got.Skipped || got.Message != "skipped: status 500 is not 2xx" {
+		t.Fatalf("expected skipped result, got %+v", got)
+	}
+
+	status = http.StatusOK
+	result = runConditionalCheck(url, jsonpathWhenOK)
+	if result.Success || result.AssertionResults[0].Skipped {
+		t.Fatalf("expected evaluated jsonpath to fail on 200, got %+v", result.AssertionResults)
+	}
+	if result.Reason != ReasonBodyMismatch {
+		t.Fatalf("expected reason %q, got %q", ReasonBodyMismatch, result.Reason)
+	}
+}
+
+func TestWhenReferencesEarlierAssertion(t *testing.T) {
+	status := http.StatusOK
+	url := startStatusServer(t, &status, `{"mode":"degraded"}`)
+	assertions := []config.Assertion{
+		{ID: "healthy", Kind: "body_contains", Op: "contains", Value: `"mode":"ok"`, When: &config.AssertionCondition{StatusCode: 200}},
+		{ID: "degraded-detail", Kind: "jsonpath", Path: "$.reason", Op: "exists", When: &config.AssertionCondition{Failed: "healthy"}},
+		{Kind: "status_code", Op: "equals", Value: 200, When: &config.AssertionCondition{Passed: "degraded-detail"}},
+	}
+
+	result := runConditionalCheck(url, assertions...)
+	if result.Success {
+		t.Fatalf("expected check to fail")
+	}
+	got := result.AssertionResults
+	if got[0].Passed || got[0].Skipped || got[1].Skipped || got[1].Passed {
+		t.Fatalf("expected healthy to fail and the detail to be evaluated, got %+v", got)
+	}
+	if !got[2].Skipped || got[2].Message != `skipped: assertion "degraded-detail" failed` {
+		t.Fatalf("expected dependent assertion to be skipped, got %+v", got[2])
+	}
+
+	status = http.StatusServiceUnavailable
+	result = runConditionalCheck(url, assertions...)
+	got = result.AssertionResults
+	if !result.Success || !got[0].Skipped || !got[1].Skipped || !got[2].Skipped {
+		t.Fatalf("expected the chain to be skipped, got %+v", got)
+	}
+	if got[1].Message != `skipped: assertion "healthy" was skipped` {
+		t.Fatalf("unexpected message %q", got[1].Message)
+	}
+}
+
+func TestWhenUnknownReferenceFails(t *testing.T) {
+	status := http.StatusOK
+	url := startStatusServer(t, &status, "ok")
+	result := runConditionalCheck(url,
+		config.Assertion{Kind: "status_code", Op: "equals", Value: 200, When: &config.AssertionCondition{Passed: "later"}},
+		config.Assertion{ID: "later", Kind: "status_code", Op: "equals", Value: 200},
+	)
+	if result.Success || result.AssertionResults[0].Message != `when references unknown or later assertion "later"` {
+		t.Fatalf("expected forward reference to fail, got %+v", result.AssertionResults)
+	}
+}
+
+func TestLatencyAssertionKeepsIDForLaterConditions(t *testing.T) {
+	status := http.StatusOK
+	url := startStatusServer(t, &status, "ok")
+	result := runConditionalCheck(url,
+		config.Assertion{ID: "fast", Kind: "latency_ms", Op: "less_than", Value: 5000},
+		config.Assertion{Kind: "status_code", Op: "equals", Value: 200, When: &config.AssertionCondition{Passed: "fast"}},
+	)
+	got := result.AssertionResults
+	if !result.Success || got[0].ID != "fast" || got[1].Skipped {
+		t.Fatalf("expected the latency result to keep its id, got %+v", got)
+	}
+}
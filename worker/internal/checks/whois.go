@@ -0,0 +1,66 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// whoisRegexCache holds compiled whois.patterns regexes by source.
+var whoisRegexCache sync.Map
+
+// whoisPatternsFor returns the patterns whose TLD covers the public suffix,
+// e.g. "uk" covers "co.uk".
+func whoisPatternsFor(patterns []config.WHOISPattern, suffix string) []config.WHOISPattern {
+	suffix = strings.ToLower(suffix)
+	var out []config.WHOISPattern
+	for _, pattern := range patterns {
+		tld := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(pattern.TLD), "."))
+		if tld == suffix || strings.HasSuffix(suffix, "."+tld) {
+			out = append(out, pattern)
+		}
+	}
+	return out
+}
+
+// matchWHOISPattern reports the expiry found by pattern, if it matches. An
+// invalid regex is an error; a date that does not fit the layout is too,
+// because the pattern matched but was misconfigured.
+func matchWHOISPattern(body string, pattern config.WHOISPattern) (time.Time, bool, error) {
+	re, err := compileWHOISRegex(pattern.Regex)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("whois pattern for %q: %w", pattern.TLD, err)
+	}
+	match := re.FindStringSubmatch(body)
+	if len(match) < 2 {
+		return time.Time{}, false, nil
+	}
+	layout := pattern.Layout
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.Parse(layout, strings.TrimSpace(match[1]))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("parse expiry with whois pattern for %q: %w", pattern.TLD, err)
+	}
+	return t, true, nil
+}
+
+func compileWHOISRegex(expr string) (*regexp.Regexp, error) {
+	if cached, ok := whoisRegexCache.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("regex %q has no capture group", expr)
+	}
+	whoisRegexCache.Store(expr, re)
+	return re, nil
+}
@@ -0,0 +1,62 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+const deWHOISResponse = `Domain: example.de
+Nserver: ns1.example.net
+Status: connect
+Changed: 2024-06-01T09:12:44+02:00
+Expires: 15.03.2026
+`
+
+func TestExtractExpiryUsesTLDPattern(t *testing.T) {
+	if _, err := extractExpiry(deWHOISResponse, nil); err == nil {
+		t.Fatalf("expected the built-in regex not to parse the .de response")
+	}
+
+	patterns := whoisPatternsFor([]config.WHOISPattern{
+		{TLD: "com", Regex: `Registry Expiry Date:\s*(\S+)`},
+		{TLD: ".de", Server: "whois.example-registry.de", Regex: `(?m)^Expires:\s*(\S+)`, Layout: "02.01.2006"},
+	}, "de")
+	if len(patterns) != 1 {
+		t.Fatalf("expected only the .de pattern to apply, got %+v", patterns)
+	}
+
+	expiry, err := extractExpiry(deWHOISResponse, patterns)
+	if err != nil {
+		t.Fatalf("extract expiry: %v", err)
+	}
+	if want := time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC); !expiry.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, expiry)
+	}
+
+	server, err := whoisServerForDomain("example.de", patterns)
+	if err != nil || server != "whois.example-registry.de" {
+		t.Fatalf("expected pattern server, got %q (%v)", server, err)
+	}
+}
+
+func TestExtractExpiryFallsBackToBuiltIn(t *testing.T) {
+	body := "Registry Expiry Date: 2027-01-02T03:04:05Z\n"
+	patterns := []config.WHOISPattern{{TLD: "com", Regex: `Expires:\s*(\S+)`, Layout: "02.01.2006"}}
+
+	expiry, err := extractExpiry(body, patterns)
+	if err != nil {
+		t.Fatalf("extract expiry: %v", err)
+	}
+	if want := time.Date(2027, time.January, 2, 3, 4, 5, 0, time.UTC); !expiry.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, expiry)
+	}
+}
+
+func TestExtractExpiryRejectsPatternWithoutGroup(t *testing.T) {
+	patterns := []config.WHOISPattern{{TLD: "de", Regex: `Expires:\s*\S+`}}
+	if _, err := extractExpiry(deWHOISResponse, patterns); err == nil {
+		t.Fatalf("expected an error for a pattern without capture group")
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SkippedFile is a checks_dir file that was ignored because it is malformed.
+type SkippedFile struct {
+	Path string
+	Err  error
+}
+
+// ChecksDirPath resolves service.checks_dir relative to the config file.
+func ChecksDirPath(configPath, dir string) string {
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(configPath), dir)
+}
+
+// IsCheckFile reports whether a checks_dir entry is loaded as a check file.
+func IsCheckFile(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(base))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// loadChecksDir appends the checks defined in dir, in file name order. A file
+// holds a single check, a list of checks or a mapping with a `checks` list.
+// Malformed files and checks whose id is missing or already taken are
+// recorded in SkippedCheckFiles instead of failing the load.
+func (c *Config) loadChecksDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read checks_dir: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !IsCheckFile(entry.Name()) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]bool, len(c.Checks))
+	for _, check := range c.Checks {
+		seen[check.ID] = true
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		checks, err := readCheckFile(path)
+		if err == nil {
+			err = validateCheckIDs(checks, seen)
+		}
+		if err != nil {
+			c.SkippedCheckFiles = append(c.SkippedCheckFiles, SkippedFile{Path: path, Err: err})
+			continue
+		}
+		for _, check := range checks {
+			seen[check.ID] = true
+		}
+		c.Checks = append(c.Checks, checks...)
+	}
+	return nil
+}
+
+func readCheckFile(path string) ([]CheckConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	var checks []CheckConfig
+	switch {
+	case root.Kind == yaml.SequenceNode:
+		err = root.Decode(&checks)
+	case root.Kind == yaml.MappingNode && mappingValue(root, "checks") != nil:
+		err = mappingValue(root, "checks").Decode(&checks)
+	case root.Kind == yaml.MappingNode:
+		var check CheckConfig
+		err = root.Decode(&check)
+		checks = []CheckConfig{check}
+	default:
+		err = errors.New("expected a check, a list of checks or a checks mapping")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+func validateCheckIDs(checks []CheckConfig, seen map[string]bool) error {
+	ids := make(map[string]bool, len(checks))
+	for idx, check := range checks {
+		switch {
+		case strings.TrimSpace(check.ID) == "":
+			return fmt.Errorf("check %d has no id", idx)
+		case seen[check.ID] || ids[check.ID]:
+			return fmt.Errorf("duplicate check id %q", check.ID)
+		}
+		ids[check.ID] = true
+	}
+	return nil
+}
@@ -3,19 +3,132 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Load reads configuration from a YAML file path.
 func Load(path string) (*Config, error) {
+	return LoadForEnv(path, "")
+}
+
+// LoadForEnv reads the base configuration and, when env is set, deep-merges
+// the overrides.<env>.yml file found next to it on top:
+//   - mappings are merged key by key, scalars in the overlay replace the base,
+//   - lists whose items all carry an `id` (checks, notifiers, policies) are
+//     merged by id, unknown ids are appended,
+//   - any other list in the overlay replaces the base list.
+func LoadForEnv(path, env string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	var base yaml.Node
+	if err := yaml.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	env = strings.TrimSpace(env)
+	if env != "" {
+		overlayPath := OverlayPath(path, env)
+		overlayData, err := os.ReadFile(overlayPath)
+		if err != nil {
+			return nil, fmt.Errorf("read %s overlay: %w", env, err)
+		}
+		var overlay yaml.Node
+		if err := yaml.Unmarshal(overlayData, &overlay); err != nil {
+			return nil, fmt.Errorf("parse %s overlay: %w", env, err)
+		}
+		if len(overlay.Content) > 0 {
+			if len(base.Content) == 0 {
+				base = overlay
+			} else {
+				mergeNodes(base.Content[0], overlay.Content[0])
+			}
+		}
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if len(base.Content) == 0 {
+		return &cfg, nil
+	}
+	if err := base.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
+	if cfg.Service.ChecksDir != "" {
+		if err := cfg.loadChecksDir(ChecksDirPath(path, cfg.Service.ChecksDir)); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadTemplateFiles(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	cfg.resolveSchemaPaths(filepath.Dir(path))
 	return &cfg, nil
 }
+
+// OverlayPath returns the location of the overlay for env next to the base config.
+func OverlayPath(path, env string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf("overrides.%s.yml", env))
+}
+
+func mergeNodes(dst, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if existing := mappingValue(dst, key.Value); existing != nil {
+				mergeNodes(existing, value)
+				continue
+			}
+			dst.Content = append(dst.Content, key, value)
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode && keyedByID(dst) && keyedByID(src):
+		for _, item := range src.Content {
+			id := mappingValue(item, "id").Value
+			if existing := itemByID(dst, id); existing != nil {
+				mergeNodes(existing, item)
+				continue
+			}
+			dst.Content = append(dst.Content, item)
+		}
+	default:
+		*dst = *src
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func keyedByID(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		id := mappingValue(item, "id")
+		if id == nil || id.Kind != yaml.ScalarNode || id.Value == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func itemByID(node *yaml.Node, id string) *yaml.Node {
+	for _, item := range node.Content {
+		if value := mappingValue(item, "id"); value != nil && value.Value == id {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+version: 1
+service:
+  name: Infra
+  defaults:
+    interval: 60s
+    retries: 2
+checks:
+  - id: api
+    name: API
+    type: http
+    target: https://api.example.com/health
+    assertions:
+      - kind: status_code
+        op: equals
+        value: 200
+    thresholds:
+      failure_ratio:
+        window: 5
+        fail_count: 3
+    labels:
+      team: core
+  - id: db
+    name: Database
+    type: tcp
+    target: db.example.com:5432
+`
+
+const stagingOverlay = `
+service:
+  defaults:
+    retries: 0
+checks:
+  - id: api
+    target: https://api.staging.example.com/health
+    thresholds:
+      failure_ratio:
+        fail_count: 1
+  - id: cache
+    name: Cache
+    type: tcp
+    target: cache.staging.example.com:6379
+`
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return filepath.Join(dir, "config.yml")
+}
+
+func TestLoadForEnvMergesOverlay(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{
+		"config.yml":            baseConfig,
+		"overrides.staging.yml": stagingOverlay,
+	})
+
+	cfg, err := LoadForEnv(path, "staging")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.Service.Defaults.Retries != 0 {
+		t.Fatalf("expected retries overridden to 0, got %d", cfg.Service.Defaults.Retries)
+	}
+	if cfg.Service.Defaults.Interval.Duration != time.Minute {
+		t.Fatalf("expected interval kept from base, got %s", cfg.Service.Defaults.Interval.Duration)
+	}
+	if len(cfg.Checks) != 3 {
+		t.Fatalf("expected 3 checks after merge, got %d", len(cfg.Checks))
+	}
+
+	api := cfg.Checks[0]
+	if api.ID != "api" || api.Target != "https://api.staging.example.com/health" {
+		t.Fatalf("unexpected api check: %+v", api)
+	}
+	ratio := api.Thresholds.FailureRatio
+	if ratio == nil || ratio.FailCount != 1 || ratio.Window != 5 {
+		t.Fatalf("expected tightened fail_count with base window, got %+v", ratio)
+	}
+	if api.Name != "API" || api.Labels["team"] != "core" || len(api.Assertions) != 1 {
+		t.Fatalf("expected untouched api fields to be kept, got %+v", api)
+	}
+	if cfg.Checks[1].ID != "db" || cfg.Checks[1].Target != "db.example.com:5432" {
+		t.Fatalf("expected db check unchanged, got %+v", cfg.Checks[1])
+	}
+	if cfg.Checks[2].ID != "cache" {
+		t.Fatalf("expected overlay-only check appended, got %+v", cfg.Checks[2])
+	}
+}
+
+func TestLoadForEnvReplacesListsWithoutIDs(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{
+		"config.yml": baseConfig,
+		"overrides.prod.yml": `
+checks:
+  - id: api
+    assertions:
+      - kind: status_class
+        op: equals
+        value: 2xx
+      - kind: latency_ms
+        op: less_than
+        value: 500
+`,
+	})
+
+	cfg, err := LoadForEnv(path, "prod")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	assertions := cfg.Checks[0].Assertions
+	if len(assertions) != 2 || assertions[0].Kind != "status_class" || assertions[1].Kind != "latency_ms" {
+		t.Fatalf("expected overlay assertions to replace base list, got %+v", assertions)
+	}
+}
+
+func TestLoadForEnvMissingOverlay(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{"config.yml": baseConfig})
+	if _, err := LoadForEnv(path, "staging"); err == nil {
+		t.Fatalf("expected error for missing overlay")
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load without env: %v", err)
+	}
+	if cfg.Checks[0].Thresholds.FailureRatio.FailCount != 3 {
+		t.Fatalf("expected base threshold without overlay")
+	}
+}
+
+func TestLoadForEnvReadsChecksDir(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{
+		"config.yml": `
+service:
+  name: Infra
+  checks_dir: checks.d
+checks:
+  - id: api
+    type: http
+    target: https://api.example.com/health
+`,
+	})
+	dir := filepath.Join(filepath.Dir(path), "checks.d")
+	files := map[string]string{
+		"a-single.yml": "id: web\ntype: http\ntarget: https://www.example.com\n",
+		"b-list.yaml":  "- id: dns\n  type: dns\n  target: example.com\n",
+		"c-broken.yml": "id: [unterminated\n",
+		"d-dup.yml":    "checks:\n  - id: api\n    type: tcp\n    target: db:5432\n",
+		"notes.txt":    "not a check",
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := LoadForEnv(path, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var ids []string
+	for _, check := range cfg.Checks {
+		ids = append(ids, check.ID)
+	}
+	if got := strings.Join(ids, ","); got != "api,web,dns" {
+		t.Fatalf("expected checks api,web,dns, got %s", got)
+	}
+	if len(cfg.SkippedCheckFiles) != 2 {
+		t.Fatalf("expected 2 skipped files, got %+v", cfg.SkippedCheckFiles)
+	}
+	if base := filepath.Base(cfg.SkippedCheckFiles[0].Path); base != "c-broken.yml" {
+		t.Fatalf("expected malformed file skipped, got %s", base)
+	}
+	if base := filepath.Base(cfg.SkippedCheckFiles[1].Path); base != "d-dup.yml" {
+		t.Fatalf("expected duplicate id file skipped, got %s", base)
+	}
+}
+
+func TestLoadReadsNotifierTemplateFiles(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{
+		"config.yml": `
+notifiers:
+  - id: hook
+    type: webhook
+    config:
+      url: https://hooks.example.com
+      template_file: templates/incident.tmpl
+  - id: chat
+    type: slack
+    config:
+      firing_template_file: templates/incident.tmpl
+      resolved_template: "resolved {{ .check.name }}"
+`,
+	})
+	dir := filepath.Join(filepath.Dir(path), "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "incident.tmpl"), []byte("{{ .summary }}\n"), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	hook, chat := cfg.Notifiers[0].Config, cfg.Notifiers[1].Config
+	if hook["template"] != "{{ .summary }}\n" || chat["firing_template"] != "{{ .summary }}\n" {
+		t.Fatalf("expected template files to be inlined, got %v and %v", hook, chat)
+	}
+	if _, ok := hook["template_file"]; ok {
+		t.Fatalf("expected template_file to be removed, got %v", hook)
+	}
+	if chat["resolved_template"] != "resolved {{ .check.name }}" {
+		t.Fatalf("expected inline template to stay, got %v", chat)
+	}
+}
+
+func TestLoadRejectsTemplateAndTemplateFile(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{
+		"config.yml": `
+notifiers:
+  - id: hook
+    type: webhook
+    config:
+      template: inline
+      template_file: incident.tmpl
+`,
+		"incident.tmpl": "from file",
+	})
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "set either template or template_file") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func TestLoadResolvesSchemaPathsAgainstConfigDir(t *testing.T) {
+	path := writeConfigFiles(t, map[string]string{
+		"config.yml": `
+assertion_sets:
+  api:
+    - kind: json_schema
+      path: schemas/set.json
+checks:
+  - id: api
+    type: http
+    target: https://example.com
+    assertions:
+      - kind: json_schema
+        path: schemas/api.json
+      - kind: json_schema
+        path: /etc/upupup/absolute.json
+      - kind: jsonpath
+        path: $.id
+`,
+	})
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	dir := filepath.Dir(path)
+	got := cfg.Checks[0].Assertions
+	if got[0].Path != filepath.Join(dir, "schemas", "api.json") || got[1].Path != "/etc/upupup/absolute.json" || got[2].Path != "$.id" {
+		t.Fatalf("unexpected assertion paths %q, %q, %q", got[0].Path, got[1].Path, got[2].Path)
+	}
+	if set := cfg.CheckAssertionSets["api"]; set[0].Path != filepath.Join(dir, "schemas", "set.json") {
+		t.Fatalf("expected assertion set schema path to resolve, got %q", set[0].Path)
+	}
+}
+
+func TestResolveSecretsFallsBackToNextSource(t *testing.T) {
+	t.Setenv("UPUPUP_TEST_SMTP", "from-env")
+	path := writeConfigFiles(t, map[string]string{"config.yml": `
+secrets:
+  SMTP_PASSWORD: [env:UPUPUP_TEST_SMTP_PRIMARY, env:UPUPUP_TEST_SMTP]
+  API_TOKEN: env:UPUPUP_TEST_SMTP
+`})
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if spec := cfg.Secrets["SMTP_PASSWORD"]; spec.Value != "UPUPUP_TEST_SMTP_PRIMARY" || len(spec.Fallbacks) != 1 {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if secrets["SMTP_PASSWORD"] != "from-env" || secrets["API_TOKEN"] != "from-env" {
+		t.Fatalf("unexpected secrets %v", secrets)
+	}
+
+	cfg.Secrets = map[string]SecretSpec{"SMTP_PASSWORD": {
+		Source:    "env",
+		Value:     "UPUPUP_TEST_MISSING_A",
+		Fallbacks: []SecretSpec{{Source: "env", Value: "UPUPUP_TEST_MISSING_B"}},
+	}}
+	_, err = cfg.ResolveSecrets()
+	if err == nil || !strings.Contains(err.Error(), `env:UPUPUP_TEST_MISSING_A: missing env var "UPUPUP_TEST_MISSING_A"; env:UPUPUP_TEST_MISSING_B`) {
+		t.Fatalf("expected every attempted source in the error, got %v", err)
+	}
+
+	cfg.Secrets["SMTP_PASSWORD"] = SecretSpec{Source: "envv", Value: "X", Fallbacks: []SecretSpec{{Source: "env", Value: "UPUPUP_TEST_SMTP"}}}
+	if _, err := cfg.ResolveSecrets(); err == nil || !strings.Contains(err.Error(), `unsupported secret source "envv"`) {
+		t.Fatalf("expected an unsupported source to fail without falling back, got %v", err)
+	}
+}
+
+func TestResolveSecretsReadsFiles(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "smtp")
+	if err := os.WriteFile(secretPath, []byte("s3cret \n\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("UPUPUP_TEST_TOKEN", "from-env")
+	path := writeConfigFiles(t, map[string]string{"config.yml": `
+secrets:
+  SMTP_PASSWORD: file:` + secretPath + `
+  API_TOKEN: [file:` + filepath.Join(dir, "missing") + `, env:UPUPUP_TEST_TOKEN]
+`})
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if secrets["SMTP_PASSWORD"] != "s3cret" || secrets["API_TOKEN"] != "from-env" {
+		t.Fatalf("unexpected secrets %q", secrets)
+	}
+
+	cfg.Secrets = map[string]SecretSpec{"SMTP_PASSWORD": {Source: "file", Value: filepath.Join(dir, "missing")}}
+	_, err = cfg.ResolveSecrets()
+	if err == nil || !strings.Contains(err.Error(), "read secret file") || !strings.Contains(err.Error(), `for secret "SMTP_PASSWORD"`) {
+		t.Fatalf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestResolveSecretsReadsVault(t *testing.T) {
+	requests := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/smtp" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"user":"alerts","password":"s3cret"},"metadata":{"version":3}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	path := writeConfigFiles(t, map[string]string{"config.yml": `
+secrets:
+  SMTP_USER: vault:secret/data/smtp#user
+  SMTP_PASSWORD: vault:secret/data/smtp#password
+`})
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if secrets["SMTP_USER"] != "alerts" || secrets["SMTP_PASSWORD"] != "s3cret" {
+		t.Fatalf("unexpected secrets %q", secrets)
+	}
+	if requests != 1 {
+		t.Fatalf("expected one vault request per path, got %d", requests)
+	}
+
+	cfg.Secrets = map[string]SecretSpec{"SMTP_PASSWORD": {
+		Source:    "vault",
+		Value:     "secret/data/smtp#token",
+		Fallbacks: []SecretSpec{{Source: "env", Value: "UPUPUP_TEST_MISSING"}},
+	}}
+	_, err = cfg.ResolveSecrets()
+	if err == nil || !strings.Contains(err.Error(), `vault path "secret/data/smtp" has no field "token"`) {
+		t.Fatalf("expected a missing field to fail without falling back, got %v", err)
+	}
+
+	cfg.Secrets["SMTP_PASSWORD"] = SecretSpec{Source: "vault", Value: "secret/data/smtp"}
+	if _, err := cfg.ResolveSecrets(); err == nil || !strings.Contains(err.Error(), "want path#field") {
+		t.Fatalf("expected a reference without field to fail, got %v", err)
+	}
+
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("UPUPUP_TEST_SMTP", "from-env")
+	cfg.Secrets["SMTP_PASSWORD"] = SecretSpec{
+		Source:    "vault",
+		Value:     "secret/data/smtp#password",
+		Fallbacks: []SecretSpec{{Source: "env", Value: "UPUPUP_TEST_SMTP"}},
+	}
+	secrets, err = cfg.ResolveSecrets()
+	if err != nil || secrets["SMTP_PASSWORD"] != "from-env" {
+		t.Fatalf("expected fallback when vault is not configured, got %q, %v", secrets, err)
+	}
+}
+
+func TestResolveSecretsSkipsUnavailableVault(t *testing.T) {
+	requests := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	t.Setenv("UPUPUP_TEST_SMTP_USER", "alerts")
+	t.Setenv("UPUPUP_TEST_SMTP_PASSWORD", "s3cret")
+
+	path := writeConfigFiles(t, map[string]string{"config.yml": `
+secrets:
+  SMTP_USER: [vault:secret/data/smtp#user, env:UPUPUP_TEST_SMTP_USER]
+  SMTP_PASSWORD: [vault:secret/data/mail#password, env:UPUPUP_TEST_SMTP_PASSWORD]
+`})
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	secrets, err := cfg.ResolveSecrets()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if secrets["SMTP_USER"] != "alerts" || secrets["SMTP_PASSWORD"] != "s3cret" {
+		t.Fatalf("expected env fallbacks, got %q", secrets)
+	}
+	if requests != 1 {
+		t.Fatalf("expected vault to be skipped once unavailable, got %d requests", requests)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// templateFileSuffix marks notifier config keys that name a file holding the
+// template of the key without the suffix, e.g. template_file for template.
+const templateFileSuffix = "_file"
+
+// loadTemplateFiles replaces every <key>template_file entry of the notifier
+// configs with the contents of the file under <key>template. Relative paths
+// resolve against dir; a file shared by several notifiers is read once.
+func (c *Config) loadTemplateFiles(dir string) error {
+	cache := map[string]string{}
+	for i := range c.Notifiers {
+		notifier := &c.Notifiers[i]
+		for key, value := range notifier.Config {
+			if !strings.HasSuffix(key, "template"+templateFileSuffix) {
+				continue
+			}
+			target := strings.TrimSuffix(key, templateFileSuffix)
+			if _, ok := notifier.Config[target]; ok {
+				return fmt.Errorf("notifier %s: set either %s or %s", notifier.ID, target, key)
+			}
+			path, ok := value.(string)
+			if !ok || strings.TrimSpace(path) == "" {
+				return fmt.Errorf("notifier %s: %s must be a file path", notifier.ID, key)
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			content, ok := cache[path]
+			if !ok {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("notifier %s: read %s: %w", notifier.ID, key, err)
+				}
+				content = string(data)
+				cache[path] = content
+			}
+			notifier.Config[target] = content
+			delete(notifier.Config, key)
+		}
+	}
+	return nil
+}
+
+// resolveSchemaPaths makes the path of every json_schema assertion absolute,
+// resolving relative paths against dir like template files, so they do not
+// depend on the worker's working directory.
+func (c *Config) resolveSchemaPaths(dir string) {
+	resolve := func(assertions []Assertion) {
+		for i := range assertions {
+			assertion := &assertions[i]
+			path := strings.TrimSpace(assertion.Path)
+			if !strings.EqualFold(assertion.Kind, "json_schema") || path == "" || filepath.IsAbs(path) {
+				continue
+			}
+			assertion.Path = filepath.Join(dir, path)
+		}
+	}
+	for i := range c.Checks {
+		resolve(c.Checks[i].Assertions)
+	}
+	for _, set := range c.CheckAssertionSets {
+		resolve(set)
+	}
+}
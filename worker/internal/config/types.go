@@ -1,12 +1,16 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/osbits/upupup/shared/computed"
+	"github.com/osbits/upupup/shared/maintenance"
 )
 
 // Duration wraps time.Duration to allow YAML unmarshalling from strings.
@@ -34,6 +38,11 @@ func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
 	}
 }
 
+// MarshalYAML renders the duration in time.Duration string form.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.Duration.String(), nil
+}
+
 // NullableDuration allows distinguishing between zero and unset durations.
 type NullableDuration struct {
 	Duration time.Duration
@@ -55,6 +64,14 @@ func (d *NullableDuration) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// MarshalYAML renders set durations as strings and unset ones as null.
+func (d NullableDuration) MarshalYAML() (interface{}, error) {
+	if !d.Set {
+		return nil, nil
+	}
+	return d.Duration.String(), nil
+}
+
 // Config is the root configuration.
 type Config struct {
 	Version              int                    `yaml:"version"`
@@ -62,17 +79,40 @@ type Config struct {
 	Secrets              map[string]SecretSpec  `yaml:"secrets"`
 	Notifiers            []NotifierConfig       `yaml:"notifiers"`
 	NotificationPolicies []NotificationPolicy   `yaml:"notification_policies"`
+	Groups               []GroupPolicy          `yaml:"groups"`
 	CheckAssertionSets   map[string][]Assertion `yaml:"assertion_sets"`
 	Checks               []CheckConfig          `yaml:"checks"`
 	Templates            map[string]interface{} `yaml:"templates"`
 	Storage              StorageConfig          `yaml:"storage"`
+	Sinks                []SinkConfig           `yaml:"sinks"`
+	Admin                AdminConfig            `yaml:"admin"`
+	WHOIS                WHOISConfig            `yaml:"whois"`
+	Exec                 ExecConfig             `yaml:"exec"`
+
+	// SkippedCheckFiles lists checks_dir files that could not be loaded.
+	SkippedCheckFiles []SkippedFile `yaml:"-"`
 }
 
 // ServiceConfig contains global settings.
 type ServiceConfig struct {
-	Name     string         `yaml:"name"`
-	Timezone string         `yaml:"timezone"`
-	Defaults ServiceDefault `yaml:"defaults"`
+	Name     string `yaml:"name"`
+	Timezone string `yaml:"timezone"`
+	// Environment names the deployment (e.g. prod, staging); notifiers can be
+	// limited to a set of environments.
+	Environment string         `yaml:"environment"`
+	Defaults    ServiceDefault `yaml:"defaults"`
+	// UIBaseURL is the dashboard address notifications link checks to, as
+	// <ui_base_url>/checks/<id>. Links are omitted when it is empty.
+	UIBaseURL string `yaml:"ui_base_url"`
+	// ChecksDir holds additional check files (*.yml, *.yaml), relative to
+	// the config file. It is watched for changes while the worker runs.
+	ChecksDir string `yaml:"checks_dir"`
+	// Audit streams every check run, passing or failing, to a webhook
+	// independent of notification policies.
+	Audit AuditConfig `yaml:"audit"`
+	// EscalationInterval is how often escalation stages of failing checks
+	// are re-evaluated between check runs. Defaults to 15s.
+	EscalationInterval Duration `yaml:"escalation_interval"`
 }
 
 // ServiceDefault defines default runtime values.
@@ -83,6 +123,26 @@ type ServiceDefault struct {
 	Backoff            Duration          `yaml:"backoff"`
 	MaintenanceWindows []MaintenanceSpec `yaml:"maintenance_windows"`
 	LogRuns            bool              `yaml:"log_runs"`
+	// MaxNotificationsPerEvent caps how many notifiers one event is sent
+	// to. Zero (the default) means no cap.
+	MaxNotificationsPerEvent int `yaml:"max_notifications_per_event"`
+	// MinInterval is the shortest interval any check may run at; shorter
+	// intervals are raised to it. Zero disables the floor.
+	MinInterval Duration `yaml:"min_interval"`
+	// BackoffStrategy is "fixed" (default) or "exponential", which doubles
+	// the backoff after each failed attempt up to BackoffMax.
+	BackoffStrategy string   `yaml:"backoff_strategy"`
+	BackoffMax      Duration `yaml:"backoff_max"`
+	// BackoffJitter sleeps a random duration between zero and the computed
+	// backoff (full jitter) so retries of many checks spread out.
+	BackoffJitter bool `yaml:"backoff_jitter"`
+	// MaxConcurrentChecks caps how many checks run at the same time; checks
+	// over the limit wait for a free slot. Zero means no limit.
+	MaxConcurrentChecks int `yaml:"max_concurrent_checks"`
+	// Jitter delays the first run of every check by a random duration below
+	// it and each later run by a fresh one, so checks with the same interval
+	// don't run in lockstep.
+	Jitter Duration `yaml:"jitter"`
 }
 
 // StorageConfig describes persistence options.
@@ -90,53 +150,110 @@ type StorageConfig struct {
 	Path                     string `yaml:"path"`
 	CheckStateRetention      int    `yaml:"check_state_retention"`
 	NotificationLogRetention int    `yaml:"notification_log_retention"`
+	// Optional keeps the worker monitoring when the database cannot be
+	// opened at startup; it runs without persistence and retries opening it
+	// every RetryInterval.
+	Optional      bool     `yaml:"optional"`
+	RetryInterval Duration `yaml:"retry_interval"`
 }
 
-// MaintenanceSpec includes cron or range expressions.
-type MaintenanceSpec struct {
-	Expr string
-	Kind MaintenanceKind
+// WHOISConfig tunes whois checks for registries the built-in parser does not
+// understand.
+type WHOISConfig struct {
+	Patterns []WHOISPattern `yaml:"patterns"`
+}
+
+// ExecConfig restricts exec checks, which run local commands as the worker
+// user. They are refused unless Enabled is set, and AllowedCommands, when
+// not empty, lists the executables (absolute paths or filepath.Match
+// patterns) they may run.
+type ExecConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	AllowedCommands []string `yaml:"allowed_commands"`
+	// InheritEnv passes the worker's environment to commands. By default
+	// they only see PATH, the check variables and their configured env.
+	InheritEnv bool `yaml:"inherit_env"`
 }
 
+// WHOISPattern extracts the expiry date of domains under TLD. Regex must
+// capture the date in its first group, which is parsed with Layout (Go time
+// layout, RFC 3339 by default). Server overrides the whois server queried for
+// the TLD.
+type WHOISPattern struct {
+	TLD    string `yaml:"tld"`
+	Server string `yaml:"server"`
+	Regex  string `yaml:"regex"`
+	Layout string `yaml:"layout"`
+}
+
+// AdminConfig configures the worker's admin/metrics listener. The listener is
+// disabled when Listen is empty and bound to localhost when no allowlist is set.
+type AdminConfig struct {
+	Listen     string          `yaml:"listen"`
+	AllowedIPs []string        `yaml:"allowed_ips"`
+	TokenRef   string          `yaml:"token_ref"`
+	TLS        *AdminTLSConfig `yaml:"tls"`
+}
+
+// AdminTLSConfig points at the certificate served by the admin listener.
+type AdminTLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// MaintenanceSpec includes cron or range expressions.
+type MaintenanceSpec = maintenance.Spec
+
 // MaintenanceKind indicates the maintenance window type.
-type MaintenanceKind string
+type MaintenanceKind = maintenance.Kind
 
 const (
-	MaintenanceKindCron  MaintenanceKind = "cron"
-	MaintenanceKindRange MaintenanceKind = "range"
+	MaintenanceKindCron  = maintenance.KindCron
+	MaintenanceKindRange = maintenance.KindRange
 )
 
-// UnmarshalYAML allows parsing "cron: ..." or "range: ...".
-func (m *MaintenanceSpec) UnmarshalYAML(value *yaml.Node) error {
-	if value.Kind != yaml.ScalarNode {
-		return fmt.Errorf("maintenance spec must be scalar, got %s", value.ShortTag())
-	}
-	raw := strings.TrimSpace(value.Value)
-	switch {
-	case strings.HasPrefix(raw, "cron:"):
-		m.Kind = MaintenanceKindCron
-		m.Expr = strings.TrimSpace(strings.TrimPrefix(raw, "cron:"))
-	case strings.HasPrefix(raw, "range:"):
-		m.Kind = MaintenanceKindRange
-		m.Expr = strings.TrimSpace(strings.TrimPrefix(raw, "range:"))
-	default:
-		return fmt.Errorf("unsupported maintenance spec %q", raw)
-	}
-	return nil
-}
-
-// SecretSpec defines how to resolve a secret.
+// SecretSpec defines how to resolve a secret. Fallbacks are tried in order
+// when Source cannot be resolved.
 type SecretSpec struct {
-	Source string
-	Value  string
+	Source    string
+	Value     string
+	Fallbacks []SecretSpec
 }
 
-// UnmarshalYAML parses secret definitions like "env:SMTP_PASSWORD".
+// UnmarshalYAML parses secret definitions like "env:SMTP_PASSWORD",
+// "file:/var/run/secrets/smtp" or "vault:secret/data/smtp#password", or a
+// list of them tried in order.
 func (s *SecretSpec) UnmarshalYAML(value *yaml.Node) error {
-	if value.Kind != yaml.ScalarNode {
-		return fmt.Errorf("secret must be scalar, got %s", value.ShortTag())
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return s.parse(value.Value)
+	case yaml.SequenceNode:
+		if len(value.Content) == 0 {
+			return fmt.Errorf("secret source list is empty")
+		}
+		*s = SecretSpec{}
+		for i, item := range value.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("secret source must be scalar, got %s", item.ShortTag())
+			}
+			var source SecretSpec
+			if err := source.parse(item.Value); err != nil {
+				return err
+			}
+			if i == 0 {
+				s.Source, s.Value = source.Source, source.Value
+				continue
+			}
+			s.Fallbacks = append(s.Fallbacks, source)
+		}
+		return nil
+	default:
+		return fmt.Errorf("secret must be scalar or a list, got %s", value.ShortTag())
 	}
-	raw := strings.TrimSpace(value.Value)
+}
+
+func (s *SecretSpec) parse(raw string) error {
+	raw = strings.TrimSpace(raw)
 	parts := strings.SplitN(raw, ":", 2)
 	if len(parts) != 2 {
 		return fmt.Errorf("invalid secret spec %q", raw)
@@ -146,29 +263,143 @@ func (s *SecretSpec) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
-// ResolveSecrets resolves secrets into a map.
+// MarshalYAML renders the spec in its "source:value" form, or as a list
+// when it has fallbacks.
+func (s SecretSpec) MarshalYAML() (interface{}, error) {
+	if len(s.Fallbacks) == 0 {
+		return s.String(), nil
+	}
+	sources := []string{s.String()}
+	for _, fallback := range s.Fallbacks {
+		sources = append(sources, fallback.String())
+	}
+	return sources, nil
+}
+
+// String returns the "source:value" form of the first source.
+func (s SecretSpec) String() string {
+	return s.Source + ":" + s.Value
+}
+
+// secretUnavailableError reports a supported source that has no value, so
+// the next fallback is tried.
+type secretUnavailableError struct {
+	reason string
+}
+
+func (e *secretUnavailableError) Error() string {
+	return e.reason
+}
+
+// ResolveSecrets resolves secrets into a map. Each secret takes the value of
+// the first of its sources that resolves; an unsupported source fails
+// immediately rather than falling through.
 func (c *Config) ResolveSecrets() (map[string]string, error) {
 	resolved := make(map[string]string, len(c.Secrets))
+	vault := &vaultClient{}
 	for key, spec := range c.Secrets {
-		switch spec.Source {
-		case "env":
-			val, ok := os.LookupEnv(spec.Value)
-			if !ok {
-				return nil, fmt.Errorf("missing env var %q for secret %q", spec.Value, key)
+		sources := append([]SecretSpec{{Source: spec.Source, Value: spec.Value}}, spec.Fallbacks...)
+		var failures []string
+		var lastErr error
+		for _, source := range sources {
+			val, err := resolveSecretSource(source, vault)
+			if err == nil {
+				resolved[key] = val
+				break
+			}
+			var unavailable *secretUnavailableError
+			if !errors.As(err, &unavailable) {
+				return nil, fmt.Errorf("%w for secret %q", err, key)
 			}
-			resolved[key] = val
-		default:
-			return nil, fmt.Errorf("unsupported secret source %q for secret %q", spec.Source, key)
+			failures = append(failures, fmt.Sprintf("%s: %v", source, err))
+			lastErr = err
+		}
+		if len(failures) < len(sources) {
+			continue
 		}
+		if len(sources) == 1 {
+			return nil, fmt.Errorf("%w for secret %q", lastErr, key)
+		}
+		return nil, fmt.Errorf("no source resolved secret %q: %s", key, strings.Join(failures, "; "))
 	}
 	return resolved, nil
 }
 
+// resolveSecretSource reads one source. A *secretUnavailableError means the
+// source has no value. vault is shared by every secret of one resolution.
+func resolveSecretSource(spec SecretSpec, vault *vaultClient) (string, error) {
+	switch spec.Source {
+	case "env":
+		val, ok := os.LookupEnv(spec.Value)
+		if !ok {
+			return "", &secretUnavailableError{reason: fmt.Sprintf("missing env var %q", spec.Value)}
+		}
+		return val, nil
+	case "file":
+		data, err := os.ReadFile(spec.Value)
+		if err != nil {
+			return "", &secretUnavailableError{reason: fmt.Sprintf("read secret file: %v", err)}
+		}
+		return strings.TrimRight(string(data), " \t\r\n"), nil
+	case "vault":
+		return vault.lookup(spec.Value)
+	default:
+		return "", fmt.Errorf("unsupported secret source %q", spec.Source)
+	}
+}
+
 // NotifierConfig describes a notification endpoint.
 type NotifierConfig struct {
 	ID     string                 `yaml:"id"`
 	Type   string                 `yaml:"type"`
 	Config map[string]interface{} `yaml:"config"`
+	// Throttle suppresses repeated notifications for the same check and
+	// status within the window.
+	Throttle Duration `yaml:"throttle,omitempty"`
+	// Enabled turns the notifier off when false; Environments limits it to
+	// the listed service environments.
+	Enabled      *bool    `yaml:"enabled"`
+	Environments []string `yaml:"environments"`
+	// MinSeverity skips events below this severity (info, warning or
+	// critical); empty delivers everything.
+	MinSeverity string `yaml:"min_severity"`
+}
+
+// ActiveIn reports whether the notifier should be built for the service environment.
+func (n NotifierConfig) ActiveIn(env string) bool {
+	if n.Enabled != nil && !*n.Enabled {
+		return false
+	}
+	if len(n.Environments) == 0 {
+		return true
+	}
+	for _, allowed := range n.Environments {
+		if strings.EqualFold(strings.TrimSpace(allowed), strings.TrimSpace(env)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditConfig configures the audit stream. It is disabled while WebhookURL
+// is empty; the remaining fields behave like those of the http sink.
+type AuditConfig struct {
+	WebhookURL    string            `yaml:"webhook_url"`
+	Headers       map[string]string `yaml:"headers"`
+	BatchSize     int               `yaml:"batch_size"`
+	FlushInterval Duration          `yaml:"flush_interval"`
+	MaxRetries    int               `yaml:"max_retries"`
+	RetryBackoff  Duration          `yaml:"retry_backoff"`
+	Timeout       Duration          `yaml:"timeout"`
+	QueueSize     int               `yaml:"queue_size"`
+}
+
+// SinkConfig describes an external destination that receives check runs and
+// notifications in addition to sqlite.
+type SinkConfig struct {
+	ID     string                 `yaml:"id"`
+	Type   string                 `yaml:"type"`
+	Config map[string]interface{} `yaml:"config"`
 }
 
 // NotificationPolicy describes an escalation chain.
@@ -179,6 +410,18 @@ type NotificationPolicy struct {
 	ResolveNotifiers []string          `yaml:"resolve_notifiers"`
 }
 
+// GroupPolicy notifies once when at least MinFailing checks of a group are
+// failing, and again when the group recovers below it.
+type GroupPolicy struct {
+	ID               string   `yaml:"id"`
+	Name             string   `yaml:"name"`
+	MinFailing       int      `yaml:"min_failing"`
+	Notifiers        []string `yaml:"notifiers"`
+	ResolveNotifiers []string `yaml:"resolve_notifiers"`
+	// SuppressMembers drops the member checks' own notifications.
+	SuppressMembers bool `yaml:"suppress_members"`
+}
+
 // PolicyStage describes a notification stage.
 type PolicyStage struct {
 	After     Duration  `yaml:"after"`
@@ -199,12 +442,87 @@ type CheckConfig struct {
 	Assertions    []Assertion       `yaml:"assertions"`
 	Thresholds    Thresholds        `yaml:"thresholds"`
 	Metrics       *MetricsCheck     `yaml:"metrics"`
+	History       *HistoryCheck     `yaml:"history"`
+	Diff          *DiffCheck        `yaml:"diff"`
+	S3            *S3Check          `yaml:"s3"`
+	Exec          *ExecCheck        `yaml:"exec"`
+	ICMP          *ICMPCheck        `yaml:"icmp"`
+	GRPC          *GRPCCheck        `yaml:"grpc"`
 	Labels        map[string]string `yaml:"labels"`
+	Group         string            `yaml:"group"`
 	Notifications CheckNotification `yaml:"notifications"`
 	Resolver      string            `yaml:"resolver"`
 	RecordType    string            `yaml:"record_type"`
 	SNI           string            `yaml:"sni"`
-	LogRuns       *bool             `yaml:"log_runs"`
+	ALPN          []string          `yaml:"alpn"`
+	// TLSKeyTypes makes tls checks handshake once per key type (rsa, ecdsa)
+	// so every certificate of a dual-cert server is evaluated.
+	TLSKeyTypes   []string    `yaml:"tls_key_types"`
+	Protocol      string      `yaml:"protocol"`
+	Proxy         string      `yaml:"proxy"`
+	NoProxy       []string    `yaml:"no_proxy"`
+	TargetFromSRV *SRVTarget  `yaml:"target_from_srv"`
+	Pool          *PoolConfig `yaml:"pool"`
+	LogRuns       *bool       `yaml:"log_runs"`
+
+	// ExpectedMaintenanceStatus marks responses of a planned maintenance
+	// page; they are reported as maintenance instead of failing the check.
+	ExpectedMaintenanceStatus *MaintenanceStatus `yaml:"expected_maintenance_status"`
+	// Resolvers queries every listed resolver instead of Resolver, e.g. the
+	// internal and external views of split-horizon DNS.
+	Resolvers []DNSResolver `yaml:"resolvers"`
+	// FailureSummaryTemplate replaces the generated summary of a failed run
+	// in notifications and the stored check run.
+	FailureSummaryTemplate string `yaml:"failure_summary_template"`
+	// TLSSessionCache makes tls checks handshake a second time with the
+	// session of the first to find out whether the server resumes sessions.
+	TLSSessionCache bool `yaml:"tls_session_cache"`
+	// ExpectFailure inverts the check: a run passes only when it fails with
+	// this reason code (e.g. connection_refused, timeout), or with any
+	// reason when set to "any".
+	ExpectFailure string `yaml:"expect_failure"`
+	// LatencyChange fails a run when the check's recent median latency rose
+	// too much compared with an earlier window of its own history.
+	LatencyChange *LatencyChange `yaml:"latency_change"`
+}
+
+// LatencyChange compares the median latency of the check's successful runs
+// within Window with the median of the same window Offset earlier, e.g. the
+// same hour a week ago.
+type LatencyChange struct {
+	Window Duration `yaml:"window"`
+	Offset Duration `yaml:"offset"`
+	// MaxIncreasePercent is the largest tolerated rise of the median, e.g.
+	// 100 for "latency doubled".
+	MaxIncreasePercent float64 `yaml:"max_increase_percent"`
+	// MinRuns is how many successful runs each window needs before they are
+	// compared. Defaults to 5.
+	MinRuns int `yaml:"min_runs"`
+}
+
+// DNSResolver is one resolver of a dns check with several resolvers. Its
+// assertions apply to its answer in addition to the check's assertions.
+type DNSResolver struct {
+	Name       string      `yaml:"name"`
+	Address    string      `yaml:"address"`
+	Assertions []Assertion `yaml:"assertions"`
+}
+
+// SRVTarget resolves the host:port of a check from a DNS SRV record at run time.
+type SRVTarget struct {
+	Name     string `yaml:"name"`
+	Resolver string `yaml:"resolver"`
+	All      bool   `yaml:"all"`
+}
+
+// PoolConfig probes every backend of an HTTP check individually. Targets are
+// host or host:port addresses dialled in place of the URL host; ResolveAll adds
+// every address the URL host resolves to. The check passes while at least
+// NHealthy backends are healthy (default: all of them).
+type PoolConfig struct {
+	Targets    []string `yaml:"targets"`
+	ResolveAll bool     `yaml:"resolve_all"`
+	NHealthy   int      `yaml:"n_healthy"`
 }
 
 // CheckSchedule customizing schedule per check.
@@ -213,6 +531,28 @@ type CheckSchedule struct {
 	Timeout  *NullableDuration `yaml:"timeout"`
 	Retries  *int              `yaml:"retries"`
 	Backoff  *NullableDuration `yaml:"backoff"`
+	// Cron runs the check at the activations of a standard five-field cron
+	// expression instead of every Interval; the two are mutually exclusive.
+	Cron string `yaml:"cron"`
+	// Jitter overrides the service default jitter.
+	Jitter *NullableDuration `yaml:"jitter"`
+	// BackoffStrategy, BackoffMax and BackoffJitter override the service
+	// defaults of the same name.
+	BackoffStrategy string            `yaml:"backoff_strategy"`
+	BackoffMax      *NullableDuration `yaml:"backoff_max"`
+	BackoffJitter   *bool             `yaml:"backoff_jitter"`
+	// RespectRetryAfter defers the next run while a 429/503 response's
+	// Retry-After is in effect, capped by MaxRetryAfter (default 1h).
+	RespectRetryAfter bool              `yaml:"respect_retry_after"`
+	MaxRetryAfter     *NullableDuration `yaml:"max_retry_after"`
+	CircuitBreaker    *CircuitBreaker   `yaml:"circuit_breaker"`
+}
+
+// CircuitBreaker pauses a check for Cooldown after Failures consecutive
+// connection errors.
+type CircuitBreaker struct {
+	Failures int      `yaml:"failures"`
+	Cooldown Duration `yaml:"cooldown"`
 }
 
 // HTTPRequest describes an HTTP request template.
@@ -222,6 +562,34 @@ type HTTPRequest struct {
 	Headers map[string]string `yaml:"headers"`
 	Body    string            `yaml:"body"`
 	Timeout *NullableDuration `yaml:"timeout"`
+	// MaxJSONBytes fails jsonpath evaluation for larger bodies instead of
+	// decoding them (0 = unlimited).
+	MaxJSONBytes int64 `yaml:"max_json_bytes"`
+	// JSONExactNumbers decodes JSON numbers as json.Number so large integers
+	// compare exactly instead of going through float64.
+	JSONExactNumbers bool `yaml:"json_exact_numbers"`
+	// Timing records the DNS, connect, TLS handshake and time-to-first-byte
+	// phases of the request in the run metadata.
+	Timing bool `yaml:"timing"`
+	// ConnectTimeout and TLSTimeout bound the dial and the TLS handshake
+	// separately from Timeout, which covers the whole request.
+	ConnectTimeout Duration `yaml:"connect_timeout"`
+	TLSTimeout     Duration `yaml:"tls_timeout"`
+	// FollowRedirects set to false returns the first response, so assertions
+	// see a redirect itself instead of where it leads. Unset follows.
+	FollowRedirects *bool `yaml:"follow_redirects"`
+	// Auth sets the Authorization header from secrets. An explicit
+	// Authorization header in Headers wins.
+	Auth *HTTPAuth `yaml:"auth"`
+}
+
+// HTTPAuth authenticates an HTTP request: type basic sends Username and the
+// secret named by PasswordRef, type bearer the secret named by TokenRef.
+type HTTPAuth struct {
+	Type        string `yaml:"type"`
+	Username    string `yaml:"username"`
+	PasswordRef string `yaml:"password_ref"`
+	TokenRef    string `yaml:"token_ref"`
 }
 
 // PreAuthConfig defines an authentication flow prior to running the check.
@@ -240,10 +608,28 @@ type CaptureSpec struct {
 
 // Assertion expresses an expectation.
 type Assertion struct {
+	// ID names the assertion so the when condition of a later one can
+	// refer to its outcome.
+	ID    string      `yaml:"id"`
 	Kind  string      `yaml:"kind"`
 	Op    string      `yaml:"op"`
 	Path  string      `yaml:"path"`
 	Value interface{} `yaml:"value"`
+	// When skips the assertion unless the condition holds.
+	When *AssertionCondition `yaml:"when"`
+	// MessageTemplate replaces the built-in message of a failed assertion.
+	MessageTemplate string `yaml:"message_template"`
+}
+
+// AssertionCondition gates an assertion of an HTTP check. Every field that is
+// set must hold: the response status must equal StatusCode and fall in
+// StatusClass, and the earlier assertions named by Passed and Failed must
+// have passed or failed. A skipped assertion has neither passed nor failed.
+type AssertionCondition struct {
+	StatusCode  int    `yaml:"status_code"`
+	StatusClass string `yaml:"status_class"`
+	Passed      string `yaml:"passed"`
+	Failed      string `yaml:"failed"`
 }
 
 // Thresholds describes alerting thresholds.
@@ -257,12 +643,76 @@ type FailureRatioThreshold struct {
 	FailCount int `yaml:"fail_count"`
 }
 
+// HistoryCheck evaluates the stored run history of another check over a
+// trailing window. CheckID defaults to the check target.
+type HistoryCheck struct {
+	CheckID string   `yaml:"check_id"`
+	Window  Duration `yaml:"window"`
+}
+
+// MaintenanceStatus matches an HTTP response signalling planned maintenance:
+// the status code must equal Status and, when BodyContains is set, the body
+// must contain it.
+type MaintenanceStatus struct {
+	Status       int    `yaml:"status"`
+	BodyContains string `yaml:"body_contains"`
+}
+
+// DiffCheck compares the response of the check target with a secondary URL
+// fetched with the same request. Without JSONPaths the full bodies must be
+// identical; otherwise only the listed jsonpath values are compared.
+type DiffCheck struct {
+	Secondary string   `yaml:"secondary"`
+	JSONPaths []string `yaml:"jsonpaths"`
+}
+
+// GRPCCheck configures a grpc check. Service is the name passed to the
+// grpc.health.v1.Health/Check RPC; empty queries the server as a whole.
+type GRPCCheck struct {
+	Service string `yaml:"service"`
+	TLS     bool   `yaml:"tls"`
+}
+
+// ICMPCheck configures icmp checks. Families (ipv4, ipv6) makes the check
+// ping the A and AAAA addresses of the target separately.
+type ICMPCheck struct {
+	Families []string `yaml:"families"`
+}
+
+// ExecCheck runs a local command. Command is the argv, without a shell; Env
+// values are templates and may reference secrets.
+type ExecCheck struct {
+	Command []string          `yaml:"command"`
+	Dir     string            `yaml:"dir"`
+	Env     map[string]string `yaml:"env"`
+}
+
+// S3Check locates an object in S3-compatible storage. Endpoint defaults to
+// AWS S3; any other endpoint, such as MinIO, is addressed path-style. Key and
+// the credentials are rendered as templates, so they can reference secrets.
+type S3Check struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	Key             string `yaml:"key"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+}
+
 // MetricsCheck configures a metrics-based check.
 type MetricsCheck struct {
-	NodeID     string                    `yaml:"node_id"`
-	MaxAge     *NullableDuration         `yaml:"max_age"`
+	NodeID string            `yaml:"node_id"`
+	MaxAge *NullableDuration `yaml:"max_age"`
+	// StaleFatal controls whether a snapshot older than MaxAge fails the check
+	// outright (default) or only adds a warning next to the threshold results.
+	StaleFatal *bool                     `yaml:"stale_fatal"`
 	Thresholds []MetricThreshold         `yaml:"thresholds"`
 	Computed   map[string]ComputedMetric `yaml:"computed"`
+	// FirstRunGrace is how long a node that has never reported may go
+	// without a snapshot, counted from the check's first run without one,
+	// before the check fails instead of being reported as pending.
+	FirstRunGrace Duration `yaml:"first_run_grace,omitempty"`
 }
 
 // MetricThreshold defines an individual metric expectation.
@@ -271,30 +721,32 @@ type MetricThreshold struct {
 	Op     string            `yaml:"op"`
 	Value  float64           `yaml:"value"`
 	Labels map[string]string `yaml:"labels"`
+	// Severity is the event severity when this threshold is breached:
+	// "warning" or "critical" (default).
+	Severity string `yaml:"severity"`
+	// For is how long the threshold must stay breached before it fails the
+	// check; shorter breaches are reported as warnings.
+	For Duration `yaml:"for,omitempty"`
 }
 
 // ComputedMetric defines a derived metric calculated from other metrics.
-type ComputedMetric struct {
-	Expression  string                     `yaml:"expression"`
-	Variables   map[string]MetricReference `yaml:"variables"`
-	Labels      map[string]string          `yaml:"labels"`
-	Description string                     `yaml:"description"`
-}
+type ComputedMetric = computed.Metric
 
 // MetricReference identifies a metric to pull into a computed expression.
-type MetricReference struct {
-	Name    string            `yaml:"name"`
-	Labels  map[string]string `yaml:"labels"`
-	Default *float64          `yaml:"default"`
-}
+type MetricReference = computed.Reference
 
 // CheckNotification describes check-specific notification config.
 type CheckNotification struct {
 	Route     string                `yaml:"route"`
 	Overrides *NotificationOverride `yaml:"overrides"`
+	// RenotifyInterval limits repeated escalation notifications while the
+	// check keeps failing with the same reason.
+	RenotifyInterval Duration `yaml:"renotify_interval,omitempty"`
 }
 
 // NotificationOverride overrides the policy for a check.
 type NotificationOverride struct {
 	InitialNotifiers []string `yaml:"initial_notifiers"`
+	// ResolveNotifiers replaces the resolve_notifiers of the check's policy.
+	ResolveNotifiers []string `yaml:"resolve_notifiers"`
 }
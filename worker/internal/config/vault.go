@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultClient reads secrets from the Vault KV API at VAULT_ADDR with
+// VAULT_TOKEN. It is created lazily on the first vault: secret and keeps the
+// HTTP client and every path it read, so fields of one path cost one request.
+// Once Vault is unavailable every later read fails the same way without a
+// request, so a down Vault delays a resolution by one timeout at most.
+type vaultClient struct {
+	addr        string
+	token       string
+	client      *http.Client
+	paths       map[string]map[string]any
+	unavailable *secretUnavailableError
+}
+
+// lookup resolves a "path#field" reference such as secret/data/smtp#password.
+// An unreachable Vault is unavailable so fallbacks apply; a missing path or
+// field fails at once.
+func (v *vaultClient) lookup(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	field = strings.TrimSpace(field)
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid vault reference %q, want path#field", ref)
+	}
+	data, err := v.read(path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault path %q has no field %q", path, field)
+	}
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case nil:
+		return "", fmt.Errorf("vault field %q of %q is null", field, path)
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("encode vault field %q of %q: %w", field, path, err)
+		}
+		return string(encoded), nil
+	}
+}
+
+func (v *vaultClient) read(path string) (map[string]any, error) {
+	if data, ok := v.paths[path]; ok {
+		return data, nil
+	}
+	if v.unavailable != nil {
+		return nil, v.unavailable
+	}
+	data, err := v.fetch(path)
+	var unavailable *secretUnavailableError
+	if errors.As(err, &unavailable) {
+		v.unavailable = unavailable
+	}
+	return data, err
+}
+
+func (v *vaultClient) fetch(path string) (map[string]any, error) {
+	if v.client == nil {
+		v.addr = strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+		v.token = os.Getenv("VAULT_TOKEN")
+		v.client = &http.Client{Timeout: 10 * time.Second}
+		v.paths = map[string]map[string]any{}
+	}
+	if v.addr == "" {
+		return nil, &secretUnavailableError{reason: "VAULT_ADDR is not set"}
+	}
+	if v.token == "" {
+		return nil, &secretUnavailableError{reason: "VAULT_TOKEN is not set"}
+	}
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, &secretUnavailableError{reason: fmt.Sprintf("vault request: %v", err)}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("vault path %q not found", path)
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("vault denied access to %q", path)
+	case resp.StatusCode >= 500:
+		return nil, &secretUnavailableError{reason: fmt.Sprintf("vault returned %s", resp.Status)}
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("vault returned %s for %q", resp.Status, path)
+	}
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode vault response for %q: %w", path, err)
+	}
+	data := body.Data
+	// KV version 2 nests the secret under data.data next to its metadata.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = inner
+		}
+	}
+	v.paths[path] = data
+	return data, nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// checksDirDebounce groups the burst of events an editor or a ConfigMap
+// update produces into a single reload.
+const checksDirDebounce = 500 * time.Millisecond
+
+// WatchChecksDir calls onChange after files in dir are created, written,
+// removed or renamed. Events arriving in quick succession trigger one call.
+// It blocks until ctx is cancelled.
+func WatchChecksDir(ctx context.Context, dir string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch checks_dir: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("watch checks_dir: %w", err)
+	}
+
+	timer := time.NewTimer(checksDirDebounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// Symlink swaps (as used by Kubernetes ConfigMaps) only touch
+			// dotted entries, so every non-chmod event counts.
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			timer.Reset(checksDirDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				timer.Reset(checksDirDebounce)
+				continue
+			}
+			return fmt.Errorf("watch checks_dir: %w", err)
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
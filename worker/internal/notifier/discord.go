@@ -7,23 +7,30 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
 )
 
 // DiscordConfig configures Discord webhook.
 type DiscordConfig struct {
 	WebhookURLRef string `mapstructure:"webhook_url_ref"`
 	Username      string `mapstructure:"username"`
+	// FiringTemplate and ResolvedTemplate replace the built-in message of
+	// firing and resolved events.
+	FiringTemplate   string `mapstructure:"firing_template"`
+	ResolvedTemplate string `mapstructure:"resolved_template"`
 }
 
 type discordNotifier struct {
-	id     string
-	cfg    DiscordConfig
-	url    string
-	client *http.Client
+	id        string
+	cfg       DiscordConfig
+	url       string
+	client    *http.Client
+	templates statusTemplates
 }
 
 // NewDiscordNotifier constructs Discord notifier.
-func NewDiscordNotifier(id string, cfg DiscordConfig, secrets map[string]string) (Notifier, error) {
+func NewDiscordNotifier(id string, cfg DiscordConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	url, ok := secrets[cfg.WebhookURLRef]
 	if cfg.WebhookURLRef != "" && !ok {
 		return nil, fmt.Errorf("missing secret %q", cfg.WebhookURLRef)
@@ -35,6 +42,12 @@ func NewDiscordNotifier(id string, cfg DiscordConfig, secrets map[string]string)
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: statusTemplates{
+			firing:   cfg.FiringTemplate,
+			resolved: cfg.ResolvedTemplate,
+			secrets:  secrets,
+			renderer: renderer,
+		},
 	}, nil
 }
 
@@ -43,13 +56,26 @@ func (d *discordNotifier) ID() string {
 }
 
 func (d *discordNotifier) Notify(ctx context.Context, event Event) error {
-	payload := map[string]interface{}{
-		"content": fmt.Sprintf("**%s** %s\nStatus: %s | Severity: %s | Run: %s",
+	content, ok, err := d.templates.render(event)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		content = fmt.Sprintf("**%s** %s\nStatus: %s | Severity: %s | Run: %s",
 			event.Check.Name,
 			event.Summary,
 			event.Status,
 			event.Severity,
-			event.RunID),
+			event.RunID)
+	}
+	payload := map[string]interface{}{
+		"content": content,
+	}
+	if event.CheckURL != "" {
+		payload["embeds"] = []map[string]interface{}{{
+			"title": "Open check",
+			"url":   event.CheckURL,
+		}}
 	}
 	if d.cfg.Username != "" {
 		payload["username"] = d.cfg.Username
@@ -69,7 +95,7 @@ func (d *discordNotifier) Notify(ctx context.Context, event Event) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("discord webhook: %s", resp.Status)
+		return responseError("discord webhook", resp)
 	}
 	return nil
 }
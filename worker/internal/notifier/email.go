@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/jordan-wright/email"
+
+	"github.com/osbits/upupup/worker/internal/render"
 )
 
 // EmailConfig contains SMTP configuration.
@@ -18,16 +20,22 @@ type EmailConfig struct {
 	PasswordRef string   `mapstructure:"password_ref"`
 	From        string   `mapstructure:"from"`
 	To          []string `mapstructure:"to"`
+	// SubjectTemplate and BodyTemplate replace the built-in subject and
+	// plain-text body; they receive the same data as webhook templates.
+	SubjectTemplate string `mapstructure:"subject_template"`
+	BodyTemplate    string `mapstructure:"body_template"`
 }
 
 type emailNotifier struct {
 	id       string
 	cfg      EmailConfig
 	password string
+	secrets  map[string]string
+	renderer *render.Engine
 }
 
 // NewEmailNotifier creates an email notifier.
-func NewEmailNotifier(id string, cfg EmailConfig, secrets map[string]string) (Notifier, error) {
+func NewEmailNotifier(id string, cfg EmailConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	pass, ok := secrets[cfg.PasswordRef]
 	if cfg.PasswordRef != "" && !ok {
 		return nil, fmt.Errorf("missing secret %q", cfg.PasswordRef)
@@ -36,6 +44,8 @@ func NewEmailNotifier(id string, cfg EmailConfig, secrets map[string]string) (No
 		id:       id,
 		cfg:      cfg,
 		password: pass,
+		secrets:  secrets,
+		renderer: renderer,
 	}, nil
 }
 
@@ -44,16 +54,10 @@ func (e *emailNotifier) ID() string {
 }
 
 func (e *emailNotifier) Notify(ctx context.Context, event Event) error {
-	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(event.Status), event.Check.Name)
-	body := fmt.Sprintf("%s\n\nCheck: %s (%s)\nStatus: %s\nSeverity: %s\nSummary: %s\nRunID: %s\n",
-		subject,
-		event.Check.Name,
-		event.Check.Target,
-		event.Status,
-		event.Severity,
-		event.Summary,
-		event.RunID,
-	)
+	subject, body, err := e.compose(event)
+	if err != nil {
+		return err
+	}
 	em := email.NewEmail()
 	em.From = e.cfg.From
 	em.To = append([]string{}, e.cfg.To...)
@@ -70,3 +74,34 @@ func (e *emailNotifier) Notify(ctx context.Context, event Event) error {
 	}
 	return em.SendWithTLS(addr, auth, tlsConfig)
 }
+
+// compose returns the subject and body of the mail, rendering the configured
+// templates over the built-in ones.
+func (e *emailNotifier) compose(event Event) (subject, body string, err error) {
+	subject = fmt.Sprintf("[%s] %s", strings.ToUpper(event.Status), event.Check.Name)
+	body = fmt.Sprintf("%s\n\nCheck: %s (%s)\nStatus: %s\nSeverity: %s\nSummary: %s\nRunID: %s\n",
+		subject,
+		event.Check.Name,
+		event.Check.Target,
+		event.Status,
+		event.Severity,
+		event.Summary,
+		event.RunID,
+	)
+	if e.renderer == nil || (e.cfg.SubjectTemplate == "" && e.cfg.BodyTemplate == "") {
+		return subject, body, nil
+	}
+	ctx := render.TemplateContext{Secrets: e.secrets, Data: eventTemplateData(event)}
+	if e.cfg.SubjectTemplate != "" {
+		if subject, err = e.renderer.RenderString(e.cfg.SubjectTemplate, ctx); err != nil {
+			return "", "", fmt.Errorf("render subject_template: %w", err)
+		}
+		subject = strings.TrimSpace(subject)
+	}
+	if e.cfg.BodyTemplate != "" {
+		if body, err = e.renderer.RenderString(e.cfg.BodyTemplate, ctx); err != nil {
+			return "", "", fmt.Errorf("render body_template: %w", err)
+		}
+	}
+	return subject, body, nil
+}
@@ -0,0 +1,37 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestEmailComposeRendersTemplates(t *testing.T) {
+	n, err := NewEmailNotifier("mail", EmailConfig{
+		SubjectTemplate: "{{ .status }}: {{ .check.name }}\n",
+		BodyTemplate:    "{{ .summary }} ({{ .incident_id }})",
+	}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new email: %v", err)
+	}
+	event := Event{Check: config.CheckConfig{ID: "api", Name: "API"}, Status: "firing", Summary: "status 500", IncidentID: "api-1"}
+
+	subject, body, err := n.(*emailNotifier).compose(event)
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	if subject != "firing: API" || body != "status 500 (api-1)" {
+		t.Fatalf("unexpected mail %q / %q", subject, body)
+	}
+
+	plain, err := NewEmailNotifier("mail", EmailConfig{}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new email: %v", err)
+	}
+	subject, body, err = plain.(*emailNotifier).compose(event)
+	if err != nil || subject != "[FIRING] API" || !strings.Contains(body, "Summary: status 500") {
+		t.Fatalf("expected built-in mail, got %q / %q (%v)", subject, body, err)
+	}
+}
@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// Error classes recorded with failed deliveries.
+const (
+	ErrorClassAuth        = "auth"
+	ErrorClassRateLimited = "rate_limited"
+	ErrorClassTransient   = "transient"
+	ErrorClassPermanent   = "permanent"
+)
+
+// DeliveryError is returned by notifiers when the receiving service rejects a
+// delivery. Class is one of the ErrorClass constants.
+type DeliveryError struct {
+	Service    string
+	StatusCode int
+	Status     string
+	Class      string
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Service, e.Status)
+}
+
+// responseError wraps a non-2xx response of service in a DeliveryError.
+func responseError(service string, resp *http.Response) error {
+	return &DeliveryError{
+		Service:    service,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Class:      classifyStatus(resp.StatusCode),
+	}
+}
+
+func classifyStatus(code int) string {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrorClassAuth
+	case code == http.StatusTooManyRequests:
+		return ErrorClassRateLimited
+	case code == http.StatusRequestTimeout || code >= 500:
+		return ErrorClassTransient
+	default:
+		return ErrorClassPermanent
+	}
+}
+
+// Classify returns the error class of a failed delivery, or "" for a nil
+// error. Timeouts and network errors are transient; errors that are not
+// recognised are permanent so they surface until the configuration changes.
+func Classify(err error) string {
+	if err == nil {
+		return ""
+	}
+	var delivery *DeliveryError
+	if errors.As(err, &delivery) {
+		return delivery.Class
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		switch {
+		case smtpErr.Code == 530 || smtpErr.Code == 535:
+			return ErrorClassAuth
+		case smtpErr.Code >= 400 && smtpErr.Code < 500:
+			return ErrorClassTransient
+		default:
+			return ErrorClassPermanent
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassTransient
+	}
+	return ErrorClassPermanent
+}
+
+// ErrorMessage returns the text of a failed delivery for the notification
+// log. Webhook URLs of Slack, Telegram and the like carry their token, so the
+// URL of a failed request is cut down to its scheme and host.
+func ErrorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	message := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.URL != "" {
+		redacted := "<redacted>"
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil && u.Host != "" {
+			redacted = u.Scheme + "://" + u.Host
+		}
+		message = strings.ReplaceAll(message, urlErr.URL, redacted)
+	}
+	return message
+}
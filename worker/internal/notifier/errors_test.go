@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func notifyWebhook(t *testing.T, ctx context.Context, handler http.HandlerFunc) error {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	n, err := NewWebhookNotifier("hook", WebhookConfig{URL: srv.URL}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+	return n.Notify(ctx, Event{Check: config.CheckConfig{ID: "api"}, Status: "firing"})
+}
+
+func TestClassifyAuthFailureDistinctFromTimeout(t *testing.T) {
+	authErr := notifyWebhook(t, context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	if got := Classify(authErr); got != ErrorClassAuth {
+		t.Fatalf("expected 401 to classify as %q, got %q (%v)", ErrorClassAuth, got, authErr)
+	}
+	if authErr.Error() != "webhook response: 401 Unauthorized" {
+		t.Fatalf("unexpected error message %q", authErr)
+	}
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	timeoutErr := notifyWebhook(t, ctx, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	if got := Classify(timeoutErr); got != ErrorClassTransient {
+		t.Fatalf("expected timeout to classify as %q, got %q (%v)", ErrorClassTransient, got, timeoutErr)
+	}
+}
+
+func TestClassifyStatusCodes(t *testing.T) {
+	cases := map[int]string{
+		http.StatusForbidden:           ErrorClassAuth,
+		http.StatusTooManyRequests:     ErrorClassRateLimited,
+		http.StatusServiceUnavailable:  ErrorClassTransient,
+		http.StatusBadRequest:          ErrorClassPermanent,
+		http.StatusNotFound:            ErrorClassPermanent,
+		http.StatusInternalServerError: ErrorClassTransient,
+	}
+	for code, want := range cases {
+		err := notifyWebhook(t, context.Background(), func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		})
+		if got := Classify(fmt.Errorf("wrapped: %w", err)); got != want {
+			t.Fatalf("status %d: expected %q, got %q", code, want, got)
+		}
+	}
+}
+
+func TestClassifyOtherErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{&textproto.Error{Code: 535, Msg: "authentication failed"}, ErrorClassAuth},
+		{&textproto.Error{Code: 421, Msg: "try again later"}, ErrorClassTransient},
+		{&textproto.Error{Code: 550, Msg: "mailbox unavailable"}, ErrorClassPermanent},
+		{context.DeadlineExceeded, ErrorClassTransient},
+		{errors.New("missing secret"), ErrorClassPermanent},
+	}
+	for _, tc := range cases {
+		if got := Classify(tc.err); got != tc.want {
+			t.Fatalf("%v: expected %q, got %q", tc.err, tc.want, got)
+		}
+	}
+}
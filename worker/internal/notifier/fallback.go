@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// FallbackConfig lists the notifiers a fallback notifier tries, in order.
+type FallbackConfig struct {
+	Notifiers []string `mapstructure:"notifiers"`
+}
+
+// Deliverer is implemented by notifiers that hand events on to other
+// notifiers. Deliver reports the id of the notifier that delivered.
+type Deliverer interface {
+	Deliver(ctx context.Context, event Event) (string, error)
+}
+
+type fallbackNotifier struct {
+	id      string
+	members []Notifier
+}
+
+// NewFallbackNotifier builds a notifier that tries members in order until
+// one delivers the event.
+func NewFallbackNotifier(id string, members []Notifier) (Notifier, error) {
+	if len(members) == 0 {
+		return nil, errors.New("fallback needs at least one notifier")
+	}
+	return &fallbackNotifier{id: id, members: members}, nil
+}
+
+func (f *fallbackNotifier) ID() string {
+	return f.id
+}
+
+func (f *fallbackNotifier) Notify(ctx context.Context, event Event) error {
+	_, err := f.Deliver(ctx, event)
+	return err
+}
+
+// Deliver tries each member until one succeeds. When all of them fail the
+// error wraps every member's error, so Classify sees the first one.
+func (f *fallbackNotifier) Deliver(ctx context.Context, event Event) (string, error) {
+	var errs []error
+	for _, member := range f.members {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		err := member.Notify(ctx, event)
+		if err == nil {
+			return member.ID(), nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", member.ID(), err))
+	}
+	return "", fmt.Errorf("all fallback notifiers failed: %w", errors.Join(errs...))
+}
+
+type fallbackSpec struct {
+	id  string
+	cfg FallbackConfig
+}
+
+// buildFallbacks adds the fallback notifiers once every other notifier is
+// registered. Fallbacks cannot be nested. Members disabled in this
+// environment are skipped; a fallback left without members is disabled too.
+func buildFallbacks(reg *Registry, specs []fallbackSpec, fallbackIDs map[string]bool) error {
+	for _, spec := range specs {
+		var members []Notifier
+		for _, memberID := range spec.cfg.Notifiers {
+			if fallbackIDs[memberID] {
+				return fmt.Errorf("notifier %q: fallback member %q is itself a fallback", spec.id, memberID)
+			}
+			member, ok := reg.Get(memberID)
+			if !ok {
+				if reg.Disabled(memberID) {
+					continue
+				}
+				return fmt.Errorf("notifier %q: unknown fallback member %q", spec.id, memberID)
+			}
+			members = append(members, member)
+		}
+		if len(members) == 0 && len(spec.cfg.Notifiers) > 0 {
+			reg.disabled[spec.id] = true
+			continue
+		}
+		n, err := NewFallbackNotifier(spec.id, members)
+		if err != nil {
+			return fmt.Errorf("notifier %q: %w", spec.id, err)
+		}
+		if err := reg.Add(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func countingServer(t *testing.T, status int) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &calls
+}
+
+func TestFallbackFallsThroughToSecondary(t *testing.T) {
+	primaryURL, primaryCalls := countingServer(t, http.StatusServiceUnavailable)
+	secondaryURL, secondaryCalls := countingServer(t, http.StatusOK)
+	reg, err := Build(Factory{Render: render.New()}, []config.NotifierConfig{
+		{ID: "chat-or-mail", Type: "fallback", Config: map[string]interface{}{"notifiers": []interface{}{"chat", "mail"}}},
+		{ID: "chat", Type: "webhook", Config: map[string]interface{}{"url": primaryURL}},
+		{ID: "mail", Type: "webhook", Config: map[string]interface{}{"url": secondaryURL}},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	n, ok := reg.Get("chat-or-mail")
+	if !ok {
+		t.Fatalf("expected fallback notifier to be built")
+	}
+
+	deliveredBy, err := n.(Deliverer).Deliver(context.Background(), Event{Check: config.CheckConfig{ID: "api"}, Status: "firing"})
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if deliveredBy != "mail" {
+		t.Fatalf("expected mail to deliver, got %q", deliveredBy)
+	}
+	if primaryCalls.Load() != 1 || secondaryCalls.Load() != 1 {
+		t.Fatalf("expected one call each, got primary=%d secondary=%d", primaryCalls.Load(), secondaryCalls.Load())
+	}
+}
+
+func TestFallbackReportsEveryFailure(t *testing.T) {
+	firstURL, _ := countingServer(t, http.StatusUnauthorized)
+	secondURL, _ := countingServer(t, http.StatusBadGateway)
+	first, _ := NewWebhookNotifier("chat", WebhookConfig{URL: firstURL}, nil, render.New())
+	second, _ := NewWebhookNotifier("mail", WebhookConfig{URL: secondURL}, nil, render.New())
+	n, err := NewFallbackNotifier("chat-or-mail", []Notifier{first, second})
+	if err != nil {
+		t.Fatalf("new fallback: %v", err)
+	}
+
+	err = n.Notify(context.Background(), Event{Check: config.CheckConfig{ID: "api"}, Status: "firing"})
+	if err == nil || !strings.Contains(err.Error(), "chat:") || !strings.Contains(err.Error(), "mail:") {
+		t.Fatalf("expected both failures in error, got %v", err)
+	}
+	if class := Classify(err); class != ErrorClassAuth {
+		t.Fatalf("expected class of the first failure, got %q", class)
+	}
+}
+
+func TestFallbackBuildRejectsUnknownAndNestedMembers(t *testing.T) {
+	cases := map[string][]config.NotifierConfig{
+		"unknown": {
+			{ID: "fb", Type: "fallback", Config: map[string]interface{}{"notifiers": []interface{}{"missing"}}},
+		},
+		"nested": {
+			{ID: "inner", Type: "fallback", Config: map[string]interface{}{"notifiers": []interface{}{"hook"}}},
+			{ID: "outer", Type: "fallback", Config: map[string]interface{}{"notifiers": []interface{}{"inner"}}},
+			{ID: "hook", Type: "webhook", Config: map[string]interface{}{"url": "https://hooks.example.com"}},
+		},
+	}
+	for name, configs := range cases {
+		if _, err := Build(Factory{Render: render.New()}, configs); err == nil {
+			t.Fatalf("%s: expected build to fail", name)
+		}
+	}
+}
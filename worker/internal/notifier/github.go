@@ -0,0 +1,216 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubIssueConfig files a tracking issue per failing check. Issues carry a
+// dedup label derived from the check id so a check has at most one open
+// issue; APIURL points at GitHub Enterprise when set.
+type GitHubIssueConfig struct {
+	APIURL     string   `mapstructure:"api_url"`
+	Repository string   `mapstructure:"repository"`
+	TokenRef   string   `mapstructure:"token_ref"`
+	Labels     []string `mapstructure:"labels"`
+	// KeepOpen only comments on resolve instead of closing the issue.
+	KeepOpen bool `mapstructure:"keep_open"`
+}
+
+type githubIssueNotifier struct {
+	id     string
+	cfg    GitHubIssueConfig
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+type githubIssue struct {
+	Number      int             `json:"number"`
+	PullRequest json.RawMessage `json:"pull_request,omitempty"`
+}
+
+// NewGitHubIssueNotifier builds a notifier that opens an issue on firing and
+// comments on and closes it on resolve.
+func NewGitHubIssueNotifier(id string, cfg GitHubIssueConfig, secrets map[string]string) (Notifier, error) {
+	owner, repo, ok := strings.Cut(strings.TrimSpace(cfg.Repository), "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return nil, fmt.Errorf("repository must be owner/name, got %q", cfg.Repository)
+	}
+	token, ok := secrets[cfg.TokenRef]
+	if cfg.TokenRef == "" || !ok {
+		return nil, fmt.Errorf("missing secret %q", cfg.TokenRef)
+	}
+	apiURL := strings.TrimRight(strings.TrimSpace(cfg.APIURL), "/")
+	if apiURL == "" {
+		apiURL = defaultGitHubAPIURL
+	}
+	return &githubIssueNotifier{
+		id:     id,
+		cfg:    cfg,
+		apiURL: apiURL + "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo),
+		token:  token,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+func (g *githubIssueNotifier) ID() string {
+	return g.id
+}
+
+func (g *githubIssueNotifier) Notify(ctx context.Context, event Event) error {
+	label := githubDedupLabel(event.Check.ID)
+	issue, err := g.findOpenIssue(ctx, label)
+	if err != nil {
+		return err
+	}
+	if event.Status == "resolved" {
+		if issue == nil {
+			return nil
+		}
+		comment := map[string]string{"body": githubResolvedComment(event)}
+		if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/issues/%d/comments", issue.Number), comment, nil); err != nil {
+			return fmt.Errorf("comment on issue #%d: %w", issue.Number, err)
+		}
+		if g.cfg.KeepOpen {
+			return nil
+		}
+		if err := g.do(ctx, http.MethodPatch, fmt.Sprintf("/issues/%d", issue.Number), map[string]string{"state": "closed", "state_reason": "completed"}, nil); err != nil {
+			return fmt.Errorf("close issue #%d: %w", issue.Number, err)
+		}
+		return nil
+	}
+	if issue != nil {
+		// The check already has an open issue; repeat notifications don't
+		// file another one.
+		return nil
+	}
+	labels := append([]string{label}, g.cfg.Labels...)
+	create := map[string]any{
+		"title":  githubIssueTitle(event),
+		"body":   githubIssueBody(event),
+		"labels": labels,
+	}
+	if err := g.do(ctx, http.MethodPost, "/issues", create, nil); err != nil {
+		return fmt.Errorf("create issue: %w", err)
+	}
+	return nil
+}
+
+// findOpenIssue returns the open issue carrying the dedup label, or nil.
+func (g *githubIssueNotifier) findOpenIssue(ctx context.Context, label string) (*githubIssue, error) {
+	query := url.Values{"state": {"open"}, "labels": {label}, "per_page": {"10"}}
+	var issues []githubIssue
+	if err := g.do(ctx, http.MethodGet, "/issues?"+query.Encode(), nil, &issues); err != nil {
+		return nil, fmt.Errorf("search issues: %w", err)
+	}
+	for _, issue := range issues {
+		if len(issue.PullRequest) == 0 {
+			return &issue, nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *githubIssueNotifier) do(ctx context.Context, method, path string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, g.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError("github api", resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// githubDedupLabel is the label that ties issues to a check.
+func githubDedupLabel(checkID string) string {
+	return "upupup:" + checkID
+}
+
+func githubIssueTitle(event Event) string {
+	name := event.Check.Name
+	if name == "" {
+		name = event.Check.ID
+	}
+	return fmt.Sprintf("%s is failing", name)
+}
+
+func githubIssueBody(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", event.Summary)
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Check | `%s` |\n", event.Check.ID)
+	if event.Check.Target != "" {
+		fmt.Fprintf(&b, "| Target | `%s` |\n", event.Check.Target)
+	}
+	fmt.Fprintf(&b, "| Severity | %s |\n", event.Severity)
+	if event.Reason != "" {
+		fmt.Fprintf(&b, "| Reason | `%s` |\n", event.Reason)
+	}
+	if !event.FirstFailureAt.IsZero() {
+		fmt.Fprintf(&b, "| First failure | %s |\n", event.FirstFailureAt.UTC().Format(time.RFC3339))
+	}
+	if event.IncidentID != "" {
+		fmt.Fprintf(&b, "| Incident | `%s` |\n", event.IncidentID)
+	}
+	fmt.Fprintf(&b, "| Run | `%s` |\n", event.RunID)
+	if len(event.Labels) > 0 {
+		keys := make([]string, 0, len(event.Labels))
+		for k := range event.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("`%s=%s`", k, event.Labels[k]))
+		}
+		fmt.Fprintf(&b, "| Labels | %s |\n", strings.Join(pairs, " "))
+	}
+	if event.CheckURL != "" {
+		fmt.Fprintf(&b, "\n[Open check](%s)\n", event.CheckURL)
+	}
+	return b.String()
+}
+
+func githubResolvedComment(event Event) string {
+	at := event.OccurredAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return fmt.Sprintf("Resolved at %s: %s (run `%s`)", at.UTC().Format(time.RFC3339), event.Summary, event.RunID)
+}
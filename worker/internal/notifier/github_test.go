@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// stubIssuesAPI keeps issues in memory and serves the subset of the GitHub
+// issues API used by the notifier.
+type stubIssuesAPI struct {
+	mu       sync.Mutex
+	issues   []map[string]any
+	comments map[int][]string
+	auth     string
+}
+
+func (s *stubIssuesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = r.Header.Get("Authorization")
+	path := strings.TrimPrefix(r.URL.Path, "/repos/acme/ops")
+	var payload map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	switch {
+	case r.Method == http.MethodGet && path == "/issues":
+		open := []map[string]any{}
+		for _, issue := range s.issues {
+			if issue["state"] == "open" && hasLabel(issue, r.URL.Query().Get("labels")) {
+				open = append(open, issue)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(open)
+	case r.Method == http.MethodPost && path == "/issues":
+		payload["number"] = len(s.issues) + 1
+		payload["state"] = "open"
+		s.issues = append(s.issues, payload)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(payload)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/comments"):
+		var number int
+		_, _ = fmt.Sscanf(path, "/issues/%d/comments", &number)
+		s.comments[number] = append(s.comments[number], payload["body"].(string))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{}"))
+	case r.Method == http.MethodPatch:
+		var number int
+		_, _ = fmt.Sscanf(path, "/issues/%d", &number)
+		s.issues[number-1]["state"] = payload["state"]
+		_, _ = w.Write([]byte("{}"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func hasLabel(issue map[string]any, label string) bool {
+	labels, _ := issue["labels"].([]any)
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func newGitHubTestNotifier(t *testing.T, api *stubIssuesAPI, keepOpen bool) Notifier {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	n, err := NewGitHubIssueNotifier("issues", GitHubIssueConfig{
+		APIURL:     srv.URL,
+		Repository: "acme/ops",
+		TokenRef:   "GITHUB_TOKEN",
+		Labels:     []string{"monitoring"},
+		KeepOpen:   keepOpen,
+	}, map[string]string{"GITHUB_TOKEN": "ghp_test"})
+	if err != nil {
+		t.Fatalf("new github notifier: %v", err)
+	}
+	return n
+}
+
+func TestGitHubIssueCreatesOnceAndClosesOnResolve(t *testing.T) {
+	api := &stubIssuesAPI{comments: map[int][]string{}}
+	n := newGitHubTestNotifier(t, api, false)
+	check := config.CheckConfig{ID: "nightly-backup", Name: "Nightly backup", Target: "s3://backups/db.tar.gz"}
+
+	firing := Event{Check: check, Status: "firing", Severity: "warning", Summary: "object not found", Reason: "object_missing", RunID: "run-1"}
+	for range 2 {
+		if err := n.Notify(context.Background(), firing); err != nil {
+			t.Fatalf("notify firing: %v", err)
+		}
+	}
+	if len(api.issues) != 1 {
+		t.Fatalf("expected one deduplicated issue, got %d", len(api.issues))
+	}
+	issue := api.issues[0]
+	if issue["title"] != "Nightly backup is failing" || !strings.Contains(issue["body"].(string), "`object_missing`") {
+		t.Fatalf("unexpected issue %v", issue)
+	}
+	if labels := issue["labels"].([]any); len(labels) != 2 || labels[0] != "upupup:nightly-backup" || labels[1] != "monitoring" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if api.auth != "Bearer ghp_test" {
+		t.Fatalf("expected token from secrets, got %q", api.auth)
+	}
+
+	resolved := Event{Check: check, Status: "resolved", Summary: "Check succeeded", RunID: "run-2"}
+	if err := n.Notify(context.Background(), resolved); err != nil {
+		t.Fatalf("notify resolved: %v", err)
+	}
+	if api.issues[0]["state"] != "closed" {
+		t.Fatalf("expected issue closed, got %v", api.issues[0]["state"])
+	}
+	if comments := api.comments[1]; len(comments) != 1 || !strings.Contains(comments[0], "Resolved at") {
+		t.Fatalf("expected resolve comment, got %v", comments)
+	}
+
+	if err := n.Notify(context.Background(), firing); err != nil {
+		t.Fatalf("notify firing: %v", err)
+	}
+	if len(api.issues) != 2 {
+		t.Fatalf("expected a new issue after the previous one closed, got %d", len(api.issues))
+	}
+}
+
+func TestGitHubIssueKeepOpenOnlyComments(t *testing.T) {
+	api := &stubIssuesAPI{comments: map[int][]string{}}
+	n := newGitHubTestNotifier(t, api, true)
+	check := config.CheckConfig{ID: "api", Name: "API"}
+
+	if err := n.Notify(context.Background(), Event{Check: check, Status: "resolved"}); err != nil {
+		t.Fatalf("resolve without issue: %v", err)
+	}
+	if len(api.issues) != 0 || len(api.comments) != 0 {
+		t.Fatalf("expected nothing filed for a resolve without issue")
+	}
+	if err := n.Notify(context.Background(), Event{Check: check, Status: "firing"}); err != nil {
+		t.Fatalf("notify firing: %v", err)
+	}
+	if err := n.Notify(context.Background(), Event{Check: check, Status: "resolved"}); err != nil {
+		t.Fatalf("notify resolved: %v", err)
+	}
+	if api.issues[0]["state"] != "open" || len(api.comments[1]) != 1 {
+		t.Fatalf("expected open issue with one comment, got state=%v comments=%v", api.issues[0]["state"], api.comments[1])
+	}
+}
+
+func TestBuildGitHubIssueRequiresRepository(t *testing.T) {
+	_, err := Build(Factory{Secrets: map[string]string{"GITHUB_TOKEN": "t"}}, []config.NotifierConfig{{
+		ID:     "issues",
+		Type:   "github_issue",
+		Config: map[string]interface{}{"repository": "ops", "token_ref": "GITHUB_TOKEN"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "owner/name") {
+		t.Fatalf("expected repository error, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// defaultTeamsColors are the MessageCard theme colors of firing and resolved
+// events unless theme_color overrides them.
+var defaultTeamsColors = map[string]string{
+	"firing":   "D13438",
+	"resolved": "2EB886",
+}
+
+// MSTeamsConfig configures a Microsoft Teams incoming webhook.
+type MSTeamsConfig struct {
+	WebhookURLRef string `mapstructure:"webhook_url_ref"`
+	// Card is "message" (default) for a legacy connector MessageCard or
+	// "adaptive" for an Adaptive Card, which Teams workflow webhooks need.
+	Card string `mapstructure:"card"`
+	// ThemeColor maps an event status (firing, resolved) to the hex color of
+	// the MessageCard bar.
+	ThemeColor map[string]string `mapstructure:"theme_color"`
+	// FiringTemplate and ResolvedTemplate replace the built-in message of
+	// firing and resolved events.
+	FiringTemplate   string `mapstructure:"firing_template"`
+	ResolvedTemplate string `mapstructure:"resolved_template"`
+}
+
+type msTeamsNotifier struct {
+	id        string
+	cfg       MSTeamsConfig
+	url       string
+	client    *http.Client
+	templates statusTemplates
+}
+
+// NewMSTeamsNotifier builds a Microsoft Teams notifier.
+func NewMSTeamsNotifier(id string, cfg MSTeamsConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
+	url, ok := secrets[cfg.WebhookURLRef]
+	if cfg.WebhookURLRef != "" && !ok {
+		return nil, fmt.Errorf("missing secret %q", cfg.WebhookURLRef)
+	}
+	switch strings.ToLower(cfg.Card) {
+	case "", "message", "adaptive":
+	default:
+		return nil, fmt.Errorf("unsupported card %q", cfg.Card)
+	}
+	return &msTeamsNotifier{
+		id:  id,
+		cfg: cfg,
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		templates: statusTemplates{
+			firing:   cfg.FiringTemplate,
+			resolved: cfg.ResolvedTemplate,
+			secrets:  secrets,
+			renderer: renderer,
+		},
+	}, nil
+}
+
+func (m *msTeamsNotifier) ID() string {
+	return m.id
+}
+
+func (m *msTeamsNotifier) Notify(ctx context.Context, event Event) error {
+	text, ok, err := m.templates.render(event)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		text = event.Summary
+	}
+	var payload map[string]interface{}
+	if strings.EqualFold(m.cfg.Card, "adaptive") {
+		payload = m.adaptiveCard(event, text)
+	} else {
+		payload = m.messageCard(event, text)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError("teams webhook", resp)
+	}
+	return nil
+}
+
+type teamsFact struct {
+	Name  string `json:"name,omitempty"`
+	Title string `json:"title,omitempty"`
+	Value string `json:"value"`
+}
+
+// facts lists status, severity, target and run id, plus the reason of a
+// failed run, in the key of the card format.
+func (m *msTeamsNotifier) facts(event Event, adaptive bool) []teamsFact {
+	pairs := [][2]string{
+		{"Status", event.Status},
+		{"Severity", event.Severity},
+		{"Target", event.Check.Target},
+		{"Run ID", event.RunID},
+	}
+	if event.Reason != "" {
+		pairs = append(pairs, [2]string{"Reason", event.Reason})
+	}
+	facts := make([]teamsFact, 0, len(pairs))
+	for _, pair := range pairs {
+		if adaptive {
+			facts = append(facts, teamsFact{Title: pair[0], Value: pair[1]})
+		} else {
+			facts = append(facts, teamsFact{Name: pair[0], Value: pair[1]})
+		}
+	}
+	return facts
+}
+
+func (m *msTeamsNotifier) title(event Event) string {
+	name := event.Check.Name
+	if name == "" {
+		name = event.Check.ID
+	}
+	return fmt.Sprintf("[%s] %s", strings.ToUpper(event.Status), name)
+}
+
+func (m *msTeamsNotifier) themeColor(status string) string {
+	if color, ok := m.cfg.ThemeColor[status]; ok && color != "" {
+		return strings.TrimPrefix(color, "#")
+	}
+	if color, ok := defaultTeamsColors[status]; ok {
+		return color
+	}
+	return defaultTeamsColors["firing"]
+}
+
+func (m *msTeamsNotifier) messageCard(event Event, text string) map[string]interface{} {
+	section := map[string]interface{}{
+		"activityTitle": m.title(event),
+		"facts":         m.facts(event, false),
+	}
+	if text != "" {
+		section["text"] = text
+	}
+	card := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    m.title(event),
+		"themeColor": m.themeColor(event.Status),
+		"sections":   []map[string]interface{}{section},
+	}
+	if event.CheckURL != "" {
+		card["potentialAction"] = []map[string]interface{}{{
+			"@type":   "OpenUri",
+			"name":    "Open check",
+			"targets": []map[string]string{{"os": "default", "uri": event.CheckURL}},
+		}}
+	}
+	return card
+}
+
+// adaptiveCard wraps an Adaptive Card in the message envelope Teams webhooks
+// expect. Adaptive Cards have no free color bar, so the header container
+// uses the attention or good style instead.
+func (m *msTeamsNotifier) adaptiveCard(event Event, text string) map[string]interface{} {
+	style := "attention"
+	if event.Status == "resolved" {
+		style = "good"
+	}
+	body := []map[string]interface{}{{
+		"type":  "Container",
+		"style": style,
+		"bleed": true,
+		"items": []map[string]interface{}{{
+			"type":   "TextBlock",
+			"text":   m.title(event),
+			"weight": "Bolder",
+			"size":   "Medium",
+			"wrap":   true,
+		}},
+	}}
+	if text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true})
+	}
+	body = append(body, map[string]interface{}{"type": "FactSet", "facts": m.facts(event, true)})
+	content := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if event.CheckURL != "" {
+		content["actions"] = []map[string]interface{}{{
+			"type":  "Action.OpenUrl",
+			"title": "Open check",
+			"url":   event.CheckURL,
+		}}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     content,
+		}},
+	}
+}
@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func newTeamsServer(t *testing.T, status int) (string, chan map[string]any) {
+	t.Helper()
+	payloads := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, payloads
+}
+
+func teamsEvent(status string) Event {
+	return Event{
+		Check:    config.CheckConfig{ID: "api", Name: "API", Target: "https://api.example.com"},
+		Status:   status,
+		Severity: "critical",
+		Summary:  "status 500",
+		RunID:    "api-1",
+		CheckURL: "https://status.example.com/checks/api",
+	}
+}
+
+func TestMSTeamsMessageCardColorsByStatus(t *testing.T) {
+	url, payloads := newTeamsServer(t, http.StatusOK)
+	cfg := MSTeamsConfig{WebhookURLRef: "TEAMS", ThemeColor: map[string]string{"resolved": "#00FF00"}}
+	n, err := NewMSTeamsNotifier("teams", cfg, map[string]string{"TEAMS": url}, render.New())
+	if err != nil {
+		t.Fatalf("new teams: %v", err)
+	}
+
+	if err := n.Notify(context.Background(), teamsEvent("firing")); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	card := <-payloads
+	if card["@type"] != "MessageCard" || card["themeColor"] != "D13438" {
+		t.Fatalf("expected red message card, got %v", card)
+	}
+	section := card["sections"].([]any)[0].(map[string]any)
+	if section["activityTitle"] != "[FIRING] API" || section["text"] != "status 500" {
+		t.Fatalf("unexpected section %v", section)
+	}
+	facts := map[string]any{}
+	for _, fact := range section["facts"].([]any) {
+		fact := fact.(map[string]any)
+		facts[fact["name"].(string)] = fact["value"]
+	}
+	if facts["Status"] != "firing" || facts["Severity"] != "critical" || facts["Target"] != "https://api.example.com" || facts["Run ID"] != "api-1" {
+		t.Fatalf("unexpected facts %v", facts)
+	}
+	if card["potentialAction"] == nil {
+		t.Fatalf("expected an open check action")
+	}
+
+	if err := n.Notify(context.Background(), teamsEvent("resolved")); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if card := <-payloads; card["themeColor"] != "00FF00" {
+		t.Fatalf("expected theme_color override for resolved, got %v", card["themeColor"])
+	}
+}
+
+func TestMSTeamsAdaptiveCard(t *testing.T) {
+	url, payloads := newTeamsServer(t, http.StatusAccepted)
+	n, err := NewMSTeamsNotifier("teams", MSTeamsConfig{WebhookURLRef: "TEAMS", Card: "adaptive"}, map[string]string{"TEAMS": url}, render.New())
+	if err != nil {
+		t.Fatalf("new teams: %v", err)
+	}
+	if err := n.Notify(context.Background(), teamsEvent("resolved")); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	payload := <-payloads
+	attachment := payload["attachments"].([]any)[0].(map[string]any)
+	if payload["type"] != "message" || attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("unexpected envelope %v", payload)
+	}
+	content := attachment["content"].(map[string]any)
+	header := content["body"].([]any)[0].(map[string]any)
+	if content["type"] != "AdaptiveCard" || header["style"] != "good" {
+		t.Fatalf("expected good style for resolved, got %v", content)
+	}
+}
+
+func TestMSTeamsRejectsNon2xx(t *testing.T) {
+	url, payloads := newTeamsServer(t, http.StatusBadRequest)
+	n, err := NewMSTeamsNotifier("teams", MSTeamsConfig{WebhookURLRef: "TEAMS"}, map[string]string{"TEAMS": url}, render.New())
+	if err != nil {
+		t.Fatalf("new teams: %v", err)
+	}
+	err = n.Notify(context.Background(), teamsEvent("firing"))
+	<-payloads
+	if err == nil || !strings.Contains(err.Error(), "teams webhook") {
+		t.Fatalf("expected teams webhook error, got %v", err)
+	}
+
+	if _, err := NewMSTeamsNotifier("teams", MSTeamsConfig{Card: "hero"}, nil, render.New()); err == nil {
+		t.Fatalf("expected unsupported card to fail")
+	}
+}
@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// Recipient is a phone number of an SMS or voice notifier. In the config it
+// is either a plain number or an object with number and locale.
+type Recipient struct {
+	Number string `mapstructure:"number"`
+	// Locale picks the message template, e.g. "de" or "fr-CH".
+	Locale string `mapstructure:"locale"`
+}
+
+// recipientHook decodes a plain string into a Recipient without a locale.
+func recipientHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(Recipient{}) || from.Kind() != reflect.String {
+		return data, nil
+	}
+	return Recipient{Number: data.(string)}, nil
+}
+
+// localeTemplates are the per-locale message templates of SMS and voice
+// notifiers.
+type localeTemplates struct {
+	templates     map[string]string
+	defaultLocale string
+	secrets       map[string]string
+	renderer      *render.Engine
+}
+
+func newLocaleTemplates(templates map[string]string, defaultLocale string, secrets map[string]string, renderer *render.Engine) localeTemplates {
+	normalized := make(map[string]string, len(templates))
+	for locale, tmpl := range templates {
+		normalized[normalizeLocale(locale)] = tmpl
+	}
+	return localeTemplates{
+		templates:     normalized,
+		defaultLocale: normalizeLocale(defaultLocale),
+		secrets:       secrets,
+		renderer:      renderer,
+	}
+}
+
+// lookup picks the template of a locale: the exact locale, then its
+// language ("de" for "de-CH"), then the default locale. It returns the
+// locale whose template it found.
+func (t localeTemplates) lookup(locale string) (string, string, bool) {
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, language)
+	}
+	candidates = append(candidates, t.defaultLocale)
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if tmpl, ok := t.templates[candidate]; ok && tmpl != "" {
+			return tmpl, candidate, true
+		}
+	}
+	return "", "", false
+}
+
+// render returns the message for a recipient locale, or ok=false when no
+// template applies. The template sees the event data plus .locale.
+func (t localeTemplates) render(event Event, locale string) (message string, ok bool, err error) {
+	if len(t.templates) == 0 || t.renderer == nil {
+		return "", false, nil
+	}
+	tmpl, matched, ok := t.lookup(locale)
+	if !ok {
+		return "", false, nil
+	}
+	data := eventTemplateData(event)
+	data["locale"] = matched
+	message, err = t.renderer.RenderString(tmpl, render.TemplateContext{
+		Secrets: t.secrets,
+		Data:    data,
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("render %s template: %w", matched, err)
+	}
+	return message, true, nil
+}
+
+// forEachRecipient renders the message of every recipient, once per
+// locale, and sends it. fallback is the message when no template applies.
+func (t localeTemplates) forEachRecipient(event Event, recipients []Recipient, fallback string, send func(to Recipient, message string) error) error {
+	messages := map[string]string{}
+	for _, to := range recipients {
+		locale := normalizeLocale(to.Locale)
+		message, cached := messages[locale]
+		if !cached {
+			rendered, ok, err := t.render(event, locale)
+			if err != nil {
+				return err
+			}
+			message = fallback
+			if ok {
+				message = rendered
+			}
+			messages[locale] = message
+		}
+		if err := send(to, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeLocale lowercases a locale and uses "-" as separator, so "de_CH"
+// and "de-ch" select the same template.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestSMSRecipientsGetLocalizedMessages(t *testing.T) {
+	forms := make(chan url.Values, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		forms <- r.PostForm
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	reg, err := Build(Factory{Secrets: map[string]string{"TWILIO": "token"}, Render: render.New()}, []config.NotifierConfig{{
+		ID:   "sms",
+		Type: "sms",
+		Config: map[string]interface{}{
+			"account_sid":    "AC1",
+			"auth_token_ref": "TWILIO",
+			"from":           "+15550000",
+			"default_locale": "en",
+			"to": []interface{}{
+				map[string]interface{}{"number": "+4930111", "locale": "de-DE"},
+				map[string]interface{}{"number": "+33122", "locale": "fr"},
+				"+15550123",
+			},
+			"templates": map[string]interface{}{
+				"en": `{{ .check.name }} is down: {{ .summary }}`,
+				"de": `{{ .check.name }} ist ausgefallen: {{ .summary }} ({{ .locale }})`,
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	n, _ := reg.Get("sms")
+	n.(*twilioSMSNotifier).client.Transport = redirectTransport{target: target}
+
+	firing, _ := templateEvents()
+	if err := n.Notify(context.Background(), firing); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	want := map[string]string{
+		"+4930111":  "API ist ausgefallen: status 500 (de)",
+		"+33122":    "API is down: status 500",
+		"+15550123": "API is down: status 500",
+	}
+	for range want {
+		form := <-forms
+		if got := form.Get("Body"); got != want[form.Get("To")] {
+			t.Fatalf("unexpected message for %s: %q", form.Get("To"), got)
+		}
+	}
+}
+
+func TestVoiceCallSpeaksRecipientLocale(t *testing.T) {
+	payloads := make(chan map[string]any, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	n, err := NewVonageVoiceNotifier("voice", VonageVoiceConfig{
+		JWT: "jwt",
+		To:  []Recipient{{Number: "+4930111", Locale: "de-DE"}, {Number: "+15550123"}},
+		Templates: map[string]string{
+			"de-de": `Achtung, {{ .check.name }} ist ausgefallen.`,
+		},
+	}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new voice: %v", err)
+	}
+	n.(*vonageVoiceNotifier).client.Transport = redirectTransport{target: target}
+
+	firing, _ := templateEvents()
+	if err := n.Notify(context.Background(), firing); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	talk := func(payload map[string]any) map[string]any {
+		ncco, _ := payload["ncco"].([]any)
+		if len(ncco) != 1 {
+			t.Fatalf("unexpected ncco %v", payload["ncco"])
+		}
+		return ncco[0].(map[string]any)
+	}
+	german := talk(<-payloads)
+	if german["text"] != "Achtung, API ist ausgefallen." || german["language"] != "de-DE" {
+		t.Fatalf("unexpected german call %v", german)
+	}
+	fallback := talk(<-payloads)
+	if fallback["text"] != "API. Status firing. Severity . status 500." || fallback["language"] != nil {
+		t.Fatalf("expected the built-in message without language, got %v", fallback)
+	}
+}
@@ -10,13 +10,15 @@ import (
 
 // Registry stores notifiers by ID.
 type Registry struct {
-	items map[string]Notifier
+	items    map[string]Notifier
+	disabled map[string]bool
 }
 
 // NewRegistry creates a registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		items: map[string]Notifier{},
+		items:    map[string]Notifier{},
+		disabled: map[string]bool{},
 	}
 }
 
@@ -35,6 +37,12 @@ func (r *Registry) Get(id string) (Notifier, bool) {
 	return n, ok
 }
 
+// Disabled reports whether id names a configured notifier that was skipped
+// because it is disabled or gated to other environments.
+func (r *Registry) Disabled(id string) bool {
+	return r.disabled[id]
+}
+
 // Items returns map copy.
 func (r *Registry) Items() map[string]Notifier {
 	out := make(map[string]Notifier, len(r.items))
@@ -47,7 +55,28 @@ func (r *Registry) Items() map[string]Notifier {
 // Build constructs notifiers from config.
 func Build(factory Factory, configs []config.NotifierConfig) (*Registry, error) {
 	reg := NewRegistry()
+	fallbackIDs := map[string]bool{}
+	var fallbacks []fallbackSpec
+	for _, cfg := range configs {
+		if cfg.Type == "fallback" {
+			fallbackIDs[cfg.ID] = true
+		}
+	}
 	for _, cfg := range configs {
+		if !cfg.ActiveIn(factory.Environment) {
+			reg.disabled[cfg.ID] = true
+			continue
+		}
+		if cfg.Type == "fallback" {
+			// Members may be declared after the fallback, so it is built
+			// once the others are registered.
+			var fc FallbackConfig
+			if err := decode(cfg.Config, &fc); err != nil {
+				return nil, fmt.Errorf("notifier %q: %w", cfg.ID, err)
+			}
+			fallbacks = append(fallbacks, fallbackSpec{id: cfg.ID, cfg: fc})
+			continue
+		}
 		n, err := buildNotifier(factory, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("notifier %q: %w", cfg.ID, err)
@@ -56,17 +85,25 @@ func Build(factory Factory, configs []config.NotifierConfig) (*Registry, error)
 			return nil, err
 		}
 	}
+	if err := buildFallbacks(reg, fallbacks, fallbackIDs); err != nil {
+		return nil, err
+	}
 	return reg, nil
 }
 
 func buildNotifier(factory Factory, cfg config.NotifierConfig) (Notifier, error) {
+	resolved, err := resolveSecretRefs(cfg.Config, factory.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Config = resolved
 	switch cfg.Type {
 	case "email":
 		var nc EmailConfig
 		if err := decode(cfg.Config, &nc); err != nil {
 			return nil, err
 		}
-		return NewEmailNotifier(cfg.ID, nc, factory.Secrets)
+		return NewEmailNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
 	case "sms":
 		var nc TwilioSMSConfig
 		if err := decode(cfg.Config, &nc); err != nil {
@@ -74,13 +111,13 @@ func buildNotifier(factory Factory, cfg config.NotifierConfig) (Notifier, error)
 		}
 		switch strings.ToLower(nc.Provider) {
 		case "twilio", "":
-			return NewTwilioSMSNotifier(cfg.ID, nc, factory.Secrets)
+			return NewTwilioSMSNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
 		case "vonage":
 			var vc VonageSMSConfig
 			if err := decode(cfg.Config, &vc); err != nil {
 				return nil, err
 			}
-			return NewVonageSMSNotifier(cfg.ID, vc, factory.Secrets)
+			return NewVonageSMSNotifier(cfg.ID, vc, factory.Secrets, factory.Render)
 		default:
 			return nil, fmt.Errorf("unsupported sms provider %q", nc.Provider)
 		}
@@ -91,7 +128,7 @@ func buildNotifier(factory Factory, cfg config.NotifierConfig) (Notifier, error)
 		}
 		switch strings.ToLower(nc.Provider) {
 		case "twilio", "":
-			return NewTwilioVoiceNotifier(cfg.ID, nc, factory.Secrets)
+			return NewTwilioVoiceNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
 		case "vonage":
 			var vc VonageVoiceConfig
 			if err := decode(cfg.Config, &vc); err != nil {
@@ -112,19 +149,31 @@ func buildNotifier(factory Factory, cfg config.NotifierConfig) (Notifier, error)
 		if err := decode(cfg.Config, &nc); err != nil {
 			return nil, err
 		}
-		return NewSlackNotifier(cfg.ID, nc, factory.Secrets)
+		return NewSlackNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
 	case "telegram":
 		var nc TelegramConfig
 		if err := decode(cfg.Config, &nc); err != nil {
 			return nil, err
 		}
-		return NewTelegramNotifier(cfg.ID, nc, factory.Secrets)
+		return NewTelegramNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
 	case "discord":
 		var nc DiscordConfig
 		if err := decode(cfg.Config, &nc); err != nil {
 			return nil, err
 		}
-		return NewDiscordNotifier(cfg.ID, nc, factory.Secrets)
+		return NewDiscordNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
+	case "msteams":
+		var nc MSTeamsConfig
+		if err := decode(cfg.Config, &nc); err != nil {
+			return nil, err
+		}
+		return NewMSTeamsNotifier(cfg.ID, nc, factory.Secrets, factory.Render)
+	case "github_issue":
+		var nc GitHubIssueConfig
+		if err := decode(cfg.Config, &nc); err != nil {
+			return nil, err
+		}
+		return NewGitHubIssueNotifier(cfg.ID, nc, factory.Secrets)
 	default:
 		return nil, fmt.Errorf("unsupported notifier type %q", cfg.Type)
 	}
@@ -133,6 +182,7 @@ func buildNotifier(factory Factory, cfg config.NotifierConfig) (Notifier, error)
 func decode(input map[string]interface{}, target interface{}) error {
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		WeaklyTypedInput: true,
+		DecodeHook:       recipientHook,
 		Result:           target,
 	})
 	if err != nil {
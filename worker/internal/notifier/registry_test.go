@@ -0,0 +1,96 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func gatedNotifiers() []config.NotifierConfig {
+	disabled := false
+	return []config.NotifierConfig{
+		{ID: "hook", Type: "webhook", Config: map[string]interface{}{"url": "https://hooks.example.com"}},
+		{
+			ID:           "voice-escalation",
+			Type:         "voice",
+			Environments: []string{"prod"},
+			Config:       map[string]interface{}{"auth_token_ref": "TWILIO_AUTH_TOKEN"},
+		},
+		{ID: "muted", Type: "webhook", Enabled: &disabled, Config: map[string]interface{}{"url": "https://hooks.example.com"}},
+	}
+}
+
+func TestBuildSkipsNotifiersGatedToOtherEnvironments(t *testing.T) {
+	reg, err := Build(Factory{Render: render.New(), Environment: "staging"}, gatedNotifiers())
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if _, ok := reg.Get("hook"); !ok {
+		t.Fatalf("expected ungated notifier to be built")
+	}
+	if _, ok := reg.Get("voice-escalation"); ok {
+		t.Fatalf("expected prod-only notifier to be skipped in staging")
+	}
+	if !reg.Disabled("voice-escalation") || !reg.Disabled("muted") || reg.Disabled("hook") {
+		t.Fatalf("unexpected disabled set")
+	}
+}
+
+func TestBuildIncludesNotifiersForMatchingEnvironment(t *testing.T) {
+	factory := Factory{
+		Render:      render.New(),
+		Secrets:     map[string]string{"TWILIO_AUTH_TOKEN": "token"},
+		Environment: "prod",
+	}
+	reg, err := Build(factory, gatedNotifiers())
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if _, ok := reg.Get("voice-escalation"); !ok {
+		t.Fatalf("expected prod-only notifier to be built in prod")
+	}
+	if _, ok := reg.Get("muted"); ok {
+		t.Fatalf("expected disabled notifier to stay off in prod")
+	}
+}
+
+func TestBuildResolvesSecretRefsInConfig(t *testing.T) {
+	factory := Factory{
+		Render:  render.New(),
+		Secrets: map[string]string{"TELEGRAM_CHAT_ID": "-100123", "HOOK_TOKEN": "s3cret"},
+	}
+	reg, err := Build(factory, []config.NotifierConfig{
+		{ID: "telegram", Type: "telegram", Config: map[string]interface{}{"chat_id": `{{ secret "TELEGRAM_CHAT_ID" }}`}},
+		{ID: "hook", Type: "webhook", Config: map[string]interface{}{
+			"url":      "https://hooks.example.com",
+			"template": `{"status": "{{ .status }}"}`,
+			"headers":  map[string]interface{}{"Authorization": `Bearer {{ secret "HOOK_TOKEN" }}`},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	n, _ := reg.Get("telegram")
+	if got := n.(*telegramNotifier).cfg.ChatID; got != "-100123" {
+		t.Fatalf("expected chat_id from secret, got %q", got)
+	}
+	hook, _ := reg.Get("hook")
+	cfg := hook.(*webhookNotifier).cfg
+	if got := cfg.Headers["Authorization"]; got != "Bearer s3cret" {
+		t.Fatalf("expected header from secret, got %q", got)
+	}
+	if cfg.Template != `{"status": "{{ .status }}"}` {
+		t.Fatalf("expected event template to stay unrendered, got %q", cfg.Template)
+	}
+}
+
+func TestBuildFailsOnMissingSecretRef(t *testing.T) {
+	_, err := Build(Factory{Render: render.New()}, []config.NotifierConfig{
+		{ID: "telegram", Type: "telegram", Config: map[string]interface{}{"chat_id": `{{ secret "TELEGRAM_CHAT_ID" }}`}},
+	})
+	if err == nil || !strings.Contains(err.Error(), `secret "TELEGRAM_CHAT_ID" not found`) {
+		t.Fatalf("expected missing secret error, got %v", err)
+	}
+}
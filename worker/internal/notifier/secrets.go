@@ -0,0 +1,104 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// resolveSecretRefs renders string values in a notifier config that only
+// reference secrets, such as `chat_id: '{{ secret "TELEGRAM_CHAT_ID" }}'`,
+// recursing into nested maps and lists. Values that use event data are left
+// untouched for the notifier to render when it sends.
+func resolveSecretRefs(input map[string]interface{}, secrets map[string]string) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		resolved, err := resolveSecretValue(value, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		out[key] = resolved
+	}
+	return out, nil
+}
+
+func resolveSecretValue(value interface{}, secrets map[string]string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return renderSecretTemplate(v, secrets)
+	case map[string]interface{}:
+		return resolveSecretRefs(v, secrets)
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = item
+		}
+		return resolveSecretRefs(converted, secrets)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := resolveSecretValue(item, secrets)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func renderSecretTemplate(value string, secrets map[string]string) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	tmpl, err := template.New("config").Funcs(template.FuncMap{
+		"secret": func(key string) (string, error) {
+			val, ok := secrets[key]
+			if !ok {
+				return "", fmt.Errorf("secret %q not found", key)
+			}
+			return val, nil
+		},
+	}).Parse(value)
+	if err != nil || !onlySecretRefs(tmpl.Tree.Root) {
+		// Not a secret reference: either a template the notifier renders
+		// per event, or a literal it reports itself.
+		return value, nil
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// onlySecretRefs reports whether every action in the template is a plain
+// `secret "NAME"` call.
+func onlySecretRefs(root *parse.ListNode) bool {
+	for _, node := range root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) != 1 {
+				return false
+			}
+			args := n.Pipe.Cmds[0].Args
+			if len(args) != 2 {
+				return false
+			}
+			ident, ok := args[0].(*parse.IdentifierNode)
+			if !ok || ident.Ident != "secret" {
+				return false
+			}
+			if _, ok := args[1].(*parse.StringNode); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
 )
 
 // SlackConfig defines Slack webhook integration.
@@ -14,17 +16,22 @@ type SlackConfig struct {
 	WebhookURLRef string `mapstructure:"webhook_url_ref"`
 	Channel       string `mapstructure:"channel"`
 	Username      string `mapstructure:"username"`
+	// FiringTemplate and ResolvedTemplate replace the built-in message of
+	// firing and resolved events.
+	FiringTemplate   string `mapstructure:"firing_template"`
+	ResolvedTemplate string `mapstructure:"resolved_template"`
 }
 
 type slackNotifier struct {
-	id     string
-	cfg    SlackConfig
-	url    string
-	client *http.Client
+	id        string
+	cfg       SlackConfig
+	url       string
+	client    *http.Client
+	templates statusTemplates
 }
 
 // NewSlackNotifier builds Slack notifier.
-func NewSlackNotifier(id string, cfg SlackConfig, secrets map[string]string) (Notifier, error) {
+func NewSlackNotifier(id string, cfg SlackConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	url, ok := secrets[cfg.WebhookURLRef]
 	if cfg.WebhookURLRef != "" && !ok {
 		return nil, fmt.Errorf("missing secret %q", cfg.WebhookURLRef)
@@ -36,6 +43,12 @@ func NewSlackNotifier(id string, cfg SlackConfig, secrets map[string]string) (No
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: statusTemplates{
+			firing:   cfg.FiringTemplate,
+			resolved: cfg.ResolvedTemplate,
+			secrets:  secrets,
+			renderer: renderer,
+		},
 	}, nil
 }
 
@@ -44,13 +57,36 @@ func (s *slackNotifier) ID() string {
 }
 
 func (s *slackNotifier) Notify(ctx context.Context, event Event) error {
-	payload := map[string]interface{}{
-		"text": fmt.Sprintf("*%s* %s\nStatus: %s | Severity: %s | Run: %s",
+	text, ok, err := s.templates.render(event)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		text = fmt.Sprintf("*%s* %s\nStatus: %s | Severity: %s | Run: %s",
 			event.Check.Name,
 			event.Summary,
 			event.Status,
 			event.Severity,
-			event.RunID),
+			event.RunID)
+	}
+	payload := map[string]interface{}{
+		"text": text,
+	}
+	if event.CheckURL != "" {
+		payload["blocks"] = []map[string]interface{}{
+			{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": payload["text"]},
+			},
+			{
+				"type": "actions",
+				"elements": []map[string]interface{}{{
+					"type": "button",
+					"text": map[string]interface{}{"type": "plain_text", "text": "Open check"},
+					"url":  event.CheckURL,
+				}},
+			},
+		}
 	}
 	if s.cfg.Channel != "" {
 		payload["channel"] = s.cfg.Channel
@@ -73,7 +109,7 @@ func (s *slackNotifier) Notify(ctx context.Context, event Event) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("slack webhook: %s", resp.Status)
+		return responseError("slack webhook", resp)
 	}
 	return nil
 }
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestSlackLinksCheckOnlyWithURL(t *testing.T) {
+	payloads := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := NewSlackNotifier("slack", SlackConfig{WebhookURLRef: "SLACK"}, map[string]string{"SLACK": srv.URL}, render.New())
+	if err != nil {
+		t.Fatalf("new slack: %v", err)
+	}
+
+	event := Event{Check: config.CheckConfig{ID: "api", Name: "API"}, Status: "firing", CheckURL: "https://status.example.com/checks/api"}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	payload := <-payloads
+	blocks, _ := payload["blocks"].([]any)
+	if len(blocks) != 2 {
+		t.Fatalf("expected text and button blocks, got %v", payload["blocks"])
+	}
+	button := blocks[1].(map[string]any)["elements"].([]any)[0].(map[string]any)
+	if button["url"] != event.CheckURL {
+		t.Fatalf("expected button to link %s, got %v", event.CheckURL, button["url"])
+	}
+
+	event.CheckURL = ""
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if payload := <-payloads; payload["blocks"] != nil {
+		t.Fatalf("expected no link without ui_base_url, got %v", payload["blocks"])
+	}
+}
+
+func TestDiscordLinksCheckOnlyWithURL(t *testing.T) {
+	payloads := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := NewDiscordNotifier("discord", DiscordConfig{WebhookURLRef: "DISCORD"}, map[string]string{"DISCORD": srv.URL}, render.New())
+	if err != nil {
+		t.Fatalf("new discord: %v", err)
+	}
+
+	event := Event{Check: config.CheckConfig{ID: "api", Name: "API"}, Status: "firing", CheckURL: "https://status.example.com/checks/api"}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	embeds, _ := (<-payloads)["embeds"].([]any)
+	if len(embeds) != 1 || embeds[0].(map[string]any)["url"] != event.CheckURL {
+		t.Fatalf("expected embed linking the check, got %v", embeds)
+	}
+
+	event.CheckURL = ""
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if payload := <-payloads; payload["embeds"] != nil {
+		t.Fatalf("expected no link without ui_base_url, got %v", payload["embeds"])
+	}
+}
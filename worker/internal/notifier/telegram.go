@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
 )
 
 // TelegramConfig configures Telegram notifications.
@@ -15,17 +17,22 @@ type TelegramConfig struct {
 	BotTokenRef string `mapstructure:"bot_token_ref"`
 	ChatID      string `mapstructure:"chat_id"`
 	ParseMode   string `mapstructure:"parse_mode"`
+	// FiringTemplate and ResolvedTemplate replace the built-in message of
+	// firing and resolved events.
+	FiringTemplate   string `mapstructure:"firing_template"`
+	ResolvedTemplate string `mapstructure:"resolved_template"`
 }
 
 type telegramNotifier struct {
-	id     string
-	cfg    TelegramConfig
-	token  string
-	client *http.Client
+	id        string
+	cfg       TelegramConfig
+	token     string
+	client    *http.Client
+	templates statusTemplates
 }
 
 // NewTelegramNotifier constructs a Telegram notifier.
-func NewTelegramNotifier(id string, cfg TelegramConfig, secrets map[string]string) (Notifier, error) {
+func NewTelegramNotifier(id string, cfg TelegramConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	token, ok := secrets[cfg.BotTokenRef]
 	if cfg.BotTokenRef != "" && !ok {
 		return nil, fmt.Errorf("missing secret %q", cfg.BotTokenRef)
@@ -37,6 +44,12 @@ func NewTelegramNotifier(id string, cfg TelegramConfig, secrets map[string]strin
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: statusTemplates{
+			firing:   cfg.FiringTemplate,
+			resolved: cfg.ResolvedTemplate,
+			secrets:  secrets,
+			renderer: renderer,
+		},
 	}, nil
 }
 
@@ -45,13 +58,19 @@ func (t *telegramNotifier) ID() string {
 }
 
 func (t *telegramNotifier) Notify(ctx context.Context, event Event) error {
-	text := fmt.Sprintf("*%s* %s\nStatus: %s\nSeverity: %s\nRun: `%s`",
-		event.Check.Name,
-		event.Summary,
-		strings.ToUpper(event.Status),
-		strings.ToUpper(event.Severity),
-		event.RunID,
-	)
+	text, ok, err := t.templates.render(event)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		text = fmt.Sprintf("*%s* %s\nStatus: %s\nSeverity: %s\nRun: `%s`",
+			event.Check.Name,
+			event.Summary,
+			strings.ToUpper(event.Status),
+			strings.ToUpper(event.Severity),
+			event.RunID,
+		)
+	}
 	payload := map[string]interface{}{
 		"chat_id": t.cfg.ChatID,
 		"text":    text,
@@ -77,7 +96,7 @@ func (t *telegramNotifier) Notify(ctx context.Context, event Event) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("telegram response: %s", resp.Status)
+		return responseError("telegram response", resp)
 	}
 	return nil
 }
@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// eventTemplateData exposes an event to notifier templates. Resolved events
+// that know when the failure started also carry the downtime, and ui holds
+// check_url only when a UI base URL is configured.
+func eventTemplateData(event Event) map[string]interface{} {
+	data := map[string]interface{}{
+		"check": map[string]interface{}{
+			"id":     event.Check.ID,
+			"name":   event.Check.Name,
+			"target": event.Check.Target,
+		},
+		"status":      event.Status,
+		"severity":    event.Severity,
+		"summary":     event.Summary,
+		"reason":      event.Reason,
+		"labels":      event.Labels,
+		"run_id":      event.RunID,
+		"incident_id": event.IncidentID,
+		"occurred_at": event.OccurredAt.Format(time.RFC3339),
+		"first_failure_at": func() interface{} {
+			if event.FirstFailureAt.IsZero() {
+				return nil
+			}
+			return event.FirstFailureAt.Format(time.RFC3339)
+		}(),
+		"result": ResultTemplateData(event.Result),
+		"ui":     map[string]interface{}{},
+	}
+	if event.CheckURL != "" {
+		data["ui"] = map[string]interface{}{"check_url": event.CheckURL}
+	}
+	if event.Status == "resolved" && !event.FirstFailureAt.IsZero() && !event.OccurredAt.IsZero() {
+		data["downtime"] = event.OccurredAt.Sub(event.FirstFailureAt).Round(time.Second).String()
+	}
+	return data
+}
+
+// statusTemplates are the optional message templates of chat notifiers.
+// The template matching the event status replaces the built-in message.
+type statusTemplates struct {
+	firing   string
+	resolved string
+	secrets  map[string]string
+	renderer *render.Engine
+}
+
+// render returns the message for the event, or ok=false when no template
+// is configured for its status.
+func (t statusTemplates) render(event Event) (message string, ok bool, err error) {
+	name, tmpl := "firing_template", t.firing
+	if event.Status == "resolved" {
+		name, tmpl = "resolved_template", t.resolved
+	}
+	if tmpl == "" || t.renderer == nil {
+		return "", false, nil
+	}
+	message, err = t.renderer.RenderString(tmpl, render.TemplateContext{
+		Secrets: t.secrets,
+		Data:    eventTemplateData(event),
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("render %s: %w", name, err)
+	}
+	return message, true, nil
+}
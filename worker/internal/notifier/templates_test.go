@@ -0,0 +1,128 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func startPayloadServer(t *testing.T) (string, chan map[string]any) {
+	t.Helper()
+	payloads := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, payloads
+}
+
+func templateEvents() (Event, Event) {
+	failedAt := time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)
+	firing := Event{Check: config.CheckConfig{ID: "api", Name: "API"}, Status: "firing", Summary: "status 500", FirstFailureAt: failedAt, OccurredAt: failedAt}
+	resolved := firing
+	resolved.Status = "resolved"
+	resolved.Summary = "Check succeeded"
+	resolved.OccurredAt = failedAt.Add(12*time.Minute + 30*time.Second)
+	return firing, resolved
+}
+
+func TestSlackUsesStatusTemplates(t *testing.T) {
+	srvURL, payloads := startPayloadServer(t)
+	n, err := NewSlackNotifier("slack", SlackConfig{
+		WebhookURLRef:    "SLACK",
+		FiringTemplate:   `:red_circle: {{ .check.name }} down: {{ .summary }}`,
+		ResolvedTemplate: `:large_green_circle: {{ .check.name }} back after {{ .downtime }}`,
+	}, map[string]string{"SLACK": srvURL}, render.New())
+	if err != nil {
+		t.Fatalf("new slack: %v", err)
+	}
+
+	firing, resolved := templateEvents()
+	if err := n.Notify(context.Background(), firing); err != nil {
+		t.Fatalf("notify firing: %v", err)
+	}
+	if text := (<-payloads)["text"]; text != ":red_circle: API down: status 500" {
+		t.Fatalf("unexpected firing text %q", text)
+	}
+	if err := n.Notify(context.Background(), resolved); err != nil {
+		t.Fatalf("notify resolved: %v", err)
+	}
+	if text := (<-payloads)["text"]; text != ":large_green_circle: API back after 12m30s" {
+		t.Fatalf("unexpected resolved text %q", text)
+	}
+}
+
+func TestDiscordFallsBackWithoutStatusTemplate(t *testing.T) {
+	srvURL, payloads := startPayloadServer(t)
+	n, err := NewDiscordNotifier("discord", DiscordConfig{
+		WebhookURLRef:  "DISCORD",
+		FiringTemplate: `{{ .check.name }} is failing`,
+	}, map[string]string{"DISCORD": srvURL}, render.New())
+	if err != nil {
+		t.Fatalf("new discord: %v", err)
+	}
+
+	firing, resolved := templateEvents()
+	if err := n.Notify(context.Background(), firing); err != nil {
+		t.Fatalf("notify firing: %v", err)
+	}
+	if content := (<-payloads)["content"]; content != "API is failing" {
+		t.Fatalf("unexpected firing content %q", content)
+	}
+	if err := n.Notify(context.Background(), resolved); err != nil {
+		t.Fatalf("notify resolved: %v", err)
+	}
+	if content, _ := (<-payloads)["content"].(string); !strings.HasPrefix(content, "**API** Check succeeded\nStatus: resolved") {
+		t.Fatalf("expected built-in resolved content, got %q", content)
+	}
+}
+
+// redirectTransport sends every request to target, keeping the path.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTelegramUsesResolvedTemplate(t *testing.T) {
+	srvURL, payloads := startPayloadServer(t)
+	target, _ := url.Parse(srvURL)
+	n, err := NewTelegramNotifier("telegram", TelegramConfig{
+		BotTokenRef:      "TELEGRAM",
+		ChatID:           "42",
+		ResolvedTemplate: `✅ {{ .check.name }} recovered ({{ .downtime }} downtime)`,
+	}, map[string]string{"TELEGRAM": "token"}, render.New())
+	if err != nil {
+		t.Fatalf("new telegram: %v", err)
+	}
+	n.(*telegramNotifier).client.Transport = redirectTransport{target: target}
+
+	firing, resolved := templateEvents()
+	if err := n.Notify(context.Background(), firing); err != nil {
+		t.Fatalf("notify firing: %v", err)
+	}
+	if text, _ := (<-payloads)["text"].(string); !strings.HasPrefix(text, "*API* status 500\nStatus: FIRING") {
+		t.Fatalf("expected built-in firing text, got %q", text)
+	}
+	if err := n.Notify(context.Background(), resolved); err != nil {
+		t.Fatalf("notify resolved: %v", err)
+	}
+	if text := (<-payloads)["text"]; text != "✅ API recovered (12m30s downtime)" {
+		t.Fatalf("unexpected resolved text %q", text)
+	}
+}
@@ -2,20 +2,27 @@ package notifier
 
 import (
 	"context"
+	"encoding/xml"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
 )
 
 // TwilioSMSConfig configures Twilio SMS delivery.
 type TwilioSMSConfig struct {
-	Provider     string   `mapstructure:"provider"`
-	AccountSID   string   `mapstructure:"account_sid"`
-	AuthTokenRef string   `mapstructure:"auth_token_ref"`
-	From         string   `mapstructure:"from"`
-	To           []string `mapstructure:"to"`
+	Provider     string      `mapstructure:"provider"`
+	AccountSID   string      `mapstructure:"account_sid"`
+	AuthTokenRef string      `mapstructure:"auth_token_ref"`
+	From         string      `mapstructure:"from"`
+	To           []Recipient `mapstructure:"to"`
+	// Templates maps a locale to the message template of recipients with
+	// that locale; DefaultLocale is used for the others.
+	Templates     map[string]string `mapstructure:"templates"`
+	DefaultLocale string            `mapstructure:"default_locale"`
 }
 
 type twilioSMSNotifier struct {
@@ -23,10 +30,11 @@ type twilioSMSNotifier struct {
 	cfg       TwilioSMSConfig
 	authToken string
 	client    *http.Client
+	templates localeTemplates
 }
 
 // NewTwilioSMSNotifier constructs a Twilio SMS notifier.
-func NewTwilioSMSNotifier(id string, cfg TwilioSMSConfig, secrets map[string]string) (Notifier, error) {
+func NewTwilioSMSNotifier(id string, cfg TwilioSMSConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	if cfg.Provider == "" {
 		cfg.Provider = "twilio"
 	}
@@ -44,6 +52,7 @@ func NewTwilioSMSNotifier(id string, cfg TwilioSMSConfig, secrets map[string]str
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: newLocaleTemplates(cfg.Templates, cfg.DefaultLocale, secrets, renderer),
 	}, nil
 }
 
@@ -60,12 +69,9 @@ func (t *twilioSMSNotifier) Notify(ctx context.Context, event Event) error {
 		event.Severity,
 		event.RunID,
 	)
-	for _, to := range t.cfg.To {
-		if err := t.sendMessage(ctx, to, body); err != nil {
-			return err
-		}
-	}
-	return nil
+	return t.templates.forEachRecipient(event, t.cfg.To, body, func(to Recipient, message string) error {
+		return t.sendMessage(ctx, to.Number, message)
+	})
 }
 
 func (t *twilioSMSNotifier) sendMessage(ctx context.Context, to, body string) error {
@@ -86,19 +92,23 @@ func (t *twilioSMSNotifier) sendMessage(ctx context.Context, to, body string) er
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("twilio sms failed: %s", resp.Status)
+		return responseError("twilio sms failed", resp)
 	}
 	return nil
 }
 
 // TwilioVoiceConfig configures voice call notifier.
 type TwilioVoiceConfig struct {
-	Provider     string   `mapstructure:"provider"`
-	AccountSID   string   `mapstructure:"account_sid"`
-	AuthTokenRef string   `mapstructure:"auth_token_ref"`
-	From         string   `mapstructure:"from"`
-	To           []string `mapstructure:"to"`
-	VoiceMessage string   `mapstructure:"voice_message"`
+	Provider     string      `mapstructure:"provider"`
+	AccountSID   string      `mapstructure:"account_sid"`
+	AuthTokenRef string      `mapstructure:"auth_token_ref"`
+	From         string      `mapstructure:"from"`
+	To           []Recipient `mapstructure:"to"`
+	VoiceMessage string      `mapstructure:"voice_message"`
+	// Templates maps a locale to the spoken message of recipients with that
+	// locale; DefaultLocale is used for the others.
+	Templates     map[string]string `mapstructure:"templates"`
+	DefaultLocale string            `mapstructure:"default_locale"`
 }
 
 type twilioVoiceNotifier struct {
@@ -106,10 +116,11 @@ type twilioVoiceNotifier struct {
 	cfg       TwilioVoiceConfig
 	authToken string
 	client    *http.Client
+	templates localeTemplates
 }
 
 // NewTwilioVoiceNotifier constructs a Twilio voice notifier.
-func NewTwilioVoiceNotifier(id string, cfg TwilioVoiceConfig, secrets map[string]string) (Notifier, error) {
+func NewTwilioVoiceNotifier(id string, cfg TwilioVoiceConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	if cfg.Provider == "" {
 		cfg.Provider = "twilio"
 	}
@@ -127,6 +138,7 @@ func NewTwilioVoiceNotifier(id string, cfg TwilioVoiceConfig, secrets map[string
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: newLocaleTemplates(cfg.Templates, cfg.DefaultLocale, secrets, renderer),
 	}, nil
 }
 
@@ -144,20 +156,26 @@ func (t *twilioVoiceNotifier) Notify(ctx context.Context, event Event) error {
 	if t.cfg.VoiceMessage != "" {
 		body = t.cfg.VoiceMessage + " (Status " + event.Status + ")"
 	}
-	for _, to := range t.cfg.To {
-		if err := t.startCall(ctx, to, body); err != nil {
-			return err
-		}
-	}
-	return nil
+	return t.templates.forEachRecipient(event, t.cfg.To, body, func(to Recipient, message string) error {
+		return t.startCall(ctx, to, message)
+	})
 }
 
-func (t *twilioVoiceNotifier) startCall(ctx context.Context, to, message string) error {
+func (t *twilioVoiceNotifier) startCall(ctx context.Context, to Recipient, message string) error {
 	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Calls.json", t.cfg.AccountSID)
+	var text strings.Builder
+	_ = xml.EscapeText(&text, []byte(message))
+	say := "<Say>"
+	if to.Locale != "" {
+		// The locale also picks the voice's language, e.g. de-DE.
+		var language strings.Builder
+		_ = xml.EscapeText(&language, []byte(to.Locale))
+		say = fmt.Sprintf(`<Say language="%s">`, language.String())
+	}
 	form := url.Values{}
 	form.Set("From", t.cfg.From)
-	form.Set("To", to)
-	form.Set("Twiml", fmt.Sprintf("<Response><Say>%s</Say></Response>", message))
+	form.Set("To", to.Number)
+	form.Set("Twiml", fmt.Sprintf("<Response>%s%s</Say></Response>", say, text.String()))
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
 	if err != nil {
 		return err
@@ -170,7 +188,7 @@ func (t *twilioVoiceNotifier) startCall(ctx context.Context, to, message string)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("twilio voice failed: %s", resp.Status)
+		return responseError("twilio voice failed", resp)
 	}
 	return nil
 }
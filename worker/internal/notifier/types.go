@@ -18,9 +18,14 @@ type Event struct {
 	Summary        string
 	Details        map[string]any
 	Labels         map[string]string
-	RunID          string
+	RunID          string // unique per dispatch
+	IncidentID     string // shared by every notification of one failure episode
 	FirstFailureAt time.Time
 	OccurredAt     time.Time
+	// Reason is the failure code of the result, e.g. "tls_expired".
+	Reason string
+	// CheckURL links to the check in the UI; empty when no ui_base_url is set.
+	CheckURL string
 }
 
 // Notifier represents a delivery mechanism.
@@ -33,4 +38,6 @@ type Notifier interface {
 type Factory struct {
 	Secrets map[string]string
 	Render  *render.Engine
+	// Environment is matched against NotifierConfig.Environments.
+	Environment string
 }
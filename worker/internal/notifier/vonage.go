@@ -14,14 +14,18 @@ import (
 
 // VonageSMSConfig configures Vonage SMS delivery.
 type VonageSMSConfig struct {
-	Provider      string   `mapstructure:"provider"`
-	APIKey        string   `mapstructure:"api_key"`
-	APIKeyRef     string   `mapstructure:"api_key_ref"`
-	APISecret     string   `mapstructure:"api_secret"`
-	APISecretRef  string   `mapstructure:"api_secret_ref"`
-	From          string   `mapstructure:"from"`
-	To            []string `mapstructure:"to"`
-	MessagePrefix string   `mapstructure:"message_prefix"`
+	Provider      string      `mapstructure:"provider"`
+	APIKey        string      `mapstructure:"api_key"`
+	APIKeyRef     string      `mapstructure:"api_key_ref"`
+	APISecret     string      `mapstructure:"api_secret"`
+	APISecretRef  string      `mapstructure:"api_secret_ref"`
+	From          string      `mapstructure:"from"`
+	To            []Recipient `mapstructure:"to"`
+	MessagePrefix string      `mapstructure:"message_prefix"`
+	// Templates maps a locale to the message template of recipients with
+	// that locale; DefaultLocale is used for the others.
+	Templates     map[string]string `mapstructure:"templates"`
+	DefaultLocale string            `mapstructure:"default_locale"`
 }
 
 type vonageSMSNotifier struct {
@@ -30,10 +34,11 @@ type vonageSMSNotifier struct {
 	apiKey    string
 	apiSecret string
 	client    *http.Client
+	templates localeTemplates
 }
 
 // NewVonageSMSNotifier constructs a Vonage (Nexmo) SMS notifier.
-func NewVonageSMSNotifier(id string, cfg VonageSMSConfig, secrets map[string]string) (Notifier, error) {
+func NewVonageSMSNotifier(id string, cfg VonageSMSConfig, secrets map[string]string, renderer *render.Engine) (Notifier, error) {
 	apiKey := cfg.APIKey
 	if apiKey == "" && cfg.APIKeyRef != "" {
 		if val, ok := secrets[cfg.APIKeyRef]; ok {
@@ -64,6 +69,7 @@ func NewVonageSMSNotifier(id string, cfg VonageSMSConfig, secrets map[string]str
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: newLocaleTemplates(cfg.Templates, cfg.DefaultLocale, secrets, renderer),
 	}, nil
 }
 
@@ -83,12 +89,9 @@ func (v *vonageSMSNotifier) Notify(ctx context.Context, event Event) error {
 	if v.cfg.MessagePrefix != "" {
 		body = fmt.Sprintf("%s %s", v.cfg.MessagePrefix, body)
 	}
-	for _, to := range v.cfg.To {
-		if err := v.sendMessage(ctx, to, body); err != nil {
-			return err
-		}
-	}
-	return nil
+	return v.templates.forEachRecipient(event, v.cfg.To, body, func(to Recipient, message string) error {
+		return v.sendMessage(ctx, to.Number, message)
+	})
 }
 
 func (v *vonageSMSNotifier) sendMessage(ctx context.Context, to, message string) error {
@@ -111,29 +114,34 @@ func (v *vonageSMSNotifier) sendMessage(ctx context.Context, to, message string)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("vonage sms failed: %s", resp.Status)
+		return responseError("vonage sms failed", resp)
 	}
 	return nil
 }
 
 // VonageVoiceConfig configures Vonage Voice calls.
 type VonageVoiceConfig struct {
-	Provider      string   `mapstructure:"provider"`
-	JWT           string   `mapstructure:"jwt"`
-	JWTRef        string   `mapstructure:"jwt_ref"`
-	From          string   `mapstructure:"from"`
-	To            []string `mapstructure:"to"`
-	Message       string   `mapstructure:"message"`
-	MessagePrefix string   `mapstructure:"message_prefix"`
+	Provider      string      `mapstructure:"provider"`
+	JWT           string      `mapstructure:"jwt"`
+	JWTRef        string      `mapstructure:"jwt_ref"`
+	From          string      `mapstructure:"from"`
+	To            []Recipient `mapstructure:"to"`
+	Message       string      `mapstructure:"message"`
+	MessagePrefix string      `mapstructure:"message_prefix"`
+	// Templates maps a locale to the spoken message of recipients with that
+	// locale, in place of Message; DefaultLocale is used for the others.
+	Templates     map[string]string `mapstructure:"templates"`
+	DefaultLocale string            `mapstructure:"default_locale"`
 }
 
 type vonageVoiceNotifier struct {
-	id       string
-	cfg      VonageVoiceConfig
-	jwt      string
-	client   *http.Client
-	renderer *render.Engine
-	secrets  map[string]string
+	id        string
+	cfg       VonageVoiceConfig
+	jwt       string
+	client    *http.Client
+	renderer  *render.Engine
+	secrets   map[string]string
+	templates localeTemplates
 }
 
 // NewVonageVoiceNotifier constructs a Vonage voice notifier.
@@ -158,6 +166,7 @@ func NewVonageVoiceNotifier(id string, cfg VonageVoiceConfig, secrets map[string
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: newLocaleTemplates(cfg.Templates, cfg.DefaultLocale, secrets, renderer),
 	}, nil
 }
 
@@ -166,13 +175,9 @@ func (v *vonageVoiceNotifier) ID() string {
 }
 
 func (v *vonageVoiceNotifier) Notify(ctx context.Context, event Event) error {
-	message := v.composeMessage(event)
-	for _, to := range v.cfg.To {
-		if err := v.startCall(ctx, to, message); err != nil {
-			return err
-		}
-	}
-	return nil
+	return v.templates.forEachRecipient(event, v.cfg.To, v.composeMessage(event), func(to Recipient, message string) error {
+		return v.startCall(ctx, to, message)
+	})
 }
 
 func (v *vonageVoiceNotifier) composeMessage(event Event) string {
@@ -185,6 +190,7 @@ func (v *vonageVoiceNotifier) composeMessage(event Event) string {
 				"severity":         event.Severity,
 				"summary":          event.Summary,
 				"run_id":           event.RunID,
+				"incident_id":      event.IncidentID,
 				"first_failure_at": event.FirstFailureAt,
 				"labels":           event.Labels,
 			},
@@ -205,24 +211,27 @@ func (v *vonageVoiceNotifier) composeMessage(event Event) string {
 	return base
 }
 
-func (v *vonageVoiceNotifier) startCall(ctx context.Context, to, message string) error {
+func (v *vonageVoiceNotifier) startCall(ctx context.Context, to Recipient, message string) error {
+	talk := map[string]interface{}{
+		"action": "talk",
+		"text":   message,
+	}
+	if to.Locale != "" {
+		// The locale also picks the voice's language, e.g. de-DE.
+		talk["language"] = to.Locale
+	}
 	payload := map[string]interface{}{
 		"to": []map[string]string{
 			{
 				"type":   "phone",
-				"number": to,
+				"number": to.Number,
 			},
 		},
 		"from": map[string]string{
 			"type":   "phone",
 			"number": v.cfg.From,
 		},
-		"ncco": []map[string]interface{}{
-			{
-				"action": "talk",
-				"text":   message,
-			},
-		},
+		"ncco": []map[string]interface{}{talk},
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -240,7 +249,7 @@ func (v *vonageVoiceNotifier) startCall(ctx context.Context, to, message string)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("vonage voice failed: %s", resp.Status)
+		return responseError("vonage voice failed", resp)
 	}
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/osbits/upupup/worker/internal/checks"
 	"github.com/osbits/upupup/worker/internal/render"
 )
 
@@ -49,28 +50,7 @@ func (w *webhookNotifier) Notify(ctx context.Context, event Event) error {
 	if method == "" {
 		method = http.MethodPost
 	}
-	data := map[string]interface{}{
-		"check": map[string]interface{}{
-			"id":     event.Check.ID,
-			"name":   event.Check.Name,
-			"target": event.Check.Target,
-		},
-		"status":      event.Status,
-		"severity":    event.Severity,
-		"summary":     event.Summary,
-		"labels":      event.Labels,
-		"run_id":      event.RunID,
-		"occurred_at": event.OccurredAt.Format(time.RFC3339),
-		"first_failure_at": func() interface{} {
-			if event.FirstFailureAt.IsZero() {
-				return nil
-			}
-			return event.FirstFailureAt.Format(time.RFC3339)
-		}(),
-		"ui": map[string]interface{}{
-			"check_url": fmt.Sprintf("https://monitoring.local/checks/%s", event.Check.ID),
-		},
-	}
+	data := eventTemplateData(event)
 	ctxRender := render.TemplateContext{
 		Secrets: w.secrets,
 		Data:    data,
@@ -105,7 +85,97 @@ func (w *webhookNotifier) Notify(ctx context.Context, event Event) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook response: %s", resp.Status)
+		return responseError("webhook response", resp)
 	}
 	return nil
 }
+
+// ResultTemplateData exposes the full check result to webhook templates and
+// to the failure_summary_template of checks.
+// Timestamps render as RFC3339 strings (empty when unset) and durations as
+// milliseconds, so values can be embedded in JSON payloads directly.
+func ResultTemplateData(result checks.Result) map[string]interface{} {
+	assertions := make([]map[string]interface{}, 0, len(result.AssertionResults))
+	for _, assertion := range result.AssertionResults {
+		assertions = append(assertions, map[string]interface{}{
+			"id":      assertion.ID,
+			"kind":    assertion.Kind,
+			"op":      assertion.Op,
+			"path":    assertion.Path,
+			"passed":  assertion.Passed,
+			"warning": assertion.Warning,
+			"skipped": assertion.Skipped,
+			"message": assertion.Message,
+		})
+	}
+	targets := make([]map[string]interface{}, 0, len(result.Targets))
+	for _, target := range result.Targets {
+		targets = append(targets, map[string]interface{}{
+			"target":     target.Target,
+			"success":    target.Success,
+			"latency_ms": durationMillis(target.Latency),
+			"error":      target.Error,
+			"reason":     target.Reason,
+		})
+	}
+	errText := ""
+	if result.Error != nil {
+		errText = result.Error.Error()
+	}
+	metadata, _ := templateValue(result.Metadata).(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"success":      result.Success,
+		"started_at":   formatTime(result.StartedAt),
+		"completed_at": formatTime(result.CompletedAt),
+		"latency_ms":   durationMillis(result.Latency),
+		"error":        errText,
+		"reason":       result.Reason,
+		"assertions":   assertions,
+		"metadata":     metadata,
+		"targets":      targets,
+		"retry_after":  formatTime(result.RetryAfter),
+	}
+}
+
+func templateValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return formatTime(v)
+	case time.Duration:
+		return durationMillis(v)
+	case map[string]any:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = templateValue(item)
+		}
+		return out
+	case []map[string]any:
+		out := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			out = append(out, templateValue(item))
+		}
+		return out
+	case []any:
+		out := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			out = append(out, templateValue(item))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
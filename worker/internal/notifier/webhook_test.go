@@ -0,0 +1,200 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+func TestWebhookTemplatesFullResult(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := NewWebhookNotifier("hook", WebhookConfig{
+		URL: srv.URL,
+		Template: `{
+  "cipher": "{{ index .result.metadata "cipher_suite" }}",
+  "completed_at": "{{ .result.completed_at }}",
+  "latency_ms": {{ .result.latency_ms }},
+  "assertions": {{ to_json .result.assertions }},
+  "metadata": {{ to_json .result.metadata }}
+}`,
+	}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+
+	completedAt := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	event := Event{
+		Check:  config.CheckConfig{ID: "tls", Name: "TLS"},
+		Status: "firing",
+		Result: checks.Result{
+			CompletedAt: completedAt,
+			Latency:     1500 * time.Microsecond,
+			AssertionResults: []checks.AssertionResult{
+				{Kind: "ssl_valid_days", Op: "greater_than", Passed: false, Message: "cert expires in 3 days"},
+			},
+			Metadata: map[string]any{
+				"negotiated_protocol": "h2",
+				"cipher_suite":        "TLS_AES_128_GCM_SHA256",
+				"not_after":           completedAt.Add(72 * time.Hour),
+			},
+		},
+		OccurredAt: completedAt,
+	}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	var payload struct {
+		Cipher      string                   `json:"cipher"`
+		CompletedAt string                   `json:"completed_at"`
+		LatencyMS   float64                  `json:"latency_ms"`
+		Assertions  []map[string]interface{} `json:"assertions"`
+		Metadata    map[string]interface{}   `json:"metadata"`
+	}
+	body := <-bodies
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decode payload %s: %v", body, err)
+	}
+	if payload.Cipher != "TLS_AES_128_GCM_SHA256" {
+		t.Fatalf("unexpected cipher %q", payload.Cipher)
+	}
+	if payload.CompletedAt != "2025-03-04T05:06:07Z" || payload.LatencyMS != 1.5 {
+		t.Fatalf("unexpected timing fields: %s", body)
+	}
+	if len(payload.Assertions) != 1 || payload.Assertions[0]["message"] != "cert expires in 3 days" || payload.Assertions[0]["passed"] != false {
+		t.Fatalf("unexpected assertions: %+v", payload.Assertions)
+	}
+	if payload.Metadata["not_after"] != "2025-03-07T05:06:07Z" || payload.Metadata["negotiated_protocol"] != "h2" {
+		t.Fatalf("unexpected metadata: %+v", payload.Metadata)
+	}
+}
+
+func TestWebhookCheckURL(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := NewWebhookNotifier("hook", WebhookConfig{
+		URL:      srv.URL,
+		Template: `{{ with .ui.check_url }}{{ . }}{{ else }}none{{ end }}`,
+	}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+
+	for _, tc := range []struct{ url, want string }{
+		{url: "https://status.example.com/checks/api", want: "https://status.example.com/checks/api"},
+		{url: "", want: "none"},
+	} {
+		event := Event{Check: config.CheckConfig{ID: "api"}, Status: "firing", CheckURL: tc.url}
+		if err := n.Notify(context.Background(), event); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+		if got := string(<-bodies); got != tc.want {
+			t.Fatalf("expected %q, got %q", tc.want, got)
+		}
+	}
+}
+
+func TestWebhookLeavesOutEmptyCheckURL(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := NewWebhookNotifier("hook", WebhookConfig{
+		URL:      srv.URL,
+		Template: `{{ to_json .ui }}`,
+	}, nil, render.New())
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+	event := Event{Check: config.CheckConfig{ID: "api"}, Status: "firing"}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if got := string(<-bodies); got != "{}" {
+		t.Fatalf("expected no check_url without a ui base url, got %s", got)
+	}
+}
+
+func TestWebhookTemplateFileRendersLikeInline(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+
+	tmpl := `{"check": "{{ .check.name }}", "status": "{{ .status }}", "assertions": {{ to_json .result.assertions }}}`
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "incident.tmpl"), []byte(tmpl), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	inline, err := json.Marshal(tmpl)
+	if err != nil {
+		t.Fatalf("marshal template: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yml")
+	content := "notifiers:\n" +
+		"  - id: inline\n    type: webhook\n    config:\n      url: " + srv.URL + "\n      template: " + string(inline) + "\n" +
+		"  - id: from-file\n    type: webhook\n    config:\n      url: " + srv.URL + "\n      template_file: templates/incident.tmpl\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	reg, err := Build(Factory{Render: render.New()}, cfg.Notifiers)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	event := Event{
+		Check:  config.CheckConfig{ID: "api", Name: "API"},
+		Status: "firing",
+		Result: checks.Result{AssertionResults: []checks.AssertionResult{{Kind: "status_code", Op: "equals", Message: "status 500"}}},
+	}
+	var rendered []string
+	for _, id := range []string{"inline", "from-file"} {
+		n, ok := reg.Get(id)
+		if !ok {
+			t.Fatalf("expected notifier %s", id)
+		}
+		if err := n.Notify(context.Background(), event); err != nil {
+			t.Fatalf("notify %s: %v", id, err)
+		}
+		rendered = append(rendered, string(<-bodies))
+	}
+	if rendered[0] != rendered[1] {
+		t.Fatalf("expected identical payloads, got %s and %s", rendered[0], rendered[1])
+	}
+	if !json.Valid([]byte(rendered[1])) {
+		t.Fatalf("expected JSON payload, got %s", rendered[1])
+	}
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+// resetBaselineHooks returns the active reset_baseline hooks targeting the
+// check. While one is active, the check's next successful run becomes its new
+// baseline.
+func (r *Runner) resetBaselineHooks(now time.Time, check config.CheckConfig) []storage.HookExecution {
+	var result []storage.HookExecution
+	for _, hook := range r.fetchActiveHooks(now) {
+		if strings.EqualFold(strings.TrimSpace(hook.Kind), "reset_baseline") && hookMatchesCheck(hook, check) {
+			result = append(result, hook)
+		}
+	}
+	return result
+}
+
+// completeResetBaselineHooks ends the reset_baseline hooks of a check that
+// only targeted it, once the check stored its new baseline.
+func (r *Runner) completeResetBaselineHooks(hooks []storage.HookExecution, check config.CheckConfig) {
+	store := r.currentStore()
+	if len(hooks) == 0 || store == nil {
+		return
+	}
+	var anyCompleted bool
+	for _, hook := range hooks {
+		if !strings.EqualFold(strings.TrimSpace(hook.Scope), "check") || len(hook.TargetIDs) != 1 {
+			continue
+		}
+		if err := store.CompleteHookExecution(context.Background(), hook.ID); err != nil {
+			r.logger.Error("failed to complete reset_baseline hook", "hook_id", hook.HookID, "error", err)
+			continue
+		}
+		anyCompleted = true
+		r.logger.Info("completed reset_baseline hook after new baseline", "hook_id", hook.HookID, "check_id", check.ID)
+	}
+	if anyCompleted {
+		r.invalidateHookCache()
+	}
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+const redacted = "<redacted>"
+
+// sensitiveKeys are matched against notifier and sink config keys and request headers
+// whose literal values must not be printed.
+var sensitiveKeys = []string{"password", "secret", "token", "api_key", "apikey", "auth", "cookie", "credential", "private_key"}
+
+// EffectiveConfig returns a copy of cfg as the runner will use it: assertion
+// sets are expanded and every check carries its resolved interval, timeout,
+// retries, backoff settings and jitter. Secrets are never resolved, and literal values of
+// credential-like notifier and sink options and request headers are
+// redacted; template references such as {{ secret "x" }} are kept.
+func EffectiveConfig(cfg *config.Config) (*config.Config, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("copy config: %w", err)
+	}
+	var resolved config.Config
+	if err := yaml.Unmarshal(data, &resolved); err != nil {
+		return nil, fmt.Errorf("copy config: %w", err)
+	}
+	if err := applyAssertionSets(&resolved); err != nil {
+		return nil, err
+	}
+	resolved.CheckAssertionSets = nil
+
+	r := &Runner{defaults: resolved.Service.Defaults}
+	for i := range resolved.Checks {
+		check := &resolved.Checks[i]
+		check.AssertionSets = nil
+		schedule := config.CheckSchedule{}
+		if check.Schedule != nil {
+			schedule = *check.Schedule
+		}
+		retries := r.effectiveRetries(*check)
+		if cronSpec(*check) == "" {
+			schedule.Interval = &config.NullableDuration{Duration: r.effectiveInterval(*check), Set: true}
+		}
+		schedule.Timeout = &config.NullableDuration{Duration: checks.EffectiveTimeout(*check, r.defaults), Set: true}
+		schedule.Backoff = &config.NullableDuration{Duration: r.effectiveBackoff(*check), Set: true}
+		schedule.Retries = &retries
+		schedule.BackoffStrategy = r.effectiveBackoffStrategy(*check)
+		if schedule.BackoffStrategy == backoffExponential {
+			schedule.BackoffMax = &config.NullableDuration{Duration: r.effectiveBackoffMax(*check), Set: true}
+		}
+		backoffJitter := r.effectiveBackoffJitter(*check)
+		schedule.BackoffJitter = &backoffJitter
+		schedule.Jitter = &config.NullableDuration{Duration: r.effectiveJitter(*check), Set: true}
+		check.Schedule = &schedule
+
+		if check.Request != nil {
+			redactHeaders(check.Request.Headers)
+		}
+		if check.PreAuth != nil {
+			redactHeaders(check.PreAuth.Request.Headers)
+		}
+		check.Proxy = redactProxy(check.Proxy)
+	}
+	for i := range resolved.Notifiers {
+		redactMap(resolved.Notifiers[i].Config)
+	}
+	for i := range resolved.Sinks {
+		redactMap(resolved.Sinks[i].Config)
+	}
+	return &resolved, nil
+}
+
+func redactHeaders(headers map[string]string) {
+	for key, value := range headers {
+		if isSensitiveKey(key) && !strings.Contains(value, "{{") {
+			headers[key] = redacted
+		}
+	}
+}
+
+// redactProxy masks the password of a literal proxy URL.
+func redactProxy(raw string) string {
+	if raw == "" || strings.Contains(raw, "{{") {
+		return raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	return parsed.Redacted()
+}
+
+func redactMap(values map[string]interface{}) {
+	for key, value := range values {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			redactMap(typed)
+		case string:
+			if isSensitiveKey(key) && typed != "" && !strings.Contains(typed, "{{") {
+				values[key] = redacted
+			}
+		default:
+			if isSensitiveKey(key) && typed != nil {
+				values[key] = redacted
+			}
+		}
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	if strings.HasSuffix(key, "_ref") {
+		// *_ref options name a secret rather than holding its value.
+		return false
+	}
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
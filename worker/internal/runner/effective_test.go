@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+func TestEffectiveConfigMergesDefaults(t *testing.T) {
+	retries := 0
+	cfg := testConfig(
+		config.CheckConfig{ID: "plain", Type: "http", Target: "https://example.com"},
+		config.CheckConfig{
+			ID:            "tuned",
+			Type:          "http",
+			Target:        "https://example.com",
+			AssertionSets: []string{"ok"},
+			Schedule: &config.CheckSchedule{
+				Interval: &config.NullableDuration{Duration: 15 * time.Second, Set: true},
+				Retries:  &retries,
+			},
+			Request: &config.HTTPRequest{Headers: map[string]string{
+				"Authorization": "Bearer literal",
+				"X-Token":       `{{ secret "API_TOKEN" }}`,
+			}},
+			Assertions: []config.Assertion{{Kind: "latency_ms", Op: "less_than", Value: 300}},
+		},
+	)
+	cfg.Service.Defaults.Retries = 3
+	cfg.Service.Defaults.Backoff = config.Duration{Duration: time.Second}
+	cfg.CheckAssertionSets = map[string][]config.Assertion{
+		"ok": {{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	cfg.Notifiers = []config.NotifierConfig{{ID: "hook", Type: "webhook", Config: map[string]interface{}{
+		"url":          "https://hooks.example.com",
+		"password":     "hunter2",
+		"password_ref": "SMTP_PASSWORD",
+	}}}
+
+	resolved, err := EffectiveConfig(cfg)
+	if err != nil {
+		t.Fatalf("effective config: %v", err)
+	}
+
+	plain := resolved.Checks[0].Schedule
+	if plain == nil || plain.Interval.Duration != time.Minute || plain.Timeout.Duration != 2*time.Second || *plain.Retries != 3 || plain.Backoff.Duration != time.Second {
+		t.Fatalf("expected service defaults on plain check, got %+v", plain)
+	}
+	tuned := resolved.Checks[1]
+	if tuned.Schedule.Interval.Duration != 15*time.Second || *tuned.Schedule.Retries != 0 || tuned.Schedule.Timeout.Duration != 2*time.Second {
+		t.Fatalf("expected check overrides merged with defaults, got %+v", tuned.Schedule)
+	}
+	if len(tuned.Assertions) != 2 || tuned.Assertions[0].Kind != "status_code" || len(tuned.AssertionSets) != 0 {
+		t.Fatalf("expected assertion set expanded, got %+v", tuned.Assertions)
+	}
+	if tuned.Request.Headers["Authorization"] != redacted || tuned.Request.Headers["X-Token"] != `{{ secret "API_TOKEN" }}` {
+		t.Fatalf("unexpected header redaction: %+v", tuned.Request.Headers)
+	}
+	notifierCfg := resolved.Notifiers[0].Config
+	if notifierCfg["password"] != redacted || notifierCfg["password_ref"] != "SMTP_PASSWORD" || notifierCfg["url"] != "https://hooks.example.com" {
+		t.Fatalf("unexpected notifier redaction: %+v", notifierCfg)
+	}
+
+	if cfg.Checks[0].Schedule != nil || cfg.Notifiers[0].Config["password"] != "hunter2" || len(cfg.Checks[1].Assertions) != 1 {
+		t.Fatalf("expected source config to stay untouched")
+	}
+}
+
+func TestMinIntervalClampsShortIntervals(t *testing.T) {
+	interval := func(d time.Duration) *config.CheckSchedule {
+		return &config.CheckSchedule{Interval: &config.NullableDuration{Duration: d, Set: true}}
+	}
+	cfg := testConfig(
+		config.CheckConfig{ID: "typo", Type: "tcp", Target: "db:5432", Schedule: interval(time.Second)},
+		config.CheckConfig{ID: "normal", Type: "tcp", Target: "db:5432", Schedule: interval(30 * time.Second)},
+		config.CheckConfig{ID: "default", Type: "tcp", Target: "db:5432"},
+	)
+	cfg.Service.Defaults.MinInterval = config.Duration{Duration: 10 * time.Second}
+	r := newTestRunnerWith(t, cfg, nil, nil)
+
+	if got, clamped := r.clampedInterval(cfg.Checks[0]); got != 10*time.Second || !clamped {
+		t.Fatalf("expected 1s to be clamped to 10s, got %s (clamped=%v)", got, clamped)
+	}
+	if got, clamped := r.clampedInterval(cfg.Checks[1]); got != 30*time.Second || clamped {
+		t.Fatalf("expected 30s to be untouched, got %s (clamped=%v)", got, clamped)
+	}
+	if got := r.effectiveInterval(cfg.Checks[2]); got != time.Minute {
+		t.Fatalf("expected default interval, got %s", got)
+	}
+
+	resolved, err := EffectiveConfig(cfg)
+	if err != nil {
+		t.Fatalf("effective config: %v", err)
+	}
+	if got := resolved.Checks[0].Schedule.Interval.Duration; got != 10*time.Second {
+		t.Fatalf("expected -print-config to show the clamped interval, got %s", got)
+	}
+}
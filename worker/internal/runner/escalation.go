@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// defaultEscalationInterval is how often failing checks are re-evaluated for
+// escalation when service.escalation_interval is unset.
+const defaultEscalationInterval = 15 * time.Second
+
+func (r *Runner) escalationInterval() time.Duration {
+	if interval := r.cfg.Service.EscalationInterval.Duration; interval > 0 {
+		return interval
+	}
+	return defaultEscalationInterval
+}
+
+// runEscalationLoop advances the escalation stages of failing checks on a
+// steady clock, so a stage fires at its configured time even when the check
+// interval is longer than the stage delay.
+func (r *Runner) runEscalationLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.escalateFailing()
+		}
+	}
+}
+
+// escalateFailing re-evaluates the escalation stages of every running check
+// that is failing, using its latest result.
+func (r *Runner) escalateFailing() {
+	if r.inMaintenance(time.Now().In(r.location)) {
+		return
+	}
+	r.loopsMu.Lock()
+	running := make([]config.CheckConfig, 0, len(r.loops))
+	for _, loop := range r.loops {
+		running = append(running, loop.check)
+	}
+	r.loopsMu.Unlock()
+
+	for _, check := range running {
+		if _, ok := r.policies[check.Notifications.Route]; !ok {
+			continue
+		}
+		if r.suppressedByGroup(check) {
+			continue
+		}
+		state := r.getState(check.ID)
+		state.notifyMu.Lock()
+		if state.Failing && !state.LastResult.Maintenance && !state.LastResult.Pending {
+			r.sendEscalations(check, state, state.LastResult)
+		}
+		state.notifyMu.Unlock()
+	}
+}
@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/notifier"
+)
+
+// groupState aggregates the failing members of a check group.
+type groupState struct {
+	failing map[string]bool
+	firing  bool
+	since   time.Time
+}
+
+// groupTransition is a group crossing its min_failing threshold.
+type groupTransition struct {
+	policy  config.GroupPolicy
+	status  string
+	failing []string
+	since   time.Time
+}
+
+// updateGroup records whether a check is failing in its group and notifies
+// the group when it crosses min_failing. A check that moved to another group
+// (or was removed, with an empty group) leaves its previous group first.
+func (r *Runner) updateGroup(checkID, group string, failing bool) {
+	r.groupsMu.Lock()
+	var transitions []groupTransition
+	if prev := r.checkGroups[checkID]; prev != "" && prev != group {
+		transitions = r.setGroupMember(transitions, prev, checkID, false)
+	}
+	if group == "" {
+		delete(r.checkGroups, checkID)
+	} else {
+		r.checkGroups[checkID] = group
+		transitions = r.setGroupMember(transitions, group, checkID, failing)
+	}
+	r.groupsMu.Unlock()
+
+	for _, t := range transitions {
+		r.notifyGroup(t)
+	}
+}
+
+// setGroupMember updates one member and appends the resulting transition, if
+// any. Callers hold groupsMu.
+func (r *Runner) setGroupMember(out []groupTransition, groupID, checkID string, failing bool) []groupTransition {
+	policy, ok := r.groups[groupID]
+	if !ok {
+		return out
+	}
+	gs := r.groupStates[groupID]
+	if gs == nil {
+		gs = &groupState{failing: map[string]bool{}}
+		r.groupStates[groupID] = gs
+	}
+	if failing {
+		gs.failing[checkID] = true
+	} else {
+		delete(gs.failing, checkID)
+	}
+
+	breached := len(gs.failing) >= minFailing(policy)
+	var status string
+	switch {
+	case breached && !gs.firing:
+		gs.firing = true
+		gs.since = time.Now()
+		status = "firing"
+	case !breached && gs.firing:
+		gs.firing = false
+		status = "resolved"
+	default:
+		return out
+	}
+	members := make([]string, 0, len(gs.failing))
+	for id := range gs.failing {
+		members = append(members, id)
+	}
+	sort.Strings(members)
+	return append(out, groupTransition{policy: policy, status: status, failing: members, since: gs.since})
+}
+
+func minFailing(policy config.GroupPolicy) int {
+	if policy.MinFailing < 1 {
+		return 1
+	}
+	return policy.MinFailing
+}
+
+// suppressedByGroup reports whether the check's own notifications are
+// replaced by its group's.
+func (r *Runner) suppressedByGroup(check config.CheckConfig) bool {
+	policy, ok := r.groups[check.Group]
+	return ok && policy.SuppressMembers
+}
+
+func (r *Runner) notifyGroup(t groupTransition) {
+	name := t.policy.Name
+	if name == "" {
+		name = t.policy.ID
+	}
+	now := time.Now()
+	check := config.CheckConfig{
+		ID:     "group:" + t.policy.ID,
+		Name:   name,
+		Labels: map[string]string{"group": t.policy.ID},
+	}
+	result := checks.Result{
+		CheckID:     check.ID,
+		CheckName:   name,
+		Success:     t.status == "resolved",
+		CompletedAt: now,
+	}
+	ids := t.policy.ResolveNotifiers
+	summary := fmt.Sprintf("group %s recovered (%d of min %d checks failing)", name, len(t.failing), minFailing(t.policy))
+	if t.status == "firing" {
+		ids = t.policy.Notifiers
+		result.Reason = checks.ReasonGroupDegraded
+		summary = fmt.Sprintf("%d checks failing in group %s: %s", len(t.failing), name, strings.Join(t.failing, ", "))
+		r.logger.Error("check group degraded", "group", t.policy.ID, "failing", t.failing)
+	} else {
+		r.logger.Info("check group recovered", "group", t.policy.ID)
+	}
+
+	r.dispatch(ids, notifier.Event{
+		Check:    check,
+		Result:   result,
+		Status:   t.status,
+		Severity: checks.SeverityCritical,
+		Summary:  summary,
+		Details: map[string]any{
+			"group":          t.policy.ID,
+			"failing_checks": t.failing,
+			"min_failing":    minFailing(t.policy),
+		},
+		Labels:         eventLabels(check.Labels, result.Reason),
+		RunID:          fmt.Sprintf("%s-%d", check.ID, now.UnixNano()),
+		IncidentID:     incidentID(check.ID, t.since),
+		FirstFailureAt: t.since,
+		OccurredAt:     now,
+		Reason:         result.Reason,
+	})
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// OnceResult is the outcome of one check in a RunOnce pass.
+type OnceResult struct {
+	Check   config.CheckConfig
+	Result  checks.Result
+	Summary string
+}
+
+// RunOnce executes every check a single time, with its retries, and returns
+// the results in config order. Checks run concurrently, up to
+// max_concurrent_checks at a time, maintenance windows are ignored, and
+// nothing is persisted, notified or published.
+func (r *Runner) RunOnce(ctx context.Context) []OnceResult {
+	configured := r.currentChecks()
+	results := make([]OnceResult, len(configured))
+	var wg sync.WaitGroup
+	for i, check := range configured {
+		wg.Add(1)
+		go func(i int, check config.CheckConfig) {
+			defer wg.Done()
+			now := time.Now().In(r.location)
+			env := r.checkEnvironment(now, check, r.getState(check.ID), false)
+			env.ReadOnly = true
+			result, ok := r.attempt(ctx, check, env)
+			if !ok {
+				results[i] = OnceResult{Check: check, Result: checks.Result{CheckID: check.ID, CheckName: check.Name, Error: ctx.Err()}}
+				return
+			}
+			results[i] = OnceResult{Check: check, Result: result, Summary: r.summarize(check, result)}
+		}(i, check)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// relaxedKinds are the latency assertions a relax_thresholds hook loosens.
+var relaxedKinds = map[string]bool{
+	"latency_ms":       true,
+	"latency_ms_p95":   true,
+	"dns_ms":           true,
+	"connect_ms":       true,
+	"tls_handshake_ms": true,
+	"ttfb_ms":          true,
+}
+
+// relaxThresholds returns the check with the latency thresholds of its
+// less_than assertions loosened by the active relax_thresholds hooks that
+// target it, and the ids of those hooks. The latency_factor parameter
+// multiplies a threshold and latency_ms raises it to at least that many
+// milliseconds; with several hooks the loosest values apply.
+func (r *Runner) relaxThresholds(now time.Time, check config.CheckConfig) (config.CheckConfig, []string) {
+	var factor, floor float64
+	var hookIDs []string
+	for _, hook := range r.fetchActiveHooks(now) {
+		if !strings.EqualFold(strings.TrimSpace(hook.Kind), "relax_thresholds") || !hookMatchesCheck(hook, check) {
+			continue
+		}
+		hookFactor, err := relaxParam(hook.Parameters, "latency_factor")
+		if err != nil {
+			r.logger.Warn("ignoring relax_thresholds parameter", "hook_id", hook.HookID, "error", err)
+		}
+		hookFloor, err := relaxParam(hook.Parameters, "latency_ms")
+		if err != nil {
+			r.logger.Warn("ignoring relax_thresholds parameter", "hook_id", hook.HookID, "error", err)
+		}
+		if hookFactor <= 1 && hookFloor <= 0 {
+			continue
+		}
+		factor = max(factor, hookFactor)
+		floor = max(floor, hookFloor)
+		hookIDs = append(hookIDs, hook.HookID)
+	}
+	if len(hookIDs) == 0 {
+		return check, nil
+	}
+
+	assertions := make([]config.Assertion, len(check.Assertions))
+	copy(assertions, check.Assertions)
+	for i, assertion := range assertions {
+		if !relaxedKinds[strings.ToLower(assertion.Kind)] {
+			continue
+		}
+		switch strings.ToLower(assertion.Op) {
+		case "less_than", "<":
+		default:
+			continue
+		}
+		threshold, ok := relaxValue(assertion.Value)
+		if !ok {
+			continue
+		}
+		if factor > 1 {
+			threshold *= factor
+		}
+		assertions[i].Value = max(threshold, floor)
+	}
+	check.Assertions = assertions
+	return check, hookIDs
+}
+
+// relaxParam parses a numeric hook parameter. A missing parameter is zero.
+func relaxParam(params map[string]string, key string) (float64, error) {
+	raw := strings.TrimSpace(params[key])
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%s: invalid value %q", key, raw)
+	}
+	return v, nil
+}
+
+func relaxValue(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
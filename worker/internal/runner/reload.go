@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// checkLoop is the running schedule of one check.
+type checkLoop struct {
+	check  config.CheckConfig
+	cancel context.CancelFunc
+	// done is closed when the loop goroutine has returned.
+	done chan struct{}
+}
+
+// startLoop runs a check until its loop or the runner is cancelled. Callers
+// hold loopsMu.
+func (r *Runner) startLoop(check config.CheckConfig) {
+	ctx, cancel := context.WithCancel(r.runCtx)
+	done := make(chan struct{})
+	r.loops[check.ID] = &checkLoop{check: check, cancel: cancel, done: done}
+	r.loopsWG.Add(1)
+	go func() {
+		defer r.loopsWG.Done()
+		defer close(done)
+		r.runCheckLoop(ctx, check)
+	}()
+}
+
+// ReloadChecks replaces the running checks: new checks start, removed checks
+// stop and changed checks restart with their new definition. The state of
+// unchanged checks, including failing status and escalations, is kept. A
+// stopped loop has returned, finishing any run in flight, before its check
+// is forgotten or restarted, so two loops never run the same check.
+func (r *Runner) ReloadChecks(checks []config.CheckConfig, assertionSets map[string][]config.Assertion) error {
+	reloaded := &config.Config{Checks: append([]config.CheckConfig(nil), checks...), CheckAssertionSets: assertionSets}
+	if err := applyAssertionSets(reloaded); err != nil {
+		return err
+	}
+	if err := validateBackoff(r.defaults.BackoffStrategy, reloaded.Checks); err != nil {
+		return err
+	}
+	if err := validateSchedules(reloaded.Checks); err != nil {
+		return err
+	}
+	if err := validateThresholdSeverities(reloaded.Checks); err != nil {
+		return err
+	}
+	if err := validateResolvers(reloaded.Checks); err != nil {
+		return err
+	}
+	if err := validateRequestTimeouts(reloaded.Checks); err != nil {
+		return err
+	}
+	if err := validateLatencyChange(r.defaults, r.cfg.Storage.CheckStateRetention, reloaded.Checks); err != nil {
+		return err
+	}
+
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	wanted := make(map[string]config.CheckConfig, len(reloaded.Checks))
+	for _, check := range reloaded.Checks {
+		wanted[check.ID] = check
+	}
+
+	// Loops are stopped under loopsMu but waited for without it, so a slow
+	// run does not block the escalation loop or admin requests meanwhile.
+	r.loopsMu.Lock()
+	if err := r.runningErr(); err != nil {
+		r.loopsMu.Unlock()
+		return err
+	}
+	stopped := make(map[string]*checkLoop)
+	for id, loop := range r.loops {
+		check, ok := wanted[id]
+		if ok && reflect.DeepEqual(check, loop.check) {
+			continue
+		}
+		loop.cancel()
+		stopped[id] = loop
+		delete(r.loops, id)
+	}
+	r.loopsMu.Unlock()
+
+	for id, loop := range stopped {
+		<-loop.done
+		if _, ok := wanted[id]; !ok {
+			r.forgetCheck(id)
+			r.logger.Info("check removed", "check_id", id)
+		}
+	}
+
+	r.cfgMu.Lock()
+	r.cfg.Checks = reloaded.Checks
+	r.cfgMu.Unlock()
+
+	r.loopsMu.Lock()
+	defer r.loopsMu.Unlock()
+	if err := r.runningErr(); err != nil {
+		return err
+	}
+	for _, check := range reloaded.Checks {
+		if _, running := r.loops[check.ID]; running {
+			continue
+		}
+		r.logger.Info("check loaded", "check_id", check.ID)
+		r.startLoop(check)
+	}
+	return nil
+}
+
+// runningErr reports why checks cannot be (re)started, or nil while the
+// runner is running. Callers hold loopsMu.
+func (r *Runner) runningErr() error {
+	if r.runCtx == nil {
+		return errors.New("runner not started")
+	}
+	if r.runCtx.Err() != nil {
+		return errors.New("runner stopped")
+	}
+	return nil
+}
+
+// currentChecks returns the checks of the current configuration, which
+// ReloadChecks replaces.
+func (r *Runner) currentChecks() []config.CheckConfig {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.cfg.Checks
+}
+
+// forgetCheck drops the in-memory state of a removed check.
+func (r *Runner) forgetCheck(id string) {
+	r.stateMu.Lock()
+	delete(r.state, id)
+	r.stateMu.Unlock()
+	r.statusMu.Lock()
+	delete(r.statuses, id)
+	r.statusMu.Unlock()
+	r.updateGroup(id, "", false)
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// ReplayRun is the outcome of a metrics check against one historical
+// snapshot.
+type ReplayRun struct {
+	At      time.Time
+	Result  checks.Result
+	Summary string
+	Failing bool // whether the check's thresholds held it failing after this run
+	Fired   bool // whether this run moved the check into the failing state
+}
+
+// ReplayReport is the pass/fail timeline of a replayed metrics check.
+type ReplayReport struct {
+	CheckID string
+	NodeID  string
+	Runs    []ReplayRun
+	Fires   int
+}
+
+// Replay runs a metrics check against the snapshot history of its node since
+// the given time and reports when it would have started failing. The check's
+// failure_ratio threshold applies as it does live; nothing is notified or
+// persisted.
+func (r *Runner) Replay(ctx context.Context, checkID string, since time.Time) (ReplayReport, error) {
+	var check config.CheckConfig
+	found := false
+	for _, candidate := range r.currentChecks() {
+		if candidate.ID == checkID {
+			check, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return ReplayReport{}, fmt.Errorf("check %q not found", checkID)
+	}
+	if check.Type != "metrics" {
+		return ReplayReport{}, fmt.Errorf("check %q is a %s check; only metrics checks can be replayed", checkID, check.Type)
+	}
+	store := r.currentStore()
+	if store == nil {
+		return ReplayReport{}, errors.New("storage unavailable")
+	}
+	nodeID := checks.MetricsNodeID(check)
+	snapshots, err := store.NodeMetricsHistory(ctx, nodeID, since)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	results, err := checks.ReplayMetrics(check, snapshots)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	return r.replayTimeline(check, nodeID, results), nil
+}
+
+// replayTimeline applies the failure threshold of the check to replayed
+// results in order and counts the runs that would have fired.
+func (r *Runner) replayTimeline(check config.CheckConfig, nodeID string, results []checks.Result) ReplayReport {
+	report := ReplayReport{CheckID: check.ID, NodeID: nodeID, Runs: make([]ReplayRun, 0, len(results))}
+	state := &checkState{}
+	failing := false
+	for _, result := range results {
+		state.appendHistory(!result.Success, r.windowSize(check))
+		nowFailing := r.thresholdBreached(check, state.history)
+		run := ReplayRun{At: result.StartedAt, Result: result, Summary: r.summarize(check, result), Failing: nowFailing, Fired: nowFailing && !failing}
+		if run.Fired {
+			report.Fires++
+		}
+		failing = nowFailing
+		report.Runs = append(report.Runs, run)
+	}
+	return report
+}
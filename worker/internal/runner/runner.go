@@ -4,29 +4,53 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/osbits/upupup/shared/maintenance"
+	"github.com/osbits/upupup/shared/schedule"
 	"github.com/osbits/upupup/worker/internal/checks"
 	"github.com/osbits/upupup/worker/internal/config"
 	"github.com/osbits/upupup/worker/internal/notifier"
 	"github.com/osbits/upupup/worker/internal/render"
+	"github.com/osbits/upupup/worker/internal/sink"
 	"github.com/osbits/upupup/worker/internal/storage"
-	"github.com/robfig/cron/v3"
 )
 
 // Runner coordinates periodic execution of checks and notifications.
 type Runner struct {
-	cfg       *config.Config
-	defaults  config.ServiceDefault
+	cfg      *config.Config
+	defaults config.ServiceDefault
+	renderer *render.Engine
+	policies map[string]config.NotificationPolicy
+	groups   map[string]config.GroupPolicy
+	logger   *slog.Logger
+	location *time.Location
+	// slots bounds concurrent check runs to max_concurrent_checks; nil
+	// when unlimited.
+	slots chan struct{}
+	// rng draws schedule and backoff jitter. Tests replace it with a seeded
+	// source.
+	rngMu sync.Mutex
+	rng   *rand.Rand
+
+	// storeMu guards store, which is nil while storage is unavailable and
+	// attached later by RecoverStorage.
+	storeMu sync.RWMutex
+	store   *storage.Store
+
+	// secretsMu guards secrets and notifiers, which ReloadSecrets swaps
+	// together so a run or dispatch sees one consistent snapshot.
+	secretsMu sync.RWMutex
 	secrets   map[string]string
-	renderer  *render.Engine
 	notifiers *notifier.Registry
-	policies  map[string]config.NotificationPolicy
-	logger    *slog.Logger
-	location  *time.Location
-	store     *storage.Store
 
 	stateMu sync.Mutex
 	state   map[string]*checkState
@@ -35,7 +59,38 @@ type Runner struct {
 	hookCache       []storage.HookExecution
 	hookCacheExpiry time.Time
 
-	maintenance []maintenanceWindow
+	maintenance []maintenance.Window
+
+	throttles    map[string]time.Duration
+	minSeverity  map[string]int
+	deliveriesMu sync.Mutex
+	deliveries   map[deliveryKey]time.Time
+	sinks        []sink.Sink
+
+	statusMu sync.Mutex
+	statuses map[string]CheckStatus
+
+	groupsMu    sync.Mutex
+	groupStates map[string]*groupState
+	checkGroups map[string]string
+
+	notifyWG sync.WaitGroup
+
+	// cfgMu guards cfg.Checks, which ReloadChecks replaces.
+	cfgMu sync.RWMutex
+	// reloadMu serialises ReloadChecks.
+	reloadMu sync.Mutex
+
+	loopsMu sync.Mutex
+	loopsWG sync.WaitGroup
+	runCtx  context.Context
+	loops   map[string]*checkLoop
+}
+
+type deliveryKey struct {
+	notifierID string
+	checkID    string
+	status     string
 }
 
 // New constructs a new runner.
@@ -43,55 +98,170 @@ func New(cfg *config.Config, secrets map[string]string, reg *notifier.Registry,
 	if err := applyAssertionSets(cfg); err != nil {
 		return nil, err
 	}
+	if err := validateBackoff(cfg.Service.Defaults.BackoffStrategy, cfg.Checks); err != nil {
+		return nil, err
+	}
+	if err := validateSchedules(cfg.Checks); err != nil {
+		return nil, err
+	}
+	if err := validateThresholdSeverities(cfg.Checks); err != nil {
+		return nil, err
+	}
+	if err := validateResolvers(cfg.Checks); err != nil {
+		return nil, err
+	}
+	if err := validateRequestTimeouts(cfg.Checks); err != nil {
+		return nil, err
+	}
+	if err := validateLatencyChange(cfg.Service.Defaults, cfg.Storage.CheckStateRetention, cfg.Checks); err != nil {
+		return nil, err
+	}
 	policies := make(map[string]config.NotificationPolicy, len(cfg.NotificationPolicies))
 	for _, p := range cfg.NotificationPolicies {
 		policies[p.ID] = p
 	}
-	maintenance, err := parseMaintenance(cfg.Service.Defaults.MaintenanceWindows, location, cfg.Service.Defaults.Interval.Duration)
-	if err != nil {
-		return nil, err
+	groups := make(map[string]config.GroupPolicy, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		groups[g.ID] = g
 	}
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &Runner{
+	warnDisabledNotifiers(logger, reg, cfg.NotificationPolicies, cfg.Service.Environment)
+	windows, err := maintenance.Parse(cfg.Service.Defaults.MaintenanceWindows, location, cfg.Service.Defaults.Interval.Duration)
+	if err != nil {
+		return nil, err
+	}
+	throttles := make(map[string]time.Duration)
+	minSeverities := make(map[string]int)
+	for _, n := range cfg.Notifiers {
+		if n.Throttle.Duration > 0 {
+			throttles[n.ID] = n.Throttle.Duration
+		}
+		if n.MinSeverity != "" {
+			rank := checks.SeverityRank(n.MinSeverity)
+			if rank == 0 {
+				return nil, fmt.Errorf("notifier %q: unknown min_severity %q", n.ID, n.MinSeverity)
+			}
+			minSeverities[n.ID] = rank
+		}
+	}
+	var slots chan struct{}
+	if limit := cfg.Service.Defaults.MaxConcurrentChecks; limit > 0 {
+		slots = make(chan struct{}, limit)
+	}
+	r := &Runner{
 		cfg:         cfg,
 		defaults:    cfg.Service.Defaults,
 		secrets:     secrets,
 		renderer:    renderer,
 		notifiers:   reg,
 		policies:    policies,
+		groups:      groups,
 		logger:      logger,
 		location:    location,
+		slots:       slots,
+		rng:         rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
 		store:       store,
 		state:       map[string]*checkState{},
-		maintenance: maintenance,
-	}, nil
+		maintenance: windows,
+		throttles:   throttles,
+		minSeverity: minSeverities,
+		deliveries:  map[deliveryKey]time.Time{},
+		statuses:    map[string]CheckStatus{},
+		groupStates: map[string]*groupState{},
+		checkGroups: map[string]string{},
+		loops:       map[string]*checkLoop{},
+	}
+	r.loadDeliveries()
+	return r, nil
+}
+
+// AddSink streams every recorded check run and notification to s in
+// addition to sqlite. It must be called before Start.
+func (r *Runner) AddSink(s sink.Sink) {
+	r.sinks = append(r.sinks, s)
+}
+
+func (r *Runner) publish(record sink.Record) {
+	for _, s := range r.sinks {
+		s.Publish(record)
+	}
+}
+
+// warnDisabledNotifiers logs policies that route to notifiers which were not
+// built for this environment; those stages are skipped at dispatch time.
+func warnDisabledNotifiers(logger *slog.Logger, reg *notifier.Registry, policies []config.NotificationPolicy, env string) {
+	if reg == nil {
+		return
+	}
+	for _, policy := range policies {
+		ids := append([]string(nil), policy.ResolveNotifiers...)
+		for _, stage := range policy.Stages {
+			ids = append(ids, stage.Notifiers...)
+		}
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] || !reg.Disabled(id) {
+				continue
+			}
+			seen[id] = true
+			logger.Warn("notification policy references disabled notifier", "policy_id", policy.ID, "notifier_id", id, "environment", env)
+		}
+	}
 }
 
 // Start launches check goroutines.
 func (r *Runner) Start(ctx context.Context) error {
-	var wg sync.WaitGroup
-	for _, checkCfg := range r.cfg.Checks {
-		check := checkCfg
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			r.runCheckLoop(ctx, check)
-		}()
-	}
+	r.loopsMu.Lock()
+	r.runCtx = ctx
+	for _, check := range r.currentChecks() {
+		r.startLoop(check)
+	}
+	r.loopsWG.Add(1)
+	go func() {
+		defer r.loopsWG.Done()
+		r.runEscalationLoop(ctx, r.escalationInterval())
+	}()
+	r.loopsMu.Unlock()
 	<-ctx.Done()
-	wg.Wait()
+	r.loopsWG.Wait()
+	r.waitNotifications(notifyShutdownGrace)
 	return ctx.Err()
 }
 
+// notifyShutdownGrace bounds how long Start waits for notifications that are
+// still being delivered when the runner stops.
+const notifyShutdownGrace = 10 * time.Second
+
+func (r *Runner) waitNotifications(grace time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		r.notifyWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grace):
+		r.logger.Warn("notifications still in flight at shutdown", "grace", grace)
+	}
+}
+
 func (r *Runner) runCheckLoop(ctx context.Context, check config.CheckConfig) {
-	interval := r.effectiveInterval(check)
+	if spec := cronSpec(check); spec != "" {
+		r.runCronLoop(ctx, check, spec)
+		return
+	}
+	interval, clamped := r.clampedInterval(check)
+	if clamped {
+		r.logger.Warn("check interval below min_interval, clamping", "check_id", check.ID, "min_interval", interval)
+	}
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	r.logger.Info("starting check loop", "check_id", check.ID, "interval", interval)
-	r.executeCheck(ctx, check)
+	jitter := r.effectiveJitter(check)
+	r.logger.Info("starting check loop", "check_id", check.ID, "interval", interval, "jitter", jitter)
+	r.executeJittered(ctx, check, jitter)
 
 	for {
 		select {
@@ -99,9 +269,47 @@ func (r *Runner) runCheckLoop(ctx context.Context, check config.CheckConfig) {
 			r.logger.Info("stopping check loop", "check_id", check.ID)
 			return
 		case <-ticker.C:
-			r.executeCheck(ctx, check)
+			r.executeJittered(ctx, check, jitter)
+		}
+	}
+}
+
+// executeJittered waits a random duration in [0, jitter) and then runs the
+// check. The ticker keeps its phase, so the offset does not accumulate.
+func (r *Runner) executeJittered(ctx context.Context, check config.CheckConfig, jitter time.Duration) {
+	if jitter > 0 {
+		sleepContext(ctx, r.randDuration(jitter))
+		if ctx.Err() != nil {
+			return
 		}
 	}
+	r.executeCheck(ctx, check)
+}
+
+// acquireSlot waits for a free max_concurrent_checks slot rather than
+// skipping the run. It reports false when ctx ended first.
+func (r *Runner) acquireSlot(ctx context.Context) bool {
+	if r.slots == nil {
+		return true
+	}
+	select {
+	case r.slots <- struct{}{}:
+		return true
+	default:
+	}
+	r.logger.Debug("waiting for a free check slot", "max_concurrent_checks", cap(r.slots))
+	select {
+	case r.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *Runner) releaseSlot() {
+	if r.slots != nil {
+		<-r.slots
+	}
 }
 
 func (r *Runner) executeCheck(ctx context.Context, check config.CheckConfig) {
@@ -111,45 +319,168 @@ func (r *Runner) executeCheck(ctx context.Context, check config.CheckConfig) {
 		return
 	}
 
-	retries := r.effectiveRetries(check)
-	backoff := r.effectiveBackoff(check)
+	state := r.getState(check.ID)
+	if now.Before(state.DeferredUntil) {
+		r.logger.Info("deferring check", "check_id", check.ID, "until", state.DeferredUntil, "reason", state.DeferReason)
+		return
+	}
+
+	resetHooks := r.resetBaselineHooks(now.UTC(), check)
+	relaxed, relaxedBy := r.relaxThresholds(now.UTC(), check)
+	result, ok := r.attempt(ctx, relaxed, r.checkEnvironment(now, check, state, len(resetHooks) > 0))
+	if !ok {
+		return
+	}
+	if len(relaxedBy) > 0 {
+		if result.Metadata == nil {
+			result.Metadata = map[string]any{}
+		}
+		result.Metadata["relaxed_by"] = relaxedBy
+	}
+	if errs, ok := result.Metadata["message_template_errors"].([]string); ok {
+		r.logger.Error("failed to render assertion message templates", "check_id", check.ID, "errors", errs)
+	}
 
-	env := checks.Environment{
+	// A run cut short by shutdown or a reload says nothing about the target.
+	if ctx.Err() != nil && !result.Success {
+		r.logger.Info("discarding interrupted check run", "check_id", check.ID)
+		return
+	}
+
+	if result.BaselineEstablished {
+		r.completeResetBaselineHooks(resetHooks, check)
+	}
+	r.logRun(check, result)
+	r.persistCheckState(check, result)
+	r.applyBackpressure(check, state, result)
+	r.handleResult(check, result)
+}
+
+// checkEnvironment collects what a run of the check needs from the runner.
+func (r *Runner) checkEnvironment(now time.Time, check config.CheckConfig, state *checkState, resetBaseline bool) checks.Environment {
+	return checks.Environment{
 		Defaults:       r.defaults,
-		Secrets:        r.secrets,
+		Secrets:        r.currentSecrets(),
 		TemplateEngine: r.renderer,
 		TimeLocation:   r.location,
-		Store:          r.store,
+		Store:          r.currentStore(),
+		Vars:           r.hookVars(now.UTC(), check),
+		WHOISPatterns:  r.cfg.WHOIS.Patterns,
+		BreachedSince:  state.thresholdBreaches(),
+		AwaitingData:   state.awaitingData(),
+		ResetBaseline:  resetBaseline,
+		Exec:           r.cfg.Exec,
 	}
+}
 
+// attempt executes the check, retrying failed runs with the check's backoff.
+// Each run holds a max_concurrent_checks slot, which is released during the
+// backoff so a retrying check doesn't keep others waiting. It reports false
+// when ctx was cancelled before a run started.
+func (r *Runner) attempt(ctx context.Context, check config.CheckConfig, env checks.Environment) (checks.Result, bool) {
+	retries := r.effectiveRetries(check)
 	var result checks.Result
 	for attempt := 0; attempt <= retries; attempt++ {
 		select {
 		case <-ctx.Done():
 			r.logger.Warn("context canceled", "check_id", check.ID)
-			return
+			return result, false
 		default:
 		}
+		if !r.acquireSlot(ctx) {
+			r.logger.Warn("context canceled", "check_id", check.ID)
+			return result, false
+		}
 		attemptCtx, cancel := context.WithCancel(ctx)
 		result = checks.Execute(attemptCtx, check, env)
 		cancel()
+		r.releaseSlot()
 
 		if result.Success {
 			break
 		}
+		if r.respectRetryAfter(check) && !result.RetryAfter.IsZero() {
+			break
+		}
 		if attempt < retries {
-			r.logger.Warn("check attempt failed, retrying", "check_id", check.ID, "attempt", attempt+1, "error", result.Error)
-			time.Sleep(backoff)
+			delay := r.retryDelay(check, attempt)
+			r.logger.Warn("check attempt failed, retrying", "check_id", check.ID, "attempt", attempt+1, "backoff", delay, "error", result.Error)
+			sleepContext(ctx, delay)
 		}
 	}
+	return result, true
+}
 
-	r.logRun(check, result)
-	r.persistCheckState(check, result)
-	r.handleResult(check, result)
+// applyBackpressure defers the next runs of a check when the target sent a
+// Retry-After or when its circuit breaker trips on repeated connection errors.
+func (r *Runner) applyBackpressure(check config.CheckConfig, state *checkState, result checks.Result) {
+	now := time.Now()
+	if result.Error != nil {
+		state.ConnectionFailures++
+	} else {
+		state.ConnectionFailures = 0
+	}
+
+	if r.respectRetryAfter(check) && result.RetryAfter.After(now) {
+		until := result.RetryAfter
+		if limit := now.Add(r.maxRetryAfter(check)); until.After(limit) {
+			until = limit
+		}
+		if until.After(state.DeferredUntil) {
+			state.DeferredUntil = until
+			state.DeferReason = "retry_after"
+			r.logger.Warn("target requested retry later", "check_id", check.ID, "until", until)
+		}
+	}
+
+	if check.Schedule == nil || check.Schedule.CircuitBreaker == nil {
+		return
+	}
+	breaker := check.Schedule.CircuitBreaker
+	if breaker.Failures <= 0 || breaker.Cooldown.Duration <= 0 || state.ConnectionFailures < breaker.Failures {
+		return
+	}
+	until := now.Add(breaker.Cooldown.Duration)
+	if until.After(state.DeferredUntil) {
+		state.DeferredUntil = until
+		state.DeferReason = "circuit_open"
+		r.logger.Warn("circuit breaker opened", "check_id", check.ID, "consecutive_failures", state.ConnectionFailures, "until", until)
+	}
+}
+
+func (r *Runner) respectRetryAfter(check config.CheckConfig) bool {
+	return check.Schedule != nil && check.Schedule.RespectRetryAfter
+}
+
+func (r *Runner) maxRetryAfter(check config.CheckConfig) time.Duration {
+	if check.Schedule != nil && check.Schedule.MaxRetryAfter != nil && check.Schedule.MaxRetryAfter.Set {
+		return check.Schedule.MaxRetryAfter.Duration
+	}
+	return time.Hour
 }
 
 func (r *Runner) handleResult(check config.CheckConfig, result checks.Result) {
 	state := r.getState(check.ID)
+	state.notifyMu.Lock()
+	defer state.notifyMu.Unlock()
+	if result.Maintenance {
+		// A maintenance page neither fires nor resolves: the check keeps its
+		// current state until the target answers normally again.
+		state.LastResult = result
+		state.LastUpdated = time.Now()
+		r.recordStatus(check, result, state.Failing)
+		r.logger.Info("check reported maintenance", "check_id", check.ID)
+		return
+	}
+	if result.Pending {
+		// Within first_run_grace a node without metrics is not a failure
+		// yet, and not a recovery either.
+		state.LastResult = result
+		state.LastUpdated = time.Now()
+		r.recordStatus(check, result, state.Failing)
+		r.logger.Info("check pending first metrics snapshot", "check_id", check.ID)
+		return
+	}
 	fail := !result.Success
 	state.appendHistory(fail, r.windowSize(check))
 	prevFailing := state.Failing
@@ -157,28 +488,40 @@ func (r *Runner) handleResult(check config.CheckConfig, result checks.Result) {
 
 	state.LastResult = result
 	state.LastUpdated = time.Now()
+	r.recordStatus(check, result, nowFailing)
 	if result.Error != nil {
 		state.LastError = result.Error
 	}
 
+	suppressed := r.suppressedByGroup(check)
 	if nowFailing {
+		if !result.Success {
+			state.Severity = eventSeverity(result)
+		}
 		if !prevFailing {
 			state.Failing = true
 			state.FirstFailure = time.Now()
 			state.StageState = map[int]stageNotificationState{}
 			state.InitialNotified = false
-			r.logger.Error("check entered failing state", "check_id", check.ID, "summary", summarizeResult(result))
+			state.LastNotifiedReason = ""
+			state.LastNotifiedAt = time.Time{}
+			r.logger.Error("check entered failing state", "check_id", check.ID, "reason", result.Reason, "summary", r.summarize(check, result))
+		}
+		if !suppressed {
+			r.sendInitialNotifications(check, state, result)
+			r.sendEscalations(check, state, result)
 		}
-		r.sendInitialNotifications(check, state, result)
-		r.sendEscalations(check, state, result)
 	} else {
 		if prevFailing {
 			state.Failing = false
 			r.completePauseHooks(check)
 			r.logger.Info("check recovered", "check_id", check.ID)
-			r.sendResolveNotifications(check, state, result)
+			if !suppressed {
+				r.sendResolveNotifications(check, state, result)
+			}
 		}
 	}
+	r.updateGroup(check.ID, check.Group, nowFailing)
 }
 
 func (r *Runner) sendInitialNotifications(check config.CheckConfig, state *checkState, result checks.Result) {
@@ -196,6 +539,7 @@ func (r *Runner) sendInitialNotifications(check config.CheckConfig, state *check
 	event := r.buildEvent(check, state, result, "firing")
 	r.dispatch(ids, event)
 	state.InitialNotified = true
+	state.markNotified(result.Reason, time.Now())
 }
 
 func (r *Runner) sendEscalations(check config.CheckConfig, state *checkState, result checks.Result) {
@@ -213,6 +557,7 @@ func (r *Runner) sendEscalations(check config.CheckConfig, state *checkState, re
 		return
 	}
 	event := r.buildEvent(check, state, result, "firing")
+	renotifyHeld := r.renotifyHeld(check, state, result.Reason, now)
 	for idx, stage := range policy.Stages {
 		elapsed := now.Sub(state.FirstFailure)
 		if elapsed < stage.After.Duration {
@@ -228,10 +573,15 @@ func (r *Runner) sendEscalations(check config.CheckConfig, state *checkState, re
 		switch {
 		case every > 0:
 			if stageState.LastSent.IsZero() || now.Sub(stageState.LastSent) >= every {
+				if stageState.Sent && renotifyHeld {
+					r.logger.Debug("repeat notification held by renotify interval", "check_id", check.ID, "stage_index", idx, "reason", result.Reason)
+					continue
+				}
 				r.dispatch(stage.Notifiers, event)
 				stageState.Sent = true
 				stageState.LastSent = now
 				state.StageState[idx] = stageState
+				state.markNotified(result.Reason, now)
 			}
 		default:
 			if stage.Every != nil && stageState.LastSent.IsZero() {
@@ -244,38 +594,189 @@ func (r *Runner) sendEscalations(check config.CheckConfig, state *checkState, re
 			stageState.Sent = true
 			stageState.LastSent = now
 			state.StageState[idx] = stageState
+			state.markNotified(result.Reason, now)
 		}
 	}
 }
 
+// renotifyHeld reports whether repeat notifications must wait because the
+// check already notified about the same failure reason within its
+// renotify_interval. A changed reason is always notified.
+func (r *Runner) renotifyHeld(check config.CheckConfig, state *checkState, reason string, now time.Time) bool {
+	interval := check.Notifications.RenotifyInterval.Duration
+	if interval <= 0 || state.LastNotifiedAt.IsZero() {
+		return false
+	}
+	return state.LastNotifiedReason == reason && now.Sub(state.LastNotifiedAt) < interval
+}
+
+// sendResolveNotifications notifies the check's resolve_notifiers override,
+// falling back to the resolve_notifiers of its policy.
 func (r *Runner) sendResolveNotifications(check config.CheckConfig, state *checkState, result checks.Result) {
-	policy, ok := r.policies[check.Notifications.Route]
-	if !ok {
+	var ids []string
+	if overrides := check.Notifications.Overrides; overrides != nil && len(overrides.ResolveNotifiers) > 0 {
+		ids = overrides.ResolveNotifiers
+	} else if policy, ok := r.policies[check.Notifications.Route]; ok {
+		ids = policy.ResolveNotifiers
+	}
+	if len(ids) == 0 {
 		return
 	}
 	event := r.buildEvent(check, state, result, "resolved")
-	r.dispatch(policy.ResolveNotifiers, event)
+	r.dispatch(ids, event)
 }
 
 func (r *Runner) dispatch(ids []string, event notifier.Event) {
-	for _, id := range ids {
-		not, ok := r.notifiers.Get(id)
+	registry := r.notifierRegistry()
+	limit := r.maxNotificationsPerEvent()
+	sent := 0
+	for i, id := range ids {
+		if limit > 0 && sent >= limit {
+			r.logger.Error("notification fan-out limit reached, skipping remaining notifiers", "check_id", event.Check.ID, "status", event.Status, "limit", limit, "skipped", ids[i:])
+			return
+		}
+		not, ok := registry.Get(id)
 		if !ok {
+			if registry.Disabled(id) {
+				r.logger.Debug("skipping disabled notifier", "notifier_id", id, "check_id", event.Check.ID)
+				continue
+			}
 			r.logger.Error("notifier not found", "notifier_id", id)
 			continue
 		}
-		r.recordNotification(id, event)
+		if min := r.minSeverity[id]; min > 0 && checks.SeverityRank(event.Severity) < min {
+			r.logger.Info("notification filtered by min_severity", "notifier_id", id, "check_id", event.Check.ID, "severity", event.Severity)
+			r.recordNotification(id, event, skipMinSeverity, "", nil)
+			continue
+		}
+		reserved, ok := r.allowDelivery(id, event)
+		if !ok {
+			r.logger.Info("notification throttled", "notifier_id", id, "check_id", event.Check.ID, "status", event.Status)
+			r.recordNotification(id, event, skipThrottled, "", nil)
+			continue
+		}
+		sent++
+		r.notifyWG.Add(1)
 		go func(n notifier.Notifier) {
-			if err := n.Notify(context.Background(), event); err != nil {
-				r.logger.Error("notifier error", "notifier_id", n.ID(), "error", err)
+			defer r.notifyWG.Done()
+			var deliveredBy string
+			var err error
+			if d, ok := n.(notifier.Deliverer); ok {
+				deliveredBy, err = d.Deliver(context.Background(), event)
+			} else {
+				err = n.Notify(context.Background(), event)
+			}
+			if err != nil {
+				r.logger.Error("notifier error", "notifier_id", n.ID(), "check_id", event.Check.ID, "error_class", notifier.Classify(err), "error", err)
 			}
+			r.completeDelivery(id, event, reserved, err)
+			r.recordNotification(id, event, "", deliveredBy, err)
 		}(not)
 	}
 }
 
+// maxNotificationsPerEvent returns the fan-out cap of one dispatch; zero
+// means no cap.
+func (r *Runner) maxNotificationsPerEvent() int {
+	return max(r.defaults.MaxNotificationsPerEvent, 0)
+}
+
+// allowDelivery reports whether the notifier may send the event's status for
+// the check outside its throttle window. If so, it reserves the window and
+// returns the reserved delivery time, which completeDelivery settles once the
+// notifier returns; the time is zero when the notifier is not throttled.
+func (r *Runner) allowDelivery(notifierID string, event notifier.Event) (time.Time, bool) {
+	window := r.throttles[notifierID]
+	if window <= 0 {
+		return time.Time{}, true
+	}
+	now := event.OccurredAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := deliveryKey{notifierID: notifierID, checkID: event.Check.ID, status: event.Status}
+
+	r.deliveriesMu.Lock()
+	defer r.deliveriesMu.Unlock()
+	last := r.deliveries[key]
+	if !last.IsZero() && now.Sub(last) < window {
+		return time.Time{}, false
+	}
+	r.deliveries[key] = now
+	return now, true
+}
+
+// completeDelivery persists the throttle window reserved by allowDelivery
+// when the delivery succeeded. A failed delivery releases the reservation,
+// so the next notification is not suppressed.
+func (r *Runner) completeDelivery(notifierID string, event notifier.Event, reserved time.Time, deliveryErr error) {
+	if reserved.IsZero() {
+		return
+	}
+	key := deliveryKey{notifierID: notifierID, checkID: event.Check.ID, status: event.Status}
+	if deliveryErr != nil {
+		r.deliveriesMu.Lock()
+		if r.deliveries[key].Equal(reserved) {
+			delete(r.deliveries, key)
+		}
+		r.deliveriesMu.Unlock()
+		return
+	}
+
+	store := r.currentStore()
+	if store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := store.RecordNotifierDelivery(ctx, storage.NotifierDelivery{
+		NotifierID: notifierID,
+		CheckID:    event.Check.ID,
+		Status:     event.Status,
+		LastSentAt: reserved,
+	})
+	if err != nil {
+		r.logger.Error("failed to record notifier delivery", "notifier_id", notifierID, "check_id", event.Check.ID, "error", err)
+	}
+}
+
+// loadDeliveries rehydrates throttle windows persisted by a previous process.
+func (r *Runner) loadDeliveries() {
+	store := r.currentStore()
+	if store == nil || len(r.throttles) == 0 {
+		return
+	}
+	var longest time.Duration
+	for _, window := range r.throttles {
+		if window > longest {
+			longest = window
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	deliveries, err := store.NotifierDeliveries(ctx, time.Now().Add(-longest))
+	if err != nil {
+		r.logger.Error("failed to load notifier deliveries", "error", err)
+		return
+	}
+	r.deliveriesMu.Lock()
+	defer r.deliveriesMu.Unlock()
+	for _, d := range deliveries {
+		key := deliveryKey{notifierID: d.NotifierID, checkID: d.CheckID, status: d.Status}
+		// Deliveries sent while storage was unavailable are newer than
+		// anything persisted.
+		if at, ok := r.deliveries[key]; !ok || d.LastSentAt.After(at) {
+			r.deliveries[key] = d.LastSentAt
+		}
+	}
+}
+
 func (r *Runner) buildEvent(check config.CheckConfig, state *checkState, result checks.Result, status string) notifier.Event {
-	severity := "critical"
-	summary := summarizeResult(result)
+	severity := eventSeverity(result)
+	if status == "resolved" && state.Severity != "" {
+		severity = state.Severity
+	}
+	summary := r.summarize(check, result)
 	return notifier.Event{
 		Check:          check,
 		Result:         result,
@@ -283,11 +784,54 @@ func (r *Runner) buildEvent(check config.CheckConfig, state *checkState, result
 		Severity:       severity,
 		Summary:        summary,
 		Details:        map[string]any{},
-		Labels:         check.Labels,
+		Labels:         eventLabels(check.Labels, result.Reason),
 		RunID:          fmt.Sprintf("%s-%d", check.ID, time.Now().UnixNano()),
+		IncidentID:     incidentID(check.ID, state.FirstFailure),
 		FirstFailureAt: state.FirstFailure,
 		OccurredAt:     time.Now(),
+		Reason:         result.Reason,
+		CheckURL:       checkURL(r.cfg.Service.UIBaseURL, check.ID),
+	}
+}
+
+// incidentID identifies a failure episode of a check. It is derived from
+// the time the episode started, so escalations and the resolve notification
+// of one episode share it while the next episode gets a new one.
+func incidentID(checkID string, firstFailure time.Time) string {
+	if firstFailure.IsZero() {
+		return checkID
+	}
+	return fmt.Sprintf("%s-%d", checkID, firstFailure.UnixNano())
+}
+
+// checkURL links a check in the UI at base, or returns "" without a base.
+func checkURL(base, checkID string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		return ""
+	}
+	return base + "/checks/" + url.PathEscape(checkID)
+}
+
+func eventSeverity(result checks.Result) string {
+	if result.Severity != "" {
+		return result.Severity
+	}
+	return checks.SeverityCritical
+}
+
+// eventLabels adds the failure reason to a copy of the check labels so
+// notifiers and stored notification logs can be grouped by it.
+func eventLabels(labels map[string]string, reason string) map[string]string {
+	if reason == "" {
+		return labels
+	}
+	out := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		out[key] = value
 	}
+	out["reason"] = reason
+	return out
 }
 
 func (r *Runner) thresholdBreached(check config.CheckConfig, history []bool) bool {
@@ -319,10 +863,18 @@ func (r *Runner) windowSize(check config.CheckConfig) int {
 }
 
 func (r *Runner) effectiveInterval(check config.CheckConfig) time.Duration {
+	interval, _ := r.clampedInterval(check)
+	return interval
+}
+
+// clampedInterval resolves the interval of a check and raises it to
+// min_interval, reporting whether it did.
+func (r *Runner) clampedInterval(check config.CheckConfig) (time.Duration, bool) {
+	interval := r.defaults.Interval.Duration
 	if check.Schedule != nil && check.Schedule.Interval != nil && check.Schedule.Interval.Set {
-		return check.Schedule.Interval.Duration
+		interval = check.Schedule.Interval.Duration
 	}
-	return r.defaults.Interval.Duration
+	return schedule.ClampInterval(interval, r.defaults.MinInterval.Duration)
 }
 
 func (r *Runner) effectiveRetries(check config.CheckConfig) int {
@@ -339,6 +891,233 @@ func (r *Runner) effectiveBackoff(check config.CheckConfig) time.Duration {
 	return r.defaults.Backoff.Duration
 }
 
+// Retry backoff strategies.
+const (
+	backoffFixed       = "fixed"
+	backoffExponential = "exponential"
+)
+
+func (r *Runner) effectiveBackoffStrategy(check config.CheckConfig) string {
+	strategy := r.defaults.BackoffStrategy
+	if check.Schedule != nil && check.Schedule.BackoffStrategy != "" {
+		strategy = check.Schedule.BackoffStrategy
+	}
+	if strategy == "" {
+		return backoffFixed
+	}
+	return strings.ToLower(strategy)
+}
+
+func (r *Runner) effectiveBackoffMax(check config.CheckConfig) time.Duration {
+	if check.Schedule != nil && check.Schedule.BackoffMax != nil && check.Schedule.BackoffMax.Set {
+		return check.Schedule.BackoffMax.Duration
+	}
+	return r.defaults.BackoffMax.Duration
+}
+
+func (r *Runner) effectiveBackoffJitter(check config.CheckConfig) bool {
+	if check.Schedule != nil && check.Schedule.BackoffJitter != nil {
+		return *check.Schedule.BackoffJitter
+	}
+	return r.defaults.BackoffJitter
+}
+
+// retryDelay is the wait after the failed attempt with the given zero-based
+// index: the backoff itself for the fixed strategy, or backoff * 2^attempt
+// capped at backoff_max for the exponential one, randomized to between zero
+// and that value with backoff_jitter.
+func (r *Runner) retryDelay(check config.CheckConfig, attempt int) time.Duration {
+	delay := r.effectiveBackoff(check)
+	if r.effectiveBackoffStrategy(check) == backoffExponential && delay > 0 {
+		limit := r.effectiveBackoffMax(check)
+		for i := 0; i < attempt; i++ {
+			if limit > 0 && delay >= limit {
+				break
+			}
+			if delay > math.MaxInt64/2 {
+				delay = math.MaxInt64
+				break
+			}
+			delay *= 2
+		}
+		if limit > 0 && delay > limit {
+			delay = limit
+		}
+	}
+	if delay > 0 && r.effectiveBackoffJitter(check) {
+		delay = r.randDuration(delay + 1)
+	}
+	return delay
+}
+
+// randDuration returns a random duration in [0, n).
+func (r *Runner) randDuration(n time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	r.rngMu.Lock()
+	defer r.rngMu.Unlock()
+	return time.Duration(r.rng.Int64N(int64(n)))
+}
+
+// effectiveJitter resolves the jitter of a check, limited to its interval.
+func (r *Runner) effectiveJitter(check config.CheckConfig) time.Duration {
+	jitter := r.defaults.Jitter.Duration
+	if check.Schedule != nil && check.Schedule.Jitter != nil && check.Schedule.Jitter.Set {
+		jitter = check.Schedule.Jitter.Duration
+	}
+	if interval := r.effectiveInterval(check); jitter > interval {
+		return interval
+	}
+	return jitter
+}
+
+// validateBackoff rejects unknown backoff strategies.
+func validateBackoff(defaultStrategy string, checks []config.CheckConfig) error {
+	if !knownBackoffStrategy(defaultStrategy) {
+		return fmt.Errorf("unknown backoff_strategy %q, want fixed or exponential", defaultStrategy)
+	}
+	for _, check := range checks {
+		if check.Schedule != nil && !knownBackoffStrategy(check.Schedule.BackoffStrategy) {
+			return fmt.Errorf("check %q: unknown backoff_strategy %q, want fixed or exponential", check.ID, check.Schedule.BackoffStrategy)
+		}
+	}
+	return nil
+}
+
+func knownBackoffStrategy(strategy string) bool {
+	switch strings.ToLower(strategy) {
+	case "", backoffFixed, backoffExponential:
+		return true
+	}
+	return false
+}
+
+// validateThresholdSeverities rejects metrics thresholds with an unknown
+// severity.
+func validateThresholdSeverities(checkConfigs []config.CheckConfig) error {
+	for _, check := range checkConfigs {
+		if check.Metrics == nil {
+			continue
+		}
+		for _, threshold := range check.Metrics.Thresholds {
+			if threshold.Severity != "" && checks.SeverityRank(threshold.Severity) == 0 {
+				return fmt.Errorf("check %q: threshold %q: unknown severity %q, want info, warning or critical", check.ID, threshold.Name, threshold.Severity)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRequestTimeouts rejects connect_timeout and tls_timeout on HTTP/3
+// checks, whose QUIC transport has no separate dial or handshake to bound.
+func validateRequestTimeouts(checkConfigs []config.CheckConfig) error {
+	for _, check := range checkConfigs {
+		if check.Request == nil || !checks.IsHTTP3(check) {
+			continue
+		}
+		if check.Request.ConnectTimeout.Duration > 0 || check.Request.TLSTimeout.Duration > 0 {
+			return fmt.Errorf("check %q: request connect_timeout and tls_timeout are not supported with protocol %q", check.ID, check.Protocol)
+		}
+	}
+	return nil
+}
+
+// validateLatencyChange rejects latency_change on checks whose history, at
+// their interval, spans more runs than storage.check_state_retention keeps:
+// the earlier window would never have runs and the check would always pass.
+// Cron checks are counted at their longest gap, so only a certain shortfall
+// is reported.
+func validateLatencyChange(defaults config.ServiceDefault, retention int, checkConfigs []config.CheckConfig) error {
+	if retention <= 0 {
+		retention = storage.DefaultCheckStateRetention
+	}
+	for _, check := range checkConfigs {
+		if check.LatencyChange == nil {
+			continue
+		}
+		var interval time.Duration
+		if spec := cronSpec(check); spec != "" {
+			cronInterval, err := schedule.CronInterval(spec, time.Now())
+			if err != nil {
+				continue
+			}
+			interval = cronInterval
+		} else {
+			interval = defaults.Interval.Duration
+			if check.Schedule != nil && check.Schedule.Interval != nil && check.Schedule.Interval.Set {
+				interval = check.Schedule.Interval.Duration
+			}
+			interval, _ = schedule.ClampInterval(interval, defaults.MinInterval.Duration)
+		}
+		if interval <= 0 {
+			continue
+		}
+		span := checks.LatencyChangeSpan(check.LatencyChange)
+		if runs := int(span / interval); runs > retention {
+			return fmt.Errorf("check %q: latency_change reads %s of history, about %d runs at %s, but storage.check_state_retention keeps %d", check.ID, span, runs, interval, retention)
+		}
+	}
+	return nil
+}
+
+// validateResolvers rejects dns resolver addresses that are not host:port
+// and resolvers sharing a name, which would report into the same view.
+func validateResolvers(checkConfigs []config.CheckConfig) error {
+	for _, check := range checkConfigs {
+		if check.Resolver != "" {
+			if err := validateResolverAddress(check.Resolver); err != nil {
+				return fmt.Errorf("check %q: resolver: %w", check.ID, err)
+			}
+		}
+		names := make(map[string]bool, len(check.Resolvers))
+		for i, resolver := range check.Resolvers {
+			if err := validateResolverAddress(resolver.Address); err != nil {
+				return fmt.Errorf("check %q: resolvers[%d]: %w", check.ID, i, err)
+			}
+			name := resolver.Name
+			if name == "" {
+				name = resolver.Address
+			}
+			if names[name] {
+				return fmt.Errorf("check %q: resolvers[%d]: duplicate resolver %q", check.ID, i, name)
+			}
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+func validateResolverAddress(address string) error {
+	if address == "" {
+		return fmt.Errorf("address is required")
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("address %q: want host:port", address)
+	}
+	if host == "" {
+		return fmt.Errorf("address %q: missing host", address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("address %q: invalid port %q", address, port)
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
 func (r *Runner) getState(checkID string) *checkState {
 	r.stateMu.Lock()
 	defer r.stateMu.Unlock()
@@ -373,6 +1152,34 @@ func (r *Runner) applicablePauseHooks(now time.Time, check config.CheckConfig) [
 	return result
 }
 
+// hookVars collects the parameters of active hooks targeting the check so its
+// templates can read them through `var`. Newer hooks win on conflicting keys.
+func (r *Runner) hookVars(now time.Time, check config.CheckConfig) map[string]string {
+	hooks := r.fetchActiveHooks(now)
+	if len(hooks) == 0 {
+		return nil
+	}
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].RequestedAt.Before(hooks[j].RequestedAt)
+	})
+	var vars map[string]string
+	for _, hook := range hooks {
+		if len(hook.Parameters) == 0 || !hookMatchesCheck(hook, check) {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(hook.Kind), "resume_notifications") {
+			continue
+		}
+		if vars == nil {
+			vars = make(map[string]string, len(hook.Parameters))
+		}
+		for k, v := range hook.Parameters {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
 func (r *Runner) activePauseHooks(now time.Time) []storage.HookExecution {
 	hooks := r.fetchActiveHooks(now)
 	if len(hooks) == 0 {
@@ -399,7 +1206,8 @@ func (r *Runner) activePauseHooks(now time.Time) []storage.HookExecution {
 }
 
 func (r *Runner) fetchActiveHooks(now time.Time) []storage.HookExecution {
-	if r.store == nil {
+	store := r.currentStore()
+	if store == nil {
 		return nil
 	}
 	r.hookCacheMu.Lock()
@@ -411,7 +1219,7 @@ func (r *Runner) fetchActiveHooks(now time.Time) []storage.HookExecution {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	hooks, err := r.store.ActiveHookExecutions(ctx, now)
+	hooks, err := store.ActiveHookExecutions(ctx, now)
 	if err != nil {
 		r.logger.Error("failed to load active hooks", "error", err)
 		r.hookCache = nil
@@ -431,7 +1239,7 @@ func (r *Runner) invalidateHookCache() {
 }
 
 func (r *Runner) applyResumeHooks(resumeHooks, pauseHooks []storage.HookExecution) bool {
-	if len(resumeHooks) == 0 || r.store == nil {
+	if len(resumeHooks) == 0 || r.currentStore() == nil {
 		return false
 	}
 
@@ -472,7 +1280,16 @@ func (r *Runner) applyResumeHooks(resumeHooks, pauseHooks []storage.HookExecutio
 	return changed
 }
 
+// correlationParam links a resume_notifications hook to the pause hooks it ends.
+const correlationParam = "correlation_id"
+
+// hooksOverlap reports whether resume ends pause. A resume carrying a
+// correlation_id parameter only matches pauses with the same id; otherwise
+// targets and scopes are compared.
 func hooksOverlap(resume, pause storage.HookExecution) bool {
+	if correlationID := strings.TrimSpace(resume.Parameters[correlationParam]); correlationID != "" {
+		return strings.TrimSpace(pause.Parameters[correlationParam]) == correlationID
+	}
 	if targetMatches(resume.TargetIDs, "*") || targetMatches(pause.TargetIDs, "*") {
 		return true
 	}
@@ -496,17 +1313,19 @@ func hooksOverlap(resume, pause storage.HookExecution) bool {
 }
 
 func (r *Runner) completeHookNow(id int64) error {
-	if r.store == nil {
+	store := r.currentStore()
+	if store == nil {
 		return nil
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	return r.store.CompleteHookExecution(ctx, id)
+	return store.CompleteHookExecution(ctx, id)
 }
 
 func (r *Runner) completePauseHooks(check config.CheckConfig) {
 	hooks := r.applicablePauseHooks(time.Now().UTC(), check)
-	if len(hooks) == 0 || r.store == nil {
+	store := r.currentStore()
+	if len(hooks) == 0 || store == nil {
 		return
 	}
 	var anyCompleted bool
@@ -517,7 +1336,7 @@ func (r *Runner) completePauseHooks(check config.CheckConfig) {
 		if !strings.EqualFold(strings.TrimSpace(hook.Scope), "check") {
 			continue
 		}
-		if err := r.store.CompleteHookExecution(context.Background(), hook.ID); err != nil {
+		if err := store.CompleteHookExecution(context.Background(), hook.ID); err != nil {
 			r.logger.Error("failed to complete pause hook", "hook_id", hook.HookID, "error", err)
 			continue
 		}
@@ -577,15 +1396,14 @@ func hookIDs(hooks []storage.HookExecution) []string {
 }
 
 func (r *Runner) inMaintenance(now time.Time) bool {
-	for _, mw := range r.maintenance {
-		if mw.contains(now) {
-			return true
-		}
-	}
-	return false
+	return maintenance.Active(r.maintenance, now)
 }
 
 type checkState struct {
+	// notifyMu serialises result handling and the escalation ticker, which
+	// both advance the notification state below.
+	notifyMu sync.Mutex
+
 	history         []bool
 	Failing         bool
 	FirstFailure    time.Time
@@ -594,6 +1412,27 @@ type checkState struct {
 	LastResult      checks.Result
 	LastUpdated     time.Time
 	LastError       error
+
+	ConnectionFailures int
+	DeferredUntil      time.Time
+	DeferReason        string
+
+	// LastNotifiedReason and LastNotifiedAt track the latest firing
+	// notification for renotify_interval.
+	LastNotifiedReason string
+	LastNotifiedAt     time.Time
+
+	// Severity is the severity of the latest firing event; the resolved
+	// event reuses it so min_severity filters both alike.
+	Severity string
+
+	// ThresholdBreaches records when each metrics threshold started
+	// breaching, for thresholds with a `for` duration.
+	ThresholdBreaches map[int]time.Time
+
+	// AwaitingData records, per node that has never reported, when the
+	// check first found no snapshot, for first_run_grace.
+	AwaitingData map[string]time.Time
 }
 
 type stageNotificationState struct {
@@ -601,119 +1440,69 @@ type stageNotificationState struct {
 	LastSent time.Time
 }
 
-func (s *checkState) appendHistory(failed bool, max int) {
-	s.history = append(s.history, failed)
-	if max > 0 && len(s.history) > max {
-		s.history = s.history[len(s.history)-max:]
-	}
-}
-
-type maintenanceWindow struct {
-	kind     config.MaintenanceKind
-	start    time.Time
-	end      time.Time
-	schedule cron.Schedule
-	duration time.Duration
-}
-
-func (m maintenanceWindow) contains(t time.Time) bool {
-	switch m.kind {
-	case config.MaintenanceKindRange:
-		if m.start.IsZero() || m.end.IsZero() {
-			return false
-		}
-		return (t.Equal(m.start) || t.After(m.start)) && t.Before(m.end)
-	case config.MaintenanceKindCron:
-		if m.schedule == nil {
-			return false
-		}
-		prev := m.schedule.Next(t.Add(-m.duration))
-		if prev.After(t) {
-			return false
-		}
-		return t.Sub(prev) <= m.duration
-	default:
-		return false
+func (s *checkState) thresholdBreaches() map[int]time.Time {
+	if s.ThresholdBreaches == nil {
+		s.ThresholdBreaches = map[int]time.Time{}
 	}
+	return s.ThresholdBreaches
 }
 
-func parseMaintenance(specs []config.MaintenanceSpec, loc *time.Location, defaultDuration time.Duration) ([]maintenanceWindow, error) {
-	result := make([]maintenanceWindow, 0, len(specs))
-	for _, spec := range specs {
-		switch spec.Kind {
-		case config.MaintenanceKindRange:
-			start, end, err := parseRange(spec.Expr, loc)
-			if err != nil {
-				return nil, err
-			}
-			result = append(result, maintenanceWindow{
-				kind:  config.MaintenanceKindRange,
-				start: start,
-				end:   end,
-			})
-		case config.MaintenanceKindCron:
-			schedule, err := cron.ParseStandard(spec.Expr)
-			if err != nil {
-				return nil, fmt.Errorf("parse cron %q: %w", spec.Expr, err)
-			}
-			duration := defaultDuration
-			if duration == 0 {
-				duration = time.Hour
-			}
-			result = append(result, maintenanceWindow{
-				kind:     config.MaintenanceKindCron,
-				schedule: schedule,
-				duration: duration,
-			})
-		default:
-			return nil, fmt.Errorf("unsupported maintenance kind %q", spec.Kind)
-		}
+func (s *checkState) awaitingData() map[string]time.Time {
+	if s.AwaitingData == nil {
+		s.AwaitingData = map[string]time.Time{}
 	}
-	return result, nil
+	return s.AwaitingData
 }
 
-func parseRange(expr string, loc *time.Location) (time.Time, time.Time, error) {
-	parts := splitRange(expr)
-	if len(parts) != 2 {
-		return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q", expr)
-	}
-	layout := "2006-01-02T15:04"
-	start, err := time.ParseInLocation(layout, parts[0], loc)
-	if err != nil {
-		return time.Time{}, time.Time{}, fmt.Errorf("parse range start: %w", err)
-	}
-	end, err := time.ParseInLocation(layout, parts[1], loc)
-	if err != nil {
-		return time.Time{}, time.Time{}, fmt.Errorf("parse range end: %w", err)
-	}
-	return start, end, nil
+func (s *checkState) markNotified(reason string, at time.Time) {
+	s.LastNotifiedReason = reason
+	s.LastNotifiedAt = at
 }
 
-func splitRange(expr string) []string {
-	chunks := splitN(expr, "-", 6)
-	if len(chunks) < 6 {
-		return nil
+func (s *checkState) appendHistory(failed bool, max int) {
+	s.history = append(s.history, failed)
+	if max > 0 && len(s.history) > max {
+		s.history = s.history[len(s.history)-max:]
 	}
-	start := strings.Join(chunks[:3], "-")
-	end := strings.Join(chunks[3:], "-")
-	return []string{start, end}
 }
 
-func splitN(s, sep string, n int) []string {
-	result := make([]string, 0, n)
-	for len(result) < n-1 {
-		idx := strings.Index(s, sep)
-		if idx < 0 {
-			break
+// summarize renders the failure_summary_template of the check for a failed
+// run and falls back to summarizeResult when it is unset or fails to render.
+func (r *Runner) summarize(check config.CheckConfig, result checks.Result) string {
+	tmpl := strings.TrimSpace(check.FailureSummaryTemplate)
+	if tmpl == "" || result.Success || result.Maintenance || result.Pending || r.renderer == nil {
+		return summarizeResult(result)
+	}
+	summary, err := r.renderer.RenderString(tmpl, render.TemplateContext{
+		Secrets: r.currentSecrets(),
+		Vars:    r.hookVars(time.Now().UTC(), check),
+		Data: map[string]interface{}{
+			"check": map[string]interface{}{
+				"id":     check.ID,
+				"name":   check.Name,
+				"target": check.Target,
+			},
+			"labels": check.Labels,
+			"reason": result.Reason,
+			"result": notifier.ResultTemplateData(result),
+		},
+	})
+	if err != nil || strings.TrimSpace(summary) == "" {
+		if err != nil {
+			r.logger.Error("failed to render failure summary template", "check_id", check.ID, "error", err)
 		}
-		result = append(result, s[:idx])
-		s = s[idx+len(sep):]
+		return summarizeResult(result)
 	}
-	result = append(result, s)
-	return result
+	return strings.TrimSpace(summary)
 }
 
 func summarizeResult(result checks.Result) string {
+	if result.Maintenance {
+		return "Target reported maintenance"
+	}
+	if result.Pending {
+		return "Waiting for first metrics snapshot"
+	}
 	if result.Success {
 		return "Check succeeded"
 	}
@@ -755,20 +1544,36 @@ func (r *Runner) logRun(check config.CheckConfig, result checks.Result) {
 	if result.Error != nil {
 		attrs = append(attrs, "error", result.Error.Error())
 	}
-	failures := 0
+	if result.Reason != "" {
+		attrs = append(attrs, "reason", result.Reason)
+	}
+	failures, warnings, skipped := 0, 0, 0
 	for _, assertion := range result.AssertionResults {
-		if !assertion.Passed {
+		switch {
+		case assertion.Skipped:
+			skipped++
+		case assertion.Passed:
+		case assertion.Warning:
+			warnings++
+		default:
 			failures++
 		}
 	}
 	if failures > 0 {
 		attrs = append(attrs, "failed_assertions", failures)
 	}
+	if warnings > 0 {
+		attrs = append(attrs, "warnings", warnings)
+	}
+	if skipped > 0 {
+		attrs = append(attrs, "skipped_assertions", skipped)
+	}
 	r.logger.Info("check run", attrs...)
 }
 
 func (r *Runner) persistCheckState(check config.CheckConfig, result checks.Result) {
-	if r.store == nil {
+	store := r.currentStore()
+	if store == nil && len(r.sinks) == 0 {
 		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -791,18 +1596,49 @@ func (r *Runner) persistCheckState(check config.CheckConfig, result checks.Resul
 		CheckID:    check.ID,
 		CheckName:  check.Name,
 		Success:    result.Success,
-		Summary:    summarizeResult(result),
+		Summary:    r.summarize(check, result),
 		Error:      errText,
+		Reason:     result.Reason,
 		Latency:    latency,
 		OccurredAt: occurredAt,
 	}
-	if err := r.store.RecordCheckRun(ctx, run); err != nil {
+	r.publish(sink.CheckRunRecord(run))
+	if store == nil {
+		return
+	}
+	if err := store.RecordCheckRun(ctx, run); err != nil {
 		r.logger.Error("failed to record check state", "check_id", check.ID, "error", err)
 	}
+	if check.Pool == nil {
+		return
+	}
+	targets := make([]storage.CheckTargetState, 0, len(result.Targets))
+	for _, target := range result.Targets {
+		targets = append(targets, storage.CheckTargetState{
+			CheckID:    check.ID,
+			Target:     target.Target,
+			Success:    target.Success,
+			Latency:    target.Latency,
+			Error:      target.Error,
+			OccurredAt: occurredAt,
+		})
+	}
+	if err := store.ReplaceCheckTargets(ctx, check.ID, targets); err != nil {
+		r.logger.Error("failed to record check targets", "check_id", check.ID, "error", err)
+	}
 }
 
-func (r *Runner) recordNotification(notifierID string, event notifier.Event) {
-	if r.store == nil {
+// Reasons recorded for notifications that dispatch did not send.
+const (
+	skipMinSeverity = "min_severity"
+	skipThrottled   = "throttled"
+)
+
+// recordNotification logs a delivery attempt together with the class of its
+// error, if any, or the reason the notification was skipped.
+func (r *Runner) recordNotification(notifierID string, event notifier.Event, skipped, deliveredBy string, deliveryErr error) {
+	store := r.currentStore()
+	if store == nil && len(r.sinks) == 0 {
 		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -814,18 +1650,29 @@ func (r *Runner) recordNotification(notifierID string, event notifier.Event) {
 	}
 
 	logEntry := storage.NotificationLog{
-		NotifierID: notifierID,
-		CheckID:    event.Check.ID,
-		CheckName:  event.Check.Name,
-		RunID:      event.RunID,
-		Status:     event.Status,
-		Severity:   event.Severity,
-		Summary:    event.Summary,
-		Labels:     event.Labels,
-		OccurredAt: occurredAt,
+		NotifierID:  notifierID,
+		CheckID:     event.Check.ID,
+		CheckName:   event.Check.Name,
+		RunID:       event.RunID,
+		IncidentID:  event.IncidentID,
+		Status:      event.Status,
+		Severity:    event.Severity,
+		Summary:     event.Summary,
+		Labels:      event.Labels,
+		OccurredAt:  occurredAt,
+		DeliveredBy: deliveredBy,
+		Skipped:     skipped,
+	}
+	if deliveryErr != nil {
+		logEntry.ErrorClass = notifier.Classify(deliveryErr)
+		logEntry.Error = notifier.ErrorMessage(deliveryErr)
+	}
+	r.publish(sink.NotificationRecord(logEntry))
+	if store == nil {
+		return
 	}
 
-	if err := r.store.RecordNotification(ctx, logEntry); err != nil {
+	if err := store.RecordNotification(ctx, logEntry); err != nil {
 		r.logger.Error("failed to record notification", "notifier_id", notifierID, "check_id", event.Check.ID, "error", err)
 	}
 }
@@ -0,0 +1,2131 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/notifier"
+	"github.com/osbits/upupup/worker/internal/render"
+	"github.com/osbits/upupup/worker/internal/sink"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func testConfig(checks ...config.CheckConfig) *config.Config {
+	return &config.Config{
+		Service: config.ServiceConfig{
+			Defaults: config.ServiceDefault{
+				Interval: config.Duration{Duration: time.Minute},
+				Timeout:  config.Duration{Duration: 2 * time.Second},
+			},
+		},
+		Checks: checks,
+	}
+}
+
+func newTestRunner(t *testing.T, checks ...config.CheckConfig) *Runner {
+	t.Helper()
+	return newTestRunnerWith(t, testConfig(checks...), notifier.NewRegistry(), nil)
+}
+
+func newTestRunnerWith(t *testing.T, cfg *config.Config, reg *notifier.Registry, store *storage.Store) *Runner {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r, err := New(cfg, map[string]string{}, reg, render.New(), logger, time.UTC, store)
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	return r
+}
+
+func openTestStore(t *testing.T, path string) *storage.Store {
+	t.Helper()
+	store, err := storage.Open(path, storage.Options{})
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	return store
+}
+
+type recordingNotifier struct {
+	id     string
+	events chan notifier.Event
+}
+
+func newRecordingNotifier(id string) *recordingNotifier {
+	return &recordingNotifier{id: id, events: make(chan notifier.Event, 16)}
+}
+
+func (n *recordingNotifier) ID() string {
+	return n.id
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event notifier.Event) error {
+	n.events <- event
+	return nil
+}
+
+func (n *recordingNotifier) expectEvent(t *testing.T) notifier.Event {
+	t.Helper()
+	select {
+	case event := <-n.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected notification on %s", n.id)
+		return notifier.Event{}
+	}
+}
+
+func (n *recordingNotifier) expectNoEvent(t *testing.T) {
+	t.Helper()
+	select {
+	case event := <-n.events:
+		t.Fatalf("unexpected notification on %s: %+v", n.id, event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestExecuteCheckDefersAfterRetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	retries := 2
+	check := config.CheckConfig{
+		ID:     "rate-limited",
+		Type:   "http",
+		Target: srv.URL,
+		Schedule: &config.CheckSchedule{
+			Retries:           &retries,
+			RespectRetryAfter: true,
+		},
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	r := newTestRunner(t, check)
+
+	r.executeCheck(context.Background(), check)
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("expected a single request without retries, got %d", got)
+	}
+	state := r.getState(check.ID)
+	if wait := time.Until(state.DeferredUntil); wait < 110*time.Second || wait > 120*time.Second {
+		t.Fatalf("expected next run deferred ~120s, got %s", wait)
+	}
+
+	r.executeCheck(context.Background(), check)
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("expected deferred run to be skipped, got %d requests", got)
+	}
+
+	state.DeferredUntil = time.Now().Add(-time.Second)
+	r.executeCheck(context.Background(), check)
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("expected run after deferral to hit target, got %d requests", got)
+	}
+}
+
+func TestExecuteCheckIgnoresRetryAfterByDefault(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	check := config.CheckConfig{
+		ID:         "unavailable",
+		Type:       "http",
+		Target:     srv.URL,
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	r := newTestRunner(t, check)
+
+	r.executeCheck(context.Background(), check)
+	r.executeCheck(context.Background(), check)
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("expected both runs to hit target, got %d", got)
+	}
+}
+
+func TestExecuteCheckCircuitBreakerOpensAfterConnectionFailures(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			_ = conn.Close()
+		}
+	}()
+
+	check := config.CheckConfig{
+		ID:     "flapping",
+		Type:   "http",
+		Target: "http://" + ln.Addr().String() + "/",
+		Schedule: &config.CheckSchedule{
+			CircuitBreaker: &config.CircuitBreaker{Failures: 2, Cooldown: config.Duration{Duration: time.Minute}},
+		},
+	}
+	r := newTestRunner(t, check)
+
+	for i := 0; i < 3; i++ {
+		r.executeCheck(context.Background(), check)
+	}
+	if got := accepted.Load(); got != 2 {
+		t.Fatalf("expected breaker to stop the third run, got %d connections", got)
+	}
+	state := r.getState(check.ID)
+	if state.DeferReason != "circuit_open" || time.Until(state.DeferredUntil) <= 0 {
+		t.Fatalf("expected open circuit, got reason %q until %s", state.DeferReason, state.DeferredUntil)
+	}
+}
+
+func TestExecuteCheckUsesActiveHookParameters(t *testing.T) {
+	var primaryHits, failoverHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+	}))
+	t.Cleanup(primary.Close)
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failoverHits.Add(1)
+	}))
+	t.Cleanup(failover.Close)
+
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	store := openTestStore(t, path)
+
+	check := config.CheckConfig{
+		ID:         "api",
+		Type:       "http",
+		Target:     `{{ if .vars.endpoint }}{{ var "endpoint" }}{{ else }}` + primary.URL + `{{ end }}/health`,
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	r := newTestRunner(t, check)
+	r.store = store
+
+	r.executeCheck(context.Background(), check)
+	if primaryHits.Load() != 1 || failoverHits.Load() != 0 {
+		t.Fatalf("expected primary target without hooks, got primary=%d failover=%d", primaryHits.Load(), failoverHits.Load())
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	_, err = db.Exec(`
+		INSERT INTO hook_executions (hook_id, kind, scope, target_ids_json, requested_by, requested_from_ip, parameters_json, note, requested_at, status)
+		VALUES ('failover', 'pause_notifications', 'check', '["api"]', '', '', ?, '', ?, 'active')
+	`, `{"endpoint":"`+failover.URL+`"}`, time.Now().UTC().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("insert hook: %v", err)
+	}
+	r.invalidateHookCache()
+
+	r.executeCheck(context.Background(), check)
+	if primaryHits.Load() != 1 || failoverHits.Load() != 1 {
+		t.Fatalf("expected hook parameter to switch target, got primary=%d failover=%d", primaryHits.Load(), failoverHits.Load())
+	}
+}
+
+func TestRelaxThresholdsHookRaisesLatencyUntilExpiry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	store := openTestStore(t, path)
+
+	check := config.CheckConfig{
+		ID:         "api",
+		Type:       "http",
+		Target:     srv.URL,
+		Assertions: []config.Assertion{{Kind: "latency_ms", Op: "less_than", Value: 20}},
+	}
+	r := newTestRunner(t, check)
+	r.store = store
+
+	r.executeCheck(context.Background(), check)
+	if r.getState(check.ID).LastResult.Success {
+		t.Fatalf("expected slow run to fail the 20ms threshold")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	_, err = db.Exec(`
+		INSERT INTO hook_executions (hook_id, kind, scope, target_ids_json, requested_by, requested_from_ip, parameters_json, note, requested_at, active_until, status)
+		VALUES ('degraded', 'relax_thresholds', 'check', '["api"]', '', '', ?, '', ?, ?, 'active')
+	`, `{"latency_factor":"2","latency_ms":"5000"}`, time.Now().UTC().Add(-time.Minute), time.Now().UTC().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("insert hook: %v", err)
+	}
+	r.invalidateHookCache()
+
+	relaxed, relaxedBy := r.relaxThresholds(time.Now().UTC(), check)
+	if got := relaxed.Assertions[0].Value; got != float64(5000) {
+		t.Fatalf("expected threshold raised to 5000ms, got %v", got)
+	}
+	if check.Assertions[0].Value != 20 {
+		t.Fatalf("expected configured assertion to stay unchanged, got %v", check.Assertions[0].Value)
+	}
+	if len(relaxedBy) != 1 || relaxedBy[0] != "degraded" {
+		t.Fatalf("expected relaxing hook id, got %v", relaxedBy)
+	}
+
+	r.executeCheck(context.Background(), check)
+	result := r.getState(check.ID).LastResult
+	if !result.Success {
+		t.Fatalf("expected relaxed threshold to pass, got %+v", result.AssertionResults)
+	}
+	if ids, _ := result.Metadata["relaxed_by"].([]string); len(ids) != 1 || ids[0] != "degraded" {
+		t.Fatalf("expected relaxed_by metadata, got %v", result.Metadata["relaxed_by"])
+	}
+
+	if _, err := db.Exec(`UPDATE hook_executions SET active_until = ? WHERE hook_id = 'degraded'`, time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatalf("expire hook: %v", err)
+	}
+	r.invalidateHookCache()
+
+	r.executeCheck(context.Background(), check)
+	result = r.getState(check.ID).LastResult
+	if result.Success {
+		t.Fatalf("expected expired hook to restore the 20ms threshold")
+	}
+	if _, ok := result.Metadata["relaxed_by"]; ok {
+		t.Fatalf("expected no relaxed_by metadata after expiry")
+	}
+}
+
+func TestDispatchThrottleSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	check := config.CheckConfig{ID: "api", Name: "API"}
+	cfg := testConfig(check)
+	cfg.Notifiers = []config.NotifierConfig{{ID: "pager", Type: "webhook", Throttle: config.Duration{Duration: 10 * time.Minute}}}
+
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+
+	first := newTestRunnerWith(t, cfg, reg, openTestStore(t, path))
+	first.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	pager.expectEvent(t)
+	first.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	pager.expectNoEvent(t)
+	first.notifyWG.Wait()
+
+	restarted := newTestRunnerWith(t, cfg, reg, openTestStore(t, path))
+	restarted.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	pager.expectNoEvent(t)
+
+	restarted.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "resolved", OccurredAt: time.Now()})
+	if event := pager.expectEvent(t); event.Status != "resolved" {
+		t.Fatalf("expected resolved notification, got %q", event.Status)
+	}
+
+	restarted.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now().Add(11 * time.Minute)})
+	pager.expectEvent(t)
+}
+
+// flakyNotifier fails its first delivery and records every attempt.
+type flakyNotifier struct {
+	id       string
+	attempts chan notifier.Event
+}
+
+func (n *flakyNotifier) ID() string { return n.id }
+
+func (n *flakyNotifier) Notify(_ context.Context, event notifier.Event) error {
+	n.attempts <- event
+	if len(n.attempts) == 1 {
+		return errors.New("delivery failed")
+	}
+	return nil
+}
+
+func TestDispatchThrottlesOnlyAfterSuccessfulDelivery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	check := config.CheckConfig{ID: "api", Name: "API"}
+	cfg := testConfig(check)
+	cfg.Notifiers = []config.NotifierConfig{{ID: "pager", Type: "webhook", Throttle: config.Duration{Duration: 10 * time.Minute}}}
+
+	pager := &flakyNotifier{id: "pager", attempts: make(chan notifier.Event, 4)}
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	store := openTestStore(t, path)
+	r := newTestRunnerWith(t, cfg, reg, store)
+
+	r.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	r.notifyWG.Wait()
+	deliveries, err := store.NotifierDeliveries(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("notifier deliveries: %v", err)
+	}
+	if len(deliveries) != 0 {
+		t.Fatalf("expected a failed delivery not to open the throttle window, got %+v", deliveries)
+	}
+
+	r.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	r.notifyWG.Wait()
+	r.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	r.notifyWG.Wait()
+	if n := len(pager.attempts); n != 2 {
+		t.Fatalf("expected a retry after the failure and throttling after the success, got %d attempts", n)
+	}
+}
+
+func TestExecuteCheckPersistsPoolTargets(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(healthy.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(down.Close)
+
+	healthyAddr := healthy.Listener.Addr().String()
+	downAddr := down.Listener.Addr().String()
+	check := config.CheckConfig{
+		ID:         "pool",
+		Type:       "http",
+		Target:     "http://pool.example.test/",
+		Pool:       &config.PoolConfig{Targets: []string{healthyAddr, downAddr}, NHealthy: 1},
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	store := openTestStore(t, filepath.Join(t.TempDir(), "monitor.db"))
+	r := newTestRunnerWith(t, testConfig(check), notifier.NewRegistry(), store)
+
+	r.executeCheck(context.Background(), check)
+	targets, err := store.CheckTargets(context.Background(), check.ID)
+	if err != nil {
+		t.Fatalf("check targets: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 persisted targets, got %+v", targets)
+	}
+	byTarget := map[string]storage.CheckTargetState{}
+	for _, target := range targets {
+		byTarget[target.Target] = target
+	}
+	if !byTarget[healthyAddr].Success || byTarget[downAddr].Success {
+		t.Fatalf("unexpected persisted target health: %+v", targets)
+	}
+}
+
+func TestHooksOverlapCorrelationID(t *testing.T) {
+	pause := func(scope string, targets []string, params map[string]string) storage.HookExecution {
+		return storage.HookExecution{Kind: "pause_notifications", Scope: scope, TargetIDs: targets, Parameters: params}
+	}
+	resume := func(scope string, targets []string, params map[string]string) storage.HookExecution {
+		return storage.HookExecution{Kind: "resume_notifications", Scope: scope, TargetIDs: targets, Parameters: params}
+	}
+	deploy := map[string]string{"correlation_id": "deploy-42"}
+	cases := []struct {
+		name   string
+		resume storage.HookExecution
+		pause  storage.HookExecution
+		want   bool
+	}{
+		{"same correlation id", resume("check", []string{"api"}, deploy), pause("check", []string{"db"}, deploy), true},
+		{"different correlation id", resume("check", []string{"api"}, deploy), pause("check", []string{"api"}, map[string]string{"correlation_id": "deploy-7"}), false},
+		{"global resume keeps unrelated pause", resume("global", nil, deploy), pause("check", []string{"api"}, nil), false},
+		{"fallback on targets", resume("check", []string{"api"}, nil), pause("check", []string{"api"}, deploy), true},
+		{"fallback on shared scope", resume("check", []string{"api"}, nil), pause("check", []string{"db"}, nil), true},
+		{"fallback on scope mismatch", resume("check", []string{"api"}, nil), pause("label", []string{"team"}, nil), false},
+	}
+	for _, tc := range cases {
+		if got := hooksOverlap(tc.resume, tc.pause); got != tc.want {
+			t.Errorf("%s: hooksOverlap = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestActivePauseHooksResumesByCorrelationID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	store := openTestStore(t, path)
+	r := newTestRunnerWith(t, testConfig(), notifier.NewRegistry(), store)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	requestedAt := time.Now().UTC().Add(-time.Minute)
+	for _, hook := range []struct{ id, kind, params string }{
+		{"pause-deploy-42", "pause_notifications", `{"correlation_id":"deploy-42"}`},
+		{"pause-deploy-7", "pause_notifications", `{"correlation_id":"deploy-7"}`},
+		{"resume-deploy-42", "resume_notifications", `{"correlation_id":"deploy-42"}`},
+	} {
+		_, err = db.Exec(`
+			INSERT INTO hook_executions (hook_id, kind, scope, target_ids_json, requested_by, requested_from_ip, parameters_json, note, requested_at, status)
+			VALUES (?, ?, 'check', '["api"]', '', '', ?, '', ?, 'active')
+		`, hook.id, hook.kind, hook.params, requestedAt)
+		if err != nil {
+			t.Fatalf("insert hook: %v", err)
+		}
+	}
+
+	paused := r.activePauseHooks(time.Now().UTC())
+	if len(paused) != 1 || paused[0].HookID != "pause-deploy-7" {
+		t.Fatalf("expected only the unrelated pause to remain, got %+v", paused)
+	}
+}
+
+type stubSink struct {
+	mu      sync.Mutex
+	records []sink.Record
+}
+
+func (s *stubSink) ID() string { return "stub" }
+
+func (s *stubSink) Publish(record sink.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+}
+
+func (s *stubSink) Close(context.Context) error { return nil }
+
+func TestSinksReceiveRunsAndNotifications(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	check := config.CheckConfig{
+		ID:         "api",
+		Name:       "API",
+		Type:       "http",
+		Target:     srv.URL,
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+	stub := &stubSink{}
+	r.AddSink(stub)
+
+	r.executeCheck(context.Background(), check)
+	r.dispatch([]string{"pager"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	pager.expectEvent(t)
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.records) != 2 {
+		t.Fatalf("expected run and notification records, got %+v", stub.records)
+	}
+	if run := stub.records[0].CheckRun; run == nil || run.CheckID != "api" || !run.Success {
+		t.Fatalf("unexpected check run record: %+v", stub.records[0])
+	}
+	if n := stub.records[1].Notification; n == nil || n.NotifierID != "pager" || n.Status != "firing" {
+		t.Fatalf("unexpected notification record: %+v", stub.records[1])
+	}
+}
+
+type failingNotifier struct{ id string }
+
+func (n failingNotifier) ID() string { return n.id }
+
+func (n failingNotifier) Notify(context.Context, notifier.Event) error {
+	return errors.New("delivery failed")
+}
+
+type unreachableNotifier struct{ id, url string }
+
+func (n unreachableNotifier) ID() string { return n.id }
+
+func (n unreachableNotifier) Notify(context.Context, notifier.Event) error {
+	return fmt.Errorf("send slack message: %w", &url.Error{Op: "Post", URL: n.url, Err: errors.New("connection refused")})
+}
+
+func TestRecordedDeliveryErrorOmitsWebhookURL(t *testing.T) {
+	check := config.CheckConfig{ID: "api", Name: "API"}
+	chat := unreachableNotifier{id: "chat", url: "https://hooks.slack.com/services/T000/B000/s3cr3t-token"}
+	reg := notifier.NewRegistry()
+	if err := reg.Add(chat); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+	stub := &stubSink{}
+	r.AddSink(stub)
+
+	r.dispatch([]string{"chat"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	r.notifyWG.Wait()
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.records) != 1 || stub.records[0].Notification == nil {
+		t.Fatalf("expected one notification record, got %+v", stub.records)
+	}
+	n := stub.records[0].Notification
+	if strings.Contains(n.Error, "s3cr3t-token") || strings.Contains(n.Error, "/services/") {
+		t.Fatalf("expected the webhook url to be redacted, got %q", n.Error)
+	}
+	if n.Error != `send slack message: Post "https://hooks.slack.com": connection refused` || n.ErrorClass != notifier.ErrorClassTransient {
+		t.Fatalf("unexpected recorded error %q (%s)", n.Error, n.ErrorClass)
+	}
+}
+
+func TestFallbackRecordsDeliveringNotifier(t *testing.T) {
+	check := config.CheckConfig{ID: "api", Name: "API"}
+	mail := newRecordingNotifier("mail")
+	fallback, err := notifier.NewFallbackNotifier("chat-or-mail", []notifier.Notifier{failingNotifier{id: "chat"}, mail})
+	if err != nil {
+		t.Fatalf("new fallback: %v", err)
+	}
+	reg := notifier.NewRegistry()
+	if err := reg.Add(fallback); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+	stub := &stubSink{}
+	r.AddSink(stub)
+
+	r.dispatch([]string{"chat-or-mail"}, notifier.Event{Check: check, Status: "firing", OccurredAt: time.Now()})
+	mail.expectEvent(t)
+	r.notifyWG.Wait()
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.records) != 1 {
+		t.Fatalf("expected one notification record, got %+v", stub.records)
+	}
+	n := stub.records[0].Notification
+	if n == nil || n.NotifierID != "chat-or-mail" || n.DeliveredBy != "mail" || n.Error != "" {
+		t.Fatalf("expected delivery via mail to be recorded, got %+v", n)
+	}
+}
+
+func TestFiringEventCarriesReason(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	check := config.CheckConfig{
+		ID:         "api",
+		Name:       "API",
+		Type:       "http",
+		Target:     srv.URL,
+		Labels:     map[string]string{"team": "core"},
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+
+	r.executeCheck(context.Background(), check)
+	event := pager.expectEvent(t)
+	if event.Reason != checks.ReasonStatusMismatch {
+		t.Fatalf("expected reason %q, got %q", checks.ReasonStatusMismatch, event.Reason)
+	}
+	if event.Labels["reason"] != checks.ReasonStatusMismatch || event.Labels["team"] != "core" {
+		t.Fatalf("expected reason label next to check labels, got %+v", event.Labels)
+	}
+	if _, ok := check.Labels["reason"]; ok {
+		t.Fatalf("check labels must not be mutated")
+	}
+}
+
+func TestAssertionMessageTemplateUsedInNotification(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	t.Cleanup(srv.Close)
+	check := config.CheckConfig{
+		ID:     "checkout",
+		Name:   "Checkout API",
+		Type:   "http",
+		Target: srv.URL,
+		Assertions: []config.Assertion{{
+			Kind:            "latency_ms",
+			Op:              "less_than",
+			Value:           1,
+			MessageTemplate: `{{ .check.name }} latency exceeds SLO of {{ .expected }}ms`,
+		}},
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+
+	r.executeCheck(context.Background(), check)
+	want := "Checkout API latency exceeds SLO of 1ms"
+	event := pager.expectEvent(t)
+	if event.Summary != want {
+		t.Fatalf("expected event summary %q, got %q", want, event.Summary)
+	}
+	if got := event.Result.AssertionResults[0].Message; got != want {
+		t.Fatalf("expected assertion message %q, got %q", want, got)
+	}
+}
+
+func TestFailureSummaryTemplateUsedInEventAndRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	check := config.CheckConfig{
+		ID:         "payments",
+		Name:       "Payments API",
+		Type:       "http",
+		Target:     srv.URL,
+		Assertions: []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		FailureSummaryTemplate: `{{ .check.name }} failed ({{ .reason }}):` +
+			`{{ range .result.assertions }}{{ if not .passed }} {{ .kind }} {{ .op }}{{ end }}{{ end }}`,
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+	stub := &stubSink{}
+	r.AddSink(stub)
+
+	r.executeCheck(context.Background(), check)
+	want := "Payments API failed (status_mismatch): status_code equals"
+	if event := pager.expectEvent(t); event.Summary != want {
+		t.Fatalf("expected event summary %q, got %q", want, event.Summary)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.records) == 0 || stub.records[0].CheckRun == nil {
+		t.Fatalf("expected check run record, got %+v", stub.records)
+	}
+	if got := stub.records[0].CheckRun.Summary; got != want {
+		t.Fatalf("expected stored summary %q, got %q", want, got)
+	}
+}
+
+func TestFailureSummaryTemplateFallsBack(t *testing.T) {
+	r := newTestRunner(t)
+	failure := checks.Result{AssertionResults: []checks.AssertionResult{{Kind: "status_code", Op: "equals", Message: "status 500"}}}
+
+	check := config.CheckConfig{ID: "api"}
+	if got := r.summarize(check, failure); got != "status 500" {
+		t.Fatalf("expected generated summary without template, got %q", got)
+	}
+	check.FailureSummaryTemplate = "{{ .missing"
+	if got := r.summarize(check, failure); got != "status 500" {
+		t.Fatalf("expected generated summary for broken template, got %q", got)
+	}
+	check.FailureSummaryTemplate = "{{ .check.id }} is down"
+	if got := r.summarize(check, checks.Result{Success: true}); got != "Check succeeded" {
+		t.Fatalf("expected template to apply to failures only, got %q", got)
+	}
+}
+
+func TestResolveOverrideTakesPrecedenceOverPolicy(t *testing.T) {
+	check := config.CheckConfig{
+		ID:   "api",
+		Type: "http",
+		Notifications: config.CheckNotification{
+			Route:     "ops",
+			Overrides: &config.NotificationOverride{ResolveNotifiers: []string{"chat"}},
+		},
+	}
+	cfg := testConfig(check)
+	cfg.NotificationPolicies = []config.NotificationPolicy{{
+		ID:               "ops",
+		Stages:           []config.PolicyStage{{Notifiers: []string{"voice"}}},
+		ResolveNotifiers: []string{"voice"},
+	}}
+	voice := newRecordingNotifier("voice")
+	chat := newRecordingNotifier("chat")
+	reg := notifier.NewRegistry()
+	for _, n := range []*recordingNotifier{voice, chat} {
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+
+	r.sendResolveNotifications(check, r.getState(check.ID), checks.Result{Success: true})
+	if event := chat.expectEvent(t); event.Status != "resolved" {
+		t.Fatalf("expected resolved event on chat, got %q", event.Status)
+	}
+	voice.expectNoEvent(t)
+
+	check.Notifications.Overrides = nil
+	r.sendResolveNotifications(check, r.getState(check.ID), checks.Result{Success: true})
+	voice.expectEvent(t)
+	chat.expectNoEvent(t)
+}
+
+func TestEscalationsRespectRenotifyInterval(t *testing.T) {
+	check := config.CheckConfig{
+		ID:   "api",
+		Type: "http",
+		Notifications: config.CheckNotification{
+			Route:            "ops",
+			RenotifyInterval: config.Duration{Duration: time.Hour},
+		},
+	}
+	cfg := testConfig(check)
+	cfg.NotificationPolicies = []config.NotificationPolicy{{
+		ID:     "ops",
+		Stages: []config.PolicyStage{{Every: &config.Duration{Duration: time.Millisecond}, Notifiers: []string{"pager"}}},
+	}}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+	state := r.getState(check.ID)
+	state.Failing = true
+	state.FirstFailure = time.Now()
+
+	failure := checks.Result{Reason: checks.ReasonStatusMismatch}
+	r.sendEscalations(check, state, failure)
+	pager.expectEvent(t)
+
+	time.Sleep(5 * time.Millisecond)
+	r.sendEscalations(check, state, failure)
+	pager.expectNoEvent(t)
+
+	r.sendEscalations(check, state, checks.Result{Reason: checks.ReasonTimeout})
+	if event := pager.expectEvent(t); event.Reason != checks.ReasonTimeout {
+		t.Fatalf("expected changed reason to notify immediately, got %q", event.Reason)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	state.LastNotifiedAt = time.Now().Add(-2 * time.Hour)
+	r.sendEscalations(check, state, checks.Result{Reason: checks.ReasonTimeout})
+	pager.expectEvent(t)
+}
+
+func TestEscalationsRepeatWithoutRenotifyInterval(t *testing.T) {
+	check := config.CheckConfig{ID: "api", Type: "http", Notifications: config.CheckNotification{Route: "ops"}}
+	cfg := testConfig(check)
+	cfg.NotificationPolicies = []config.NotificationPolicy{{
+		ID:     "ops",
+		Stages: []config.PolicyStage{{Every: &config.Duration{Duration: time.Millisecond}, Notifiers: []string{"pager"}}},
+	}}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+	state := r.getState(check.ID)
+	state.Failing = true
+	state.FirstFailure = time.Now()
+
+	failure := checks.Result{Reason: checks.ReasonStatusMismatch}
+	r.sendEscalations(check, state, failure)
+	pager.expectEvent(t)
+	time.Sleep(5 * time.Millisecond)
+	r.sendEscalations(check, state, failure)
+	pager.expectEvent(t)
+}
+
+func TestIncidentIDStableWithinFailureEpisode(t *testing.T) {
+	check := config.CheckConfig{ID: "api", Type: "http", Notifications: config.CheckNotification{Route: "ops"}}
+	cfg := testConfig(check)
+	cfg.NotificationPolicies = []config.NotificationPolicy{{
+		ID:               "ops",
+		Stages:           []config.PolicyStage{{Every: &config.Duration{Duration: time.Millisecond}, Notifiers: []string{"pager"}}},
+		ResolveNotifiers: []string{"pager"},
+	}}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+	state := r.getState(check.ID)
+	state.Failing = true
+	state.FirstFailure = time.Now()
+
+	failure := checks.Result{Reason: checks.ReasonStatusMismatch}
+	r.sendEscalations(check, state, failure)
+	first := pager.expectEvent(t)
+	time.Sleep(5 * time.Millisecond)
+	r.sendEscalations(check, state, failure)
+	escalated := pager.expectEvent(t)
+	r.sendResolveNotifications(check, state, checks.Result{Success: true})
+	resolved := pager.expectEvent(t)
+
+	if first.IncidentID == "" {
+		t.Fatal("expected incident id on firing event")
+	}
+	for _, event := range []notifier.Event{escalated, resolved} {
+		if event.IncidentID != first.IncidentID {
+			t.Fatalf("expected incident id %q across the episode, got %q", first.IncidentID, event.IncidentID)
+		}
+	}
+	if escalated.RunID == first.RunID {
+		t.Fatalf("expected a new run id per dispatch, got %q twice", first.RunID)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	state.FirstFailure = time.Now()
+	state.StageState = nil
+	r.sendEscalations(check, state, failure)
+	if next := pager.expectEvent(t); next.IncidentID == first.IncidentID {
+		t.Fatalf("expected a new incident id for the next episode, got %q", next.IncidentID)
+	}
+}
+
+func TestEscalationStageFiresBetweenCheckRuns(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	check := config.CheckConfig{
+		ID:            "api",
+		Type:          "http",
+		Target:        srv.URL,
+		Assertions:    []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		Notifications: config.CheckNotification{Route: "ops"},
+	}
+	cfg := testConfig(check)
+	cfg.Service.Defaults.Interval = config.Duration{Duration: 10 * time.Minute}
+	cfg.Service.EscalationInterval = config.Duration{Duration: 10 * time.Millisecond}
+	cfg.NotificationPolicies = []config.NotificationPolicy{{
+		ID: "ops",
+		Stages: []config.PolicyStage{
+			{Notifiers: []string{"oncall"}},
+			{After: config.Duration{Duration: 200 * time.Millisecond}, Notifiers: []string{"manager"}},
+		},
+	}}
+	oncall := newRecordingNotifier("oncall")
+	manager := newRecordingNotifier("manager")
+	reg := notifier.NewRegistry()
+	for _, n := range []*recordingNotifier{oncall, manager} {
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = r.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	oncall.expectEvent(t)
+	started := time.Now()
+	manager.expectNoEvent(t)
+	manager.expectEvent(t)
+	if waited := time.Since(started); waited > time.Second {
+		t.Fatalf("expected escalation shortly after its stage delay, waited %s", waited)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("expected escalation without another check run, got %d runs", got)
+	}
+	oncall.expectNoEvent(t)
+}
+
+func TestReloadChecksStartsCheckAddedToChecksDir(t *testing.T) {
+	hits := make(chan string, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case hits <- r.URL.Path:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	checksDir := filepath.Join(dir, "checks.d")
+	if err := os.MkdirAll(checksDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yml")
+	base := fmt.Sprintf(`
+service:
+  checks_dir: checks.d
+  defaults:
+    interval: 1m
+    timeout: 2s
+checks:
+  - id: existing
+    type: http
+    target: %s/existing
+`, server.URL)
+	if err := os.WriteFile(configPath, []byte(base), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := config.LoadForEnv(configPath, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	r := newTestRunnerWith(t, cfg, notifier.NewRegistry(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = r.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitForHit(t, hits, "/existing")
+
+	added := fmt.Sprintf("id: added\ntype: http\ntarget: %s/added\n", server.URL)
+	if err := os.WriteFile(filepath.Join(checksDir, "added.yml"), []byte(added), 0o600); err != nil {
+		t.Fatalf("write check file: %v", err)
+	}
+	reloaded, err := config.LoadForEnv(configPath, "")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if err := r.ReloadChecks(reloaded.Checks, reloaded.CheckAssertionSets); err != nil {
+		t.Fatalf("reload checks: %v", err)
+	}
+	waitForHit(t, hits, "/added")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var ids []string
+		for _, status := range r.CheckStatuses() {
+			ids = append(ids, status.CheckID)
+		}
+		if len(ids) == 2 && ids[0] == "added" && ids[1] == "existing" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected added and existing checks active, got %v", ids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case path := <-hits:
+		t.Fatalf("unchanged check restarted on reload: %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReloadChecksWaitsForChangedLoopToExit(t *testing.T) {
+	hits := make(chan string, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case hits <- r.URL.Path:
+		default:
+		}
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	check := config.CheckConfig{ID: "api", Type: "http", Target: server.URL + "/slow"}
+	r := newTestRunner(t, check)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = r.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitForHit(t, hits, "/slow")
+
+	r.loopsMu.Lock()
+	old := r.loops["api"]
+	r.loopsMu.Unlock()
+	changed := check
+	changed.Target = server.URL + "/fast"
+	if err := r.ReloadChecks([]config.CheckConfig{changed}, nil); err != nil {
+		t.Fatalf("reload checks: %v", err)
+	}
+	select {
+	case <-old.done:
+	default:
+		t.Fatalf("changed check restarted before its old loop exited")
+	}
+	waitForHit(t, hits, "/fast")
+}
+
+// blockingSink holds the first record it receives until release is closed.
+type blockingSink struct {
+	once    sync.Once
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSink) ID() string { return "blocking" }
+
+func (s *blockingSink) Publish(sink.Record) {
+	s.once.Do(func() {
+		close(s.entered)
+		<-s.release
+	})
+}
+
+func (s *blockingSink) Close(context.Context) error { return nil }
+
+func TestReloadChecksReleasesLoopsLockWhileWaiting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	check := config.CheckConfig{ID: "api", Type: "http", Target: server.URL}
+	r := newTestRunner(t, check)
+	blocking := &blockingSink{entered: make(chan struct{}), release: make(chan struct{})}
+	r.AddSink(blocking)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = r.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	release := sync.OnceFunc(func() { close(blocking.release) })
+	defer release()
+	select {
+	case <-blocking.entered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the check to run")
+	}
+
+	// The loop of the removed check is stuck publishing its run.
+	reloaded := make(chan error, 1)
+	go func() { reloaded <- r.ReloadChecks(nil, nil) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if r.loopsMu.TryLock() {
+			_, running := r.loops["api"]
+			r.loopsMu.Unlock()
+			if !running {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected loopsMu to be free while reload waits for the loop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-reloaded:
+		t.Fatalf("reload returned before the loop exited: %v", err)
+	default:
+	}
+
+	release()
+	if err := <-reloaded; err != nil {
+		t.Fatalf("reload checks: %v", err)
+	}
+	if checks := r.currentChecks(); len(checks) != 0 {
+		t.Fatalf("expected the reloaded checks to replace the config, got %+v", checks)
+	}
+}
+
+func waitForHit(t *testing.T, hits <-chan string, path string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case got := <-hits:
+			if got == path {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected request to %s", path)
+		}
+	}
+}
+
+func TestMetricsEventUsesWorstThresholdSeverity(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "monitor.db"))
+	err := store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+		NodeID:     "node-a",
+		Payload:    "disk_usage_root 85\nnode_load1 3\n",
+		IngestedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("upsert metrics: %v", err)
+	}
+	check := config.CheckConfig{
+		ID:   "node",
+		Name: "Node",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{NodeID: "node-a", Thresholds: []config.MetricThreshold{
+			{Name: "disk_usage_root", Op: "<", Value: 80, Severity: "warning"},
+			{Name: "node_load1", Op: "<", Value: 2, Severity: "critical"},
+		}},
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, store)
+
+	r.executeCheck(context.Background(), check)
+	if event := pager.expectEvent(t); event.Severity != checks.SeverityCritical {
+		t.Fatalf("expected critical severity, got %q", event.Severity)
+	}
+}
+
+func newGroupTestRunner(t *testing.T, group config.GroupPolicy, members ...config.CheckConfig) (*Runner, *recordingNotifier, *recordingNotifier) {
+	t.Helper()
+	groupPager := newRecordingNotifier("group-pager")
+	memberPager := newRecordingNotifier("member-pager")
+	reg := notifier.NewRegistry()
+	for _, n := range []*recordingNotifier{groupPager, memberPager} {
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+	}
+	cfg := testConfig(members...)
+	cfg.Groups = []config.GroupPolicy{group}
+	return newTestRunnerWith(t, cfg, reg, nil), groupPager, memberPager
+}
+
+func groupMember(id string) config.CheckConfig {
+	return config.CheckConfig{
+		ID:    id,
+		Name:  id,
+		Type:  "http",
+		Group: "payments",
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"member-pager"}},
+		},
+	}
+}
+
+func TestGroupNotifiesWhenMinFailingReached(t *testing.T) {
+	members := []config.CheckConfig{groupMember("api"), groupMember("checkout"), groupMember("ledger")}
+	r, groupPager, _ := newGroupTestRunner(t, config.GroupPolicy{
+		ID:               "payments",
+		Name:             "Payments",
+		MinFailing:       2,
+		Notifiers:        []string{"group-pager"},
+		ResolveNotifiers: []string{"group-pager"},
+	}, members...)
+	failed := checks.Result{Success: false}
+
+	r.handleResult(members[0], failed)
+	groupPager.expectNoEvent(t)
+
+	r.handleResult(members[1], failed)
+	event := groupPager.expectEvent(t)
+	if event.Status != "firing" || event.Reason != checks.ReasonGroupDegraded {
+		t.Fatalf("expected firing group_degraded event, got %s %q", event.Status, event.Reason)
+	}
+	if event.Check.ID != "group:payments" || event.Labels["group"] != "payments" {
+		t.Fatalf("expected group event identity, got %q %+v", event.Check.ID, event.Labels)
+	}
+	if got := event.Details["failing_checks"]; fmt.Sprint(got) != "[api checkout]" {
+		t.Fatalf("expected failing members listed, got %v", got)
+	}
+
+	r.handleResult(members[2], failed)
+	groupPager.expectNoEvent(t)
+
+	r.handleResult(members[0], checks.Result{Success: true})
+	groupPager.expectNoEvent(t)
+	r.handleResult(members[1], checks.Result{Success: true})
+	if event := groupPager.expectEvent(t); event.Status != "resolved" {
+		t.Fatalf("expected resolved group event, got %s", event.Status)
+	}
+}
+
+func TestGroupSuppressesMemberNotifications(t *testing.T) {
+	members := []config.CheckConfig{groupMember("api"), groupMember("checkout")}
+	r, groupPager, memberPager := newGroupTestRunner(t, config.GroupPolicy{
+		ID:              "payments",
+		MinFailing:      1,
+		Notifiers:       []string{"group-pager"},
+		SuppressMembers: true,
+	}, members...)
+
+	r.handleResult(members[0], checks.Result{Success: false})
+	groupPager.expectEvent(t)
+	memberPager.expectNoEvent(t)
+}
+
+func TestMetricsThresholdForDelaysFiring(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "monitor.db"))
+	upsert := func(payload string) {
+		t.Helper()
+		err := store.UpsertNodeMetrics(context.Background(), storage.NodeMetricSnapshot{
+			NodeID:     "node-a",
+			Payload:    payload,
+			IngestedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("upsert metrics: %v", err)
+		}
+	}
+	check := config.CheckConfig{
+		ID:   "node",
+		Name: "Node",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{NodeID: "node-a", Thresholds: []config.MetricThreshold{
+			{Name: "node_load1", Op: "<", Value: 2, For: config.Duration{Duration: 5 * time.Minute}},
+		}},
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, store)
+
+	upsert("node_load1 3\n")
+	r.executeCheck(context.Background(), check)
+	pager.expectNoEvent(t)
+
+	upsert("node_load1 1\n")
+	r.executeCheck(context.Background(), check)
+	if _, ok := r.getState(check.ID).ThresholdBreaches[0]; ok {
+		t.Fatalf("expected recovery to clear the breach start")
+	}
+
+	upsert("node_load1 3\n")
+	r.executeCheck(context.Background(), check)
+	pager.expectNoEvent(t)
+	r.getState(check.ID).ThresholdBreaches[0] = time.Now().Add(-6 * time.Minute)
+	r.executeCheck(context.Background(), check)
+	pager.expectEvent(t)
+}
+
+func TestShutdownDuringRunDiscardsInterruptedResult(t *testing.T) {
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	store := openTestStore(t, filepath.Join(t.TempDir(), "monitor.db"))
+	check := config.CheckConfig{ID: "slow", Name: "Slow", Type: "http", Target: srv.URL}
+	r := newTestRunnerWith(t, testConfig(check), notifier.NewRegistry(), store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Start(ctx)
+	}()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("check did not start")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("runner did not stop")
+	}
+
+	total, _, err := store.RecentOutcomeCounts(context.Background(), check.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("count outcomes: %v", err)
+	}
+	if total != 0 {
+		t.Fatalf("expected interrupted run not to be recorded, got %d", total)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close store: %v", err)
+	}
+	if err := r.ReloadChecks([]config.CheckConfig{check}, nil); err == nil {
+		t.Fatalf("expected reload after shutdown to fail")
+	}
+}
+
+func TestBuildEventCheckURL(t *testing.T) {
+	check := config.CheckConfig{ID: "api/v1", Name: "API"}
+	cfg := testConfig(check)
+	cfg.Service.UIBaseURL = "https://status.example.com/"
+	r := newTestRunnerWith(t, cfg, notifier.NewRegistry(), nil)
+
+	event := r.buildEvent(check, r.getState(check.ID), checks.Result{}, "firing")
+	if want := "https://status.example.com/checks/api%2Fv1"; event.CheckURL != want {
+		t.Fatalf("expected %s, got %s", want, event.CheckURL)
+	}
+
+	r = newTestRunnerWith(t, testConfig(check), notifier.NewRegistry(), nil)
+	if event := r.buildEvent(check, r.getState(check.ID), checks.Result{}, "firing"); event.CheckURL != "" {
+		t.Fatalf("expected no check URL without ui_base_url, got %s", event.CheckURL)
+	}
+}
+
+func TestDispatchFiltersBelowMinSeverity(t *testing.T) {
+	check := config.CheckConfig{
+		ID:   "node",
+		Name: "Node",
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager", "chat"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	chat := newRecordingNotifier("chat")
+	reg := notifier.NewRegistry()
+	for _, n := range []*recordingNotifier{pager, chat} {
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+	}
+	cfg := testConfig(check)
+	cfg.Notifiers = []config.NotifierConfig{{ID: "pager", MinSeverity: "critical"}, {ID: "chat"}}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+
+	r.handleResult(check, checks.Result{Success: false, Severity: checks.SeverityWarning})
+	if event := chat.expectEvent(t); event.Severity != checks.SeverityWarning {
+		t.Fatalf("expected warning event, got %q", event.Severity)
+	}
+	pager.expectNoEvent(t)
+
+	r.handleResult(check, checks.Result{Success: true})
+	r.handleResult(check, checks.Result{Success: false, Severity: checks.SeverityCritical})
+	if event := pager.expectEvent(t); event.Severity != checks.SeverityCritical {
+		t.Fatalf("expected critical event, got %q", event.Severity)
+	}
+}
+
+func TestDispatchRecordsSkippedNotifications(t *testing.T) {
+	check := config.CheckConfig{ID: "node", Name: "Node"}
+	pager := newRecordingNotifier("pager")
+	chat := newRecordingNotifier("chat")
+	reg := notifier.NewRegistry()
+	for _, n := range []*recordingNotifier{pager, chat} {
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+	}
+	cfg := testConfig(check)
+	cfg.Notifiers = []config.NotifierConfig{
+		{ID: "pager", MinSeverity: "critical"},
+		{ID: "chat", Throttle: config.Duration{Duration: time.Hour}},
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+	stub := &stubSink{}
+	r.AddSink(stub)
+
+	event := notifier.Event{Check: check, Status: "firing", Severity: checks.SeverityWarning, OccurredAt: time.Now()}
+	r.dispatch([]string{"pager", "chat"}, event)
+	chat.expectEvent(t)
+	r.notifyWG.Wait()
+	r.dispatch([]string{"chat"}, event)
+	chat.expectNoEvent(t)
+	pager.expectNoEvent(t)
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	skipped := map[string]string{}
+	for _, record := range stub.records {
+		if n := record.Notification; n != nil && n.Skipped != "" {
+			skipped[n.NotifierID] = n.Skipped
+		}
+	}
+	if len(stub.records) != 3 || skipped["pager"] != "min_severity" || skipped["chat"] != "throttled" {
+		t.Fatalf("expected the filtered and throttled notifications to be recorded, got %+v", stub.records)
+	}
+}
+
+func TestDispatchCapsFanOutPerEvent(t *testing.T) {
+	check := config.CheckConfig{ID: "api", Name: "API"}
+	cfg := testConfig(check)
+	cfg.Service.Defaults.MaxNotificationsPerEvent = 3
+	reg := notifier.NewRegistry()
+	var recorders []*recordingNotifier
+	var ids []string
+	for i := range 6 {
+		n := newRecordingNotifier(fmt.Sprintf("hook-%d", i))
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+		recorders = append(recorders, n)
+		ids = append(ids, n.ID())
+	}
+	cfg.Notifiers = []config.NotifierConfig{{ID: "hook-0", MinSeverity: "critical"}}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+
+	r.dispatch(ids, notifier.Event{Check: check, Status: "firing", Severity: checks.SeverityWarning})
+	// hook-0 is filtered by min_severity and does not count against the cap.
+	recorders[0].expectNoEvent(t)
+	for _, n := range recorders[1:4] {
+		n.expectEvent(t)
+	}
+	for _, n := range recorders[4:] {
+		n.expectNoEvent(t)
+	}
+}
+
+func TestDispatchIsUncappedByDefault(t *testing.T) {
+	check := config.CheckConfig{ID: "api", Name: "API"}
+	reg := notifier.NewRegistry()
+	var recorders []*recordingNotifier
+	var ids []string
+	for i := range 30 {
+		n := newRecordingNotifier(fmt.Sprintf("hook-%d", i))
+		if err := reg.Add(n); err != nil {
+			t.Fatalf("add notifier: %v", err)
+		}
+		recorders = append(recorders, n)
+		ids = append(ids, n.ID())
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+
+	r.dispatch(ids, notifier.Event{Check: check, Status: "firing"})
+	for _, n := range recorders {
+		n.expectEvent(t)
+	}
+}
+
+func TestNewRejectsUnknownMinSeverity(t *testing.T) {
+	cfg := testConfig()
+	cfg.Notifiers = []config.NotifierConfig{{ID: "pager", MinSeverity: "urgent"}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := New(cfg, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil); err == nil {
+		t.Fatalf("expected unknown min_severity to be rejected")
+	}
+}
+
+func TestNewRejectsUnknownThresholdSeverity(t *testing.T) {
+	check := config.CheckConfig{
+		ID:   "node",
+		Type: "metrics",
+		Metrics: &config.MetricsCheck{
+			Thresholds: []config.MetricThreshold{{Name: "node_load1", Op: "less_than", Value: 4, Severity: "urgent"}},
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := New(testConfig(check), nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown severity "urgent"`) {
+		t.Fatalf("expected unknown threshold severity to be rejected, got %v", err)
+	}
+}
+
+func TestNewRejectsInvalidResolvers(t *testing.T) {
+	cases := map[string]struct {
+		resolvers []config.DNSResolver
+		want      string
+	}{
+		"missing port": {
+			resolvers: []config.DNSResolver{{Name: "internal", Address: "10.0.0.2"}},
+			want:      `resolvers[0]: address "10.0.0.2": want host:port`,
+		},
+		"bad port": {
+			resolvers: []config.DNSResolver{{Name: "internal", Address: "10.0.0.2:dns"}},
+			want:      `resolvers[0]: address "10.0.0.2:dns": invalid port "dns"`,
+		},
+		"missing address": {
+			resolvers: []config.DNSResolver{{Name: "internal", Address: "10.0.0.2:53"}, {Name: "external"}},
+			want:      "resolvers[1]: address is required",
+		},
+		"duplicate name": {
+			resolvers: []config.DNSResolver{{Name: "view", Address: "10.0.0.2:53"}, {Name: "view", Address: "1.1.1.1:53"}},
+			want:      `resolvers[1]: duplicate resolver "view"`,
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for name, tc := range cases {
+		check := config.CheckConfig{ID: "split", Type: "dns", Target: "app.example.com", Resolvers: tc.resolvers}
+		_, err := New(testConfig(check), nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestNewRejectsPhaseTimeoutsOnHTTP3(t *testing.T) {
+	check := config.CheckConfig{
+		ID:       "quic",
+		Type:     "http",
+		Target:   "https://example.com",
+		Protocol: "http3",
+		Request:  &config.HTTPRequest{TLSTimeout: config.Duration{Duration: 2 * time.Second}},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := New(testConfig(check), nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil)
+	if err == nil || !strings.Contains(err.Error(), "not supported with protocol") {
+		t.Fatalf("expected tls_timeout to be rejected for http3, got %v", err)
+	}
+}
+
+func TestNewRejectsLatencyChangeBeyondRetention(t *testing.T) {
+	check := config.CheckConfig{
+		ID:            "api",
+		Type:          "http",
+		Target:        "https://example.com",
+		LatencyChange: &config.LatencyChange{MaxIncreasePercent: 100},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := testConfig(check)
+	cfg.Service.Defaults.Interval = config.Duration{Duration: time.Minute}
+	_, err := New(cfg, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil)
+	if err == nil || !strings.Contains(err.Error(), "storage.check_state_retention keeps 30") {
+		t.Fatalf("expected latency_change beyond the default retention to be rejected, got %v", err)
+	}
+
+	cfg = testConfig(check)
+	cfg.Service.Defaults.Interval = config.Duration{Duration: time.Minute}
+	cfg.Storage.CheckStateRetention = 10200
+	if _, err := New(cfg, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil); err != nil {
+		t.Fatalf("expected a retention covering offset and window to be accepted, got %v", err)
+	}
+}
+
+func TestMaintenanceResponseDoesNotFire(t *testing.T) {
+	var marker atomic.Bool
+	marker.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if marker.Load() {
+			_, _ = w.Write([]byte("<h1>Scheduled maintenance</h1>"))
+			return
+		}
+		_, _ = w.Write([]byte("upstream connect error"))
+	}))
+	t.Cleanup(srv.Close)
+
+	check := config.CheckConfig{
+		ID:                        "web",
+		Type:                      "http",
+		Target:                    srv.URL,
+		Assertions:                []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		ExpectedMaintenanceStatus: &config.MaintenanceStatus{Status: 503, BodyContains: "Scheduled maintenance"},
+		Notifications: config.CheckNotification{
+			Overrides: &config.NotificationOverride{InitialNotifiers: []string{"pager"}},
+		},
+	}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, testConfig(check), reg, nil)
+
+	r.executeCheck(context.Background(), check)
+	pager.expectNoEvent(t)
+	if r.getState(check.ID).Failing {
+		t.Fatalf("expected maintenance response not to mark the check failing")
+	}
+	statuses := r.CheckStatuses()
+	if len(statuses) != 1 || !statuses[0].Maintenance {
+		t.Fatalf("expected maintenance status, got %+v", statuses)
+	}
+
+	marker.Store(false)
+	r.executeCheck(context.Background(), check)
+	pager.expectEvent(t)
+	if statuses := r.CheckStatuses(); statuses[0].Maintenance {
+		t.Fatalf("expected 503 without marker to clear maintenance, got %+v", statuses[0])
+	}
+}
+
+func TestReloadSecretsRebuildsNotifiersWithRotatedSecret(t *testing.T) {
+	tokens := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.Header.Get("Authorization")
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("UPUPUP_TEST_HOOK_TOKEN", "old-token")
+	cfg := testConfig()
+	cfg.Secrets = map[string]config.SecretSpec{"HOOK_TOKEN": {Source: "env", Value: "UPUPUP_TEST_HOOK_TOKEN"}}
+	cfg.Notifiers = []config.NotifierConfig{{
+		ID:   "hook",
+		Type: "webhook",
+		Config: map[string]interface{}{
+			"url":      srv.URL,
+			"template": `{"status": "{{ .status }}"}`,
+			"headers":  map[string]interface{}{"Authorization": `Bearer {{ secret "HOOK_TOKEN" }}`},
+		},
+	}}
+	build := func() (map[string]string, *notifier.Registry) {
+		t.Helper()
+		secrets, err := cfg.ResolveSecrets()
+		if err != nil {
+			t.Fatalf("resolve secrets: %v", err)
+		}
+		reg, err := notifier.Build(notifier.Factory{Secrets: secrets, Render: render.New()}, cfg.Notifiers)
+		if err != nil {
+			t.Fatalf("build notifiers: %v", err)
+		}
+		return secrets, reg
+	}
+	secrets, reg := build()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r, err := New(cfg, secrets, reg, render.New(), logger, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	event := notifier.Event{Check: config.CheckConfig{ID: "api"}, Status: "firing"}
+
+	r.dispatch([]string{"hook"}, event)
+	if got := <-tokens; got != "Bearer old-token" {
+		t.Fatalf("expected old token before reload, got %q", got)
+	}
+
+	t.Setenv("UPUPUP_TEST_HOOK_TOKEN", "new-token")
+	if err := r.ReloadSecrets(build()); err != nil {
+		t.Fatalf("reload secrets: %v", err)
+	}
+	if got := r.currentSecrets()["HOOK_TOKEN"]; got != "new-token" {
+		t.Fatalf("expected checks to see the rotated secret, got %q", got)
+	}
+	r.dispatch([]string{"hook"}, event)
+	if got := <-tokens; got != "Bearer new-token" {
+		t.Fatalf("expected rotated token after reload, got %q", got)
+	}
+}
+
+func TestReplayCountsFiresOverSnapshotHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	store := openTestStore(t, path)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	start := time.Now().UTC().Add(-time.Hour)
+	seed := func(node string, loads ...int) {
+		for i, load := range loads {
+			_, err := db.Exec(`INSERT INTO node_metrics_history (node_id, payload, ingested_at) VALUES (?, ?, ?)`,
+				node, fmt.Sprintf("node_load1 %d\n", load), start.Add(time.Duration(i)*time.Minute))
+			if err != nil {
+				t.Fatalf("seed history: %v", err)
+			}
+		}
+	}
+	// The first snapshot of node-a predates the replay window.
+	seed("node-a", 3, 1, 3, 3, 1, 3, 1)
+	seed("node-b", 3, 3, 3, 3, 1, 3)
+
+	load := config.MetricThreshold{Name: "node_load1", Op: "<", Value: 2}
+	held := load
+	held.For = config.Duration{Duration: 3 * time.Minute}
+	r := newTestRunnerWith(t, testConfig(
+		config.CheckConfig{ID: "load", Type: "metrics", Metrics: &config.MetricsCheck{NodeID: "node-a", Thresholds: []config.MetricThreshold{load}}},
+		config.CheckConfig{ID: "held", Type: "metrics", Target: "node-b", Metrics: &config.MetricsCheck{Thresholds: []config.MetricThreshold{held}}},
+	), notifier.NewRegistry(), store)
+
+	report, err := r.Replay(context.Background(), "load", start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(report.Runs) != 6 || report.Fires != 2 {
+		t.Fatalf("expected 2 fires over 6 runs, got %d over %d", report.Fires, len(report.Runs))
+	}
+	if !report.Runs[1].Fired || report.Runs[2].Fired || !report.Runs[2].Failing || !report.Runs[4].Fired {
+		t.Fatalf("unexpected timeline %+v", report.Runs)
+	}
+
+	// A breach fires once it has lasted `for`, measured in snapshot time.
+	report, err = r.Replay(context.Background(), "held", start)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if report.NodeID != "node-b" || report.Fires != 1 || !report.Runs[3].Fired {
+		t.Fatalf("expected a single fire at the fourth snapshot, got %+v", report)
+	}
+	if report.Runs[5].Failing {
+		t.Fatalf("expected a new breach to be held as a warning, got %+v", report.Runs[5])
+	}
+
+	if _, err := r.Replay(context.Background(), "missing", start); err == nil {
+		t.Fatalf("expected unknown check to fail")
+	}
+}
+
+func TestRetryDelayExponentialCappedAndJittered(t *testing.T) {
+	jitter := true
+	check := config.CheckConfig{ID: "api", Schedule: &config.CheckSchedule{
+		BackoffStrategy: "exponential",
+		BackoffMax:      &config.NullableDuration{Duration: 5 * time.Second, Set: true},
+	}}
+	cfg := testConfig(check)
+	cfg.Service.Defaults.Backoff = config.Duration{Duration: time.Second}
+	r := newTestRunnerWith(t, cfg, notifier.NewRegistry(), nil)
+
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := r.retryDelay(check, attempt); got != want {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+	if got := r.retryDelay(config.CheckConfig{ID: "fixed"}, 3); got != time.Second {
+		t.Fatalf("expected fixed backoff by default, got %s", got)
+	}
+
+	check.Schedule.BackoffJitter = &jitter
+	for i := 0; i < 50; i++ {
+		if got := r.retryDelay(check, 2); got < 0 || got > 4*time.Second {
+			t.Fatalf("expected jittered delay within [0, 4s], got %s", got)
+		}
+	}
+
+	cfg.Checks[0].Schedule.BackoffStrategy = "linear"
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := New(cfg, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil); err == nil {
+		t.Fatalf("expected unknown backoff_strategy to be rejected")
+	}
+}
+
+func TestExecuteCheckStopsBackoffOnCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closed := ln.Addr().String()
+	_ = ln.Close()
+
+	retries := 3
+	check := config.CheckConfig{
+		ID:     "down",
+		Type:   "tcp",
+		Target: closed,
+		Schedule: &config.CheckSchedule{
+			Retries: &retries,
+			Backoff: &config.NullableDuration{Duration: 30 * time.Second, Set: true},
+		},
+	}
+	store := openTestStore(t, filepath.Join(t.TempDir(), "monitor.db"))
+	r := newTestRunnerWith(t, testConfig(check), notifier.NewRegistry(), store)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		r.executeCheck(ctx, check)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("executeCheck kept sleeping through its backoff after cancel")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected executeCheck to return promptly, took %s", elapsed)
+	}
+	total, _, err := store.RecentOutcomeCounts(context.Background(), check.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("count outcomes: %v", err)
+	}
+	if total != 0 {
+		t.Fatalf("expected the interrupted run not to be recorded, got %d", total)
+	}
+}
+
+func TestMaxConcurrentChecksLimitsOverlap(t *testing.T) {
+	var running, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}))
+	t.Cleanup(srv.Close)
+
+	var checkConfigs []config.CheckConfig
+	for i := 0; i < 6; i++ {
+		checkConfigs = append(checkConfigs, config.CheckConfig{ID: fmt.Sprintf("api-%d", i), Type: "http", Target: srv.URL})
+	}
+	cfg := testConfig(checkConfigs...)
+	cfg.Service.Defaults.MaxConcurrentChecks = 2
+	r := newTestRunnerWith(t, cfg, notifier.NewRegistry(), nil)
+
+	var wg sync.WaitGroup
+	for _, check := range checkConfigs {
+		wg.Add(1)
+		go func(check config.CheckConfig) {
+			defer wg.Done()
+			r.executeCheck(context.Background(), check)
+		}(check)
+	}
+	wg.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("expected at most 2 overlapping runs and all checks to wait for a slot, peak was %d", got)
+	}
+	statuses := r.CheckStatuses()
+	if len(statuses) != len(checkConfigs) {
+		t.Fatalf("expected every check to run, got %+v", statuses)
+	}
+	for _, status := range statuses {
+		if !status.Success {
+			t.Fatalf("expected %s to succeed, got %+v", status.CheckID, status)
+		}
+	}
+}
+
+func TestMaxConcurrentChecksReleasesSlotDuringBackoff(t *testing.T) {
+	hits := make(chan string, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits <- r.URL.Path
+		if r.URL.Path == "/flaky" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	status200 := []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}}
+	flaky := config.CheckConfig{ID: "flaky", Type: "http", Target: srv.URL + "/flaky", Assertions: status200}
+	healthy := config.CheckConfig{ID: "healthy", Type: "http", Target: srv.URL + "/healthy", Assertions: status200}
+	cfg := testConfig(flaky, healthy)
+	cfg.Service.Defaults.MaxConcurrentChecks = 1
+	cfg.Service.Defaults.Retries = 1
+	cfg.Service.Defaults.Backoff = config.Duration{Duration: 500 * time.Millisecond}
+	r := newTestRunnerWith(t, cfg, notifier.NewRegistry(), nil)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.executeCheck(context.Background(), flaky)
+	}()
+	waitForHit(t, hits, "/flaky")
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.executeCheck(context.Background(), healthy)
+	}()
+	wg.Wait()
+	close(hits)
+
+	var order []string
+	for path := range hits {
+		order = append(order, path)
+	}
+	if len(order) != 2 || order[0] != "/healthy" || order[1] != "/flaky" {
+		t.Fatalf("expected the healthy check to run during the retry backoff, got %v", order)
+	}
+}
+
+func TestScheduleJitterDelaysRunsBySeededOffset(t *testing.T) {
+	ran := make(chan time.Time, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ran <- time.Now()
+	}))
+	t.Cleanup(srv.Close)
+
+	check := config.CheckConfig{ID: "api", Type: "http", Target: srv.URL, Schedule: &config.CheckSchedule{
+		Jitter: &config.NullableDuration{Duration: 200 * time.Millisecond, Set: true},
+	}}
+	cfg := testConfig(check)
+	cfg.Service.Defaults.Jitter = config.Duration{Duration: 2 * time.Hour}
+	r := newTestRunnerWith(t, cfg, notifier.NewRegistry(), nil)
+
+	if got := r.effectiveJitter(check); got != 200*time.Millisecond {
+		t.Fatalf("expected the check jitter to override the default, got %s", got)
+	}
+	if got := r.effectiveJitter(config.CheckConfig{ID: "plain"}); got != time.Minute {
+		t.Fatalf("expected jitter limited to the interval, got %s", got)
+	}
+
+	r.rng = rand.New(rand.NewPCG(1, 2))
+	seeded := rand.New(rand.NewPCG(1, 2))
+	want := time.Duration(seeded.Int64N(int64(200 * time.Millisecond)))
+	start := time.Now()
+	r.executeJittered(context.Background(), check, r.effectiveJitter(check))
+	select {
+	case at := <-ran:
+		if delay := at.Sub(start); delay < want {
+			t.Fatalf("expected the run delayed by the seeded %s, ran after %s", want, delay)
+		}
+	default:
+		t.Fatalf("expected the check to run")
+	}
+	next := time.Duration(seeded.Int64N(int64(200 * time.Millisecond)))
+	if got := r.randDuration(200 * time.Millisecond); got != next {
+		t.Fatalf("expected the seeded sequence to continue with %s, got %s", next, got)
+	}
+}
+
+func TestCronScheduleValidatedAndWaitsForActivation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	both := testConfig(config.CheckConfig{ID: "nightly", Schedule: &config.CheckSchedule{
+		Cron:     "0 3 * * *",
+		Interval: &config.NullableDuration{Duration: time.Hour, Set: true},
+	}})
+	if _, err := New(both, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil); err == nil {
+		t.Fatalf("expected interval and cron together to be rejected")
+	}
+	invalid := testConfig(config.CheckConfig{ID: "nightly", Schedule: &config.CheckSchedule{Cron: "every night"}})
+	if _, err := New(invalid, nil, notifier.NewRegistry(), render.New(), logger, time.UTC, nil); err == nil {
+		t.Fatalf("expected invalid cron to be rejected")
+	}
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+	check := config.CheckConfig{
+		ID:       "nightly",
+		Type:     "http",
+		Target:   srv.URL,
+		Schedule: &config.CheckSchedule{Cron: "0 3 * * *"},
+	}
+	r := newTestRunner(t, check)
+
+	effective, err := EffectiveConfig(testConfig(check))
+	if err != nil {
+		t.Fatalf("effective config: %v", err)
+	}
+	if schedule := effective.Checks[0].Schedule; schedule.Interval != nil || schedule.Cron != "0 3 * * *" {
+		t.Fatalf("expected cron schedule without interval, got %+v", schedule)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		r.runCheckLoop(ctx, check)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected cron loop to stop on cancel")
+	}
+	if got := hits.Load(); got != 0 {
+		t.Fatalf("expected no run before the first cron activation, got %d", got)
+	}
+}
+
+func TestSelectedChecksOnlyStartLoops(t *testing.T) {
+	checks := []config.CheckConfig{
+		{ID: "api", Labels: map[string]string{"team": "core"}},
+		{ID: "cdn", Labels: map[string]string{"team": "edge"}},
+		{ID: "db", Labels: map[string]string{"team": "core", "tier": "data"}},
+	}
+	if _, err := SelectChecks(checks, CheckSelector{IDs: []string{"apii"}}); err == nil {
+		t.Fatalf("expected unknown check id to be rejected")
+	}
+	if _, err := SelectChecks(checks, CheckSelector{Labels: map[string]string{"team": "ops"}}); err == nil {
+		t.Fatalf("expected selector without matches to be rejected")
+	}
+	selected, err := SelectChecks(checks, CheckSelector{IDs: []string{"api", "db"}, Labels: map[string]string{"team": "core", "tier": "data"}})
+	if err != nil || len(selected) != 1 || selected[0].ID != "db" {
+		t.Fatalf("expected ids and labels to both apply, got %+v (%v)", selected, err)
+	}
+
+	selected, err = SelectChecks(checks, CheckSelector{Labels: map[string]string{"team": "core"}})
+	if err != nil {
+		t.Fatalf("select checks: %v", err)
+	}
+	r := newTestRunner(t, selected...)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = r.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.loopsMu.Lock()
+		started := make([]string, 0, len(r.loops))
+		for id := range r.loops {
+			started = append(started, id)
+		}
+		r.loopsMu.Unlock()
+		sort.Strings(started)
+		if len(started) == 2 {
+			if started[0] != "api" || started[1] != "db" {
+				t.Fatalf("expected loops for api and db only, got %v", started)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected two check loops, got %v", started)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+// cronSpec returns the schedule.cron expression of a check, or "".
+func cronSpec(check config.CheckConfig) string {
+	if check.Schedule == nil {
+		return ""
+	}
+	return strings.TrimSpace(check.Schedule.Cron)
+}
+
+// validateSchedules rejects cron expressions that don't parse and checks
+// that set both schedule.interval and schedule.cron.
+func validateSchedules(checks []config.CheckConfig) error {
+	for _, check := range checks {
+		spec := cronSpec(check)
+		if spec == "" {
+			continue
+		}
+		if check.Schedule.Interval != nil && check.Schedule.Interval.Set {
+			return fmt.Errorf("check %q: schedule.interval and schedule.cron are mutually exclusive", check.ID)
+		}
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return fmt.Errorf("check %q: parse cron %q: %w", check.ID, spec, err)
+		}
+	}
+	return nil
+}
+
+// runCronLoop runs the check at every activation of its cron schedule,
+// evaluated in the service timezone. Unlike interval checks, it does not run
+// at startup.
+func (r *Runner) runCronLoop(ctx context.Context, check config.CheckConfig, spec string) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		r.logger.Error("invalid check cron", "check_id", check.ID, "cron", spec, "error", err)
+		return
+	}
+	jitter := r.effectiveJitter(check)
+	r.logger.Info("starting check loop", "check_id", check.ID, "cron", spec, "jitter", jitter)
+
+	for {
+		next := schedule.Next(time.Now().In(r.location))
+		if next.IsZero() {
+			r.logger.Warn("check cron has no further activations", "check_id", check.ID, "cron", spec)
+			return
+		}
+		sleepContext(ctx, time.Until(next))
+		if ctx.Err() != nil {
+			r.logger.Info("stopping check loop", "check_id", check.ID)
+			return
+		}
+		r.executeJittered(ctx, check, jitter)
+	}
+}
@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/osbits/upupup/worker/internal/notifier"
+)
+
+// ReloadSecrets swaps in freshly resolved secrets and the notifiers built
+// from them, so rotated credentials take effect without a restart. Check runs
+// and notifications already started keep the snapshot they began with.
+func (r *Runner) ReloadSecrets(secrets map[string]string, reg *notifier.Registry) error {
+	if reg == nil {
+		return errors.New("notifier registry is required")
+	}
+	warnDisabledNotifiers(r.logger, reg, r.cfg.NotificationPolicies, r.cfg.Service.Environment)
+	r.secretsMu.Lock()
+	r.secrets = secrets
+	r.notifiers = reg
+	r.secretsMu.Unlock()
+	return nil
+}
+
+func (r *Runner) currentSecrets() map[string]string {
+	r.secretsMu.RLock()
+	defer r.secretsMu.RUnlock()
+	return r.secrets
+}
+
+func (r *Runner) notifierRegistry() *notifier.Registry {
+	r.secretsMu.RLock()
+	defer r.secretsMu.RUnlock()
+	return r.notifiers
+}
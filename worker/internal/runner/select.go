@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// CheckSelector restricts the checks a runner runs, e.g. to debug one check.
+// A check is selected when its id is listed (or no ids are) and it carries
+// every listed label. The zero value selects every check.
+type CheckSelector struct {
+	IDs    []string
+	Labels map[string]string
+}
+
+// Empty reports whether the selector selects every check.
+func (s CheckSelector) Empty() bool {
+	return len(s.IDs) == 0 && len(s.Labels) == 0
+}
+
+// Matches reports whether the selector selects check.
+func (s CheckSelector) Matches(check config.CheckConfig) bool {
+	if len(s.IDs) > 0 && !slices.Contains(s.IDs, check.ID) {
+		return false
+	}
+	for key, value := range s.Labels {
+		if got, ok := check.Labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectChecks returns the checks the selector selects. It fails when a
+// listed id names no check or nothing is selected, so a typo doesn't start
+// a worker that runs nothing.
+func SelectChecks(checks []config.CheckConfig, sel CheckSelector) ([]config.CheckConfig, error) {
+	if sel.Empty() {
+		return checks, nil
+	}
+	for _, id := range sel.IDs {
+		if !containsCheck(checks, id) {
+			return nil, fmt.Errorf("unknown check %q", id)
+		}
+	}
+	var selected []config.CheckConfig
+	for _, check := range checks {
+		if sel.Matches(check) {
+			selected = append(selected, check)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no check matches %s", sel)
+	}
+	return selected, nil
+}
+
+// String describes the selector as the flags that would build it.
+func (s CheckSelector) String() string {
+	var parts []string
+	for _, id := range s.IDs {
+		parts = append(parts, "-check "+id)
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for key := range s.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, "-tag "+key+"="+s.Labels[key])
+	}
+	return strings.Join(parts, " ")
+}
+
+func containsCheck(checks []config.CheckConfig, id string) bool {
+	for _, check := range checks {
+		if check.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"sort"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+// CheckStatus is a point-in-time view of a check exposed by the admin listener.
+type CheckStatus struct {
+	CheckID   string
+	CheckName string
+	Success   bool
+	Failing   bool
+	// Maintenance is set while the target answers with its maintenance page.
+	Maintenance bool
+	// Pending is set while a metrics check waits for its first snapshot.
+	Pending bool
+	Reason  string
+	Latency time.Duration
+	LastRun time.Time
+}
+
+// CheckStatuses returns the latest status of every check that has run,
+// ordered by check id.
+func (r *Runner) CheckStatuses() []CheckStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	statuses := make([]CheckStatus, 0, len(r.statuses))
+	for _, status := range r.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].CheckID < statuses[j].CheckID
+	})
+	return statuses
+}
+
+func (r *Runner) recordStatus(check config.CheckConfig, result checks.Result, failing bool) {
+	lastRun := result.CompletedAt
+	if lastRun.IsZero() {
+		lastRun = time.Now()
+	}
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.statuses[check.ID] = CheckStatus{
+		CheckID:     check.ID,
+		CheckName:   check.Name,
+		Success:     result.Success,
+		Failing:     failing,
+		Maintenance: result.Maintenance,
+		Pending:     result.Pending,
+		Reason:      result.Reason,
+		Latency:     result.Latency,
+		LastRun:     lastRun,
+	}
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+const defaultStorageRetryInterval = 30 * time.Second
+
+// Store returns the attached store, or nil while the runner works without
+// storage.
+func (r *Runner) Store() *storage.Store {
+	return r.currentStore()
+}
+
+func (r *Runner) currentStore() *storage.Store {
+	r.storeMu.RLock()
+	defer r.storeMu.RUnlock()
+	return r.store
+}
+
+// RecoverStorage calls open every interval while the runner has no store and
+// attaches the first store it returns. Until then the runner is degraded:
+// checks run and notifications fire, but alert state lives in memory only,
+// nothing is persisted and hooks are not applied. It returns once a store is
+// attached or ctx is done.
+func (r *Runner) RecoverStorage(ctx context.Context, open func() (*storage.Store, error), interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStorageRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for attempt := 1; r.currentStore() == nil; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		store, err := open()
+		if err != nil {
+			r.logger.Warn("storage unavailable, running degraded", "attempt", attempt, "error", err)
+			continue
+		}
+		r.attachStore(store)
+		r.logger.Info("storage recovered", "attempts", attempt)
+	}
+}
+
+func (r *Runner) attachStore(store *storage.Store) {
+	r.storeMu.Lock()
+	r.store = store
+	r.storeMu.Unlock()
+	r.loadDeliveries()
+	r.invalidateHookCache()
+}
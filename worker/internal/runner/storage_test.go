@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/notifier"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func TestRunnerWorksWithoutStoreAndRecovers(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(srv.Close)
+	check := config.CheckConfig{
+		ID:            "api",
+		Type:          "http",
+		Target:        srv.URL,
+		Assertions:    []config.Assertion{{Kind: "status_code", Op: "equals", Value: 200}},
+		Notifications: config.CheckNotification{Route: "ops"},
+	}
+	cfg := testConfig(check)
+	cfg.NotificationPolicies = []config.NotificationPolicy{{
+		ID:               "ops",
+		Stages:           []config.PolicyStage{{Notifiers: []string{"pager"}}},
+		ResolveNotifiers: []string{"pager"},
+	}}
+	pager := newRecordingNotifier("pager")
+	reg := notifier.NewRegistry()
+	if err := reg.Add(pager); err != nil {
+		t.Fatalf("add notifier: %v", err)
+	}
+	r := newTestRunnerWith(t, cfg, reg, nil)
+
+	r.executeCheck(context.Background(), check)
+	if event := pager.expectEvent(t); event.Status != "firing" {
+		t.Fatalf("expected firing notification without a store, got %q", event.Status)
+	}
+	if !r.getState(check.ID).Failing {
+		t.Fatalf("expected in-memory state to track the failure")
+	}
+
+	path := filepath.Join(t.TempDir(), "monitor.db")
+	var attempts atomic.Int32
+	var opened *storage.Store
+	open := func() (*storage.Store, error) {
+		if attempts.Add(1) < 3 {
+			return nil, errors.New("disk full")
+		}
+		opened = openTestStore(t, path)
+		return opened, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r.RecoverStorage(ctx, open, time.Millisecond)
+	if r.Store() == nil || r.Store() != opened || attempts.Load() != 3 {
+		t.Fatalf("expected store attached on the third attempt, got %d attempts", attempts.Load())
+	}
+
+	status.Store(http.StatusOK)
+	r.executeCheck(context.Background(), check)
+	if event := pager.expectEvent(t); event.Status != "resolved" {
+		t.Fatalf("expected in-memory failure to resolve after recovery, got %q", event.Status)
+	}
+	total, failed, err := opened.RecentOutcomeCounts(context.Background(), check.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("recent outcomes: %v", err)
+	}
+	if total != 1 || failed != 0 {
+		t.Fatalf("expected only the run after recovery to be stored, got total=%d failed=%d", total, failed)
+	}
+}
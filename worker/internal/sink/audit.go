@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// AuditSinkID identifies the audit stream in logs.
+const AuditSinkID = "audit"
+
+// AuditRecord is the compact JSON form of a check run sent to the audit
+// stream.
+type AuditRecord struct {
+	CheckID    string    `json:"check_id"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason,omitempty"`
+	LatencyMS  int64     `json:"latency_ms"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// NewAuditSink streams every check run, passing or failing, to
+// cfg.WebhookURL as a JSON array of audit records. Notifications are not
+// sent. Batching, retries and dropping records when the queue is full work
+// as for the http sink. The URL and header values may reference secrets.
+func NewAuditSink(cfg config.AuditConfig, secrets map[string]string, engine *render.Engine, logger *slog.Logger) (Sink, error) {
+	if engine == nil {
+		engine = render.New()
+	}
+	url, err := engine.RenderString(cfg.WebhookURL, render.TemplateContext{Secrets: secrets})
+	if err != nil {
+		return nil, fmt.Errorf("render webhook_url: %w", err)
+	}
+	s, err := newHTTPSink(AuditSinkID, HTTPConfig{
+		URL:           url,
+		Headers:       cfg.Headers,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: cfg.FlushInterval.Duration,
+		MaxRetries:    cfg.MaxRetries,
+		RetryBackoff:  cfg.RetryBackoff.Duration,
+		Timeout:       cfg.Timeout.Duration,
+		QueueSize:     cfg.QueueSize,
+	}, secrets, engine, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.accept = func(record Record) bool {
+		return record.CheckRun != nil
+	}
+	s.encode = encodeAudit
+	go s.loop()
+	return s, nil
+}
+
+func encodeAudit(batch []Record) ([]byte, error) {
+	records := make([]AuditRecord, 0, len(batch))
+	for _, record := range batch {
+		run := record.CheckRun
+		records = append(records, AuditRecord{
+			CheckID:    run.CheckID,
+			Success:    run.Success,
+			Reason:     run.Reason,
+			LatencyMS:  run.LatencyMS,
+			OccurredAt: run.OccurredAt,
+		})
+	}
+	return json.Marshal(records)
+}
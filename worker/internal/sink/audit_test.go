@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/checks"
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+func TestAuditSinkDeliversPassingAndFailingRuns(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records []AuditRecord
+		auth    string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []AuditRecord
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decode batch: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		records = append(records, batch...)
+		auth = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := NewAuditSink(config.AuditConfig{
+		WebhookURL:    srv.URL,
+		Headers:       map[string]string{"Authorization": `Bearer {{ secret "AUDIT_TOKEN" }}`},
+		FlushInterval: config.Duration{Duration: time.Hour},
+	}, map[string]string{"AUDIT_TOKEN": "s3cret"}, nil, nil)
+	if err != nil {
+		t.Fatalf("new audit sink: %v", err)
+	}
+	now := time.Now()
+	s.Publish(CheckRunRecord(storage.CheckRun{CheckID: "api", Success: true, Latency: 80 * time.Millisecond, OccurredAt: now}))
+	s.Publish(NotificationRecord(storage.NotificationLog{NotifierID: "pager", CheckID: "api", Status: "firing", OccurredAt: now}))
+	s.Publish(CheckRunRecord(storage.CheckRun{CheckID: "api", Success: false, Reason: checks.ReasonStatusMismatch, Latency: 95 * time.Millisecond, OccurredAt: now}))
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 2 {
+		t.Fatalf("expected both runs and no notification, got %+v", records)
+	}
+	if !records[0].Success || records[0].LatencyMS != 80 || records[0].Reason != "" {
+		t.Fatalf("unexpected passing record: %+v", records[0])
+	}
+	if records[1].Success || records[1].Reason != checks.ReasonStatusMismatch || records[1].CheckID != "api" {
+		t.Fatalf("unexpected failing record: %+v", records[1])
+	}
+	if auth != "Bearer s3cret" {
+		t.Fatalf("expected rendered auth header, got %q", auth)
+	}
+}
@@ -0,0 +1,216 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/render"
+)
+
+// HTTPConfig configures a sink that POSTs batches of records as a JSON array.
+type HTTPConfig struct {
+	URL           string            `mapstructure:"url"`
+	Headers       map[string]string `mapstructure:"headers"`
+	BatchSize     int               `mapstructure:"batch_size"`
+	FlushInterval time.Duration     `mapstructure:"flush_interval"`
+	MaxRetries    int               `mapstructure:"max_retries"`
+	RetryBackoff  time.Duration     `mapstructure:"retry_backoff"`
+	Timeout       time.Duration     `mapstructure:"timeout"`
+	QueueSize     int               `mapstructure:"queue_size"`
+}
+
+type httpSink struct {
+	id      string
+	cfg     HTTPConfig
+	headers map[string]string
+	client  *http.Client
+	logger  *slog.Logger
+	queue   chan Record
+	done    chan struct{}
+	// accept and encode let other sinks reuse the delivery loop; nil
+	// accepts every record and encodes batches as a JSON array of records.
+	accept func(Record) bool
+	encode func([]Record) ([]byte, error)
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewHTTPSink creates an HTTP sink and starts its delivery loop. Header values
+// may reference secrets via {{ secret "NAME" }}.
+func NewHTTPSink(id string, cfg HTTPConfig, secrets map[string]string, engine *render.Engine, logger *slog.Logger) (Sink, error) {
+	s, err := newHTTPSink(id, cfg, secrets, engine, logger)
+	if err != nil {
+		return nil, err
+	}
+	go s.loop()
+	return s, nil
+}
+
+func newHTTPSink(id string, cfg HTTPConfig, secrets map[string]string, engine *render.Engine, logger *slog.Logger) (*httpSink, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, errors.New("url is required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	headers := cfg.Headers
+	if len(headers) > 0 {
+		if engine == nil {
+			engine = render.New()
+		}
+		rendered, err := render.RenderMap(headers, render.TemplateContext{Secrets: secrets}, engine)
+		if err != nil {
+			return nil, fmt.Errorf("render headers: %w", err)
+		}
+		headers = rendered
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &httpSink{
+		id:      id,
+		cfg:     cfg,
+		headers: headers,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		logger:  logger,
+		queue:   make(chan Record, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+	return s, nil
+}
+
+func (s *httpSink) ID() string {
+	return s.id
+}
+
+// Publish enqueues the record, dropping it when the queue is full so a slow
+// endpoint never stalls checks.
+func (s *httpSink) Publish(record Record) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed || (s.accept != nil && !s.accept(record)) {
+		return
+	}
+	select {
+	case s.queue <- record:
+	default:
+		s.logger.Warn("sink queue full, dropping record", "sink_id", s.id, "type", record.Type)
+	}
+}
+
+// Close stops accepting records and waits for pending batches to be sent.
+func (s *httpSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *httpSink) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, s.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.send(batch); err != nil {
+			s.logger.Error("sink delivery failed", "sink_id", s.id, "records", len(batch), "error", err)
+		}
+		batch = make([]Record, 0, s.cfg.BatchSize)
+	}
+	for {
+		select {
+		case record, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *httpSink) send(batch []Record) error {
+	encode := s.encode
+	if encode == nil {
+		encode = func(batch []Record) ([]byte, error) { return json.Marshal(batch) }
+	}
+	payload, err := encode(batch)
+	if err != nil {
+		return fmt.Errorf("encode batch: %w", err)
+	}
+	var lastErr error
+	for attempt := 0; attempt <= s.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(s.cfg.RetryBackoff * time.Duration(attempt))
+		}
+		if lastErr = s.post(payload); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (s *httpSink) post(payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sink response: %s", resp.Status)
+	}
+	return nil
+}
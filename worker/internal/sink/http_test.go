@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]Record
+	headers []http.Header
+}
+
+func (b *batchRecorder) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var batch []Record
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decode batch: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b.mu.Lock()
+		b.batches = append(b.batches, batch)
+		b.headers = append(b.headers, r.Header.Clone())
+		b.mu.Unlock()
+	}
+}
+
+func (b *batchRecorder) sizes() []int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sizes := make([]int, 0, len(b.batches))
+	for _, batch := range b.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestHTTPSinkBatchesRecords(t *testing.T) {
+	recorder := &batchRecorder{}
+	srv := httptest.NewServer(recorder.handler(t))
+	t.Cleanup(srv.Close)
+
+	s, err := NewHTTPSink("events", HTTPConfig{
+		URL:           srv.URL,
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+		Headers:       map[string]string{"Authorization": `Bearer {{ secret "SINK_TOKEN" }}`},
+	}, map[string]string{"SINK_TOKEN": "s3cret"}, nil, nil)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	for i := 0; i < 7; i++ {
+		s.Publish(CheckRunRecord(storage.CheckRun{CheckID: "api", Success: i%2 == 0, Latency: 120 * time.Millisecond, OccurredAt: time.Now()}))
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	sizes := recorder.sizes()
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Fatalf("expected batches of 3, 3 and 1, got %v", sizes)
+	}
+	first := recorder.batches[0][0]
+	if first.Type != "check_run" || first.CheckRun == nil || first.CheckRun.CheckID != "api" || first.CheckRun.LatencyMS != 120 {
+		t.Fatalf("unexpected record: %+v", first)
+	}
+	if got := recorder.headers[0].Get("Authorization"); got != "Bearer s3cret" {
+		t.Fatalf("expected rendered auth header, got %q", got)
+	}
+}
+
+func TestHTTPSinkFlushesOnIntervalAndRetries(t *testing.T) {
+	recorder := &batchRecorder{}
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		recorder.handler(t)(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := NewHTTPSink("events", HTTPConfig{
+		URL:           srv.URL,
+		BatchSize:     100,
+		FlushInterval: 20 * time.Millisecond,
+		MaxRetries:    2,
+		RetryBackoff:  10 * time.Millisecond,
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close(context.Background())
+	})
+	s.Publish(NotificationRecord(storage.NotificationLog{NotifierID: "pager", CheckID: "api", Status: "firing", OccurredAt: time.Now()}))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sizes := recorder.sizes()
+	if len(sizes) != 1 || sizes[0] != 1 {
+		t.Fatalf("expected single retried batch, got %v", sizes)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("expected one retry, got %d attempts", attempts.Load())
+	}
+	if record := recorder.batches[0][0]; record.Type != "notification" || record.Notification.NotifierID != "pager" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+}
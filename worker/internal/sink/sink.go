@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/osbits/upupup/worker/internal/config"
+	"github.com/osbits/upupup/worker/internal/render"
+	"github.com/osbits/upupup/worker/internal/storage"
+)
+
+// Record is a single check run or notification streamed to sinks.
+type Record struct {
+	Type         string        `json:"type"`
+	CheckRun     *CheckRun     `json:"check_run,omitempty"`
+	Notification *Notification `json:"notification,omitempty"`
+}
+
+// CheckRun is the JSON form of a persisted check run.
+type CheckRun struct {
+	CheckID    string    `json:"check_id"`
+	CheckName  string    `json:"check_name"`
+	Success    bool      `json:"success"`
+	Summary    string    `json:"summary,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	LatencyMS  int64     `json:"latency_ms"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Notification is the JSON form of a notification log entry.
+type Notification struct {
+	NotifierID  string            `json:"notifier_id"`
+	CheckID     string            `json:"check_id"`
+	CheckName   string            `json:"check_name"`
+	RunID       string            `json:"run_id,omitempty"`
+	IncidentID  string            `json:"incident_id,omitempty"`
+	Status      string            `json:"status"`
+	Severity    string            `json:"severity,omitempty"`
+	Summary     string            `json:"summary,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	OccurredAt  time.Time         `json:"occurred_at"`
+	ErrorClass  string            `json:"error_class,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	DeliveredBy string            `json:"delivered_by,omitempty"`
+	Skipped     string            `json:"skipped,omitempty"`
+}
+
+// CheckRunRecord wraps a stored check run.
+func CheckRunRecord(run storage.CheckRun) Record {
+	return Record{
+		Type: "check_run",
+		CheckRun: &CheckRun{
+			CheckID:    run.CheckID,
+			CheckName:  run.CheckName,
+			Success:    run.Success,
+			Summary:    run.Summary,
+			Error:      run.Error,
+			Reason:     run.Reason,
+			LatencyMS:  int64(run.Latency / time.Millisecond),
+			OccurredAt: run.OccurredAt.UTC(),
+		},
+	}
+}
+
+// NotificationRecord wraps a stored notification log entry.
+func NotificationRecord(entry storage.NotificationLog) Record {
+	return Record{
+		Type: "notification",
+		Notification: &Notification{
+			NotifierID:  entry.NotifierID,
+			CheckID:     entry.CheckID,
+			CheckName:   entry.CheckName,
+			RunID:       entry.RunID,
+			IncidentID:  entry.IncidentID,
+			Status:      entry.Status,
+			Severity:    entry.Severity,
+			Summary:     entry.Summary,
+			Labels:      entry.Labels,
+			OccurredAt:  entry.OccurredAt.UTC(),
+			ErrorClass:  entry.ErrorClass,
+			Error:       entry.Error,
+			DeliveredBy: entry.DeliveredBy,
+			Skipped:     entry.Skipped,
+		},
+	}
+}
+
+// Sink receives records next to sqlite persistence. Publish must not block
+// the caller; Close flushes pending records until ctx is done.
+type Sink interface {
+	ID() string
+	Publish(record Record)
+	Close(ctx context.Context) error
+}
+
+// Factory carries the shared dependencies of sinks.
+type Factory struct {
+	Secrets map[string]string
+	Render  *render.Engine
+	Logger  *slog.Logger
+}
+
+// Build constructs sinks from config. When one fails, the sinks already
+// built are closed, so their delivery loops do not outlive the error.
+func Build(factory Factory, configs []config.SinkConfig) ([]Sink, error) {
+	if factory.Logger == nil {
+		factory.Logger = slog.Default()
+	}
+	sinks := make([]Sink, 0, len(configs))
+	seen := map[string]bool{}
+	for _, cfg := range configs {
+		if seen[cfg.ID] {
+			closeAll(factory.Logger, sinks)
+			return nil, fmt.Errorf("duplicate sink %q", cfg.ID)
+		}
+		seen[cfg.ID] = true
+		s, err := buildSink(factory, cfg)
+		if err != nil {
+			closeAll(factory.Logger, sinks)
+			return nil, fmt.Errorf("sink %q: %w", cfg.ID, err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// closeAll closes the sinks built before a later one failed.
+func closeAll(logger *slog.Logger, sinks []Sink) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, s := range sinks {
+		if err := s.Close(ctx); err != nil {
+			logger.Warn("failed to close sink", "sink_id", s.ID(), "error", err)
+		}
+	}
+}
+
+func buildSink(factory Factory, cfg config.SinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case "http":
+		var hc HTTPConfig
+		if err := decode(cfg.Config, &hc); err != nil {
+			return nil, err
+		}
+		return NewHTTPSink(cfg.ID, hc, factory.Secrets, factory.Render, factory.Logger)
+	default:
+		return nil, fmt.Errorf("unsupported sink type %q", cfg.Type)
+	}
+}
+
+func decode(input map[string]interface{}, target interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		Result:           target,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
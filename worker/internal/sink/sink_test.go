@@ -0,0 +1,29 @@
+package sink
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/osbits/upupup/worker/internal/config"
+)
+
+func TestBuildClosesBuiltSinksWhenOneFails(t *testing.T) {
+	before := runtime.NumGoroutine()
+	configs := []config.SinkConfig{
+		{ID: "first", Type: "http", Config: map[string]interface{}{"url": "http://127.0.0.1:1/first"}},
+		{ID: "second", Type: "http", Config: map[string]interface{}{"url": "http://127.0.0.1:1/second"}},
+		{ID: "broken", Type: "kafka"},
+	}
+	if _, err := Build(Factory{}, configs); err == nil {
+		t.Fatalf("expected the unsupported sink to fail the build")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the delivery loops of the built sinks to stop, %d goroutines left of %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const checkBaselinesTableDDL = `
+CREATE TABLE IF NOT EXISTS check_baselines (
+	check_id TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	value REAL NOT NULL,
+	established_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (check_id, metric)
+);
+`
+
+// CheckBaseline is the reference value a baseline_deviation assertion
+// compares a check's metric against.
+type CheckBaseline struct {
+	CheckID       string
+	Metric        string
+	Value         float64
+	EstablishedAt time.Time
+}
+
+// SetCheckBaseline stores the baseline of a check metric, replacing any
+// previous one.
+func (s *Store) SetCheckBaseline(ctx context.Context, baseline CheckBaseline) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	if baseline.EstablishedAt.IsZero() {
+		baseline.EstablishedAt = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO check_baselines (check_id, metric, value, established_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(check_id, metric) DO UPDATE SET
+			value = excluded.value,
+			established_at = excluded.established_at
+	`, baseline.CheckID, baseline.Metric, baseline.Value, baseline.EstablishedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("upsert check baseline: %w", err)
+	}
+	return nil
+}
+
+// CheckBaselines returns the stored baselines of a check keyed by metric.
+func (s *Store) CheckBaselines(ctx context.Context, checkID string) (map[string]CheckBaseline, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT check_id, metric, value, established_at
+		FROM check_baselines
+		WHERE check_id = ?
+	`, checkID)
+	if err != nil {
+		return nil, fmt.Errorf("query check baselines: %w", err)
+	}
+	defer rows.Close()
+
+	result := map[string]CheckBaseline{}
+	for rows.Next() {
+		var baseline CheckBaseline
+		if err := rows.Scan(&baseline.CheckID, &baseline.Metric, &baseline.Value, &baseline.EstablishedAt); err != nil {
+			return nil, fmt.Errorf("scan check baseline: %w", err)
+		}
+		baseline.EstablishedAt = baseline.EstablishedAt.UTC()
+		result[baseline.Metric] = baseline
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate check baselines: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const checkFirstSeenTableDDL = `
+CREATE TABLE IF NOT EXISTS check_first_seen (
+	check_id TEXT NOT NULL,
+	node_id TEXT NOT NULL,
+	first_seen_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (check_id, node_id)
+);
+`
+
+// CheckFirstSeen returns when the check first found a snapshot of the node,
+// recording now if it has not been seen before.
+func (s *Store) CheckFirstSeen(ctx context.Context, checkID, nodeID string, now time.Time) (time.Time, error) {
+	if s == nil || s.db == nil {
+		return time.Time{}, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return time.Time{}, err
+	}
+	defer s.release()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO check_first_seen (check_id, node_id, first_seen_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(check_id, node_id) DO NOTHING
+	`, checkID, nodeID, now.UTC())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("insert check first seen: %w", err)
+	}
+	var firstSeen time.Time
+	err = s.db.QueryRowContext(ctx, `
+		SELECT first_seen_at FROM check_first_seen
+		WHERE check_id = ? AND node_id = ?
+	`, checkID, nodeID).Scan(&firstSeen)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query check first seen: %w", err)
+	}
+	return firstSeen, nil
+}
+
+// LookupCheckFirstSeen returns when the check first found a snapshot of the
+// node without recording anything. ok is false when it has not been seen.
+func (s *Store) LookupCheckFirstSeen(ctx context.Context, checkID, nodeID string) (firstSeen time.Time, ok bool, err error) {
+	if s == nil || s.db == nil {
+		return time.Time{}, false, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return time.Time{}, false, err
+	}
+	defer s.release()
+	err = s.db.QueryRowContext(ctx, `
+		SELECT first_seen_at FROM check_first_seen
+		WHERE check_id = ? AND node_id = ?
+	`, checkID, nodeID).Scan(&firstSeen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("query check first seen: %w", err)
+	}
+	return firstSeen, true, nil
+}
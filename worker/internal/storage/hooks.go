@@ -51,6 +51,10 @@ func (s *Store) ActiveHookExecutions(ctx context.Context, now time.Time) ([]Hook
 	if s == nil || s.db == nil {
 		return nil, errors.New("store not initialised")
 	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, hook_id, kind, scope, target_ids_json, requested_by, requested_from_ip,
 		       parameters_json, note, until_first_success, active_until, requested_at, status
@@ -107,6 +111,10 @@ func (s *Store) CompleteHookExecution(ctx context.Context, id int64) error {
 	if s == nil || s.db == nil {
 		return nil
 	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE hook_executions
 		SET status = 'completed'
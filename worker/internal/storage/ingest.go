@@ -16,6 +16,14 @@ CREATE TABLE IF NOT EXISTS node_metrics (
 	ingested_at TIMESTAMP NOT NULL,
 	source_ip TEXT
 );
+CREATE TABLE IF NOT EXISTS node_metrics_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	node_id TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	ingested_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_node_metrics_history_node ON node_metrics_history (node_id, ingested_at);
+CREATE INDEX IF NOT EXISTS idx_node_metrics_history_ingested ON node_metrics_history (ingested_at);
 `
 
 // NodeMetricSnapshot represents the latest metrics payload for a node.
@@ -31,6 +39,10 @@ func (s *Store) EnsureNodeMetricsSchema(ctx context.Context) error {
 	if s == nil || s.db == nil {
 		return errors.New("store not initialised")
 	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
 	if _, err := s.db.ExecContext(ctx, nodeMetricsTableDDL); err != nil {
 		return fmt.Errorf("ensure node metrics schema: %w", err)
 	}
@@ -42,6 +54,10 @@ func (s *Store) UpsertNodeMetrics(ctx context.Context, snapshot NodeMetricSnapsh
 	if s == nil || s.db == nil {
 		return errors.New("store not initialised")
 	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
 	nodeID := strings.TrimSpace(snapshot.NodeID)
 	if nodeID == "" {
 		return errors.New("node id is required")
@@ -78,6 +94,10 @@ func (s *Store) LatestNodeMetrics(ctx context.Context, nodeID string) (*NodeMetr
 	if s == nil || s.db == nil {
 		return nil, errors.New("store not initialised")
 	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
 	nodeID = strings.TrimSpace(nodeID)
 	if nodeID == "" {
 		return nil, errors.New("node id is required")
@@ -102,3 +122,44 @@ func (s *Store) LatestNodeMetrics(ctx context.Context, nodeID string) (*NodeMetr
 	snapshot.IngestedAt = snapshot.IngestedAt.UTC()
 	return &snapshot, nil
 }
+
+// NodeMetricsHistory returns the snapshots the server kept for the node since
+// the given time, oldest first. The server only keeps history when
+// server.ingest.history_retention is set.
+func (s *Store) NodeMetricsHistory(ctx context.Context, nodeID string, since time.Time) ([]NodeMetricSnapshot, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+	nodeID = strings.TrimSpace(nodeID)
+	if nodeID == "" {
+		return nil, errors.New("node id is required")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT node_id, payload, ingested_at
+		FROM node_metrics_history
+		WHERE node_id = ? AND ingested_at >= ?
+		ORDER BY ingested_at, id
+	`, nodeID, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query node metrics history: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []NodeMetricSnapshot
+	for rows.Next() {
+		var snapshot NodeMetricSnapshot
+		if err := rows.Scan(&snapshot.NodeID, &snapshot.Payload, &snapshot.IngestedAt); err != nil {
+			return nil, fmt.Errorf("scan node metrics history: %w", err)
+		}
+		snapshot.IngestedAt = snapshot.IngestedAt.UTC()
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate node metrics history: %w", err)
+	}
+	return snapshots, nil
+}
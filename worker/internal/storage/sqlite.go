@@ -9,22 +9,35 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// DefaultCheckStateRetention is how many check runs per check are kept when
+// Options.CheckStateRetention is unset.
+const DefaultCheckStateRetention = 30
+
 // Options configures storage behaviour.
 type Options struct {
 	CheckStateRetention   int
 	NotificationRetention int
 }
 
+// ErrClosed is returned by operations started after Close.
+var ErrClosed = errors.New("store closed")
+
 // Store wraps sqlite persistence for check runs and notifications.
 type Store struct {
 	db                *sql.DB
 	checkStateLimit   int
 	notificationLimit int
+
+	// closeMu is held for reading by every operation so Close can wait for
+	// writes in flight before closing the database.
+	closeMu sync.RWMutex
+	closed  bool
 }
 
 // CheckRun represents a persisted check execution result.
@@ -34,6 +47,7 @@ type CheckRun struct {
 	Success    bool
 	Summary    string
 	Error      string
+	Reason     string
 	Latency    time.Duration
 	OccurredAt time.Time
 }
@@ -44,11 +58,22 @@ type NotificationLog struct {
 	CheckID    string
 	CheckName  string
 	RunID      string
+	IncidentID string
 	Status     string
 	Severity   string
 	Summary    string
 	Labels     map[string]string
 	OccurredAt time.Time
+	// ErrorClass and Error describe a failed delivery; both are empty when
+	// the notifier accepted the event.
+	ErrorClass string
+	Error      string
+	// DeliveredBy names the notifier that delivered the event when
+	// NotifierID is a fallback notifier.
+	DeliveredBy string
+	// Skipped names why the event was not sent to the notifier, e.g.
+	// "min_severity" or "throttled"; empty for a delivery attempt.
+	Skipped string
 }
 
 // Open initialises a sqlite store with WAL enabled and required schema.
@@ -74,7 +99,7 @@ func Open(path string, opts Options) (*Store, error) {
 
 	checkLimit := opts.CheckStateRetention
 	if checkLimit <= 0 {
-		checkLimit = 30
+		checkLimit = DefaultCheckStateRetention
 	}
 	notificationLimit := opts.NotificationRetention
 	if notificationLimit <= 0 {
@@ -95,14 +120,35 @@ func Open(path string, opts Options) (*Store, error) {
 	return store, nil
 }
 
-// Close closes the underlying database connection.
+// Close waits for operations in flight and closes the underlying database
+// connection. Operations started afterwards fail with ErrClosed.
 func (s *Store) Close() error {
 	if s == nil || s.db == nil {
 		return nil
 	}
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
 	return s.db.Close()
 }
 
+// acquire registers an operation with Close. Callers release it when done.
+func (s *Store) acquire() error {
+	s.closeMu.RLock()
+	if s.closed {
+		s.closeMu.RUnlock()
+		return ErrClosed
+	}
+	return nil
+}
+
+func (s *Store) release() {
+	s.closeMu.RUnlock()
+}
+
 func configureSQLite(db *sql.DB) error {
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
@@ -131,7 +177,8 @@ func (s *Store) initSchema() error {
 			summary TEXT,
 			error TEXT,
 			latency_ms INTEGER,
-			occurred_at TIMESTAMP NOT NULL
+			occurred_at TIMESTAMP NOT NULL,
+			reason TEXT NOT NULL DEFAULT ''
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_check_states_check ON check_states (check_id, occurred_at DESC);`,
 		`CREATE TABLE IF NOT EXISTS notification_logs (
@@ -144,25 +191,139 @@ func (s *Store) initSchema() error {
 			severity TEXT,
 			summary TEXT,
 			labels_json TEXT,
-			occurred_at TIMESTAMP NOT NULL
+			occurred_at TIMESTAMP NOT NULL,
+			error_class TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			delivered_by TEXT NOT NULL DEFAULT '',
+			incident_id TEXT NOT NULL DEFAULT '',
+			skipped TEXT NOT NULL DEFAULT ''
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_notification_logs_occurred ON notification_logs (occurred_at DESC);`,
 		hookTableDDL,
 		nodeMetricsTableDDL,
+		notifierDeliveriesTableDDL,
+		checkTargetStatesTableDDL,
+		checkBaselinesTableDDL,
+		checkFirstSeenTableDDL,
 	}
 	for _, stmt := range stmts {
 		if _, err := s.db.Exec(stmt); err != nil {
 			return fmt.Errorf("init schema: %w", err)
 		}
 	}
+	if err := s.ensureColumn("check_states", "reason", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+	if err := s.ensureColumn("notification_logs", "error_class", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+	if err := s.ensureColumn("notification_logs", "error", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+	if err := s.ensureColumn("notification_logs", "delivered_by", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+	if err := s.ensureColumn("notification_logs", "incident_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
+	if err := s.ensureColumn("notification_logs", "skipped", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("init schema: %w", err)
+	}
 	return nil
 }
 
+// ensureColumn adds a column to tables created by an older release.
+func (s *Store) ensureColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return fmt.Errorf("inspect %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// RecentOutcomeCounts returns the number of runs and failed runs of a check since the given time.
+func (s *Store) RecentOutcomeCounts(ctx context.Context, checkID string, since time.Time) (total int, failed int, err error) {
+	if s == nil || s.db == nil {
+		return 0, 0, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return 0, 0, err
+	}
+	defer s.release()
+	row := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END), 0)
+		FROM check_states
+		WHERE check_id = ? AND occurred_at >= ?
+	`, checkID, since.UTC())
+	if err := row.Scan(&total, &failed); err != nil {
+		return 0, 0, fmt.Errorf("count recent outcomes: %w", err)
+	}
+	return total, failed, nil
+}
+
+// SuccessLatencies returns the latencies of a check's successful runs that
+// occurred in [from, to), oldest first.
+func (s *Store) SuccessLatencies(ctx context.Context, checkID string, from, to time.Time) ([]time.Duration, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT latency_ms
+		FROM check_states
+		WHERE check_id = ? AND success = 1 AND latency_ms IS NOT NULL AND occurred_at >= ? AND occurred_at < ?
+		ORDER BY occurred_at ASC
+	`, checkID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query latencies: %w", err)
+	}
+	defer rows.Close()
+	var latencies []time.Duration
+	for rows.Next() {
+		var ms int64
+		if err := rows.Scan(&ms); err != nil {
+			return nil, fmt.Errorf("scan latency: %w", err)
+		}
+		latencies = append(latencies, time.Duration(ms)*time.Millisecond)
+	}
+	return latencies, rows.Err()
+}
+
 // RecordCheckRun persists the outcome of a check execution and enforces retention.
 func (s *Store) RecordCheckRun(ctx context.Context, run CheckRun) error {
 	if s == nil || s.db == nil {
 		return nil
 	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
 	if run.OccurredAt.IsZero() {
 		run.OccurredAt = time.Now()
 	}
@@ -179,9 +340,9 @@ func (s *Store) RecordCheckRun(ctx context.Context, run CheckRun) error {
 	}()
 
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO check_states (check_id, check_name, success, summary, error, latency_ms, occurred_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, run.CheckID, run.CheckName, boolToInt(run.Success), run.Summary, run.Error, latency, run.OccurredAt.UTC())
+		INSERT INTO check_states (check_id, check_name, success, summary, error, latency_ms, occurred_at, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.CheckID, run.CheckName, boolToInt(run.Success), run.Summary, run.Error, latency, run.OccurredAt.UTC(), run.Reason)
 	if err != nil {
 		return fmt.Errorf("insert check_state: %w", err)
 	}
@@ -210,6 +371,10 @@ func (s *Store) RecordNotification(ctx context.Context, log NotificationLog) err
 	if s == nil || s.db == nil {
 		return nil
 	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
 	if log.OccurredAt.IsZero() {
 		log.OccurredAt = time.Now()
 	}
@@ -233,9 +398,9 @@ func (s *Store) RecordNotification(ctx context.Context, log NotificationLog) err
 	}()
 
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO notification_logs (notifier_id, check_id, check_name, run_id, status, severity, summary, labels_json, occurred_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, log.NotifierID, log.CheckID, log.CheckName, log.RunID, log.Status, log.Severity, log.Summary, labels, log.OccurredAt.UTC())
+		INSERT INTO notification_logs (notifier_id, check_id, check_name, run_id, status, severity, summary, labels_json, occurred_at, error_class, error, delivered_by, incident_id, skipped)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, log.NotifierID, log.CheckID, log.CheckName, log.RunID, log.Status, log.Severity, log.Summary, labels, log.OccurredAt.UTC(), log.ErrorClass, log.Error, log.DeliveredBy, log.IncidentID, log.Skipped)
 	if err != nil {
 		return fmt.Errorf("insert notification_log: %w", err)
 	}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseWaitsForWritesInFlight(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "monitor.db"), Options{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.RecordCheckRun(context.Background(), CheckRun{
+				CheckID:    "api",
+				CheckName:  "API",
+				OccurredAt: time.Now(),
+			})
+		}()
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil && !errors.Is(err, ErrClosed) {
+			t.Fatalf("expected write to complete or fail with ErrClosed, got %v", err)
+		}
+	}
+	if err := store.RecordNotification(context.Background(), NotificationLog{NotifierID: "pager", CheckID: "api"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after close, got %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const checkTargetStatesTableDDL = `
+CREATE TABLE IF NOT EXISTS check_target_states (
+	check_id TEXT NOT NULL,
+	target TEXT NOT NULL,
+	success INTEGER NOT NULL,
+	latency_ms INTEGER NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	occurred_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (check_id, target)
+);
+`
+
+// CheckTargetState is the latest outcome against one backend of a pooled check.
+type CheckTargetState struct {
+	CheckID    string
+	Target     string
+	Success    bool
+	Latency    time.Duration
+	Error      string
+	OccurredAt time.Time
+}
+
+// ReplaceCheckTargets stores the per-target results of the latest run of a
+// check, dropping targets that are no longer part of the pool.
+func (s *Store) ReplaceCheckTargets(ctx context.Context, checkID string, targets []CheckTargetState) error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM check_target_states WHERE check_id = ?`, checkID); err != nil {
+		return fmt.Errorf("clear check targets: %w", err)
+	}
+	for _, target := range targets {
+		occurredAt := target.OccurredAt
+		if occurredAt.IsZero() {
+			occurredAt = time.Now()
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO check_target_states (check_id, target, success, latency_ms, error, occurred_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, checkID, target.Target, boolToInt(target.Success), int64(target.Latency/time.Millisecond), target.Error, occurredAt.UTC())
+		if err != nil {
+			return fmt.Errorf("insert check target: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit check targets: %w", err)
+	}
+	return nil
+}
+
+// CheckTargets returns the stored per-target results of a check ordered by target.
+func (s *Store) CheckTargets(ctx context.Context, checkID string) ([]CheckTargetState, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT check_id, target, success, latency_ms, error, occurred_at
+		FROM check_target_states
+		WHERE check_id = ?
+		ORDER BY target
+	`, checkID)
+	if err != nil {
+		return nil, fmt.Errorf("query check targets: %w", err)
+	}
+	defer rows.Close()
+
+	var result []CheckTargetState
+	for rows.Next() {
+		var (
+			state     CheckTargetState
+			success   int
+			latencyMS int64
+		)
+		if err := rows.Scan(&state.CheckID, &state.Target, &success, &latencyMS, &state.Error, &state.OccurredAt); err != nil {
+			return nil, fmt.Errorf("scan check target: %w", err)
+		}
+		state.Success = success != 0
+		state.Latency = time.Duration(latencyMS) * time.Millisecond
+		state.OccurredAt = state.OccurredAt.UTC()
+		result = append(result, state)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate check targets: %w", err)
+	}
+	return result, nil
+}
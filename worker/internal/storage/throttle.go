@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const notifierDeliveriesTableDDL = `
+CREATE TABLE IF NOT EXISTS notifier_deliveries (
+	notifier_id TEXT NOT NULL,
+	check_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	last_sent_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (notifier_id, check_id, status)
+);
+`
+
+// NotifierDelivery records when a notifier last sent a status for a check so
+// throttling windows survive restarts.
+type NotifierDelivery struct {
+	NotifierID string
+	CheckID    string
+	Status     string
+	LastSentAt time.Time
+}
+
+// RecordNotifierDelivery stores the latest delivery time for the notifier, check and status.
+func (s *Store) RecordNotifierDelivery(ctx context.Context, delivery NotifierDelivery) error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	if delivery.LastSentAt.IsZero() {
+		delivery.LastSentAt = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notifier_deliveries (notifier_id, check_id, status, last_sent_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(notifier_id, check_id, status) DO UPDATE SET
+			last_sent_at = excluded.last_sent_at
+	`, delivery.NotifierID, delivery.CheckID, delivery.Status, delivery.LastSentAt.UTC())
+	if err != nil {
+		return fmt.Errorf("upsert notifier delivery: %w", err)
+	}
+	return nil
+}
+
+// NotifierDeliveries returns the deliveries sent since the given time.
+func (s *Store) NotifierDeliveries(ctx context.Context, since time.Time) ([]NotifierDelivery, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialised")
+	}
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT notifier_id, check_id, status, last_sent_at
+		FROM notifier_deliveries
+		WHERE last_sent_at >= ?
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query notifier deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []NotifierDelivery
+	for rows.Next() {
+		var delivery NotifierDelivery
+		if err := rows.Scan(&delivery.NotifierID, &delivery.CheckID, &delivery.Status, &delivery.LastSentAt); err != nil {
+			return nil, fmt.Errorf("scan notifier delivery: %w", err)
+		}
+		delivery.LastSentAt = delivery.LastSentAt.UTC()
+		result = append(result, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate notifier deliveries: %w", err)
+	}
+	return result, nil
+}